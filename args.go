@@ -0,0 +1,138 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badwolf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// bindArgs replaces the $1, $2, ... placeholders of the provided statement
+// with the BQL representation of the matching arguments. Placeholders inside
+// quoted text are left untouched. It fails if a placeholder has no argument or
+// an argument is never referenced. Each argument becomes a single token, so it
+// cannot alter the structure of the statement. When arguments are nodes or
+// literals used as the subjects or objects of graph clauses, the statement
+// cache binds them to the statement parsed for the first arguments instead of
+// parsing the query again.
+func bindArgs(q string, args []interface{}) (string, error) {
+	if len(args) == 0 {
+		return q, nil
+	}
+	used := make([]bool, len(args))
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(q):
+			b.WriteByte(c)
+			i++
+			c = q[i]
+		case c == '"':
+			quoted = !quoted
+		case c == '$' && !quoted:
+			j := i + 1
+			for j < len(q) && q[j] >= '0' && q[j] <= '9' {
+				j++
+			}
+			if j == i+1 {
+				break
+			}
+			n, err := strconv.Atoi(q[i+1 : j])
+			if err != nil || n < 1 || n > len(args) {
+				return "", fmt.Errorf("badwolf: no argument provided for placeholder %s", q[i:j])
+			}
+			v, err := argument(args[n-1])
+			if err != nil {
+				return "", fmt.Errorf("badwolf: invalid argument for placeholder %s; %v", q[i:j], err)
+			}
+			used[n-1] = true
+			b.WriteString(v)
+			i = j - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	for i, u := range used {
+		if !u {
+			return "", fmt.Errorf("badwolf: argument %d is not referenced by any placeholder", i+1)
+		}
+	}
+	return b.String(), nil
+}
+
+// argument returns the BQL representation of the provided argument. It fails
+// if the argument is not lexed back as a single token holding it, such as text
+// containing quotes, which BQL cannot represent.
+func argument(a interface{}) (string, error) {
+	var (
+		l   *literal.Literal
+		err error
+	)
+	switch v := a.(type) {
+	case *node.Node:
+		return singleToken(v.String(), lexer.ItemNode)
+	case *predicate.Predicate:
+		return singleToken(v.String(), lexer.ItemPredicate)
+	case *literal.Literal:
+		l = v
+	case string:
+		l, err = literal.DefaultBuilder().Build(literal.Text, v)
+	case int:
+		l, err = literal.DefaultBuilder().Build(literal.Int64, int64(v))
+	case int64:
+		l, err = literal.DefaultBuilder().Build(literal.Int64, v)
+	case float64:
+		l, err = literal.DefaultBuilder().Build(literal.Float64, v)
+	case bool:
+		l, err = literal.DefaultBuilder().Build(literal.Bool, v)
+	case time.Time:
+		// Time anchors are only valid inside predicates; render them so they
+		// can be used in time bounds, as in "knows"@[$1,$2].
+		return v.Format(time.RFC3339Nano), nil
+	default:
+		return "", fmt.Errorf("unsupported argument type %T", a)
+	}
+	if err != nil {
+		return "", err
+	}
+	return singleToken(l.String(), lexer.ItemLiteral)
+}
+
+// singleToken returns the provided text if it is lexed as a single token of the
+// given type.
+func singleToken(txt string, tt lexer.TokenType) (string, error) {
+	n := 0
+	ok := true
+	for tkn := range lexer.New(txt, 0) {
+		if tkn.Type == lexer.ItemEOF {
+			continue
+		}
+		n++
+		ok = ok && tkn.Type == tt && tkn.Text == txt
+	}
+	if n != 1 || !ok {
+		return "", fmt.Errorf("%s cannot be bound as a single BQL value", txt)
+	}
+	return txt, nil
+}
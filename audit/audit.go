@@ -0,0 +1,194 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit provides structured logging hooks for the statements executed
+// against BadWolf stores. Each execution is described by an Entry holding the
+// statement text, the identity of the caller taken from the context, the
+// graphs it read and wrote, its duration, and the number of rows it returned,
+// so services can keep the audit trails regulated environments require.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// callerKey is the context key the caller identity is stored under.
+type callerKey struct{}
+
+// WithCaller returns a copy of the context carrying the identity of the caller
+// issuing statements, such as a user or service name.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// Caller returns the identity of the caller stored on the context, or an
+// empty string if there is none.
+func Caller(ctx context.Context) string {
+	c, _ := ctx.Value(callerKey{}).(string)
+	return c
+}
+
+// Entry describes the execution of a statement.
+type Entry struct {
+	// Time contains when the execution started.
+	Time time.Time
+
+	// Caller contains the identity of the caller, if known.
+	Caller string
+
+	// Statement contains the text of the executed statement.
+	Statement string
+
+	// Type contains the type of the executed statement.
+	Type semantic.StatementType
+
+	// ReadGraphs and WrittenGraphs contain the graphs the statement retrieved
+	// data from, and created, removed, or modified.
+	ReadGraphs    []string
+	WrittenGraphs []string
+
+	// Duration contains how long the execution took.
+	Duration time.Duration
+
+	// Rows contains the number of rows returned.
+	Rows int
+
+	// Err contains the error the execution failed with, if any.
+	Err error
+}
+
+// NewEntry returns the entry for a statement whose execution started at the
+// provided time and just finished. The caller is taken from the context.
+func NewEntry(ctx context.Context, q string, stm *semantic.Statement, start time.Time, rows int, err error) *Entry {
+	return &Entry{
+		Time:          start,
+		Caller:        Caller(ctx),
+		Statement:     q,
+		Type:          stm.Type(),
+		ReadGraphs:    stm.ReadGraphs(),
+		WrittenGraphs: stm.WrittenGraphs(),
+		Duration:      time.Since(start),
+		Rows:          rows,
+		Err:           err,
+	}
+}
+
+// Logger receives the entries describing each executed statement.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Log(e *Entry)
+}
+
+// LoggerFunc adapts a function to the Logger interface.
+type LoggerFunc func(e *Entry)
+
+// Log calls the function.
+func (f LoggerFunc) Log(e *Entry) {
+	f(e)
+}
+
+// Redacting returns a logger that replaces the values of the literals on the
+// statements with *** before passing the entries to the provided logger. The
+// type of the literals is preserved.
+func Redacting(l Logger) Logger {
+	return LoggerFunc(func(e *Entry) {
+		re := *e
+		re.Statement = Redact(e.Statement)
+		l.Log(&re)
+	})
+}
+
+// Redact returns the statement with the values of its literals replaced by
+// ***, as in "***"^^type:int64. The lexer stops on the first error, so the
+// text after the last token lexed, which may hold literals, is replaced by
+// *** as a whole.
+func Redact(q string) string {
+	spans, _ := lexer.Scan(q, lexer.Options{})
+	var b strings.Builder
+	last, lexed := 0, 0
+	for _, s := range spans {
+		if s.Type == lexer.ItemError {
+			b.WriteString(q[last:lexed])
+			b.WriteString(" ***")
+			return b.String()
+		}
+		lexed = s.End
+		if s.Type != lexer.ItemLiteral {
+			continue
+		}
+		i := strings.LastIndex(s.Text, `"^^`)
+		if i < 0 {
+			continue
+		}
+		b.WriteString(q[last:s.Start])
+		b.WriteString(`"***`)
+		b.WriteString(s.Text[i:])
+		last = s.End
+	}
+	b.WriteString(q[last:])
+	return b.String()
+}
+
+// jsonEntry contains the JSON encoding of an entry.
+type jsonEntry struct {
+	Time          time.Time `json:"time"`
+	Caller        string    `json:"caller,omitempty"`
+	Statement     string    `json:"statement"`
+	Type          string    `json:"type"`
+	ReadGraphs    []string  `json:"read_graphs,omitempty"`
+	WrittenGraphs []string  `json:"written_graphs,omitempty"`
+	Duration      float64   `json:"duration_seconds"`
+	Rows          int       `json:"rows"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// jsonLogger writes entries as JSON objects, one per line.
+type jsonLogger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONLogger returns a logger that writes each entry to the writer as a
+// JSON object on its own line. Errors writing the entries are ignored.
+func NewJSONLogger(w io.Writer) Logger {
+	return &jsonLogger{enc: json.NewEncoder(w)}
+}
+
+// Log writes the entry.
+func (l *jsonLogger) Log(e *Entry) {
+	je := &jsonEntry{
+		Time:          e.Time.UTC(),
+		Caller:        e.Caller,
+		Statement:     e.Statement,
+		Type:          e.Type.String(),
+		ReadGraphs:    e.ReadGraphs,
+		WrittenGraphs: e.WrittenGraphs,
+		Duration:      e.Duration.Seconds(),
+		Rows:          e.Rows,
+	}
+	if e.Err != nil {
+		je.Error = e.Err.Error()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(je)
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/bql"
+)
+
+func TestCaller(t *testing.T) {
+	ctx := context.Background()
+	if got := Caller(ctx); got != "" {
+		t.Errorf("audit.Caller returned %q for a context without caller", got)
+	}
+	if got, want := Caller(WithCaller(ctx, "joe")), "joe"; got != want {
+		t.Errorf("audit.Caller returned %q; want %q", got, want)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	table := []struct {
+		q, want string
+	}{
+		{
+			q:    `select ?s from ?g where {?s "age"@[] "42"^^type:int64};`,
+			want: `select ?s from ?g where {?s "age"@[] "***"^^type:int64};`,
+		},
+		{
+			q:    `insert data into ?g {/u<joe> "pin"@[] "1234"^^type:text . /u<joe> "name"@[] "Joe \"J\" Doe"^^type:text};`,
+			want: `insert data into ?g {/u<joe> "pin"@[] "***"^^type:text . /u<joe> "name"@[] "***"^^type:text};`,
+		},
+		{
+			q:    `select ?s from ?g where {?s ?p ?o};`,
+			want: `select ?s from ?g where {?s ?p ?o};`,
+		},
+		{
+			q:    `insert data into ?g {/u<joe> "pin"@[] "1234"^^type:text . /u<joe> "pin"@[] "5678^^type:text};`,
+			want: `insert data into ?g {/u<joe> "pin"@[] "***"^^type:text . /u<joe> "pin"@[] ***`,
+		},
+		{
+			q:    `insert data into ?g {/u<joe> "pin"@[] "1234"^^type:text . /u<joe> "pin"@[ "5678"^^type:text};`,
+			want: `insert data into ?g {/u<joe> "pin"@[] "***"^^type:text . /u<joe> ***`,
+		},
+	}
+	for _, entry := range table {
+		if got := Redact(entry.q); got != entry.want {
+			t.Errorf("audit.Redact(%q) = %q; want %q", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	c, err := bql.NewCache(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := `select ?s from ?g where {?s "age"@[] "42"^^type:int64};`
+	stm, err := c.Statement(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	l := Redacting(NewJSONLogger(&buf))
+	ctx := WithCaller(context.Background(), "joe")
+	start := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.Log(NewEntry(ctx, q, stm, start, 3, nil))
+	l.Log(NewEntry(ctx, q, stm, start, 0, errors.New("boom")))
+
+	dec := json.NewDecoder(&buf)
+	var got []map[string]interface{}
+	for dec.More() {
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err != nil {
+			t.Fatalf("JSON logger wrote an invalid entry; %v", err)
+		}
+		delete(m, "duration_seconds")
+		got = append(got, m)
+	}
+	want := []map[string]interface{}{
+		{
+			"time":        "2016-01-02T03:04:05Z",
+			"caller":      "joe",
+			"statement":   `select ?s from ?g where {?s "age"@[] "***"^^type:int64};`,
+			"type":        "QUERY",
+			"read_graphs": []interface{}{"?g"},
+			"rows":        float64(3),
+		},
+		{
+			"time":        "2016-01-02T03:04:05Z",
+			"caller":      "joe",
+			"statement":   `select ?s from ?g where {?s "age"@[] "***"^^type:int64};`,
+			"type":        "QUERY",
+			"read_graphs": []interface{}{"?g"},
+			"rows":        float64(0),
+			"error":       "boom",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON logger wrote %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench generates synthetic graphs and measures the latency of a
+// standard suite of queries against them. It helps comparing storage drivers
+// and evaluating planner changes on the same workload.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+const (
+	// batchSize contains the number of triples added to the graph at once.
+	batchSize = 1000
+
+	// cacheSize contains the number of parsed queries kept while running the
+	// suite.
+	cacheSize = 1024
+)
+
+// GraphOptions configures the synthetic graphs. Graphs contain users that
+// follow other users; each user also has a name and an age.
+type GraphOptions struct {
+	// Users contains the number of users in the graph.
+	Users int
+
+	// FanOut contains the number of users each user follows. It must be
+	// smaller than the number of users.
+	FanOut int
+
+	// TemporalDensity contains the fraction, between 0 and 1, of the follow
+	// edges that use the temporal "followed" predicate instead of the
+	// immutable "follows" one.
+	TemporalDensity float64
+
+	// Start and Span define the period the time anchors of the temporal
+	// predicates are spread over.
+	Start time.Time
+	Span  time.Duration
+
+	// Seed seeds the random generator, so the same options always generate
+	// the same graph.
+	Seed int64
+}
+
+// DefaultGraphOptions returns the options of a graph of a thousand users that
+// follow ten users each, half of them using temporal predicates spread over a
+// year.
+func DefaultGraphOptions() GraphOptions {
+	return GraphOptions{
+		Users:           1000,
+		FanOut:          10,
+		TemporalDensity: 0.5,
+		Start:           time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Span:            365 * 24 * time.Hour,
+		Seed:            1,
+	}
+}
+
+// validate checks that the options describe a valid graph.
+func (o GraphOptions) validate() error {
+	if o.Users < 2 || o.FanOut < 0 || o.FanOut >= o.Users || o.TemporalDensity < 0 || o.TemporalDensity > 1 || o.Span < 0 {
+		return fmt.Errorf("invalid graph options %+v", o)
+	}
+	return nil
+}
+
+// user returns the node of the i-th user.
+func user(i int) *node.Node {
+	n, _ := node.NewNodeFromStrings("/user", strconv.Itoa(i))
+	return n
+}
+
+// Generate adds the synthetic graph described by the options to the provided
+// graph, and returns the number of triples added.
+func Generate(g storage.Graph, opts GraphOptions) (int, error) {
+	if err := opts.validate(); err != nil {
+		return 0, fmt.Errorf("bench.Generate: %v", err)
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	b := literal.DefaultBuilder()
+	follows, err := predicate.NewImmutable("follows")
+	if err != nil {
+		return 0, err
+	}
+	name, err := predicate.NewImmutable("name")
+	if err != nil {
+		return 0, err
+	}
+	age, err := predicate.NewImmutable("age")
+	if err != nil {
+		return 0, err
+	}
+	var (
+		ts  []*triple.Triple
+		cnt int
+	)
+	add := func(s *node.Node, p *predicate.Predicate, o *triple.Object) error {
+		t, err := triple.New(s, p, o)
+		if err != nil {
+			return err
+		}
+		if ts = append(ts, t); len(ts) == batchSize {
+			if err := g.AddTriples(ts); err != nil {
+				return err
+			}
+			cnt, ts = cnt+len(ts), nil
+		}
+		return nil
+	}
+	for i := 0; i < opts.Users; i++ {
+		u := user(i)
+		nl, err := b.Build(literal.Text, fmt.Sprintf("user %d", i))
+		if err != nil {
+			return cnt, err
+		}
+		if err := add(u, name, triple.NewLiteralObject(nl)); err != nil {
+			return cnt, err
+		}
+		al, err := b.Build(literal.Int64, int64(18+rnd.Intn(60)))
+		if err != nil {
+			return cnt, err
+		}
+		if err := add(u, age, triple.NewLiteralObject(al)); err != nil {
+			return cnt, err
+		}
+		seen := map[int]bool{i: true}
+		for len(seen) <= opts.FanOut {
+			f := rnd.Intn(opts.Users)
+			if seen[f] {
+				continue
+			}
+			seen[f] = true
+			p := follows
+			if rnd.Float64() < opts.TemporalDensity {
+				at := opts.Start.Add(time.Duration(rnd.Int63n(int64(opts.Span) + 1))).Truncate(time.Second)
+				if p, err = predicate.NewTemporal("followed", at); err != nil {
+					return cnt, err
+				}
+			}
+			if err := add(u, p, triple.NewNodeObject(user(f))); err != nil {
+				return cnt, err
+			}
+		}
+	}
+	if len(ts) > 0 {
+		if err := g.AddTriples(ts); err != nil {
+			return cnt, err
+		}
+		cnt += len(ts)
+	}
+	return cnt, nil
+}
+
+// Query is a query of the suite. Statement returns the BQL statement to run
+// on each run, so runs can look up different users.
+type Query struct {
+	Name      string
+	Statement func(run int) string
+}
+
+// Suite returns the standard query suite for a graph generated using the
+// provided options: point lookups, one and two hop joins, temporal ranges,
+// and a full scan aggregation.
+func Suite(graph string, opts GraphOptions) []*Query {
+	g := graph
+	if g == "" || g[0] != '?' {
+		g = strconv.Quote(graph)
+	}
+	// Runs spread the lookups over the users using a large prime stride.
+	u := func(run int) string {
+		return user(run * 7919 % opts.Users).String()
+	}
+	window := opts.Span / 12
+	anchor := func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return []*Query{
+		{
+			Name: "point lookup",
+			Statement: func(run int) string {
+				return fmt.Sprintf(`select ?n from %s where {%s "name"@[] ?n};`, g, u(run))
+			},
+		},
+		{
+			Name: "one hop",
+			Statement: func(run int) string {
+				return fmt.Sprintf(`select ?f from %s where {%s "follows"@[] ?f};`, g, u(run))
+			},
+		},
+		{
+			Name: "two hop join",
+			Statement: func(run int) string {
+				return fmt.Sprintf(`select ?f, ?n from %s where {%s "follows"@[] ?f . ?f "follows"@[] ?ff . ?ff "name"@[] ?n};`, g, u(run))
+			},
+		},
+		{
+			Name: "temporal range",
+			Statement: func(run int) string {
+				from := opts.Start.Add(time.Duration(run%12) * window)
+				return fmt.Sprintf(`select ?f from %s where {%s "followed"@[%s,%s] ?f};`, g, u(run), anchor(from), anchor(from.Add(window)))
+			},
+		},
+		{
+			Name: "temporal range scan",
+			Statement: func(run int) string {
+				from := opts.Start.Add(time.Duration(run%12) * window)
+				return fmt.Sprintf(`select ?u, ?f from %s where {?u "followed"@[%s,%s] ?f};`, g, anchor(from), anchor(from.Add(window)))
+			},
+		},
+		{
+			Name: "scan aggregation",
+			Statement: func(run int) string {
+				return fmt.Sprintf(`select ?a, count(?u) as ?n from %s where {?u "age"@[] ?a} group by ?a;`, g)
+			},
+		},
+	}
+}
+
+// Result contains the latencies measured running a query of the suite.
+type Result struct {
+	Name string
+
+	// Runs contains the number of times the query was run.
+	Runs int
+
+	// Rows contains the total number of rows returned by all the runs.
+	Rows int
+
+	// Latencies contains the duration of each run.
+	Latencies []time.Duration
+}
+
+// Percentile returns the latency below which the provided percentage, between
+// 0 and 100, of the runs fall. It uses the nearest rank method.
+func (r *Result) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	ls := append([]time.Duration{}, r.Latencies...)
+	sort.Slice(ls, func(i, j int) bool { return ls[i] < ls[j] })
+	i := int(p/100*float64(len(ls))+0.5) - 1
+	switch {
+	case i < 0:
+		i = 0
+	case i >= len(ls):
+		i = len(ls) - 1
+	}
+	return ls[i]
+}
+
+// Run runs each query of the suite the provided number of times against the
+// store, and returns the latencies measured. It stops on the first query that
+// fails.
+func Run(ctx context.Context, store storage.Store, qs []*Query, runs int) ([]*Result, error) {
+	if runs <= 0 {
+		return nil, fmt.Errorf("bench.Run: invalid number of runs %d", runs)
+	}
+	c, err := bql.NewCache(cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	var res []*Result
+	for _, q := range qs {
+		r := &Result{Name: q.Name}
+		for i := 0; i < runs; i++ {
+			stm := q.Statement(i)
+			start := time.Now()
+			pln, err := c.Plan(store, stm)
+			if err != nil {
+				return res, fmt.Errorf("bench.Run: failed to plan %q with error %v", stm, err)
+			}
+			tbl, err := pln.Excecute(ctx)
+			if err != nil {
+				return res, fmt.Errorf("bench.Run: failed to run %q with error %v", stm, err)
+			}
+			r.Latencies = append(r.Latencies, time.Since(start))
+			r.Runs++
+			r.Rows += tbl.NumRows()
+			if err := tbl.Close(); err != nil {
+				return res, fmt.Errorf("bench.Run: failed to run %q with error %v", stm, err)
+			}
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// WriteReport writes a table with the latency percentiles of each result.
+func WriteReport(w io.Writer, rs []*Result) error {
+	if _, err := fmt.Fprintf(w, "%-20s %6s %10s %12s %12s %12s %12s\n", "QUERY", "RUNS", "ROWS/RUN", "P50", "P90", "P99", "MAX"); err != nil {
+		return err
+	}
+	for _, r := range rs {
+		rows := 0.0
+		if r.Runs > 0 {
+			rows = float64(r.Rows) / float64(r.Runs)
+		}
+		if _, err := fmt.Fprintf(w, "%-20s %6d %10.1f %12v %12v %12v %12v\n", r.Name, r.Runs, rows,
+			r.Percentile(50), r.Percentile(90), r.Percentile(99), r.Percentile(100)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+)
+
+// newGraph returns a new empty graph on the provided store.
+func newGraph(t *testing.T, s storage.Store, id string) storage.Graph {
+	t.Helper()
+	g, err := s.NewGraph(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestGenerate(t *testing.T) {
+	opts := DefaultGraphOptions()
+	opts.Users, opts.FanOut = 50, 5
+	s := memory.NewStore()
+	g1, g2 := newGraph(t, s, "?a"), newGraph(t, s, "?b")
+	for _, g := range []storage.Graph{g1, g2} {
+		n, err := Generate(g, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := opts.Users * (2 + opts.FanOut); n != want {
+			t.Errorf("bench.Generate added %d triples, want %d", n, want)
+		}
+	}
+	d, err := bio.DiffGraphs(g1, g2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Equal() {
+		t.Errorf("bench.Generate should generate the same graph given the same seed")
+	}
+
+	for _, o := range []GraphOptions{
+		{Users: 1},
+		{Users: 10, FanOut: 10},
+		{Users: 10, FanOut: 2, TemporalDensity: 1.5},
+	} {
+		if _, err := Generate(newGraph(t, s, "?c"), o); err == nil {
+			t.Errorf("bench.Generate should have rejected options %+v", o)
+		}
+		s.DeleteGraph("?c")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	r := &Result{}
+	for i := 10; i > 0; i-- {
+		r.Latencies = append(r.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	table := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 5 * time.Millisecond},
+		{90, 9 * time.Millisecond},
+		{99, 10 * time.Millisecond},
+		{100, 10 * time.Millisecond},
+	}
+	for _, entry := range table {
+		if got := r.Percentile(entry.p); got != entry.want {
+			t.Errorf("Result.Percentile(%v) returned %v, want %v", entry.p, got, entry.want)
+		}
+	}
+	if got := (&Result{}).Percentile(50); got != 0 {
+		t.Errorf("Result.Percentile for no runs returned %v, want 0", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	opts := DefaultGraphOptions()
+	opts.Users, opts.FanOut = 100, 4
+	s := memory.NewStore()
+	if _, err := Generate(newGraph(t, s, "/bench/graph"), opts); err != nil {
+		t.Fatal(err)
+	}
+	qs := Suite("/bench/graph", opts)
+	rs, err := Run(context.Background(), s, qs, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != len(qs) {
+		t.Fatalf("bench.Run returned %d results, want %d", len(rs), len(qs))
+	}
+	for _, r := range rs {
+		if r.Runs != 12 || len(r.Latencies) != 12 || r.Rows == 0 {
+			t.Errorf("bench.Run returned %d runs and %d rows for %q; want 12 runs returning rows", r.Runs, r.Rows, r.Name)
+		}
+	}
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, rs); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(buf.String(), "\n"), len(rs)+1; got != want {
+		t.Errorf("bench.WriteReport wrote %d lines, want %d:\n%s", got, want, buf.String())
+	}
+	if _, err := Run(context.Background(), s, qs, 0); err == nil {
+		t.Errorf("bench.Run should have rejected a non positive number of runs")
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bql
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// Access identifies how a statement uses a graph.
+type Access int8
+
+const (
+	// Read access retrieves data from a graph.
+	Read Access = iota
+	// Write access creates, removes, or modifies a graph.
+	Write
+)
+
+// String returns a readable name for the access.
+func (a Access) String() string {
+	switch a {
+	case Read:
+		return "READ"
+	case Write:
+		return "WRITE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Role lists the permissions granted to the callers holding it.
+type Role struct {
+	// Statements contains the types of the statements the role may run. All
+	// types are allowed if empty.
+	Statements []semantic.StatementType
+
+	// Read and Write contain the patterns of the graphs the role may read and
+	// write. Patterns follow path.Match, as in /prod/*, and * alone matches
+	// every graph.
+	Read  []string
+	Write []string
+}
+
+// allows returns true if the role may run statements of the provided type.
+func (r *Role) allows(t semantic.StatementType) bool {
+	if len(r.Statements) == 0 {
+		return true
+	}
+	for _, st := range r.Statements {
+		if st == t {
+			return true
+		}
+	}
+	return false
+}
+
+// grants returns true if the role grants the access to the provided graph.
+func (r *Role) grants(a Access, id string) bool {
+	ps := r.Read
+	if a == Write {
+		ps = r.Write
+	}
+	for _, p := range ps {
+		if p == "*" {
+			return true
+		}
+		if ok, err := path.Match(p, id); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy decides which statements callers may run based on the roles they
+// hold. A caller is allowed to run a statement if any of its roles allows the
+// statement type, and every graph the statement reads or writes is granted by
+// any of its roles. A nil policy allows everything.
+type Policy struct {
+	Roles map[string]*Role
+}
+
+// rolesKey is the context key the caller roles are stored under.
+type rolesKey struct{}
+
+// WithRoles returns a copy of the context carrying the roles of the caller.
+func WithRoles(ctx context.Context, roles ...string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+// Roles returns the roles of the caller stored on the context.
+func Roles(ctx context.Context) []string {
+	rs, _ := ctx.Value(rolesKey{}).([]string)
+	return rs
+}
+
+// AuthorizationError describes the rule a denied statement violated.
+type AuthorizationError struct {
+	// Roles contains the roles of the denied caller.
+	Roles []string
+
+	// Type contains the type of the denied statement. Accesses checked via
+	// AuthorizeAccess report Query for reads and Insert for writes.
+	Type semantic.StatementType
+
+	// Graph and Access contain the graph access no role granted. Graph is
+	// empty if the statement type itself was not allowed.
+	Graph  string
+	Access Access
+}
+
+// Rule returns the violated rule, as in "WRITE ?secret" or "statement DROP".
+func (e *AuthorizationError) Rule() string {
+	if e.Graph == "" {
+		return fmt.Sprintf("statement %s", e.Type)
+	}
+	return fmt.Sprintf("%s %s", e.Access, e.Graph)
+}
+
+// Error returns the description of the denial.
+func (e *AuthorizationError) Error() string {
+	if e.Graph == "" {
+		return fmt.Sprintf("bql.Policy: roles %v are not allowed to run %s statements", e.Roles, e.Type)
+	}
+	return fmt.Sprintf("bql.Policy: roles %v are not granted %s access to graph %q", e.Roles, e.Access, e.Graph)
+}
+
+// roles returns the policy roles held by the caller.
+func (p *Policy) roles(ctx context.Context) []*Role {
+	var rs []*Role
+	for _, n := range Roles(ctx) {
+		if r, ok := p.Roles[n]; ok {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// Authorize checks that the caller, identified by the roles on the context,
+// may run the provided statement. It returns an *AuthorizationError if the
+// statement is denied. It is meant to be called before planning statements.
+func (p *Policy) Authorize(ctx context.Context, stm *semantic.Statement) error {
+	if p == nil {
+		return nil
+	}
+	rs := p.roles(ctx)
+	allowed := false
+	for _, r := range rs {
+		allowed = allowed || r.allows(stm.Type())
+	}
+	if !allowed {
+		return &AuthorizationError{Roles: Roles(ctx), Type: stm.Type()}
+	}
+	for _, a := range []struct {
+		access Access
+		ids    []string
+	}{
+		{Read, stm.ReadGraphs()},
+		{Write, stm.WrittenGraphs()},
+	} {
+		for _, id := range a.ids {
+			if !grants(rs, a.access, id) {
+				return &AuthorizationError{Roles: Roles(ctx), Type: stm.Type(), Graph: id, Access: a.access}
+			}
+		}
+	}
+	return nil
+}
+
+// AuthorizeAccess checks that the caller, identified by the roles on the
+// context, is granted the access to the provided graph. Frontends use it to
+// guard the operations that do not go through BQL statements.
+func (p *Policy) AuthorizeAccess(ctx context.Context, a Access, id string) error {
+	if p == nil || grants(p.roles(ctx), a, id) {
+		return nil
+	}
+	t := semantic.Query
+	if a == Write {
+		t = semantic.Insert
+	}
+	return &AuthorizationError{Roles: Roles(ctx), Type: t, Graph: id, Access: a}
+}
+
+// grants returns true if any of the roles grants the access to the graph.
+func grants(rs []*Role, a Access, id string) bool {
+	for _, r := range rs {
+		if r.grants(a, id) {
+			return true
+		}
+	}
+	return false
+}
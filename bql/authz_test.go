@@ -0,0 +1,103 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/badwolf/bql/semantic"
+)
+
+func testPolicy() *Policy {
+	return &Policy{
+		Roles: map[string]*Role{
+			"analyst": {
+				Statements: []semantic.StatementType{semantic.Query},
+				Read:       []string{"/prod/*", "?public"},
+			},
+			"loader": {
+				Statements: []semantic.StatementType{semantic.Insert, semantic.Delete},
+				Write:      []string{"/staging/*"},
+			},
+			"admin": {
+				Read:  []string{"*"},
+				Write: []string{"*"},
+			},
+		},
+	}
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	c, err := NewCache(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := testPolicy()
+	table := []struct {
+		roles []string
+		q     string
+		rule  string
+	}{
+		{[]string{"analyst"}, `select ?s from "/prod/users" where {?s ?p ?o};`, ""},
+		{[]string{"analyst"}, `select ?s from "/prod/users", ?public where {?s ?p ?o};`, ""},
+		{[]string{"analyst"}, `select ?s from ?secret where {?s ?p ?o};`, "READ ?secret"},
+		{[]string{"analyst"}, `select ?s from "/prod/users/old" where {?s ?p ?o};`, "READ /prod/users/old"},
+		{[]string{"analyst"}, `insert data into "/prod/users" {/u<a> "p"@[] /u<b>};`, "statement INSERT"},
+		{[]string{"loader"}, `insert data into "/staging/users" {/u<a> "p"@[] /u<b>};`, ""},
+		{[]string{"loader"}, `insert data into "/prod/users" {/u<a> "p"@[] /u<b>};`, "WRITE /prod/users"},
+		{[]string{"loader"}, `drop graph "/staging/users";`, "statement DROP"},
+		{[]string{"analyst", "loader"}, `select ?s from "/prod/users" where {?s ?p ?o};`, ""},
+		{[]string{"admin"}, `drop graph ?anything;`, ""},
+		{[]string{"unknown"}, `select ?s from ?public where {?s ?p ?o};`, "statement QUERY"},
+		{nil, `select ?s from ?public where {?s ?p ?o};`, "statement QUERY"},
+	}
+	for _, entry := range table {
+		stm, err := c.Statement(entry.q)
+		if err != nil {
+			t.Fatalf("Cache.Statement(%q) failed; %v", entry.q, err)
+		}
+		err = p.Authorize(WithRoles(context.Background(), entry.roles...), stm)
+		if entry.rule == "" {
+			if err != nil {
+				t.Errorf("Policy.Authorize(%v, %q) failed; %v", entry.roles, entry.q, err)
+			}
+			continue
+		}
+		ae, ok := err.(*AuthorizationError)
+		if !ok {
+			t.Errorf("Policy.Authorize(%v, %q) = %v; want an *AuthorizationError", entry.roles, entry.q, err)
+			continue
+		}
+		if got := ae.Rule(); got != entry.rule {
+			t.Errorf("Policy.Authorize(%v, %q) violated rule %q; want %q", entry.roles, entry.q, got, entry.rule)
+		}
+	}
+}
+
+func TestPolicyAuthorizeAccess(t *testing.T) {
+	p := testPolicy()
+	ctx := WithRoles(context.Background(), "loader")
+	if err := p.AuthorizeAccess(ctx, Write, "/staging/users"); err != nil {
+		t.Errorf("Policy.AuthorizeAccess should have granted writing /staging/users; %v", err)
+	}
+	if err := p.AuthorizeAccess(ctx, Read, "/staging/users"); err == nil {
+		t.Errorf("Policy.AuthorizeAccess should have denied reading /staging/users")
+	}
+	var np *Policy
+	if err := np.AuthorizeAccess(ctx, Write, "?any"); err != nil {
+		t.Errorf("a nil policy should allow everything; %v", err)
+	}
+}
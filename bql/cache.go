@@ -0,0 +1,297 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bql provides helpers to run BQL statements shared by the services
+// built on top of the BQL parser and planner.
+package bql
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/metrics"
+	"github.com/google/badwolf/storage"
+)
+
+// Cache keeps the prepared statements resulting from parsing BQL queries, so
+// services issuing the same queries repeatedly do not need to lex, parse,
+// rewrite, and validate them each time. Queries are cached by their normalized
+// text, hence queries only differing in white spaces or the case of their
+// keywords share the same entry. When all the literals and nodes of a query
+// are the subjects or objects of its graph clauses, they are also
+// parameterized: queries only differing in those values share the same entry,
+// and their statements are obtained by binding their values to the cached one
+// instead of parsing them.
+// Queries using the now keyword are never cached, since their time bounds are
+// resolved while parsing them. The least recently used entries are evicted
+// once the cache is full. Cache is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	p       *grammar.Parser
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+	mc      metrics.Collector
+}
+
+// cacheEntry contains a cached statement and the key it was stored under.
+// Parameterized entries also contain the values the statement was parsed with
+// and the slots holding them.
+type cacheEntry struct {
+	key   string
+	prep  *planner.Prepared
+	param bool
+	vals  []string
+	slots []slot
+}
+
+// NewCache returns a new cache able to hold up to size statements.
+func NewCache(size int) (*Cache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("bql.NewCache: invalid cache size %d; it should be greater than 0", size)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		return nil, fmt.Errorf("bql.NewCache: failed to create the BQL parser with error %v", err)
+	}
+	return &Cache{
+		p:       p,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}, nil
+}
+
+// isKeyword returns true if the provided token type belongs to a keyword, and
+// hence its text is case insensitive.
+func isKeyword(tt lexer.TokenType) bool {
+	if tt == lexer.ItemHintName {
+		return true
+	}
+	_, ok := lexer.Keyword(tt)
+	return ok
+}
+
+// valuePlaceholder replaces the literals and nodes on the parameterized text of
+// queries. It cannot be lexed, so it never appears on the text of a query.
+const valuePlaceholder = "$"
+
+// canonicalQuery contains the canonical forms of a query.
+type canonicalQuery struct {
+	// text contains the normalized text of the query.
+	text string
+	// param contains the normalized text of the query with its literals and
+	// nodes replaced by placeholders.
+	param string
+	// vals contains the text of the literals and nodes of the query in order.
+	vals []string
+	// now is true if the query uses the now keyword.
+	now bool
+}
+
+// canonicalize returns the canonical forms of the provided query.
+func canonicalize(q string) (*canonicalQuery, error) {
+	var ts, ps []string
+	cq := &canonicalQuery{}
+	for tkn := range lexer.New(q, 0) {
+		t := tkn.Text
+		switch {
+		case tkn.Type == lexer.ItemError:
+			return nil, fmt.Errorf("bql.Normalize: failed to lex query %q with error %s", q, tkn.ErrorMessage)
+		case tkn.Type == lexer.ItemEOF:
+			continue
+		case isKeyword(tkn.Type):
+			t = strings.ToLower(t)
+		}
+		ts = append(ts, t)
+		switch tkn.Type {
+		case lexer.ItemLiteral, lexer.ItemNode:
+			cq.vals = append(cq.vals, t)
+			ps = append(ps, valuePlaceholder)
+		case lexer.ItemNow:
+			cq.now = true
+			fallthrough
+		default:
+			ps = append(ps, t)
+		}
+	}
+	cq.text, cq.param = strings.Join(ts, " "), strings.Join(ps, " ")
+	return cq, nil
+}
+
+// Normalize returns the canonical text of the provided query. Tokens are
+// separated by a single space and keywords are lower cased, while the text of
+// the rest of the tokens is preserved.
+func Normalize(q string) (string, error) {
+	cq, err := canonicalize(q)
+	if err != nil {
+		return "", err
+	}
+	return cq.text, nil
+}
+
+// equalValues returns true if both lists contain the same values.
+func equalValues(l1, l2 []string) bool {
+	if len(l1) != len(l2) {
+		return false
+	}
+	for i := range l1 {
+		if l1[i] != l2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the prepared statement cached for the provided query, if
+// any. Statements cached for queries only differing in their literals and
+// nodes are bound to the ones of the query.
+func (c *Cache) lookup(cq *canonicalQuery) (*planner.Prepared, bool, error) {
+	c.mu.Lock()
+	e, ok := c.entries[cq.param]
+	if !ok || !e.Value.(*cacheEntry).param {
+		e, ok = c.entries[cq.text]
+	}
+	if c.mc != nil {
+		c.mc.CacheLookup(ok)
+	}
+	if !ok {
+		c.mu.Unlock()
+		return nil, false, nil
+	}
+	c.lru.MoveToFront(e)
+	ce := e.Value.(*cacheEntry)
+	c.mu.Unlock()
+	if !ce.param || equalValues(ce.vals, cq.vals) {
+		return ce.prep, true, nil
+	}
+	stm, err := bindValues(ce.prep.Statement(), ce.slots, cq.vals)
+	if err != nil {
+		return nil, false, err
+	}
+	prep, err := planner.Prepare(stm)
+	if err != nil {
+		return nil, false, err
+	}
+	return prep, true, nil
+}
+
+// Prepare returns the prepared statement for the provided query, parsing it
+// only if it is not already cached. Only query statements are cached. The
+// returned statement is shared by all the callers requesting the same query
+// and must not be modified.
+func (c *Cache) Prepare(q string) (*planner.Prepared, error) {
+	cq, err := canonicalize(q)
+	if err != nil {
+		return nil, err
+	}
+	if !cq.now {
+		prep, ok, err := c.lookup(cq)
+		if err != nil {
+			return nil, fmt.Errorf("bql.Cache.Prepare: failed to bind the values of query %q with error %v", q, err)
+		}
+		if ok {
+			return prep, nil
+		}
+	}
+	stm := &semantic.Statement{}
+	if err := c.p.Parse(grammar.NewLLk(q, c.p.LookAhead()), stm); err != nil {
+		return nil, fmt.Errorf("bql.Cache.Prepare: failed to parse query %q with error %v", q, err)
+	}
+	prep, err := planner.Prepare(stm)
+	if err != nil {
+		return nil, err
+	}
+	if stm.Type() != semantic.Query || cq.now {
+		return prep, nil
+	}
+	ce := &cacheEntry{key: cq.text, prep: prep}
+	if slots, ok := valueSlots(stm, cq.vals); ok && len(slots) > 0 {
+		ce.key, ce.param, ce.vals, ce.slots = cq.param, true, cq.vals, slots
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[ce.key]; ok {
+		// Another caller cached the statement while it was being parsed.
+		c.lru.Remove(e)
+	}
+	c.entries[ce.key] = c.lru.PushFront(ce)
+	c.evict()
+	return prep, nil
+}
+
+// Statement returns the statement for the provided query, parsing it only if
+// it is not already cached; see Prepare. The returned statement is shared by
+// all the callers requesting the same query and must not be modified.
+func (c *Cache) Statement(q string) (*semantic.Statement, error) {
+	prep, err := c.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	return prep.Statement(), nil
+}
+
+// evict removes the least recently used entries until the cache fits its
+// size. It must be called holding the lock.
+func (c *Cache) evict() {
+	for c.lru.Len() > c.size {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*cacheEntry).key)
+	}
+}
+
+// Resize changes the number of statements the cache can hold, evicting the
+// least recently used ones if they no longer fit.
+func (c *Cache) Resize(size int) error {
+	if size <= 0 {
+		return fmt.Errorf("bql.Cache.Resize: invalid cache size %d; it should be greater than 0", size)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.evict()
+	return nil
+}
+
+// Plan returns a new executable plan for the provided query against the given
+// store, reusing the cached prepared statement if available.
+func (c *Cache) Plan(store storage.Store, q string) (planner.Excecutor, error) {
+	prep, err := c.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	return prep.Plan(store, planner.Limits{})
+}
+
+// SetCollector sets the collector the cache reports its lookups to. A nil
+// collector disables the reports.
+func (c *Cache) SetCollector(mc metrics.Collector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mc = mc
+}
+
+// Len returns the number of cached statements.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,407 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/io"
+	"github.com/google/badwolf/metrics"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestNormalize(t *testing.T) {
+	testTable := []struct {
+		q    string
+		want string
+	}{
+		{
+			q:    `select ?s from ?g where {?s ?p ?o};`,
+			want: `select ?s from ?g where { ?s ?p ?o } ;`,
+		},
+		{
+			q:    "SELECT   ?s\nFROM ?g WHERE {?s ?p ?o};",
+			want: `select ?s from ?g where { ?s ?p ?o } ;`,
+		},
+		{
+			q:    `select ?s from ?g where {?s "Foo"@[] "Bar"^^type:text} LIMIT "1"^^type:int64;`,
+			want: `select ?s from ?g where { ?s "Foo"@[] "Bar"^^type:text } limit "1"^^type:int64 ;`,
+		},
+		{
+			q:    `select ?s from ?g where {?s ?p ?o} HAVING NOT ?s AND ?p OR ?o;`,
+			want: `select ?s from ?g where { ?s ?p ?o } having not ?s and ?p or ?o ;`,
+		},
+		{
+			q:    `select ?s from ?g where {?s ?p ?o} having not ?s and ?p or ?o;`,
+			want: `select ?s from ?g where { ?s ?p ?o } having not ?s and ?p or ?o ;`,
+		},
+		{
+			q:    `PREFIX FOAF: /foaf SELECT ?s FROM ?g WHERE {?s "FOAF:knows"@[] FOAF:<joe>};`,
+			want: `prefix FOAF: /foaf select ?s from ?g where { ?s "FOAF:knows"@[] FOAF:<joe> } ;`,
+		},
+	}
+	for _, entry := range testTable {
+		got, err := Normalize(entry.q)
+		if err != nil {
+			t.Errorf("Normalize(%q) failed with error %v", entry.q, err)
+		}
+		if got != entry.want {
+			t.Errorf("Normalize(%q) returned the wrong text; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+	if _, err := Normalize(`select ?s from ?g where {?s /u<joe ?o};`); err == nil {
+		t.Errorf("Normalize should have failed for a query with an unterminated node")
+	}
+}
+
+func TestCacheStatement(t *testing.T) {
+	if _, err := NewCache(0); err == nil {
+		t.Errorf("NewCache(0) should have failed to create a cache with no room")
+	}
+	c, err := NewCache(2)
+	if err != nil {
+		t.Fatalf("NewCache(2) failed with error %v", err)
+	}
+	q1, q2, q3 := `select ?s from ?g where {?s ?p ?o};`, `select ?p from ?g where {?s ?p ?o};`, `select ?o from ?g where {?s ?p ?o};`
+	s1, err := c.Statement(q1)
+	if err != nil {
+		t.Fatalf("Cache.Statement(%q) failed with error %v", q1, err)
+	}
+	if s, err := c.Statement("SELECT ?s\n FROM ?g WHERE {?s ?p ?o};"); err != nil || s != s1 {
+		t.Errorf("Cache.Statement should have returned the cached statement for an equivalent query; got %p, want %p, error %v", s, s1, err)
+	}
+	if _, err := c.Statement(q2); err != nil {
+		t.Fatalf("Cache.Statement(%q) failed with error %v", q2, err)
+	}
+	// Refresh the first query so the second one becomes the least recently used.
+	if _, err := c.Statement(q1); err != nil {
+		t.Fatalf("Cache.Statement(%q) failed with error %v", q1, err)
+	}
+	if _, err := c.Statement(q3); err != nil {
+		t.Fatalf("Cache.Statement(%q) failed with error %v", q3, err)
+	}
+	if got, want := c.Len(), 2; got != want {
+		t.Errorf("Cache.Len returned the wrong number of entries; got %d, want %d", got, want)
+	}
+	if s, err := c.Statement(q1); err != nil || s != s1 {
+		t.Errorf("Cache.Statement should have kept the recently used statement for %q; got %p, want %p, error %v", q1, s, s1, err)
+	}
+	if _, ok := c.entries[`select ?p from ?g where { ?s ?p ?o } ;`]; ok {
+		t.Errorf("Cache.Statement should have evicted the least recently used statement for %q", q2)
+	}
+	if _, err := c.Statement(`create graph ?foo;`); err != nil {
+		t.Errorf("Cache.Statement failed to parse a create statement with error %v", err)
+	}
+	if got, want := c.Len(), 2; got != want {
+		t.Errorf("Cache.Statement should not cache non query statements; got %d entries, want %d", got, want)
+	}
+	if _, err := c.Statement(`select ?s from ?g where {?s ?p};`); err == nil {
+		t.Errorf("Cache.Statement should have failed to parse an invalid query")
+	}
+}
+
+func TestCacheResize(t *testing.T) {
+	c, err := NewCache(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q1, q2, q3 := `select ?s from ?g where {?s ?p ?o};`, `select ?p from ?g where {?s ?p ?o};`, `select ?o from ?g where {?s ?p ?o};`
+	for _, q := range []string{q1, q2, q3} {
+		if _, err := c.Statement(q); err != nil {
+			t.Fatalf("Cache.Statement(%q) failed with error %v", q, err)
+		}
+	}
+	if err := c.Resize(0); err == nil {
+		t.Errorf("Cache.Resize(0) should have failed")
+	}
+	if err := c.Resize(1); err != nil {
+		t.Fatalf("Cache.Resize(1) failed with error %v", err)
+	}
+	if got, want := c.Len(), 1; got != want {
+		t.Errorf("Cache.Resize(1) kept %d entries; want %d", got, want)
+	}
+	if _, ok := c.entries[`select ?o from ?g where { ?s ?p ?o } ;`]; !ok {
+		t.Errorf("Cache.Resize(1) should have kept the most recently used statement for %q", q3)
+	}
+}
+
+func TestCachePlan(t *testing.T) {
+	s := memory.NewStore()
+	g, err := s.NewGraph("?test")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	b := bytes.NewBufferString("/u<joe>\t\"parent_of\"@[]\t/u<mary>\n/u<joe>\t\"parent_of\"@[]\t/u<peter>\n")
+	if _, err := io.ReadIntoGraph(g, b, literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	c, err := NewCache(1)
+	if err != nil {
+		t.Fatalf("NewCache(1) failed with error %v", err)
+	}
+	q := `select ?o from ?test where {/u<joe> "parent_of"@[] ?o};`
+	for i := 0; i < 3; i++ {
+		pln, err := c.Plan(s, q)
+		if err != nil {
+			t.Fatalf("Cache.Plan(%q) failed with error %v", q, err)
+		}
+		tbl, err := pln.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("Excecute failed for query %q with error %v", q, err)
+		}
+		if got, want := tbl.NumRows(), 2; got != want {
+			t.Errorf("Cache.Plan returned a plan producing the wrong number of rows on run %d; got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestCacheCollector(t *testing.T) {
+	c, err := NewCache(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := metrics.NewCounters()
+	c.SetCollector(mc)
+	for _, q := range []string{
+		`select ?s from ?g where {?s ?p ?o};`,
+		`SELECT ?s FROM ?g WHERE {?s ?p ?o};`,
+		`select ?o from ?g where {?s ?p ?o};`,
+	} {
+		if _, err := c.Statement(q); err != nil {
+			t.Fatalf("Cache.Statement(%q) failed; %v", q, err)
+		}
+	}
+	s := mc.Snapshot()
+	if s.CacheHits != 1 || s.CacheMisses != 2 {
+		t.Errorf("Cache.Statement reported %d hits and %d misses; want 1 and 2", s.CacheHits, s.CacheMisses)
+	}
+}
+
+func TestCacheNow(t *testing.T) {
+	c, err := NewCache(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := `select ?s from ?g where {?s ?p ?o} after now - "24h"^^type:duration;`
+	s1, err := c.Statement(q)
+	if err != nil {
+		t.Fatalf("Cache.Statement(%q) failed with error %v", q, err)
+	}
+	s2, err := c.Statement(q)
+	if err != nil {
+		t.Fatalf("Cache.Statement(%q) failed with error %v", q, err)
+	}
+	if s1 == s2 {
+		t.Errorf("Cache.Statement(%q) should have parsed the query again to resolve now", q)
+	}
+	if got, want := c.Len(), 0; got != want {
+		t.Errorf("Cache.Statement should not cache queries using now; got %d entries, want %d", got, want)
+	}
+}
+
+func TestCacheParameterizedLiterals(t *testing.T) {
+	s := memory.NewStore()
+	g, err := s.NewGraph("?test")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	b := bytes.NewBufferString(`/u<joe>	"age"@[]	"42"^^type:int64
+/u<mary>	"age"@[]	"43"^^type:int64
+/u<mary>	"height"@[]	"43"^^type:int64
+`)
+	if _, err := io.ReadIntoGraph(g, b, literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	c, err := NewCache(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		q    string
+		want string
+	}{
+		{
+			q:    `select ?s from ?test where {?s "age"@[] "42"^^type:int64};`,
+			want: "/u<joe>",
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s "age"@[] "43"^^type:int64};`,
+			want: "/u<mary>",
+		},
+		{
+			q:    `select ?s from ?test where {?s "age"@[] "42"^^type:int64};`,
+			want: "/u<joe>",
+		},
+	}
+	var prep []interface{}
+	for _, entry := range testTable {
+		p, err := c.Prepare(entry.q)
+		if err != nil {
+			t.Fatalf("Cache.Prepare(%q) failed with error %v", entry.q, err)
+		}
+		prep = append(prep, p)
+		pln, err := p.Plan(s, planner.Limits{})
+		if err != nil {
+			t.Fatalf("Prepared.Plan(%q) failed with error %v", entry.q, err)
+		}
+		tbl, err := pln.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("Excecute failed for query %q with error %v", entry.q, err)
+		}
+		if tbl.NumRows() != 1 || tbl.Rows()[0]["?s"].String() != entry.want {
+			t.Errorf("Cache.Prepare(%q) returned the wrong statement; got rows %v, want %s", entry.q, tbl.Rows(), entry.want)
+		}
+	}
+	if got, want := c.Len(), 1; got != want {
+		t.Errorf("Cache.Prepare should have shared the entry for queries only differing in their literals; got %d entries, want %d", got, want)
+	}
+	if prep[0] != prep[2] {
+		t.Errorf("Cache.Prepare should have reused the prepared statement for the same literals")
+	}
+	if prep[0] == prep[1] {
+		t.Errorf("Cache.Prepare should have bound the literals of %q to a new statement", testTable[1].q)
+	}
+	stm, err := c.Statement(testTable[1].q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lits []string
+	for _, tkn := range stm.Tokens() {
+		if tkn.Type == lexer.ItemLiteral {
+			lits = append(lits, tkn.Text)
+		}
+	}
+	if got, want := fmt.Sprint(lits), `["43"^^type:int64]`; got != want {
+		t.Errorf("Cache.Statement(%q) returned a statement with the wrong literal tokens; got %s, want %s", testTable[1].q, got, want)
+	}
+
+	// Literals that are not the only match of a clause object are not
+	// parameterized.
+	for _, q := range []string{
+		`select ?s from ?test where {?s "age"@[] "43"^^type:int64. ?s "height"@[] "43"^^type:int64};`,
+		`select ?s, count(?o) as ?n from ?test where {?s ?p ?o} group by ?s having ?n > "1"^^type:int64;`,
+	} {
+		if _, err := c.Prepare(q); err != nil {
+			t.Fatalf("Cache.Prepare(%q) failed with error %v", q, err)
+		}
+		k, err := Normalize(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := c.entries[k]; !ok {
+			t.Errorf("Cache.Prepare(%q) should have cached the statement by its text", q)
+		}
+	}
+}
+
+func TestCacheParameterizedNodes(t *testing.T) {
+	s := memory.NewStore()
+	g, err := s.NewGraph("?test")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	b := bytes.NewBufferString(`/u<joe>	"knows"@[]	/u<mary>
+/u<mary>	"knows"@[]	/u<peter>
+`)
+	if _, err := io.ReadIntoGraph(g, b, literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	c, err := NewCache(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		q    string
+		want string
+	}{
+		{
+			q:    `select ?o from ?test where {/u<joe> "knows"@[] ?o};`,
+			want: "/u<mary>",
+		},
+		{
+			q:    `select ?o from ?test where {/u<mary> "knows"@[] ?o};`,
+			want: "/u<peter>",
+		},
+		{
+			q:    `select ?s from ?test where {?s "knows"@[] /u<peter>};`,
+			want: "/u<mary>",
+		},
+		{
+			q:    `select ?s from ?test where {?s "knows"@[] /u<mary>};`,
+			want: "/u<joe>",
+		},
+	}
+	for _, entry := range testTable {
+		p, err := c.Prepare(entry.q)
+		if err != nil {
+			t.Fatalf("Cache.Prepare(%q) failed with error %v", entry.q, err)
+		}
+		pln, err := p.Plan(s, planner.Limits{})
+		if err != nil {
+			t.Fatalf("Prepared.Plan(%q) failed with error %v", entry.q, err)
+		}
+		tbl, err := pln.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("Excecute failed for query %q with error %v", entry.q, err)
+		}
+		rs := tbl.Rows()
+		if len(rs) != 1 || rs[0][p.Statement().OutputBindings()[0]].String() != entry.want {
+			t.Errorf("Cache.Prepare(%q) returned the wrong statement; got rows %v, want %s", entry.q, rs, entry.want)
+		}
+	}
+	if got, want := c.Len(), 2; got != want {
+		t.Errorf("Cache.Prepare should have shared the entries for queries only differing in their nodes; got %d entries, want %d", got, want)
+	}
+}
+
+func TestCacheConcurrentParsing(t *testing.T) {
+	var cs []*Cache
+	for i := 0; i < 2; i++ {
+		c, err := NewCache(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs = append(cs, c)
+	}
+	var wg sync.WaitGroup
+	for i, c := range cs {
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func(c *Cache, n int) {
+				defer wg.Done()
+				for k := 0; k < 20; k++ {
+					q := fmt.Sprintf(`select ?s from ?g where {/u<n%d> "p%d"@[] ?s};`, n, k)
+					stm, err := c.Statement(q)
+					if err != nil {
+						t.Errorf("Cache.Statement(%q) failed with error %v", q, err)
+						return
+					}
+					cls := stm.OrderedGraphPatternClauses()
+					if len(cls) != 1 || cls[0].S.String() != fmt.Sprintf("/u<n%d>", n) {
+						t.Errorf("Cache.Statement(%q) returned the wrong clauses %v", q, cls)
+					}
+				}
+			}(c, i*10+j)
+		}
+	}
+	wg.Wait()
+}
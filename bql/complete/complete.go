@@ -0,0 +1,313 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package complete suggests continuations for partial BQL statements. It is
+// meant to back the autocompletion of interactive tools, such as REPLs and web
+// consoles.
+package complete
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// maxSampledTriples contains the maximum number of triples scanned on each
+// graph to collect the predicates to suggest.
+const maxSampledTriples = 10000
+
+// Kind identifies the kind of a candidate continuation.
+type Kind int8
+
+const (
+	// Keyword candidates are BQL keywords.
+	Keyword Kind = iota
+	// Symbol candidates are punctuation tokens, such as { or ;.
+	Symbol
+	// Graph candidates are the names of graphs in the store.
+	Graph
+	// Predicate candidates are predicates sampled from the queried graphs.
+	Predicate
+)
+
+// String returns a readable name for the candidate kind.
+func (k Kind) String() string {
+	switch k {
+	case Keyword:
+		return "KEYWORD"
+	case Symbol:
+		return "SYMBOL"
+	case Graph:
+		return "GRAPH"
+	case Predicate:
+		return "PREDICATE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Candidate contains a possible continuation of a partial statement.
+type Candidate struct {
+	Text string
+	Kind Kind
+}
+
+// Completion contains the candidates that can replace the text between Start
+// and End, the byte offsets of the partial token under the cursor. Start and
+// End are both the cursor position if the cursor is not on a token.
+type Completion struct {
+	Start      int
+	End        int
+	Candidates []*Candidate
+}
+
+// symbols contains the text of the punctuation tokens.
+var symbols = map[lexer.TokenType]string{
+	lexer.ItemLBracket:  "{",
+	lexer.ItemRBracket:  "}",
+	lexer.ItemLPar:      "(",
+	lexer.ItemRPar:      ")",
+	lexer.ItemSemicolon: ";",
+	lexer.ItemComma:     ",",
+	lexer.ItemDot:       ".",
+	lexer.ItemLT:        "<",
+	lexer.ItemGT:        ">",
+	lexer.ItemEQ:        "=",
+	lexer.ItemPlus:      "+",
+	lexer.ItemMinus:     "-",
+	lexer.ItemStar:      "*",
+	lexer.ItemLTriple:   "<<",
+	lexer.ItemRTriple:   ">>",
+}
+
+// Complete returns the candidates that continue the BQL input at the provided
+// cursor, a byte offset into the input. Only the text before the cursor is
+// considered. Graph names and predicates are only suggested if a store is
+// provided. Graph names require the store to implement storage.GraphLister.
+// No candidates are returned if the text before the cursor is not a valid
+// prefix of a BQL script.
+func Complete(ctx context.Context, store storage.Store, input string, cursor int) (*Completion, error) {
+	if cursor < 0 || cursor > len(input) {
+		return nil, fmt.Errorf("complete.Complete: cursor %d out of the input range [0, %d]", cursor, len(input))
+	}
+	c := &Completion{Start: cursor, End: cursor}
+	spans, _ := lexer.Scan(input[:cursor], lexer.Options{})
+	for i := len(spans) - 1; i >= 0; i-- {
+		if last := spans[i]; last.Type != lexer.ItemEOF {
+			// The lexer stops on partial tokens, such as unterminated predicates,
+			// returning an error token where they start.
+			partial := last.Type == lexer.ItemError && !strings.ContainsAny(input[last.Start:cursor], " \t\r\n")
+			if partial || last.End == cursor && symbols[last.Type] == "" {
+				c.Start = last.Start
+			}
+			break
+		}
+	}
+	partial := input[c.Start:cursor]
+	var tkns []lexer.Token
+	for _, s := range spans {
+		if s.Start >= c.Start || s.End > c.Start || s.Type == lexer.ItemEOF {
+			break
+		}
+		tkns = append(tkns, s.Token)
+	}
+
+	exp, err := expected(input[:c.Start])
+	if err != nil || len(exp) == 0 {
+		return c, nil
+	}
+	add := func(txt string, k Kind) {
+		if strings.HasPrefix(strings.ToLower(txt), strings.ToLower(partial)) {
+			c.Candidates = append(c.Candidates, &Candidate{Text: txt, Kind: k})
+		}
+	}
+	for _, tt := range exp {
+		if s, ok := symbols[tt]; ok {
+			add(s, Symbol)
+			continue
+		}
+		if kw, ok := lexer.Keyword(tt); ok {
+			add(kw, Keyword)
+		}
+	}
+	quoted := accepts(exp, lexer.ItemString)
+	if store != nil && (quoted || accepts(exp, lexer.ItemBinding)) && graphContext(tkns) {
+		ids, err := graphNames(store)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if ref := graphReference(id); quoted || ref == id {
+				add(ref, Graph)
+			}
+		}
+	}
+	if store != nil && (accepts(exp, lexer.ItemPredicate) || accepts(exp, lexer.ItemPredicateBound)) {
+		ps, err := predicates(ctx, store, queriedGraphs(tkns))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			add(p, Predicate)
+		}
+	}
+	sort.SliceStable(c.Candidates, func(i, j int) bool {
+		ci, cj := c.Candidates[i], c.Candidates[j]
+		if ci.Kind != cj.Kind {
+			return ci.Kind < cj.Kind
+		}
+		return ci.Text < cj.Text
+	})
+	return c, nil
+}
+
+// expected returns the token types that can follow the provided text. It
+// fails if the text is not a valid prefix of a BQL script.
+func expected(text string) ([]lexer.TokenType, error) {
+	p, err := grammar.NewParser(grammar.BQL())
+	if err != nil {
+		return nil, err
+	}
+	llk := grammar.NewLLk(text, p.LookAhead())
+	for {
+		if _, err := p.ParseTree(llk); err != nil {
+			perr, ok := err.(*grammar.ParseError)
+			if !ok || perr.Token.Type != lexer.ItemEOF {
+				return nil, err
+			}
+			return perr.Expected, nil
+		}
+	}
+}
+
+// accepts returns true if the token type is among the expected ones.
+func accepts(exp []lexer.TokenType, tt lexer.TokenType) bool {
+	for _, e := range exp {
+		if e == tt {
+			return true
+		}
+	}
+	return false
+}
+
+// graphContext returns true if the last tokens list graphs, as in from ?a, or
+// create graph "/b".
+func graphContext(tkns []lexer.Token) bool {
+	for i := len(tkns) - 1; i >= 0; i-- {
+		switch tkns[i].Type {
+		case lexer.ItemBinding, lexer.ItemString, lexer.ItemComma:
+			continue
+		case lexer.ItemFrom, lexer.ItemInto, lexer.ItemGraph:
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// queriedGraphs returns the graphs listed on the from clause of the last
+// statement of the provided tokens.
+func queriedGraphs(tkns []lexer.Token) []string {
+	var gs []string
+	for i := len(tkns) - 1; i >= 0 && tkns[i].Type != lexer.ItemSemicolon; i-- {
+		if tkns[i].Type != lexer.ItemFrom {
+			continue
+		}
+		for _, tkn := range tkns[i+1:] {
+			switch tkn.Type {
+			case lexer.ItemBinding:
+				gs = append(gs, tkn.Text)
+				continue
+			case lexer.ItemString:
+				if g, err := strconv.Unquote(tkn.Text); err == nil {
+					gs = append(gs, g)
+				}
+				continue
+			case lexer.ItemComma:
+				continue
+			}
+			break
+		}
+		break
+	}
+	return gs
+}
+
+// graphReference returns how a graph is referred to in BQL. Graphs named like
+// bindings are referred to by their name, others by their quoted name.
+func graphReference(id string) string {
+	if strings.HasPrefix(id, "?") {
+		return id
+	}
+	return strconv.Quote(id)
+}
+
+// graphNames returns the names of the graphs in the store, if it can list them.
+func graphNames(store storage.Store) ([]string, error) {
+	gl, ok := store.(storage.GraphLister)
+	if !ok {
+		return nil, nil
+	}
+	return gl.GraphNames()
+}
+
+// predicates returns the predicates found sampling the triples of the provided
+// graphs, or all the graphs in the store if none is provided. Temporal
+// predicates are returned without time anchor. Graphs that do not exist are
+// ignored.
+func predicates(ctx context.Context, store storage.Store, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		var err error
+		if ids, err = graphNames(store); err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool)
+	var res []string
+	for _, id := range ids {
+		g, err := store.Graph(id)
+		if err != nil {
+			continue
+		}
+		ts, err := g.Triples()
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		for t := range ts {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if n++; n > maxSampledTriples {
+				break
+			}
+			p := fmt.Sprintf("%q@[]", string(t.P().ID()))
+			if t.P().Type() == predicate.Temporal {
+				p = fmt.Sprintf("%q@[,]", string(t.P().ID()))
+			}
+			if !seen[p] {
+				seen[p] = true
+				res = append(res, p)
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package complete
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func testStore(t *testing.T) storage.Store {
+	s := memory.NewStore()
+	g, err := s.NewGraph("?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"?other", "/prod/users"} {
+		if _, err := s.NewGraph(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ts []*triple.Triple
+	for _, txt := range []string{
+		`/u<joe> "knows"@[] /u<mary>`,
+		`/u<joe> "born"@[2016-01-01T00:00:00Z] /u<mary>`,
+	} {
+		tr, err := triple.ParseTriple(txt, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts = append(ts, tr)
+	}
+	if err := g.AddTriples(ts); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestComplete(t *testing.T) {
+	s := testStore(t)
+	testTable := []struct {
+		in    string
+		start int
+		want  []Candidate
+	}{
+		{
+			in:    `sel`,
+			start: 0,
+			want:  []Candidate{{"select", Keyword}},
+		},
+		{
+			in:    `select ?s from ?t`,
+			start: 15,
+			want:  []Candidate{{"?test", Graph}},
+		},
+		{
+			in:    `select ?s from `,
+			start: 15,
+			want:  []Candidate{{`"/prod/users"`, Graph}, {"?other", Graph}, {"?test", Graph}},
+		},
+		{
+			in:    `select ?s from ?test, "/pr`,
+			start: 22,
+			want:  []Candidate{{`"/prod/users"`, Graph}},
+		},
+		{
+			in:    `select ?s from ?test where {?s `,
+			start: 31,
+			want: []Candidate{
+				{"as", Keyword}, {"id", Keyword}, {"type", Keyword}, {"under", Keyword},
+				{`"born"@[,]`, Predicate}, {`"knows"@[]`, Predicate},
+			},
+		},
+		{
+			in:    `select ?s from ?test where {?s "k`,
+			start: 31,
+			want:  []Candidate{{`"knows"@[]`, Predicate}},
+		},
+		{
+			in:    `select ?s from ?test where {?s ?p ?o} `,
+			start: 38,
+		},
+		{
+			in:    `select ?s from ?test where {?s ?p ?o} gr`,
+			start: 38,
+			want:  []Candidate{{"granularity", Keyword}, {"group", Keyword}},
+		},
+		{
+			in:    `select ?s from ?test wher {`,
+			start: 27,
+		},
+	}
+	for _, entry := range testTable {
+		c, err := Complete(context.Background(), s, entry.in, len(entry.in))
+		if err != nil {
+			t.Fatalf("complete.Complete(%q) failed with error %v", entry.in, err)
+		}
+		if c.Start != entry.start || c.End != len(entry.in) {
+			t.Errorf("complete.Complete(%q) returned range [%d, %d); want [%d, %d)", entry.in, c.Start, c.End, entry.start, len(entry.in))
+		}
+		if entry.want == nil {
+			continue
+		}
+		var got []Candidate
+		for _, cnd := range c.Candidates {
+			got = append(got, *cnd)
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("complete.Complete(%q) returned %v; want %v", entry.in, got, entry.want)
+		}
+	}
+}
+
+func TestCompleteStatementStart(t *testing.T) {
+	c, err := Complete(context.Background(), nil, `create graph ?a; `, 17)
+	if err != nil {
+		t.Fatalf("complete.Complete failed with error %v", err)
+	}
+	found := false
+	for _, cnd := range c.Candidates {
+		if cnd.Kind != Keyword {
+			t.Errorf("complete.Complete returned %v at the start of a statement; want only keywords", cnd)
+		}
+		found = found || cnd.Text == "select"
+	}
+	if !found {
+		t.Errorf("complete.Complete returned %v at the start of a statement; want select among them", c.Candidates)
+	}
+	if _, err := Complete(context.Background(), nil, `select`, 7); err == nil {
+		t.Errorf("complete.Complete should have failed given a cursor beyond the input")
+	}
+}
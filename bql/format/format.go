@@ -0,0 +1,217 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package format renders BQL statements using a canonical layout. Keywords are
+// upper cased, each query clause starts on its own line, and graph patterns
+// and data blocks are indented with one triple per line.
+package format
+
+import (
+	"strings"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// indent contains the indentation used for each nesting level.
+const indent = "  "
+
+// Source formats all the statements contained in the provided BQL text. The
+// formatted statements are separated by an empty line.
+func Source(bql string) (string, error) {
+	p, err := grammar.NewParser(grammar.BQL())
+	if err != nil {
+		return "", err
+	}
+	var stms []string
+	llk := grammar.NewLLk(bql, p.LookAhead())
+	for !llk.CanAccept(lexer.ItemEOF) {
+		t, err := p.ParseTree(llk)
+		if err != nil {
+			return "", err
+		}
+		stms = append(stms, Tree(t))
+	}
+	return strings.Join(stms, "\n"), nil
+}
+
+// Statement formats the tokens consumed while parsing the provided statement.
+func Statement(st *semantic.Statement) string {
+	return Tokens(st.Tokens())
+}
+
+// Tree formats the tokens contained on the provided syntax tree.
+func Tree(t *grammar.ParseTree) string {
+	return Tokens(t.Tokens())
+}
+
+// Tokens formats the provided tokens. Tokens are expected to belong to valid
+// BQL statements. Each formatted statement ends with a new line.
+func Tokens(tkns []*lexer.Token) string {
+	p := &printer{bol: true, stmt: lexer.ItemEOF}
+	for i, tkn := range tkns {
+		next := lexer.ItemEOF
+		if i+1 < len(tkns) {
+			next = tkns[i+1].Type
+		}
+		p.print(tkn, next)
+	}
+	return p.b.String()
+}
+
+// printer keeps the state required to lay out a sequence of tokens.
+type printer struct {
+	b     strings.Builder
+	depth int
+	// bol is true when the next token starts a new line.
+	bol bool
+	// prev contains the type of the last printed token.
+	prev lexer.TokenType
+	// stmt contains the type of the first token of the current statement.
+	stmt lexer.TokenType
+	// inPrefix is true while a prefix declaration is being printed.
+	inPrefix bool
+}
+
+// newline ends the current line, if any.
+func (p *printer) newline() {
+	if !p.bol {
+		p.b.WriteString("\n")
+		p.bol = true
+	}
+}
+
+// write adds the text of the token to the current line.
+func (p *printer) write(tt lexer.TokenType, txt string) {
+	if p.bol {
+		p.b.WriteString(strings.Repeat(indent, p.depth))
+	} else if !noSpaceBefore(tt) && !noSpaceAfter(p.prev) {
+		p.b.WriteString(" ")
+	}
+	p.b.WriteString(txt)
+	p.bol = false
+	p.prev = tt
+}
+
+// print lays out the provided token, given the type of the token that follows
+// it.
+func (p *printer) print(tkn *lexer.Token, next lexer.TokenType) {
+	txt := tkn.Text
+	if isKeyword(tkn.Type) {
+		txt = strings.ToUpper(txt)
+	}
+	switch tkn.Type {
+	case lexer.ItemEOF:
+		return
+	case lexer.ItemPrefix:
+		p.newline()
+		p.write(tkn.Type, txt)
+		p.inPrefix = true
+		return
+	case lexer.ItemLBracket:
+		p.write(tkn.Type, txt)
+		p.depth++
+		p.newline()
+		return
+	case lexer.ItemRBracket:
+		if p.depth > 0 {
+			p.depth--
+		}
+		p.newline()
+		p.write(tkn.Type, txt)
+		return
+	case lexer.ItemDot:
+		p.write(tkn.Type, txt)
+		p.newline()
+		return
+	case lexer.ItemSemicolon:
+		p.write(tkn.Type, txt)
+		p.newline()
+		p.stmt = lexer.ItemEOF
+		return
+	}
+	if p.inPrefix {
+		p.write(tkn.Type, txt)
+		if p.prev != lexer.ItemPrefixName {
+			p.inPrefix = false
+			p.newline()
+		}
+		return
+	}
+	if p.stmt == lexer.ItemEOF {
+		p.stmt = tkn.Type
+	}
+	if p.depth == 0 && startsClause(p.stmt, tkn.Type, next) {
+		p.newline()
+	}
+	p.write(tkn.Type, txt)
+}
+
+// startsClause returns true if the token starts a new clause that should be
+// printed on its own line for the provided kind of statement. The as keyword
+// only starts a clause when followed by of.
+func startsClause(stmt, tt, next lexer.TokenType) bool {
+	if stmt != lexer.ItemQuery && stmt != lexer.ItemCreate {
+		return false
+	}
+	switch tt {
+	case lexer.ItemAs:
+		return next == lexer.ItemOf
+	case lexer.ItemQuery, lexer.ItemFrom, lexer.ItemWhere, lexer.ItemSubtract,
+		lexer.ItemGroup, lexer.ItemOrder, lexer.ItemHaving, lexer.ItemLimit,
+		lexer.ItemBefore, lexer.ItemAfter, lexer.ItemBetween, lexer.ItemAt,
+		lexer.ItemGranularity, lexer.ItemWith:
+		return true
+	}
+	return false
+}
+
+// isKeyword returns true if the token type is a BQL keyword.
+func isKeyword(tt lexer.TokenType) bool {
+	switch tt {
+	case lexer.ItemQuery, lexer.ItemInsert, lexer.ItemDelete, lexer.ItemCreate,
+		lexer.ItemDrop, lexer.ItemAnalyze, lexer.ItemGraph, lexer.ItemView,
+		lexer.ItemData, lexer.ItemInto, lexer.ItemFrom, lexer.ItemWhere,
+		lexer.ItemAs, lexer.ItemOf, lexer.ItemLatest, lexer.ItemGranularity,
+		lexer.ItemType, lexer.ItemID, lexer.ItemAt, lexer.ItemUnder,
+		lexer.ItemPrefix, lexer.ItemBefore, lexer.ItemAfter, lexer.ItemBetween,
+		lexer.ItemCount, lexer.ItemDistinct, lexer.ItemSum, lexer.ItemGroup,
+		lexer.ItemBy, lexer.ItemOrder, lexer.ItemHaving, lexer.ItemAsc,
+		lexer.ItemDesc, lexer.ItemLimit, lexer.ItemNow, lexer.ItemPer,
+		lexer.ItemCast, lexer.ItemStrLen, lexer.ItemSubtract, lexer.ItemWith,
+		lexer.ItemHint, lexer.ItemNot, lexer.ItemAnd, lexer.ItemOr:
+		return true
+	}
+	return false
+}
+
+// noSpaceBefore returns true if the token is written next to the previous one.
+func noSpaceBefore(tt lexer.TokenType) bool {
+	switch tt {
+	case lexer.ItemComma, lexer.ItemSemicolon, lexer.ItemRPar, lexer.ItemRTriple:
+		return true
+	}
+	return false
+}
+
+// noSpaceAfter returns true if the next token is written next to the token.
+func noSpaceAfter(tt lexer.TokenType) bool {
+	switch tt {
+	case lexer.ItemLPar, lexer.ItemLTriple, lexer.ItemCount, lexer.ItemSum,
+		lexer.ItemCast, lexer.ItemStrLen, lexer.ItemHint:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"testing"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+func TestSource(t *testing.T) {
+	table := []struct {
+		in, want string
+	}{
+		{
+			in:   `create graph ?a,?b;`,
+			want: "CREATE GRAPH ?a, ?b;\n",
+		},
+		{
+			in: `select ?s, count(?o) as ?n from ?g where {?s "p"@[,] as ?x at ?t ?o. ?o ?q <</u<a> "r"@[] ?s>>} minus {?s "q"@[] ?o} group by ?s order by ?n desc having (?n > "1"^^type:int64) limit "10"^^type:int64 with hint(ordered);`,
+			want: `SELECT ?s, COUNT(?o) AS ?n
+FROM ?g
+WHERE {
+  ?s "p"@[,] AS ?x AT ?t ?o .
+  ?o ?q <</u<a> "r"@[] ?s>>
+}
+MINUS {
+  ?s "q"@[] ?o
+}
+GROUP BY ?s
+ORDER BY ?n DESC
+HAVING (?n > "1"^^type:int64)
+LIMIT "10"^^type:int64
+WITH HINT(ordered);
+`,
+		},
+		{
+			in: `prefix foaf: /foaf insert data into ?a {foaf:<a> "p"@[] foaf:<b> . foaf:<b> "p"@[] foaf:<c>};
+			     drop graph ?a;`,
+			want: `PREFIX foaf: /foaf
+INSERT DATA INTO ?a {
+  foaf:<a> "p"@[] foaf:<b> .
+  foaf:<b> "p"@[] foaf:<c>
+};
+
+DROP GRAPH ?a;
+`,
+		},
+		{
+			in: `select ?c as ?city from ?g where {/u<joe> "lives_in"@[,] ?c} as of "2015" granularity "day" limit "1"^^type:int64;`,
+			want: `SELECT ?c AS ?city
+FROM ?g
+WHERE {
+  /u<joe> "lives_in"@[,] ?c
+}
+AS OF "2015"
+GRANULARITY "day"
+LIMIT "1"^^type:int64;
+`,
+		},
+		{
+			in: `create view ?v as select ?a from ?g where {?a ?p ?o};`,
+			want: `CREATE VIEW ?v AS
+SELECT ?a
+FROM ?g
+WHERE {
+  ?a ?p ?o
+};
+`,
+		},
+	}
+	for _, entry := range table {
+		got, err := Source(entry.in)
+		if err != nil {
+			t.Errorf("format.Source(%q) failed with error %v", entry.in, err)
+			continue
+		}
+		if got != entry.want {
+			t.Errorf("format.Source(%q) returned\n%s\nwant\n%s", entry.in, got, entry.want)
+		}
+		// Formatting should be idempotent.
+		again, err := Source(got)
+		if err != nil {
+			t.Errorf("format.Source failed to parse formatted %q with error %v", got, err)
+			continue
+		}
+		if again != got {
+			t.Errorf("format.Source is not idempotent; got\n%s\nwant\n%s", again, got)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	for _, in := range []string{
+		`select ?a from ?g;`,
+		`create graph ?a; drop ?a;`,
+	} {
+		if _, err := Source(in); err == nil {
+			t.Errorf("format.Source(%q) should have failed", in)
+		}
+	}
+}
+
+func TestStatement(t *testing.T) {
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
+	}
+	st := &semantic.Statement{}
+	in := `select ?s from ?g where {?s ?p ?o} limit "1"^^type:int64;`
+	if err := p.Parse(grammar.NewLLk(in, 1), st); err != nil {
+		t.Fatalf("Parser.Parse(%q) failed with error %v", in, err)
+	}
+	want := `SELECT ?s
+FROM ?g
+WHERE {
+  ?s ?p ?o
+}
+LIMIT "1"^^type:int64;
+`
+	if got := Statement(st); got != want {
+		t.Errorf("format.Statement returned\n%s\nwant\n%s", got, want)
+	}
+}
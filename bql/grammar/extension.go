@@ -0,0 +1,94 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grammar
+
+import (
+	"fmt"
+
+	"github.com/google/badwolf/bql/lexer"
+)
+
+// Extension contains the productions and semantic hooks of new top level
+// statements to add to a grammar. New keywords used by the statements can be
+// registered using lexer.RegisterKeyword.
+type Extension struct {
+	// Name identifies the extension on errors.
+	Name string
+
+	// Start contains the clauses added to the START symbol. Each clause must
+	// start with a token that does not start any other statement.
+	Start []*Clause
+
+	// Symbols contains the new symbols used by the Start clauses. They cannot
+	// redefine any of the symbols already in the grammar.
+	Symbols Grammar
+}
+
+// extend returns a copy of the grammar with the provided extensions added.
+func extend(g *Grammar, exts []*Extension) (*Grammar, error) {
+	res := &Grammar{}
+	cloneGrammar(res, g)
+	first := make(map[lexer.TokenType]string)
+	for _, cls := range (*res)["START"] {
+		if len(cls.Elements) > 0 && !cls.Elements[0].isSymbol {
+			first[cls.Elements[0].Token()] = "the grammar"
+		}
+	}
+	for _, ext := range exts {
+		for sym, clss := range ext.Symbols {
+			if _, ok := (*res)[sym]; ok {
+				return nil, fmt.Errorf("grammar.NewParser: extension %q redefines symbol %q", ext.Name, sym)
+			}
+			(*res)[sym] = clss
+		}
+		for _, cls := range ext.Start {
+			if len(cls.Elements) == 0 || cls.Elements[0].isSymbol {
+				return nil, fmt.Errorf("grammar.NewParser: extension %q statements must start with a token", ext.Name)
+			}
+			tt := cls.Elements[0].Token()
+			if owner, ok := first[tt]; ok {
+				return nil, fmt.Errorf("grammar.NewParser: extension %q statement starting with %v conflicts with a statement of %s", ext.Name, tt, owner)
+			}
+			first[tt] = fmt.Sprintf("extension %q", ext.Name)
+			// Statements are tried in order, hence they must precede the empty
+			// derivation, if any.
+			(*res)["START"] = insertClause((*res)["START"], cls)
+		}
+	}
+	for sym, clss := range *res {
+		for _, cls := range clss {
+			for _, e := range cls.Elements {
+				if _, ok := (*res)[e.Symbol()]; e.isSymbol && !ok {
+					return nil, fmt.Errorf("grammar.NewParser: symbol %q used by %q is not defined", e.Symbol(), sym)
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// insertClause adds a clause before the empty derivation of the clauses, if
+// any.
+func insertClause(clss []*Clause, cls *Clause) []*Clause {
+	res := make([]*Clause, 0, len(clss)+1)
+	for i, c := range clss {
+		if len(c.Elements) == 0 {
+			res = append(res, cls)
+			return append(res, clss[i:]...)
+		}
+		res = append(res, c)
+	}
+	return append(res, cls)
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grammar
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// itemExport is the token type of the keyword used by the test extension.
+var itemExport = func() lexer.TokenType {
+	tt, err := lexer.RegisterKeyword("export")
+	if err != nil {
+		panic(err)
+	}
+	return tt
+}()
+
+// exportExtension returns an extension for statements such as
+// export ?a, ?b into "sink"^^type:text;
+func exportExtension() *Extension {
+	return &Extension{
+		Name: "export",
+		Start: []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(itemExport),
+					NewSymbol("EXPORT_GRAPHS"),
+					NewTokenType(lexer.ItemInto),
+					NewTokenType(lexer.ItemLiteral),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+				ProcessedElement: func(st *semantic.Statement, ce semantic.ConsumedElement) (semantic.ElementHook, error) {
+					if !ce.IsSymbol() && ce.Token().Type == lexer.ItemLiteral {
+						st.SetExtensionData(ce.Token().Text)
+					}
+					return nil, nil
+				},
+				ProcessEnd: semantic.TypeBindingClauseHook(semantic.Extension),
+			},
+		},
+		Symbols: Grammar{
+			"EXPORT_GRAPHS": []*Clause{
+				{
+					Elements: []Element{
+						NewTokenType(lexer.ItemBinding),
+						NewSymbol("MORE_GRAPHS"),
+					},
+					ProcessedElement: semantic.GraphAccumulatorHook(),
+				},
+			},
+		},
+	}
+}
+
+func TestExtension(t *testing.T) {
+	p, err := NewParser(SemanticBQL(), exportExtension())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: failed to extend the grammar with error %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(NewLLk(`export ?a, ?b into "sink"^^type:text;`, 1), st); err != nil {
+		t.Fatalf("Parser.Parse: failed to parse extension statement with error %v", err)
+	}
+	if got, want := st.Type(), semantic.Extension; got != want {
+		t.Errorf("Parser.Parse: got statement type %v, want %v", got, want)
+	}
+	if got, want := st.Graphs(), []string{"?a", "?b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parser.Parse: got graphs %v, want %v", got, want)
+	}
+	if got, want := st.ExtensionData(), `"sink"^^type:text`; got != want {
+		t.Errorf("Parser.Parse: got extension data %v, want %v", got, want)
+	}
+	// Existing statements are still supported.
+	if err := p.Parse(NewLLk(`select ?s from ?g where {?s ?p ?o};`, 1), &semantic.Statement{}); err != nil {
+		t.Errorf("Parser.Parse: failed to parse query with extended grammar; %v", err)
+	}
+	// The extended grammar is not modified.
+	base, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
+	}
+	if err := base.Parse(NewLLk(`export ?a into "sink"^^type:text;`, 1), &semantic.Statement{}); err == nil {
+		t.Errorf("Parser.Parse: BQL grammar should not accept extension statements")
+	}
+}
+
+func TestExtensionConflicts(t *testing.T) {
+	table := []*Extension{
+		{
+			Name: "redefines a statement",
+			Start: []*Clause{
+				{Elements: []Element{NewTokenType(lexer.ItemQuery), NewTokenType(lexer.ItemSemicolon)}},
+			},
+		},
+		{
+			Name: "redefines a symbol",
+			Start: []*Clause{
+				{Elements: []Element{NewTokenType(itemExport), NewSymbol("GRAPHS")}},
+			},
+			Symbols: Grammar{
+				"GRAPHS": []*Clause{{Elements: []Element{NewTokenType(lexer.ItemBinding)}}},
+			},
+		},
+		{
+			Name: "uses undefined symbols",
+			Start: []*Clause{
+				{Elements: []Element{NewTokenType(itemExport), NewSymbol("UNDEFINED")}},
+			},
+		},
+		{
+			Name: "starts with a symbol",
+			Start: []*Clause{
+				{Elements: []Element{NewSymbol("GRAPHS")}},
+			},
+		},
+		{
+			Name: "is empty",
+			Start: []*Clause{
+				{},
+			},
+		},
+	}
+	for _, ext := range table {
+		if _, err := NewParser(SemanticBQL(), ext); err == nil {
+			t.Errorf("grammar.NewParser should have rejected extension that %s", ext.Name)
+		}
+	}
+	if _, err := NewParser(SemanticBQL(), exportExtension(), exportExtension()); err == nil {
+		t.Errorf("grammar.NewParser should have rejected conflicting extensions")
+	}
+}
@@ -53,15 +53,20 @@ func initBQL() {
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemQuery),
+					NewSymbol("SELECT_DISTINCT"),
 					NewSymbol("VARS"),
 					NewTokenType(lexer.ItemFrom),
 					NewSymbol("GRAPHS"),
 					NewSymbol("WHERE"),
+					NewSymbol("WHERE_MINUS"),
 					NewSymbol("GROUP_BY"),
 					NewSymbol("ORDER_BY"),
 					NewSymbol("HAVING"),
 					NewSymbol("GLOBAL_TIME_BOUND"),
+					NewSymbol("AS_OF"),
+					NewSymbol("GRANULARITY"),
 					NewSymbol("LIMIT"),
+					NewSymbol("HINTS"),
 					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
@@ -71,9 +76,10 @@ func initBQL() {
 					NewTokenType(lexer.ItemData),
 					NewTokenType(lexer.ItemInto),
 					NewSymbol("GRAPHS"),
+					NewSymbol("INSERT_ANCHOR"),
 					NewTokenType(lexer.ItemLBracket),
 					NewTokenType(lexer.ItemNode),
-					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("DATA_PREDICATE"),
 					NewSymbol("INSERT_OBJECT"),
 					NewSymbol("INSERT_DATA"),
 					NewTokenType(lexer.ItemRBracket),
@@ -88,7 +94,7 @@ func initBQL() {
 					NewSymbol("GRAPHS"),
 					NewTokenType(lexer.ItemLBracket),
 					NewTokenType(lexer.ItemNode),
-					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("DATA_PREDICATE"),
 					NewSymbol("DELETE_OBJECT"),
 					NewSymbol("DELETE_DATA"),
 					NewTokenType(lexer.ItemRBracket),
@@ -109,6 +115,21 @@ func initBQL() {
 					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAnalyze),
+					NewSymbol("ANALYZE_GRAPHS"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPrefix),
+					NewTokenType(lexer.ItemPrefixName),
+					NewTokenType(lexer.ItemNodeType),
+					NewSymbol("START"),
+				},
+			},
 		},
 		"CREATE_GRAPHS": []*Clause{
 			{
@@ -117,6 +138,28 @@ func initBQL() {
 					NewSymbol("GRAPHS"),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemView),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemQuery),
+					NewSymbol("SELECT_DISTINCT"),
+					NewSymbol("VARS"),
+					NewTokenType(lexer.ItemFrom),
+					NewSymbol("GRAPHS"),
+					NewSymbol("WHERE"),
+					NewSymbol("WHERE_MINUS"),
+					NewSymbol("GROUP_BY"),
+					NewSymbol("ORDER_BY"),
+					NewSymbol("HAVING"),
+					NewSymbol("GLOBAL_TIME_BOUND"),
+					NewSymbol("AS_OF"),
+					NewSymbol("GRANULARITY"),
+					NewSymbol("LIMIT"),
+					NewSymbol("HINTS"),
+				},
+			},
 		},
 		"DROP_GRAPHS": []*Clause{
 			{
@@ -126,6 +169,14 @@ func initBQL() {
 				},
 			},
 		},
+		"ANALYZE_GRAPHS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemGraph),
+					NewSymbol("GRAPHS"),
+				},
+			},
+		},
 		"VARS": []*Clause{
 			{
 				Elements: []Element{
@@ -158,6 +209,14 @@ func initBQL() {
 				},
 			},
 		},
+		"SELECT_DISTINCT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemDistinct),
+				},
+			},
+			{},
+		},
 		"COUNT_DISTINCT": []*Clause{
 			{
 				Elements: []Element{
@@ -191,13 +250,18 @@ func initBQL() {
 					NewSymbol("MORE_GRAPHS"),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemString),
+					NewSymbol("MORE_GRAPHS"),
+				},
+			},
 		},
 		"MORE_GRAPHS": []*Clause{
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemComma),
-					NewTokenType(lexer.ItemBinding),
-					NewSymbol("MORE_GRAPHS"),
+					NewSymbol("GRAPHS"),
 				},
 			},
 			{},
@@ -212,6 +276,18 @@ func initBQL() {
 				},
 			},
 		},
+		"WHERE_MINUS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemSubtract),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+					NewSymbol("WHERE_MINUS"),
+				},
+			},
+			{},
+		},
 		"CLAUSES": []*Clause{
 			{
 				Elements: []Element{
@@ -225,12 +301,32 @@ func initBQL() {
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemBinding),
+					NewSymbol("SUBJECT_UNDER"),
 					NewSymbol("SUBJECT_EXTRACT"),
 					NewSymbol("PREDICATE"),
 					NewSymbol("OBJECT"),
 					NewSymbol("MORE_CLAUSES"),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemGraph),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+					NewSymbol("MORE_CLAUSES"),
+				},
+			},
+		},
+		"SUBJECT_UNDER": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemUnder),
+					NewTokenType(lexer.ItemNodeType),
+				},
+			},
+			{},
 		},
 		"SUBJECT_EXTRACT": []*Clause{
 			{
@@ -278,9 +374,11 @@ func initBQL() {
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("PREDICATE_PATH"),
 					NewSymbol("PREDICATE_AS"),
 					NewSymbol("PREDICATE_ID"),
 					NewSymbol("PREDICATE_AT"),
+					NewSymbol("PREDICATE_LATEST"),
 				},
 			},
 			{
@@ -289,6 +387,7 @@ func initBQL() {
 					NewSymbol("PREDICATE_AS"),
 					NewSymbol("PREDICATE_ID"),
 					NewSymbol("PREDICATE_BOUND_AT"),
+					NewSymbol("PREDICATE_LATEST"),
 				},
 			},
 			{
@@ -297,8 +396,30 @@ func initBQL() {
 					NewSymbol("PREDICATE_AS"),
 					NewSymbol("PREDICATE_ID"),
 					NewSymbol("PREDICATE_AT"),
+					NewSymbol("PREDICATE_LATEST"),
+				},
+			},
+		},
+		"PREDICATE_LATEST": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLatest),
+				},
+			},
+			{},
+		},
+		"PREDICATE_PATH": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPlus),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemStar),
+				},
+			},
+			{},
 		},
 		"PREDICATE_AS": []*Clause{
 			{
@@ -392,6 +513,61 @@ func initBQL() {
 					NewSymbol("OBJECT_LITERAL_BINDING_AT"),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLTriple),
+					NewSymbol("OBJECT_TRIPLE_SUBJECT"),
+					NewSymbol("OBJECT_TRIPLE_PREDICATE"),
+					NewSymbol("OBJECT_TRIPLE_OBJECT"),
+					NewTokenType(lexer.ItemRTriple),
+				},
+			},
+		},
+		"OBJECT_TRIPLE_SUBJECT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNode),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+		},
+		"OBJECT_TRIPLE_PREDICATE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+		},
+		"OBJECT_TRIPLE_OBJECT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNode),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLiteral),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
 		},
 		"OBJECT_SUBJECT_EXTRACT": []*Clause{
 			{
@@ -569,13 +745,38 @@ func initBQL() {
 				Elements: []Element{
 					NewTokenType(lexer.ItemOrder),
 					NewTokenType(lexer.ItemBy),
-					NewTokenType(lexer.ItemBinding),
+					NewSymbol("ORDER_BY_KEY"),
 					NewSymbol("ORDER_BY_DIRECTION"),
 					NewSymbol("ORDER_BY_BINDINGS"),
 				},
 			},
 			{},
 		},
+		"ORDER_BY_KEY": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemStrLen),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemCast),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemLiteralType),
+					NewTokenType(lexer.ItemRPar),
+				},
+			},
+		},
 		"ORDER_BY_DIRECTION": []*Clause{
 			{
 				Elements: []Element{
@@ -593,7 +794,7 @@ func initBQL() {
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemComma),
-					NewTokenType(lexer.ItemBinding),
+					NewSymbol("ORDER_BY_KEY"),
 					NewSymbol("ORDER_BY_DIRECTION"),
 					NewSymbol("ORDER_BY_BINDINGS"),
 				},
@@ -630,6 +831,32 @@ func initBQL() {
 					NewSymbol("HAVING_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLiteral),
+					NewSymbol("HAVING_CLAUSE_BINARY_COMPOSITE"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemCast),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemLiteralType),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("HAVING_CLAUSE_BINARY_COMPOSITE"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemStrLen),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("HAVING_CLAUSE_BINARY_COMPOSITE"),
+				},
+			},
 		},
 		"HAVING_CLAUSE_BINARY_COMPOSITE": []*Clause{
 			{
@@ -662,29 +889,54 @@ func initBQL() {
 					NewSymbol("HAVING_CLAUSE"),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPlus),
+					NewSymbol("HAVING_CLAUSE"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemMinus),
+					NewSymbol("HAVING_CLAUSE"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemStar),
+					NewSymbol("HAVING_CLAUSE"),
+				},
+			},
 			{},
 		},
 		"GLOBAL_TIME_BOUND": []*Clause{
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemBefore),
-					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("GLOBAL_TIME_BOUND_ANCHOR"),
 					NewSymbol("GLOBAL_TIME_BOUND_COMPOSITE"),
 				},
 			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemAfter),
-					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("GLOBAL_TIME_BOUND_ANCHOR"),
+					NewSymbol("GLOBAL_TIME_BOUND_COMPOSITE"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAt),
+					NewSymbol("GLOBAL_TIME_BOUND_ANCHOR"),
 					NewSymbol("GLOBAL_TIME_BOUND_COMPOSITE"),
 				},
 			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemBetween),
-					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("GLOBAL_TIME_BOUND_ANCHOR"),
 					NewTokenType(lexer.ItemComma),
-					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("GLOBAL_TIME_BOUND_ANCHOR"),
 					NewSymbol("GLOBAL_TIME_BOUND_COMPOSITE"),
 				},
 			},
@@ -712,15 +964,154 @@ func initBQL() {
 			},
 			{},
 		},
+		"GLOBAL_TIME_BOUND_ANCHOR": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNow),
+					NewSymbol("GLOBAL_TIME_BOUND_OFFSET"),
+				},
+			},
+		},
+		"GLOBAL_TIME_BOUND_OFFSET": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPlus),
+					NewTokenType(lexer.ItemLiteral),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemMinus),
+					NewTokenType(lexer.ItemLiteral),
+				},
+			},
+			{},
+		},
+		"AS_OF": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemOf),
+					NewTokenType(lexer.ItemString),
+				},
+			},
+			{},
+		},
+		"GRANULARITY": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemGranularity),
+					NewTokenType(lexer.ItemString),
+				},
+			},
+			{},
+		},
 		"LIMIT": []*Clause{
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemLimit),
 					NewTokenType(lexer.ItemLiteral),
+					NewSymbol("LIMIT_PER_GROUP"),
+				},
+			},
+			{},
+		},
+		"LIMIT_PER_GROUP": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPer),
+					NewTokenType(lexer.ItemGroup),
+				},
+			},
+			{},
+		},
+		"HINTS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemWith),
+					NewTokenType(lexer.ItemHint),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemHintName),
+					NewSymbol("HINT_VALUE"),
+					NewSymbol("HINT_LIST"),
+					NewTokenType(lexer.ItemRPar),
+				},
+			},
+			{},
+		},
+		"HINT_VALUE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLiteral),
+				},
+			},
+			{},
+		},
+		"HINT_LIST": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemHintName),
+					NewSymbol("HINT_VALUE"),
+					NewSymbol("HINT_LIST"),
+				},
+			},
+			{},
+		},
+		"INSERT_ANCHOR": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAt),
+					NewSymbol("INSERT_ANCHOR_TIME"),
+				},
+			},
+			{},
+		},
+		"INSERT_ANCHOR_TIME": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNow),
+					NewSymbol("INSERT_ANCHOR_OFFSET"),
+				},
+			},
+		},
+		"INSERT_ANCHOR_OFFSET": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPlus),
+					NewTokenType(lexer.ItemLiteral),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemMinus),
+					NewTokenType(lexer.ItemLiteral),
 				},
 			},
 			{},
 		},
+		"DATA_PREDICATE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicateBound),
+				},
+			},
+		},
 		"INSERT_OBJECT": []*Clause{
 			{
 				Elements: []Element{
@@ -732,18 +1123,32 @@ func initBQL() {
 					NewTokenType(lexer.ItemPredicate),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicateBound),
+				},
+			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemLiteral),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLTriple),
+					NewTokenType(lexer.ItemNode),
+					NewSymbol("DATA_PREDICATE"),
+					NewSymbol("INSERT_OBJECT"),
+					NewTokenType(lexer.ItemRTriple),
+				},
+			},
 		},
 		"INSERT_DATA": []*Clause{
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemDot),
 					NewTokenType(lexer.ItemNode),
-					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("DATA_PREDICATE"),
 					NewSymbol("INSERT_OBJECT"),
 					NewSymbol("INSERT_DATA"),
 				},
@@ -761,18 +1166,32 @@ func initBQL() {
 					NewTokenType(lexer.ItemPredicate),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicateBound),
+				},
+			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemLiteral),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLTriple),
+					NewTokenType(lexer.ItemNode),
+					NewSymbol("DATA_PREDICATE"),
+					NewSymbol("DELETE_OBJECT"),
+					NewTokenType(lexer.ItemRTriple),
+				},
+			},
 		},
 		"DELETE_DATA": []*Clause{
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemDot),
 					NewTokenType(lexer.ItemNode),
-					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("DATA_PREDICATE"),
 					NewSymbol("DELETE_OBJECT"),
 					NewSymbol("DELETE_DATA"),
 				},
@@ -798,13 +1217,21 @@ func initSemanticBQL() {
 	semanticBQL = &Grammar{}
 	cloneGrammar(semanticBQL, bql)
 
-	// Create and Drop semantic hooks for type.
+	// Create, Drop, and Analyze semantic hooks for type.
 	for _, cls := range (*semanticBQL)["CREATE_GRAPHS"] {
+		if cls.Elements[0].Token() == lexer.ItemView {
+			cls.ProcessedElement = semantic.CreateViewHook()
+			cls.ProcessEnd = semantic.TypeBindingClauseHook(semantic.CreateView)
+			continue
+		}
 		cls.ProcessEnd = semantic.TypeBindingClauseHook(semantic.Create)
 	}
 	for _, cls := range (*semanticBQL)["DROP_GRAPHS"] {
 		cls.ProcessEnd = semantic.TypeBindingClauseHook(semantic.Drop)
 	}
+	for _, cls := range (*semanticBQL)["ANALYZE_GRAPHS"] {
+		cls.ProcessEnd = semantic.TypeBindingClauseHook(semantic.Analyze)
+	}
 	// Add graph binding collection to GRAPHS and MORE_GRAPHS clauses.
 	graphSymbols := []semantic.Symbol{"GRAPHS", "MORE_GRAPHS"}
 	for _, sym := range graphSymbols {
@@ -815,7 +1242,8 @@ func initSemanticBQL() {
 
 	// Insert and Delete semantic hooks addition.
 	symbols := []semantic.Symbol{
-		"INSERT_OBJECT", "INSERT_DATA", "DELETE_OBJECT", "DELETE_DATA",
+		"DATA_PREDICATE", "INSERT_OBJECT", "INSERT_DATA", "DELETE_OBJECT",
+		"DELETE_DATA",
 	}
 	for _, sym := range symbols {
 		for _, cls := range (*semanticBQL)[sym] {
@@ -825,10 +1253,19 @@ func initSemanticBQL() {
 	for _, cls := range (*semanticBQL)["INSERT_OBJECT"] {
 		cls.ProcessEnd = semantic.TypeBindingClauseHook(semantic.Insert)
 	}
+	for _, sym := range []semantic.Symbol{"INSERT_ANCHOR", "INSERT_ANCHOR_TIME", "INSERT_ANCHOR_OFFSET"} {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.InsertAnchorHook()
+		}
+	}
 	for _, cls := range (*semanticBQL)["DELETE_OBJECT"] {
 		cls.ProcessEnd = semantic.TypeBindingClauseHook(semantic.Delete)
 	}
 	for _, cls := range (*semanticBQL)["START"] {
+		if cls.Elements[0].Token() == lexer.ItemPrefix {
+			cls.ProcessedElement = semantic.PrefixDeclarationHook()
+			continue
+		}
 		if t := cls.Elements[0].Token(); t != lexer.ItemInsert && t != lexer.ItemDelete {
 			continue
 		}
@@ -840,6 +1277,9 @@ func initSemanticBQL() {
 		cls.ProcessStart = semantic.WhereInitWorkingClauseHook()
 		cls.ProcessEnd = semantic.WhereNextWorkingClauseHook()
 	}
+	for _, cls := range (*semanticBQL)["WHERE_MINUS"] {
+		cls.ProcessStart = semantic.WhereMinusClauseHook()
+	}
 	clauseSymbols := []semantic.Symbol{
 		"CLAUSES", "MORE_CLAUSES",
 	}
@@ -851,7 +1291,7 @@ func initSemanticBQL() {
 	}
 
 	subSymbols := []semantic.Symbol{
-		"CLAUSES", "SUBJECT_EXTRACT", "SUBJECT_TYPE", "SUBJECT_ID",
+		"CLAUSES", "SUBJECT_UNDER", "SUBJECT_EXTRACT", "SUBJECT_TYPE", "SUBJECT_ID",
 	}
 	for _, sym := range subSymbols {
 		for _, cls := range (*semanticBQL)[sym] {
@@ -859,9 +1299,15 @@ func initSemanticBQL() {
 		}
 	}
 
+	for _, cls := range (*semanticBQL)["CLAUSES"] {
+		if cls.Elements[0].Token() == lexer.ItemGraph {
+			cls.ProcessedElement = semantic.WhereGraphClauseHook()
+		}
+	}
+
 	predSymbols := []semantic.Symbol{
-		"PREDICATE", "PREDICATE_AS", "PREDICATE_ID", "PREDICATE_AT", "PREDICATE_BOUND_AT",
-		"PREDICATE_BOUND_AT_BINDINGS", "PREDICATE_BOUND_AT_BINDINGS_END",
+		"PREDICATE", "PREDICATE_PATH", "PREDICATE_AS", "PREDICATE_ID", "PREDICATE_AT", "PREDICATE_BOUND_AT",
+		"PREDICATE_BOUND_AT_BINDINGS", "PREDICATE_BOUND_AT_BINDINGS_END", "PREDICATE_LATEST",
 	}
 	for _, sym := range predSymbols {
 		for _, cls := range (*semanticBQL)[sym] {
@@ -882,4 +1328,79 @@ func initSemanticBQL() {
 			cls.ProcessedElement = semantic.WhereObjectClauseHook()
 		}
 	}
+	embeddedSymbols := []semantic.Symbol{
+		"OBJECT_TRIPLE_SUBJECT", "OBJECT_TRIPLE_PREDICATE", "OBJECT_TRIPLE_OBJECT",
+	}
+	for _, sym := range embeddedSymbols {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.WhereEmbeddedTripleClauseHook()
+		}
+	}
+
+	// Global time bound semantic hooks.
+	timeBoundSymbols := []semantic.Symbol{
+		"GLOBAL_TIME_BOUND", "GLOBAL_TIME_BOUND_COMPOSITE",
+		"GLOBAL_TIME_BOUND_ANCHOR", "GLOBAL_TIME_BOUND_OFFSET",
+	}
+	for _, sym := range timeBoundSymbols {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.GlobalTimeBoundHook()
+		}
+	}
+	for _, cls := range (*semanticBQL)["GLOBAL_TIME_BOUND_ANCHOR"] {
+		cls.ProcessEnd = semantic.GlobalTimeBoundAnchorHook()
+	}
+	for _, cls := range (*semanticBQL)["AS_OF"] {
+		cls.ProcessedElement = semantic.AsOfHook()
+	}
+	for _, cls := range (*semanticBQL)["GRANULARITY"] {
+		cls.ProcessedElement = semantic.GranularityHook()
+	}
+
+	// Select clause, group by, order by, and limit semantic hooks.
+	for _, sym := range []semantic.Symbol{"VARS", "VARS_AS", "COUNT_DISTINCT"} {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.VarAccumulatorHook()
+		}
+	}
+	for _, sym := range []semantic.Symbol{"GROUP_BY", "GROUP_BY_BINDINGS"} {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.GroupByBindingsHook()
+		}
+	}
+	for _, sym := range []semantic.Symbol{"ORDER_BY", "ORDER_BY_KEY", "ORDER_BY_DIRECTION", "ORDER_BY_BINDINGS"} {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.OrderByBindingsHook()
+		}
+	}
+	for _, cls := range (*semanticBQL)["ORDER_BY_KEY"] {
+		cls.ProcessEnd = semantic.OrderByKeyHook()
+	}
+	for _, sym := range []semantic.Symbol{"LIMIT", "LIMIT_PER_GROUP"} {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.LimitCollectionHook()
+		}
+	}
+
+	// Query hint semantic hooks.
+	// Select distinct semantic hook.
+	for _, cls := range (*semanticBQL)["SELECT_DISTINCT"] {
+		cls.ProcessedElement = semantic.SelectDistinctHook()
+	}
+
+	for _, sym := range []semantic.Symbol{"HINTS", "HINT_VALUE", "HINT_LIST"} {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.HintCollectionHook()
+		}
+	}
+
+	// Having semantic hooks.
+	for _, sym := range []semantic.Symbol{"HAVING", "HAVING_CLAUSE", "HAVING_CLAUSE_BINARY_COMPOSITE"} {
+		for _, cls := range (*semanticBQL)[sym] {
+			cls.ProcessedElement = semantic.HavingExpressionHook()
+		}
+	}
+	for _, cls := range (*semanticBQL)["HAVING"] {
+		cls.ProcessEnd = semantic.HavingEvaluatorHook()
+	}
 }
@@ -15,9 +15,11 @@
 package grammar
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
 )
 
 func TestAcceptByParse(t *testing.T) {
@@ -40,6 +42,10 @@ func TestAcceptByParse(t *testing.T) {
 		`select ?a from ?b where{?s as ?x ?p ?o};`,
 		`select ?a from ?b where{?s as ?x type ?y ?p ?o};`,
 		`select ?a from ?b where{?s as ?x type ?y id ?z ?p ?o};`,
+		`select ?a from ?b where{?s under /organization ?p ?o};`,
+		`prefix foaf: /foaf select ?a from ?b where{foaf:<joe> "foaf:knows"@[] ?o};`,
+		`prefix foaf: /foaf prefix ex: /example insert data into ?a {foaf:person<joe> "ex:knows"@[] ex:<mary>};`,
+		`select ?a from ?b where{?s under /u/person as ?x type ?y ?p ?o};`,
 		`select ?a from ?b where{?s ?p as ?x ?o};`,
 		`select ?a from ?b where{?s ?p as ?x id ?y ?o};`,
 		`select ?a from ?b where{?s ?p as ?x id ?y at ?z ?o};`,
@@ -56,6 +62,21 @@ func TestAcceptByParse(t *testing.T) {
 		`select ?a from ?b where{?s ?p ?o};`,
 		`select ?a from ?b where{?s ?p ?o . ?s ?p ?o};`,
 		`select ?a from ?b where{?s ?p ?o . ?s ?p ?o . ?s ?p ?o};`,
+		// Test embedded triples.
+		`select ?a from ?b where{?s ?p <<?x "foo"@[] ?y>>};`,
+		`select ?a from ?b where{?s ?p <</_<foo> ?x /_<bar>>>};`,
+		`insert data into ?a {/_<foo> "bar"@[] <</_<a> "b"@[] <</_<c> "d"@[] "1"^^type:int64>>>>};`,
+		`delete data from ?a {/_<foo> "bar"@[] <</_<a> "b"@[] /_<c>>>};`,
+		// Test default time anchors for insertions.
+		`insert data into ?a at "t"@[2015-01-01T00:00:00Z] {/_<foo> "bar"@[] /_<foo>};`,
+		`insert data into ?a, ?b at now - "24h"^^type:duration {/_<foo> "bar"@[] /_<foo>};`,
+		// Test graph blocks.
+		`select ?a from ?b where{graph ?g {?s ?p ?o}};`,
+		`select ?a from ?b where{?s ?p ?o . graph ?b {?s ?p ?o . ?o ?p ?x} . ?x ?p ?y};`,
+		`select ?a from ?b where{graph ?g {?s ?p ?o . graph ?h {?o ?p ?x}}};`,
+		// Test minus patterns.
+		`select ?a from ?b where{?s ?p ?o} minus {?s ?p ?o};`,
+		`select ?a from ?b where{?s ?p ?o} minus {?s ?p ?o . ?o ?p ?x} minus {?s ?p ?x} group by ?s;`,
 		// Test group by.
 		`select ?a from ?b where{?s ?p ?o} group by ?a;`,
 		`select ?a from ?b where{?s ?p ?o} group by ?a, ?b;`,
@@ -66,6 +87,17 @@ func TestAcceptByParse(t *testing.T) {
 		`select ?a from ?b where{?s ?p ?o} order by ?a desc;`,
 		`select ?a from ?b where{?s ?p ?o} order by ?a asc, ?b desc;`,
 		`select ?a from ?b where{?s ?p ?o} order by ?a desc, ?b desc, ?c asc;`,
+		`select ?a from ?b where{?s ?p ?o} order by strlen(?a) desc;`,
+		`select ?a from ?b where{?s ?p ?o} order by cast(?a as type:int64), ?b desc;`,
+		`select ?a from ?b where{?s ?p ?o} order by ?a, StrLen(?b) asc;`,
+		// Test query hints.
+		`select ?a from ?b where{?s ?p ?o} with hint(ordered);`,
+		`select ?a from ?b where{?s ?p ?o} order by ?a with hint(sortbuffer "1000"^^type:int64);`,
+		`select ?a from ?b where{?s ?p ?o} limit "1"^^type:int64 with hint(noprefetch, maxrows "10"^^type:int64);`,
+		`select ?a from ?b, ?c where{?s ?p ?o} with hint(dedup);`,
+		// Test distinct selections.
+		`select distinct ?a from ?b where{?s ?p ?o};`,
+		`select distinct ?a, count(distinct ?b) as ?c from ?d where{?s ?p ?o};`,
 		// Test having clause.
 		`select ?a from ?b where {?a ?p ?o} having not ?b;`,
 		`select ?a from ?b where {?a ?p ?o} having (not ?b);`,
@@ -76,16 +108,39 @@ func TestAcceptByParse(t *testing.T) {
 		`select ?a from ?b where {?a ?p ?o} having ?b = ?b;`,
 		`select ?a from ?b where {?a ?p ?o} having (?b and ?b) or not (?b = ?b);`,
 		`select ?a from ?b where {?a ?p ?o} having ((?b and ?b) or not (?b = ?b));`,
+		`select ?a from ?b where {?a ?p ?o} having ?b + ?b * ?b > ?b - "1"^^type:int64;`,
+		`select ?a from ?b where {?a ?p ?o} having ?o > "1.5"^^type:float64;`,
+		`select ?a from ?b where {?a ?p ?o} having "1"^^type:int64 < ?o;`,
+		`select ?a from ?b where {?a ?p ?o} having cast(?o as type:int64) = "1"^^type:int64;`,
+		`select ?a from ?b where {?a ?p ?o} having CAST(?o AS type:float64) > "1"^^type:int64 and ?b;`,
 		// Test global time bounds.
 		`select ?a from ?b where {?s ?p ?o} before "foo"@["123"];`,
 		`select ?a from ?b where {?s ?p ?o} after "foo"@["123"];`,
 		`select ?a from ?b where {?s ?p ?o} between "foo"@["123"], "bar"@["123"];`,
 		`select ?a from ?b where {?s ?p ?o} (before "foo"@["123"]);`,
+		`select ?a from ?b where {?s ?p ?o} at "foo"@["123"];`,
+		`select ?a from ?b where {?s ?p ?o} at now - "1h"^^type:duration;`,
 		`select ?a from ?b where {?s ?p ?o} before "foo"@["123"] and before "foo"@["123"];`,
 		`select ?a from ?b where {?s ?p ?o} before "foo"@["123"] or before "foo"@["123"];`,
 		`select ?a from ?b where {?s ?p ?o} before "foo"@["123"] or (before "foo"@["123"] and before "foo"@["123"]);`,
+		`select ?a from ?b where {?s ?p ?o} before now;`,
+		`select ?a from ?b where {?s ?p ?o} after now - "24h"^^type:duration;`,
+		`select ?a from ?b where {?s ?p ?o} between now - "48h"^^type:duration, now + "1h"^^type:duration;`,
+		`select ?a from ?b where {?s ?p ?o} between "foo"@["123"], now;`,
+		// Test latest modifier.
+		`select ?a from ?b where {?s "foo"@[,] latest ?o};`,
+		`select ?a from ?b where {?s "foo"@[2015] latest ?o};`,
+		`select ?a from ?b where {?s ?p as ?q at ?t latest ?o};`,
+		`select ?a from ?b where {?s "foo"@[,] as ?q at ?t latest ?o};`,
+		// Test as of clause.
+		`select ?a from ?b where {?s ?p ?o} as of "2015-06-01T00:00:00Z";`,
+		`select ?a from ?b where {?s ?p ?o} before "foo"@["123"] as of "2015" limit "10"^^type:int64;`,
+		// Test granularity clause.
+		`select ?a from ?b where {?s ?p ?o} granularity "day";`,
+		`select ?a from ?b where {?s ?p ?o} as of "2015" granularity "hour" limit "10"^^type:int64;`,
 		// Test limit clause.
 		`select ?a from ?b where {?s ?p ?o} limit "10"^^type:int64;`,
+		`select ?a from ?b where {?s ?p ?o} group by ?s limit "3"^^type:int64 per group;`,
 		// Insert data.
 		`insert data into ?a {/_<foo> "bar"@["1234"] /_<foo>};`,
 		`insert data into ?a {/_<foo> "bar"@["1234"] "bar"@["1234"]};`,
@@ -100,6 +155,7 @@ func TestAcceptByParse(t *testing.T) {
 		`delete data from ?a {/_<foo> "bar"@["1234"] /_<foo>};`,
 		`delete data from ?a {/_<foo> "bar"@["1234"] "bar"@["1234"]};`,
 		`delete data from ?a {/_<foo> "bar"@["1234"] "yeah"^^type:text};`,
+		`delete data from ?a {/_<foo> "bar"@["1234", "1235"] /_<foo>};`,
 		// Delete from multiple graphs.
 		`delete data from ?a,?b,?c {/_<foo> "bar"@["1234"] /_<foo>};`,
 		// Delete multiple data.
@@ -109,9 +165,25 @@ func TestAcceptByParse(t *testing.T) {
 		// Create graphs.
 		`create graph ?a;`,
 		`create graph ?a, ?b, ?c;`,
+		// Create views.
+		`create view ?v as select ?s, ?o from ?a where {?s "knows"@[] ?o};`,
+		`create view ?v as select distinct ?s from ?a, ?b where {?s "knows"@[] ?o} order by ?s limit "10"^^type:int64;`,
+		// Transitive predicates.
+		`select ?o from ?a where {/u<joe> "knows"@[]+ ?o};`,
+		`select ?o from ?a where {/u<joe> "knows"@[]* as ?p ?o};`,
+		`select ?s, ?o from ?a where {?s "knows"@[]+ ?o. ?o "knows"@[]* /u<joe>};`,
 		// Drop graphs.
 		`drop graph ?a;`,
 		`drop graph ?a, ?b, ?c;`,
+		// Analyze graphs.
+		`analyze graph ?a;`,
+		`analyze graph ?a, ?b, ?c;`,
+		// Quoted graph names.
+		`create graph "/prod/users", ?b;`,
+		`drop graph ?a, "/prod/users";`,
+		`insert data into "/prod/users" {/_<foo> "bar"@[] /_<foo>};`,
+		`delete data from "/prod/users", "/prod/items" {/_<foo> "bar"@[] /_<foo>};`,
+		`select ?s from "/prod/users" where {?s ?p ?o};`,
 	}
 	p, err := NewParser(BQL())
 	if err != nil {
@@ -136,6 +208,36 @@ func TestRejectByParse(t *testing.T) {
 		// Reject missing comas on var bindings or missing graphs.
 		`select ?a from ?b ?c;`,
 		`select ?a from ?b,;`,
+		// Reject invalid prefix declarations.
+		`prefix foaf /foaf select ?a from ?b where{?s ?p ?o};`,
+		`prefix foaf: select ?a from ?b where{?s ?p ?o};`,
+		`prefix foaf: /foaf;`,
+		// Reject invalid subject type filters.
+		`select ?a from ?b where{?s under ?p ?o};`,
+		`select ?a from ?b where{/u<joe> under /u ?p ?o};`,
+		`select ?a from ?b where{?s as ?x under /u ?p ?o};`,
+		// Reject invalid embedded triples.
+		`select ?a from ?b where{?s ?p <<?x "foo"@[]>>};`,
+		`select ?a from ?b where{?s ?p <<?x "foo"@[] <<?a ?b ?c>>>>};`,
+		`select ?a from ?b where{<<?x "foo"@[] ?y>> ?p ?o};`,
+		`insert data into ?a {/_<foo> "bar"@[] <<?x "b"@[] /_<c>>>};`,
+		// Reject invalid default time anchors.
+		`insert data into ?a at {/_<foo> "bar"@[] /_<foo>};`,
+		`insert data into ?a at "1"^^type:int64 {/_<foo> "bar"@[] /_<foo>};`,
+		`delete data from ?a at now {/_<foo> "bar"@[] /_<foo>};`,
+		// Reject invalid graph blocks.
+		`select ?a from ?b where{graph {?s ?p ?o}};`,
+		`select ?a from ?b where{graph ?g ?s ?p ?o};`,
+		`select ?a from ?b where{graph ?g {}};`,
+		`select ?a from ?b where{graph /_<g> {?s ?p ?o}};`,
+		// Reject invalid minus patterns.
+		`select ?a from ?b where{?s ?p ?o} minus;`,
+		`select ?a from ?b where{?s ?p ?o} minus {};`,
+		`select ?a from ?b minus {?s ?p ?o};`,
+		// Reject invalid transitive predicates.
+		`select ?o from ?a where {/u<joe> "knows"@[]+* ?o};`,
+		`select ?o from ?a where {/u<joe> ?p+ ?o};`,
+		`select ?o from ?a where {/u<joe> "knows"@[,]+ ?o};`,
 		// Reject empty where clause.
 		`select ?a from ?b where{};`,
 		// Reject incomplete empty where clause.
@@ -158,6 +260,16 @@ func TestRejectByParse(t *testing.T) {
 		`select ?a from ?b where{?s ?p ?o} by ?a;`,
 		`select ?a from ?b where{?s ?p ?o} order by ?a, a;`,
 		`select ?a from ?b where{?s ?p ?o} order by ?a, ?b, desc;`,
+		`select ?a from ?b where{?s ?p ?o} order by strlen ?a;`,
+		`select ?a from ?b where{?s ?p ?o} order by strlen(?a;`,
+		`select ?a from ?b where{?s ?p ?o} order by cast(?a) desc;`,
+		// Reject invalid query hints.
+		`select ?a from ?b where{?s ?p ?o} with hint();`,
+		`select ?a from ?b where{?s ?p ?o} with hint(ordered,);`,
+		`select ?a from ?b where{?s ?p ?o} with (ordered);`,
+		`select ?a from ?b where{?s ?p ?o} hint(ordered);`,
+		`select distinct distinct ?a from ?b where{?s ?p ?o};`,
+		`select distinct from ?b where{?s ?p ?o};`,
 		// Reject invalid having clauses.
 		`select ?a from ?b where {?a ?p ?o} having not ;`,
 		`select ?a from ?b where {?a ?p ?o} having not ?b ?b;`,
@@ -168,6 +280,9 @@ func TestRejectByParse(t *testing.T) {
 		`select ?a from ?b where {?a ?p ?o} having > ?b;`,
 		`select ?a from ?b where {?a ?p ?o} having ?b = ;`,
 		`select ?a from ?b where {?a ?p ?o} having () or not (?b = ?b);`,
+		`select ?a from ?b where {?a ?p ?o} having cast(?o) = "1"^^type:int64;`,
+		`select ?a from ?b where {?a ?p ?o} having cast(?o as "1"^^type:int64);`,
+		`select ?a from ?b where {?a ?p ?o} having cast("1"^^type:int64 as type:float64);`,
 		`select ?a from ?b where {?a ?p ?o} having ((?b and ?b) (?b = ?b));`,
 		// Reject invalid global time bounds.
 		`select ?a from ?b where {?s ?p ?o} before ;`,
@@ -177,9 +292,25 @@ func TestRejectByParse(t *testing.T) {
 		`select ?a from ?b where {?s ?p ?o} before "foo"@["123"]  before "foo"@["123"];`,
 		`select ?a from ?b where {?s ?p ?o} before "foo"@["123"] or before "foo"@["123"] ,;`,
 		`select ?a from ?b where {?s ?p ?o} before "foo"@["123"] or before "foo"@["123"] and before "foo"@["123"]);`,
+		`select ?a from ?b where {?s ?p ?o} before now - ;`,
+		`select ?a from ?b where {?s ?p ?o} before now "24h"^^type:duration;`,
+		`select ?a from ?b where {?s ?p ?o} before "24h"^^type:duration;`,
+		// Test latest modifier.
+		`select ?a from ?b where {?s latest "foo"@[,] ?o};`,
+		`select ?a from ?b where {?s "foo"@[,] ?o latest};`,
+		// Test as of clause.
+		`select ?a from ?b where {?s ?p ?o} as of ;`,
+		`select ?a from ?b where {?s ?p ?o} as of "foo"@["123"];`,
+		`select ?a from ?b where {?s ?p ?o} limit "10"^^type:int64 as of "2015";`,
+		// Test granularity clause.
+		`select ?a from ?b where {?s ?p ?o} granularity ;`,
+		`select ?a from ?b where {?s ?p ?o} granularity day;`,
+		`select ?a from ?b where {?s ?p ?o} granularity "day" as of "2015";`,
 		// Test limit clause.
 		`select ?a from ?b where {?s ?p ?o} limit ?b;`,
 		`select ?a from ?b where {?s ?p ?o} limit ;`,
+		`select ?a from ?b where {?s ?p ?o} limit "3"^^type:int64 per ;`,
+		`select ?a from ?b where {?s ?p ?o} limit "3"^^type:int64 group;`,
 		// Insert incomplete data.
 		`insert data into ?a {"bar"@["1234"] /_<foo>};`,
 		`insert data into ?a {/_<foo> "bar"@["1234"]};`,
@@ -203,9 +334,20 @@ func TestRejectByParse(t *testing.T) {
 		// Create graphs.
 		`create graph ;`,
 		`create graph ?a, ?b ?c;`,
+		// Create views.
+		`create view as select ?s from ?a where {?s "knows"@[] ?o};`,
+		`create view ?v select ?s from ?a where {?s "knows"@[] ?o};`,
+		`create view ?v as insert data into ?a {/_<foo> "bar"@[] /_<foo>};`,
+		`create view ?v, ?w as select ?s from ?a where {?s "knows"@[] ?o};`,
 		// Drop graphs.
 		`drop graph ;`,
 		`drop graph ?a ?b, ?c;`,
+		// Analyze graphs.
+		`analyze graph ;`,
+		`analyze ?a;`,
+		// Quoted graph names.
+		`create graph "/a" "/b";`,
+		`create graph "/a"^^type:text;`,
 	}
 	p, err := NewParser(BQL())
 	if err != nil {
@@ -228,6 +370,10 @@ func TestAcceptOpsByParseAndSemantic(t *testing.T) {
 		{`insert data into ?a {/_<foo> "bar"@[1975-01-01T00:01:01.999999999Z] /_<foo>};`, 1, 1},
 		{`insert data into ?a {/_<foo> "bar"@[] "bar"@[1975-01-01T00:01:01.999999999Z]};`, 1, 1},
 		{`insert data into ?a {/_<foo> "bar"@[] "yeah"^^type:text};`, 1, 1},
+		// Insert interval predicates.
+		{`insert data into ?a {/_<foo> "bar"@[2015-01-01T00:00:00Z,2016-01-01T00:00:00Z] /_<foo>};`, 1, 1},
+		{`insert data into ?a {/_<foo> "bar"@[] "bar"@[2015,2016]};`, 1, 1},
+		{`insert data into ?a {/_<foo> "bar"@[] <</_<foo> "bar"@[2015,2016] /_<foo>>>};`, 1, 1},
 		// Insert into multiple graphs.
 		{`insert data into ?a,?b,?c {/_<foo> "bar"@[] /_<foo>};`, 3, 1},
 		// Insert multiple data.
@@ -238,6 +384,7 @@ func TestAcceptOpsByParseAndSemantic(t *testing.T) {
 		{`delete data from ?a {/_<foo> "bar"@[] /_<foo>};`, 1, 1},
 		{`delete data from ?a {/_<foo> "bar"@[] "bar"@[1975-01-01T00:01:01.999999999Z]};`, 1, 1},
 		{`delete data from ?a {/_<foo> "bar"@[] "yeah"^^type:text};`, 1, 1},
+		{`delete data from ?a {/_<foo> "bar"@[2015,2016] /_<foo>};`, 1, 1},
 		// Delete from multiple graphs.
 		{`delete data from ?a,?b,?c {/_<foo> "bar"@[1975-01-01T00:01:01.999999999Z] /_<foo>};`, 3, 1},
 		// Delete multiple data.
@@ -246,8 +393,12 @@ func TestAcceptOpsByParseAndSemantic(t *testing.T) {
 			                      /_<foo> "bar"@[] "yeah"^^type:text};`, 1, 3},
 		// Create graphs.
 		{`create graph ?foo;`, 1, 0},
+		// Create views.
+		{`create view ?v as select ?s from ?foo, ?bar where {?s "knows"@[] ?o};`, 2, 0},
 		// Drop graphs.
 		{`drop graph ?foo, ?bar;`, 2, 0},
+		// Analyze graphs.
+		{`analyze graph ?foo, ?bar;`, 2, 0},
 	}
 	p, err := NewParser(SemanticBQL())
 	if err != nil {
@@ -256,7 +407,7 @@ func TestAcceptOpsByParseAndSemantic(t *testing.T) {
 	for _, entry := range table {
 		st := &semantic.Statement{}
 		if err := p.Parse(NewLLk(entry.query, 1), st); err != nil {
-			t.Errorf("Parser.consume: failed to accept entry %q with error %v", entry, err)
+			t.Errorf("Parser.consume: failed to accept entry %v with error %v", entry, err)
 		}
 		if got, want := len(st.Graphs()), entry.graphs; got != want {
 			t.Errorf("Parser.consume: failed to collect right number of graphs for case %v; got %d, want %d", entry, got, want)
@@ -291,14 +442,63 @@ func TestAcceptQueryBySemanticParse(t *testing.T) {
 		// Test predicate bounds with bounds are accepted.
 		`select ?s from ?g where{/_<foo> as ?s "id"@[?foo, 2016-07-19T13:12:04.669618843-07:00] ?o};`,
 		`select ?s from ?g where{/_<foo> as ?s  ?p "id"@[2015-07-19T13:12:04.669618843-07:00, ?bar] as ?o};`,
-		`select ?s from ?g where{/_<foo> as ?s  ?p "id"@[?foo, ?bar] as ?o};`}
+		`select ?s from ?g where{/_<foo> as ?s  ?p "id"@[?foo, ?bar] as ?o};`,
+		// Test global time bounds are accepted.
+		`select ?s from ?g where{?s ?p ?o} before "foo"@[2015-07-19T13:12:04.669618843-07:00];`,
+		`select ?s from ?g where{?s ?p ?o} after now - "24h"^^type:duration;`,
+		`select ?s from ?g where{?s ?p ?o} between now - "48h"^^type:duration, now - "24h"^^type:duration;`,
+		`select ?s from ?g where{?s ?p ?o} after now - "1h"^^type:duration and before now;`,
+		// Test limits are accepted.
+		`select ?s from ?g where{?s ?p ?o} limit "10"^^type:int64;`,
+		// Test minus patterns are accepted.
+		`select ?s from ?g where{?s ?p ?o} minus {?s "foo"@[] ?o};`,
+		`select ?s from ?g where{?s ?p ?o} group by ?s order by ?o desc limit "3"^^type:int64 per group;`,
+		// Test distinct selections are accepted.
+		`select distinct ?s from ?g, ?h where{?s ?p ?o} with hint(dedup);`}
 	p, err := NewParser(SemanticBQL())
 	if err != nil {
 		t.Errorf("grammar.NewParser: should have produced a valid BQL parser")
 	}
 	for _, input := range table {
-		if err := p.Parse(NewLLk(input, 1), &semantic.Statement{}); err != nil {
+		st := &semantic.Statement{}
+		if err := p.Parse(NewLLk(input, 1), st); err != nil {
 			t.Errorf("Parser.consume: failed to accept input %q with error %v", input, err)
+			continue
+		}
+		// The text of the statement should produce the same statement.
+		rst := &semantic.Statement{}
+		if err := p.Parse(NewLLk(st.String(), 1), rst); err != nil {
+			t.Errorf("Parser.consume: failed to accept text %q of %q with error %v", st, input, err)
+			continue
+		}
+		if got, want := rst.String(), st.String(); got != want {
+			t.Errorf("Statement.String: got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestStatementString(t *testing.T) {
+	table := []struct {
+		in, want string
+	}{
+		{`create graph ?a,?b;`, `create graph ?a, ?b;`},
+		{`drop graph "/a","/b";`, `drop graph "/a", "/b";`},
+		{`select ?s,count( ?o ) as ?n from ?g where{?s ?p ?o} group by ?s;`, `select ?s, count(?o) as ?n from ?g where { ?s ?p ?o } group by ?s;`},
+		{`insert data into ?a {/u<a> "p"@[] /u<b>.
+		  /u<a> "p"@[] <</u<b> "q"@[] /u<c>>>};`, `insert data into ?a { /u<a> "p"@[] /u<b> . /u<a> "p"@[] <</u<b> "q"@[] /u<c>>> };`},
+	}
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
+	}
+	for _, entry := range table {
+		st := &semantic.Statement{}
+		if err := p.Parse(NewLLk(entry.in, 1), st); err != nil {
+			t.Errorf("Parser.Parse: failed to accept input %q with error %v", entry.in, err)
+			continue
+		}
+		if got := st.String(); got != entry.want {
+			t.Errorf("Statement.String: got %q, want %q", got, entry.want)
 		}
 	}
 }
@@ -310,6 +510,28 @@ func TestRejectByParseAndSemantic(t *testing.T) {
 		// Test invalid predicate bounds are rejected.
 		`select ?s from ?b where{/_<foo> as ?s "id"@[2018-07-19T13:12:04.669618843-07:00, 2015-07-19T13:12:04.669618843-07:00] ?o};`,
 		`select ?s from ?b where{/_<foo> as ?s  ?p "id"@[2019-07-19T13:12:04.669618843-07:00, 2015-07-19T13:12:04.669618843-07:00] as ?o};`,
+		// Test invalid global time bounds are rejected.
+		`select ?s from ?g where{?s ?p ?o} before "foo"@[];`,
+		`select ?s from ?g where{?s ?p ?o} before now - "1"^^type:int64;`,
+		`select ?s from ?g where{?s ?p ?o} between now, now - "24h"^^type:duration;`,
+		`select ?s from ?g where{?s ?p ?o} before now or after now;`,
+		// Test invalid interval predicates are rejected.
+		`insert data into ?a {/_<foo> "bar"@[2016,2015] /_<foo>};`,
+		// Test transitive predicates with bound time anchors are rejected.
+		`select ?o from ?g where{/u<joe> "knows"@[?t]+ ?o};`,
+		// Test undeclared or duplicated prefixes are rejected.
+		`select ?s from ?g where{foaf:<joe> ?p ?o};`,
+		`prefix foaf: /foaf select ?s from ?g where{ex:<joe> ?p ?o};`,
+		`prefix foaf: /foaf prefix foaf: /other select ?s from ?g where{?s ?p ?o};`,
+		// Test invalid subject type filters are rejected.
+		`select ?s from ?g where{?s under /u/ ?p ?o};`,
+		// Test unknown granularities are rejected.
+		`select ?s from ?g where{?s ?p ?o} granularity "week";`,
+		// Test invalid limits are rejected.
+		`select ?s from ?g where{?s ?p ?o} limit "10"^^type:text;`,
+		`select ?s from ?g where{?s ?p ?o} limit "3"^^type:int64 per group;`,
+		// Test empty graph names are rejected.
+		`create graph "";`,
 	}
 	p, err := NewParser(SemanticBQL())
 	if err != nil {
@@ -322,3 +544,74 @@ func TestRejectByParseAndSemantic(t *testing.T) {
 		}
 	}
 }
+
+func TestQuotedGraphNamesBySemanticParse(t *testing.T) {
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(NewLLk(`select ?s from ?a, "/prod/users", "say \"hi\"" where {?s ?p ?o};`, 1), st); err != nil {
+		t.Fatalf("Parser.Parse: failed to accept quoted graph names with error %v", err)
+	}
+	if got, want := st.Graphs(), []string{"?a", "/prod/users", `say "hi"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parser.Parse: got graphs %q, want %q", got, want)
+	}
+}
+
+func TestCreateViewBySemanticParse(t *testing.T) {
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser")
+	}
+	st := &semantic.Statement{}
+	q := `create view ?v as select ?s, ?o from ?a where {?s "knows"@[] ?o. ?o "knows"@[] ?x};`
+	if err := p.Parse(NewLLk(q, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to accept %q with error %v", q, err)
+	}
+	if got, want := st.Type(), semantic.CreateView; got != want {
+		t.Errorf("Parser.consume: wrong statement type for %q; got %v, want %v", q, got, want)
+	}
+	if got, want := st.View(), "?v"; got != want {
+		t.Errorf("Parser.consume: wrong view name for %q; got %q, want %q", q, got, want)
+	}
+	if got, want := len(st.OrderedGraphPatternClauses()), 2; got != want {
+		t.Errorf("Parser.consume: wrong number of clauses for %q; got %d, want %d", q, got, want)
+	}
+}
+
+func TestProjectionsBySemanticParse(t *testing.T) {
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser")
+	}
+	testTable := []struct {
+		q    string
+		want []*semantic.Projection
+	}{
+		{
+			q: `select ?s, ?o as ?x from ?a where {?s "knows"@[] ?o};`,
+			want: []*semantic.Projection{
+				{Binding: "?s"},
+				{Binding: "?o", Alias: "?x"},
+			},
+		},
+		{
+			q: `select ?s as ?p, count(distinct ?o) as ?n, sum(?o) as ?t from ?a where {?s "knows"@[] ?o} group by ?p;`,
+			want: []*semantic.Projection{
+				{Binding: "?s", Alias: "?p"},
+				{Binding: "?o", Alias: "?n", Aggregate: true, Kind: table.Count, Distinct: true},
+				{Binding: "?o", Alias: "?t", Aggregate: true, Kind: table.Sum},
+			},
+		},
+	}
+	for _, entry := range testTable {
+		st := &semantic.Statement{}
+		if err := p.Parse(NewLLk(entry.q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to accept %q with error %v", entry.q, err)
+		}
+		if got, want := st.Projections(), entry.want; !reflect.DeepEqual(got, want) {
+			t.Errorf("Parser.consume: wrong projections for %q; got %v, want %v", entry.q, got, want)
+		}
+	}
+}
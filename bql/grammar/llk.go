@@ -16,6 +16,7 @@ package grammar
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/google/badwolf/bql/lexer"
 )
@@ -26,15 +27,48 @@ type LLk struct {
 	k    int
 	c    <-chan lexer.Token
 	tkns []lexer.Token
+
+	// pending contains the token types that the symbols derived into the empty
+	// string since the last consumed token would have accepted. They are used
+	// to report the expected tokens on syntax errors.
+	pending []lexer.TokenType
+
+	// line and col contain the 1-based position of the scanned input in the
+	// original text, used to report the position of the tokens when only part
+	// of the original text is scanned.
+	line int
+	col  int
+
+	// last contains the type of the last consumed token.
+	last lexer.TokenType
 }
 
 // NewLLk creates a LLk structure for the given string to parse and the
 // indicated k lookahead.
 func NewLLk(input string, k int) *LLk {
-	c := lexer.New(input, 2*k) // +2 to keep a bit of buffer available.
+	return newLLkAt(input, k, 1, 1)
+}
+
+// newLLkAt creates a LLk structure for the given string to parse, which starts
+// at the provided line and column of the original text.
+func newLLkAt(input string, k, line, col int) *LLk {
+	return newLLk(lexer.New(input, 2*k), k, line, col) // +2 to keep a bit of buffer available.
+}
+
+// NewLLkFromReader creates a LLk structure for the text provided by the
+// reader and the indicated k lookahead. The text is scanned incrementally, so
+// large scripts can be parsed without loading them into memory.
+func NewLLkFromReader(r io.Reader, k int) *LLk {
+	return newLLk(lexer.NewReaderLexer(r, 2*k), k, 1, 1)
+}
+
+// newLLk creates a LLk structure for the provided token channel.
+func newLLk(c <-chan lexer.Token, k, line, col int) *LLk {
 	l := &LLk{
-		k: k,
-		c: c,
+		k:    k,
+		c:    c,
+		line: line,
+		col:  col,
 	}
 	for i := 0; i < k+1; i++ {
 		appendNextToken(l)
@@ -47,14 +81,22 @@ func NewLLk(input string, k int) *LLk {
 func appendNextToken(l *LLk) {
 	i := 0
 	for t := range l.c {
+		if t.Line == 1 {
+			t.Col += l.col - 1
+		}
+		t.Line += l.line - 1
 		l.tkns = append(l.tkns, t)
 		i++
 		break
 	}
 	for ; i < 1; i++ {
-		l.tkns = append(l.tkns, lexer.Token{
+		eof := lexer.Token{
 			Type: lexer.ItemEOF,
-		})
+		}
+		if n := len(l.tkns); n > 0 {
+			eof.Line, eof.Col = l.tkns[n-1].Line, l.tkns[n-1].Col
+		}
+		l.tkns = append(l.tkns, eof)
 	}
 }
 
@@ -82,6 +124,20 @@ func (l *LLk) CanAccept(tt lexer.TokenType) bool {
 	return l.tkns[0].Type == tt
 }
 
+// canAcceptSequence returns true if the provided token types match the current
+// token and the ones that follow it.
+func (l *LLk) canAcceptSequence(seq []lexer.TokenType) bool {
+	if len(seq) > len(l.tkns) {
+		return false
+	}
+	for i, tt := range seq {
+		if l.tkns[i].Type != tt {
+			return false
+		}
+	}
+	return true
+}
+
 // Consume will consue the current token and move to the next one if it matches
 // the provided token, false otherwise.
 func (l *LLk) Consume(tt lexer.TokenType) bool {
@@ -89,6 +145,8 @@ func (l *LLk) Consume(tt lexer.TokenType) bool {
 		return false
 	}
 	l.tkns = l.tkns[1:]
+	l.pending = l.pending[:0]
+	l.last = tt
 	appendNextToken(l)
 	return true
 }
@@ -15,9 +15,12 @@
 package grammar
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
 )
 
 func TestEmptyImputLLk(t *testing.T) {
@@ -66,3 +69,34 @@ func TestNonEmptyInputLLk(t *testing.T) {
 		t.Errorf("LLk.Peek(1): should return ItemEOF at the end of input instead of %s", tkn.Type)
 	}
 }
+
+func TestLLkFromReader(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("insert data into ?a {\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, "  /u<user %d> \"knows\"@[] \"user %d\"^^type:text .\n", i, i)
+	}
+	b.WriteString("  /u<joe> \"knows\"@[] /u<mary>\n};\ndrop graph ?a;")
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
+	}
+	llk := NewLLkFromReader(strings.NewReader(b.String()), 1)
+	st := &semantic.Statement{}
+	if err := p.Parse(llk, st); err != nil {
+		t.Fatalf("Parser.Parse: failed to parse insert statement with error %v", err)
+	}
+	if got, want := len(st.Data()), 1001; got != want {
+		t.Errorf("Parser.Parse: got %d triples, want %d", got, want)
+	}
+	st = &semantic.Statement{}
+	if err := p.Parse(llk, st); err != nil {
+		t.Fatalf("Parser.Parse: failed to parse drop statement with error %v", err)
+	}
+	if got, want := st.Type(), semantic.Drop; got != want {
+		t.Errorf("Parser.Parse: got statement type %v, want %v", got, want)
+	}
+	if !llk.CanAccept(lexer.ItemEOF) {
+		t.Errorf("NewLLkFromReader: expected EOF, got %v", llk.Current())
+	}
+}
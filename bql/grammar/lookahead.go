@@ -0,0 +1,179 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grammar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// maxLookAhead contains the largest look ahead that NewParser infers for a
+// grammar.
+const maxLookAhead = 4
+
+// lookAhead contains the token sequences that select each clause of a grammar
+// for a given look ahead k. Sequences shorter than k are produced by clauses
+// whose derivations end before k tokens, and match on their prefix.
+type lookAhead struct {
+	g    *Grammar
+	k    int
+	memo map[memoKey][][]lexer.TokenType
+	seqs map[*Clause][][]lexer.TokenType
+}
+
+// memoKey identifies the sequences of a symbol for a given look ahead.
+type memoKey struct {
+	s semantic.Symbol
+	k int
+}
+
+// newLookAhead computes the look ahead sequences of all the clauses of the
+// grammar for the provided k.
+func newLookAhead(g *Grammar, k int) *lookAhead {
+	la := &lookAhead{
+		g:    g,
+		k:    k,
+		memo: make(map[memoKey][][]lexer.TokenType),
+		seqs: make(map[*Clause][][]lexer.TokenType),
+	}
+	for _, clss := range *g {
+		for _, cls := range clss {
+			la.seqs[cls] = la.elements(cls.Elements, k)
+		}
+	}
+	return la
+}
+
+// elements returns the sequences of up to k tokens derived by the provided
+// elements.
+func (la *lookAhead) elements(elems []Element, k int) [][]lexer.TokenType {
+	if k == 0 || len(elems) == 0 {
+		return [][]lexer.TokenType{{}}
+	}
+	var res [][]lexer.TokenType
+	e := elems[0]
+	if !e.isSymbol {
+		for _, s := range la.elements(elems[1:], k-1) {
+			res = append(res, append([]lexer.TokenType{e.Token()}, s...))
+		}
+		return dedup(res)
+	}
+	for _, s := range la.symbol(e.Symbol(), k) {
+		if len(s) == k {
+			res = append(res, s)
+			continue
+		}
+		for _, rest := range la.elements(elems[1:], k-len(s)) {
+			res = append(res, append(append([]lexer.TokenType{}, s...), rest...))
+		}
+	}
+	return dedup(res)
+}
+
+// symbol returns the sequences of up to k tokens derived by the symbol.
+// Recursion always progresses, since all non empty clauses start with a token.
+func (la *lookAhead) symbol(s semantic.Symbol, k int) [][]lexer.TokenType {
+	key := memoKey{s, k}
+	if seqs, ok := la.memo[key]; ok {
+		return seqs
+	}
+	var res [][]lexer.TokenType
+	for _, cls := range (*la.g)[s] {
+		res = append(res, la.elements(cls.Elements, k)...)
+	}
+	res = dedup(res)
+	la.memo[key] = res
+	return res
+}
+
+// conflict returns an error if two non empty clauses of a symbol cannot be
+// told apart with the look ahead.
+func (la *lookAhead) conflict() error {
+	for s, clss := range *la.g {
+		for i, ci := range clss {
+			for _, cj := range clss[i+1:] {
+				if len(ci.Elements) == 0 || len(cj.Elements) == 0 {
+					// The empty derivation is only chosen if no other applies.
+					continue
+				}
+				for _, si := range la.seqs[ci] {
+					for _, sj := range la.seqs[cj] {
+						if isPrefix(si, sj) || isPrefix(sj, si) {
+							return fmt.Errorf("grammar.NewParser: clauses %v and %v of symbol %q cannot be told apart with look ahead %d", ci.Elements, cj.Elements, s, la.k)
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// inferLookAhead returns the smallest look ahead able to tell apart the
+// clauses of all the symbols in the grammar, along with its sequences.
+func inferLookAhead(g *Grammar) (*lookAhead, error) {
+	var err error
+	for k := 1; k <= maxLookAhead; k++ {
+		la := newLookAhead(g, k)
+		if err = la.conflict(); err == nil {
+			return la, nil
+		}
+	}
+	return nil, err
+}
+
+// accepts returns true if the look ahead tokens match any of the sequences of
+// the clause.
+func (la *lookAhead) accepts(llk *LLk, cls *Clause) bool {
+	for _, seq := range la.seqs[cls] {
+		if llk.canAcceptSequence(seq) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrefix returns true if a is a prefix of b.
+func isPrefix(a, b []lexer.TokenType) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for i, tt := range a {
+		if b[i] != tt {
+			return false
+		}
+	}
+	return true
+}
+
+// dedup removes duplicated sequences.
+func dedup(seqs [][]lexer.TokenType) [][]lexer.TokenType {
+	seen := make(map[string]bool)
+	var res [][]lexer.TokenType
+	for _, s := range seqs {
+		var b strings.Builder
+		for _, tt := range s {
+			fmt.Fprintf(&b, "%d,", tt)
+		}
+		if key := b.String(); !seen[key] {
+			seen[key] = true
+			res = append(res, s)
+		}
+	}
+	return res
+}
@@ -0,0 +1,115 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grammar
+
+import (
+	"testing"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// twoTokenGrammar returns a grammar whose statements can only be told apart
+// by their second token.
+func twoTokenGrammar() *Grammar {
+	return &Grammar{
+		"START": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemQuery),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemQuery),
+					NewSymbol("VARS"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+		},
+		"VARS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNode),
+				},
+			},
+		},
+	}
+}
+
+func TestLookAheadInference(t *testing.T) {
+	table := []struct {
+		g *Grammar
+		k int
+	}{
+		{SemanticBQL(), 1},
+		{twoTokenGrammar(), 2},
+	}
+	for _, entry := range table {
+		p, err := NewParser(entry.g)
+		if err != nil {
+			t.Fatalf("grammar.NewParser: should have produced a valid parser, %v", err)
+		}
+		if got, want := p.LookAhead(), entry.k; got != want {
+			t.Errorf("Parser.LookAhead: got %d, want %d", got, want)
+		}
+	}
+}
+
+func TestLookAheadParse(t *testing.T) {
+	p, err := NewParser(twoTokenGrammar())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid parser, %v", err)
+	}
+	for _, in := range []string{`select ?a;`, `select /u<joe>;`} {
+		if err := p.Parse(NewLLk(in, p.LookAhead()), &semantic.Statement{}); err != nil {
+			t.Errorf("Parser.Parse: failed to accept %q; %v", in, err)
+		}
+		if _, err := p.ParseTree(NewLLk(in, p.LookAhead())); err != nil {
+			t.Errorf("Parser.ParseTree: failed to accept %q; %v", in, err)
+		}
+	}
+	if err := p.Parse(NewLLk(`select "foo"@[];`, p.LookAhead()), &semantic.Statement{}); err == nil {
+		t.Errorf("Parser.Parse: should have rejected a statement not derived by the grammar")
+	}
+	if err := p.Parse(NewLLk(`select ?a;`, 1), &semantic.Statement{}); err == nil {
+		t.Errorf("Parser.Parse: should have rejected a look ahead smaller than the grammar's")
+	}
+}
+
+func TestLookAheadAmbiguousGrammarFailed(t *testing.T) {
+	_, err := NewParser(&Grammar{
+		"START": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemQuery),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemQuery),
+					NewTokenType(lexer.ItemSemicolon),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+		},
+	})
+	if err == nil {
+		t.Errorf("grammar.NewParser: should have failed given clauses that no look ahead can tell apart")
+	}
+}
@@ -16,6 +16,9 @@ package grammar
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/google/badwolf/bql/lexer"
 	"github.com/google/badwolf/bql/semantic"
@@ -70,11 +73,20 @@ type Grammar map[semantic.Symbol][]*Clause
 // Parser implements a LLk recursive decend parser for left factorized grammars.
 type Parser struct {
 	grammar *Grammar
+	// la contains the look ahead sequences used to choose clauses.
+	la *lookAhead
 }
 
 // NewParser creates a new recursive decend parser for a left factorized
-// grammar.
-func NewParser(grammar *Grammar) (*Parser, error) {
+// grammar. The provided extensions are added to a copy of the grammar.
+func NewParser(grammar *Grammar, exts ...*Extension) (*Parser, error) {
+	if len(exts) > 0 {
+		g, err := extend(grammar, exts)
+		if err != nil {
+			return nil, err
+		}
+		grammar = g
+	}
 	// Check that the grammar is left factorized.
 	for _, clauses := range *grammar {
 		idx := 0
@@ -91,13 +103,44 @@ func NewParser(grammar *Grammar) (*Parser, error) {
 			}
 		}
 	}
+	la, err := inferLookAhead(grammar)
+	if err != nil {
+		return nil, err
+	}
 	return &Parser{
 		grammar: grammar,
+		la:      la,
 	}, nil
 }
 
+// LookAhead returns the number of tokens the parser needs to look ahead to
+// choose the clauses of the grammar. The LLk provided to the parser should be
+// created with at least this look ahead.
+func (p *Parser) LookAhead() int {
+	return p.la.k
+}
+
+// checkLookAhead returns an error if the LLk does not look ahead enough
+// tokens for the grammar of the parser.
+func (p *Parser) checkLookAhead(llk *LLk) error {
+	if llk.k < p.la.k {
+		return fmt.Errorf("grammar.Parser: the grammar requires a look ahead of %d, got %d", p.la.k, llk.k)
+	}
+	return nil
+}
+
+// parseMu serializes parsing across all parsers. The semantic hooks run while
+// parsing are shared by all of them and keep the state of the statement being
+// parsed, hence only one statement can be parsed at a time.
+var parseMu sync.Mutex
+
 // Parse attempts to run the parser for the given input.
 func (p *Parser) Parse(llk *LLk, st *semantic.Statement) error {
+	if err := p.checkLookAhead(llk); err != nil {
+		return err
+	}
+	parseMu.Lock()
+	defer parseMu.Unlock()
 	b, err := p.consume(llk, st, "START")
 	if err != nil {
 		return err
@@ -108,58 +151,254 @@ func (p *Parser) Parse(llk *LLk, st *semantic.Statement) error {
 	return nil
 }
 
+// ParseError describes where and why the parser failed to parse its input.
+type ParseError struct {
+	// Line and Column contain the 1-based position of the offending token.
+	Line   int
+	Column int
+
+	// Token contains the offending token.
+	Token lexer.Token
+
+	// Expected contains the token types that would have been accepted instead
+	// of the offending token, if the failure was a syntax error.
+	Expected []lexer.TokenType
+
+	// Err contains the underlying error if the failure was triggered by a
+	// semantic check.
+	Err error
+}
+
+// Error returns the description of the parse error.
+func (e *ParseError) Error() string {
+	pos := fmt.Sprintf("grammar.Parser: line %d, column %d", e.Line, e.Column)
+	switch {
+	case e.Token.Type == lexer.ItemError:
+		return fmt.Sprintf("%s: %s", pos, e.Token.ErrorMessage)
+	case e.Err != nil:
+		return fmt.Sprintf("%s: near %q: %v", pos, e.Token.Text, e.Err)
+	}
+	var exp []string
+	for _, tt := range e.Expected {
+		exp = append(exp, tt.String())
+	}
+	return fmt.Sprintf("%s: unexpected %s %q; expected one of %s", pos, e.Token.Type, e.Token.Text, strings.Join(exp, ", "))
+}
+
+// newParseError returns the parse error for the current token of the provided
+// LLk.
+func newParseError(llk *LLk, expected []lexer.TokenType, err error) error {
+	if perr, ok := err.(*ParseError); ok {
+		return perr
+	}
+	tkn := llk.Current()
+	m := make(map[lexer.TokenType]bool)
+	for _, tt := range append(llk.pending, expected...) {
+		m[tt] = true
+	}
+	var exp []lexer.TokenType
+	for tt := range m {
+		exp = append(exp, tt)
+	}
+	sort.Slice(exp, func(i, j int) bool { return exp[i] < exp[j] })
+	return &ParseError{
+		Line:     tkn.Line,
+		Column:   tkn.Col,
+		Token:    *tkn,
+		Expected: exp,
+		Err:      err,
+	}
+}
+
+// ParseScript parses all the statements on the provided BQL script. Instead of
+// stopping at the first error, it resynchronizes at the semicolon that ends
+// the failing statement and keeps parsing, so all the problems on the script
+// can be reported at once. It returns the statements successfully parsed and
+// the errors found, in the order they appear on the script.
+func (p *Parser) ParseScript(script string) ([]*semantic.Statement, []*ParseError) {
+	var (
+		stms []*semantic.Statement
+		errs []*ParseError
+	)
+	parseMu.Lock()
+	defer parseMu.Unlock()
+	llk := NewLLk(script, p.la.k)
+	for !llk.CanAccept(lexer.ItemEOF) {
+		st := &semantic.Statement{}
+		llk.last = lexer.ItemEOF
+		b, err := p.consume(llk, st, "START")
+		if err == nil && b {
+			stms = append(stms, st)
+			continue
+		}
+		perr, ok := err.(*ParseError)
+		if !ok {
+			perr = newParseError(llk, nil, err).(*ParseError)
+		}
+		errs = append(errs, perr)
+		llk = resync(script, llk)
+	}
+	return stms, errs
+}
+
+// resync skips the tokens of a failed statement up to the semicolon ending it.
+// Since the lexer stops scanning on errors, lexer errors are skipped by
+// scanning again the script after the next semicolon.
+func resync(script string, llk *LLk) *LLk {
+	if llk.last == lexer.ItemSemicolon {
+		// The failure was found once the statement was fully consumed.
+		return llk
+	}
+	for {
+		tkn := llk.Current()
+		switch tkn.Type {
+		case lexer.ItemEOF:
+			return llk
+		case lexer.ItemSemicolon:
+			llk.Consume(lexer.ItemSemicolon)
+			return llk
+		case lexer.ItemError:
+			off := offset(script, tkn.Line, tkn.Col)
+			idx := strings.IndexRune(script[off:], ';')
+			if idx < 0 {
+				return NewLLk("", llk.k)
+			}
+			off += idx + 1
+			line, col := position(script, off)
+			return newLLkAt(script[off:], llk.k, line, col)
+		default:
+			llk.Consume(tkn.Type)
+		}
+	}
+}
+
+// offset returns the byte offset in s of the provided 1-based line and column.
+func offset(s string, line, col int) int {
+	l, c := 1, 1
+	for i, r := range s {
+		if l == line && c == col {
+			return i
+		}
+		c++
+		if r == '\n' {
+			l, c = l+1, 1
+		}
+	}
+	return len(s)
+}
+
+// position returns the 1-based line and column of the provided byte offset in
+// s.
+func position(s string, off int) (int, int) {
+	l, c := 1, 1
+	for _, r := range s[:off] {
+		c++
+		if r == '\n' {
+			l, c = l+1, 1
+		}
+	}
+	return l, c
+}
+
 // consume attempts to consume all input tokens for the provided symbols given
 // the parser grammar.
 func (p *Parser) consume(llk *LLk, st *semantic.Statement, s semantic.Symbol) (bool, error) {
+	clause, first, err := p.choose(llk, s)
+	if err != nil {
+		return false, err
+	}
+	if len(clause.Elements) == 0 {
+		// The symbol derives the empty string, hence the tokens that would
+		// have started the other derivations are still acceptable.
+		llk.pending = append(llk.pending, first...)
+		return true, nil
+	}
+	return p.expect(llk, st, s, clause)
+}
+
+// choose returns the clause to derive the provided symbol with, given the
+// tokens looked ahead. It also returns the tokens that would have started the
+// clauses not chosen.
+func (p *Parser) choose(llk *LLk, s semantic.Symbol) (*Clause, []lexer.TokenType, error) {
+	var first []lexer.TokenType
 	for _, clause := range (*p.grammar)[s] {
 		if len(clause.Elements) == 0 {
-			return true, nil
+			return clause, first, nil
 		}
 		elem := clause.Elements[0]
 		if elem.isSymbol {
-			return false, fmt.Errorf("Parser.consume: not left factored grammar in %v", clause)
+			return nil, nil, fmt.Errorf("Parser.consume: not left factored grammar in %v", clause)
+		}
+		if p.la.accepts(llk, clause) {
+			return clause, first, nil
 		}
-		if llk.CanAccept(elem.Token()) {
-			return p.expect(llk, st, s, clause)
+		if !llk.CanAccept(elem.Token()) {
+			first = append(first, elem.Token())
 		}
 	}
-	return false, fmt.Errorf("Parser.consume: could not consume token %s in production %s", llk.Current(), s)
+	return nil, nil, newParseError(llk, first, nil)
 }
 
 // expect given the input, symbol, and clause attemps to satisfy all elements.
 func (p *Parser) expect(llk *LLk, st *semantic.Statement, s semantic.Symbol, cls *Clause) (bool, error) {
 	if cls.ProcessStart != nil {
 		if _, err := cls.ProcessStart(st, s); err != nil {
-			return false, err
+			return false, newParseError(llk, nil, err)
 		}
 	}
 	for _, elem := range cls.Elements {
 		tkn := llk.Current()
 		if elem.isSymbol {
 			if b, err := p.consume(llk, st, elem.Symbol()); !b || err != nil {
+				if _, ok := err.(*ParseError); ok {
+					return false, err
+				}
 				return false, fmt.Errorf("Parser.parse: Failed to consume symbol %v, with error %v", elem.Symbol(), err)
 			}
 		} else {
+			ctkn := *tkn
 			if !llk.Consume(elem.Token()) {
-				return false, fmt.Errorf("Parser.parse: Failed to consume %s, got %s instead", elem.Token(), llk.Current().Type)
+				return false, newParseError(llk, []lexer.TokenType{elem.Token()}, nil)
 			}
+			st.AddToken(&ctkn)
 		}
 		if cls.ProcessedElement != nil {
 			var ce semantic.ConsumedElement
 			if elem.isSymbol {
 				ce = semantic.NewConsumedSymbol(ce.Symbol())
 			} else {
-				ce = semantic.NewConsumedToken(tkn)
+				etkn, err := st.ExpandPrefixes(tkn)
+				if err != nil {
+					return false, parseErrorAt(tkn, err)
+				}
+				ce = semantic.NewConsumedToken(etkn)
 			}
 			if _, err := cls.ProcessedElement(st, ce); err != nil {
-				return false, err
+				if elem.isSymbol {
+					return false, newParseError(llk, nil, err)
+				}
+				return false, parseErrorAt(tkn, err)
 			}
 		}
 	}
 	if cls.ProcessEnd != nil {
 		if _, err := cls.ProcessEnd(st, s); err != nil {
-			return false, err
+			return false, newParseError(llk, nil, err)
 		}
 	}
 	return true, nil
 }
+
+// parseErrorAt returns the parse error for a semantic failure triggered by the
+// provided token.
+func parseErrorAt(tkn *lexer.Token, err error) error {
+	if perr, ok := err.(*ParseError); ok {
+		return perr
+	}
+	return &ParseError{
+		Line:   tkn.Line,
+		Column: tkn.Col,
+		Token:  *tkn,
+		Err:    err,
+	}
+}
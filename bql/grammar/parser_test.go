@@ -15,6 +15,7 @@
 package grammar
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/google/badwolf/bql/lexer"
@@ -227,3 +228,106 @@ func TestComplexGrammarParse(t *testing.T) {
 		t.Errorf("Parser.consume: failed to accept derivation tokens; %v", err)
 	}
 }
+
+func TestParseErrorPositions(t *testing.T) {
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	table := []struct {
+		q         string
+		line, col int
+		tkn       lexer.TokenType
+		expected  []lexer.TokenType
+		semantic  bool
+	}{
+		{
+			q:        "select ?a ?b from ?g where {?s ?p ?o};",
+			line:     1,
+			col:      11,
+			tkn:      lexer.ItemBinding,
+			expected: []lexer.TokenType{lexer.ItemFrom, lexer.ItemAs, lexer.ItemComma},
+		},
+		{
+			q:    "select ?a from ?g\n  where {?s ?p};",
+			line: 2,
+			col:  15,
+			tkn:  lexer.ItemRBracket,
+			expected: []lexer.TokenType{
+				lexer.ItemAs, lexer.ItemLatest, lexer.ItemID, lexer.ItemAt, lexer.ItemBinding, lexer.ItemNode,
+				lexer.ItemLiteral, lexer.ItemPredicate, lexer.ItemPredicateBound, lexer.ItemLTriple,
+			},
+		},
+		{
+			q:    "select ?a from ?g where {?s ?p /_<foo};",
+			line: 1,
+			col:  32,
+			tkn:  lexer.ItemError,
+		},
+		{
+			q:        "select ?a from ?g where {?s under /u/ ?p ?o};",
+			line:     1,
+			col:      35,
+			tkn:      lexer.ItemNodeType,
+			semantic: true,
+		},
+	}
+	for _, entry := range table {
+		err := p.Parse(NewLLk(entry.q, 1), &semantic.Statement{})
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Parser.Parse(%q) should have failed with a *ParseError; got %v", entry.q, err)
+			continue
+		}
+		if perr.Line != entry.line || perr.Column != entry.col || perr.Token.Type != entry.tkn {
+			t.Errorf("Parser.Parse(%q) failed at %s %d:%d; want %s %d:%d", entry.q, perr.Token.Type, perr.Line, perr.Column, entry.tkn, entry.line, entry.col)
+		}
+		if entry.expected != nil && !reflect.DeepEqual(perr.Expected, entry.expected) {
+			t.Errorf("Parser.Parse(%q) expected %v; want %v", entry.q, perr.Expected, entry.expected)
+		}
+		if got := perr.Err != nil; got != entry.semantic {
+			t.Errorf("Parser.Parse(%q) returned semantic error %v; want semantic %v", entry.q, perr.Err, entry.semantic)
+		}
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	script := `select ?a from ?b where {?s ?p ?o};
+select ?a ?b from ?g where {?s ?p ?o};
+insert data into ?g {/u<a> "p"@[] /_<foo};
+  create graph ?x;
+select ?s from ?g where {?s under /u/ ?p ?o};
+drop graph ?x;
+select`
+	stms, errs := p.ParseScript(script)
+	var types []semantic.StatementType
+	for _, st := range stms {
+		types = append(types, st.Type())
+	}
+	if want := []semantic.StatementType{semantic.Query, semantic.Create, semantic.Drop}; !reflect.DeepEqual(types, want) {
+		t.Errorf("Parser.ParseScript returned statements %v; want %v", types, want)
+	}
+	want := []struct {
+		line, col int
+	}{
+		{2, 11},
+		{3, 35},
+		{5, 35},
+		{7, 7},
+	}
+	if got := len(errs); got != len(want) {
+		t.Fatalf("Parser.ParseScript returned %d errors; want %d, got %v", got, len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Line != w.line || errs[i].Column != w.col {
+			t.Errorf("Parser.ParseScript returned error %d at %d:%d; want %d:%d; %v", i, errs[i].Line, errs[i].Column, w.line, w.col, errs[i])
+		}
+	}
+	if stms, errs := p.ParseScript(""); len(stms) != 0 || len(errs) != 0 {
+		t.Errorf("Parser.ParseScript should return nothing for empty scripts; got %v, %v", stms, errs)
+	}
+}
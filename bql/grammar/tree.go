@@ -0,0 +1,131 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grammar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// ParseTree is a node of the syntax tree built by the parser. Inner nodes
+// contain the grammar symbols derived, and leaves the consumed tokens. Symbols
+// derived into the empty string are inner nodes without children.
+type ParseTree struct {
+	Symbol   semantic.Symbol
+	Token    *lexer.Token
+	Children []*ParseTree
+}
+
+// IsToken returns true if the node is a leaf containing a token.
+func (t *ParseTree) IsToken() bool {
+	return t.Token != nil
+}
+
+// Tokens returns the tokens contained on the leaves of the tree in the order
+// they were consumed.
+func (t *ParseTree) Tokens() []*lexer.Token {
+	if t.IsToken() {
+		return []*lexer.Token{t.Token}
+	}
+	var res []*lexer.Token
+	for _, c := range t.Children {
+		res = append(res, c.Tokens()...)
+	}
+	return res
+}
+
+// Text returns the BQL text for the tree, with its tokens separated by single
+// spaces. Tools can rewrite statements by updating the tokens of the tree and
+// parsing its text again.
+func (t *ParseTree) Text() string {
+	var txt []string
+	for _, tkn := range t.Tokens() {
+		txt = append(txt, tkn.Text)
+	}
+	return strings.Join(txt, " ")
+}
+
+// Find returns all the nodes in the tree, including itself, for the provided
+// symbol in depth first order.
+func (t *ParseTree) Find(s semantic.Symbol) []*ParseTree {
+	if t.IsToken() {
+		return nil
+	}
+	var res []*ParseTree
+	if t.Symbol == s {
+		res = append(res, t)
+	}
+	for _, c := range t.Children {
+		res = append(res, c.Find(s)...)
+	}
+	return res
+}
+
+// String returns the tree as a nested list of symbols and tokens.
+func (t *ParseTree) String() string {
+	if t.IsToken() {
+		return fmt.Sprintf("%s(%q)", t.Token.Type, t.Token.Text)
+	}
+	var cs []string
+	for _, c := range t.Children {
+		cs = append(cs, c.String())
+	}
+	if len(cs) == 0 {
+		return fmt.Sprintf("(%s)", t.Symbol)
+	}
+	return fmt.Sprintf("(%s %s)", t.Symbol, strings.Join(cs, " "))
+}
+
+// ParseTree builds the syntax tree for the provided input. The semantic hooks
+// of the grammar are not run, hence the tree only reflects the syntax of the
+// input.
+func (p *Parser) ParseTree(llk *LLk) (*ParseTree, error) {
+	if err := p.checkLookAhead(llk); err != nil {
+		return nil, err
+	}
+	return p.derive(llk, "START")
+}
+
+// derive builds the tree for the derivation of the provided symbol.
+func (p *Parser) derive(llk *LLk, s semantic.Symbol) (*ParseTree, error) {
+	clause, first, err := p.choose(llk, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(clause.Elements) == 0 {
+		llk.pending = append(llk.pending, first...)
+		return &ParseTree{Symbol: s}, nil
+	}
+	t := &ParseTree{Symbol: s}
+	for _, elem := range clause.Elements {
+		if elem.isSymbol {
+			c, err := p.derive(llk, elem.Symbol())
+			if err != nil {
+				return nil, err
+			}
+			t.Children = append(t.Children, c)
+			continue
+		}
+		tkn := *llk.Current()
+		if !llk.Consume(elem.Token()) {
+			return nil, newParseError(llk, []lexer.TokenType{elem.Token()}, nil)
+		}
+		t.Children = append(t.Children, &ParseTree{Token: &tkn})
+	}
+	return t, nil
+}
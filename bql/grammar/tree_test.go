@@ -0,0 +1,106 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grammar
+
+import (
+	"testing"
+
+	"github.com/google/badwolf/bql/lexer"
+)
+
+func TestParseTree(t *testing.T) {
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid parser, %v", err)
+	}
+	table := []struct {
+		in       string
+		text     string
+		bindings int
+	}{
+		{`select ?a from ?b where {?a ?p ?o};`, `select ?a from ?b where { ?a ?p ?o } ;`, 5},
+		{`select ?s, ?o from ?g where {?s "knows"@[] ?o} limit "10"^^type:int64;`, `select ?s , ?o from ?g where { ?s "knows"@[] ?o } limit "10"^^type:int64 ;`, 5},
+		{`create graph ?a;`, `create graph ?a ;`, 1},
+	}
+	for _, entry := range table {
+		tree, err := p.ParseTree(NewLLk(entry.in, 1))
+		if err != nil {
+			t.Errorf("Parser.ParseTree: failed to parse %q; %v", entry.in, err)
+			continue
+		}
+		if tree.Symbol != "START" {
+			t.Errorf("Parser.ParseTree: wrong root symbol %q for %q", tree.Symbol, entry.in)
+		}
+		if got, want := tree.Text(), entry.text; got != want {
+			t.Errorf("ParseTree.Text: got %q, want %q", got, want)
+		}
+		n := 0
+		for _, tkn := range tree.Tokens() {
+			if tkn.Type == lexer.ItemBinding {
+				n++
+			}
+		}
+		if n != entry.bindings {
+			t.Errorf("ParseTree.Tokens: got %d bindings for %q, want %d", n, entry.in, entry.bindings)
+		}
+	}
+}
+
+func TestParseTreeRewrite(t *testing.T) {
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid parser, %v", err)
+	}
+	tree, err := p.ParseTree(NewLLk(`select ?a from ?b where {?a ?p ?o};`, 1))
+	if err != nil {
+		t.Fatalf("Parser.ParseTree: failed with %v", err)
+	}
+	for _, tkn := range tree.Tokens() {
+		if tkn.Type == lexer.ItemBinding && tkn.Text == "?b" {
+			tkn.Text = "?family"
+		}
+	}
+	want := `select ?a from ?family where { ?a ?p ?o } ;`
+	if got := tree.Text(); got != want {
+		t.Errorf("ParseTree.Text: got %q, want %q", got, want)
+	}
+	if _, err := p.ParseTree(NewLLk(tree.Text(), 1)); err != nil {
+		t.Errorf("Parser.ParseTree: failed to parse rewritten %q; %v", tree.Text(), err)
+	}
+	if got := len(tree.Find("CLAUSES")); got == 0 {
+		t.Errorf("ParseTree.Find: failed to find any CLAUSES symbol in %v", tree)
+	}
+}
+
+func TestParseTreeErrors(t *testing.T) {
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid parser, %v", err)
+	}
+	for _, in := range []string{
+		`select ?a from ?b;`,
+		`select from ?b where {?a ?p ?o};`,
+		`create graph;`,
+	} {
+		_, err := p.ParseTree(NewLLk(in, 1))
+		if err == nil {
+			t.Errorf("Parser.ParseTree: should have failed to parse %q", in)
+			continue
+		}
+		if _, ok := err.(*ParseError); !ok {
+			t.Errorf("Parser.ParseTree: expected a *ParseError for %q, got %T", in, err)
+		}
+	}
+}
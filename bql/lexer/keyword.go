@@ -0,0 +1,133 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// firstRegisteredKeyword contains the token type assigned to the first
+// keyword registered.
+const firstRegisteredKeyword = ItemOr + 1000
+
+var (
+	kwMu sync.RWMutex
+	// keywords maps the lower cased registered keywords to their token types.
+	keywords = map[string]TokenType{}
+	// keywordNames maps the token types of the registered keywords to them.
+	keywordNames = map[TokenType]string{}
+)
+
+// builtinKeywords maps the token types of the BQL keywords to them.
+var builtinKeywords = map[TokenType]string{
+	ItemQuery:       query,
+	ItemInsert:      insert,
+	ItemDelete:      delete,
+	ItemCreate:      create,
+	ItemDrop:        drop,
+	ItemAnalyze:     analyze,
+	ItemGraph:       graph,
+	ItemView:        view,
+	ItemData:        data,
+	ItemInto:        into,
+	ItemFrom:        from,
+	ItemWhere:       where,
+	ItemAs:          as,
+	ItemOf:          of,
+	ItemLatest:      latest,
+	ItemGranularity: granularity,
+	ItemBefore:      before,
+	ItemAfter:       after,
+	ItemBetween:     between,
+	ItemCount:       count,
+	ItemDistinct:    distinct,
+	ItemSum:         sum,
+	ItemGroup:       group,
+	ItemBy:          by,
+	ItemOrder:       order,
+	ItemAsc:         asc,
+	ItemDesc:        desc,
+	ItemHaving:      having,
+	ItemLimit:       limit,
+	ItemNow:         now,
+	ItemCast:        cast,
+	ItemStrLen:      strLen,
+	ItemWith:        with,
+	ItemHint:        hint,
+	ItemSubtract:    minusKeyword,
+	ItemPer:         per,
+	ItemNot:         not,
+	ItemAnd:         and,
+	ItemOr:          or,
+	ItemID:          id,
+	ItemType:        typeKeyword,
+	ItemAt:          atKeyword,
+	ItemUnder:       under,
+	ItemPrefix:      prefix,
+}
+
+// Keyword returns the lower cased text of the keyword lexed as the provided
+// token type, including the registered ones. Token types that are not lexed
+// out of a single keyword, such as ItemHintName, return false.
+func Keyword(tt TokenType) (string, bool) {
+	if kw, ok := builtinKeywords[tt]; ok {
+		return kw, true
+	}
+	if kw, ok := keywordName(tt); ok {
+		return strings.ToLower(kw), true
+	}
+	return "", false
+}
+
+// RegisterKeyword adds a new keyword to the BQL lexer and returns the token
+// type the lexer uses for it. Keywords are case insensitive and can only
+// contain letters. Registering a keyword already known by the lexer fails.
+// Registered keywords allow extending the BQL grammar with new statements.
+func RegisterKeyword(kw string) (TokenType, error) {
+	if kw == "" || strings.IndexFunc(kw, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+		return ItemError, fmt.Errorf("lexer.RegisterKeyword: invalid keyword %q; keywords can only contain letters", kw)
+	}
+	if tkn := <-New(kw, 1); tkn.Type != ItemError {
+		return ItemError, fmt.Errorf("lexer.RegisterKeyword: keyword %q is already lexed as %v", kw, tkn.Type)
+	}
+	kwMu.Lock()
+	defer kwMu.Unlock()
+	if _, ok := keywords[strings.ToLower(kw)]; ok {
+		return ItemError, fmt.Errorf("lexer.RegisterKeyword: keyword %q is already registered", kw)
+	}
+	tt := firstRegisteredKeyword + TokenType(len(keywords))
+	keywords[strings.ToLower(kw)] = tt
+	keywordNames[tt] = kw
+	return tt, nil
+}
+
+// registeredKeyword returns the token type for the provided registered keyword.
+func registeredKeyword(kw string) (TokenType, bool) {
+	kwMu.RLock()
+	defer kwMu.RUnlock()
+	tt, ok := keywords[strings.ToLower(kw)]
+	return tt, ok
+}
+
+// keywordName returns the registered keyword for the provided token type.
+func keywordName(tt TokenType) (string, bool) {
+	kwMu.RLock()
+	defer kwMu.RUnlock()
+	kw, ok := keywordNames[tt]
+	return kw, ok
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexer
+
+import "testing"
+
+// itemSink is registered once, since registered keywords cannot be removed.
+var itemSink, errSink = RegisterKeyword("sink")
+
+func TestRegisterKeyword(t *testing.T) {
+	if errSink != nil {
+		t.Fatalf("RegisterKeyword(%q) failed with error %v", "sink", errSink)
+	}
+	if got, want := itemSink.String(), "SINK"; got != want {
+		t.Errorf("TokenType.String: got %q, want %q", got, want)
+	}
+	var got []TokenType
+	for tkn := range New("select SINK ?a;", 0) {
+		got = append(got, tkn.Type)
+	}
+	want := []TokenType{ItemQuery, itemSink, ItemBinding, ItemSemicolon, ItemEOF}
+	if len(got) != len(want) {
+		t.Fatalf("lexer.New returned %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("lexer.New returned %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestRegisterKeywordErrors(t *testing.T) {
+	for _, kw := range []string{"", "select", "Ordered", "sink", "bad-keyword", "a1"} {
+		if _, err := RegisterKeyword(kw); err == nil {
+			t.Errorf("RegisterKeyword(%q) should have failed", kw)
+		}
+	}
+}
+
+func TestKeyword(t *testing.T) {
+	table := []struct {
+		tt   TokenType
+		want string
+		ok   bool
+	}{
+		{ItemQuery, "select", true},
+		{ItemSubtract, "minus", true},
+		{ItemType, "type", true},
+		{itemSink, "sink", true},
+		{ItemHintName, "", false},
+		{ItemBinding, "", false},
+	}
+	for _, entry := range table {
+		got, ok := Keyword(entry.tt)
+		if got != entry.want || ok != entry.ok {
+			t.Errorf("Keyword(%v) returned (%q, %v); want (%q, %v)", entry.tt, got, ok, entry.want, entry.ok)
+		}
+		if !ok {
+			continue
+		}
+		if tkn := <-New(got, 1); tkn.Type != entry.tt {
+			t.Errorf("Keyword(%v) returned %q, which is lexed as %v", entry.tt, got, tkn.Type)
+		}
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/google/badwolf/triple/literal"
 )
 
 // TokenType list all the possible tokens returned by a lexer.
@@ -44,8 +46,13 @@ const (
 	ItemCreate
 	// ItemDrop represent the destruction of a graph in BQL.
 	ItemDrop
+	// ItemAnalyze represents the collection of the statistics of a graph in
+	// BQL.
+	ItemAnalyze
 	// ItemGraph represent the graph to be created of destroyed in BQL.
 	ItemGraph
+	// ItemView represents the view to be created in BQL.
+	ItemView
 	// ItemData represents the data keyword in BQL.
 	ItemData
 	// ItemInto represents the into keyword in BQL.
@@ -56,12 +63,30 @@ const (
 	ItemWhere
 	// ItemAs represents the as keyword in BQL.
 	ItemAs
+	// ItemOf represents the of keyword used to query the state of the graphs
+	// as of a given time in BQL.
+	ItemOf
+	// ItemLatest represents the latest keyword used to only match the most
+	// recent temporal predicates of a graph clause in BQL.
+	ItemLatest
+	// ItemGranularity represents the granularity keyword used to match the
+	// time anchors within the same period as equal in BQL.
+	ItemGranularity
 	// ItemType represents keyword type in BQL.
 	ItemType
 	// ItemID represents id keyword in BQL.
 	ItemID
 	// ItemAt represents at keyword in BQL.
 	ItemAt
+	// ItemUnder represents the under keyword used to filter subjects by node
+	// type in BQL.
+	ItemUnder
+	// ItemPrefix represents the prefix keyword used to declare namespaces in
+	// BQL.
+	ItemPrefix
+	// ItemPrefixName represents the name of a declared namespace, such as
+	// foaf:, in BQL.
+	ItemPrefixName
 	// ItemBefore represents the before keyword in BQL.
 	ItemBefore
 	// ItemAfter represents the after keyword in BQL.
@@ -88,23 +113,54 @@ const (
 	ItemDesc
 	// ItemLimit represetnts the limit clause in BQL.
 	ItemLimit
+	// ItemNow represents the now keyword used in time bounds in BQL.
+	ItemNow
+	// ItemPer represents the per keyword in limit per group clauses in BQL.
+	ItemPer
+	// ItemCast represents the cast function in BQL.
+	ItemCast
+	// ItemStrLen represents the strlen function in BQL.
+	ItemStrLen
+	// ItemSubtract represents the minus keyword used to subtract graph
+	// patterns in BQL.
+	ItemSubtract
+	// ItemWith represents the with keyword that introduces query hints in BQL.
+	ItemWith
+	// ItemHint represents the hint keyword in BQL.
+	ItemHint
+	// ItemHintName represents the name of a query hint, such as ordered,
+	// noprefetch, maxrows, or sortbuffer, in BQL.
+	ItemHintName
 
 	// ItemBinding respresents a variable binding in BQL.
 	ItemBinding
 
 	// ItemNode respresents a BadWolf node in BQL.
 	ItemNode
+	// ItemNodeType represents a BadWolf node type, such as /organization,
+	// in BQL.
+	ItemNodeType
 	// ItemLiteral represents a BadWolf literal in BQL.
 	ItemLiteral
 	// ItemPredicate represents a BadWolf predicates in BQL.
 	ItemPredicate
 	// ItemPredicateBound represents a BadWolf predicate bound in BQL.
 	ItemPredicateBound
+	// ItemLiteralType represents a BadWolf literal type, such as type:int64,
+	// in BQL.
+	ItemLiteralType
+	// ItemString represents a quoted string with neither time anchor nor type,
+	// such as the graph name "/prod/users", in BQL.
+	ItemString
 
 	// ItemLBracket representes the left opening bracket token in BQL.
 	ItemLBracket
 	// ItemRBracket representes the right opening bracket token in BQL.
 	ItemRBracket
+	// ItemLTriple represents the << opening of an embedded triple in BQL.
+	ItemLTriple
+	// ItemRTriple represents the >> closing of an embedded triple in BQL.
+	ItemRTriple
 	// ItemLPar representes the left opening parentesis token in BQL.
 	ItemLPar
 	// ItemRPar representes the right closing parentesis token in BQL.
@@ -121,6 +177,12 @@ const (
 	ItemGT
 	// ItemEQ represents = in BQL.
 	ItemEQ
+	// ItemPlus represents + in BQL.
+	ItemPlus
+	// ItemMinus represents - in BQL.
+	ItemMinus
+	// ItemStar represents * in BQL.
+	ItemStar
 	// ItemNot represents keyword not in BQL.
 	ItemNot
 	// ItemAnd represents keyword and in BQL.
@@ -145,8 +207,12 @@ func (tt TokenType) String() string {
 		return "CREATE"
 	case ItemDrop:
 		return "DROP"
+	case ItemAnalyze:
+		return "ANALYZE"
 	case ItemGraph:
 		return "Graph"
+	case ItemView:
+		return "VIEW"
 	case ItemData:
 		return "DATA"
 	case ItemInto:
@@ -173,6 +239,22 @@ func (tt TokenType) String() string {
 		return "DESC"
 	case ItemLimit:
 		return "LIMIT"
+	case ItemNow:
+		return "NOW"
+	case ItemPer:
+		return "PER"
+	case ItemCast:
+		return "CAST"
+	case ItemStrLen:
+		return "STRLEN"
+	case ItemSubtract:
+		return "SUBTRACT"
+	case ItemWith:
+		return "WITH"
+	case ItemHint:
+		return "HINT"
+	case ItemHintName:
+		return "HINT_NAME"
 	case ItemAs:
 		return "AS"
 	case ItemBefore:
@@ -185,16 +267,26 @@ func (tt TokenType) String() string {
 		return "BINDING"
 	case ItemNode:
 		return "NODE"
+	case ItemNodeType:
+		return "NODE_TYPE"
 	case ItemLiteral:
 		return "LITERAL"
 	case ItemPredicate:
 		return "PREDICATE"
 	case ItemPredicateBound:
 		return "PREDICATE_BOUND"
+	case ItemLiteralType:
+		return "LITERAL_TYPE"
+	case ItemString:
+		return "STRING"
 	case ItemLBracket:
 		return "LEFT_BRACKET"
 	case ItemRBracket:
 		return "RIGHT_BRACKET"
+	case ItemLTriple:
+		return "LEFT_TRIPLE"
+	case ItemRTriple:
+		return "RIGHT_TRIPLE"
 	case ItemLPar:
 		return "LEFT_PARENT"
 	case ItemRPar:
@@ -211,6 +303,12 @@ func (tt TokenType) String() string {
 		return "GT"
 	case ItemEQ:
 		return "EQ"
+	case ItemPlus:
+		return "PLUS"
+	case ItemMinus:
+		return "MINUS"
+	case ItemStar:
+		return "STAR"
 	case ItemNot:
 		return "NOT"
 	case ItemAnd:
@@ -223,9 +321,24 @@ func (tt TokenType) String() string {
 		return "TYPE"
 	case ItemAt:
 		return "AT"
+	case ItemUnder:
+		return "UNDER"
+	case ItemOf:
+		return "OF"
+	case ItemLatest:
+		return "LATEST"
+	case ItemGranularity:
+		return "GRANULARITY"
+	case ItemPrefix:
+		return "PREFIX"
+	case ItemPrefixName:
+		return "PREFIX_NAME"
 	case ItemDistinct:
 		return "DISTINCT"
 	default:
+		if kw, ok := keywordName(tt); ok {
+			return strings.ToUpper(kw)
+		}
 		return "UNKNOWN"
 	}
 }
@@ -248,6 +361,9 @@ const (
 	lt             = rune('<')
 	gt             = rune('>')
 	eq             = rune('=')
+	plus           = rune('+')
+	minus          = rune('-')
+	star           = rune('*')
 	quote          = rune('"')
 	hat            = rune('^')
 	at             = rune('@')
@@ -257,12 +373,17 @@ const (
 	delete         = "delete"
 	create         = "create"
 	drop           = "drop"
+	analyze        = "analyze"
 	graph          = "graph"
+	view           = "view"
 	data           = "data"
 	into           = "into"
 	from           = "from"
 	where          = "where"
 	as             = "as"
+	of             = "of"
+	latest         = "latest"
+	granularity    = "granularity"
 	before         = "before"
 	after          = "after"
 	between        = "between"
@@ -276,26 +397,39 @@ const (
 	asc            = "asc"
 	desc           = "desc"
 	limit          = "limit"
+	now            = "now"
+	per            = "per"
+	cast           = "cast"
+	minusKeyword   = "minus"
+	strLen         = "strlen"
+	with           = "with"
+	hint           = "hint"
 	not            = "not"
 	and            = "and"
 	or             = "or"
 	id             = "id"
 	typeKeyword    = "type"
 	atKeyword      = "at"
+	under          = "under"
+	prefix         = "prefix"
 	anchor         = "\"@["
 	literalType    = "\"^^type:"
-	literalBool    = "bool"
-	literalInt     = "int64"
-	literalFloat   = "float64"
-	literalText    = "text"
-	literalBlob    = "blob"
+	typePrefix     = "type:"
 )
 
+// hintNames contains the names of the supported query hints.
+var hintNames = []string{"ordered", "noprefetch", "maxrows", "sortbuffer", "dedup"}
+
 // Token contains the type and text collected around the captured token.
 type Token struct {
 	Type         TokenType
 	Text         string
 	ErrorMessage string
+
+	// Line and Col contain the 1-based line and column, in runes, where the
+	// token starts in the input.
+	Line int
+	Col  int
 }
 
 // stateFn represents the state of the scanner  as a function that returns
@@ -306,6 +440,8 @@ type stateFn func(*lexer) stateFn
 type lexer struct {
 	input    string     // the string being scanned.
 	start    int        // start position of this item.
+	sLine    int        // line number where this item starts.
+	sCol     int        // column number where this item starts.
 	pos      int        // current position in the input.
 	width    int        // width of last rune read from input.
 	line     int        // current line number for error reporting.
@@ -313,13 +449,36 @@ type lexer struct {
 	col      int        // current column number for error reporting.
 	lastCol  int        // last column number for error reporting.
 	tokens   chan Token // channel of scanned items.
+
+	// caseSensitive is true if keywords are only recognized in lower case.
+	caseSensitive bool
+}
+
+// Options contains the settings of a lexer.
+type Options struct {
+	// CaseSensitiveKeywords makes the lexer only recognize keywords written in
+	// lower case. By default keywords are case insensitive, hence SELECT,
+	// Select, and select are all lexed as ItemQuery. Bindings, node IDs, and
+	// literals always preserve their case regardless of this setting.
+	CaseSensitiveKeywords bool
 }
 
 // lex creates a new lexer for the givne input
 func lex(input string, capacity int) (*lexer, <-chan Token) {
+	return lexAt(input, capacity, 1, 1, Options{})
+}
+
+// lexAt creates a new lexer for the given input, which starts at the provided
+// 1-based line and column of the original text.
+func lexAt(input string, capacity, line, col int, opts Options) (*lexer, <-chan Token) {
 	l := &lexer{
-		input:  input,
-		tokens: make(chan Token, capacity),
+		input:         input,
+		line:          line - 1,
+		sLine:         line - 1,
+		col:           col - 1,
+		sCol:          col - 1,
+		tokens:        make(chan Token, capacity),
+		caseSensitive: opts.CaseSensitiveKeywords,
 	}
 	go l.run() // Concurrently run state machine.
 	return l, l.tokens
@@ -328,10 +487,16 @@ func lex(input string, capacity int) (*lexer, <-chan Token) {
 // New return a new read only channel with the tokens found in the provided
 // input string.
 func New(input string, capacity int) <-chan Token {
+	return NewWithOptions(input, capacity, Options{})
+}
+
+// NewWithOptions return a new read only channel with the tokens found in the
+// provided input string, scanned using the provided options.
+func NewWithOptions(input string, capacity int, opts Options) <-chan Token {
 	if capacity < 0 {
 		capacity = 0
 	}
-	_, c := lex(input, capacity)
+	_, c := lexAt(input, capacity, 1, 1, opts)
 	return c
 }
 
@@ -374,6 +539,12 @@ func lexToken(l *lexer) stateFn {
 		if state := isSingleSymboToken(l, ItemComma, comma); state != nil {
 			return state
 		}
+		if state := isDoubleSymbolToken(l, ItemLTriple, lt); state != nil {
+			return state
+		}
+		if state := isDoubleSymbolToken(l, ItemRTriple, gt); state != nil {
+			return state
+		}
 		if state := isSingleSymboToken(l, ItemLT, lt); state != nil {
 			return state
 		}
@@ -383,6 +554,15 @@ func lexToken(l *lexer) stateFn {
 		if state := isSingleSymboToken(l, ItemEQ, eq); state != nil {
 			return state
 		}
+		if state := isSingleSymboToken(l, ItemPlus, plus); state != nil {
+			return state
+		}
+		if state := isSingleSymboToken(l, ItemMinus, minus); state != nil {
+			return state
+		}
+		if state := isSingleSymboToken(l, ItemStar, star); state != nil {
+			return state
+		}
 		{
 			r := l.next()
 			if unicode.IsSpace(r) {
@@ -408,6 +588,18 @@ func isSingleSymboToken(l *lexer, tt TokenType, symbol rune) stateFn {
 	return nil
 }
 
+// isDoubleSymbolToken check if a symbol repeated twice should be lexed.
+func isDoubleSymbolToken(l *lexer, tt TokenType, symbol rune) stateFn {
+	ds := string([]rune{symbol, symbol})
+	if strings.HasPrefix(l.input[l.pos:], ds) {
+		l.next()
+		l.next()
+		l.emit(tt)
+		return lexSpace // Next state.
+	}
+	return nil
+}
+
 // lexBinding lexes a binding variable.
 func lexBinding(l *lexer) stateFn {
 	for {
@@ -432,6 +624,15 @@ func lexSpace(l *lexer) stateFn {
 	return lexToken
 }
 
+// isKeyword returns true if the provided input spells the keyword. Unless the
+// lexer is case sensitive, the case of the input is ignored.
+func (l *lexer) isKeyword(input, kw string) bool {
+	if l.caseSensitive {
+		return input == kw
+	}
+	return strings.EqualFold(input, kw)
+}
+
 // lexKeywork lexes the BQL keywords.
 func lexKeyword(l *lexer) stateFn {
 	input := l.input[l.pos:]
@@ -441,126 +642,198 @@ func lexKeyword(l *lexer) stateFn {
 	if idx := strings.IndexFunc(input, f); idx >= 0 {
 		input = input[:idx]
 	}
-	if strings.EqualFold(input, query) {
+	if n := prefixNameLen(l.input[l.pos:]); n > 0 && !l.isKeyword(l.input[l.pos:l.pos+n-1], typeKeyword) {
+		return lexPrefixed
+	}
+	if l.isKeyword(input, query) {
 		consumeKeyword(l, ItemQuery)
 		return lexSpace
 	}
-	if strings.EqualFold(input, insert) {
+	if l.isKeyword(input, insert) {
 		consumeKeyword(l, ItemInsert)
 		return lexSpace
 	}
-	if strings.EqualFold(input, delete) {
+	if l.isKeyword(input, delete) {
 		consumeKeyword(l, ItemDelete)
 		return lexSpace
 	}
-	if strings.EqualFold(input, create) {
+	if l.isKeyword(input, create) {
 		consumeKeyword(l, ItemCreate)
 		return lexSpace
 	}
-	if strings.EqualFold(input, drop) {
+	if l.isKeyword(input, drop) {
 		consumeKeyword(l, ItemDrop)
 		return lexSpace
 	}
-	if strings.EqualFold(input, graph) {
+	if l.isKeyword(input, analyze) {
+		consumeKeyword(l, ItemAnalyze)
+		return lexSpace
+	}
+	if l.isKeyword(input, graph) {
 		consumeKeyword(l, ItemGraph)
 		return lexSpace
 	}
-	if strings.EqualFold(input, data) {
+	if l.isKeyword(input, view) {
+		consumeKeyword(l, ItemView)
+		return lexSpace
+	}
+	if l.isKeyword(input, data) {
 		consumeKeyword(l, ItemData)
 		return lexSpace
 	}
-	if strings.EqualFold(input, into) {
+	if l.isKeyword(input, into) {
 		consumeKeyword(l, ItemInto)
 		return lexSpace
 	}
-	if strings.EqualFold(input, from) {
+	if l.isKeyword(input, from) {
 		consumeKeyword(l, ItemFrom)
 		return lexSpace
 	}
-	if strings.EqualFold(input, where) {
+	if l.isKeyword(input, where) {
 		consumeKeyword(l, ItemWhere)
 		return lexSpace
 	}
-	if strings.EqualFold(input, as) {
+	if l.isKeyword(input, as) {
 		consumeKeyword(l, ItemAs)
 		return lexSpace
 	}
-	if strings.EqualFold(input, before) {
+	if l.isKeyword(input, before) {
 		consumeKeyword(l, ItemBefore)
 		return lexSpace
 	}
-	if strings.EqualFold(input, after) {
+	if l.isKeyword(input, after) {
 		consumeKeyword(l, ItemAfter)
 		return lexSpace
 	}
-	if strings.EqualFold(input, between) {
+	if l.isKeyword(input, between) {
 		consumeKeyword(l, ItemBetween)
 		return lexSpace
 	}
-	if strings.EqualFold(input, count) {
+	if l.isKeyword(input, count) {
 		consumeKeyword(l, ItemCount)
 		return lexSpace
 	}
-	if strings.EqualFold(input, distinct) {
+	if l.isKeyword(input, distinct) {
 		consumeKeyword(l, ItemDistinct)
 		return lexSpace
 	}
-	if strings.EqualFold(input, sum) {
+	if l.isKeyword(input, sum) {
 		consumeKeyword(l, ItemSum)
 		return lexSpace
 	}
-	if strings.EqualFold(input, group) {
+	if l.isKeyword(input, group) {
 		consumeKeyword(l, ItemGroup)
 		return lexSpace
 	}
-	if strings.EqualFold(input, by) {
+	if l.isKeyword(input, by) {
 		consumeKeyword(l, ItemBy)
 		return lexSpace
 	}
-	if strings.EqualFold(input, order) {
+	if l.isKeyword(input, order) {
 		consumeKeyword(l, ItemOrder)
 		return lexSpace
 	}
-	if strings.EqualFold(input, asc) {
+	if l.isKeyword(input, asc) {
 		consumeKeyword(l, ItemAsc)
 		return lexSpace
 	}
-	if strings.EqualFold(input, desc) {
+	if l.isKeyword(input, desc) {
 		consumeKeyword(l, ItemDesc)
 		return lexSpace
 	}
-	if strings.EqualFold(input, having) {
+	if l.isKeyword(input, having) {
 		consumeKeyword(l, ItemHaving)
 		return lexSpace
 	}
-	if strings.EqualFold(input, limit) {
+	if l.isKeyword(input, limit) {
 		consumeKeyword(l, ItemLimit)
 		return lexSpace
 	}
-	if strings.EqualFold(input, not) {
+	if l.isKeyword(input, now) {
+		consumeKeyword(l, ItemNow)
+		return lexSpace
+	}
+	if l.isKeyword(input, cast) {
+		consumeKeyword(l, ItemCast)
+		return lexSpace
+	}
+	if l.isKeyword(input, strLen) {
+		consumeKeyword(l, ItemStrLen)
+		return lexSpace
+	}
+	if l.isKeyword(input, with) {
+		consumeKeyword(l, ItemWith)
+		return lexSpace
+	}
+	if l.isKeyword(input, hint) {
+		consumeKeyword(l, ItemHint)
+		return lexSpace
+	}
+	for _, h := range hintNames {
+		if l.isKeyword(input, h) {
+			consumeKeyword(l, ItemHintName)
+			return lexSpace
+		}
+	}
+	if l.isKeyword(input, minusKeyword) {
+		consumeKeyword(l, ItemSubtract)
+		return lexSpace
+	}
+	if l.isKeyword(input, per) {
+		consumeKeyword(l, ItemPer)
+		return lexSpace
+	}
+	if l.isKeyword(input, not) {
 		consumeKeyword(l, ItemNot)
 		return lexSpace
 	}
-	if strings.EqualFold(input, and) {
+	if l.isKeyword(input, and) {
 		consumeKeyword(l, ItemAnd)
 		return lexSpace
 	}
-	if strings.EqualFold(input, or) {
+	if l.isKeyword(input, or) {
 		consumeKeyword(l, ItemOr)
 		return lexSpace
 	}
-	if strings.EqualFold(input, id) {
+	if l.isKeyword(input, id) {
 		consumeKeyword(l, ItemID)
 		return lexSpace
 	}
-	if strings.EqualFold(input, typeKeyword) {
+	if l.isKeyword(input, typeKeyword) {
+		if rest := l.input[l.pos+len(input):]; strings.HasPrefix(rest, string(colon)) {
+			return lexLiteralType
+		}
 		consumeKeyword(l, ItemType)
 		return lexSpace
 	}
-	if strings.EqualFold(input, atKeyword) {
+	if l.isKeyword(input, atKeyword) {
 		consumeKeyword(l, ItemAt)
 		return lexSpace
 	}
+	if l.isKeyword(input, under) {
+		consumeKeyword(l, ItemUnder)
+		return lexSpace
+	}
+	if l.isKeyword(input, of) {
+		consumeKeyword(l, ItemOf)
+		return lexSpace
+	}
+	if l.isKeyword(input, latest) {
+		consumeKeyword(l, ItemLatest)
+		return lexSpace
+	}
+	if l.isKeyword(input, granularity) {
+		consumeKeyword(l, ItemGranularity)
+		return lexSpace
+	}
+	if l.isKeyword(input, prefix) {
+		consumeKeyword(l, ItemPrefix)
+		return lexSpace
+	}
+	if tt, ok := registeredKeyword(input); ok && (!l.caseSensitive || input == strings.ToLower(input)) {
+		consumeKeyword(l, tt)
+		return lexSpace
+	}
 	for {
 		r := l.next()
 		if unicode.IsSpace(r) || r == eof {
@@ -577,17 +850,29 @@ func lexNode(l *lexer) stateFn {
 	for done := false; !done; {
 		switch r := l.next(); r {
 		case backSlash:
-			if nr := l.peek(); nr == lt {
+			// Escaped characters, such as \> in IDs, never delimit the node.
+			if l.peek() != eof {
 				l.next()
-				continue
 			}
 		case eof:
+			if !ltID {
+				// A bare node type, such as /organization.
+				l.emit(ItemNodeType)
+				return lexSpace
+			}
 			l.emitError("node is not properly terminated; missing final > delimiter")
 			return nil
 		case lt:
 			ltID = true
 		case gt:
 			done = true
+		default:
+			if !ltID && unicode.IsSpace(r) {
+				// A bare node type, such as /organization.
+				l.backup()
+				l.emit(ItemNodeType)
+				return lexSpace
+			}
 		}
 	}
 	if !ltID {
@@ -598,19 +883,71 @@ func lexNode(l *lexer) stateFn {
 	return lexSpace
 }
 
-// lexPredicateOrLiteral tries to lex a predicate or a literal out of the input.
+// prefixNameLen returns the length of the namespace prefix, including its
+// trailing colon, the input starts with. It returns -1 if the input does not
+// start with a prefix name made of letters, digits, and underscores.
+func prefixNameLen(input string) int {
+	for i, r := range input {
+		if r == colon && i > 0 {
+			return i + 1
+		}
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			return -1
+		}
+	}
+	return -1
+}
+
+// lexPrefixed lexes a prefix name, such as foaf:, or the nodes and node types
+// that use it, such as foaf:<joe> or foaf:person.
+func lexPrefixed(l *lexer) stateFn {
+	for l.next() != colon {
+	}
+	if r := l.peek(); unicode.IsSpace(r) || r == eof {
+		l.emit(ItemPrefixName)
+		return lexSpace
+	}
+	return lexNode
+}
+
+// lexPredicateOrLiteral tries to lex a predicate, a literal, or a plain string
+// out of the input. The decision is made by looking at what follows the
+// closing quote of the current token, so literals followed by predicates later
+// in the input are not mistaken for predicates.
 func lexPredicateOrLiteral(l *lexer) stateFn {
 	text := l.input[l.pos:]
-	if strings.Contains(text, anchor) {
+	idx := closingQuote(text)
+	if idx >= 0 {
+		text = text[idx:]
+	}
+	if strings.HasPrefix(text, anchor) {
 		return lexPredicate
 	}
-	if strings.Contains(text, literalType) {
+	if strings.HasPrefix(text, literalType) {
 		return lexLiteral
 	}
+	// Quotes followed by malformed anchors or types are not plain strings.
+	if idx >= 0 && !strings.HasPrefix(text, "\"@") && !strings.HasPrefix(text, "\"^") {
+		return lexString
+	}
 	l.emitError("failed to parse predicate or literal for opening \" delimiter")
 	return nil
 }
 
+// closingQuote returns the index of the first unescaped quote after the
+// opening one, or -1 if none could be found.
+func closingQuote(text string) int {
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
 // lexPredicate lexes a predicicate of out of the input.
 func lexPredicate(l *lexer) stateFn {
 	l.next()
@@ -662,6 +999,24 @@ func lexPredicate(l *lexer) stateFn {
 	return lexSpace
 }
 
+// lexString lexes a quoted string with neither time anchor nor type out of
+// the input.
+func lexString(l *lexer) stateFn {
+	l.next()
+	for {
+		switch r := l.next(); r {
+		case backSlash:
+			l.next()
+		case quote:
+			l.emit(ItemString)
+			return lexSpace
+		case eof:
+			l.emitError("strings need to be properly terminated; missing \"")
+			return nil
+		}
+	}
+}
+
 // lexPredicate lexes a literal of out of the input.
 func lexLiteral(l *lexer) stateFn {
 	l.next()
@@ -686,16 +1041,13 @@ func lexLiteral(l *lexer) stateFn {
 				}
 				literalT += string(r)
 			}
-			literalT = strings.ToLower(literalT)
-			switch literalT {
-			case literalBool, literalInt, literalFloat, literalText, literalBlob:
-				l.backup()
-				l.emit(ItemLiteral)
-				done = true
-			default:
+			if !isLiteralType(literalT) {
 				l.emitError("invalid literal type " + literalT)
 				return nil
 			}
+			l.backup()
+			l.emit(ItemLiteral)
+			done = true
 		case eof:
 			l.emitError("literals needs to be properly terminated; missing \" and type")
 			return nil
@@ -704,6 +1056,36 @@ func lexLiteral(l *lexer) stateFn {
 	return lexSpace
 }
 
+// isLiteralType returns true if the provided name is a valid literal type,
+// either a built-in or a registered one.
+func isLiteralType(t string) bool {
+	_, ok := literal.TypeByName(strings.ToLower(t))
+	return ok
+}
+
+// lexLiteralType lexes a literal type, such as type:int64, out of the input.
+func lexLiteralType(l *lexer) stateFn {
+	if !l.consume(typePrefix) {
+		l.emitError("literal types require the type: prefix")
+		return nil
+	}
+	literalT := ""
+	for {
+		r := l.next()
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r)) || r == eof {
+			l.backup()
+			break
+		}
+		literalT += string(r)
+	}
+	if !isLiteralType(literalT) {
+		l.emitError("invalid literal type " + literalT)
+		return nil
+	}
+	l.emit(ItemLiteralType)
+	return lexSpace
+}
+
 // consumeKeyword consume and emits a valid token
 func consumeKeyword(l *lexer, t TokenType) {
 	for {
@@ -728,8 +1110,10 @@ func (l *lexer) emit(t TokenType) {
 	l.tokens <- Token{
 		Type: t,
 		Text: l.input[l.start:l.pos],
+		Line: l.sLine + 1,
+		Col:  l.sCol + 1,
 	}
-	l.start = l.pos
+	l.ignore()
 }
 
 // emitError passes and error to the client with proper error messaging.
@@ -738,13 +1122,16 @@ func (l *lexer) emitError(msg string) {
 		Type:         ItemError,
 		Text:         l.input[l.start:l.pos],
 		ErrorMessage: fmt.Sprintf("[lexer:%d:%d] %s", l.line, l.col, msg),
+		Line:         l.sLine + 1,
+		Col:          l.sCol + 1,
 	}
-	l.start = l.pos
+	l.ignore()
 }
 
 // ignore skips over the pending input before this point.
 func (l *lexer) ignore() {
 	l.start = l.pos
+	l.sLine, l.sCol = l.line, l.col
 }
 
 // backup steps back one rune. Can be called only once per call of next.
@@ -757,6 +1144,7 @@ func (l *lexer) backup() {
 func (l *lexer) next() rune {
 	if l.pos >= len(l.input) {
 		l.width = 0
+		l.lastCol, l.lastLine = l.col, l.line
 		return eof
 	}
 	var r rune
@@ -24,7 +24,7 @@ func TestIndividualTokens(t *testing.T) {
 		{"",
 			[]Token{
 				{Type: ItemEOF}}},
-		{"{}().;,<>=",
+		{"{}().;,<>=+-*",
 			[]Token{
 				{Type: ItemLBracket, Text: "{"},
 				{Type: ItemRBracket, Text: "}"},
@@ -36,6 +36,9 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemLT, Text: "<"},
 				{Type: ItemGT, Text: ">"},
 				{Type: ItemEQ, Text: "="},
+				{Type: ItemPlus, Text: "+"},
+				{Type: ItemMinus, Text: "-"},
+				{Type: ItemStar, Text: "*"},
 				{Type: ItemEOF}}},
 		{"?foo ?bar",
 			[]Token{
@@ -44,7 +47,8 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemEOF}}},
 		{`SeLeCt FrOm WhErE As BeFoRe AfTeR BeTwEeN CoUnT SuM GrOuP bY HaViNg LiMiT
 		  OrDeR AsC DeSc NoT AnD Or Id TyPe At DiStInCt InSeRt DeLeTe DaTa InTo
-			CrEaTe DrOp GrApH`,
+			CrEaTe DrOp GrApH NoW PeR CaSt MiNuS StRlEn WiTh HiNt OrDeReD
+			NoPrEfEtCh MaXrOwS SoRtBuFfEr AnAlYzE DeDuP ViEw`,
 			[]Token{
 				{Type: ItemQuery, Text: "SeLeCt"},
 				{Type: ItemFrom, Text: "FrOm"},
@@ -76,6 +80,20 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemCreate, Text: "CrEaTe"},
 				{Type: ItemDrop, Text: "DrOp"},
 				{Type: ItemGraph, Text: "GrApH"},
+				{Type: ItemNow, Text: "NoW"},
+				{Type: ItemPer, Text: "PeR"},
+				{Type: ItemCast, Text: "CaSt"},
+				{Type: ItemSubtract, Text: "MiNuS"},
+				{Type: ItemStrLen, Text: "StRlEn"},
+				{Type: ItemWith, Text: "WiTh"},
+				{Type: ItemHint, Text: "HiNt"},
+				{Type: ItemHintName, Text: "OrDeReD"},
+				{Type: ItemHintName, Text: "NoPrEfEtCh"},
+				{Type: ItemHintName, Text: "MaXrOwS"},
+				{Type: ItemHintName, Text: "SoRtBuFfEr"},
+				{Type: ItemAnalyze, Text: "AnAlYzE"},
+				{Type: ItemHintName, Text: "DeDuP"},
+				{Type: ItemView, Text: "ViEw"},
 				{Type: ItemEOF}}},
 		{"/_<foo>/_<bar>",
 			[]Token{
@@ -88,6 +106,11 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemError, Text: "/_\\<bar>",
 					ErrorMessage: "[lexer:0:15] node should start ID section with a < delimiter"},
 				{Type: ItemEOF}}},
+		{`/_<a\>b\\> /_<c>`,
+			[]Token{
+				{Type: ItemNode, Text: `/_<a\>b\\>`},
+				{Type: ItemNode, Text: "/_<c>"},
+				{Type: ItemEOF}}},
 		{"/_foo>",
 			[]Token{
 				{Type: ItemError, Text: "/_foo>",
@@ -98,6 +121,40 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemError, Text: "/_<foo",
 					ErrorMessage: "[lexer:0:6] node is not properly terminated; missing final > delimiter"},
 				{Type: ItemEOF}}},
+		{"PrEfIx foaf: /foaf foaf:<joe> foaf:person<mary> foaf:person",
+			[]Token{
+				{Type: ItemPrefix, Text: "PrEfIx"},
+				{Type: ItemPrefixName, Text: "foaf:"},
+				{Type: ItemNodeType, Text: "/foaf"},
+				{Type: ItemNode, Text: "foaf:<joe>"},
+				{Type: ItemNode, Text: "foaf:person<mary>"},
+				{Type: ItemNodeType, Text: "foaf:person"},
+				{Type: ItemEOF}}},
+		{"?s UnDeR /organization/team /u",
+			[]Token{
+				{Type: ItemBinding, Text: "?s"},
+				{Type: ItemUnder, Text: "UnDeR"},
+				{Type: ItemNodeType, Text: "/organization/team"},
+				{Type: ItemNodeType, Text: "/u"},
+				{Type: ItemEOF}}},
+		{`As Of "2015-06-01T00:00:00Z"`,
+			[]Token{
+				{Type: ItemAs, Text: "As"},
+				{Type: ItemOf, Text: "Of"},
+				{Type: ItemString, Text: `"2015-06-01T00:00:00Z"`},
+				{Type: ItemEOF}}},
+		{`?s "lives_in"@[,] LaTeSt ?o`,
+			[]Token{
+				{Type: ItemBinding, Text: "?s"},
+				{Type: ItemPredicateBound, Text: `"lives_in"@[,]`},
+				{Type: ItemLatest, Text: "LaTeSt"},
+				{Type: ItemBinding, Text: "?o"},
+				{Type: ItemEOF}}},
+		{`GrAnUlArItY "day"`,
+			[]Token{
+				{Type: ItemGranularity, Text: "GrAnUlArItY"},
+				{Type: ItemString, Text: `"day"`},
+				{Type: ItemEOF}}},
 		{`"true"^^type:bool "1"^^type:int64"2"^^type:float64"t"^^type:text`,
 			[]Token{
 				{Type: ItemLiteral, Text: `"true"^^type:bool`},
@@ -109,6 +166,53 @@ func TestIndividualTokens(t *testing.T) {
 			[]Token{
 				{Type: ItemLiteral, Text: `"[1 2 3 4]"^^type:blob`},
 				{Type: ItemEOF}}},
+		{`"24h"^^type:duration "-1m30s"^^type:DuRaTiOn`,
+			[]Token{
+				{Type: ItemLiteral, Text: `"24h"^^type:duration`},
+				{Type: ItemLiteral, Text: `"-1m30s"^^type:DuRaTiOn`},
+				{Type: ItemEOF}}},
+		{`"1.5"^^type:float32 "-3.14"^^type:decimal "2015-07-19"^^type:date`,
+			[]Token{
+				{Type: ItemLiteral, Text: `"1.5"^^type:float32`},
+				{Type: ItemLiteral, Text: `"-3.14"^^type:decimal`},
+				{Type: ItemLiteral, Text: `"2015-07-19"^^type:date`},
+				{Type: ItemEOF}}},
+		{"cast(?x as type:float64) type:DuRaTiOn type",
+			[]Token{
+				{Type: ItemCast, Text: "cast"},
+				{Type: ItemLPar, Text: "("},
+				{Type: ItemBinding, Text: "?x"},
+				{Type: ItemAs, Text: "as"},
+				{Type: ItemLiteralType, Text: "type:float64"},
+				{Type: ItemRPar, Text: ")"},
+				{Type: ItemLiteralType, Text: "type:DuRaTiOn"},
+				{Type: ItemType, Text: "type"},
+				{Type: ItemEOF}}},
+		{`<</_<foo> "bar"@[] <<?s ?p "1"^^type:int64>>>> < >`,
+			[]Token{
+				{Type: ItemLTriple, Text: "<<"},
+				{Type: ItemNode, Text: "/_<foo>"},
+				{Type: ItemPredicate, Text: `"bar"@[]`},
+				{Type: ItemLTriple, Text: "<<"},
+				{Type: ItemBinding, Text: "?s"},
+				{Type: ItemBinding, Text: "?p"},
+				{Type: ItemLiteral, Text: `"1"^^type:int64`},
+				{Type: ItemRTriple, Text: ">>"},
+				{Type: ItemRTriple, Text: ">>"},
+				{Type: ItemLT, Text: "<"},
+				{Type: ItemGT, Text: ">"},
+				{Type: ItemEOF}}},
+		{"type:int32",
+			[]Token{
+				{Type: ItemError,
+					Text:         "type:int32",
+					ErrorMessage: "[lexer:0:10] invalid literal type int32"},
+				{Type: ItemEOF}}},
+		{`"1"^^type:int64 "p"@[]`,
+			[]Token{
+				{Type: ItemLiteral, Text: `"1"^^type:int64`},
+				{Type: ItemPredicate, Text: `"p"@[]`},
+				{Type: ItemEOF}}},
 		{"\"1\"^type:int64",
 			[]Token{
 				{Type: ItemError,
@@ -136,6 +240,14 @@ func TestIndividualTokens(t *testing.T) {
 					Text:         "",
 					ErrorMessage: "[lexer:0:0] failed to parse predicate or literal for opening \" delimiter"},
 				{Type: ItemEOF}}},
+		{`"/prod/users", "a \"b\""; "c"`,
+			[]Token{
+				{Type: ItemString, Text: `"/prod/users"`},
+				{Type: ItemComma, Text: `,`},
+				{Type: ItemString, Text: `"a \"b\""`},
+				{Type: ItemSemicolon, Text: `;`},
+				{Type: ItemString, Text: `"c"`},
+				{Type: ItemEOF}}},
 		{`"p1"@[,,]`,
 			[]Token{
 				{Type: ItemError,
@@ -151,6 +263,8 @@ func TestIndividualTokens(t *testing.T) {
 			if idx >= len(test.tokens) {
 				t.Fatalf("lex(%q) has not finished producing tokens when it should have.", test.input)
 			}
+			// Token positions are checked by TestTokenPositions.
+			got.Line, got.Col = 0, 0
 			if want := test.tokens[idx]; got != want {
 				t.Errorf("lex(%q) failed to provide %+v, got %+v instead", test.input, want, got)
 			}
@@ -195,6 +309,11 @@ func TestValidTokenQuery(t *testing.T) {
 			ItemBinding, ItemLT, ItemBinding, ItemAnd, ItemNot, ItemBinding, ItemOr,
 			ItemBinding, ItemEQ, ItemBinding, ItemLimit, ItemLiteral, ItemSemicolon,
 			ItemEOF}},
+		{`select ?s from ?foo where {?s ?p ?o}
+				between now - "48h"^^type:duration, "bar"@["123"];`, []TokenType{
+			ItemQuery, ItemBinding, ItemFrom, ItemBinding, ItemWhere, ItemLBracket,
+			ItemBinding, ItemBinding, ItemBinding, ItemRBracket, ItemBetween, ItemNow,
+			ItemMinus, ItemLiteral, ItemComma, ItemPredicate, ItemSemicolon, ItemEOF}},
 	}
 	for _, test := range table {
 		_, c := lex(test.input, 0)
@@ -204,10 +323,87 @@ func TestValidTokenQuery(t *testing.T) {
 				t.Fatalf("lex(%q) has not finished producing tokens when it should have.", test.input)
 			}
 			if want := test.tokens[idx]; got.Type != want {
-				t.Errorf("lex(%q) failed to provide token %s; got %s instead", test.input, got, want)
+				t.Errorf("lex(%q) failed to provide token %s; got %s instead", test.input, want, got.Type)
 			}
 			idx++
 		}
 	}
 
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "select ?s\n  from ?g\n where {?s ?p /u<joe>};"
+	want := []struct {
+		text      string
+		line, col int
+	}{
+		{"select", 1, 1},
+		{"?s", 1, 8},
+		{"from", 2, 3},
+		{"?g", 2, 8},
+		{"where", 3, 2},
+		{"{", 3, 8},
+		{"?s", 3, 9},
+		{"?p", 3, 12},
+		{"/u<joe>", 3, 15},
+		{"}", 3, 22},
+		{";", 3, 23},
+		{"", 3, 24},
+	}
+	idx := 0
+	for got := range New(input, 0) {
+		if idx >= len(want) {
+			t.Fatalf("lex(%q) produced more tokens than expected", input)
+		}
+		w := want[idx]
+		if got.Text != w.text || got.Line != w.line || got.Col != w.col {
+			t.Errorf("lex(%q) returned %q at %d:%d; want %q at %d:%d", input, got.Text, got.Line, got.Col, w.text, w.line, w.col)
+		}
+		idx++
+	}
+}
+
+func TestKeywordCase(t *testing.T) {
+	input := `SELECT ?Foo FROM ?Bar WHERE {?Foo /U<Joe> "Knows"@[]};`
+	want := []Token{
+		{Type: ItemQuery, Text: "SELECT"},
+		{Type: ItemBinding, Text: "?Foo"},
+		{Type: ItemFrom, Text: "FROM"},
+		{Type: ItemBinding, Text: "?Bar"},
+		{Type: ItemWhere, Text: "WHERE"},
+		{Type: ItemLBracket, Text: "{"},
+		{Type: ItemBinding, Text: "?Foo"},
+		{Type: ItemNode, Text: "/U<Joe>"},
+		{Type: ItemPredicate, Text: `"Knows"@[]`},
+		{Type: ItemRBracket, Text: "}"},
+		{Type: ItemSemicolon, Text: ";"},
+		{Type: ItemEOF},
+	}
+	idx := 0
+	for got := range New(input, 0) {
+		if idx >= len(want) {
+			t.Fatalf("lex(%q) produced more tokens than expected", input)
+		}
+		if w := want[idx]; got.Type != w.Type || got.Text != w.Text {
+			t.Errorf("lex(%q) returned %s %q; want %s %q", input, got.Type, got.Text, w.Type, w.Text)
+		}
+		idx++
+	}
+
+	opts := Options{CaseSensitiveKeywords: true}
+	table := []struct {
+		input string
+		want  TokenType
+	}{
+		{"select", ItemQuery},
+		{"SELECT", ItemError},
+		{"Where", ItemError},
+		{"SINK", ItemError},
+		{"sink", itemSink},
+	}
+	for _, entry := range table {
+		if got := <-NewWithOptions(entry.input, 0, opts); got.Type != entry.want {
+			t.Errorf("lexer.NewWithOptions(%q, %+v) returned %s; want %s", entry.input, opts, got.Type, entry.want)
+		}
+	}
+}
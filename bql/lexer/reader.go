@@ -0,0 +1,154 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// chunkSize contains the minimum size of the chunks of text scanned at once
+// by the lexers created by NewReaderLexer.
+const chunkSize = 4096
+
+// NewReaderLexer returns a new read only channel with the tokens found in the
+// text provided by the reader. Instead of loading the whole text into memory,
+// the text is read and scanned incrementally in chunks that end on white
+// spaces separating tokens. Errors returned by the reader are reported as
+// ItemError tokens.
+func NewReaderLexer(r io.Reader, capacity int) <-chan Token {
+	return NewReaderLexerWithOptions(r, capacity, Options{})
+}
+
+// NewReaderLexerWithOptions returns a new read only channel with the tokens
+// found in the text provided by the reader, scanned using the provided options.
+func NewReaderLexerWithOptions(r io.Reader, capacity int, opts Options) <-chan Token {
+	if capacity < 0 {
+		capacity = 0
+	}
+	c := make(chan Token, capacity)
+	go func() {
+		defer close(c)
+		s := &chunker{r: bufio.NewReader(r), line: 1, col: 1}
+		for {
+			chunk, line, col, err := s.next()
+			if err != nil && err != io.EOF {
+				c <- Token{
+					Type:         ItemError,
+					ErrorMessage: fmt.Sprintf("[lexer:%d:%d] failed to read input; %v", s.line, s.col, err),
+					Line:         s.line,
+					Col:          s.col,
+				}
+				return
+			}
+			last := err == io.EOF
+			_, tkns := lexAt(chunk, capacity, line, col, opts)
+			for tkn := range tkns {
+				if tkn.Type == ItemEOF && !last {
+					continue
+				}
+				c <- tkn
+				if tkn.Type == ItemError {
+					// The lexer stops scanning on errors.
+					for range tkns {
+					}
+					return
+				}
+			}
+			if last {
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// chunker splits the text provided by a reader into chunks that can be
+// scanned independently.
+type chunker struct {
+	r   *bufio.Reader
+	buf strings.Builder
+	// line and col contain the 1-based position where the next chunk starts.
+	line int
+	col  int
+	// The state below tracks if the text read so far can be split without
+	// breaking a token apart.
+	inQuote  bool
+	escaped  bool
+	angles   int
+	brackets int
+}
+
+// next returns the next chunk of text and its 1-based starting position. It
+// returns io.EOF with the last chunk.
+func (c *chunker) next() (string, int, int, error) {
+	c.buf.Reset()
+	line, col := c.line, c.col
+	for {
+		r, _, err := c.r.ReadRune()
+		if err != nil {
+			return c.buf.String(), line, col, err
+		}
+		c.buf.WriteRune(r)
+		c.col++
+		if r == newLine {
+			c.line++
+			c.col = 1
+		}
+		if c.track(r) && c.buf.Len() >= chunkSize {
+			return c.buf.String(), line, col, nil
+		}
+	}
+}
+
+// track updates the state of the chunker with the provided rune and returns
+// true if the text read so far can be split after it.
+func (c *chunker) track(r rune) bool {
+	if c.inQuote {
+		switch {
+		case c.escaped:
+			c.escaped = false
+		case r == backSlash:
+			c.escaped = true
+		case r == quote:
+			c.inQuote = false
+		}
+		return false
+	}
+	switch r {
+	case quote:
+		c.inQuote = true
+	case lt:
+		c.angles++
+	case gt:
+		if c.angles > 0 {
+			c.angles--
+		}
+	case '[':
+		c.brackets++
+	case rightSquarePar:
+		if c.brackets > 0 {
+			c.brackets--
+		}
+	case semicolon:
+		// Comparison operators may leave angles unbalanced. Statements never
+		// span past a semicolon.
+		c.angles, c.brackets = 0, 0
+	}
+	return unicode.IsSpace(r) && c.angles == 0 && c.brackets == 0
+}
@@ -0,0 +1,86 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func collect(c <-chan Token) []Token {
+	var res []Token
+	for tkn := range c {
+		res = append(res, tkn)
+	}
+	return res
+}
+
+func TestNewReaderLexer(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("insert data into ?a {\n")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&b, "  /u<user %d> \"knows\"@[] \"a ; b %d\"^^type:text .\n", i, i)
+		fmt.Fprintf(&b, "  /u<user %d>  \"met\"@[2015-07-19T13:12:04.669618843-07:00] /u<joe>\t.\n", i)
+	}
+	b.WriteString("  /u<joe> \"knows\"@[] /u<mary>\n};\n")
+	b.WriteString("select ?a from ?b where {?a \"met\"@[2015-07-19T13:12:04.669618843-07:00, 2016-07-19T13:12:04.669618843-07:00] ?c} having ?a < ?c;")
+	b.WriteString(strings.Repeat(" ", 5000))
+	b.WriteString("select ?a from ?b where {?a ?p ?o};")
+	table := []string{
+		"",
+		"select ?a from ?b where {?a ?p ?o};",
+		b.String(),
+		b.String() + " /u<unterminated",
+	}
+	for _, in := range table {
+		want := collect(New(in, 0))
+		got := collect(NewReaderLexer(strings.NewReader(in), 0))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("NewReaderLexer returned %d tokens different from the %d tokens returned by New", len(got), len(want))
+			for i := 0; i < len(got) && i < len(want); i++ {
+				if got[i] != want[i] {
+					t.Errorf("first difference at token %d; got %+v, want %+v", i, got[i], want[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+type failingReader struct {
+	r io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("boom")
+	}
+	return n, err
+}
+
+func TestNewReaderLexerReadErrors(t *testing.T) {
+	tkns := collect(NewReaderLexer(&failingReader{strings.NewReader("select ?a")}, 0))
+	if len(tkns) == 0 {
+		t.Fatalf("NewReaderLexer should have returned an error token")
+	}
+	if got := tkns[len(tkns)-1]; got.Type != ItemError || !strings.Contains(got.ErrorMessage, "boom") {
+		t.Errorf("NewReaderLexer returned %+v as last token; want an error token with the reader error", got)
+	}
+}
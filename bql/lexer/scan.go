@@ -0,0 +1,87 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexer
+
+import "unicode/utf8"
+
+// Span contains a token along with the byte offsets of its text in the
+// scanned input.
+type Span struct {
+	Token
+
+	// Start and End contain the byte offsets where the text of the token starts
+	// and ends, exclusive, in the input.
+	Start int
+	End   int
+}
+
+// Trivia contains a piece of the input that the lexer skips between tokens,
+// such as white spaces.
+type Trivia struct {
+	Text string
+
+	// Line and Col contain the 1-based line and column, in runes, where the
+	// trivia starts in the input.
+	Line int
+	Col  int
+
+	// Start and End contain the byte offsets where the trivia starts and ends,
+	// exclusive, in the input.
+	Start int
+	End   int
+}
+
+// Scan returns all the tokens found in the input along with their byte
+// offsets, and the trivia found between them. Tokens and trivia are returned
+// in the order they appear in the input, and together cover all of it up to
+// the end of the input or the first ItemError token, since the lexer stops
+// scanning on errors. Scan allows editors to highlight BQL using the same
+// tokens the parser sees.
+func Scan(input string, opts Options) ([]Span, []Trivia) {
+	var (
+		spans  []Span
+		trivia []Trivia
+	)
+	pos, line, col := 0, 1, 1
+	advance := func() {
+		r, w := utf8.DecodeRuneInString(input[pos:])
+		pos += w
+		col++
+		if r == newLine {
+			line++
+			col = 1
+		}
+	}
+	for tkn := range NewWithOptions(input, 0, opts) {
+		start, sLine, sCol := pos, line, col
+		for pos < len(input) && (line < tkn.Line || line == tkn.Line && col < tkn.Col) {
+			advance()
+		}
+		if pos > start {
+			trivia = append(trivia, Trivia{
+				Text:  input[start:pos],
+				Line:  sLine,
+				Col:   sCol,
+				Start: start,
+				End:   pos,
+			})
+		}
+		spans = append(spans, Span{Token: tkn, Start: pos, End: pos + len(tkn.Text)})
+		for end := pos + len(tkn.Text); pos < end; {
+			advance()
+		}
+	}
+	return spans, trivia
+}
@@ -0,0 +1,55 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	input := "select ?s\n  from ?g where {?s \"né\"@[] /u<joe>};  "
+	spans, trivia := Scan(input, Options{})
+	var got []string
+	for _, s := range spans {
+		if s.Text != input[s.Start:s.End] {
+			t.Errorf("lexer.Scan returned %q for %v at offsets [%d, %d); want %q", input[s.Start:s.End], s.Type, s.Start, s.End, s.Text)
+		}
+		got = append(got, s.Text)
+	}
+	want := []string{"select", "?s", "from", "?g", "where", "{", "?s", `"né"@[]`, "/u<joe>", "}", ";", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lexer.Scan returned tokens %q; want %q", got, want)
+	}
+	if last := spans[len(spans)-1]; last.Type != ItemEOF || last.Start != len(input) {
+		t.Errorf("lexer.Scan returned %v at %d as last token; want EOF at %d", last.Type, last.Start, len(input))
+	}
+	n := 0
+	for _, tr := range trivia {
+		if tr.Text != input[tr.Start:tr.End] {
+			t.Errorf("lexer.Scan returned trivia %q at offsets [%d, %d); want %q", tr.Text, tr.Start, tr.End, input[tr.Start:tr.End])
+		}
+		n += len(tr.Text)
+	}
+	for _, s := range spans {
+		n += len(s.Text)
+	}
+	if n != len(input) {
+		t.Errorf("lexer.Scan tokens and trivia cover %d bytes; want %d", n, len(input))
+	}
+	if got, want := trivia[1], (Trivia{Text: "\n  ", Line: 1, Col: 10, Start: 9, End: 12}); got != want {
+		t.Errorf("lexer.Scan returned trivia %+v; want %+v", got, want)
+	}
+}
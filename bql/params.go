@@ -0,0 +1,128 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bql
+
+import (
+	"fmt"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// slot locates the graph clause whose subject or object holds one of the
+// values of a statement.
+type slot struct {
+	// minus contains the index of the minus pattern holding the clause, or -1
+	// if the clause belongs to the graph pattern.
+	minus int
+	// cls contains the index of the clause on its pattern.
+	cls int
+	// subject is true if the value is the subject of the clause.
+	subject bool
+}
+
+// visitClauses calls the provided function for each graph clause of the
+// statement, including the ones of its minus patterns. Clauses are always
+// visited in the same order for statements only differing in the values of
+// their literals.
+func visitClauses(stm *semantic.Statement, f func(slot, *semantic.GraphClause)) {
+	for i, cls := range stm.GraphPatternClauses() {
+		f(slot{minus: -1, cls: i}, cls)
+	}
+	for i, ptrn := range stm.MinusPatterns() {
+		for j, cls := range ptrn {
+			f(slot{minus: i, cls: j}, cls)
+		}
+	}
+}
+
+// valueSlots returns the slots holding each of the provided literals and
+// nodes, in the order they were written on the statement. It returns false if
+// the values cannot be parameterized: if any of them is not the subject or
+// object of exactly one graph clause, such as the literals of having or limit
+// clauses or the nodes using prefixes, or if two of them have the same value,
+// since their slots would be ambiguous.
+func valueSlots(stm *semantic.Statement, vals []string) ([]slot, bool) {
+	seen := make(map[string]bool)
+	var slots []slot
+	for _, val := range vals {
+		o, err := triple.ParseObject(val, literal.DefaultBuilder())
+		if err != nil {
+			return nil, false
+		}
+		v := o.String()
+		if seen[v] {
+			return nil, false
+		}
+		seen[v] = true
+		var found []slot
+		visitClauses(stm, func(s slot, cls *semantic.GraphClause) {
+			if cls == nil {
+				return
+			}
+			if cls.S != nil && cls.S.String() == v {
+				found = append(found, slot{minus: s.minus, cls: s.cls, subject: true})
+			}
+			if cls.O != nil && cls.O.String() == v {
+				found = append(found, s)
+			}
+		})
+		if len(found) != 1 {
+			return nil, false
+		}
+		slots = append(slots, found[0])
+	}
+	return slots, true
+}
+
+// bindValues returns a copy of the statement with the values held by the
+// provided slots replaced by the given ones, as if the statement had been
+// parsed with them.
+func bindValues(stm *semantic.Statement, slots []slot, vals []string) (*semantic.Statement, error) {
+	if len(slots) != len(vals) {
+		return nil, fmt.Errorf("bql.bindValues: got %d values for %d slots", len(vals), len(slots))
+	}
+	objs := make(map[slot]*triple.Object)
+	for i, val := range vals {
+		o, err := triple.ParseObject(val, literal.DefaultBuilder())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := o.Node(); slots[i].subject && err != nil {
+			return nil, fmt.Errorf("bql.bindValues: subject %s is not a node", val)
+		}
+		objs[slots[i]] = o
+	}
+	bs := stm.Clone()
+	visitClauses(bs, func(s slot, cls *semantic.GraphClause) {
+		if o, ok := objs[s]; ok {
+			cls.O = o
+		}
+		s.subject = true
+		if o, ok := objs[s]; ok {
+			cls.S, _ = o.Node()
+		}
+	})
+	i := 0
+	for _, tkn := range bs.Tokens() {
+		if (tkn.Type == lexer.ItemLiteral || tkn.Type == lexer.ItemNode) && i < len(vals) {
+			tkn.Text = vals[i]
+			i++
+		}
+	}
+	return bs, nil
+}
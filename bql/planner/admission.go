@@ -0,0 +1,222 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AdmissionOptions configures an admission controller. Zero values disable
+// the corresponding limit.
+type AdmissionOptions struct {
+	// MaxConcurrent is the maximum number of statements running at once.
+	MaxConcurrent int
+
+	// MaxPerCaller is the maximum number of statements a single caller may run
+	// at once, so a burst from one caller cannot take all the slots.
+	MaxPerCaller int
+
+	// MaxQueued is the maximum number of statements waiting to be admitted.
+	// Statements arriving once the queue is full are rejected right away.
+	MaxQueued int
+
+	// QueueTimeout is the maximum time a statement may wait to be admitted.
+	// Statements always stop waiting once their context is done.
+	QueueTimeout time.Duration
+}
+
+// AdmissionError is returned when a statement is not admitted.
+type AdmissionError struct {
+	// Limit contains the name of the limit that prevented the admission.
+	Limit string
+
+	// Max contains the value of the limit.
+	Max string
+}
+
+// Error returns the error message.
+func (e *AdmissionError) Error() string {
+	return fmt.Sprintf("statement not admitted; the %s limit of %s was reached", e.Limit, e.Max)
+}
+
+// waiter contains a statement waiting to be admitted.
+type waiter struct {
+	caller   string
+	admitted chan struct{}
+}
+
+// Admission decides when statements may start running, queueing them while
+// the concurrency limits are reached. Queued statements are admitted in
+// arrival order, skipping those whose caller is at its quota so they do not
+// block others. Admission is safe for concurrent use.
+type Admission struct {
+	opts AdmissionOptions
+
+	mu      sync.Mutex
+	running int
+	callers map[string]int
+	queue   *list.List
+}
+
+// NewAdmission returns a new admission controller.
+func NewAdmission(opts AdmissionOptions) (*Admission, error) {
+	if opts.MaxConcurrent < 0 || opts.MaxPerCaller < 0 || opts.MaxQueued < 0 || opts.QueueTimeout < 0 {
+		return nil, fmt.Errorf("planner.NewAdmission: invalid negative options %+v", opts)
+	}
+	return &Admission{
+		opts:    opts,
+		callers: make(map[string]int),
+		queue:   list.New(),
+	}, nil
+}
+
+// fits returns true if a statement of the caller can run now.
+func (a *Admission) fits(caller string) bool {
+	if m := a.opts.MaxConcurrent; m > 0 && a.running >= m {
+		return false
+	}
+	if m := a.opts.MaxPerCaller; m > 0 && a.callers[caller] >= m {
+		return false
+	}
+	return true
+}
+
+// start records a statement of the caller as running.
+func (a *Admission) start(caller string) {
+	a.running++
+	a.callers[caller]++
+}
+
+// Admit blocks until a statement of the provided caller may run, and returns
+// the function that must be called once it finishes. It fails with an
+// *AdmissionError if the queue is full or the statement waited longer than
+// the queue timeout, and with the context error if the context is done first.
+func (a *Admission) Admit(ctx context.Context, caller string) (release func(), err error) {
+	a.mu.Lock()
+	// Queued statements never fit, since they are admitted as soon as they
+	// do, hence admitting a statement that fits does not skip any of them.
+	if a.fits(caller) {
+		a.start(caller)
+		a.mu.Unlock()
+		return a.releaser(caller), nil
+	}
+	if m := a.opts.MaxQueued; m > 0 && a.queue.Len() >= m {
+		a.mu.Unlock()
+		return nil, &AdmissionError{Limit: "MaxQueued", Max: fmt.Sprint(m)}
+	}
+	w := &waiter{caller: caller, admitted: make(chan struct{})}
+	e := a.queue.PushBack(w)
+	d := a.opts.QueueTimeout
+	a.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case <-w.admitted:
+		return a.releaser(caller), nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = &AdmissionError{Limit: "QueueTimeout", Max: d.String()}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	select {
+	case <-w.admitted:
+		// Admitted while giving up; hand the slot to the next statement.
+		a.finish(caller)
+	default:
+		a.queue.Remove(e)
+	}
+	return nil, err
+}
+
+// releaser returns the function that releases the slot of a statement of the
+// caller. Calling it more than once has no effect.
+func (a *Admission) releaser(caller string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.finish(caller)
+		})
+	}
+}
+
+// finish records a statement of the caller as finished, and admits the queued
+// statements that fit. It must be called holding the lock.
+func (a *Admission) finish(caller string) {
+	a.running--
+	if a.callers[caller]--; a.callers[caller] == 0 {
+		delete(a.callers, caller)
+	}
+	a.admit()
+}
+
+// admit admits the queued statements that fit. It must be called holding the
+// lock.
+func (a *Admission) admit() {
+	for e := a.queue.Front(); e != nil; {
+		next := e.Next()
+		w := e.Value.(*waiter)
+		if a.fits(w.caller) {
+			a.queue.Remove(e)
+			a.start(w.caller)
+			close(w.admitted)
+		}
+		if m := a.opts.MaxConcurrent; m > 0 && a.running >= m {
+			break
+		}
+		e = next
+	}
+}
+
+// Options returns the options currently used by the controller.
+func (a *Admission) Options() AdmissionOptions {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.opts
+}
+
+// SetOptions replaces the options of the controller, so its limits can be
+// tuned while it is in use. Statements already running are not affected,
+// while the queued ones that fit the new limits are admitted right away. The
+// queue timeout only applies to the statements queued afterwards.
+func (a *Admission) SetOptions(opts AdmissionOptions) error {
+	if opts.MaxConcurrent < 0 || opts.MaxPerCaller < 0 || opts.MaxQueued < 0 || opts.QueueTimeout < 0 {
+		return fmt.Errorf("planner.Admission.SetOptions: invalid negative options %+v", opts)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.opts = opts
+	a.admit()
+	return nil
+}
+
+// Load returns the number of statements running and waiting to be admitted.
+func (a *Admission) Load() (running, queued int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.running, a.queue.Len()
+}
@@ -0,0 +1,173 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// admitAsync requests the admission of a statement of the caller in the
+// background, returning the channel that receives its release function once
+// admitted, or nil if it fails.
+func admitAsync(a *Admission, ctx context.Context, caller string) <-chan func() {
+	c := make(chan func(), 1)
+	go func() {
+		r, err := a.Admit(ctx, caller)
+		if err != nil {
+			r = nil
+		}
+		c <- r
+	}()
+	return c
+}
+
+// waitQueued waits until the controller has n statements queued.
+func waitQueued(t *testing.T, a *Admission, n int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if _, q := a.Load(); q == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("admission controller never queued %d statements", n)
+}
+
+func TestAdmissionConcurrency(t *testing.T) {
+	a, err := NewAdmission(AdmissionOptions{MaxConcurrent: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	r1, err := a.Admit(ctx, "joe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := admitAsync(a, ctx, "mary")
+	waitQueued(t, a, 1)
+	select {
+	case <-c:
+		t.Fatalf("Admission.Admit should have waited for a free slot")
+	default:
+	}
+	r1()
+	r1()
+	r2 := <-c
+	if r2 == nil {
+		t.Fatalf("Admission.Admit should have admitted the queued statement")
+	}
+	if running, queued := a.Load(); running != 1 || queued != 0 {
+		t.Errorf("Admission.Load() = %d, %d; want 1, 0", running, queued)
+	}
+	r2()
+}
+
+func TestAdmissionPerCaller(t *testing.T) {
+	a, err := NewAdmission(AdmissionOptions{MaxConcurrent: 3, MaxPerCaller: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	r1, err := a.Admit(ctx, "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := admitAsync(a, ctx, "batch")
+	waitQueued(t, a, 1)
+	// Interactive callers are not blocked by the queued batch statement.
+	r2, err := a.Admit(ctx, "interactive")
+	if err != nil {
+		t.Fatalf("Admission.Admit should have admitted a different caller; %v", err)
+	}
+	r2()
+	r1()
+	if r := <-c; r == nil {
+		t.Errorf("Admission.Admit should have admitted the queued batch statement")
+	} else {
+		r()
+	}
+}
+
+func TestAdmissionRejections(t *testing.T) {
+	a, err := NewAdmission(AdmissionOptions{MaxConcurrent: 1, MaxQueued: 1, QueueTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	r, err := a.Admit(ctx, "joe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r()
+	c := admitAsync(a, ctx, "joe")
+	waitQueued(t, a, 1)
+	if _, err := a.Admit(ctx, "joe"); err == nil {
+		t.Errorf("Admission.Admit should have rejected a statement once the queue is full")
+	} else if ae, ok := err.(*AdmissionError); !ok || ae.Limit != "MaxQueued" {
+		t.Errorf("Admission.Admit returned %v; want a MaxQueued admission error", err)
+	}
+	if r := <-c; r != nil {
+		t.Errorf("Admission.Admit should have timed out waiting")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	c = admitAsync(a, cctx, "joe")
+	waitQueued(t, a, 1)
+	cancel()
+	if r := <-c; r != nil {
+		t.Errorf("Admission.Admit should have stopped waiting once the context was cancelled")
+	}
+	if _, queued := a.Load(); queued != 0 {
+		t.Errorf("Admission.Admit left %d abandoned statements queued", queued)
+	}
+	if _, err := NewAdmission(AdmissionOptions{MaxQueued: -1}); err == nil {
+		t.Errorf("planner.NewAdmission should have rejected negative options")
+	}
+}
+
+func TestAdmissionSetOptions(t *testing.T) {
+	a, err := NewAdmission(AdmissionOptions{MaxConcurrent: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	r1, err := a.Admit(ctx, "joe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := admitAsync(a, ctx, "mary")
+	waitQueued(t, a, 1)
+	if err := a.SetOptions(AdmissionOptions{MaxConcurrent: -1}); err == nil {
+		t.Errorf("Admission.SetOptions should have rejected negative options")
+	}
+	want := AdmissionOptions{MaxConcurrent: 2}
+	if err := a.SetOptions(want); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Options(); got != want {
+		t.Errorf("Admission.Options() = %+v; want %+v", got, want)
+	}
+	r2 := <-c
+	if r2 == nil {
+		t.Fatalf("Admission.SetOptions should have admitted the queued statement")
+	}
+	if running, queued := a.Load(); running != 2 || queued != 0 {
+		t.Errorf("Admission.Load() = %d, %d; want 2, 0", running, queued)
+	}
+	r1()
+	r2()
+}
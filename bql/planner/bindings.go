@@ -0,0 +1,104 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/badwolf/bql/table"
+)
+
+// bindingChecker enforces that all the values assigned to a binding while
+// building or joining rows agree. Rows that fail the check are dropped, and
+// the checker keeps a count of them per offending binding so the conflicts
+// can be reported. The checker can be reset and reused across rows, but it is
+// not safe for concurrent use.
+type bindingChecker struct {
+	vals      map[string]*table.Cell
+	conflicts map[string]int64
+}
+
+// newBindingChecker returns a new empty binding checker.
+func newBindingChecker() *bindingChecker {
+	return &bindingChecker{
+		vals:      make(map[string]*table.Cell),
+		conflicts: make(map[string]int64),
+	}
+}
+
+// reset forgets the values bound so far, keeping the conflicts recorded.
+func (c *bindingChecker) reset() {
+	for k := range c.vals {
+		delete(c.vals, k)
+	}
+}
+
+// bind assigns the value to the binding. It returns false and records the
+// conflict if the binding was already assigned a different value since the
+// last reset.
+func (c *bindingChecker) bind(b string, v *table.Cell) bool {
+	if ov, ok := c.vals[b]; ok && !ov.Equal(v) {
+		c.conflicts[b]++
+		return false
+	}
+	c.vals[b] = v
+	return true
+}
+
+// compatible returns true if both rows agree on the values of all the
+// bindings they share. Null cells agree with any value. Otherwise, it records
+// the conflict on the first binding found to disagree.
+func (c *bindingChecker) compatible(r1, r2 table.Row) bool {
+	for b, v := range r2 {
+		if v1, ok := r1[b]; ok && !v1.IsNull() && !v.IsNull() && !v1.Equal(v) {
+			c.conflicts[b]++
+			return false
+		}
+	}
+	return true
+}
+
+// merge adds the conflicts recorded by the provided checker.
+func (c *bindingChecker) merge(o *bindingChecker) {
+	for b, n := range o.conflicts {
+		c.conflicts[b] += n
+	}
+}
+
+// dropped returns the total number of rows dropped because of conflicting
+// bindings.
+func (c *bindingChecker) dropped() int64 {
+	var n int64
+	for _, v := range c.conflicts {
+		n += v
+	}
+	return n
+}
+
+// String returns the recorded conflicts sorted by binding.
+func (c *bindingChecker) String() string {
+	var bs []string
+	for b := range c.conflicts {
+		bs = append(bs, b)
+	}
+	sort.Strings(bs)
+	var ss []string
+	for _, b := range bs {
+		ss = append(ss, fmt.Sprintf("%s=%d", b, c.conflicts[b]))
+	}
+	return strings.Join(ss, ", ")
+}
@@ -0,0 +1,126 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"testing"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+)
+
+func TestBindingCheckerBind(t *testing.T) {
+	n1, n2 := node.NewBlankNode(), node.NewBlankNode()
+	chk := newBindingChecker()
+	if !chk.bind("?s", &table.Cell{N: n1}) {
+		t.Errorf("bindingChecker.bind should accept the first value of a binding")
+	}
+	if !chk.bind("?s", &table.Cell{N: n1}) {
+		t.Errorf("bindingChecker.bind should accept equal values for the same binding")
+	}
+	if chk.bind("?s", &table.Cell{N: n2}) {
+		t.Errorf("bindingChecker.bind should reject different values for the same binding")
+	}
+	chk.reset()
+	if !chk.bind("?s", &table.Cell{N: n2}) {
+		t.Errorf("bindingChecker.bind should accept a new value after a reset")
+	}
+	if got, want := chk.dropped(), int64(1); got != want {
+		t.Errorf("bindingChecker.dropped returned the wrong number of conflicts; got %d, want %d", got, want)
+	}
+	if got, want := chk.String(), "?s=1"; got != want {
+		t.Errorf("bindingChecker.String returned the wrong conflicts; got %q, want %q", got, want)
+	}
+}
+
+func TestBindingCheckerCompatible(t *testing.T) {
+	n1, n2 := node.NewBlankNode(), node.NewBlankNode()
+	testTable := []struct {
+		r1, r2 table.Row
+		want   bool
+	}{
+		{
+			r1:   table.Row{"?a": &table.Cell{N: n1}},
+			r2:   table.Row{"?b": &table.Cell{N: n2}},
+			want: true,
+		},
+		{
+			r1:   table.Row{"?a": &table.Cell{N: n1}},
+			r2:   table.Row{"?a": &table.Cell{N: n1}, "?b": &table.Cell{N: n2}},
+			want: true,
+		},
+		{
+			r1:   table.Row{"?a": &table.Cell{N: n1}},
+			r2:   table.Row{"?a": &table.Cell{N: n2}},
+			want: false,
+		},
+	}
+	chk := newBindingChecker()
+	for _, entry := range testTable {
+		if got, want := chk.compatible(entry.r1, entry.r2), entry.want; got != want {
+			t.Errorf("bindingChecker.compatible(%v, %v) returned %v; want %v", entry.r1, entry.r2, got, want)
+		}
+	}
+	if got, want := chk.String(), "?a=1"; got != want {
+		t.Errorf("bindingChecker.String returned the wrong conflicts; got %q, want %q", got, want)
+	}
+}
+
+func TestBindingCheckerMerge(t *testing.T) {
+	c1, c2 := newBindingChecker(), newBindingChecker()
+	c1.conflicts["?a"] = 1
+	c2.conflicts["?a"] = 2
+	c2.conflicts["?b"] = 3
+	c1.merge(c2)
+	if got, want := c1.String(), "?a=3, ?b=3"; got != want {
+		t.Errorf("bindingChecker.merge returned the wrong conflicts; got %q, want %q", got, want)
+	}
+	if got, want := c1.dropped(), int64(6); got != want {
+		t.Errorf("bindingChecker.dropped returned the wrong number of conflicts; got %d, want %d", got, want)
+	}
+}
+
+func TestTripleToRowRecordsConflicts(t *testing.T) {
+	tpls := []string{
+		"/u<joe>\t\"knows\"@[]\t/u<joe>",
+		"/u<joe>\t\"knows\"@[]\t/u<mary>",
+		"/u<mary>\t\"knows\"@[]\t/u<mary>",
+	}
+	cls := &semantic.GraphClause{SBinding: "?x", OBinding: "?x"}
+	chk := newBindingChecker()
+	n := 0
+	for _, s := range tpls {
+		tpl, err := triple.ParseTriple(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.ParseTriple failed to parse valid triple %q with error %v", s, err)
+		}
+		r, err := tripleToRow(tpl, cls, chk)
+		if err != nil {
+			t.Fatalf("tripleToRow for triple %q failed with error %v", tpl, err)
+		}
+		if r != nil {
+			n++
+		}
+	}
+	if got, want := n, 2; got != want {
+		t.Errorf("tripleToRow returned the wrong number of rows; got %d, want %d", got, want)
+	}
+	if got, want := chk.String(), "?x=1"; got != want {
+		t.Errorf("tripleToRow recorded the wrong conflicts; got %q, want %q", got, want)
+	}
+}
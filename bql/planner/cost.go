@@ -0,0 +1,112 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"sort"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+)
+
+// graphStats returns the statistics of the provided graphs added together, or
+// nil if any of them has not been analyzed, since the costs of the clauses
+// could not be compared then.
+func graphStats(gs []storage.Graph) *storage.GraphStats {
+	if len(gs) == 0 {
+		return nil
+	}
+	res := &storage.GraphStats{Predicates: make(map[string]int64)}
+	for _, g := range gs {
+		k, ok := g.(storage.StatsKeeper)
+		if !ok {
+			return nil
+		}
+		s, err := k.Stats()
+		if err != nil || s == nil {
+			return nil
+		}
+		res.Triples += s.Triples
+		res.Subjects += s.Subjects
+		for p, n := range s.Predicates {
+			res.Predicates[p] += n
+		}
+	}
+	return res
+}
+
+// clauseCost returns the estimated number of triples the clause matches on
+// graphs with the provided statistics. Predicates are counted exactly, while
+// subjects and objects are assumed to be evenly distributed, hence a fixed
+// subject matches the average fanout of the subjects of the graphs. Objects
+// are assumed to be as distinct as subjects.
+func clauseCost(cls *semantic.GraphClause, s *storage.GraphStats) float64 {
+	c := float64(s.Triples)
+	if cls.P != nil {
+		c = float64(s.Predicates[string(cls.P.ID())])
+	}
+	if s.Subjects > 0 {
+		if cls.S != nil {
+			c /= float64(s.Subjects)
+		}
+		if cls.O != nil {
+			c /= float64(s.Subjects)
+		}
+	}
+	return c
+}
+
+// costOrder returns the provided clauses in the order they should be joined
+// given the statistics of the queried graphs. It starts with the clause that
+// matches the fewest triples, and then repeatedly picks the cheapest clause
+// sharing a binding with the ones already picked, so intermediate results are
+// kept small and cartesian products are only computed when unavoidable. Ties
+// keep the order of the provided clauses.
+func costOrder(cls []*semantic.GraphClause, s *storage.GraphStats) []*semantic.GraphClause {
+	left := make([]*semantic.GraphClause, len(cls))
+	copy(left, cls)
+	sort.SliceStable(left, func(i, j int) bool {
+		return clauseCost(left[i], s) < clauseCost(left[j], s)
+	})
+	bound := make(map[string]bool)
+	var res []*semantic.GraphClause
+	for len(left) > 0 {
+		next := 0
+		for i, c := range left {
+			if sharesBinding(c, bound) {
+				next = i
+				break
+			}
+		}
+		c := left[next]
+		left = append(left[:next], left[next+1:]...)
+		res = append(res, c)
+		for b := range c.BindingsMap() {
+			bound[b] = true
+		}
+	}
+	return res
+}
+
+// sharesBinding returns true if any of the bindings of the clause is on the
+// provided set.
+func sharesBinding(cls *semantic.GraphClause, bound map[string]bool) bool {
+	for b := range cls.BindingsMap() {
+		if bound[b] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/storage/stats"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestCostOrder(t *testing.T) {
+	s := memory.NewStore()
+	g, err := s.NewGraph("?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts []*triple.Triple
+	for p, n := range map[string]int{"knows": 20, "other": 5, "rare": 2} {
+		for i := 0; i < n; i++ {
+			tr, err := triple.ParseTriple(fmt.Sprintf("/u<p%d>\t\"%s\"@[]\t/u<p%d>", i, p, i+1), literal.DefaultBuilder())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts = append(ts, tr)
+		}
+	}
+	if err := g.AddTriples(ts); err != nil {
+		t.Fatal(err)
+	}
+	q := `select ?s, ?x from ?test where {?s "knows"@[] ?o . ?x "other"@[] ?y . ?s "rare"@[] ?r};`
+	order := func() []string {
+		p, err := newQueryPlan(s, parseTestStatement(t, q))
+		if err != nil {
+			t.Fatalf("newQueryPlan(%q) failed with error %v", q, err)
+		}
+		var ps []string
+		for _, cls := range p.cls {
+			ps = append(ps, string(cls.P.ID()))
+		}
+		tbl, err := p.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute(%q) failed with error %v", q, err)
+		}
+		if got, want := tbl.NumRows(), 2*5; got != want {
+			t.Errorf("planner.Excecute(%q) returned %d rows; want %d", q, got, want)
+		}
+		return ps
+	}
+	if got, want := order(), []string{"knows", "other", "rare"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the planner should have kept the specificity order of the clauses for a graph without statistics; got %v, want %v", got, want)
+	}
+	if err := stats.Analyze(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	// The rare clause goes first, followed by the knows clause it joins with,
+	// even if the disconnected other clause matches fewer triples.
+	if got, want := order(), []string{"rare", "knows", "other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the planner ordered the clauses of an analyzed graph wrongly; got %v, want %v", got, want)
+	}
+}
@@ -15,8 +15,9 @@
 package planner
 
 import (
+	"context"
 	"fmt"
-	"reflect"
+	"sync"
 
 	"github.com/google/badwolf/bql/semantic"
 	"github.com/google/badwolf/bql/table"
@@ -26,18 +27,19 @@ import (
 	"github.com/google/badwolf/triple/predicate"
 )
 
-// updateTimeBounds updates the time bounds use for the lookup based on the
-// provided graph clause.
+// updateTimeBounds returns a copy of the provided lookup options with its time
+// bounds narrowed by the ones specified on the provided graph clause.
 func updateTimeBounds(lo *storage.LookupOptions, cls *semantic.GraphClause) *storage.LookupOptions {
 	nlo := &storage.LookupOptions{}
+	*nlo = *lo
 	if cls.PLowerBound != nil {
-		if lo.LowerAnchor == nil || (lo.LowerAnchor != nil && cls.PLowerBound.After(*lo.LowerAnchor)) {
-			lo.LowerAnchor = cls.PLowerBound
+		if nlo.LowerAnchor == nil || (nlo.LowerAnchor != nil && cls.PLowerBound.After(*nlo.LowerAnchor)) {
+			nlo.LowerAnchor = cls.PLowerBound
 		}
 	}
 	if cls.PUpperBound != nil {
-		if lo.UpperAnchor == nil || (lo.UpperAnchor != nil && cls.PUpperBound.Before(*lo.UpperAnchor)) {
-			lo.UpperAnchor = cls.PUpperBound
+		if nlo.UpperAnchor == nil || (nlo.UpperAnchor != nil && cls.PUpperBound.Before(*nlo.UpperAnchor)) {
+			nlo.UpperAnchor = cls.PUpperBound
 		}
 	}
 	return nlo
@@ -69,12 +71,90 @@ func updateTimeBoundsForRow(lo *storage.LookupOptions, cls *semantic.GraphClause
 	return nlo, nil
 }
 
+// maxFetchWorkers contains the maximum number of graphs queried concurrently
+// while fetching the data for a clause.
+const maxFetchWorkers = 8
+
 // simpleFetch returns a table containing the data specified by the graph
-// clause by querying the provided stora. Will return an error if it had poblems
-// retrieveing the data.
-func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions) (*table.Table, error) {
+// clause by querying the provided stora. Clauses scoped to a graph only query
+// that graph, and clauses with a graph binding bind it to the graph each row
+// was retrieved from. Graphs are queried concurrently and their rows are
+// added in the order the graphs were provided. Will return an error if it had
+// poblems retrieveing the data. Triples dropped for assigning conflicting
+// values to a binding are recorded on the provided checker.
+func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions, chk *bindingChecker) (*table.Table, error) {
+	if cls.Graph != "" {
+		var sgs []storage.Graph
+		for _, g := range gs {
+			if g.ID() == cls.Graph {
+				sgs = append(sgs, g)
+			}
+		}
+		gs = sgs
+	}
+	if len(gs) == 1 && cls.GBinding == "" {
+		return fetchFromGraphs(ctx, gs, cls, lo, chk)
+	}
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, maxFetchWorkers)
+		gts  = make([]*table.Table, len(gs))
+		chks = make([]*bindingChecker, len(gs))
+		errs = make([]error, len(gs))
+	)
+	for i, g := range gs {
+		wg.Add(1)
+		go func(i int, g storage.Graph) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			chks[i] = newBindingChecker()
+			gts[i], errs[i] = fetchFromGraphs(ctx, []storage.Graph{g}, cls, lo, chks[i])
+		}(i, g)
+	}
+	wg.Wait()
+	for _, c := range chks {
+		chk.merge(c)
+	}
+	tbl, err := table.New(cls.Bindings())
+	if err != nil {
+		return nil, err
+	}
+	for i, gt := range gts {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		var c *table.Cell
+		if cls.GBinding != "" {
+			c = &table.Cell{S: gs[i].ID()}
+		}
+		for _, r := range gt.Rows() {
+			if c != nil {
+				r[cls.GBinding] = c
+			}
+			tbl.AddRow(r)
+		}
+	}
+	return tbl, nil
+}
+
+// fetchFromGraphs returns a table containing the data specified by the graph
+// clause retrieved from the union of the provided graphs. Triples dropped for
+// assigning conflicting values to a binding are recorded on the checker.
+func fetchFromGraphs(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions, chk *bindingChecker) (*table.Table, error) {
 	s, p, o := cls.S, cls.P, cls.O
 	lo = updateTimeBounds(lo, cls)
+	if cls.PLatest {
+		gs = latestGraphs(gs, lo)
+	}
+	// The storage can only be asked for a bounded number of triples if each of
+	// them becomes a row of the table.
+	slo := lo
+	if lo.MaxElements > 0 && !exactLookup(cls) {
+		nlo := *lo
+		nlo.MaxElements = 0
+		slo = &nlo
+	}
 	tbl, err := table.New(cls.Bindings())
 	if err != nil {
 		return nil, err
@@ -94,7 +174,7 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 				ts := make(chan *triple.Triple, 1)
 				ts <- t
 				close(ts)
-				if err := addTriples(ts, cls, tbl); err != nil {
+				if err := addTriples(ctx, ts, cls, tbl, lo, chk); err != nil {
 					return nil, err
 				}
 			}
@@ -104,7 +184,7 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 	if s != nil && p != nil && o == nil {
 		// SP request.
 		for _, g := range gs {
-			os, err := g.Objects(s, p, lo)
+			os, err := g.Objects(s, p, slo)
 			if err != nil {
 				return nil, err
 			}
@@ -121,7 +201,7 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 				ts <- t
 			}
 			close(ts)
-			if err := addTriples(ts, cls, tbl); err != nil {
+			if err := addTriples(ctx, ts, cls, tbl, lo, chk); err != nil {
 				return nil, err
 			}
 		}
@@ -130,7 +210,7 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 	if s != nil && p == nil && o != nil {
 		// SO request.
 		for _, g := range gs {
-			ps, err := g.PredicatesForSubjectAndObject(s, o, lo)
+			ps, err := g.PredicatesForSubjectAndObject(s, o, slo)
 			if err != nil {
 				return nil, err
 			}
@@ -147,7 +227,7 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 				ts <- t
 			}
 			close(ts)
-			if err := addTriples(ts, cls, tbl); err != nil {
+			if err := addTriples(ctx, ts, cls, tbl, lo, chk); err != nil {
 				return nil, err
 			}
 		}
@@ -156,7 +236,7 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 	if s == nil && p != nil && o != nil {
 		// PO request.
 		for _, g := range gs {
-			ss, err := g.Subjects(p, o, lo)
+			ss, err := g.Subjects(p, o, slo)
 			if err != nil {
 				return nil, err
 			}
@@ -173,7 +253,7 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 				ts <- t
 			}
 			close(ts)
-			if err := addTriples(ts, cls, tbl); err != nil {
+			if err := addTriples(ctx, ts, cls, tbl, lo, chk); err != nil {
 				return nil, err
 			}
 		}
@@ -182,11 +262,11 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 	if s != nil && p == nil && o == nil {
 		// S request.
 		for _, g := range gs {
-			ts, err := g.TriplesForSubject(s, lo)
+			ts, err := g.TriplesForSubject(s, slo)
 			if err != nil {
 				return nil, err
 			}
-			if err := addTriples(ts, cls, tbl); err != nil {
+			if err := addTriples(ctx, ts, cls, tbl, lo, chk); err != nil {
 				return nil, err
 			}
 		}
@@ -195,11 +275,11 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 	if s == nil && p != nil && o == nil {
 		// P request.
 		for _, g := range gs {
-			ts, err := g.TriplesForPredicate(p, lo)
+			ts, err := g.TriplesForPredicate(p, slo)
 			if err != nil {
 				return nil, err
 			}
-			if err := addTriples(ts, cls, tbl); err != nil {
+			if err := addTriples(ctx, ts, cls, tbl, lo, chk); err != nil {
 				return nil, err
 			}
 		}
@@ -208,11 +288,11 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 	if s == nil && p == nil && o != nil {
 		// O request.
 		for _, g := range gs {
-			ts, err := g.TriplesForObject(o, lo)
+			ts, err := g.TriplesForObject(o, slo)
 			if err != nil {
 				return nil, err
 			}
-			if err := addTriples(ts, cls, tbl); err != nil {
+			if err := addTriples(ctx, ts, cls, tbl, lo, chk); err != nil {
 				return nil, err
 			}
 		}
@@ -225,7 +305,7 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 			if err != nil {
 				return nil, err
 			}
-			if err := addTriples(ts, cls, tbl); err != nil {
+			if err := addTriples(ctx, ts, cls, tbl, lo, chk); err != nil {
 				return nil, err
 			}
 		}
@@ -235,55 +315,69 @@ func simpleFetch(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.Look
 	return nil, fmt.Errorf("planner.simpleFetch could not recognize request in clause %v", cls)
 }
 
+// exactLookup returns true if every triple retrieved for the clause becomes a
+// row. Clauses filtering triples by predicate or object IDs, matching embedded
+// triples, or using the same binding more than once may drop some of them.
+func exactLookup(cls *semantic.GraphClause) bool {
+	if cls.PID != "" || cls.OID != "" || cls.OEmbedded != nil {
+		return false
+	}
+	for _, n := range cls.BindingsMap() {
+		if n > 1 {
+			return false
+		}
+	}
+	return true
+}
+
 // addTriples add all the retrieved triples from the graphs into the results
 // table. The semantic graph clause is also passed to be able to identify what
-// bindings to set.
-func addTriples(ts storage.Triples, cls *semantic.GraphClause, tbl *table.Table) error {
+// bindings to set. Temporal triples outside the time bounds of the provided
+// lookup options are dropped. If the lookup options set a granularity, the time
+// bounds cover whole periods and the time anchors of the triples are reduced to
+// it before being bound. It stops consuming triples once the table holds
+// the maximum number of elements set on the lookup options, and returns the
+// context error once the provided context is done. Triples assigning
+// conflicting values to a binding are dropped and recorded on the provided
+// checker.
+func addTriples(ctx context.Context, ts storage.Triples, cls *semantic.GraphClause, tbl *table.Table, lo *storage.LookupOptions, chk *bindingChecker) error {
 	for t := range ts {
-		r, err := tripleToRow(t, cls)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+		if lo.MaxElements > 0 && tbl.NumRows() >= lo.MaxElements {
+			return nil
+		}
+		if !t.P().WithinBounds(lo.Granularity.Widen(lo.LowerAnchor, lo.UpperAnchor)) {
+			continue
+		}
+		if cls.SType != nil && !t.S().Type().IsSubtypeOf(cls.SType) {
+			continue
+		}
 		if cls.PID != "" {
 			// The triples need to be filtered.
 			if t.P().ID() != predicate.ID(cls.PID) {
 				continue
 			}
-			if cls.PTemporal && t.P().Type() == predicate.Temporal {
-				ta, err := t.P().TimeAnchor()
-				if err != nil {
-					return fmt.Errorf("failed to retrieve time anchor from time predicate in triple %s with error %v", t, err)
-				}
-				// Need to check teh bounds of the triple.
-				if cls.PLowerBound != nil && cls.PLowerBound.After(*ta) {
-					continue
-				}
-				if cls.PUpperBound != nil && cls.PUpperBound.Before(*ta) {
-					continue
-				}
+			if cls.PTemporal && !t.P().WithinBounds(lo.Granularity.Widen(cls.PLowerBound, cls.PUpperBound)) {
+				continue
 			}
 		}
 		if cls.OID != "" {
-			if p, err := t.O().Predicate(); err == nil {
+			if p, ok := t.O().AsPredicate(); ok {
 				// The triples need to be filtered.
 				if p.ID() != predicate.ID(cls.OID) {
 					continue
 				}
-				if cls.OTemporal && p.Type() == predicate.Temporal {
-					ta, err := p.TimeAnchor()
-					if err != nil {
-						return fmt.Errorf("failed to retrieve time anchor from time predicate in triple %s with error %v", t, err)
-					}
-					// Need to check teh bounds of the triple.
-					if cls.OLowerBound != nil && cls.OLowerBound.After(*ta) {
-						continue
-					}
-					if cls.OUpperBound != nil && cls.OUpperBound.Before(*ta) {
-						continue
-					}
+				if cls.OTemporal && !p.WithinBounds(lo.Granularity.Widen(cls.OLowerBound, cls.OUpperBound)) {
+					continue
 				}
 			}
 		}
+		r, err := tripleToRow(coarsenTriple(t, lo.Granularity), cls, chk)
+		if err != nil {
+			return err
+		}
 		if r != nil {
 			tbl.AddRow(r)
 		}
@@ -294,66 +388,84 @@ func addTriples(ts storage.Triples, cls *semantic.GraphClause, tbl *table.Table)
 // objectToCell returns a cell containing the data boxed in the object.
 func objectToCell(o *triple.Object) (*table.Cell, error) {
 	c := &table.Cell{}
-	if n, err := o.Node(); err == nil {
-		c.N = n
-		return c, nil
+	switch o.Kind() {
+	case triple.NodeKind:
+		c.N, _ = o.AsNode()
+	case triple.PredicateKind:
+		c.P, _ = o.AsPredicate()
+	case triple.LiteralKind:
+		c.L, _ = o.AsLiteral()
+	case triple.TripleKind:
+		c.E, _ = o.AsTriple()
+	default:
+		return nil, fmt.Errorf("unknown object type in object %q", o)
+	}
+	return c, nil
+}
+
+// embeddedObject returns the object boxing the embedded triple described by
+// the provided pattern once its bindings are replaced by the values available
+// on the row. It returns nil if the row does not fully specify the triple.
+func embeddedObject(e *semantic.GraphClause, r table.Row) (*triple.Object, error) {
+	s, p, o := e.S, e.P, e.O
+	if v, ok := r[e.SBinding]; ok && s == nil {
+		s = v.N
+	}
+	if v, ok := r[e.PBinding]; ok && p == nil {
+		p = v.P
+	}
+	if v, ok := r[e.OBinding]; ok && o == nil {
+		co, err := cellToObject(v)
+		if err != nil {
+			return nil, err
+		}
+		o = co
 	}
-	if p, err := o.Predicate(); err == nil {
-		c.P = p
-		return c, nil
+	if s == nil || p == nil || o == nil {
+		return nil, nil
 	}
-	if l, err := o.Literal(); err == nil {
-		c.L = l
-		return c, nil
+	t, err := triple.New(s, p, o)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("unknown object type in object %q", o)
+	return triple.NewTripleObject(t), nil
 }
 
 // tripleToRow converts a triple into a row using the binndings specidfied
-// on the graph clause.
-func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error) {
+// on the graph clause. It returns a nil row if the triple assigns different
+// values to the same binding, recording the conflict on the provided checker.
+func tripleToRow(t *triple.Triple, cls *semantic.GraphClause, chk *bindingChecker) (table.Row, error) {
 	r, s, p, o := make(table.Row), t.S(), t.P(), t.O()
 
-	// Enforce binding validity inside te clause.
-	bnd := make(map[string]*table.Cell)
-	validBinding := func(k string, v *table.Cell) bool {
-		c, ok := bnd[k]
-		bnd[k] = v
-		if !ok {
-			return true
-		}
-		if reflect.DeepEqual(c, v) {
-			return true
-		}
-		return false
-	}
+	// Enforce binding validity inside the clause.
+	chk.reset()
 
 	// Subject related bindings.
 	if cls.SBinding != "" {
 		c := &table.Cell{N: s}
 		r[cls.SBinding] = c
-		if !validBinding(cls.SBinding, c) {
+		if !chk.bind(cls.SBinding, c) {
 			return nil, nil
 		}
 	}
 	if cls.SAlias != "" {
 		c := &table.Cell{N: s}
 		r[cls.SAlias] = c
-		if !validBinding(cls.SAlias, c) {
+		if !chk.bind(cls.SAlias, c) {
 			return nil, nil
 		}
 	}
 	if cls.STypeAlias != "" {
 		c := &table.Cell{S: s.Type().String()}
 		r[cls.STypeAlias] = c
-		if !validBinding(cls.STypeAlias, c) {
+		if !chk.bind(cls.STypeAlias, c) {
 			return nil, nil
 		}
 	}
 	if cls.SIDAlias != "" {
 		c := &table.Cell{S: s.ID().String()}
 		r[cls.SIDAlias] = c
-		if !validBinding(cls.SIDAlias, c) {
+		if !chk.bind(cls.SIDAlias, c) {
 			return nil, nil
 		}
 	}
@@ -362,21 +474,21 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 	if cls.PBinding != "" {
 		c := &table.Cell{P: p}
 		r[cls.PBinding] = c
-		if !validBinding(cls.PBinding, c) {
+		if !chk.bind(cls.PBinding, c) {
 			return nil, nil
 		}
 	}
 	if cls.PAlias != "" {
 		c := &table.Cell{P: p}
 		r[cls.PAlias] = c
-		if !validBinding(cls.PAlias, c) {
+		if !chk.bind(cls.PAlias, c) {
 			return nil, nil
 		}
 	}
 	if cls.PIDAlias != "" {
 		c := &table.Cell{S: string(p.ID())}
 		r[cls.PIDAlias] = c
-		if !validBinding(cls.PIDAlias, c) {
+		if !chk.bind(cls.PIDAlias, c) {
 			return nil, nil
 		}
 	}
@@ -390,7 +502,7 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 		}
 		c := &table.Cell{T: t}
 		r[cls.PAnchorBinding] = c
-		if !validBinding(cls.PAnchorBinding, c) {
+		if !chk.bind(cls.PAnchorBinding, c) {
 			return nil, nil
 		}
 	}
@@ -405,7 +517,7 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 		}
 		c := &table.Cell{T: t}
 		r[cls.PAnchorAlias] = c
-		if !validBinding(cls.PAnchorAlias, c) {
+		if !chk.bind(cls.PAnchorAlias, c) {
 			return nil, nil
 		}
 	}
@@ -418,7 +530,7 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 			return nil, err
 		}
 		r[cls.OBinding] = c
-		if !validBinding(cls.OBinding, c) {
+		if !chk.bind(cls.OBinding, c) {
 			return nil, nil
 		}
 	}
@@ -429,7 +541,7 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 			return nil, err
 		}
 		r[cls.OAlias] = c
-		if !validBinding(cls.OAlias, c) {
+		if !chk.bind(cls.OAlias, c) {
 			return nil, nil
 		}
 	}
@@ -440,13 +552,12 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 		}
 		c := &table.Cell{S: n.Type().String()}
 		r[cls.OTypeAlias] = c
-		if !validBinding(cls.OTypeAlias, c) {
+		if !chk.bind(cls.OTypeAlias, c) {
 			return nil, nil
 		}
 	}
 	if cls.OIDAlias != "" {
-		n, err := o.Node()
-		if err == nil {
+		if n, ok := o.AsNode(); ok {
 			r[cls.OIDAlias] = &table.Cell{S: n.ID().String()}
 		} else {
 			p, err := o.Predicate()
@@ -455,7 +566,7 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 			}
 			c := &table.Cell{S: string(p.ID())}
 			r[cls.OIDAlias] = c
-			if !validBinding(cls.OIDAlias, c) {
+			if !chk.bind(cls.OIDAlias, c) {
 				return nil, nil
 			}
 		}
@@ -471,7 +582,7 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 		}
 		c := &table.Cell{T: ts}
 		r[cls.OAnchorBinding] = c
-		if !validBinding(cls.OAnchorBinding, c) {
+		if !chk.bind(cls.OAnchorBinding, c) {
 			return nil, nil
 		}
 	}
@@ -486,9 +597,51 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 		}
 		c := &table.Cell{T: ts}
 		r[cls.OAnchorAlias] = c
-		if !validBinding(cls.OAnchorAlias, c) {
+		if !chk.bind(cls.OAnchorAlias, c) {
+			return nil, nil
+		}
+	}
+
+	// Embedded triple related bindings.
+	if e := cls.OEmbedded; e != nil {
+		et, ok := o.AsTriple()
+		if !ok {
+			// Only objects boxing a triple can match the embedded pattern.
+			return nil, nil
+		}
+		if e.S != nil && e.S.String() != et.S().String() {
+			return nil, nil
+		}
+		if e.P != nil && e.P.String() != et.P().String() {
 			return nil, nil
 		}
+		if e.O != nil && e.O.GUID() != et.O().GUID() {
+			return nil, nil
+		}
+		if e.SBinding != "" {
+			c := &table.Cell{N: et.S()}
+			r[e.SBinding] = c
+			if !chk.bind(e.SBinding, c) {
+				return nil, nil
+			}
+		}
+		if e.PBinding != "" {
+			c := &table.Cell{P: et.P()}
+			r[e.PBinding] = c
+			if !chk.bind(e.PBinding, c) {
+				return nil, nil
+			}
+		}
+		if e.OBinding != "" {
+			c, err := objectToCell(et.O())
+			if err != nil {
+				return nil, err
+			}
+			r[e.OBinding] = c
+			if !chk.bind(e.OBinding, c) {
+				return nil, nil
+			}
+		}
 	}
 
 	return r, nil
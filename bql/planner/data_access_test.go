@@ -15,6 +15,9 @@
 package planner
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -78,7 +81,7 @@ func TestSimpleFetch(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tbl, err := simpleFetch([]storage.Graph{g}, cls, &storage.LookupOptions{})
+	tbl, err := simpleFetch(context.Background(), []storage.Graph{g}, cls, &storage.LookupOptions{}, newBindingChecker())
 	if err != nil {
 		t.Errorf("addTriple failed with errorf %v", err)
 	}
@@ -95,6 +98,58 @@ func TestSimpleFetch(t *testing.T) {
 	}
 }
 
+// failingGraph fails all the lookups issued against it.
+type failingGraph struct {
+	storage.Graph
+}
+
+func (g *failingGraph) Triples() (storage.Triples, error) {
+	return nil, errors.New("failingGraph.Triples always fails")
+}
+
+func TestSimpleFetchMultipleGraphs(t *testing.T) {
+	s := memory.NewStore()
+	var gs []storage.Graph
+	for i := 0; i < 2*maxFetchWorkers; i++ {
+		g, err := s.NewGraph(fmt.Sprintf("?g%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.AddTriples(getTestTriples(t)[:i%3+1]); err != nil {
+			t.Fatalf("g.AddTriples(_) failed failed to add test triples with error %v", err)
+		}
+		gs = append(gs, g)
+	}
+	cls := &semantic.GraphClause{
+		GBinding: "?g",
+		SBinding: "?s",
+		PBinding: "?p",
+		OBinding: "?o",
+	}
+	tbl, err := simpleFetch(context.Background(), gs, cls, &storage.LookupOptions{}, newBindingChecker())
+	if err != nil {
+		t.Fatalf("simpleFetch failed with error %v", err)
+	}
+	var got, want []string
+	for i, g := range gs {
+		for j := 0; j < i%3+1; j++ {
+			want = append(want, g.ID())
+		}
+	}
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?g"].S)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("simpleFetch returned rows out of graph order; got %v, want %v", got, want)
+	}
+
+	cls.GBinding = ""
+	gs[3] = &failingGraph{gs[3]}
+	if _, err := simpleFetch(context.Background(), gs, cls, &storage.LookupOptions{}, newBindingChecker()); err == nil {
+		t.Errorf("simpleFetch should have failed when one of the graphs fails")
+	}
+}
+
 func TestAddTriples(t *testing.T) {
 	testBindings := []string{"?s", "?p", "?o"}
 	cls := &semantic.GraphClause{
@@ -114,7 +169,7 @@ func TestAddTriples(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := addTriples(ts, cls, tbl); err != nil {
+	if err := addTriples(context.Background(), ts, cls, tbl, &storage.LookupOptions{}, newBindingChecker()); err != nil {
 		t.Errorf("addTriple failed with errorf %v", err)
 	}
 	if got, want := tbl.NumRows(), len(testTextTriples); got != want {
@@ -226,7 +281,7 @@ func TestFBasicBindings(t *testing.T) {
 		if err != nil {
 			t.Errorf("triple.Parse failed to parse valid triple %q with error %v", entry.t, err)
 		}
-		r, err := tripleToRow(tpl, cls)
+		r, err := tripleToRow(tpl, cls, newBindingChecker())
 		if err != nil {
 			t.Errorf("tripleToRow for triple %q and clasuse %v, failed with error %v", tpl, cls, err)
 		}
@@ -271,7 +326,7 @@ func TestTripleToRowSubjectBindings(t *testing.T) {
 		if err != nil {
 			t.Errorf("triple.Parse failed to parse valid triple %q with error %v", entry.t, err)
 		}
-		r, err := tripleToRow(tpl, entry.cls)
+		r, err := tripleToRow(tpl, entry.cls, newBindingChecker())
 		if err != nil {
 			t.Errorf("tripleToRow for triple %q and clasuse %v, failed with error %v", tpl, entry.cls, err)
 		}
@@ -326,7 +381,7 @@ func TestTripleToRowPredicateBindings(t *testing.T) {
 		if err != nil {
 			t.Errorf("triple.Parse failed to parse valid triple %q with error %v", entry.t, err)
 		}
-		r, err := tripleToRow(tpl, entry.cls)
+		r, err := tripleToRow(tpl, entry.cls, newBindingChecker())
 		if err != nil {
 			t.Errorf("tripleToRow for triple %q and clasuse %v, failed with error %v", tpl, entry.cls, err)
 		}
@@ -398,7 +453,7 @@ func TestTripleToRowObjectBindings(t *testing.T) {
 		if err != nil {
 			t.Errorf("triple.Parse failed to parse valid triple %q with error %v", entry.t, err)
 		}
-		r, err := tripleToRow(tpl, entry.cls)
+		r, err := tripleToRow(tpl, entry.cls, newBindingChecker())
 		if err != nil {
 			t.Errorf("tripleToRow for triple %q and clasuse %v, failed with error %v", tpl, entry.cls, err)
 		}
@@ -473,7 +528,7 @@ func TestTripleToRowObjectBindingsDroping(t *testing.T) {
 		if err != nil {
 			t.Errorf("triple.Parse failed to parse valid triple %q with error %v", entry.t, err)
 		}
-		r, err := tripleToRow(tpl, entry.cls)
+		r, err := tripleToRow(tpl, entry.cls, newBindingChecker())
 		if err != nil {
 			t.Errorf("tripleToRow for triple %q and clasuse %v, failed with error %v", tpl, entry.cls, err)
 		}
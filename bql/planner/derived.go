@@ -0,0 +1,321 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// derivedStore exposes the graphs holding the triples derived by the rules in
+// place of the store ones.
+type derivedStore struct {
+	storage.Store
+	gs map[string]*derivedGraph
+}
+
+// Graph returns the graph with the derived triples, if any.
+func (s *derivedStore) Graph(id string) (storage.Graph, error) {
+	if g, ok := s.gs[id]; ok {
+		return g, nil
+	}
+	return s.Store.Graph(id)
+}
+
+// derivedGraph exposes the triples of a graph along with the ones derived into
+// it by rules, which are kept apart on another graph. Lookups are fully
+// resolved before returning, and the maximum number of elements requested is
+// enforced across both graphs.
+type derivedGraph struct {
+	storage.Graph
+	d storage.Graph
+}
+
+// add adds the triples instantiated by the head clauses for each of the rows
+// that the graph does not hold yet, and returns them.
+func (g *derivedGraph) add(head []*semantic.GraphClause, rws []table.Row) ([]*triple.Triple, error) {
+	seen := make(map[string]bool)
+	var nts []*triple.Triple
+	for _, r := range rws {
+		ts, err := rowTriples(head, r)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range ts {
+			if seen[t.GUID()] {
+				continue
+			}
+			seen[t.GUID()] = true
+			ok, err := g.Exist(t)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				nts = append(nts, t)
+			}
+		}
+	}
+	if len(nts) == 0 {
+		return nil, nil
+	}
+	if err := g.d.AddTriples(nts); err != nil {
+		return nil, err
+	}
+	return nts, nil
+}
+
+// within returns true if another element fits on the lookup results.
+func within(lo *storage.LookupOptions, n int) bool {
+	return lo.MaxElements <= 0 || n < lo.MaxElements
+}
+
+// mergeTriples returns the triples returned by the provided lookups.
+func mergeTriples(lo *storage.LookupOptions, tss ...storage.Triples) storage.Triples {
+	var res []*triple.Triple
+	for _, ts := range tss {
+		for t := range ts {
+			if within(lo, len(res)) {
+				res = append(res, t)
+			}
+		}
+	}
+	out := make(chan *triple.Triple, len(res))
+	defer close(out)
+	for _, t := range res {
+		out <- t
+	}
+	return out
+}
+
+// mergeNodes returns the nodes returned by the provided lookups.
+func mergeNodes(lo *storage.LookupOptions, nss ...storage.Nodes) storage.Nodes {
+	var res []*node.Node
+	for _, ns := range nss {
+		for n := range ns {
+			if within(lo, len(res)) {
+				res = append(res, n)
+			}
+		}
+	}
+	out := make(chan *node.Node, len(res))
+	defer close(out)
+	for _, n := range res {
+		out <- n
+	}
+	return out
+}
+
+// mergePredicates returns the predicates returned by the provided lookups.
+func mergePredicates(lo *storage.LookupOptions, pss ...storage.Predicates) storage.Predicates {
+	var res []*predicate.Predicate
+	for _, ps := range pss {
+		for p := range ps {
+			if within(lo, len(res)) {
+				res = append(res, p)
+			}
+		}
+	}
+	out := make(chan *predicate.Predicate, len(res))
+	defer close(out)
+	for _, p := range res {
+		out <- p
+	}
+	return out
+}
+
+// mergeObjects returns the objects returned by the provided lookups.
+func mergeObjects(lo *storage.LookupOptions, oss ...storage.Objects) storage.Objects {
+	var res []*triple.Object
+	for _, obs := range oss {
+		for o := range obs {
+			if within(lo, len(res)) {
+				res = append(res, o)
+			}
+		}
+	}
+	out := make(chan *triple.Object, len(res))
+	defer close(out)
+	for _, o := range res {
+		out <- o
+	}
+	return out
+}
+
+// Objects returns the objects for the provided subject and predicate.
+func (g *derivedGraph) Objects(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Objects, error) {
+	obs, err := g.Graph.Objects(s, p, lo)
+	if err != nil {
+		return nil, err
+	}
+	dobs, err := g.d.Objects(s, p, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergeObjects(lo, obs, dobs), nil
+}
+
+// Subjects returns the subjects for the provided predicate and object.
+func (g *derivedGraph) Subjects(p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions) (storage.Nodes, error) {
+	ns, err := g.Graph.Subjects(p, o, lo)
+	if err != nil {
+		return nil, err
+	}
+	dns, err := g.d.Subjects(p, o, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergeNodes(lo, ns, dns), nil
+}
+
+// PredicatesForSubject returns the predicates of the provided subject.
+func (g *derivedGraph) PredicatesForSubject(s *node.Node, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ps, err := g.Graph.PredicatesForSubject(s, lo)
+	if err != nil {
+		return nil, err
+	}
+	dps, err := g.d.PredicatesForSubject(s, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergePredicates(lo, ps, dps), nil
+}
+
+// PredicatesForObject returns the predicates of the provided object.
+func (g *derivedGraph) PredicatesForObject(o *triple.Object, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ps, err := g.Graph.PredicatesForObject(o, lo)
+	if err != nil {
+		return nil, err
+	}
+	dps, err := g.d.PredicatesForObject(o, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergePredicates(lo, ps, dps), nil
+}
+
+// PredicatesForSubjectAndObject returns the predicates linking the provided
+// subject and object.
+func (g *derivedGraph) PredicatesForSubjectAndObject(s *node.Node, o *triple.Object, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ps, err := g.Graph.PredicatesForSubjectAndObject(s, o, lo)
+	if err != nil {
+		return nil, err
+	}
+	dps, err := g.d.PredicatesForSubjectAndObject(s, o, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergePredicates(lo, ps, dps), nil
+}
+
+// TriplesForSubject returns the triples of the provided subject.
+func (g *derivedGraph) TriplesForSubject(s *node.Node, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForSubject(s, lo)
+	if err != nil {
+		return nil, err
+	}
+	dts, err := g.d.TriplesForSubject(s, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergeTriples(lo, ts, dts), nil
+}
+
+// TriplesForPredicate returns the triples of the provided predicate.
+func (g *derivedGraph) TriplesForPredicate(p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForPredicate(p, lo)
+	if err != nil {
+		return nil, err
+	}
+	dts, err := g.d.TriplesForPredicate(p, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergeTriples(lo, ts, dts), nil
+}
+
+// TriplesForObject returns the triples of the provided object.
+func (g *derivedGraph) TriplesForObject(o *triple.Object, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForObject(o, lo)
+	if err != nil {
+		return nil, err
+	}
+	dts, err := g.d.TriplesForObject(o, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergeTriples(lo, ts, dts), nil
+}
+
+// TriplesForSubjectAndPredicate returns the triples of the provided subject
+// and predicate.
+func (g *derivedGraph) TriplesForSubjectAndPredicate(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForSubjectAndPredicate(s, p, lo)
+	if err != nil {
+		return nil, err
+	}
+	dts, err := g.d.TriplesForSubjectAndPredicate(s, p, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergeTriples(lo, ts, dts), nil
+}
+
+// TriplesForPredicateAndObject returns the triples of the provided predicate
+// and object.
+func (g *derivedGraph) TriplesForPredicateAndObject(p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForPredicateAndObject(p, o, lo)
+	if err != nil {
+		return nil, err
+	}
+	dts, err := g.d.TriplesForPredicateAndObject(p, o, lo)
+	if err != nil {
+		return nil, err
+	}
+	return mergeTriples(lo, ts, dts), nil
+}
+
+// Exist returns true if the triple is held by the graph or was derived into it.
+func (g *derivedGraph) Exist(t *triple.Triple) (bool, error) {
+	ok, err := g.Graph.Exist(t)
+	if err != nil || ok {
+		return ok, err
+	}
+	return g.d.Exist(t)
+}
+
+// Triples returns the triples of the graph along with the derived ones.
+func (g *derivedGraph) Triples() (storage.Triples, error) {
+	ts, err := g.Graph.Triples()
+	if err != nil {
+		return nil, err
+	}
+	dts, err := g.d.Triples()
+	if err != nil {
+		return nil, err
+	}
+	return mergeTriples(storage.DefaultLookup, ts, dts), nil
+}
+
+// Prefetch passes the hints to the graph, if it supports them.
+func (g *derivedGraph) Prefetch(h *storage.PrefetchHints) error {
+	if pf, ok := g.Graph.(storage.Prefetcher); ok {
+		return pf.Prefetch(h)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// Explainer is implemented by the plans able to describe how they resolve
+// their statement.
+type Explainer interface {
+	// Explain returns a human readable description of the plan. Once the plan
+	// has been excecuted, it also reports the rows dropped by each clause for
+	// assigning conflicting values to the same binding.
+	Explain() string
+}
+
+// Explain returns the graphs queried, the time they are queried as of, the
+// granularity time anchors are matched at, and the clauses of the graph
+// pattern and minus patterns in the order they are processed. Queries known to
+// return no results also report why no data will be retrieved for them.
+func (p *queryPlan) Explain() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "graphs: %s\n", strings.Join(p.grfsNames, ", "))
+	if t := p.stm.AsOf(); t != nil {
+		fmt.Fprintf(&b, "as of: %s\n", t.Format(time.RFC3339Nano))
+	}
+	if g := p.stm.Granularity(); g != predicate.Instant {
+		fmt.Fprintf(&b, "granularity: %s\n", g)
+	}
+	if r := p.emptyReason(); r != "" {
+		fmt.Fprintf(&b, "empty results: %s\n", r)
+	}
+	p.explainClauses(&b, "clause")
+	for i, mp := range p.mps {
+		mp.explainClauses(&b, fmt.Sprintf("minus %d clause", i+1))
+	}
+	return b.String()
+}
+
+// explainClauses writes the clauses of the plan and the binding conflicts
+// recorded for each of them.
+func (p *queryPlan) explainClauses(b *bytes.Buffer, name string) {
+	for i, cls := range p.cls {
+		if cls == nil || cls.IsEmpty() {
+			continue
+		}
+		fmt.Fprintf(b, "%s %d: %s\n", name, i+1, clauseString(cls))
+		if i < len(p.chks) && p.chks[i] != nil && p.chks[i].dropped() > 0 {
+			fmt.Fprintf(b, "  binding conflicts: %s\n", p.chks[i])
+		}
+	}
+}
+
+// clauseString returns the subject, predicate, and object of the clause,
+// using the binding names for the unspecified ones.
+func clauseString(cls *semantic.GraphClause) string {
+	var ss []string
+	if cls.Graph != "" {
+		ss = append(ss, "graph "+cls.Graph)
+	} else if cls.GBinding != "" {
+		ss = append(ss, "graph "+cls.GBinding)
+	}
+	switch {
+	case cls.S != nil:
+		ss = append(ss, cls.S.String())
+	case cls.SBinding != "":
+		ss = append(ss, cls.SBinding)
+	default:
+		ss = append(ss, "_")
+	}
+	switch {
+	case cls.P != nil && cls.PReflexive:
+		ss = append(ss, cls.P.String()+"*")
+	case cls.P != nil && cls.PTransitive:
+		ss = append(ss, cls.P.String()+"+")
+	case cls.P != nil:
+		ss = append(ss, cls.P.String())
+	case cls.PID != "":
+		ss = append(ss, fmt.Sprintf("%q@[...]", cls.PID))
+	case cls.PBinding != "":
+		ss = append(ss, cls.PBinding)
+	default:
+		ss = append(ss, "_")
+	}
+	if cls.PLatest {
+		ss = append(ss, "latest")
+	}
+	switch {
+	case cls.O != nil:
+		ss = append(ss, cls.O.String())
+	case cls.OEmbedded != nil:
+		ss = append(ss, "{"+clauseString(cls.OEmbedded)+"}")
+	case cls.OID != "":
+		ss = append(ss, fmt.Sprintf("%q@[...]", cls.OID))
+	case cls.OBinding != "":
+		ss = append(ss, cls.OBinding)
+	default:
+		ss = append(ss, "_")
+	}
+	return strings.Join(ss, " ")
+}
@@ -0,0 +1,131 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q: `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`,
+			want: []string{
+				"graphs: ?test",
+				`clause 1: ?s "parent_of"@[] ?o`,
+			},
+		},
+		{
+			q: `select ?s from ?test where {?s "parent_of"@[] ?s};`,
+			want: []string{
+				"graphs: ?test",
+				`clause 1: ?s "parent_of"@[] ?s`,
+				"  binding conflicts: ?s=4",
+			},
+		},
+		{
+			q: `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} minus {?s "parent_of"@[] /u<mary>};`,
+			want: []string{
+				"graphs: ?test",
+				`clause 1: ?s "parent_of"@[] ?o`,
+				`minus 1 clause 1: ?s "parent_of"@[] /u<mary>`,
+			},
+		},
+	}
+	for _, entry := range testTable {
+		s := populateTestStore(t)
+		pln, err := New(s, parseTestStatement(t, entry.q))
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan for %q with error %v", entry.q, err)
+		}
+		if _, err := pln.Excecute(context.Background()); err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		e, ok := pln.(Explainer)
+		if !ok {
+			t.Fatalf("planner.New should return a plan implementing Explainer for query %q", entry.q)
+		}
+		if got, want := e.Explain(), strings.Join(entry.want, "\n")+"\n"; got != want {
+			t.Errorf("Explain returned the wrong description for query %q; got\n%s\nwant\n%s", entry.q, got, want)
+		}
+	}
+}
+
+func TestStaticallyEmptyQueries(t *testing.T) {
+	testTable := []struct {
+		q      string
+		graph  string
+		reason string
+	}{
+		{
+			q:      `select ?s, ?o from ?test where {?s "bought"@[2016-01-01T00:00:00-08:00, 2016-02-01T00:00:00-08:00] ?o} after "x"@[2016-03-01T00:00:00-08:00];`,
+			reason: `empty results: clause ?s "bought"@[...] ?o has contradictory time bounds`,
+		},
+		{
+			q:      `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} having "1"^^type:int64 > "2"^^type:int64;`,
+			reason: "empty results: having clause is always false",
+		},
+		{
+			q:      `select ?s, ?o from ?test where {graph ?test {?s "parent_of"@[] ?o}};`,
+			graph:  "?other",
+			reason: `empty results: clause graph ?other ?s "parent_of"@[] ?o is scoped to graph ?other which is not queried`,
+		},
+	}
+	for _, entry := range testTable {
+		s := populateTestStore(t)
+		pln, err := New(s, parseTestStatement(t, entry.q))
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan for %q with error %v", entry.q, err)
+		}
+		if entry.graph != "" {
+			// BQL only scopes clauses to queried graphs, but statements may be
+			// built programatically.
+			pln.(*queryPlan).cls[0].Graph = entry.graph
+		}
+		if got, want := pln.(Explainer).Explain(), entry.reason; !strings.Contains(got, want) {
+			t.Errorf("Explain for query %q should contain %q; got\n%s", entry.q, want, got)
+		}
+		tbl, err := pln.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		if got, want := tbl.NumRows(), 0; got != want {
+			t.Errorf("planner.Excecute returned the wrong number of rows for query %q; got %d, want %d", entry.q, got, want)
+		}
+		if got, want := len(tbl.Bindings()), 2; got != want {
+			t.Errorf("planner.Excecute returned the wrong number of bindings for query %q; got %d, want %d", entry.q, got, want)
+		}
+		// No data should have been retrieved.
+		if p := pln.(Profiler).Profile(); len(p.Clauses) != 0 {
+			t.Errorf("planner.Excecute should not have processed any clause for query %q; got %v", entry.q, p)
+		}
+	}
+	// Constant having clauses that hold and global time bounds on immutable
+	// predicates do not prevent retrieving data.
+	for _, q := range []string{
+		`select ?s, ?o from ?test where {?s "parent_of"@[] ?o} having "1"^^type:int64 < "2"^^type:int64;`,
+		`select ?s, ?o from ?test where {?s "parent_of"@[] ?o} before "x"@[2000-01-01T00:00:00-08:00];`,
+	} {
+		s := populateTestStore(t)
+		if got, want := executeTestStatement(t, s, q), 4; got != want {
+			t.Errorf("planner.Excecute returned the wrong number of rows for query %q; got %d, want %d", q, got, want)
+		}
+	}
+}
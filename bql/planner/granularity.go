@@ -0,0 +1,118 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"time"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// anchorPeriod returns the bounds of the periods of the provided granularity
+// covered by the time anchor of the predicate. It returns false for immutable
+// predicates and validity intervals.
+func anchorPeriod(p *predicate.Predicate, g predicate.Granularity) (*time.Time, *time.Time, bool) {
+	if p == nil || p.Type() != predicate.Temporal {
+		return nil, nil, false
+	}
+	if _, _, ok := p.Interval(); ok {
+		return nil, nil, false
+	}
+	first, last, err := p.TimeSpan()
+	if err != nil {
+		return nil, nil, false
+	}
+	lower, upper := g.Widen(&first, &last)
+	return lower, upper, true
+}
+
+// coarsenClause returns a copy of the clause whose temporal predicates, on
+// either the predicate or the object position, are replaced by their ID and
+// the bounds of the periods of the provided granularity covering their time
+// anchor. This way, the clause matches any triple anchored within the same
+// period. The clause is returned untouched if there is nothing to replace.
+func coarsenClause(cls *semantic.GraphClause, g predicate.Granularity) *semantic.GraphClause {
+	if g == predicate.Instant {
+		return cls
+	}
+	ncls, changed := *cls, false
+	if lower, upper, ok := anchorPeriod(cls.P, g); ok && !cls.PTransitive {
+		ncls.P, ncls.PID, ncls.PTemporal = nil, string(cls.P.ID()), true
+		ncls.PLowerBound, ncls.PUpperBound = lower, upper
+		changed = true
+	}
+	if cls.O != nil {
+		if op, ok := cls.O.AsPredicate(); ok {
+			if lower, upper, ok := anchorPeriod(op, g); ok {
+				ncls.O, ncls.OID, ncls.OTemporal = nil, string(op.ID()), true
+				ncls.OLowerBound, ncls.OUpperBound = lower, upper
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return cls
+	}
+	return &ncls
+}
+
+// coarsenTriple returns the triple with the time anchors of its predicate and
+// its predicate object reduced to the provided granularity, so triples
+// anchored within the same period become equal.
+func coarsenTriple(t *triple.Triple, g predicate.Granularity) *triple.Triple {
+	if g == predicate.Instant {
+		return t
+	}
+	p, o := t.P().Coarsen(g), t.O()
+	if op, ok := o.AsPredicate(); ok {
+		if cp := op.Coarsen(g); cp != op {
+			o = triple.NewPredicateObject(cp)
+		}
+	}
+	if p == t.P() && o == t.O() {
+		return t
+	}
+	nt, err := triple.New(t.S(), p, o)
+	if err != nil {
+		return t
+	}
+	return nt
+}
+
+// existCoarsened returns true if the graph contains a triple equal to the
+// provided one once the time anchors of both are reduced to the provided
+// granularity.
+func existCoarsened(g storage.Graph, t *triple.Triple, gr predicate.Granularity) (bool, error) {
+	_, isPredicate := t.O().AsPredicate()
+	if gr == predicate.Instant || t.P().Type() != predicate.Temporal && !isPredicate {
+		return g.Exist(t)
+	}
+	want := coarsenTriple(t, gr)
+	ts, err := g.TriplesForSubject(t.S(), storage.DefaultLookup)
+	if err != nil {
+		return false, err
+	}
+	found := false
+	for ct := range ts {
+		// The channel is drained to release the resources held by the graph.
+		if !found && coarsenTriple(ct, gr).GUID() == want.GUID() {
+			found = true
+		}
+	}
+	return found, nil
+}
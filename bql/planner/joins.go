@@ -0,0 +1,469 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/badwolf/bql/table"
+)
+
+// fetchOperator returns the rows of the data retrieved for the first clause of
+// a graph pattern. The data is only retrieved once the first row is pulled,
+// and it is accounted for on the memory budget until the operator is closed.
+type fetchOperator struct {
+	ctx   context.Context
+	fetch func() (*table.Table, error)
+	bgt   *memoryBudget
+	scan  *scanOperator
+}
+
+func (o *fetchOperator) next() (table.Row, error) {
+	if o.scan == nil {
+		tbl, err := o.fetch()
+		if err != nil {
+			return nil, err
+		}
+		size := o.bgt.sizeOfTable(tbl)
+		if err := o.bgt.require(size); err != nil {
+			return nil, err
+		}
+		o.scan = &scanOperator{ctx: o.ctx, it: tbl.Iterator(scanChunkSize), bgt: o.bgt, size: size}
+	}
+	return o.scan.next()
+}
+
+func (o *fetchOperator) close() error {
+	if o.scan != nil {
+		return o.scan.close()
+	}
+	return nil
+}
+
+// productOperator returns the cartesian product of its input rows and the data
+// retrieved for a clause that shares no bindings with them. Only the data of
+// the clause is held, and it is spilled to disk if it has more rows than the
+// buffer allows or it does not fit on the memory budget; in that case it is
+// read back from disk for each input row. The data is only retrieved once the
+// first input row is pulled.
+type productOperator struct {
+	ctx     context.Context
+	in      operator
+	fetch   func() (*table.Table, error)
+	buffer  int
+	bgt     *memoryBudget
+	prof    *ClauseProfile
+	fetched bool
+	rows    []table.Row
+	size    int64
+	run     *table.Run
+	cur     table.Row
+	i       int
+}
+
+// load retrieves the data of the clause, spilling it to disk if needed.
+func (o *productOperator) load() error {
+	tbl, err := o.fetch()
+	if err != nil {
+		return err
+	}
+	n := tbl.NumRows()
+	if n == 0 {
+		return nil
+	}
+	size := o.bgt.sizeOfTable(tbl)
+	if (o.buffer <= 0 || n <= o.buffer) && o.bgt.reserve(size) {
+		o.rows, o.size = tbl.Rows(), size
+		return nil
+	}
+	run, err := table.NewRun("badwolf-product-")
+	if err != nil {
+		return err
+	}
+	o.run = run
+	it := tbl.Iterator(scanChunkSize)
+	for {
+		rs, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if len(rs) == 0 {
+			break
+		}
+		for _, r := range rs {
+			if err := run.Write(r); err != nil {
+				return err
+			}
+		}
+	}
+	o.prof.SpilledRows += int64(n)
+	return nil
+}
+
+func (o *productOperator) next() (table.Row, error) {
+	for {
+		if o.cur == nil {
+			if err := o.ctx.Err(); err != nil {
+				return nil, err
+			}
+			r, err := o.in.next()
+			if r == nil || err != nil {
+				return nil, err
+			}
+			if !o.fetched {
+				// The data is only retrieved if there are input rows.
+				o.fetched = true
+				if err := o.load(); err != nil {
+					return nil, err
+				}
+			}
+			if o.run == nil && len(o.rows) == 0 {
+				// The product of no rows is empty.
+				return nil, nil
+			}
+			o.cur, o.i = r, 0
+			if o.run != nil {
+				if err := o.run.Rewind(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		var (
+			r   table.Row
+			err error
+		)
+		switch {
+		case o.run != nil:
+			r, err = o.run.Read()
+		case o.i < len(o.rows):
+			r = o.rows[o.i]
+			o.i++
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			o.cur = nil
+			continue
+		}
+		return table.MergeRows([]table.Row{o.cur, r}), nil
+	}
+}
+
+func (o *productOperator) close() error {
+	o.bgt.release(o.size)
+	o.rows, o.size, o.cur = nil, 0, nil
+	err := o.in.close()
+	if o.run != nil {
+		if rerr := o.run.Close(); rerr != nil && err == nil {
+			err = rerr
+		}
+		o.run = nil
+	}
+	return err
+}
+
+// hashJoinOperator joins its input rows with the data retrieved for a clause
+// that does not depend on the values of each row. The data of the clause is
+// hashed on the bindings shared with the input rows, which are then probed
+// against it as they are pulled. Rows are compatible if they agree on the
+// values of the shared bindings, where null cells agree with any value. The
+// data is only retrieved once the first row is pulled, and it needs to fit on
+// the memory budget.
+type hashJoinOperator struct {
+	ctx     context.Context
+	in      operator
+	fetch   func() (*table.Table, error)
+	on      []string
+	bgt     *memoryBudget
+	fetched bool
+	size    int64
+	all     []table.Row
+	idx     map[string][]table.Row
+	nulls   []table.Row
+	pending []table.Row
+}
+
+// joinKey returns the key used to hash the row on the provided bindings. It
+// returns false if the row holds a null cell for any of them.
+func joinKey(r table.Row, on []string) (string, bool) {
+	for _, b := range on {
+		if c, ok := r[b]; !ok || c.IsNull() {
+			return "", false
+		}
+	}
+	return table.RowKey(r, on), true
+}
+
+// compatibleOn returns true if both rows agree on the values of the provided
+// bindings. Null cells agree with any value.
+func compatibleOn(r1, r2 table.Row, on []string) bool {
+	for _, b := range on {
+		c1, c2 := r1[b], r2[b]
+		if c1.IsNull() || c2.IsNull() {
+			continue
+		}
+		if !c1.Equal(c2) {
+			return false
+		}
+	}
+	return true
+}
+
+// load retrieves the data of the clause and hashes it.
+func (o *hashJoinOperator) load() error {
+	tbl, err := o.fetch()
+	if err != nil {
+		return err
+	}
+	size := o.bgt.sizeOfTable(tbl)
+	if err := o.bgt.require(size); err != nil {
+		return err
+	}
+	o.size, o.all = size, tbl.Rows()
+	o.idx = make(map[string][]table.Row)
+	for _, r := range o.all {
+		if k, ok := joinKey(r, o.on); ok {
+			o.idx[k] = append(o.idx[k], r)
+		} else {
+			o.nulls = append(o.nulls, r)
+		}
+	}
+	return nil
+}
+
+func (o *hashJoinOperator) next() (table.Row, error) {
+	if !o.fetched {
+		o.fetched = true
+		if err := o.load(); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.all) == 0 {
+		return nil, nil
+	}
+	for len(o.pending) == 0 {
+		if err := o.ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, err := o.in.next()
+		if r == nil || err != nil {
+			return nil, err
+		}
+		k, ok := joinKey(r, o.on)
+		if !ok {
+			// Rows with null join cells need to be checked against all rows.
+			for _, r2 := range o.all {
+				if compatibleOn(r, r2, o.on) {
+					o.pending = append(o.pending, table.MergeRows([]table.Row{r, r2}))
+				}
+			}
+			continue
+		}
+		for _, r2 := range o.idx[k] {
+			o.pending = append(o.pending, table.MergeRows([]table.Row{r, r2}))
+		}
+		for _, r2 := range o.nulls {
+			if compatibleOn(r, r2, o.on) {
+				o.pending = append(o.pending, table.MergeRows([]table.Row{r, r2}))
+			}
+		}
+	}
+	r := o.pending[0]
+	o.pending[0] = nil
+	o.pending = o.pending[1:]
+	return r, nil
+}
+
+func (o *hashJoinOperator) close() error {
+	o.bgt.release(o.size)
+	o.size, o.all, o.idx, o.nulls, o.pending = 0, nil, nil, nil, nil
+	return o.in.close()
+}
+
+// expandOperator returns the rows produced by the provided function for each
+// of its input rows, such as the ones resulting from looking up the data of a
+// clause after substituting the values bound on each row, or the row itself
+// if the triple it fully specifies exists. Only the rows produced for the
+// current input row are held.
+type expandOperator struct {
+	ctx     context.Context
+	in      operator
+	f       func(table.Row) ([]table.Row, error)
+	pending []table.Row
+}
+
+func (o *expandOperator) next() (table.Row, error) {
+	for len(o.pending) == 0 {
+		if err := o.ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, err := o.in.next()
+		if r == nil || err != nil {
+			return nil, err
+		}
+		if o.pending, err = o.f(r); err != nil {
+			return nil, err
+		}
+	}
+	r := o.pending[0]
+	o.pending[0] = nil
+	o.pending = o.pending[1:]
+	return r, nil
+}
+
+func (o *expandOperator) close() error {
+	o.pending = nil
+	return o.in.close()
+}
+
+// minusOperator only returns the input rows not removed by subtracting the
+// solutions of the minus patterns of a query. The solutions are resolved once
+// the first row is pulled, and the bytes reserved for them on the memory
+// budget are released once the operator is closed.
+type minusOperator struct {
+	in    operator
+	bs    []string
+	build func() ([]*table.Table, int64, error)
+	bgt   *memoryBudget
+	built bool
+	size  int64
+	tbls  []*table.Table
+}
+
+func (o *minusOperator) next() (table.Row, error) {
+	if !o.built {
+		o.built = true
+		tbls, size, err := o.build()
+		if err != nil {
+			return nil, err
+		}
+		o.tbls, o.size = tbls, size
+	}
+	for {
+		r, err := o.in.next()
+		if r == nil || err != nil {
+			return nil, err
+		}
+		subtracted := false
+		for _, t := range o.tbls {
+			if t.Subtracts(o.bs, r) {
+				subtracted = true
+				break
+			}
+		}
+		if !subtracted {
+			return r, nil
+		}
+	}
+}
+
+func (o *minusOperator) close() error {
+	o.bgt.release(o.size)
+	o.size, o.tbls = 0, nil
+	return o.in.close()
+}
+
+// groupOperator holds all its input rows on the plan table to group them as
+// requested by the plan statement, and returns the resulting rows. The input
+// rows are only pulled once the first row is pulled from it.
+type groupOperator struct {
+	ctx  context.Context
+	in   operator
+	p    *queryPlan
+	size int64
+	scan *scanOperator
+}
+
+func (o *groupOperator) next() (table.Row, error) {
+	if o.scan == nil {
+		o.scan = &scanOperator{ctx: o.ctx}
+		size, err := o.p.hold(o.in)
+		o.in = nil
+		if err != nil {
+			return nil, err
+		}
+		err = o.p.group()
+		o.p.bgt.release(size)
+		if err != nil {
+			return nil, err
+		}
+		// Only the grouped rows are held from now on.
+		o.size = o.p.bgt.sizeOfTable(o.p.tbl)
+		if err := o.p.bgt.require(o.size); err != nil {
+			o.size = 0
+			return nil, err
+		}
+		o.scan.it = o.p.tbl.Iterator(scanChunkSize)
+	}
+	return o.scan.next()
+}
+
+func (o *groupOperator) close() error {
+	o.p.bgt.release(o.size)
+	o.size = 0
+	if o.in != nil {
+		return o.in.close()
+	}
+	return nil
+}
+
+// clauseOperator profiles the operator resolving a clause of a graph pattern,
+// and fails once it produces more rows than the limits allow. The time spent
+// and the rows fetched by the operators resolving the previous clauses, if
+// any, are not accounted for.
+type clauseOperator struct {
+	in      operator
+	prev    *clauseOperator
+	p       *queryPlan
+	prof    *ClauseProfile
+	total   time.Duration
+	fetched int64
+}
+
+func (o *clauseOperator) next() (table.Row, error) {
+	var (
+		prevTotal   time.Duration
+		prevFetched int64
+	)
+	if o.prev != nil {
+		prevTotal, prevFetched = o.prev.total, o.prev.fetched
+	}
+	start, fetched := time.Now(), o.p.fetched
+	r, err := o.in.next()
+	d, f := time.Since(start), o.p.fetched-fetched
+	o.total += d
+	o.fetched += f
+	if o.prev != nil {
+		d -= o.prev.total - prevTotal
+		f -= o.prev.fetched - prevFetched
+		o.prof.InputRows = o.prev.prof.OutputRows
+	}
+	o.prof.Duration += d
+	o.prof.RowsFetched += f
+	if r == nil || err != nil {
+		return nil, err
+	}
+	o.prof.OutputRows++
+	if err := o.p.checkIntermediateRows(int(o.prof.OutputRows)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (o *clauseOperator) close() error {
+	return o.in.close()
+}
@@ -0,0 +1,193 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/badwolf/bql/table"
+)
+
+// testJoinTable returns a table with the provided bindings and one row for
+// each list of values, where empty values are left null.
+func testJoinTable(t *testing.T, bs []string, vss ...[]string) *table.Table {
+	tbl, err := table.New(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vs := range vss {
+		r := make(table.Row)
+		for i, v := range vs {
+			if v != "" {
+				r[bs[i]] = &table.Cell{S: v}
+			}
+		}
+		tbl.AddRow(r)
+	}
+	return tbl
+}
+
+// joinedValues returns the sorted values of the rows on the provided
+// bindings, joined by commas.
+func joinedValues(rws []table.Row, bs ...string) []string {
+	var res []string
+	for _, r := range rws {
+		v := ""
+		for i, b := range bs {
+			if i > 0 {
+				v += ","
+			}
+			if c, ok := r[b]; ok {
+				v += c.String()
+			}
+		}
+		res = append(res, v)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestProductOperator(t *testing.T) {
+	ctx := context.Background()
+	left := testJoinTable(t, []string{"?a"}, []string{"1"}, []string{"2"})
+	right := testJoinTable(t, []string{"?b"}, []string{"x"}, []string{"y"}, []string{"z"})
+	want := []string{"1,x", "1,y", "1,z", "2,x", "2,y", "2,z"}
+	testTable := []struct {
+		buffer  int
+		bgt     *memoryBudget
+		spilled bool
+	}{
+		{buffer: 0},
+		{buffer: 3},
+		{buffer: 2, spilled: true},
+		{bgt: &memoryBudget{max: 1}, spilled: true},
+	}
+	for _, entry := range testTable {
+		prof := &ClauseProfile{}
+		op := &productOperator{
+			ctx:    ctx,
+			in:     &scanOperator{rows: left.Rows()},
+			fetch:  func() (*table.Table, error) { return right, nil },
+			buffer: entry.buffer,
+			bgt:    entry.bgt,
+			prof:   prof,
+		}
+		if got := joinedValues(drainOperator(t, op), "?a", "?b"); !reflect.DeepEqual(got, want) {
+			t.Errorf("productOperator with buffer %d returned the wrong rows; got %v, want %v", entry.buffer, got, want)
+		}
+		if got, want := prof.SpilledRows > 0, entry.spilled; got != want {
+			t.Errorf("productOperator with buffer %d spilled %v; want %v", entry.buffer, got, want)
+		}
+		if entry.bgt != nil && entry.bgt.used != 0 {
+			t.Errorf("productOperator did not release %d bytes of the memory budget", entry.bgt.used)
+		}
+	}
+}
+
+func TestProductOperatorEmptyInput(t *testing.T) {
+	fetched := false
+	op := &productOperator{
+		ctx: context.Background(),
+		in:  &scanOperator{},
+		fetch: func() (*table.Table, error) {
+			fetched = true
+			return testJoinTable(t, []string{"?b"}, []string{"x"}), nil
+		},
+		prof: &ClauseProfile{},
+	}
+	if got := drainOperator(t, op); len(got) != 0 {
+		t.Errorf("productOperator returned rows for an empty input; got %v", got)
+	}
+	if fetched {
+		t.Errorf("productOperator should not retrieve the clause data for an empty input")
+	}
+}
+
+func TestHashJoinOperator(t *testing.T) {
+	left := testJoinTable(t, []string{"?a", "?k"}, []string{"1", "x"}, []string{"2", "y"}, []string{"3", ""}, []string{"4", "w"})
+	right := testJoinTable(t, []string{"?k", "?b"}, []string{"x", "p"}, []string{"x", "q"}, []string{"", "r"}, []string{"z", "s"})
+	op := &hashJoinOperator{
+		ctx:   context.Background(),
+		in:    &scanOperator{rows: left.Rows()},
+		fetch: func() (*table.Table, error) { return right, nil },
+		on:    []string{"?k"},
+	}
+	// Null cells agree with any value.
+	want := []string{"1,x,p", "1,x,q", "1,x,r", "2,y,r", "3,x,p", "3,x,q", "3,,r", "3,z,s", "4,w,r"}
+	sort.Strings(want)
+	if got := joinedValues(drainOperator(t, op), "?a", "?k", "?b"); !reflect.DeepEqual(got, want) {
+		t.Errorf("hashJoinOperator returned the wrong rows; got %v, want %v", got, want)
+	}
+}
+
+func TestHashJoinOperatorBudget(t *testing.T) {
+	op := &hashJoinOperator{
+		ctx:   context.Background(),
+		in:    &scanOperator{rows: testJoinTable(t, []string{"?k"}, []string{"x"}).Rows()},
+		fetch: func() (*table.Table, error) { return testJoinTable(t, []string{"?k"}, []string{"x"}), nil },
+		on:    []string{"?k"},
+		bgt:   &memoryBudget{max: 1},
+	}
+	if _, err := op.next(); err == nil {
+		t.Errorf("hashJoinOperator should have failed to hold data exceeding the memory budget")
+	} else if le, ok := err.(*LimitError); !ok || le.Limit != "MaxMemoryBytes" {
+		t.Errorf("hashJoinOperator should have failed with a MaxMemoryBytes limit error; got %v", err)
+	}
+}
+
+func TestExpandOperator(t *testing.T) {
+	in := testJoinTable(t, []string{"?a"}, []string{"1"}, []string{"2"}, []string{"3"})
+	op := &expandOperator{
+		ctx: context.Background(),
+		in:  &scanOperator{rows: in.Rows()},
+		f: func(r table.Row) ([]table.Row, error) {
+			// Row "1" produces no rows, row "2" one, and row "3" two.
+			var rws []table.Row
+			for i := 0; i < int(r["?a"].S[0]-'1'); i++ {
+				rws = append(rws, r)
+			}
+			return rws, nil
+		},
+	}
+	if got, want := joinedValues(drainOperator(t, op), "?a"), []string{"2", "3", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expandOperator returned the wrong rows; got %v, want %v", got, want)
+	}
+}
+
+func TestMinusOperator(t *testing.T) {
+	in := testJoinTable(t, []string{"?a", "?b"}, []string{"1", "x"}, []string{"2", "y"}, []string{"3", "z"})
+	bgt := &memoryBudget{max: 100}
+	op := &minusOperator{
+		in: &scanOperator{rows: in.Rows()},
+		bs: []string{"?a", "?b"},
+		build: func() ([]*table.Table, int64, error) {
+			bgt.used += 10
+			return []*table.Table{
+				testJoinTable(t, []string{"?a"}, []string{"1"}),
+				testJoinTable(t, []string{"?b", "?c"}, []string{"z", "w"}),
+			}, 10, nil
+		},
+		bgt: bgt,
+	}
+	if got, want := joinedValues(drainOperator(t, op), "?a"), []string{"2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("minusOperator returned the wrong rows; got %v, want %v", got, want)
+	}
+	if bgt.used != 0 {
+		t.Errorf("minusOperator did not release %d bytes of the memory budget", bgt.used)
+	}
+}
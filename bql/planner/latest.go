@@ -0,0 +1,342 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// statementGraph returns the graph with the provided id. Graphs queried by
+// statements with an as of clause only return the facts holding at its time.
+func statementGraph(store storage.Store, stm *semantic.Statement, id string) (storage.Graph, error) {
+	g, err := store.Graph(id)
+	if err != nil {
+		return nil, err
+	}
+	if t := stm.AsOf(); t != nil {
+		lg := newLatestGraph(g, nil, t)
+		lg.current = true
+		return lg, nil
+	}
+	return g, nil
+}
+
+// latestGraphs returns the provided graphs restricted to the latest temporal
+// triples within the time bounds of the lookup options.
+func latestGraphs(gs []storage.Graph, lo *storage.LookupOptions) []storage.Graph {
+	var lgs []storage.Graph
+	lower, upper := lo.Granularity.Widen(lo.LowerAnchor, lo.UpperAnchor)
+	for _, g := range gs {
+		lgs = append(lgs, newLatestGraph(g, lower, upper))
+	}
+	return lgs
+}
+
+// latestGraph restricts the temporal triples of a graph to the latest ones:
+// for each subject and predicate ID, only the triples with the latest time
+// anchor among the ones within the time bounds hold. If current is set,
+// triples whose validity interval ended by the upper bound do not hold either,
+// since they no longer hold at that time. Immutable triples always hold.
+// Lookups are fully resolved before returning, and the maximum number of
+// elements requested is enforced once the triples not holding are dropped.
+type latestGraph struct {
+	storage.Graph
+	lower, upper *time.Time
+	current      bool
+
+	mu sync.Mutex
+	// latest contains the latest time anchor within the bounds of the temporal
+	// triples of each subject, by predicate ID.
+	latest map[string]map[predicate.ID]time.Time
+}
+
+// newLatestGraph returns the provided graph restricted to the latest temporal
+// triples within the provided bounds. Nil bounds are not enforced.
+func newLatestGraph(g storage.Graph, lower, upper *time.Time) *latestGraph {
+	return &latestGraph{
+		Graph:  g,
+		lower:  lower,
+		upper:  upper,
+		latest: make(map[string]map[predicate.ID]time.Time),
+	}
+}
+
+// holds returns true if the triple with the provided subject and predicate
+// holds on the graph, that is, if it is immutable or one of the latest ones.
+func (g *latestGraph) holds(s *node.Node, p *predicate.Predicate) (bool, error) {
+	if p.Type() != predicate.Temporal {
+		return true, nil
+	}
+	if !p.WithinBounds(g.lower, g.upper) {
+		return false, nil
+	}
+	if _, end, ok := p.Interval(); ok && g.current && g.upper != nil && !end.After(*g.upper) {
+		return false, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	k := s.String()
+	l, ok := g.latest[k]
+	if !ok {
+		ts, err := g.Graph.TriplesForSubject(s, &storage.LookupOptions{LowerAnchor: g.lower, UpperAnchor: g.upper})
+		if err != nil {
+			return false, err
+		}
+		l = make(map[predicate.ID]time.Time)
+		for t := range ts {
+			if t.P().Type() != predicate.Temporal || !t.P().WithinBounds(g.lower, g.upper) {
+				continue
+			}
+			a, _ := t.P().TimeAnchor()
+			if lt, ok := l[t.P().ID()]; !ok || a.After(lt) {
+				l[t.P().ID()] = *a
+			}
+		}
+		g.latest[k] = l
+	}
+	ta, _ := p.TimeAnchor()
+	return ta.Equal(l[p.ID()]), nil
+}
+
+// unbounded returns a copy of the provided lookup options without a maximum
+// number of elements.
+func unbounded(lo *storage.LookupOptions) *storage.LookupOptions {
+	nlo := *lo
+	nlo.MaxElements = 0
+	return &nlo
+}
+
+// triples returns the triples holding on the graph.
+func (g *latestGraph) triples(ts storage.Triples, lo *storage.LookupOptions) (storage.Triples, error) {
+	var res []*triple.Triple
+	for t := range ts {
+		res = append(res, t)
+	}
+	out := make(chan *triple.Triple, len(res))
+	defer close(out)
+	for _, t := range res {
+		if lo.MaxElements > 0 && len(out) >= lo.MaxElements {
+			break
+		}
+		ok, err := g.holds(t.S(), t.P())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out <- t
+		}
+	}
+	return out, nil
+}
+
+// predicates returns the predicates of the provided triples holding on the
+// graph, once each.
+func (g *latestGraph) predicates(ts storage.Triples, lo *storage.LookupOptions) (storage.Predicates, error) {
+	hts, err := g.triples(ts, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ps []*predicate.Predicate
+	for t := range hts {
+		if k := t.P().String(); !seen[k] {
+			seen[k] = true
+			ps = append(ps, t.P())
+		}
+	}
+	out := make(chan *predicate.Predicate, len(ps))
+	defer close(out)
+	for _, p := range ps {
+		if lo.MaxElements > 0 && len(out) >= lo.MaxElements {
+			break
+		}
+		out <- p
+	}
+	return out, nil
+}
+
+// Objects returns the objects for the provided subject and predicate, if the
+// predicate holds for the subject on the graph.
+func (g *latestGraph) Objects(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Objects, error) {
+	ok, err := g.holds(s, p)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		out := make(chan *triple.Object)
+		close(out)
+		return out, nil
+	}
+	return g.Graph.Objects(s, p, lo)
+}
+
+// Subjects returns the subjects for the provided predicate and object whose
+// triples hold on the graph.
+func (g *latestGraph) Subjects(p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions) (storage.Nodes, error) {
+	ss, err := g.Graph.Subjects(p, o, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	var res []*node.Node
+	for s := range ss {
+		res = append(res, s)
+	}
+	out := make(chan *node.Node, len(res))
+	defer close(out)
+	for _, s := range res {
+		if lo.MaxElements > 0 && len(out) >= lo.MaxElements {
+			break
+		}
+		ok, err := g.holds(s, p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out <- s
+		}
+	}
+	return out, nil
+}
+
+// PredicatesForSubject returns the predicates holding for the provided subject
+// on the graph.
+func (g *latestGraph) PredicatesForSubject(s *node.Node, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ts, err := g.Graph.TriplesForSubject(s, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return g.predicates(ts, lo)
+}
+
+// PredicatesForObject returns the predicates of the triples with the provided
+// object holding on the graph.
+func (g *latestGraph) PredicatesForObject(o *triple.Object, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ts, err := g.Graph.TriplesForObject(o, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return g.predicates(ts, lo)
+}
+
+// PredicatesForSubjectAndObject returns the predicates holding for the
+// provided subject and object on the graph.
+func (g *latestGraph) PredicatesForSubjectAndObject(s *node.Node, o *triple.Object, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ps, err := g.Graph.PredicatesForSubjectAndObject(s, o, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	var res []*predicate.Predicate
+	for p := range ps {
+		res = append(res, p)
+	}
+	out := make(chan *predicate.Predicate, len(res))
+	defer close(out)
+	for _, p := range res {
+		if lo.MaxElements > 0 && len(out) >= lo.MaxElements {
+			break
+		}
+		ok, err := g.holds(s, p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out <- p
+		}
+	}
+	return out, nil
+}
+
+// TriplesForSubject returns the triples of the provided subject holding on the
+// graph.
+func (g *latestGraph) TriplesForSubject(s *node.Node, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForSubject(s, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return g.triples(ts, lo)
+}
+
+// TriplesForPredicate returns the triples of the provided predicate holding on
+// the graph.
+func (g *latestGraph) TriplesForPredicate(p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForPredicate(p, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return g.triples(ts, lo)
+}
+
+// TriplesForObject returns the triples of the provided object holding on the
+// graph.
+func (g *latestGraph) TriplesForObject(o *triple.Object, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForObject(o, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return g.triples(ts, lo)
+}
+
+// TriplesForSubjectAndPredicate returns the triples of the provided subject
+// and predicate holding on the graph.
+func (g *latestGraph) TriplesForSubjectAndPredicate(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForSubjectAndPredicate(s, p, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return g.triples(ts, lo)
+}
+
+// TriplesForPredicateAndObject returns the triples of the provided predicate
+// and object holding on the graph.
+func (g *latestGraph) TriplesForPredicateAndObject(p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions) (storage.Triples, error) {
+	ts, err := g.Graph.TriplesForPredicateAndObject(p, o, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return g.triples(ts, lo)
+}
+
+// Exist returns true if the triple exists and holds on the graph.
+func (g *latestGraph) Exist(t *triple.Triple) (bool, error) {
+	ok, err := g.holds(t.S(), t.P())
+	if err != nil || !ok {
+		return false, err
+	}
+	return g.Graph.Exist(t)
+}
+
+// Triples returns the triples holding on the graph.
+func (g *latestGraph) Triples() (storage.Triples, error) {
+	ts, err := g.Graph.Triples()
+	if err != nil {
+		return nil, err
+	}
+	return g.triples(ts, storage.DefaultLookup)
+}
+
+// Prefetch passes the hints to the graph, if it supports them.
+func (g *latestGraph) Prefetch(h *storage.PrefetchHints) error {
+	if pf, ok := g.Graph.(storage.Prefetcher); ok {
+		return pf.Prefetch(h)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limits contains the resource limits enforced while executing queries. Zero
+// values disable the corresponding limit.
+type Limits struct {
+	// MaxRows is the maximum number of rows a query may return.
+	MaxRows int64
+
+	// MaxIntermediateRows is the maximum number of rows any clause of the
+	// graph pattern of a query may produce.
+	MaxIntermediateRows int64
+
+	// MaxMemoryBytes is the maximum number of bytes the operators running a
+	// query may hold in memory, as estimated from the rows they keep. Sorts
+	// and the data joined by cartesian products are spilled to disk once it is
+	// reached, while the operators that cannot spill, such as hash joins and
+	// distinct, fail.
+	MaxMemoryBytes int64
+
+	// MaxExecutionTime is the maximum time a query may take to run, including
+	// the time spent pulling its rows.
+	MaxExecutionTime time.Duration
+
+	// MaxGraphsScanned is the maximum number of graphs a query may retrieve
+	// data from.
+	MaxGraphsScanned int
+
+	// MaxPathLength is the maximum number of triples transitive predicate
+	// clauses may chain to reach a node.
+	MaxPathLength int
+}
+
+// LimitError is returned when a query exceeds one of the resource limits
+// configured on the planner, or the one set by its maxrows hint.
+type LimitError struct {
+	// Limit contains the name of the exceeded limit.
+	Limit string
+
+	// Max contains the value of the exceeded limit.
+	Max string
+}
+
+// Error returns the error message.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("query exceeded the %s limit of %s", e.Limit, e.Max)
+}
+
+// checkGraphs fails if the query retrieves data from more graphs than allowed.
+func (p *queryPlan) checkGraphs() error {
+	if m := p.lmts.MaxGraphsScanned; m > 0 && len(p.grfs) > m {
+		return &LimitError{Limit: "MaxGraphsScanned", Max: fmt.Sprint(m)}
+	}
+	return nil
+}
+
+// checkIntermediateRows fails if a clause of the graph pattern produces more
+// than n rows. The maxrows hint takes precedence over
+// the planner limits if it is more restrictive.
+func (p *queryPlan) checkIntermediateRows(n int) error {
+	if m := p.stm.Hints().MaxRows; m > 0 && int64(n) > m {
+		return &LimitError{Limit: "maxrows hint", Max: fmt.Sprint(m)}
+	}
+	if m := p.lmts.MaxIntermediateRows; m > 0 && int64(n) > m {
+		return &LimitError{Limit: "MaxIntermediateRows", Max: fmt.Sprint(m)}
+	}
+	return nil
+}
+
+// executionTimeError turns the error returned when the deadline set by the
+// MaxExecutionTime limit expires into a limit error. Any other error, including
+// the ones caused by the parent context, is returned unchanged.
+func executionTimeError(parent context.Context, lmts Limits, err error) error {
+	if err == context.DeadlineExceeded && lmts.MaxExecutionTime > 0 && parent.Err() == nil {
+		return &LimitError{Limit: "MaxExecutionTime", Max: lmts.MaxExecutionTime.String()}
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryLimits(t *testing.T) {
+	testTable := []struct {
+		q     string
+		lmts  Limits
+		limit string
+	}{
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`,
+			lmts: Limits{MaxRows: 4, MaxIntermediateRows: 4, MaxExecutionTime: time.Hour, MaxGraphsScanned: 1},
+		},
+		{
+			q:     `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`,
+			lmts:  Limits{MaxRows: 3},
+			limit: "MaxRows",
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} limit "2"^^type:int64;`,
+			lmts: Limits{MaxRows: 2},
+		},
+		{
+			q:     `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l};`,
+			lmts:  Limits{MaxIntermediateRows: 10},
+			limit: "MaxIntermediateRows",
+		},
+		{
+			q:     `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} with hint(maxrows "2"^^type:int64);`,
+			lmts:  Limits{MaxIntermediateRows: 10},
+			limit: "maxrows hint",
+		},
+		{
+			q:     `select ?s, ?o from ?test, ?other where {?s "parent_of"@[] ?o};`,
+			lmts:  Limits{MaxGraphsScanned: 1},
+			limit: "MaxGraphsScanned",
+		},
+		{
+			q:     `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l};`,
+			lmts:  Limits{MaxExecutionTime: time.Nanosecond},
+			limit: "MaxExecutionTime",
+		},
+		{
+			q:     `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`,
+			lmts:  Limits{MaxMemoryBytes: 100},
+			limit: "MaxMemoryBytes",
+		},
+		{
+			q:    `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l} order by ?k;`,
+			lmts: Limits{MaxMemoryBytes: 1 << 20},
+		},
+	}
+	s := populateTestStore(t)
+	if _, err := s.NewGraph("?other"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?other\" with error %v", err)
+	}
+	for _, entry := range testTable {
+		plnr, err := NewWithLimits(s, parseTestStatement(t, entry.q), entry.lmts)
+		if err != nil {
+			t.Fatalf("planner.NewWithLimits failed to create a valid query plan with error %v", err)
+		}
+		_, err = plnr.Excecute(context.Background())
+		if entry.limit == "" {
+			if err != nil {
+				t.Errorf("planner.Excecute failed for query %q with error %v", entry.q, err)
+			}
+			continue
+		}
+		le, ok := err.(*LimitError)
+		if !ok {
+			t.Errorf("planner.Excecute should have failed with a limit error for query %q; got %v", entry.q, err)
+			continue
+		}
+		if got, want := le.Limit, entry.limit; got != want {
+			t.Errorf("planner.Excecute exceeded the wrong limit for query %q; got %q, want %q", entry.q, got, want)
+		}
+	}
+}
+
+func TestExecuteWithLimits(t *testing.T) {
+	q := `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`
+	rs, err := ExecuteWithLimits(context.Background(), populateTestStore(t), parseTestStatement(t, q), Limits{MaxRows: 2})
+	if err != nil {
+		t.Fatalf("planner.ExecuteWithLimits failed for query %q with error %v", q, err)
+	}
+	defer rs.Close()
+	n := 0
+	for rs.Next() {
+		n++
+	}
+	if got, want := n, 2; got != want {
+		t.Errorf("planner.ExecuteWithLimits streamed the wrong number of rows before failing; got %d, want %d", got, want)
+	}
+	if le, ok := rs.Err().(*LimitError); !ok || le.Limit != "MaxRows" {
+		t.Errorf("ResultStream.Err should have returned a MaxRows limit error; got %v", rs.Err())
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+
+	"github.com/google/badwolf/bql/table"
+)
+
+// Estimated sizes in bytes of the values held by rows, not counting the text
+// they contain.
+const (
+	rowOverhead  = 48
+	cellOverhead = 64
+	nodeSize     = 32
+	valueSize    = 48
+	tripleSize   = 256
+)
+
+// rowSize returns the estimated number of bytes the row takes in memory.
+func rowSize(r table.Row) int64 {
+	n := int64(rowOverhead)
+	for b, c := range r {
+		n += cellOverhead + int64(len(b)+len(c.S))
+		switch {
+		case c.N != nil:
+			n += nodeSize + int64(len(c.N.Type().String())+len(c.N.ID().String()))
+		case c.P != nil:
+			n += valueSize + int64(len(c.P.ID()))
+		case c.L != nil:
+			n += valueSize
+			if t, err := c.L.Text(); err == nil {
+				n += int64(len(t))
+			}
+		case c.T != nil:
+			n += valueSize
+		case c.E != nil:
+			n += tripleSize
+		}
+	}
+	return n
+}
+
+// tableSampleSize contains the number of rows sampled to estimate the size of
+// a table.
+const tableSampleSize = 32
+
+// tableSize returns the estimated number of bytes the rows of the table take
+// in memory, extrapolated from the size of a sample of them.
+func tableSize(tbl *table.Table) int64 {
+	n := tbl.NumRows()
+	if n == 0 {
+		return 0
+	}
+	step := n / tableSampleSize
+	if step == 0 {
+		step = 1
+	}
+	var size, cnt int64
+	for i := 0; i < n; i += step {
+		r, _ := tbl.Row(i)
+		size += rowSize(r)
+		cnt++
+	}
+	return size * int64(n) / cnt
+}
+
+// memoryBudget keeps track of the estimated number of bytes held in memory by
+// the operators of a query, so they stay within the MaxMemoryBytes limit.
+// Operators that can spill their rows to disk do so once their reservations do
+// not fit, while the rest fail. A nil budget does not limit anything. Since
+// rows are pulled from a query by a single caller, it is not safe for
+// concurrent use.
+type memoryBudget struct {
+	max  int64
+	used int64
+}
+
+// newMemoryBudget returns the budget enforcing the provided limits, or nil if
+// they do not limit the memory used.
+func newMemoryBudget(lmts Limits) *memoryBudget {
+	if lmts.MaxMemoryBytes <= 0 {
+		return nil
+	}
+	return &memoryBudget{max: lmts.MaxMemoryBytes}
+}
+
+// reserve accounts for n more bytes held in memory. It returns false, without
+// reserving them, if they do not fit on the budget.
+func (b *memoryBudget) reserve(n int64) bool {
+	if b == nil {
+		return true
+	}
+	if b.used+n > b.max {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// require reserves n more bytes held in memory by an operator that cannot
+// spill them to disk, and fails with a LimitError if they do not fit.
+func (b *memoryBudget) require(n int64) error {
+	if !b.reserve(n) {
+		return &LimitError{Limit: "MaxMemoryBytes", Max: fmt.Sprint(b.max)}
+	}
+	return nil
+}
+
+// release returns n reserved bytes to the budget.
+func (b *memoryBudget) release(n int64) {
+	if b != nil {
+		b.used -= n
+	}
+}
+
+// sizeOf returns the estimated size of the row if the budget needs it, so
+// rows are only measured when the memory used is limited.
+func (b *memoryBudget) sizeOf(r table.Row) int64 {
+	if b == nil {
+		return 0
+	}
+	return rowSize(r)
+}
+
+// sizeOfTable returns the estimated size of the rows of the table if the
+// budget needs it.
+func (b *memoryBudget) sizeOfTable(tbl *table.Table) int64 {
+	if b == nil {
+		return 0
+	}
+	return tableSize(tbl)
+}
@@ -0,0 +1,64 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"testing"
+
+	"github.com/google/badwolf/bql/table"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	if b := newMemoryBudget(Limits{}); b != nil {
+		t.Errorf("newMemoryBudget should return nil for unlimited memory; got %+v", b)
+	}
+	var nb *memoryBudget
+	if !nb.reserve(1 << 40) {
+		t.Errorf("a nil memory budget should not limit anything")
+	}
+	b := newMemoryBudget(Limits{MaxMemoryBytes: 100})
+	if !b.reserve(60) {
+		t.Errorf("memoryBudget.reserve(60) should fit on a budget of 100 bytes")
+	}
+	if b.reserve(60) {
+		t.Errorf("memoryBudget.reserve(60) should not fit after reserving 60 of 100 bytes")
+	}
+	if err := b.require(60); err == nil {
+		t.Errorf("memoryBudget.require(60) should fail after reserving 60 of 100 bytes")
+	} else if le, ok := err.(*LimitError); !ok || le.Limit != "MaxMemoryBytes" {
+		t.Errorf("memoryBudget.require should fail with a MaxMemoryBytes limit error; got %v", err)
+	}
+	b.release(60)
+	if err := b.require(100); err != nil {
+		t.Errorf("memoryBudget.require(100) failed after releasing all bytes with error %v", err)
+	}
+}
+
+func TestTableSize(t *testing.T) {
+	tbl, err := table.New([]string{"?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tableSize(tbl); got != 0 {
+		t.Errorf("tableSize should return 0 for an empty table; got %d", got)
+	}
+	r := table.Row{"?v": &table.Cell{S: "foo"}}
+	for i := 0; i < 100; i++ {
+		tbl.AddRow(r)
+	}
+	if got, want := tableSize(tbl), 100*rowSize(r); got != want {
+		t.Errorf("tableSize returned the wrong size; got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+)
+
+// step returns the objects directly linked to the provided one.
+type step func(o *triple.Object) ([]*triple.Object, error)
+
+// fetchPath retrieves the data for a transitive clause from the queried
+// graphs. Chains of triples may span all the queried graphs, unless the
+// clause binds the graph, in which case each graph is traversed on its own.
+func (p *queryPlan) fetchPath(ctx context.Context, cls *semantic.GraphClause, lo *storage.LookupOptions, chk *bindingChecker) (*table.Table, error) {
+	gs := p.grfs
+	if cls.Graph != "" {
+		var sgs []storage.Graph
+		for _, g := range gs {
+			if g.ID() == cls.Graph {
+				sgs = append(sgs, g)
+			}
+		}
+		gs = sgs
+	}
+	gss := [][]storage.Graph{gs}
+	if cls.GBinding != "" {
+		gss = nil
+		for _, g := range gs {
+			gss = append(gss, []storage.Graph{g})
+		}
+	}
+	lo = updateTimeBounds(lo, cls)
+	tbl, err := table.New(cls.Bindings())
+	if err != nil {
+		return nil, err
+	}
+	for _, gs := range gss {
+		pts, err := p.pathTriples(ctx, gs, cls, lo)
+		if err != nil {
+			return nil, err
+		}
+		ts := make(chan *triple.Triple, len(pts))
+		for _, t := range pts {
+			ts <- t
+		}
+		close(ts)
+		gt, err := table.New(cls.Bindings())
+		if err != nil {
+			return nil, err
+		}
+		if err := addTriples(ctx, ts, cls, gt, lo, chk); err != nil {
+			return nil, err
+		}
+		for _, r := range gt.Rows() {
+			if cls.GBinding != "" {
+				r[cls.GBinding] = &table.Cell{S: gs[0].ID()}
+			}
+			tbl.AddRow(r)
+		}
+	}
+	return tbl, nil
+}
+
+// pathTriples returns one triple for each pair of nodes linked by a chain of
+// triples using the predicate of the transitive clause on the provided graphs.
+// Chains are followed from the subject of the clause if specified, backwards
+// from its object if only that one is, or from every subject of the predicate
+// otherwise.
+func (p *queryPlan) pathTriples(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions) ([]*triple.Triple, error) {
+	// Each step of the chain needs all the linked objects.
+	nlo := *lo
+	nlo.MaxElements = 0
+	var ts []*triple.Triple
+	add := func(s *node.Node, o *triple.Object) error {
+		if cls.O != nil && cls.O.GUID() != o.GUID() {
+			return nil
+		}
+		t, err := triple.New(s, cls.P, o)
+		if err != nil {
+			return err
+		}
+		ts = append(ts, t)
+		return nil
+	}
+	switch {
+	case cls.S != nil:
+		os, err := p.closure(ctx, triple.NewNodeObject(cls.S), forward(gs, cls, &nlo), cls.PReflexive)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range os {
+			if err := add(cls.S, o); err != nil {
+				return nil, err
+			}
+		}
+	case cls.O != nil:
+		ss, err := p.closure(ctx, cls.O, backward(gs, cls, &nlo), cls.PReflexive)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			if n, ok := s.AsNode(); ok {
+				if err := add(n, cls.O); err != nil {
+					return nil, err
+				}
+			}
+		}
+	default:
+		ns, adj, err := adjacency(gs, cls, &nlo)
+		if err != nil {
+			return nil, err
+		}
+		next := func(o *triple.Object) ([]*triple.Object, error) {
+			return adj[o.GUID()], nil
+		}
+		for _, n := range ns {
+			os, err := p.closure(ctx, triple.NewNodeObject(n), next, cls.PReflexive)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range os {
+				if err := add(n, o); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.checkIntermediateRows(len(ts)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ts, nil
+}
+
+// closure returns the objects reachable from the provided one by taking one or
+// more steps, or zero or more if reflexive. It is computed as a fixed point:
+// each iteration only expands the objects first reached on the previous one,
+// and stops once no new object is reached. Objects are only expanded once, so
+// cycles are traversed at most once. The number of iterations is bounded by
+// the MaxPathLength limit, if set.
+func (p *queryPlan) closure(ctx context.Context, from *triple.Object, next step, reflexive bool) ([]*triple.Object, error) {
+	var res []*triple.Object
+	seen := make(map[string]bool)
+	if reflexive {
+		seen[from.GUID()] = true
+		res = append(res, from)
+	}
+	frontier := []*triple.Object{from}
+	for n := 1; len(frontier) > 0; n++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var nxt []*triple.Object
+		for _, o := range frontier {
+			os, err := next(o)
+			if err != nil {
+				return nil, err
+			}
+			for _, no := range os {
+				if !seen[no.GUID()] {
+					seen[no.GUID()] = true
+					nxt = append(nxt, no)
+				}
+			}
+		}
+		if m := p.lmts.MaxPathLength; m > 0 && n > m && len(nxt) > 0 {
+			return nil, &LimitError{Limit: "MaxPathLength", Max: fmt.Sprint(m)}
+		}
+		res = append(res, nxt...)
+		if err := p.checkIntermediateRows(len(res)); err != nil {
+			return nil, err
+		}
+		frontier = nxt
+	}
+	return res, nil
+}
+
+// forward returns a step that follows the predicate of the clause from
+// subject to object on the provided graphs.
+func forward(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions) step {
+	return func(o *triple.Object) ([]*triple.Object, error) {
+		n, ok := o.AsNode()
+		if !ok {
+			// Only nodes can be the subject of the next triple.
+			return nil, nil
+		}
+		var res []*triple.Object
+		for _, g := range gs {
+			os, err := g.Objects(n, cls.P, lo)
+			if err != nil {
+				return nil, err
+			}
+			for no := range os {
+				res = append(res, no)
+			}
+		}
+		return res, nil
+	}
+}
+
+// backward returns a step that follows the predicate of the clause from
+// object to subject on the provided graphs.
+func backward(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions) step {
+	return func(o *triple.Object) ([]*triple.Object, error) {
+		var res []*triple.Object
+		for _, g := range gs {
+			ss, err := g.Subjects(cls.P, o, lo)
+			if err != nil {
+				return nil, err
+			}
+			for s := range ss {
+				res = append(res, triple.NewNodeObject(s))
+			}
+		}
+		return res, nil
+	}
+}
+
+// adjacency loads all the triples using the predicate of the clause on the
+// provided graphs. It returns the subjects to start the chains from, and the
+// objects linked to each object by GUID. Reflexive clauses also start from the
+// nodes only found as objects.
+func adjacency(gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions) ([]*node.Node, map[string][]*triple.Object, error) {
+	var ns []*node.Node
+	seen := make(map[string]bool)
+	adj := make(map[string][]*triple.Object)
+	for _, g := range gs {
+		ts, err := g.TriplesForPredicate(cls.P, lo)
+		if err != nil {
+			return nil, nil, err
+		}
+		for t := range ts {
+			so := triple.NewNodeObject(t.S())
+			if !seen[so.GUID()] {
+				seen[so.GUID()] = true
+				ns = append(ns, t.S())
+			}
+			adj[so.GUID()] = append(adj[so.GUID()], t.O())
+		}
+	}
+	if cls.PReflexive {
+		for _, s := range ns {
+			for _, o := range adj[triple.NewNodeObject(s).GUID()] {
+				if n, ok := o.AsNode(); ok && !seen[o.GUID()] {
+					seen[o.GUID()] = true
+					ns = append(ns, n)
+				}
+			}
+		}
+	}
+	return ns, adj, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple/literal"
+)
+
+const knowsTriples = `/u<a> "knows"@[] /u<b>
+/u<b> "knows"@[] /u<c>
+/u<c> "knows"@[] /u<a>
+/u<c> "knows"@[] /u<d>
+`
+
+func populateKnowsStore(t *testing.T) storage.Store {
+	s := populateTestStore(t)
+	g, err := s.NewGraph("?knows")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?knows\" with error %v", err)
+	}
+	if _, err := io.ReadIntoGraph(g, bytes.NewBufferString(knowsTriples), literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read the knows graph with error %v", err)
+	}
+	return s
+}
+
+func TestTransitivePredicates(t *testing.T) {
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{
+			q:    `select ?o from ?knows where {/u<a> "knows"@[]+ ?o};`,
+			nrws: 4,
+		},
+		{
+			q:    `select ?o from ?knows where {/u<a> "knows"@[]* ?o};`,
+			nrws: 4,
+		},
+		{
+			q:    `select ?o from ?knows where {/u<d> "knows"@[]+ ?o};`,
+			nrws: 0,
+		},
+		{
+			q:    `select ?o from ?knows where {/u<d> "knows"@[]* ?o};`,
+			nrws: 1,
+		},
+		{
+			q:    `select ?s from ?knows where {?s "knows"@[]+ /u<d>};`,
+			nrws: 3,
+		},
+		{
+			q:    `select ?s, ?o from ?knows where {?s "knows"@[]+ ?o};`,
+			nrws: 12,
+		},
+		{
+			q:    `select ?s, ?o from ?knows where {?s "knows"@[]* ?o};`,
+			nrws: 13,
+		},
+		{
+			q:    `select ?s, ?o from ?knows where {?s "knows"@[] ?o. ?o "knows"@[]+ ?s};`,
+			nrws: 3,
+		},
+		{
+			q:    `select ?o from ?knows where {/u<b> "knows"@[] ?x. ?x "knows"@[]+ ?o};`,
+			nrws: 4,
+		},
+		{
+			q:    `select ?d from ?test where {/u<joe> "parent_of"@[]+ ?d};`,
+			nrws: 4,
+		},
+		{
+			q:    `select ?s, ?d from ?test, ?knows where {graph ?test {?s "parent_of"@[]+ ?d}};`,
+			nrws: 6,
+		},
+	}
+	s := populateKnowsStore(t)
+	for _, entry := range testTable {
+		if got, want := executeTestStatement(t, s, entry.q), entry.nrws; got != want {
+			t.Errorf("planner.Excecute(%q) returned the wrong number of rows; got %d, want %d", entry.q, got, want)
+		}
+	}
+}
+
+func TestTransitivePredicatesLimits(t *testing.T) {
+	testTable := []struct {
+		lmts  Limits
+		limit string
+	}{
+		{lmts: Limits{MaxPathLength: 3}},
+		{lmts: Limits{MaxPathLength: 2}, limit: "MaxPathLength"},
+		{lmts: Limits{MaxIntermediateRows: 3}, limit: "MaxIntermediateRows"},
+	}
+	s := populateKnowsStore(t)
+	q := `select ?o from ?knows where {/u<a> "knows"@[]+ ?o};`
+	for _, entry := range testTable {
+		pln, err := NewWithLimits(s, parseTestStatement(t, q), entry.lmts)
+		if err != nil {
+			t.Fatalf("planner.NewWithLimits failed to create a valid plan for %q with error %v", q, err)
+		}
+		_, err = pln.Excecute(context.Background())
+		if entry.limit == "" {
+			if err != nil {
+				t.Errorf("planner.Excecute(%q) with limits %+v failed with error %v", q, entry.lmts, err)
+			}
+			continue
+		}
+		if le, ok := err.(*LimitError); !ok || le.Limit != entry.limit {
+			t.Errorf("planner.Excecute(%q) with limits %+v returned error %v; want the %s limit exceeded", q, entry.lmts, err, entry.limit)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+)
+
+// defaultSortBuffer contains the number of rows a sort holds in memory before
+// spilling sorted runs to disk, unless overridden by the sortbuffer hint.
+const defaultSortBuffer = 100000
+
+// defaultResultBuffer contains the number of rows of the results of a query
+// Excecute holds in memory before spilling the remaining ones to disk.
+const defaultResultBuffer = 1000000
+
+// defaultProductBuffer contains the number of rows above which the data of the
+// clauses joined by cartesian products is spilled to disk.
+const defaultProductBuffer = 1000000
+
+// operator is a stage of a pull based row pipeline. Each call to next returns
+// the next row, or nil once the operator is exhausted.
+type operator interface {
+	next() (table.Row, error)
+	close() error
+}
+
+// scanChunkSize is the number of rows built at once when scanning a table.
+const scanChunkSize = 1024
+
+// scanOperator returns the rows of a slice one at a time. If an iterator is
+// provided, the slice is refilled with its chunks once exhausted. If a context
+// is provided, it stops returning rows once the context is done. The provided
+// number of bytes reserved for the rows on the memory budget, if any, is
+// released once the operator is closed.
+type scanOperator struct {
+	ctx  context.Context
+	rows []table.Row
+	it   *table.RowIterator
+	bgt  *memoryBudget
+	size int64
+}
+
+func (o *scanOperator) next() (table.Row, error) {
+	if o.ctx != nil {
+		if err := o.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.rows) == 0 && o.it != nil {
+		rs, err := o.it.Next()
+		if err != nil {
+			return nil, err
+		}
+		o.rows = rs
+	}
+	if len(o.rows) == 0 {
+		return nil, nil
+	}
+	r := o.rows[0]
+	o.rows[0] = nil
+	o.rows = o.rows[1:]
+	return r, nil
+}
+
+func (o *scanOperator) close() error {
+	o.bgt.release(o.size)
+	o.rows, o.it, o.size = nil, nil, 0
+	return nil
+}
+
+// filterOperator only returns the rows for which the provided function
+// returns true.
+type filterOperator struct {
+	in operator
+	f  func(table.Row) (bool, error)
+}
+
+func (o *filterOperator) next() (table.Row, error) {
+	for {
+		r, err := o.in.next()
+		if r == nil || err != nil {
+			return nil, err
+		}
+		ok, err := o.f(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate having clause; %v", err)
+		}
+		if ok {
+			return r, nil
+		}
+	}
+}
+
+func (o *filterOperator) close() error {
+	return o.in.close()
+}
+
+// limitOperator returns at most n rows.
+type limitOperator struct {
+	in operator
+	n  int64
+}
+
+func (o *limitOperator) next() (table.Row, error) {
+	if o.n <= 0 {
+		return nil, nil
+	}
+	o.n--
+	return o.in.next()
+}
+
+func (o *limitOperator) close() error {
+	return o.in.close()
+}
+
+// groupLimitOperator returns at most n rows for each group of rows that share
+// the same values for the provided bindings.
+type groupLimitOperator struct {
+	in   operator
+	bs   []string
+	n    int64
+	cnts map[string]int64
+}
+
+func (o *groupLimitOperator) next() (table.Row, error) {
+	var key bytes.Buffer
+	for {
+		r, err := o.in.next()
+		if r == nil || err != nil {
+			return nil, err
+		}
+		key.Reset()
+		if err := r.ToTextLine(&key, o.bs, "\x00"); err != nil {
+			return nil, err
+		}
+		k := key.String()
+		if o.cnts[k] < o.n {
+			o.cnts[k]++
+			return r, nil
+		}
+	}
+}
+
+func (o *groupLimitOperator) close() error {
+	return o.in.close()
+}
+
+// keyOverhead contains the estimated number of bytes a key takes in memory,
+// not counting its text.
+const keyOverhead = 64
+
+// distinctOperator only returns the first occurrence of each row. The keys of
+// the rows seen need to fit on the memory budget.
+type distinctOperator struct {
+	in   operator
+	bs   []string
+	seen map[string]bool
+	bgt  *memoryBudget
+	size int64
+}
+
+func (o *distinctOperator) next() (table.Row, error) {
+	for {
+		r, err := o.in.next()
+		if r == nil || err != nil {
+			return nil, err
+		}
+		k := table.RowKey(r, o.bs)
+		if !o.seen[k] {
+			if o.bgt != nil {
+				sz := int64(keyOverhead + len(k))
+				if err := o.bgt.require(sz); err != nil {
+					return nil, err
+				}
+				o.size += sz
+			}
+			o.seen[k] = true
+			return r, nil
+		}
+	}
+}
+
+func (o *distinctOperator) close() error {
+	o.bgt.release(o.size)
+	o.seen, o.size = nil, 0
+	return o.in.close()
+}
+
+// sortOperator returns the rows of its input sorted using the provided
+// configuration. Rows that compare equal retain their relative order. If the
+// input has more rows than the buffer allows, or they do not fit on the memory
+// budget, sorted runs are spilled to temporary files and merged back when the
+// rows are pulled.
+type sortOperator struct {
+	in     operator
+	cfg    []table.SortConfig
+	bs     []string
+	buffer int
+	bgt    *memoryBudget
+	held   int64
+	sorted bool
+	mem    []table.Row
+	runs   []*sortRun
+}
+
+// sortRun contains a sorted run of rows spilled to disk.
+type sortRun struct {
+	run  *table.Run
+	head table.Row
+}
+
+// advance reads the next row of the run.
+func (r *sortRun) advance() error {
+	row, err := r.run.Read()
+	if err != nil {
+		return err
+	}
+	r.head = row
+	return nil
+}
+
+// sortBuffer sorts the rows currently held in memory.
+func (o *sortOperator) sortBuffer() error {
+	tbl, err := table.New(o.bs)
+	if err != nil {
+		return err
+	}
+	for _, r := range o.mem {
+		tbl.AddRow(r)
+	}
+	if err := tbl.Sort(o.cfg); err != nil {
+		return fmt.Errorf("failed to sort results; %v", err)
+	}
+	o.mem = tbl.Rows()
+	return nil
+}
+
+// spill sorts the rows held in memory and writes them to a new run.
+func (o *sortOperator) spill() error {
+	if err := o.sortBuffer(); err != nil {
+		return err
+	}
+	tr, err := table.NewRun("badwolf-sort-")
+	if err != nil {
+		return err
+	}
+	run := &sortRun{run: tr}
+	o.runs = append(o.runs, run)
+	for _, r := range o.mem {
+		if err := tr.Write(r); err != nil {
+			return err
+		}
+	}
+	if err := tr.Rewind(); err != nil {
+		return err
+	}
+	o.mem = nil
+	o.bgt.release(o.held)
+	o.held = 0
+	return run.advance()
+}
+
+// load pulls all the input rows, spilling them to disk if needed.
+func (o *sortOperator) load() error {
+	for {
+		r, err := o.in.next()
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			break
+		}
+		o.mem = append(o.mem, r)
+		sz := o.bgt.sizeOf(r)
+		fits := o.bgt.reserve(sz)
+		if fits {
+			o.held += sz
+		}
+		if !fits || len(o.mem) >= o.buffer {
+			if err := o.spill(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(o.runs) == 0 {
+		return o.sortBuffer()
+	}
+	if len(o.mem) > 0 {
+		return o.spill()
+	}
+	return nil
+}
+
+func (o *sortOperator) next() (table.Row, error) {
+	if !o.sorted {
+		o.sorted = true
+		if err := o.load(); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.runs) == 0 {
+		if len(o.mem) == 0 {
+			return nil, nil
+		}
+		r := o.mem[0]
+		o.mem = o.mem[1:]
+		return r, nil
+	}
+	// Merge the runs. Ties are resolved in favor of the earliest run to keep
+	// the sort stable.
+	var min *sortRun
+	for _, run := range o.runs {
+		if run.head == nil {
+			continue
+		}
+		if min == nil {
+			min = run
+			continue
+		}
+		cmp, err := table.CompareRows(run.head, min.head, o.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sort results; %v", err)
+		}
+		if cmp < 0 {
+			min = run
+		}
+	}
+	if min == nil {
+		return nil, nil
+	}
+	r := min.head
+	if err := min.advance(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (o *sortOperator) close() error {
+	var rerr error
+	for _, run := range o.runs {
+		if err := run.run.Close(); err != nil && rerr == nil {
+			rerr = err
+		}
+	}
+	o.runs, o.mem = nil, nil
+	o.bgt.release(o.held)
+	o.held = 0
+	if err := o.in.close(); err != nil && rerr == nil {
+		rerr = err
+	}
+	return rerr
+}
+
+// newPipeline returns the operator pipeline that resolves the graph pattern
+// and minus patterns of the query plan statement, and then groups, filters,
+// sorts, and trims the resulting rows as requested by it. Rows flow through
+// all the operators as they are pulled, and only the ones that need to see
+// all their input before returning a row hold it. If the results are known to
+// be empty, no data is retrieved. Each operator is profiled if the plan
+// collects a profile. It also returns the bindings of the resulting rows.
+func (p *queryPlan) newPipeline(ctx context.Context, empty bool) (operator, []string) {
+	var last *profiledOperator
+	profiled := func(name string, op operator) operator {
+		if p.prof == nil {
+			return op
+		}
+		last = &profiledOperator{in: op, prev: last, st: p.prof.stage(name)}
+		return last
+	}
+	var op operator
+	if empty {
+		bs := append([]string{}, p.bndgs...)
+		sort.Strings(bs)
+		p.tbl.AddBindings(bs)
+		op = profiled("scan", &scanOperator{})
+	} else {
+		lo := p.stm.GlobalLookupOptions()
+		lo.MaxElements = p.rowLimit()
+		op = profiled("graph pattern", p.graphPattern(ctx, lo))
+		if len(p.stm.MinusPatterns()) > 0 {
+			op = profiled("minus patterns", &minusOperator{
+				in:  op,
+				bs:  p.tbl.Bindings(),
+				bgt: p.bgt,
+				build: func() ([]*table.Table, int64, error) {
+					return p.minusTables(ctx, lo)
+				},
+			})
+		}
+	}
+	bs := p.tbl.Bindings()
+	if aggs := aggregations(p.stm); len(aggs) > 0 {
+		op = profiled("group", &groupOperator{ctx: ctx, in: op, p: p})
+		bs = p.groupBindings(aggs)
+	}
+	if e := p.stm.HavingEvaluator(); e != nil {
+		// Having clauses that always hold do not filter any row.
+		if v, ok := semantic.ConstantValue(e); !ok || !v {
+			op = profiled("having", &filterOperator{in: op, f: e.Evaluate})
+		}
+	}
+	if p.stm.IsDistinct() {
+		op = profiled("distinct", &distinctOperator{in: op, bs: bs, seen: make(map[string]bool), bgt: p.bgt})
+	}
+	if cfg := p.stm.OrderBy(); len(cfg) > 0 {
+		buf := int64(defaultSortBuffer)
+		if sb := p.stm.Hints().SortBuffer; sb > 0 {
+			buf = sb
+		}
+		op = profiled("sort", &sortOperator{in: op, cfg: cfg, bs: bs, buffer: int(buf), bgt: p.bgt})
+	}
+	if p.stm.IsLimitSet() {
+		if p.stm.IsLimitPerGroup() {
+			op = profiled("group limit", &groupLimitOperator{in: op, bs: p.stm.GroupBy(), n: p.stm.Limit(), cnts: make(map[string]int64)})
+		} else {
+			op = profiled("limit", &limitOperator{in: op, n: p.stm.Limit()})
+		}
+	}
+	return op, bs
+}
@@ -0,0 +1,304 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// drainOperator returns all the rows produced by the provided operator.
+func drainOperator(t *testing.T, op operator) []table.Row {
+	var rws []table.Row
+	for {
+		r, err := op.next()
+		if err != nil {
+			t.Fatalf("operator.next failed with error %v", err)
+		}
+		if r == nil {
+			break
+		}
+		rws = append(rws, r)
+	}
+	if err := op.close(); err != nil {
+		t.Fatalf("operator.close failed with error %v", err)
+	}
+	return rws
+}
+
+func testPipelineRows(vs ...string) []table.Row {
+	var rws []table.Row
+	for i, v := range vs {
+		l, err := literal.DefaultBuilder().Build(literal.Int64, int64(i))
+		if err != nil {
+			panic(err)
+		}
+		rws = append(rws, table.Row{"?v": &table.Cell{S: v}, "?i": &table.Cell{L: l}})
+	}
+	return rws
+}
+
+func rowValues(rws []table.Row, b string) []string {
+	var vs []string
+	for _, r := range rws {
+		vs = append(vs, r[b].String())
+	}
+	return vs
+}
+
+func TestScanOperatorChunks(t *testing.T) {
+	in := testPipelineRows("a", "b", "c", "d", "e")
+	tbl, err := table.New([]string{"?v", "?i"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range in {
+		tbl.AddRow(r)
+	}
+	for _, size := range []int{1, 2, 10} {
+		got := rowValues(drainOperator(t, &scanOperator{it: tbl.Iterator(size)}), "?v")
+		if want := rowValues(in, "?v"); !reflect.DeepEqual(got, want) {
+			t.Errorf("scanOperator returned the wrong rows for chunks of %d rows; got %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestSortOperatorSpills(t *testing.T) {
+	in := testPipelineRows("d", "b", "a", "c", "b", "e", "a")
+	for _, buf := range []int{1, 2, 3, 100} {
+		op := &sortOperator{
+			in:     &scanOperator{rows: append([]table.Row{}, in...)},
+			cfg:    []table.SortConfig{{Binding: "?v"}},
+			bs:     []string{"?v", "?i"},
+			buffer: buf,
+		}
+		r, err := op.next()
+		if err != nil {
+			t.Fatalf("sortOperator.next failed with error %v", err)
+		}
+		if got, want := len(op.runs), (len(in)+buf-1)/buf; buf < len(in) && got != want {
+			t.Errorf("sortOperator spilled the wrong number of runs for buffer %d; got %d, want %d", buf, got, want)
+		}
+		var files []string
+		for _, run := range op.runs {
+			files = append(files, run.run.Name())
+		}
+		rws := append([]table.Row{r}, drainOperator(t, op)...)
+		if got, want := rowValues(rws, "?v"), []string{"a", "a", "b", "b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("sortOperator returned the wrong order for buffer %d; got %v, want %v", buf, got, want)
+		}
+		// Equal rows must retain their relative order.
+		if got, want := rowValues(rws, "?i"), []string{
+			`"2"^^type:int64`, `"6"^^type:int64`, `"1"^^type:int64`, `"4"^^type:int64`,
+			`"3"^^type:int64`, `"0"^^type:int64`, `"5"^^type:int64`,
+		}; !reflect.DeepEqual(got, want) {
+			t.Errorf("sortOperator is not stable for buffer %d; got %v, want %v", buf, got, want)
+		}
+		for _, f := range files {
+			if _, err := os.Stat(f); !os.IsNotExist(err) {
+				t.Errorf("sortOperator.close failed to remove spilled run %q", f)
+			}
+		}
+	}
+}
+
+func TestSortOperatorFailsOnKeyErrors(t *testing.T) {
+	fail := func(table.Row) (*table.Cell, error) { return nil, errors.New("boom") }
+	for _, buf := range []int{1, 100} {
+		op := &sortOperator{
+			in:     &scanOperator{rows: testPipelineRows("b", "a")},
+			cfg:    []table.SortConfig{{Value: fail}},
+			bs:     []string{"?v", "?i"},
+			buffer: buf,
+		}
+		if _, err := op.next(); err == nil {
+			t.Errorf("sortOperator.next should have failed for buffer %d", buf)
+		}
+		op.close()
+	}
+}
+
+func TestLimitOperators(t *testing.T) {
+	in := testPipelineRows("a", "b", "a", "a", "b", "c")
+	got := rowValues(drainOperator(t, &limitOperator{in: &scanOperator{rows: append([]table.Row{}, in...)}, n: 4}), "?v")
+	if want := []string{"a", "b", "a", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("limitOperator returned the wrong rows; got %v, want %v", got, want)
+	}
+	op := &groupLimitOperator{in: &scanOperator{rows: append([]table.Row{}, in...)}, bs: []string{"?v"}, n: 2, cnts: make(map[string]int64)}
+	if got, want := rowValues(drainOperator(t, op), "?v"), []string{"a", "b", "a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("groupLimitOperator returned the wrong rows; got %v, want %v", got, want)
+	}
+	op2 := &filterOperator{
+		in: &scanOperator{rows: append([]table.Row{}, in...)},
+		f:  func(r table.Row) (bool, error) { return r["?v"].S != "a", nil },
+	}
+	if got, want := rowValues(drainOperator(t, op2), "?v"), []string{"b", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("filterOperator returned the wrong rows; got %v, want %v", got, want)
+	}
+}
+
+func TestSpilledCartesianProduct(t *testing.T) {
+	testTable := []struct {
+		q       string
+		buffer  int
+		mem     int64
+		spilled bool
+		nrws    int
+	}{
+		{
+			q:       `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l};`,
+			buffer:  3,
+			spilled: true,
+			nrws:    16,
+		},
+		{
+			q:       `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l} order by ?k desc;`,
+			buffer:  3,
+			spilled: true,
+			nrws:    16,
+		},
+		{
+			q:      `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l};`,
+			buffer: 4,
+			nrws:   16,
+		},
+		{
+			q:       `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l} minus {?s "parent_of"@[] /u<john>};`,
+			buffer:  3,
+			spilled: true,
+			nrws:    8,
+		},
+		{
+			// The data of the first clause fits on the budget, but the one of the
+			// second clause does not.
+			q:       `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l} order by ?k desc;`,
+			mem:     1500,
+			spilled: true,
+			nrws:    16,
+		},
+	}
+	s := populateTestStore(t)
+	for _, entry := range testTable {
+		pln, err := NewWithLimits(s, parseTestStatement(t, entry.q), Limits{MaxMemoryBytes: entry.mem})
+		if err != nil {
+			t.Fatalf("planner.NewWithLimits failed to create a valid plan for %q with error %v", entry.q, err)
+		}
+		qp := pln.(*queryPlan)
+		qp.productBuffer = entry.buffer
+		rs, err := qp.stream(context.Background())
+		if err != nil {
+			t.Fatalf("queryPlan.stream(%q) failed with error %v", entry.q, err)
+		}
+		n := 0
+		for rs.Next() {
+			n++
+		}
+		if err := rs.Err(); err != nil {
+			t.Errorf("queryPlan.stream(%q) failed with error %v", entry.q, err)
+		}
+		if err := rs.Close(); err != nil {
+			t.Errorf("rowStream.Close failed with error %v", err)
+		}
+		if got, want := n, entry.nrws; got != want {
+			t.Errorf("queryPlan.stream(%q) returned the wrong number of rows; got %d, want %d", entry.q, got, want)
+		}
+		if got, want := qp.prof.Clauses[1].SpilledRows > 0, entry.spilled; got != want {
+			t.Errorf("queryPlan.stream(%q) with product buffer %d spilled the product %v; want %v", entry.q, entry.buffer, got, want)
+		}
+		if qp.bgt != nil && qp.bgt.used != 0 {
+			t.Errorf("queryPlan.stream(%q) did not release %d bytes of the memory budget", entry.q, qp.bgt.used)
+		}
+	}
+}
+
+func TestExcecuteKeepsSpilledResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badwolf-results-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", dir)
+	spilledFiles := func() int {
+		fs, err := filepath.Glob(filepath.Join(dir, "badwolf-table-*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(fs)
+	}
+
+	testTable := []struct {
+		buffer  int
+		mem     int64
+		spilled bool
+	}{
+		{buffer: 5, spilled: true},
+		{mem: 2000, spilled: true},
+		{buffer: 16},
+	}
+	q := `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?k "parent_of"@[] ?l};`
+	s := populateTestStore(t)
+	for _, entry := range testTable {
+		pln, err := NewWithLimits(s, parseTestStatement(t, q), Limits{MaxMemoryBytes: entry.mem})
+		if err != nil {
+			t.Fatalf("planner.NewWithLimits failed to create a valid plan for %q with error %v", q, err)
+		}
+		qp := pln.(*queryPlan)
+		qp.productBuffer, qp.resultBuffer = 3, entry.buffer
+		tbl, err := qp.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("queryPlan.Excecute(%q) failed with error %v", q, err)
+		}
+		if got, want := spilledFiles() > 0, entry.spilled; got != want {
+			t.Errorf("queryPlan.Excecute with result buffer %d and %d bytes spilled results %v; want %v", entry.buffer, entry.mem, got, want)
+		}
+		n := 0
+		it := tbl.Iterator(4)
+		for {
+			rs, err := it.Next()
+			if err != nil {
+				t.Fatalf("RowIterator.Next failed with error %v", err)
+			}
+			if len(rs) == 0 {
+				break
+			}
+			n += len(rs)
+		}
+		if got, want := n, 16; got != want {
+			t.Errorf("queryPlan.Excecute(%q) returned the wrong number of rows; got %d, want %d", q, got, want)
+		}
+		// Reading the results does not load them into memory.
+		if got, want := spilledFiles() > 0, entry.spilled; got != want {
+			t.Errorf("reading the results of queryPlan.Excecute loaded the spilled ones into memory")
+		}
+		if err := tbl.Close(); err != nil {
+			t.Errorf("table.Close failed with error %v", err)
+		}
+		if got := spilledFiles(); got != 0 {
+			t.Errorf("table.Close left %d spilled files behind", got)
+		}
+		if qp.bgt != nil && qp.bgt.used != 0 {
+			t.Errorf("queryPlan.Excecute did not release %d bytes of the memory budget", qp.bgt.used)
+		}
+	}
+}
@@ -17,23 +17,26 @@
 package planner
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/badwolf/bql/semantic"
 	"github.com/google/badwolf/bql/table"
 	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/stats"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/predicate"
 )
 
 // Excecutor interface unifies the execution of statements.
 type Excecutor interface {
 	// Execute runs the proposed plan for a given statement.
-	Excecute() (*table.Table, error)
+	Excecute(ctx context.Context) (*table.Table, error)
 }
 
 // createPlan encapsulates the sequence of instructions that need to be
@@ -44,17 +47,26 @@ type createPlan struct {
 }
 
 // Execute creates the indicated graphs.
-func (p *createPlan) Excecute() (*table.Table, error) {
+func (p *createPlan) Excecute(ctx context.Context) (*table.Table, error) {
 	t, err := table.New([]string{})
 	if err != nil {
 		return nil, err
 	}
 	errs := []string{}
+	var last error
 	for _, g := range p.stm.Graphs() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		if _, err := p.store.NewGraph(g); err != nil {
 			errs = append(errs, err.Error())
+			last = err
 		}
 	}
+	// A single failure is returned as is, so callers can inspect it.
+	if len(errs) == 1 {
+		return nil, last
+	}
 	if len(errs) > 0 {
 		return nil, errors.New(strings.Join(errs, "; "))
 	}
@@ -69,13 +81,19 @@ type dropPlan struct {
 }
 
 // Execute drops the indicated graphs.
-func (p *dropPlan) Excecute() (*table.Table, error) {
+func (p *dropPlan) Excecute(ctx context.Context) (*table.Table, error) {
 	t, err := table.New([]string{})
 	if err != nil {
 		return nil, err
 	}
 	errs := []string{}
 	for _, g := range p.stm.Graphs() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := dropView(p.store, g); err != nil {
+			errs = append(errs, err.Error())
+		}
 		if err := p.store.DeleteGraph(g); err != nil {
 			errs = append(errs, err.Error())
 		}
@@ -86,6 +104,39 @@ func (p *dropPlan) Excecute() (*table.Table, error) {
 	return t, nil
 }
 
+// analyzePlan encapsulates the sequence of instructions that need to be
+// excecuted in order to satisfy the exceution of a valid analyze BQL statement.
+type analyzePlan struct {
+	stm   *semantic.Statement
+	store storage.Store
+}
+
+// Execute collects and stores the statistics of the indicated graphs.
+func (p *analyzePlan) Excecute(ctx context.Context) (*table.Table, error) {
+	t, err := table.New([]string{})
+	if err != nil {
+		return nil, err
+	}
+	errs := []string{}
+	for _, id := range p.stm.Graphs() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		g, err := p.store.Graph(id)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := stats.Analyze(ctx, g); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return t, nil
+}
+
 // insertPlan encapsulates the sequence of instructions that need to be
 // excecuted in order to satisfy the exceution of a valid insert BQL statement.
 type insertPlan struct {
@@ -95,7 +146,7 @@ type insertPlan struct {
 
 type updater func(storage.Graph, []*triple.Triple) error
 
-func update(stm *semantic.Statement, store storage.Store, f updater) error {
+func update(ctx context.Context, stm *semantic.Statement, store storage.Store, f updater) error {
 	var (
 		mu   sync.Mutex
 		wg   sync.WaitGroup
@@ -107,6 +158,9 @@ func update(stm *semantic.Statement, store storage.Store, f updater) error {
 		errs = append(errs, err.Error())
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	for _, graphBinding := range stm.Graphs() {
 		wg.Add(1)
 		go func(graph string) {
@@ -130,12 +184,12 @@ func update(stm *semantic.Statement, store storage.Store, f updater) error {
 }
 
 // Execute inserts the provided data into the indicated graphs.
-func (p *insertPlan) Excecute() (*table.Table, error) {
+func (p *insertPlan) Excecute(ctx context.Context) (*table.Table, error) {
 	t, err := table.New([]string{})
 	if err != nil {
 		return nil, err
 	}
-	return t, update(p.stm, p.store, func(g storage.Graph, d []*triple.Triple) error {
+	return t, update(ctx, p.stm, p.store, func(g storage.Graph, d []*triple.Triple) error {
 		return g.AddTriples(d)
 	})
 }
@@ -148,12 +202,12 @@ type deletePlan struct {
 }
 
 // Execute deletes the provided data into the indicated graphs.
-func (p *deletePlan) Excecute() (*table.Table, error) {
+func (p *deletePlan) Excecute(ctx context.Context) (*table.Table, error) {
 	t, err := table.New([]string{})
 	if err != nil {
 		return nil, err
 	}
-	return t, update(p.stm, p.store, func(g storage.Graph, d []*triple.Triple) error {
+	return t, update(ctx, p.stm, p.store, func(g storage.Graph, d []*triple.Triple) error {
 		return g.RemoveTriples(d)
 	})
 }
@@ -170,10 +224,37 @@ type queryPlan struct {
 	grfs      []storage.Graph
 	cls       []*semantic.GraphClause
 	tbl       *table.Table
+	lmts      Limits
+	// Binding checkers for each clause.
+	chks []*bindingChecker
+	// Plans run to resolve the minus patterns.
+	mps []*queryPlan
+	// Execution metrics. Rows fetched are always counted, but the profile is
+	// only collected by top level plans.
+	fetched int64
+	prof    *Profile
+	// The data of the clauses joined by cartesian products is spilled to disk
+	// if it has more rows than the product buffer, or if it does not fit on
+	// the memory budget. Plans with no product buffer only spill when the
+	// budget is exceeded.
+	productBuffer int
+	bgt           *memoryBudget
+	// Excecute keeps the rows beyond the result buffer, or the ones that do
+	// not fit on the memory budget, spilled to disk on the returned table.
+	resultBuffer int
 }
 
 // newQueryPlan returns a new query plan ready to be excecuted.
 func newQueryPlan(store storage.Store, stm *semantic.Statement) (*queryPlan, error) {
+	if err := stm.Validate(); err != nil {
+		return nil, err
+	}
+	return preparedQueryPlan(store, stm)
+}
+
+// preparedQueryPlan returns a new query plan for an already validated
+// statement.
+func preparedQueryPlan(store storage.Store, stm *semantic.Statement) (*queryPlan, error) {
 	bs := []string{}
 	for _, b := range stm.Bindings() {
 		bs = append(bs, b)
@@ -184,59 +265,207 @@ func newQueryPlan(store storage.Store, stm *semantic.Statement) (*queryPlan, err
 	}
 	var gs []storage.Graph
 	for _, g := range stm.Graphs() {
-		ng, err := store.Graph(g)
+		ng, err := statementGraph(store, stm, g)
 		if err != nil {
 			return nil, err
 		}
 		gs = append(gs, ng)
 	}
+	cls := stm.SortedGraphPatternClauses()
+	if stm.Hints().Ordered {
+		cls = stm.OrderedGraphPatternClauses()
+	} else if st := graphStats(gs); st != nil {
+		cls = costOrder(cls, st)
+	}
 	return &queryPlan{
-		stm:       stm,
-		store:     store,
-		bndgs:     bs,
-		grfs:      gs,
-		grfsNames: stm.Graphs(),
-		cls:       stm.SortedGraphPatternClauses(),
-		tbl:       t,
+		stm:           stm,
+		store:         store,
+		bndgs:         bs,
+		grfs:          gs,
+		grfsNames:     stm.Graphs(),
+		cls:           cls,
+		tbl:           t,
+		productBuffer: defaultProductBuffer,
+		resultBuffer:  defaultResultBuffer,
 	}, nil
 }
 
-// processClause retrives the triples for the provided triple given the
-// information available.
-func (p *queryPlan) processClause(cls *semantic.GraphClause, lo *storage.LookupOptions) error {
-	// This method decides how to process the clause based on the current
-	// list of bindings solved and data available.
-	exist, total := 0, 0
+// joinClause returns the operator joining the rows pulled from the provided
+// one with the data of the clause, or the operator returning the data of the
+// clause if it is the first one. How the clause is joined is decided on the
+// bindings solved by the previous clauses, which are tracked on the plan
+// table.
+func (p *queryPlan) joinClause(ctx context.Context, in operator, cls *semantic.GraphClause, chk *bindingChecker, lo *storage.LookupOptions, prof *ClauseProfile) operator {
+	var shared []string
 	for _, b := range cls.Bindings() {
-		total++
 		if p.tbl.HasBinding(b) {
-			exist++
+			shared = append(shared, b)
 		}
 	}
-	if exist == 0 {
-		// Data is new.
-		tbl, err := simpleFetch(p.grfs, cls, lo)
+	bound := make(map[string]bool)
+	for _, b := range p.tbl.Bindings() {
+		bound[b] = true
+	}
+	component := p.boundComponent(cls)
+	p.tbl.AddBindings(cls.Bindings())
+	fetch := func() (*table.Table, error) {
+		return p.fetch(ctx, cls, lo, chk)
+	}
+	if in == nil {
+		return &fetchOperator{ctx: ctx, fetch: fetch, bgt: p.bgt}
+	}
+	switch exist, total := len(shared), len(cls.Bindings()); {
+	case exist == 0:
+		// No bindings are shared, hence the join is a cartesian product.
+		return &productOperator{ctx: ctx, in: in, fetch: fetch, buffer: p.productBuffer, bgt: p.bgt, prof: prof}
+	case exist < total && !component && rowIndependent(cls):
+		// The clause does not depend on the values of each row, hence its data is
+		// retrieved once and hash joined on the shared bindings.
+		return &hashJoinOperator{ctx: ctx, in: in, fetch: fetch, on: shared, bgt: p.bgt}
+	case exist < total || cls.PTransitive:
+		// The values of each row are substituted into the subject, predicate, or
+		// object of the clause to issue one index lookup per row. Clauses matching
+		// chains of triples are always resolved this way to follow them.
+		return &expandOperator{ctx: ctx, in: in, f: func(r table.Row) ([]table.Row, error) {
+			return p.specifiedRows(ctx, r, cls, chk, lo)
+		}}
+	default:
+		// Since all bindings in the clause are already solved, the clause becomes a
+		// fully specified triple. If the triple does not exist the row is dropped.
+		return &expandOperator{ctx: ctx, in: in, f: func(r table.Row) ([]table.Row, error) {
+			ok, err := p.exists(r, cls, bound, lo)
+			if !ok || err != nil {
+				return nil, err
+			}
+			return []table.Row{r}, nil
+		}}
+	}
+}
+
+// aggregations returns the aggregations listed on the select clause of the
+// statement.
+func aggregations(stm *semantic.Statement) []table.Aggregation {
+	var aggs []table.Aggregation
+	for _, prj := range stm.Projections() {
+		if prj.Aggregate {
+			aggs = append(aggs, table.Aggregation{
+				Kind:     prj.Kind,
+				Binding:  prj.Binding,
+				Alias:    prj.Alias,
+				Distinct: prj.Distinct,
+			})
+		}
+	}
+	return aggs
+}
+
+// groupBindings returns the bindings of the rows resulting from grouping the
+// rows of the plan table: the group by bindings followed by the aliases of
+// the aggregations.
+func (p *queryPlan) groupBindings(aggs []table.Aggregation) []string {
+	bs := append([]string{}, p.stm.GroupBy()...)
+	for _, a := range aggs {
+		bs = append(bs, a.Alias)
+	}
+	return bs
+}
+
+// group replaces the rows of the table by one row for each group of rows
+// sharing the values of the group by bindings, if the statement aggregates any
+// binding. The values of the other bindings listed on the select clause are
+// first bound to their aliases, so rows can be grouped by them.
+func (p *queryPlan) group() error {
+	aggs := aggregations(p.stm)
+	if len(aggs) == 0 {
+		return nil
+	}
+	var prjs []*semantic.Projection
+	for _, prj := range p.stm.Projections() {
+		if !prj.Aggregate && prj.Alias != "" {
+			prjs = append(prjs, prj)
+		}
+	}
+	if len(prjs) > 0 {
+		rws := p.tbl.Rows()
+		p.tbl.Truncate()
+		for _, r := range rws {
+			for _, prj := range prjs {
+				if c, ok := r[prj.Binding]; ok {
+					r[prj.Alias] = c
+				}
+			}
+			p.tbl.AddRow(r)
+		}
+		for _, prj := range prjs {
+			p.tbl.AddBindings([]string{prj.Alias})
+		}
+	}
+	tbl, err := p.tbl.Group(p.stm.GroupBy(), aggs)
+	if err != nil {
+		return err
+	}
+	p.tbl = tbl
+	return nil
+}
+
+// hold pulls all the rows of the operator into the plan table and closes it.
+// It returns the estimated number of bytes reserved for the rows on the memory
+// budget, which the caller needs to release once done with them.
+func (p *queryPlan) hold(op operator) (int64, error) {
+	var size int64
+	for {
+		r, err := op.next()
+		if err == nil && r != nil {
+			sz := p.bgt.sizeOf(r)
+			if err = p.bgt.require(sz); err == nil {
+				size += sz
+				p.tbl.AddRow(r)
+				continue
+			}
+		}
+		if cerr := op.close(); err == nil {
+			err = cerr
+		}
 		if err != nil {
-			return err
+			p.bgt.release(size)
+			return 0, err
 		}
-		if len(p.tbl.Bindings()) > 0 {
-			return p.tbl.DotProduct(tbl)
+		return size, nil
+	}
+}
+
+// fetch retrieves the data for the clause from the queried graphs. Identical
+// rows retrieved from different graphs are merged if the statement only
+// returns distinct rows or provides the dedup hint.
+func (p *queryPlan) fetch(ctx context.Context, cls *semantic.GraphClause, lo *storage.LookupOptions, chk *bindingChecker) (*table.Table, error) {
+	cls = coarsenClause(cls, lo.Granularity)
+	if cls.PTransitive {
+		tbl, err := p.fetchPath(ctx, cls, lo, chk)
+		if err != nil {
+			return nil, err
 		}
-		return p.tbl.AppendTable(tbl)
+		p.fetched += int64(tbl.NumRows())
+		return tbl, nil
 	}
-	if exist > 0 && exist < total {
-		// Data is partially binded, retrieve data either extends the row with the
-		// new bindings or filters it out if now new bindings are available.
-		return p.specifyClauseWithTable(cls, lo)
+	tbl, err := simpleFetch(ctx, p.grfs, cls, lo, chk)
+	if err != nil {
+		return nil, err
 	}
-	if exist > 0 && exist == total {
-		// Since all bindings in the clause are already solved, the clause becomes a
-		// fully specified triple. If the triple does not exist the row will be
-		// deleted.
-		return p.filterOnExistance(cls, lo)
+	p.fetched += int64(tbl.NumRows())
+	if len(p.grfs) > 1 && cls.GBinding == "" && (p.stm.IsDistinct() || p.stm.Hints().Dedup) {
+		tbl.Distinct()
 	}
-	// Somethign is wrong with the code.
-	return fmt.Errorf("queryPlan.processClause(%v) should have never failed to resolve the clause", cls)
+	return tbl, nil
+}
+
+// rowIndependent returns true if the data retrieved for the clause does not
+// depend on the values bound on each row, such as time bounds taken from
+// other bindings or embedded triple patterns. Clauses with no specified
+// component are never considered independent to avoid scanning whole graphs.
+func rowIndependent(cls *semantic.GraphClause) bool {
+	return cls.Specificity() > 0 && cls.OEmbedded == nil &&
+		cls.PLowerBoundAlias == "" && cls.PUpperBoundAlias == "" &&
+		cls.OLowerBoundAlias == "" && cls.OUpperBoundAlias == ""
 }
 
 // getBindedValueForComponent return the unique binded value if available on
@@ -248,74 +477,95 @@ func getBindedValueForComponent(r table.Row, bs []string) *table.Cell {
 			cs = append(cs, v)
 		}
 	}
-	if len(cs) == 1 || len(cs) == 2 && reflect.DeepEqual(cs[0], cs[1]) {
+	if len(cs) == 1 || len(cs) == 2 && cs[0].Equal(cs[1]) {
 		return cs[0]
 	}
 	return nil
 }
 
-// addSpecifiedData specializes the clause given the row provided and attemp to
-// retrieve the correspoinding clause data.
-func (p *queryPlan) addSpecifiedData(r table.Row, cls *semantic.GraphClause, lo *storage.LookupOptions) error {
+// specifiedRows specializes the clause given the row provided and returns the
+// row extended with each of the correspoinding clause data rows. The values
+// bound on the row are substituted into the clause so the lookup can use the
+// graph indices. Rows whose bound values cannot be used on the clause position
+// produce no data.
+func (p *queryPlan) specifiedRows(ctx context.Context, r table.Row, cls *semantic.GraphClause, chk *bindingChecker, lo *storage.LookupOptions) ([]table.Row, error) {
+	tmp := *cls
+	cls = &tmp
+	if cls.Graph == "" && cls.GBinding != "" {
+		if v, ok := r[cls.GBinding]; ok {
+			cls.Graph = v.S
+		}
+	}
 	if cls.S == nil {
 		v := getBindedValueForComponent(r, []string{cls.SBinding, cls.SAlias})
 		if v != nil {
-			if v.N != nil {
-				cls.S = v.N
+			if v.N == nil {
+				return nil, nil
 			}
+			cls.S = v.N
 		}
 	}
 	if cls.P == nil {
 		v := getBindedValueForComponent(r, []string{cls.PBinding, cls.PAlias})
 		if v != nil {
-			if v.N != nil {
-				cls.P = v.P
+			if v.P == nil {
+				return nil, nil
 			}
+			cls.P = v.P
 		}
 		nlo, err := updateTimeBoundsForRow(lo, cls, r)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		lo = nlo
 	}
+	if cls.O == nil && cls.OEmbedded != nil {
+		o, err := embeddedObject(cls.OEmbedded, r)
+		if err != nil {
+			return nil, err
+		}
+		cls.O = o
+	}
 	if cls.O == nil {
-		v := getBindedValueForComponent(r, []string{cls.PBinding, cls.PAlias})
+		v := getBindedValueForComponent(r, []string{cls.OBinding, cls.OAlias})
 		if v != nil {
 			o, err := cellToObject(v)
-			if err == nil {
-				cls.O = o
+			if err != nil {
+				return nil, nil
 			}
+			cls.O = o
 		}
 		nlo, err := updateTimeBoundsForRow(lo, cls, r)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		lo = nlo
 	}
-	tbl, err := simpleFetch(p.grfs, cls, lo)
+	tbl, err := p.fetch(ctx, cls, lo, chk)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	p.tbl.AddBindings(tbl.Bindings())
+	var rws []table.Row
 	for _, nr := range tbl.Rows() {
-		p.tbl.AddRow(table.MergeRows([]table.Row{r, nr}))
+		if chk.compatible(r, nr) {
+			rws = append(rws, table.MergeRows([]table.Row{r, nr}))
+		}
 	}
-	return nil
+	return rws, nil
 }
 
-// specifyClauseWithTable runs the clause, but it specifies it further based on
-// the current row being processed.
-func (p *queryPlan) specifyClauseWithTable(cls *semantic.GraphClause, lo *storage.LookupOptions) error {
-	rws := p.tbl.Rows()
-	p.tbl.Truncate()
-	for _, r := range rws {
-		tmpCls := &semantic.GraphClause{}
-		*tmpCls = *cls
-		if err := p.addSpecifiedData(r, tmpCls, lo); err != nil {
-			return err
+// boundComponent returns true if the subject, predicate, or object of the
+// clause is bound to a binding already available on the table. Such clauses
+// can be resolved by substituting the values of each row into the clause.
+func (p *queryPlan) boundComponent(cls *semantic.GraphClause) bool {
+	for _, b := range []string{
+		cls.SBinding, cls.SAlias, cls.PBinding, cls.PAlias, cls.OBinding, cls.OAlias,
+	} {
+		if b != "" && p.tbl.HasBinding(b) {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
 // cellToObject returns an object for the given cell.
@@ -332,6 +582,9 @@ func cellToObject(c *table.Cell) (*triple.Object, error) {
 	if c.L != nil {
 		return triple.NewLiteralObject(c.L), nil
 	}
+	if c.E != nil {
+		return triple.NewTripleObject(c.E), nil
+	}
 	if c.S != "" {
 		l, err := literal.DefaultBuilder().Parse(fmt.Sprintf(`"%s"^^type:string`, c.S))
 		if err != nil {
@@ -342,135 +595,404 @@ func cellToObject(c *table.Cell) (*triple.Object, error) {
 	return nil, fmt.Errorf("invalid cell %v", c)
 }
 
-// filterOnExistance removes rows based on the existance of the fully qualified
-// triple after the biding of the clause.
-func (p *queryPlan) filterOnExistance(cls *semantic.GraphClause, lo *storage.LookupOptions) error {
-	for idx, r := range p.tbl.Rows() {
-		sbj, prd, obj := cls.S, cls.P, cls.O
-		// Attempt to rebind the subject.
-		if sbj == nil && p.tbl.HasBinding(cls.SBinding) {
-			v, ok := r[cls.SBinding]
-			if !ok {
-				return fmt.Errorf("row %+v misses binding %q", r, cls.SBinding)
-			}
-			if v.N == nil {
-				return fmt.Errorf("binding %q requires a node, got %+v instead", cls.SBinding, v)
-			}
-			sbj = v.N
+// exists returns true if the fully qualified triple resulting from binding the
+// clause to the values of the row exists. The provided bindings are the ones
+// solved before the clause.
+func (p *queryPlan) exists(r table.Row, cls *semantic.GraphClause, bound map[string]bool, lo *storage.LookupOptions) (bool, error) {
+	sbj, prd, obj := cls.S, cls.P, cls.O
+	// Attempt to rebind the subject.
+	if sbj == nil && bound[cls.SBinding] {
+		v, ok := r[cls.SBinding]
+		if !ok {
+			return false, fmt.Errorf("row %+v misses binding %q", r, cls.SBinding)
 		}
-		if sbj == nil && p.tbl.HasBinding(cls.SAlias) {
-			v, ok := r[cls.SAlias]
-			if !ok {
-				return fmt.Errorf("row %+v misses binding %q", r, cls.SAlias)
-			}
-			if v.N == nil {
-				return fmt.Errorf("binding %q requires a node, got %+v instead", cls.SAlias, v)
-			}
-			sbj = v.N
+		if v.N == nil {
+			return false, fmt.Errorf("binding %q requires a node, got %+v instead", cls.SBinding, v)
 		}
-		// Attempt to rebind the predicate.
-		if prd == nil && p.tbl.HasBinding(cls.PBinding) {
-			v, ok := r[cls.PBinding]
-			if !ok {
-				return fmt.Errorf("row %+v misses binding %q", r, cls.PBinding)
-			}
-			if v.P == nil {
-				return fmt.Errorf("binding %q requires a predicate, got %+v instead", cls.PBinding, v)
-			}
-			prd = v.P
+		sbj = v.N
+	}
+	if sbj == nil && bound[cls.SAlias] {
+		v, ok := r[cls.SAlias]
+		if !ok {
+			return false, fmt.Errorf("row %+v misses binding %q", r, cls.SAlias)
 		}
-		if prd == nil && p.tbl.HasBinding(cls.PAlias) {
-			v, ok := r[cls.PAlias]
-			if !ok {
-				return fmt.Errorf("row %+v misses binding %q", r, cls.SAlias)
-			}
-			if v.N == nil {
-				return fmt.Errorf("binding %q requires a predicate, got %+v instead", cls.SAlias, v)
-			}
-			prd = v.P
+		if v.N == nil {
+			return false, fmt.Errorf("binding %q requires a node, got %+v instead", cls.SAlias, v)
 		}
-		// Attempt to rebind the object.
-		if obj == nil && p.tbl.HasBinding(cls.PBinding) {
-			v, ok := r[cls.OBinding]
-			if !ok {
-				return fmt.Errorf("row %+v misses binding %q", r, cls.OBinding)
-			}
-			if v.P == nil {
-				return fmt.Errorf("binding %q requires a object, got %+v instead", cls.OBinding, v)
-			}
-			co, err := cellToObject(v)
-			if err != nil {
-				return err
-			}
-			obj = co
+		sbj = v.N
+	}
+	// Attempt to rebind the predicate.
+	if prd == nil && bound[cls.PBinding] {
+		v, ok := r[cls.PBinding]
+		if !ok {
+			return false, fmt.Errorf("row %+v misses binding %q", r, cls.PBinding)
 		}
-		if obj == nil && p.tbl.HasBinding(cls.OAlias) {
-			v, ok := r[cls.OAlias]
-			if !ok {
-				return fmt.Errorf("row %+v misses binding %q", r, cls.OAlias)
-			}
-			if v.N == nil {
-				return fmt.Errorf("binding %q requires a object, got %+v instead", cls.OAlias, v)
-			}
-			co, err := cellToObject(v)
-			if err != nil {
-				return err
-			}
-			obj = co
+		if v.P == nil {
+			return false, fmt.Errorf("binding %q requires a predicate, got %+v instead", cls.PBinding, v)
 		}
-		// Attempt to filter.
-		if sbj == nil || prd == nil || obj == nil {
-			return fmt.Errorf("failed to fully specify clause %v for row %+v", cls, r)
+		prd = v.P
+	}
+	if prd == nil && bound[cls.PAlias] {
+		v, ok := r[cls.PAlias]
+		if !ok {
+			return false, fmt.Errorf("row %+v misses binding %q", r, cls.PAlias)
 		}
-		for _, g := range p.stm.Graphs() {
-			t, err := triple.New(sbj, prd, obj)
-			if err != nil {
-				return err
-			}
-			gph, err := p.store.Graph(g)
-			if err != nil {
-				return err
-			}
-			b, err := gph.Exist(t)
-			if err != nil {
-				return err
-			}
-			if b {
-				p.tbl.DeleteRow(idx)
-			}
+		if v.P == nil {
+			return false, fmt.Errorf("binding %q requires a predicate, got %+v instead", cls.PAlias, v)
 		}
+		prd = v.P
 	}
-	return nil
+	// Predicates matched by ID are anchored at the time bound to their anchor.
+	// It is coarsened along with the triple when checking its existence.
+	if prd == nil && cls.PID != "" {
+		np, err := idPredicate(cls.PID, r, cls.PAnchorBinding, cls.PAnchorAlias)
+		if err != nil {
+			return false, err
+		}
+		prd = np
+	}
+	// Attempt to rebind the object.
+	if obj == nil && bound[cls.OBinding] {
+		v, ok := r[cls.OBinding]
+		if !ok {
+			return false, fmt.Errorf("row %+v misses binding %q", r, cls.OBinding)
+		}
+		co, err := cellToObject(v)
+		if err != nil {
+			return false, err
+		}
+		obj = co
+	}
+	if obj == nil && bound[cls.OAlias] {
+		v, ok := r[cls.OAlias]
+		if !ok {
+			return false, fmt.Errorf("row %+v misses binding %q", r, cls.OAlias)
+		}
+		if v.N == nil {
+			return false, fmt.Errorf("binding %q requires a object, got %+v instead", cls.OAlias, v)
+		}
+		co, err := cellToObject(v)
+		if err != nil {
+			return false, err
+		}
+		obj = co
+	}
+	if obj == nil && cls.OEmbedded != nil {
+		co, err := embeddedObject(cls.OEmbedded, r)
+		if err != nil {
+			return false, err
+		}
+		obj = co
+	}
+	if obj == nil && cls.OID != "" {
+		op, err := idPredicate(cls.OID, r, cls.OAnchorBinding, cls.OAnchorAlias)
+		if err != nil {
+			return false, err
+		}
+		if op != nil {
+			obj = triple.NewPredicateObject(op)
+		}
+	}
+	// Attempt to filter.
+	if sbj == nil || prd == nil || obj == nil {
+		return false, fmt.Errorf("failed to fully specify clause %v for row %+v", cls, r)
+	}
+	gs := p.stm.Graphs()
+	if cls.Graph != "" {
+		gs = []string{cls.Graph}
+	} else if v, ok := r[cls.GBinding]; ok {
+		gs = []string{v.S}
+	}
+	t, err := triple.New(sbj, prd, obj)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range gs {
+		gph, err := statementGraph(p.store, p.stm, g)
+		if err != nil {
+			return false, err
+		}
+		if cls.PLatest {
+			gph = latestGraphs([]storage.Graph{gph}, updateTimeBounds(lo, cls))[0]
+		}
+		b, err := existCoarsened(gph, t, lo.Granularity)
+		if err != nil {
+			return false, err
+		}
+		if b {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
-// processGraphPattern proces the query graph pattern to retrieve the
-// data from the specified graphs.
-func (p *queryPlan) processGraphPattern(lo *storage.LookupOptions) error {
-	for _, cls := range p.cls {
-		// The current planner is based on naively excecuting clauses by
-		// specificity.
-		if err := p.processClause(cls, lo); err != nil {
+// graphPattern returns the operator resolving the query graph pattern with the
+// data of the specified graphs. The clauses are joined in the order they were
+// sorted, and rows flow through all of them as they are pulled.
+func (p *queryPlan) graphPattern(ctx context.Context, lo *storage.LookupOptions) operator {
+	p.chks = make([]*bindingChecker, len(p.cls))
+	var (
+		op   operator
+		last *clauseOperator
+	)
+	for i, cls := range p.cls {
+		p.chks[i] = newBindingChecker()
+		prof := &ClauseProfile{Clause: clauseString(cls)}
+		last = &clauseOperator{in: p.joinClause(ctx, op, cls, p.chks[i], lo, prof), prev: last, p: p, prof: prof}
+		op = last
+		if p.prof != nil {
+			p.prof.Clauses = append(p.prof.Clauses, prof)
+		}
+	}
+	if op == nil {
+		return &scanOperator{}
+	}
+	return op
+}
+
+// minusTables resolves the statement minus patterns, and returns the tables
+// holding their solutions and the estimated number of bytes reserved for them
+// on the memory budget.
+func (p *queryPlan) minusTables(ctx context.Context, lo *storage.LookupOptions) ([]*table.Table, int64, error) {
+	p.mps = nil
+	var (
+		tbls []*table.Table
+		size int64
+	)
+	for _, cls := range p.stm.MinusPatterns() {
+		t, err := table.New([]string{})
+		if err != nil {
+			p.bgt.release(size)
+			return nil, 0, err
+		}
+		mp := &queryPlan{
+			stm:           p.stm,
+			store:         p.store,
+			grfs:          p.grfs,
+			grfsNames:     p.grfsNames,
+			cls:           cls,
+			tbl:           t,
+			lmts:          p.lmts,
+			productBuffer: p.productBuffer,
+			bgt:           p.bgt,
+		}
+		p.mps = append(p.mps, mp)
+		n, err := mp.hold(mp.graphPattern(ctx, lo))
+		if err != nil {
+			p.bgt.release(size)
+			return nil, 0, err
+		}
+		size += n
+		tbls = append(tbls, mp.tbl)
+	}
+	return tbls, size, nil
+}
+
+// prefetch passes the statement prefetch hints to all the queried graphs that
+// support them, unless disabled by the noprefetch query hint.
+func (p *queryPlan) prefetch() error {
+	if p.stm.Hints().NoPrefetch {
+		return nil
+	}
+	var h *storage.PrefetchHints
+	for _, g := range p.grfs {
+		pf, ok := g.(storage.Prefetcher)
+		if !ok {
+			continue
+		}
+		if h == nil {
+			h = p.stm.PrefetchHints()
+		}
+		if err := pf.Prefetch(h); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Execute queries the indicated graphs.
-func (p *queryPlan) Excecute() (*table.Table, error) {
-	// Retrieve the data.
-	lo := &storage.LookupOptions{}
-	if err := p.processGraphPattern(lo); err != nil {
+// rowLimit returns the number of rows after which no more data needs to be
+// retrieved, or 0 if all of it is required. Retrieval can only stop early for
+// single clause graph patterns whose results are trimmed by a limit without
+// being filtered, deduplicated, sorted, grouped, or subtracted first.
+func (p *queryPlan) rowLimit() int {
+	stm := p.stm
+	if !stm.IsLimitSet() || stm.Limit() <= 0 || stm.IsLimitPerGroup() {
+		return 0
+	}
+	if len(stm.OrderBy()) > 0 || stm.HavingEvaluator() != nil || len(stm.MinusPatterns()) > 0 {
+		return 0
+	}
+	if stm.IsDistinct() || stm.Hints().Dedup {
+		return 0
+	}
+	n := 0
+	for _, cls := range p.cls {
+		if cls != nil && !cls.IsEmpty() {
+			n++
+		}
+	}
+	if n != 1 {
+		return 0
+	}
+	return int(stm.Limit())
+}
+
+// emptyReason returns why the query is known to return no results without
+// retrieving any data, or an empty string if it may return some. Queries are
+// statically empty if the time bounds of any of their clauses only matching
+// temporal predicates contradict the global ones, if any of their clauses is
+// scoped to a graph not being queried, or if their having clause is always
+// false.
+func (p *queryPlan) emptyReason() string {
+	if e := p.stm.HavingEvaluator(); e != nil {
+		if v, ok := semantic.ConstantValue(e); ok && !v {
+			return "having clause is always false"
+		}
+	}
+	glo := p.stm.GlobalLookupOptions()
+	for _, cls := range p.cls {
+		// Time bounds are only enforced on temporal predicates.
+		temporal := cls.PTemporal || cls.P != nil && cls.P.Type() == predicate.Temporal
+		if lo := updateTimeBounds(glo, cls); temporal && contradictoryBounds(lo.LowerAnchor, lo.UpperAnchor) {
+			return fmt.Sprintf("clause %s has contradictory time bounds", clauseString(cls))
+		}
+		if cls.Graph != "" && !contains(p.grfsNames, cls.Graph) {
+			return fmt.Sprintf("clause %s is scoped to graph %s which is not queried", clauseString(cls), cls.Graph)
+		}
+	}
+	return ""
+}
+
+// contradictoryBounds returns true if both time bounds are set and no time
+// can satisfy them.
+func contradictoryBounds(lower, upper *time.Time) bool {
+	return lower != nil && upper != nil && lower.After(*upper)
+}
+
+// stream returns a stream that retrieves the data for the indicated graphs,
+// and filters, sorts, and trims the results as rows are pulled from it.
+func (p *queryPlan) stream(ctx context.Context) (*rowStream, error) {
+	if err := p.checkGraphs(); err != nil {
+		return nil, err
+	}
+	if err := refreshViews(ctx, p.store, p.grfsNames); err != nil {
 		return nil, err
 	}
-	return p.tbl, nil
+	if err := p.applyRules(ctx); err != nil {
+		return nil, err
+	}
+	p.prof = &Profile{}
+	p.bgt = newMemoryBudget(p.lmts)
+	parent, cancel := ctx, func() {}
+	if d := p.lmts.MaxExecutionTime; d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
+	}
+	empty := p.emptyReason() != ""
+	if !empty {
+		st, start := p.prof.stage("prefetch"), time.Now()
+		if err := p.prefetch(); err != nil {
+			cancel()
+			return nil, err
+		}
+		st.Duration = time.Since(start)
+	}
+	op, bs := p.newPipeline(ctx, empty)
+	return &rowStream{
+		ctx:    ctx,
+		parent: parent,
+		cancel: cancel,
+		lmts:   p.lmts,
+		bs:     bs,
+		op:     op,
+		prof:   p.prof,
+	}, nil
+}
+
+// Execute queries the indicated graphs. Once the results have more rows than
+// the result buffer, or they do not fit on the memory budget, the remaining
+// ones are spilled to disk on the returned table until they are read; such
+// tables should be closed once no longer needed.
+func (p *queryPlan) Excecute(ctx context.Context) (*table.Table, error) {
+	rs, err := p.stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tbl, err := table.New(rs.Bindings())
+	if err != nil {
+		rs.Close()
+		return nil, err
+	}
+	// The rows held by the table are only accounted for on the budget while
+	// the query runs.
+	var held int64
+	defer func() {
+		p.bgt.release(held)
+	}()
+	spill := false
+	for rs.Next() {
+		r := rs.Row()
+		if !spill {
+			sz := p.bgt.sizeOf(r)
+			if spill = (p.resultBuffer > 0 && tbl.NumRows() >= p.resultBuffer) || !p.bgt.reserve(sz); !spill {
+				held += sz
+				tbl.AddRow(r)
+				continue
+			}
+		}
+		if err := tbl.SpillRow(r); err != nil {
+			rs.Close()
+			tbl.Close()
+			return nil, fmt.Errorf("planner.Excecute: %v", err)
+		}
+	}
+	if err := rs.Err(); err != nil {
+		tbl.Close()
+		rs.Close()
+		// Limit errors and the ones caused by the context are returned unchanged.
+		if _, ok := err.(*LimitError); ok || err == ctx.Err() {
+			return nil, err
+		}
+		return nil, fmt.Errorf("planner.Excecute: %v", err)
+	}
+	if err := rs.Close(); err != nil {
+		tbl.Close()
+		return nil, fmt.Errorf("planner.Excecute: %v", err)
+	}
+	return tbl, nil
+}
+
+// Profile returns the metrics collected while executing the query.
+func (p *queryPlan) Profile() *Profile {
+	return p.prof
 }
 
 // New create a new executable plan given a semantic BQL statement.
 func New(store storage.Store, stm *semantic.Statement) (Excecutor, error) {
+	return NewWithLimits(store, stm, Limits{})
+}
+
+// NewWithLimits create a new executable plan given a semantic BQL statement.
+// Queries fail with a LimitError if they exceed any of the provided limits.
+// The statement is first transformed by the rewriters registered using
+// semantic.RegisterRewriter.
+func NewWithLimits(store storage.Store, stm *semantic.Statement, lmts Limits) (Excecutor, error) {
+	p, err := Prepare(stm)
+	if err != nil {
+		return nil, err
+	}
+	return p.Plan(store, lmts)
+}
+
+// newPlan create a new executable plan given an already prepared statement.
+func newPlan(store storage.Store, stm *semantic.Statement, lmts Limits) (Excecutor, error) {
 	switch stm.Type() {
 	case semantic.Query:
-		return newQueryPlan(store, stm)
+		p, err := preparedQueryPlan(store, stm)
+		if err != nil {
+			return nil, err
+		}
+		p.lmts = lmts
+		return p, nil
 	case semantic.Insert:
 		return &insertPlan{
 			stm:   stm,
@@ -491,6 +1013,16 @@ func New(store storage.Store, stm *semantic.Statement) (Excecutor, error) {
 			stm:   stm,
 			store: store,
 		}, nil
+	case semantic.Analyze:
+		return &analyzePlan{
+			stm:   stm,
+			store: store,
+		}, nil
+	case semantic.CreateView:
+		return &createViewPlan{
+			stm:   stm,
+			store: store,
+		}, nil
 	default:
 		return nil, fmt.Errorf("planner.New: unknown statement type in statement %v", stm)
 	}
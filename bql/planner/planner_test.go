@@ -16,15 +16,22 @@ package planner
 
 import (
 	"bytes"
+	"context"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/badwolf/bql/grammar"
 	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
 	"github.com/google/badwolf/io"
 	"github.com/google/badwolf/storage"
 	"github.com/google/badwolf/storage/memory"
 	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
 )
 
 func insertTest(t *testing.T) {
@@ -43,7 +50,7 @@ func insertTest(t *testing.T) {
 	if err != nil {
 		t.Errorf("planner.New: should have not failed to create a plan using memory.DefaultStorage for statement %v", stm)
 	}
-	if _, err := pln.Excecute(); err != nil {
+	if _, err := pln.Excecute(context.Background()); err != nil {
 		t.Errorf("planner.Execute: failed to execute insert plan with error %v", err)
 	}
 	g, err := memory.DefaultStore.Graph("?a")
@@ -79,7 +86,7 @@ func deleteTest(t *testing.T) {
 	if err != nil {
 		t.Errorf("planner.New: should have not failed to create a plan using memory.DefaultStorage for statement %v", stm)
 	}
-	if _, err := pln.Excecute(); err != nil {
+	if _, err := pln.Excecute(context.Background()); err != nil {
 		t.Errorf("planner.Execute: failed to execute insert plan with error %v", err)
 	}
 	g, err := memory.DefaultStore.Graph("?a")
@@ -146,7 +153,7 @@ func TestCreateGraph(t *testing.T) {
 	if err != nil {
 		t.Errorf("planner.New: should have not failed to create a plan using memory.DefaultStorage for statement %v", stm)
 	}
-	if _, err := pln.Excecute(); err != nil {
+	if _, err := pln.Excecute(context.Background()); err != nil {
 		t.Errorf("planner.Execute: failed to execute insert plan with error %v", err)
 	}
 	if _, err := memory.DefaultStore.Graph("?foo"); err != nil {
@@ -176,7 +183,7 @@ func TestDropGraph(t *testing.T) {
 	if err != nil {
 		t.Errorf("planner.New: should have not failed to create a plan using memory.DefaultStorage for statement %v", stm)
 	}
-	if _, err := pln.Excecute(); err != nil {
+	if _, err := pln.Excecute(context.Background()); err != nil {
 		t.Errorf("planner.Execute: failed to execute insert plan with error %v", err)
 	}
 	if g, err := memory.DefaultStore.Graph("?foo"); err == nil {
@@ -187,6 +194,41 @@ func TestDropGraph(t *testing.T) {
 	}
 }
 
+func TestQuotedGraphNames(t *testing.T) {
+	s := memory.NewStore()
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
+	}
+	exec := func(bql string) *table.Table {
+		stm := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(bql, 1), stm); err != nil {
+			t.Fatalf("Parser.consume: failed to accept BQL %q with error %v", bql, err)
+		}
+		pln, err := New(s, stm)
+		if err != nil {
+			t.Fatalf("planner.New: failed to create plan for %q with error %v", bql, err)
+		}
+		tbl, err := pln.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Execute: failed to execute %q with error %v", bql, err)
+		}
+		return tbl
+	}
+	exec(`create graph "/prod/users";`)
+	if _, err := s.Graph("/prod/users"); err != nil {
+		t.Fatalf("planner.Execute: failed to create graph %q with error %v", "/prod/users", err)
+	}
+	exec(`insert data into "/prod/users" {/u<joe> "knows"@[] /u<mary>};`)
+	if got, want := exec(`select ?o from "/prod/users" where {/u<joe> "knows"@[] ?o};`).NumRows(), 1; got != want {
+		t.Errorf("planner.Execute: got %d rows querying a quoted graph, want %d", got, want)
+	}
+	exec(`drop graph "/prod/users";`)
+	if g, err := s.Graph("/prod/users"); err == nil {
+		t.Errorf("planner.Execute: failed to drop graph %q; returned %v", "/prod/users", g)
+	}
+}
+
 const testTriples = `
 	/u<joe> "parent_of"@[] /u<mary>
   /u<joe> "parent_of"@[] /u<peter>
@@ -246,16 +288,6 @@ func TestQuery(t *testing.T) {
 			nbs:  2,
 			nrws: 2,
 		},
-		{
-			q:    `select ?s, ?o from ?test where {?s ?p /t<car>};`,
-			nbs:  2,
-			nrws: 4,
-		},
-		{
-			q:    `select ?s, ?p from ?test where {?s "parent_of"@[] ?o};`,
-			nbs:  2,
-			nrws: 4,
-		},
 		{
 			q:    `select ?s, ?p, ?o from ?test where {/u<joe> as ?s "parent_of"@[] as ?p /u<mary> as ?o};`,
 			nbs:  3,
@@ -281,11 +313,6 @@ func TestQuery(t *testing.T) {
 			nbs:  1,
 			nrws: 4,
 		},
-		{
-			q:    `select ?s from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] /u<john>};`,
-			nbs:  1,
-			nrws: 1,
-		},
 		{
 			q:    `select ?s, ?o from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] ?s};`,
 			nbs:  2,
@@ -336,6 +363,81 @@ func TestQuery(t *testing.T) {
 			nbs:  1,
 			nrws: 4,
 		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[2016] ?o};`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[2016-02] ?o};`,
+			nbs:  1,
+			nrws: 1,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[2016-02-01,2016-03] ?o};`,
+			nbs:  1,
+			nrws: 2,
+		},
+		{
+			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[2016-03] as ?o};`,
+			nbs:  1,
+			nrws: 1,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} between "x"@[2016-01], "x"@[2016-02];`,
+			nbs:  1,
+			nrws: 2,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} before now;`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} after now - "24h"^^type:duration;`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} between "x"@[2016-01-15T00:00:00-08:00], "x"@[2016-03-15T00:00:00-08:00];`,
+			nbs:  1,
+			nrws: 2,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} after "x"@[2016-01-15T00:00:00-08:00] and before now - "24h"^^type:duration;`,
+			nbs:  1,
+			nrws: 3,
+		},
+		{
+			q:    `select ?s, ?p, ?o from ?test where {?s ?p ?o} before "x"@[2016-01-15T00:00:00-08:00];`,
+			nbs:  3,
+			nrws: 13,
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} minus {?s "bought"@[,] ?c};`,
+			nbs:  2,
+			nrws: 2,
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} minus {?o "parent_of"@[] ?k};`,
+			nbs:  2,
+			nrws: 3,
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} minus {?x "bought"@[,] ?c};`,
+			nbs:  2,
+			nrws: 4,
+		},
+		{
+			q:    `select ?s, ?p, ?o from ?test where {?s ?p ?o} limit "5"^^type:int64;`,
+			nbs:  3,
+			nrws: 5,
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} group by ?s limit "1"^^type:int64 per group;`,
+			nbs:  2,
+			nrws: 2,
+		},
 	}
 
 	s := populateTestStore(t)
@@ -352,7 +454,7 @@ func TestQuery(t *testing.T) {
 		if err != nil {
 			t.Errorf("planner.New failed to create a valid query plan with error %v", err)
 		}
-		tbl, err := plnr.Excecute()
+		tbl, err := plnr.Excecute(context.Background())
 		if err != nil {
 			t.Errorf("planner.Excecute failed for query %q with error %v", entry.q, err)
 		}
@@ -364,3 +466,1063 @@ func TestQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryValidation(t *testing.T) {
+	testTable := []struct {
+		q    string
+		kind semantic.ValidationErrorKind
+	}{
+		{`select ?s, ?o from ?test where {?s ?p /t<car>};`, semantic.UnboundProjection},
+		{`select ?s, ?p from ?test where {?s "parent_of"@[] ?o};`, semantic.UnboundProjection},
+		{`select ?s from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] /u<john>};`, semantic.UnboundProjection},
+		{`select ?s, count(?o) as ?n from ?test where {?s "parent_of"@[] ?o} group by ?x;`, semantic.UnboundGroupBy},
+		{`select ?s, count(?o) as ?n from ?test where {?s "parent_of"@[] ?o};`, semantic.UngroupedProjection},
+		{`select ?s, count(?o) as ?s from ?test where {?s "parent_of"@[] ?o} group by ?s;`, semantic.AliasCollision},
+	}
+	s := populateTestStore(t)
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	for _, entry := range testTable {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(entry.q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", entry.q, err)
+		}
+		_, err := New(s, st)
+		errs, ok := err.(semantic.ValidationErrors)
+		if !ok {
+			t.Errorf("planner.New should have failed to validate %q; got %v", entry.q, err)
+			continue
+		}
+		if !errs.Has(entry.kind) {
+			t.Errorf("planner.New returned %v for %q; want a %v error", errs, entry.q, entry.kind)
+		}
+	}
+}
+
+func TestQueryLimitPerGroupOrdering(t *testing.T) {
+	q := `select ?s, ?o, ?t
+	      from ?test
+	      where {?s "bought"@[?t] ?o}
+	      group by ?s
+	      order by ?t desc
+	      limit "2"^^type:int64 per group;`
+	s := populateTestStore(t)
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+	}
+	plnr, err := New(s, st)
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+	}
+	tbl, err := plnr.Excecute(context.Background())
+	if err != nil {
+		t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+	}
+	var got []string
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?o"].String())
+	}
+	if want := []string{"/c<model y>", "/c<model x>"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planner.Excecute failed to return the most recent purchases; got %v, want %v", got, want)
+	}
+}
+
+func TestQueryOrderByExpression(t *testing.T) {
+	s := memory.NewStore()
+	g, err := s.NewGraph("?test")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	tpls := `/u<joe> "name"@[] "Joe"^^type:text
+	/u<mary> "name"@[] "Mary Ann"^^type:text
+	/u<peter> "name"@[] "Pete"^^type:text
+	/u<alice> "name"@[] "Al"^^type:text`
+	if _, err := io.ReadIntoGraph(g, bytes.NewBufferString(tpls), literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q:    `select ?s from ?test where {?s "name"@[] ?n} order by strlen(?n) desc;`,
+			want: []string{"/u<mary>", "/u<peter>", "/u<joe>", "/u<alice>"},
+		},
+		{
+			q:    `select ?s from ?test where {?s "name"@[] ?n} order by strlen(?n), ?n desc;`,
+			want: []string{"/u<alice>", "/u<joe>", "/u<peter>", "/u<mary>"},
+		},
+		{
+			q:    `select ?s from ?test where {?s "name"@[] ?n} order by strlen(?n) desc limit "3"^^type:int64 with hint(sortbuffer "1"^^type:int64);`,
+			want: []string{"/u<mary>", "/u<peter>", "/u<joe>"},
+		},
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	for _, entry := range testTable {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(entry.q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", entry.q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		var got []string
+		for _, r := range tbl.Rows() {
+			got = append(got, r["?s"].String())
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Excecute returned the wrong order for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestQueryHavingNumericWidening(t *testing.T) {
+	s := memory.NewStore()
+	g, err := s.NewGraph("?test")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	tpls := `/c<mini> "seats"@[] "4"^^type:int64
+	/c<model s> "seats"@[] "5"^^type:int64
+	/c<model x> "seats"@[] "7"^^type:int64
+	/c<model x> "price"@[] "80000.5"^^type:float64`
+	if _, err := io.ReadIntoGraph(g, bytes.NewBufferString(tpls), literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{
+			q:    `select ?s, ?n from ?test where {?s "seats"@[] ?n} having ?n > "4.5"^^type:float64;`,
+			nrws: 2,
+		},
+		{
+			q:    `select ?s, ?n from ?test where {?s "seats"@[] ?n} having ?n = "5.0"^^type:float64 or ?n < "4.5"^^type:float64;`,
+			nrws: 2,
+		},
+		{
+			q:    `select ?s, ?n from ?test where {?s "seats"@[] ?n} having not "5"^^type:int64 < ?n;`,
+			nrws: 2,
+		},
+		{
+			q:    `select ?s, ?n from ?test where {?s "price"@[] ?n} having cast(?n as type:int64) = "80000"^^type:int64;`,
+			nrws: 1,
+		},
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	for _, entry := range testTable {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(entry.q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", entry.q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		if got, want := len(tbl.Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+	}
+}
+
+func TestQueryEmbeddedTriples(t *testing.T) {
+	s := memory.NewStore()
+	if _, err := s.NewGraph("?test"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	run := func(q string) *table.Table {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+		}
+		return tbl
+	}
+	run(`insert data into ?test {
+	       /u<joe> "claims"@[] <</u<mary> "parent_of"@[] /u<peter>>> .
+	       /u<eve> "claims"@[] <</u<mary> "parent_of"@[] /u<john>>> .
+	       /u<eve> "claims"@[] /u<nothing>
+	     };`)
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{`select ?s from ?test where {?s "claims"@[] <</u<mary> "parent_of"@[] /u<peter>>>};`, 1},
+		{`select ?s, ?c from ?test where {?s "claims"@[] <</u<mary> "parent_of"@[] ?c>>};`, 2},
+		{`select ?s, ?x, ?p, ?c from ?test where {?s "claims"@[] <<?x ?p ?c>>};`, 2},
+		{`select ?s, ?c from ?test where {/u<eve> "claims"@[] <<?s "parent_of"@[] ?c>>};`, 1},
+		{`select ?s, ?o from ?test where {?s "claims"@[] ?o};`, 3},
+	}
+	for _, entry := range testTable {
+		if got, want := len(run(entry.q).Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+	}
+	tbl := run(`select ?c from ?test where {/u<joe> "claims"@[] <</u<mary> "parent_of"@[] ?c>>};`)
+	if rws := tbl.Rows(); len(rws) != 1 || rws[0]["?c"].String() != "/u<peter>" {
+		t.Errorf("planner.Excecute failed to bind the embedded triple object; got %v", rws)
+	}
+}
+
+func TestQuerySubjectTypeFilter(t *testing.T) {
+	s := memory.NewStore()
+	if _, err := s.NewGraph("?test"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	run := func(q string) *table.Table {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+		}
+		return tbl
+	}
+	run(`insert data into ?test {
+	       /organization<acme> "name"@[] "Acme"^^type:text .
+	       /organization/team<rockets> "name"@[] "Rockets"^^type:text .
+	       /organization/team/squad<red> "name"@[] "Red"^^type:text .
+	       /organizations<other> "name"@[] "Other"^^type:text .
+	       /u<joe> "name"@[] "Joe"^^type:text .
+	       /u<joe> "member_of"@[] /organization/team<rockets>
+	     };`)
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{`select ?s from ?test where {?s under /organization "name"@[] ?n};`, 3},
+		{`select ?s from ?test where {?s under /organization/team "name"@[] ?n};`, 2},
+		{`select ?s from ?test where {?s under /organization/team/squad "name"@[] ?n};`, 1},
+		{`select ?s from ?test where {?s under /org "name"@[] ?n};`, 0},
+		{`select ?s, ?p from ?test where {?s under /u ?p ?o};`, 2},
+		{`select ?s, ?t from ?test where {?u "member_of"@[] ?s . ?s under /organization type ?t "name"@[] ?n};`, 1},
+	}
+	for _, entry := range testTable {
+		if got, want := len(run(entry.q).Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+	}
+}
+
+func TestQueryPrefixes(t *testing.T) {
+	s := memory.NewStore()
+	if _, err := s.NewGraph("?test"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	run := func(q string) *table.Table {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+		}
+		return tbl
+	}
+	run(`prefix foaf: /foaf
+	     insert data into ?test {
+	       foaf:person<joe> "foaf:knows"@[] foaf:person<mary> .
+	       foaf:person<mary> "foaf:knows"@[] foaf:<group>
+	     };`)
+	tbl := run(`select ?o from ?test where {/foaf/person<joe> "/foaf/knows"@[] ?o};`)
+	if rws := tbl.Rows(); len(rws) != 1 || rws[0]["?o"].String() != "/foaf/person<mary>" {
+		t.Errorf("planner.Excecute failed to expand the inserted prefixes; got %v", rws)
+	}
+	tbl = run(`prefix f: /foaf select ?s, ?o from ?test where {?s under f:person "f:knows"@[] ?o};`)
+	if got, want := len(tbl.Rows()), 2; got != want {
+		t.Errorf("planner.Excecute failed to return the expected number of rows for prefixed query; got %d want %d", got, want)
+	}
+	tbl = run(`prefix f: /foaf select ?o from ?test where {f:person<mary> "f:knows"@[] ?o};`)
+	if rws := tbl.Rows(); len(rws) != 1 || rws[0]["?o"].String() != "/foaf<group>" {
+		t.Errorf("planner.Excecute failed to expand the queried prefixes; got %v", rws)
+	}
+}
+
+func TestQueryIntervalPredicates(t *testing.T) {
+	s := memory.NewStore()
+	if _, err := s.NewGraph("?test"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	run := func(q string) *table.Table {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+		}
+		return tbl
+	}
+	run(`insert data into ?test {
+	       /u<ana> "works_at"@[2015-01-01T00:00:00Z,2016-06-01T00:00:00Z] /c<acme> .
+	       /u<ana> "works_at"@[2016-06-01T00:00:00Z,2018-01-01T00:00:00Z] /c<globex> .
+	       /u<ana> "visited"@[2016-06-01T00:00:00Z] /c<acme>
+	     };`)
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{`select ?c from ?test where {/u<ana> "works_at"@[,] ?c};`, 2},
+		{`select ?c from ?test where {/u<ana> "works_at"@[,] ?c} at "x"@[2016-03-01T00:00:00Z];`, 1},
+		{`select ?c from ?test where {/u<ana> "works_at"@[,] ?c} at "x"@[2016-06-01T00:00:00Z];`, 1},
+		{`select ?c from ?test where {/u<ana> "works_at"@[,] ?c} at "x"@[2016];`, 2},
+		{`select ?c from ?test where {/u<ana> "works_at"@[,] ?c} at "x"@[2019];`, 0},
+		{`select ?c from ?test where {/u<ana> "works_at"@[2017-01-01T00:00:00Z,2017-01-01T00:00:00Z] ?c};`, 1},
+		{`select ?p, ?c from ?test where {/u<ana> ?p ?c} at "x"@[2016-06-01T00:00:00Z];`, 2},
+		{`select ?p, ?c from ?test where {/u<ana> ?p ?c} at "x"@[2016-05-31T00:00:00Z];`, 1},
+	}
+	for _, entry := range testTable {
+		if got, want := len(run(entry.q).Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+	}
+	tbl := run(`select ?c from ?test where {/u<ana> "works_at"@[,] ?c} at "x"@[2017-01-01T00:00:00Z];`)
+	if rws := tbl.Rows(); len(rws) != 1 || rws[0]["?c"].String() != "/c<globex>" {
+		t.Errorf("planner.Excecute returned the wrong employer at a point in time; got %v", rws)
+	}
+}
+
+func TestQueryAsOf(t *testing.T) {
+	s := memory.NewStore()
+	if _, err := s.NewGraph("?test"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	run := func(q string) *table.Table {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+		}
+		return tbl
+	}
+	run(`insert data into ?test {
+	       /u<joe> "lives_in"@[2010-01-01T00:00:00Z] /c<paris> .
+	       /u<joe> "lives_in"@[2014-01-01T00:00:00Z] /c<rome> .
+	       /u<joe> "lives_in"@[2016-01-01T00:00:00Z] /c<tokyo> .
+	       /u<mary> "lives_in"@[2012-01-01T00:00:00Z] /c<paris> .
+	       /u<joe> "works_at"@[2013-01-01T00:00:00Z,2014-06-01T00:00:00Z] /c<acme> .
+	       /u<joe> "knows"@[] /u<mary>
+	     };`)
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{`select ?c from ?test where {/u<joe> "lives_in"@[,] ?c};`, 3},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[,] ?c} as of "2015-06-01T00:00:00Z";`, 1},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[,] ?c} as of "2009";`, 0},
+		{`select ?p, ?o from ?test where {/u<joe> ?p ?o} as of "2014-03-01T00:00:00Z";`, 3},
+		{`select ?p, ?o from ?test where {/u<joe> ?p ?o} as of "2015";`, 2},
+		{`select ?s from ?test where {?s "lives_in"@[,] /c<paris>} as of "2013";`, 2},
+		{`select ?s from ?test where {?s "lives_in"@[,] /c<paris>} as of "2015";`, 1},
+		{`select ?s from ?test where {?s "knows"@[] /u<mary> . ?s "lives_in"@[2010-01-01T00:00:00Z] /c<paris>} as of "2011";`, 1},
+		{`select ?s from ?test where {?s "knows"@[] /u<mary> . ?s "lives_in"@[2010-01-01T00:00:00Z] /c<paris>} as of "2015";`, 0},
+		{`select ?m, ?c from ?test where {/u<joe> "knows"@[] ?m . ?m "lives_in"@[,] ?c} as of "2015";`, 1},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[,] ?c} after ""@[2015] as of "2015";`, 0},
+	}
+	for _, entry := range testTable {
+		if got, want := len(run(entry.q).Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+	}
+	tbl := run(`select ?c from ?test where {/u<joe> "lives_in"@[,] ?c} as of "2015";`)
+	if rws := tbl.Rows(); len(rws) != 1 || rws[0]["?c"].String() != "/c<rome>" {
+		t.Errorf("planner.Excecute returned the wrong city as of 2015; got %v", rws)
+	}
+}
+
+func TestQueryLatest(t *testing.T) {
+	s := memory.NewStore()
+	if _, err := s.NewGraph("?test"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	run := func(q string) *table.Table {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+		}
+		return tbl
+	}
+	run(`insert data into ?test {
+	       /u<joe> "lives_in"@[2010-01-01T00:00:00Z] /c<paris> .
+	       /u<joe> "lives_in"@[2014-01-01T00:00:00Z] /c<rome> .
+	       /u<joe> "lives_in"@[2016-01-01T00:00:00Z] /c<tokyo> .
+	       /u<mary> "lives_in"@[2012-01-01T00:00:00Z] /c<paris> .
+	       /u<mary> "visited"@[2016-01-01T00:00:00Z] /c<tokyo> .
+	       /u<mary> "visited"@[2016-01-01T00:00:00Z] /c<rome> .
+	       /u<joe> "knows"@[] /u<mary>
+	     };`)
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{`select ?s, ?c from ?test where {?s "lives_in"@[,] latest ?c};`, 2},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[,] latest ?c};`, 1},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[2010-01-01T00:00:00Z,2015-01-01T00:00:00Z] latest ?c};`, 1},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[2014] latest ?c};`, 1},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[2011] latest ?c};`, 0},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[,] latest ?c} before ""@[2011];`, 1},
+		{`select ?s from ?test where {?s "lives_in"@[,] latest /c<paris>};`, 1},
+		{`select ?c from ?test where {/u<mary> "visited"@[,] latest ?c};`, 2},
+		{`select ?p, ?o from ?test where {/u<joe> ?p latest ?o};`, 2},
+		{`select ?m, ?c from ?test where {/u<joe> "knows"@[] ?m . ?m "lives_in"@[,] latest ?c};`, 1},
+		{`select ?s from ?test where {?s "lives_in"@[,] as ?p /c<paris> . ?s ?p latest /c<paris>};`, 1},
+		{`select ?c from ?test where {/u<joe> "lives_in"@[,] latest ?c} as of "2015";`, 1},
+	}
+	for _, entry := range testTable {
+		if got, want := len(run(entry.q).Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+	}
+	for q, want := range map[string]string{
+		`select ?c from ?test where {/u<joe> "lives_in"@[,] latest ?c};`:                                         "/c<tokyo>",
+		`select ?c from ?test where {/u<joe> "lives_in"@[2010-01-01T00:00:00Z,2015-01-01T00:00:00Z] latest ?c};`: "/c<rome>",
+		`select ?c from ?test where {/u<joe> "lives_in"@[,] latest ?c} before ""@[2011];`:                        "/c<paris>",
+	} {
+		if rws := run(q).Rows(); len(rws) != 1 || rws[0]["?c"].String() != want {
+			t.Errorf("planner.Excecute returned the wrong latest city for query %q; got %v, want %s", q, rws, want)
+		}
+	}
+}
+
+func TestQueryGranularity(t *testing.T) {
+	s := memory.NewStore()
+	if _, err := s.NewGraph("?test"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	run := func(q string) *table.Table {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+		}
+		return tbl
+	}
+	run(`insert data into ?test {
+	       /u<joe> "bought"@[2015-06-01T09:00:00Z] /p<book> .
+	       /u<joe> "bought"@[2015-06-01T21:00:00Z] /p<pen> .
+	       /u<mary> "bought"@[2015-06-01T10:30:00Z] /p<book> .
+	       /u<mary> "bought"@[2015-06-02T10:00:00Z] /p<pen> .
+	       /u<mary> "visited"@[2015-06-03T08:00:00Z] /c<paris> .
+	       /u<mary> "visited"@[2015-06-03T18:00:00Z] /c<paris> .
+	       /u<joe> "knows"@[] /u<mary>
+	     };`)
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{`select ?s from ?test where {?s "bought"@[2015-06-01T12:00:00Z] ?o};`, 0},
+		{`select ?s from ?test where {?s "bought"@[2015-06-01T12:00:00Z] ?o} granularity "day";`, 3},
+		{`select ?s from ?test where {?s "bought"@[2015-06-01T12:00:00Z] ?o} granularity "hour";`, 0},
+		{`select ?s from ?test where {?s "bought"@[2015-06-01T09:30:00Z] ?o} granularity "hour";`, 1},
+		{`select ?s from ?test where {?s "bought"@[,] ?o} before ""@[2015-06-01T12:00:00Z];`, 2},
+		{`select ?s from ?test where {?s "bought"@[,] ?o} before ""@[2015-06-01T12:00:00Z] granularity "day";`, 3},
+		{`select ?a, ?b from ?test where {?a "knows"@[] ?b . ?a "bought"@[,] at ?t ?x . ?b "bought"@[,] at ?t ?y};`, 0},
+		{`select ?a, ?b from ?test where {?a "knows"@[] ?b . ?a "bought"@[,] at ?t ?x . ?b "bought"@[,] at ?t ?y} granularity "day";`, 2},
+		{`select ?a, ?b from ?test where {?a ?p /p<book> . ?b ?p /p<pen>};`, 0},
+		{`select ?a, ?b from ?test where {?a ?p /p<book> . ?b ?p /p<pen>} granularity "day";`, 2},
+		{`select ?a, ?b from ?test where {?a ?p /p<book> . ?b ?p /p<pen>} granularity "month";`, 4},
+		{`select distinct ?p from ?test where {/u<mary> ?p /c<paris>};`, 2},
+		{`select distinct ?p from ?test where {/u<mary> ?p /c<paris>} granularity "day";`, 1},
+		{`select ?s from ?test where {?s "knows"@[] /u<mary> . ?s "bought"@[2015-06-01T00:00:00Z] /p<pen>};`, 0},
+		{`select ?s from ?test where {?s "knows"@[] /u<mary> . ?s "bought"@[2015-06-01T00:00:00Z] /p<pen>} granularity "day";`, 1},
+		{`select ?s from ?test where {?s "knows"@[] /u<mary> . ?s "bought"@[2015-06-02T00:00:00Z] /p<pen>} granularity "day";`, 0},
+	}
+	for _, entry := range testTable {
+		if got, want := len(run(entry.q).Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+	}
+	tbl := run(`select ?p, ?t from ?test where {/u<mary> ?p at ?t /p<book>} granularity "day";`)
+	if rws := tbl.Rows(); len(rws) != 1 || rws[0]["?p"].String() != `"bought"@[2015-06-01]` || rws[0]["?t"].String() != "2015-06-01T00:00:00Z" {
+		t.Errorf("planner.Excecute should have reduced the time anchors to the day; got %v", rws)
+	}
+	// The granularity example of the BQL documentation.
+	if _, err := s.NewGraph("?follows"); err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?follows\" with error %v", err)
+	}
+	run(`insert data into ?follows {
+	       /user<Ann> "folows"@[2015-06-01T09:00:00Z] /user<Joe> .
+	       /user<Ann> "folows"@[2015-06-01T18:00:00Z] /user<Mary> .
+	       /user<Bob> "folows"@[2015-06-01T09:00:00Z] /user<Joe> .
+	       /user<Bob> "folows"@[2015-06-02T09:00:00Z] /user<Mary>
+	     };`)
+	tbl = run(`
+	  SELECT ?user, ?day
+	  FROM ?follows
+	  WHERE {
+	    ?user "folows"@[,] AT ?day /user<Joe> .
+	    ?user "folows"@[,] AT ?day /user<Mary>
+	  }
+	  GRANULARITY "day";`)
+	if rws := tbl.Rows(); len(rws) != 1 || rws[0]["?user"].String() != "/user<Ann>" || rws[0]["?day"].String() != "2015-06-01T00:00:00Z" {
+		t.Errorf("planner.Excecute should have matched the users following both on the same day; got %v", rws)
+	}
+}
+
+func TestQueryGraphBlocks(t *testing.T) {
+	s := memory.NewStore()
+	for gn, tpls := range map[string]string{
+		"?alpha": `/u<joe> "parent_of"@[] /u<mary>
+		           /u<mary> "parent_of"@[] /u<eve>`,
+		"?beta": `/u<joe> "parent_of"@[] /u<peter>
+		          /u<peter> "parent_of"@[] /u<john>`,
+	} {
+		g, err := s.NewGraph(gn)
+		if err != nil {
+			t.Fatalf("memory.NewGraph failed to create %q with error %v", gn, err)
+		}
+		if _, err := io.ReadIntoGraph(g, bytes.NewBufferString(tpls), literal.DefaultBuilder()); err != nil {
+			t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+		}
+	}
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{`select ?s, ?o from ?alpha, ?beta where {?s "parent_of"@[] ?o};`, 4},
+		{`select ?s, ?o from ?alpha, ?beta where {graph ?beta {?s "parent_of"@[] ?o}};`, 2},
+		{`select ?o from ?alpha, ?beta where {graph ?alpha {/u<joe> "parent_of"@[] ?o}};`, 1},
+		{`select ?s, ?o, ?g from ?alpha, ?beta where {graph ?g {?s "parent_of"@[] ?o}};`, 4},
+		{`select ?o, ?g from ?alpha, ?beta where {graph ?g {/u<joe> "parent_of"@[] ?o}};`, 2},
+		{`select ?c, ?g from ?alpha, ?beta where {graph ?g {/u<joe> "parent_of"@[] ?o . ?o "parent_of"@[] ?c}};`, 2},
+		{`select ?c from ?alpha, ?beta where {graph ?alpha {/u<joe> "parent_of"@[] ?o} . graph ?beta {?o "parent_of"@[] ?c}};`, 0},
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	for _, entry := range testTable {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(entry.q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", entry.q, err)
+		}
+		plnr, err := New(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		if got, want := len(tbl.Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+		for _, r := range tbl.Rows() {
+			if c, ok := r["?g"]; ok && c.S != "?alpha" && c.S != "?beta" {
+				t.Errorf("planner.Excecute bound ?g to an unknown graph for query %q; got %v", entry.q, c)
+			}
+		}
+	}
+}
+
+// prefetchGraph records the prefetch hints it receives.
+type prefetchGraph struct {
+	storage.Graph
+	hints []*storage.PrefetchHints
+}
+
+func (g *prefetchGraph) Prefetch(h *storage.PrefetchHints) error {
+	g.hints = append(g.hints, h)
+	return nil
+}
+
+func TestQueryPrefetchHints(t *testing.T) {
+	q := `select ?o from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] ?k};`
+	s := populateTestStore(t)
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+	}
+	plnr, err := newQueryPlan(s, st)
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+	}
+	pg := &prefetchGraph{Graph: plnr.grfs[0]}
+	plnr.grfs[0] = pg
+	if _, err := plnr.Excecute(context.Background()); err != nil {
+		t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+	}
+	if got, want := len(pg.hints), 1; got != want {
+		t.Fatalf("planner.Excecute called Prefetch the wrong number of times; got %d, want %d", got, want)
+	}
+	h := pg.hints[0]
+	if len(h.Subjects) != 1 || h.Subjects[0].String() != "/u<joe>" {
+		t.Errorf("planner.Excecute passed the wrong subject hints; got %v", h.Subjects)
+	}
+	if len(h.Predicates) != 1 || h.Predicates[0].String() != `"parent_of"@[]` {
+		t.Errorf("planner.Excecute passed the wrong predicate hints; got %v", h.Predicates)
+	}
+}
+
+func TestQueryHints(t *testing.T) {
+	testTable := []struct {
+		q        string
+		nrws     int
+		prefetch int
+		err      bool
+	}{
+		{
+			q:        `select ?o from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] ?k};`,
+			nrws:     2,
+			prefetch: 1,
+		},
+		{
+			q:        `select ?o from ?test where {?o "parent_of"@[] ?k. /u<joe> "parent_of"@[] ?o} with hint(ordered);`,
+			nrws:     2,
+			prefetch: 1,
+		},
+		{
+			q:    `select ?o from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] ?k} with hint(noprefetch, ordered);`,
+			nrws: 2,
+		},
+		{
+			q:        `select ?o from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] ?k} with hint(maxrows "2"^^type:int64);`,
+			nrws:     2,
+			prefetch: 1,
+		},
+		{
+			q:   `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} with hint(maxrows "2"^^type:int64);`,
+			err: true,
+		},
+	}
+	s := populateTestStore(t)
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	for _, entry := range testTable {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(entry.q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", entry.q, err)
+		}
+		plnr, err := newQueryPlan(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		pg := &prefetchGraph{Graph: plnr.grfs[0]}
+		plnr.grfs[0] = pg
+		tbl, err := plnr.Excecute(context.Background())
+		if entry.err {
+			if err == nil {
+				t.Errorf("planner.Excecute should have failed for query %q", entry.q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("planner.Excecute failed for query %q with error %v", entry.q, err)
+			continue
+		}
+		if got, want := len(tbl.Rows()), entry.nrws; got != want {
+			t.Errorf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d", entry.q, got, want)
+		}
+		if got, want := len(pg.hints), entry.prefetch; got != want {
+			t.Errorf("planner.Excecute called Prefetch the wrong number of times for query %q; got %d, want %d", entry.q, got, want)
+		}
+	}
+}
+
+func TestRowIndependent(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		cls  *semantic.GraphClause
+		want bool
+	}{
+		{&semantic.GraphClause{S: n, PBinding: "?p", OBinding: "?o"}, true},
+		{&semantic.GraphClause{SBinding: "?s", PBinding: "?p", OBinding: "?o"}, false},
+		{&semantic.GraphClause{S: n, PBinding: "?p", PLowerBoundAlias: "?t"}, false},
+		{&semantic.GraphClause{S: n, PBinding: "?p", OEmbedded: &semantic.GraphClause{SBinding: "?x"}}, false},
+	}
+	for _, entry := range testTable {
+		if got := rowIndependent(entry.cls); got != entry.want {
+			t.Errorf("rowIndependent(%v) returned the wrong value; got %v, want %v", entry.cls, got, entry.want)
+		}
+	}
+}
+
+// lookupGraph counts the lookups issued against the graph.
+type lookupGraph struct {
+	storage.Graph
+	byPredicate, bySubjectAndPredicate int
+	maxElements                        []int
+}
+
+func (g *lookupGraph) TriplesForPredicate(p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	g.byPredicate++
+	g.maxElements = append(g.maxElements, lo.MaxElements)
+	return g.Graph.TriplesForPredicate(p, lo)
+}
+
+func (g *lookupGraph) Objects(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Objects, error) {
+	g.bySubjectAndPredicate++
+	return g.Graph.Objects(s, p, lo)
+}
+
+func TestQueryBoundLookupsPerRow(t *testing.T) {
+	q := `select ?o, ?k from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] ?k};`
+	s := populateTestStore(t)
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+	}
+	plnr, err := newQueryPlan(s, st)
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+	}
+	lg := &lookupGraph{Graph: plnr.grfs[0]}
+	plnr.grfs[0] = lg
+	tbl, err := plnr.Excecute(context.Background())
+	if err != nil {
+		t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+	}
+	var got []string
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?o"].String()+" "+r["?k"].String())
+	}
+	sort.Strings(got)
+	if want := []string{"/u<peter> /u<eve>", "/u<peter> /u<john>"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planner.Excecute returned the wrong rows; got %v, want %v", got, want)
+	}
+	if lg.byPredicate != 0 {
+		t.Errorf("planner.Excecute fetched the whole relation %d times instead of looking up each row", lg.byPredicate)
+	}
+	// One lookup for the first clause, and one per each of the two children.
+	if got, want := lg.bySubjectAndPredicate, 3; got != want {
+		t.Errorf("planner.Excecute issued the wrong number of subject and predicate lookups; got %d, want %d", got, want)
+	}
+}
+
+func TestQueryCancelled(t *testing.T) {
+	q := `select ?s, ?k from ?test where {?s ?p ?o. ?k ?l ?m};`
+	s := populateTestStore(t)
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+	}
+	plnr, err := New(s, st)
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := plnr.Excecute(ctx); err != context.Canceled {
+		t.Errorf("planner.Excecute should have failed with %v for query %q; got %v", context.Canceled, q, err)
+	}
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := plnr.Excecute(ctx); err != context.DeadlineExceeded {
+		t.Errorf("planner.Excecute should have failed with %v for query %q; got %v", context.DeadlineExceeded, q, err)
+	}
+}
+
+func TestQueryLimitPushdown(t *testing.T) {
+	testTable := []struct {
+		q           string
+		limit       int
+		maxElements []int
+		nRows       int
+	}{
+		{
+			q:           `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} limit "2"^^type:int64;`,
+			limit:       2,
+			maxElements: []int{2},
+			nRows:       2,
+		},
+		{
+			q:           `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} order by ?o limit "2"^^type:int64;`,
+			limit:       0,
+			maxElements: []int{0},
+			nRows:       2,
+		},
+		{
+			q:           `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} group by ?s limit "1"^^type:int64 per group;`,
+			limit:       0,
+			maxElements: []int{0},
+			nRows:       2,
+		},
+		{
+			q:           `select ?s from ?test where {?s "parent_of"@[] ?s} limit "1"^^type:int64;`,
+			limit:       1,
+			maxElements: []int{0},
+			nRows:       0,
+		},
+		{
+			q:           `select ?s, ?k from ?test where {?s "parent_of"@[] ?o. ?o "parent_of"@[] ?k} limit "1"^^type:int64;`,
+			limit:       0,
+			maxElements: []int{0},
+			nRows:       1,
+		},
+	}
+	s := populateTestStore(t)
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	for _, entry := range testTable {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(entry.q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", entry.q, err)
+		}
+		plnr, err := newQueryPlan(s, st)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		if got, want := plnr.rowLimit(), entry.limit; got != want {
+			t.Errorf("queryPlan.rowLimit returned the wrong limit for query %q; got %d, want %d", entry.q, got, want)
+		}
+		lg := &lookupGraph{Graph: plnr.grfs[0]}
+		plnr.grfs[0] = lg
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		if got, want := lg.maxElements, entry.maxElements; !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Excecute passed the wrong maximum number of elements to the storage for query %q; got %v, want %v", entry.q, got, want)
+		}
+		if got, want := tbl.NumRows(), entry.nRows; got != want {
+			t.Errorf("planner.Excecute returned the wrong number of rows for query %q; got %d, want %d", entry.q, got, want)
+		}
+	}
+}
+
+func TestAnalyzeGraphs(t *testing.T) {
+	s := populateTestStore(t)
+	plnr, err := New(s, parseTestStatement(t, `analyze graph ?test;`))
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid analyze plan with error %v", err)
+	}
+	if _, err := plnr.Excecute(context.Background()); err != nil {
+		t.Fatalf("planner.Excecute failed to analyze graph ?test with error %v", err)
+	}
+	g, err := s.Graph("?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := g.(storage.StatsKeeper).Stats()
+	if err != nil {
+		t.Fatalf("StatsKeeper.Stats failed with error %v", err)
+	}
+	if got, want := st.Triples, int64(len(strings.Split(testTriples, "\n"))-2); got != want {
+		t.Errorf("planner.Excecute collected the wrong number of triples for graph ?test; got %d, want %d", got, want)
+	}
+	plnr, err = New(s, parseTestStatement(t, `analyze graph ?unknown;`))
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid analyze plan with error %v", err)
+	}
+	if _, err := plnr.Excecute(context.Background()); err == nil {
+		t.Errorf("planner.Excecute should have failed to analyze a non existing graph")
+	}
+}
+
+func TestQueryDedup(t *testing.T) {
+	testTable := []struct {
+		q     string
+		nRows int
+	}{
+		{
+			q:     `select ?s, ?o from ?test, ?copy where {?s "parent_of"@[] ?o};`,
+			nRows: 6,
+		},
+		{
+			q:     `select ?s, ?o from ?test, ?copy where {?s "parent_of"@[] ?o} with hint(dedup);`,
+			nRows: 5,
+		},
+		{
+			q:     `select distinct ?s, ?o from ?test, ?copy where {?s "parent_of"@[] ?o};`,
+			nRows: 5,
+		},
+		{
+			q:     `select distinct ?s, ?o from ?test, ?copy where {?s "parent_of"@[] ?o} limit "5"^^type:int64;`,
+			nRows: 5,
+		},
+		{
+			q:     `select ?s, ?o, ?g from ?test, ?copy where {graph ?g {?s "parent_of"@[] ?o}} with hint(dedup);`,
+			nRows: 6,
+		},
+	}
+	s := populateTestStore(t)
+	g, err := s.NewGraph("?copy")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?copy\" with error %v", err)
+	}
+	b := bytes.NewBufferString("/u<joe>\t\"parent_of\"@[]\t/u<mary>\n/u<mary>\t\"parent_of\"@[]\t/u<ann>\n")
+	if _, err := io.ReadIntoGraph(g, b, literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	for _, entry := range testTable {
+		plnr, err := New(s, parseTestStatement(t, entry.q))
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		if got, want := tbl.NumRows(), entry.nRows; got != want {
+			t.Errorf("planner.Excecute returned the wrong number of rows for query %q; got %d, want %d", entry.q, got, want)
+		}
+	}
+}
+
+func TestQueryAggregations(t *testing.T) {
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q:    `select ?s, count(?o) as ?n from ?test where {?s "parent_of"@[] ?o} group by ?s order by ?s;`,
+			want: []string{`/u<joe>	"2"^^type:int64`, `/u<peter>	"2"^^type:int64`},
+		},
+		{
+			q:    `select ?s as ?p, count(?o) as ?n from ?test where {?s "parent_of"@[] ?o} group by ?p order by ?p desc;`,
+			want: []string{`/u<peter>	"2"^^type:int64`, `/u<joe>	"2"^^type:int64`},
+		},
+		{
+			q:    `select count(?o) as ?n, count(distinct ?s) as ?d from ?test where {?s "parent_of"@[] ?o};`,
+			want: []string{`"4"^^type:int64	"2"^^type:int64`},
+		},
+		{
+			q:    `select count(?o) as ?n from ?test where {/u<nobody> "parent_of"@[] ?o};`,
+			want: []string{`"0"^^type:int64`},
+		},
+		{
+			q:    `select ?s, count(?o) as ?n from ?test where {?s "parent_of"@[] ?o. ?o "parent_of"@[] ?c} group by ?s having ?n > "1"^^type:int64;`,
+			want: []string{`/u<joe>	"2"^^type:int64`},
+		},
+		{
+			q: `select ?s, count(?o) as ?n from ?test where {?s "parent_of"@[] ?o. ?o "parent_of"@[] ?c} group by ?s having ?n > "2"^^type:int64;`,
+		},
+	}
+	s := populateTestStore(t)
+	for _, entry := range testTable {
+		pln, err := New(s, parseTestStatement(t, entry.q))
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid plan for %q with error %v", entry.q, err)
+		}
+		tbl, err := pln.Excecute(context.Background())
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for %q with error %v", entry.q, err)
+		}
+		var got []string
+		for _, r := range tbl.Rows() {
+			var buf bytes.Buffer
+			if err := r.ToTextLine(&buf, tbl.Bindings(), "\t"); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, buf.String())
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Excecute(%q) returned the wrong rows; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+)
+
+// Prepared contains a statement ready to be planned. Preparing a statement
+// transforms it using the rewriters registered with semantic.RegisterRewriter
+// and validates it only once, so services running the same statement
+// repeatedly can create new plans for it without doing so again. Prepared
+// statements are immutable and safe for concurrent use, while each plan or
+// stream created from them keeps its own execution state.
+type Prepared struct {
+	parsed *semantic.Statement
+	stm    *semantic.Statement
+}
+
+// Prepare rewrites and validates the provided statement.
+func Prepare(stm *semantic.Statement) (*Prepared, error) {
+	rs, err := semantic.Rewrite(stm)
+	if err != nil {
+		return nil, err
+	}
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+	return &Prepared{parsed: stm, stm: rs}, nil
+}
+
+// Statement returns the statement as provided to Prepare, before being
+// rewritten. Callers checking whether the statement can be run should use it.
+func (p *Prepared) Statement() *semantic.Statement {
+	return p.parsed
+}
+
+// Plan returns a new executable plan for the statement against the provided
+// store. Queries fail with a LimitError if they exceed any of the provided
+// limits.
+func (p *Prepared) Plan(store storage.Store, lmts Limits) (Excecutor, error) {
+	return newPlan(store, p.stm, lmts)
+}
+
+// Execute runs the statement against the store and returns a stream of the
+// resulting rows. Queries fail with a LimitError if they exceed any of the
+// provided limits.
+func (p *Prepared) Execute(ctx context.Context, store storage.Store, lmts Limits) (ResultStream, error) {
+	e, err := p.Plan(store, lmts)
+	if err != nil {
+		return nil, err
+	}
+	return Stream(ctx, e)
+}
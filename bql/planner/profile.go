@@ -0,0 +1,136 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/google/badwolf/bql/table"
+)
+
+// Profiler is implemented by the plans and result streams able to report the
+// metrics collected while executing a query.
+type Profiler interface {
+	// Profile returns the metrics collected so far, or nil if the query has
+	// not been executed yet.
+	Profile() *Profile
+}
+
+// Profile contains the metrics collected while executing a query.
+type Profile struct {
+	// Clauses contains the metrics of each clause of the graph pattern in the
+	// order they were processed.
+	Clauses []*ClauseProfile
+
+	// Stages contains the metrics of each stage of the execution in the order
+	// they were run, including the operators filtering, sorting, and trimming
+	// the results.
+	Stages []*StageProfile
+}
+
+// ClauseProfile contains the metrics collected while processing a clause of
+// the graph pattern of a query.
+type ClauseProfile struct {
+	// Clause contains the description of the clause.
+	Clause string
+
+	// RowsFetched contains the number of rows retrieved from the graphs.
+	RowsFetched int64
+
+	// InputRows contains the number of rows before joining the clause data.
+	InputRows int64
+
+	// OutputRows contains the number of rows after joining the clause data.
+	OutputRows int64
+
+	// SpilledRows contains the number of rows of the clause data spilled to
+	// disk to compute cartesian products.
+	SpilledRows int64
+
+	// Duration contains the time spent processing the clause.
+	Duration time.Duration
+}
+
+// StageProfile contains the metrics collected while running a stage of the
+// execution of a query.
+type StageProfile struct {
+	// Name contains the name of the stage.
+	Name string
+
+	// Rows contains the number of rows produced by the stage.
+	Rows int64
+
+	// Duration contains the time spent on the stage, excluding the time spent
+	// on the stages feeding rows to it.
+	Duration time.Duration
+}
+
+// stage adds a new stage to the profile and returns it.
+func (p *Profile) stage(name string) *StageProfile {
+	s := &StageProfile{Name: name}
+	p.Stages = append(p.Stages, s)
+	return s
+}
+
+// String returns the metrics of the profile, one clause or stage per line.
+func (p *Profile) String() string {
+	var b bytes.Buffer
+	for i, c := range p.Clauses {
+		fmt.Fprintf(&b, "clause %d: %s\n", i+1, c.Clause)
+		fmt.Fprintf(&b, "  rows fetched: %d, rows: %d -> %d, time: %v\n", c.RowsFetched, c.InputRows, c.OutputRows, c.Duration)
+		if c.SpilledRows > 0 {
+			fmt.Fprintf(&b, "  rows spilled: %d\n", c.SpilledRows)
+		}
+	}
+	for _, s := range p.Stages {
+		fmt.Fprintf(&b, "stage %s: rows: %d, time: %v\n", s.Name, s.Rows, s.Duration)
+	}
+	return b.String()
+}
+
+// profiledOperator records the rows returned by the wrapped operator and the
+// time spent on it. The time spent on the operator feeding rows to it, if
+// profiled, is not accounted for.
+type profiledOperator struct {
+	in    operator
+	prev  *profiledOperator
+	st    *StageProfile
+	total time.Duration
+}
+
+func (o *profiledOperator) next() (table.Row, error) {
+	var prev time.Duration
+	if o.prev != nil {
+		prev = o.prev.total
+	}
+	start := time.Now()
+	r, err := o.in.next()
+	d := time.Since(start)
+	o.total += d
+	if o.prev != nil {
+		d -= o.prev.total - prev
+	}
+	o.st.Duration += d
+	if r != nil {
+		o.st.Rows++
+	}
+	return r, err
+}
+
+func (o *profiledOperator) close() error {
+	return o.in.close()
+}
@@ -0,0 +1,150 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/bql/table"
+)
+
+func TestProfile(t *testing.T) {
+	s := populateTestStore(t)
+	q := `select ?o, ?k from ?test where {/u<joe> "parent_of"@[] ?o. ?o "parent_of"@[] ?k} order by ?k limit "1"^^type:int64;`
+	pln, err := New(s, parseTestStatement(t, q))
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid query plan for %q with error %v", q, err)
+	}
+	pr, ok := pln.(Profiler)
+	if !ok {
+		t.Fatalf("planner.New should return a plan implementing Profiler for query %q", q)
+	}
+	if pr.Profile() != nil {
+		t.Errorf("Profile should return nil before the query is executed")
+	}
+	if _, err := pln.Excecute(context.Background()); err != nil {
+		t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+	}
+	p := pr.Profile()
+	if p == nil {
+		t.Fatalf("Profile should not return nil after the query is executed")
+	}
+	wantClauses := []ClauseProfile{
+		{Clause: `/u<joe> "parent_of"@[] ?o`, RowsFetched: 2, InputRows: 0, OutputRows: 2},
+		{Clause: `?o "parent_of"@[] ?k`, RowsFetched: 2, InputRows: 2, OutputRows: 2},
+	}
+	if got, want := len(p.Clauses), len(wantClauses); got != want {
+		t.Fatalf("Profile returned the wrong number of clauses; got %d, want %d", got, want)
+	}
+	for i, want := range wantClauses {
+		got := *p.Clauses[i]
+		got.Duration = 0
+		if got != want {
+			t.Errorf("Profile returned the wrong metrics for clause %d; got %+v, want %+v", i+1, got, want)
+		}
+	}
+	wantStages := []StageProfile{
+		{Name: "prefetch"},
+		{Name: "graph pattern", Rows: 2},
+		// Rows are pulled through the pipeline, so the sort only returns the
+		// rows requested by the limit.
+		{Name: "sort", Rows: 1},
+		{Name: "limit", Rows: 1},
+	}
+	if got, want := len(p.Stages), len(wantStages); got != want {
+		t.Fatalf("Profile returned the wrong number of stages; got %d, want %d", got, want)
+	}
+	for i, want := range wantStages {
+		got := *p.Stages[i]
+		if got.Duration < 0 {
+			t.Errorf("Profile returned a negative duration for stage %q; got %v", got.Name, got.Duration)
+		}
+		got.Duration = 0
+		if got != want {
+			t.Errorf("Profile returned the wrong metrics for stage %d; got %+v, want %+v", i, got, want)
+		}
+	}
+	if got, want := p.String(), "stage limit: rows: 1"; !strings.Contains(got, want) {
+		t.Errorf("Profile.String should contain %q; got\n%s", want, got)
+	}
+}
+
+func TestResultStreamProfile(t *testing.T) {
+	s := populateTestStore(t)
+	rs, err := Execute(context.Background(), s, parseTestStatement(t, `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`))
+	if err != nil {
+		t.Fatalf("planner.Execute failed with error %v", err)
+	}
+	defer rs.Close()
+	for rs.Next() {
+	}
+	pr, ok := rs.(Profiler)
+	if !ok {
+		t.Fatalf("planner.Execute should return a stream implementing Profiler")
+	}
+	p := pr.Profile()
+	if p == nil || len(p.Clauses) != 1 {
+		t.Fatalf("ResultStream.Profile returned the wrong profile; got %v", p)
+	}
+	if got, want := p.Clauses[0].RowsFetched, int64(4); got != want {
+		t.Errorf("ResultStream.Profile returned the wrong number of fetched rows; got %d, want %d", got, want)
+	}
+}
+
+// sleepOperator returns the rows of the wrapped operator after waiting.
+type sleepOperator struct {
+	in operator
+	d  time.Duration
+}
+
+func (o *sleepOperator) next() (table.Row, error) {
+	time.Sleep(o.d)
+	return o.in.next()
+}
+
+func (o *sleepOperator) close() error {
+	return o.in.close()
+}
+
+func TestProfiledOperatorExcludesInputTime(t *testing.T) {
+	rws := []table.Row{{}, {}}
+	d := 10 * time.Millisecond
+	in := &profiledOperator{
+		in: &sleepOperator{in: &scanOperator{rows: rws}, d: d},
+		st: &StageProfile{Name: "in"},
+	}
+	out := &profiledOperator{in: in, prev: in, st: &StageProfile{Name: "out"}}
+	for {
+		r, err := out.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == nil {
+			break
+		}
+	}
+	if got, want := in.st.Duration, 3*d; got < want {
+		t.Errorf("profiledOperator recorded too little time for the input operator; got %v, want at least %v", got, want)
+	}
+	if got, want := out.st.Duration, d; got >= want {
+		t.Errorf("profiledOperator should not account for the time of its input; got %v, want less than %v", got, want)
+	}
+	if got, want := out.st.Rows, int64(2); got != want {
+		t.Errorf("profiledOperator counted the wrong number of rows; got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,505 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+)
+
+// Rule derives the triples of its head for each row matching its body, as
+// datalog rules do. For instance, the rule
+//
+//	{?a "skip_level"@[] ?c} :- {?a "manages"@[] ?b . ?b "manages"@[] ?c}
+//
+// derives that the managers of managers are skip level managers.
+type Rule struct {
+	// Name identifies the rule among the ones registered on a store.
+	Name string
+
+	// Graph contains the graph the head triples are derived into.
+	Graph string
+
+	// Head contains the clauses instantiated with the values of each row
+	// matching the body. All their bindings must be selected by the body, and
+	// they can neither be scoped to a graph nor use aliases or time bounds.
+	Head []*semantic.GraphClause
+
+	// Body contains the query whose result rows instantiate the head.
+	Body *semantic.Statement
+
+	// Materialized indicates that the derived triples are stored on the graph
+	// and maintained as the graphs read by the body change. Otherwise, they
+	// are derived each time a query reads the graph.
+	Materialized bool
+}
+
+// registeredRule contains a rule registered on a store, and the view keeping
+// its triples if it is materialized.
+type registeredRule struct {
+	*Rule
+	v *view
+}
+
+// RuleStore exposes a store along with the rules registered on it. Queries
+// planned against it apply the rules deriving into the graphs they read, and
+// deleting one of its graphs unregisters the rules deriving into it. The rules
+// are released along with the store, once no longer referenced; materialized
+// rules over graphs providing a change feed keep being maintained until they
+// are unregistered or the store is closed. The wrapped store is available on
+// the Store field, since its optional interfaces are not exposed.
+// RuleStore is safe for concurrent use.
+type RuleStore struct {
+	storage.Store
+
+	mu sync.Mutex
+	// rs contains the registered rules, in registration order.
+	rs []*registeredRule
+}
+
+// NewRuleStore returns a store exposing the provided one with no rules
+// registered.
+func NewRuleStore(s storage.Store) *RuleStore {
+	return &RuleStore{Store: s}
+}
+
+// validate checks that the rule can be registered.
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("missing rule name")
+	}
+	if r.Graph == "" {
+		return fmt.Errorf("rule %s has no graph to derive triples into", r.Name)
+	}
+	if r.Body == nil || r.Body.Type() != semantic.Query {
+		return fmt.Errorf("the body of rule %s is not a query", r.Name)
+	}
+	if len(r.Head) == 0 {
+		return fmt.Errorf("rule %s has an empty head", r.Name)
+	}
+	if err := r.Body.Validate(); err != nil {
+		return fmt.Errorf("invalid body for rule %s; %v", r.Name, err)
+	}
+	selected := make(map[string]bool)
+	for _, b := range r.Body.OutputBindings() {
+		selected[b] = true
+	}
+	for _, cls := range r.Head {
+		if !isTemplate(cls) {
+			return fmt.Errorf("head clause %s of rule %s is not a triple template", clauseString(cls), r.Name)
+		}
+		for b := range cls.BindingsMap() {
+			if !selected[b] {
+				return fmt.Errorf("binding %s on the head of rule %s is not selected by its body", b, r.Name)
+			}
+		}
+	}
+	if r.Materialized {
+		for _, g := range r.Body.Graphs() {
+			if g == r.Graph {
+				return fmt.Errorf("materialized rule %s cannot read the graph it derives into", r.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// isTemplate returns true if the clause fully specifies a triple once its
+// bindings are replaced by values.
+func isTemplate(cls *semantic.GraphClause) bool {
+	c := semantic.GraphClause{
+		S:              cls.S,
+		SBinding:       cls.SBinding,
+		P:              cls.P,
+		PID:            cls.PID,
+		PBinding:       cls.PBinding,
+		PAnchorBinding: cls.PAnchorBinding,
+		PTemporal:      cls.PTemporal,
+		O:              cls.O,
+		OBinding:       cls.OBinding,
+		OID:            cls.OID,
+		OAnchorBinding: cls.OAnchorBinding,
+		OTemporal:      cls.OTemporal,
+		OEmbedded:      cls.OEmbedded,
+	}
+	if c != *cls || cls.S == nil && cls.SBinding == "" || cls.O == nil && cls.OBinding == "" && cls.OEmbedded == nil && cls.OID == "" {
+		return false
+	}
+	if cls.P == nil && cls.PBinding == "" && (cls.PID == "" || cls.PAnchorBinding == "") {
+		return false
+	}
+	return cls.OID == "" || cls.OAnchorBinding != ""
+}
+
+// RegisterRule registers a rule on the store. Triples of materialized rules
+// are derived right away into the rule graph, and kept up to date
+// incrementally if all the graphs read by the body provide a change feed;
+// otherwise, the body is executed again each time the graph is queried.
+// Materialized rules cannot read the graph they derive into. Rules that are
+// not materialized are evaluated each time a query reads their graph, until
+// no new triples are derived, hence they may be recursive. The graph of the
+// rule must exist.
+func (s *RuleStore) RegisterRule(ctx context.Context, r *Rule) error {
+	if err := r.validate(); err != nil {
+		return fmt.Errorf("planner.RuleStore.RegisterRule: %v", err)
+	}
+	g, err := s.Store.Graph(r.Graph)
+	if err != nil {
+		return fmt.Errorf("planner.RuleStore.RegisterRule: %v", err)
+	}
+	if s.registered(r.Name) {
+		return fmt.Errorf("planner.RuleStore.RegisterRule: rule %s is already registered", r.Name)
+	}
+	// Rules are materialized without holding the lock, since the queries
+	// executed to do so apply the registered rules.
+	rr := &registeredRule{Rule: r}
+	if r.Materialized {
+		if rr.v, err = materialize(ctx, s, r, g); err != nil {
+			return fmt.Errorf("planner.RuleStore.RegisterRule: failed to materialize rule %s with error %v", r.Name, err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, orr := range s.rs {
+		if orr.Name == r.Name {
+			if rr.v != nil {
+				rr.v.clear()
+			}
+			return fmt.Errorf("planner.RuleStore.RegisterRule: rule %s is already registered", r.Name)
+		}
+	}
+	s.rs = append(s.rs, rr)
+	return nil
+}
+
+// registered returns true if a rule with the provided name is registered on
+// the store.
+func (s *RuleStore) registered(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rr := range s.rs {
+		if rr.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// derives returns true if any of the rules registered on the store derives
+// triples into the graph.
+func (s *RuleStore) derives(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rr := range s.rs {
+		if rr.Graph == id {
+			return true
+		}
+	}
+	return false
+}
+
+// rules returns the rules registered on the store.
+func (s *RuleStore) rules() []*registeredRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*registeredRule(nil), s.rs...)
+}
+
+// materialize returns the view deriving the triples of the rule into the
+// provided graph.
+func materialize(ctx context.Context, store storage.Store, r *Rule, g storage.Graph) (*view, error) {
+	watched := true
+	for _, id := range r.Body.Graphs() {
+		bg, err := store.Graph(id)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := bg.(storage.Watcher); !ok {
+			watched = false
+		}
+	}
+	v := &view{
+		store: store,
+		stm:   r.Body,
+		head:  r.Head,
+		g:     g,
+		refs:  make(map[string]int),
+		added: make(map[string]*triple.Triple),
+	}
+	var err error
+	if watched {
+		v.sub, err = Subscribe(context.Background(), store, r.Body, v.apply)
+	} else {
+		err = v.refresh(ctx)
+	}
+	if err == nil {
+		err = v.failure()
+	}
+	if err != nil {
+		if cerr := v.clear(); cerr != nil {
+			return nil, fmt.Errorf("%v; %v", err, cerr)
+		}
+		return nil, err
+	}
+	return v, nil
+}
+
+// UnregisterRule removes the rule from the store. The triples materialized by
+// the rule are removed from its graph, except the ones it already held.
+func (s *RuleStore) UnregisterRule(name string) error {
+	rr := s.removeRules(func(rr *registeredRule) bool { return rr.Name == name })
+	if len(rr) == 0 {
+		return fmt.Errorf("planner.RuleStore.UnregisterRule: rule %s is not registered", name)
+	}
+	if rr[0].v != nil {
+		if err := rr[0].v.clear(); err != nil {
+			return fmt.Errorf("planner.RuleStore.UnregisterRule: failed to remove the triples of rule %s with error %v", name, err)
+		}
+	}
+	return nil
+}
+
+// removeRules removes the rules matching the filter from the store, and
+// returns them. Removed rules are not maintained anymore.
+func (s *RuleStore) removeRules(match func(rr *registeredRule) bool) []*registeredRule {
+	s.mu.Lock()
+	var rs, removed []*registeredRule
+	for _, rr := range s.rs {
+		if match(rr) {
+			removed = append(removed, rr)
+		} else {
+			rs = append(rs, rr)
+		}
+	}
+	s.rs = rs
+	s.mu.Unlock()
+	// Closing the subscriptions waits for the changes being processed, which
+	// may need to apply the registered rules.
+	for _, rr := range removed {
+		if rr.v != nil {
+			rr.v.close()
+		}
+	}
+	return removed
+}
+
+// Rules returns the rules registered on the store, in registration order.
+func (s *RuleStore) Rules() []*Rule {
+	var rs []*Rule
+	for _, rr := range s.rules() {
+		rs = append(rs, rr.Rule)
+	}
+	return rs
+}
+
+// DeleteGraph unregisters the rules deriving into the graph, and deletes it.
+func (s *RuleStore) DeleteGraph(id string) error {
+	s.removeRules(func(rr *registeredRule) bool { return rr.Graph == id })
+	return s.Store.DeleteGraph(id)
+}
+
+// Close unregisters all the rules, and stops maintaining the materialized
+// ones. The triples they materialized are kept on their graphs.
+func (s *RuleStore) Close() error {
+	s.removeRules(func(*registeredRule) bool { return true })
+	return nil
+}
+
+// applyRules brings up to date the materialized rules deriving into the
+// queried graphs, and derives the triples of the rest of the rules reading
+// them. Only plans against rule stores apply rules, hence the ones deriving
+// their triples do not apply them again.
+func (p *queryPlan) applyRules(ctx context.Context) error {
+	s, ok := p.store.(*RuleStore)
+	if !ok {
+		return nil
+	}
+	rs := s.rules()
+	queried := make(map[string]bool)
+	for _, g := range p.grfsNames {
+		queried[g] = true
+	}
+	for _, rr := range rs {
+		if rr.v == nil || !queried[rr.Graph] {
+			continue
+		}
+		if err := rr.v.update(ctx); err != nil {
+			return fmt.Errorf("rule %s is not up to date: %v", rr.Name, err)
+		}
+	}
+	// Rules are derived along with the ones deriving the graphs they read.
+	var drs []*Rule
+	for changed := true; changed; {
+		changed = false
+		for _, rr := range rs {
+			if rr.v != nil || !queried[rr.Graph] || containsRule(drs, rr.Rule) {
+				continue
+			}
+			drs, changed = append(drs, rr.Rule), true
+			for _, g := range rr.Body.Graphs() {
+				queried[g] = true
+			}
+		}
+	}
+	if len(drs) == 0 {
+		return nil
+	}
+	ds, err := derive(ctx, s.Store, drs)
+	if err != nil {
+		return err
+	}
+	p.store = ds
+	for i, g := range p.grfsNames {
+		if p.grfs[i], err = statementGraph(ds, p.stm, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containsRule returns true if the rule is among the provided ones.
+func containsRule(rs []*Rule, r *Rule) bool {
+	for _, cr := range rs {
+		if cr == r {
+			return true
+		}
+	}
+	return false
+}
+
+// derive returns the store exposing the graphs of the provided rules along
+// with the triples they derive, which are kept apart from the ones of the
+// graphs. Rules are evaluated semi-naively: once evaluated against the whole
+// graphs, they are only evaluated again for the rows derived from the triples
+// derived on the previous round, until no new triples are derived. Rules whose
+// rows cannot be derived that way are evaluated against the whole graphs on
+// each round.
+func derive(ctx context.Context, store storage.Store, rs []*Rule) (*derivedStore, error) {
+	ds := &derivedStore{
+		Store: store,
+		gs:    make(map[string]*derivedGraph),
+	}
+	dst := memory.NewStore()
+	for _, r := range rs {
+		if _, ok := ds.gs[r.Graph]; ok {
+			continue
+		}
+		g, err := store.Graph(r.Graph)
+		if err != nil {
+			return nil, err
+		}
+		dg, err := dst.NewGraph(r.Graph)
+		if err != nil {
+			return nil, err
+		}
+		ds.gs[r.Graph] = &derivedGraph{Graph: g, d: dg}
+	}
+	var delta map[string][]*triple.Triple
+	for first := true; first || len(delta) > 0; first = false {
+		next := make(map[string][]*triple.Triple)
+		for _, r := range rs {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			var (
+				rws []table.Row
+				err error
+			)
+			switch {
+			case first || !seminaive(r.Body):
+				rws, err = ruleRows(ctx, ds, r.Body)
+			case reads(r.Body, delta):
+				rws, err = derivations(ctx, ds, r.Body, delta)
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate rule %s with error %v", r.Name, err)
+			}
+			nts, err := ds.gs[r.Graph].add(r.Head, rws)
+			if err != nil {
+				return nil, err
+			}
+			if len(nts) > 0 {
+				next[r.Graph] = append(next[r.Graph], nts...)
+			}
+		}
+		delta = next
+	}
+	return ds, nil
+}
+
+// ruleRows returns the result rows of the body of a rule.
+func ruleRows(ctx context.Context, store storage.Store, stm *semantic.Statement) ([]table.Row, error) {
+	pln, err := preparedQueryPlan(store, stm)
+	if err != nil {
+		return nil, err
+	}
+	tbl, err := pln.Excecute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rws := tbl.Rows()
+	if err := tbl.Close(); err != nil {
+		return nil, err
+	}
+	return rws, nil
+}
+
+// seminaive returns true if the result rows of the query are the joins of the
+// rows matched by its graph clauses, hence the ones derived from some triples
+// can be computed by seeding each clause with them; see derivations. The query
+// may have no aggregations, having, limit, or minus clauses, nor be evaluated
+// as of a time or project aliases, and its clauses may neither match chains,
+// latest, or embedded triples, nor bind graphs.
+func seminaive(stm *semantic.Statement) bool {
+	if len(stm.MinusPatterns()) > 0 || len(aggregations(stm)) > 0 || stm.IsLimitSet() || stm.AsOf() != nil {
+		return false
+	}
+	if e := stm.HavingEvaluator(); e != nil {
+		if v, ok := semantic.ConstantValue(e); !ok || !v {
+			return false
+		}
+	}
+	for _, prj := range stm.Projections() {
+		if prj.Alias != "" && prj.Alias != prj.Binding {
+			return false
+		}
+	}
+	for _, cls := range stm.OrderedGraphPatternClauses() {
+		if cls.PTransitive || cls.PLatest || cls.OEmbedded != nil || cls.GBinding != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// reads returns true if the query reads any of the graphs holding the
+// provided triples.
+func reads(stm *semantic.Statement, ds map[string][]*triple.Triple) bool {
+	for _, g := range stm.Graphs() {
+		if len(ds[g]) > 0 {
+			return true
+		}
+	}
+	return false
+}
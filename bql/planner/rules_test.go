@@ -0,0 +1,290 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// testRule returns a rule deriving the head clauses into the graph for each
+// row of the body query.
+func testRule(t *testing.T, name, graph, head, body string) *Rule {
+	return &Rule{
+		Name:  name,
+		Graph: graph,
+		Head:  parseTestStatement(t, `select ?a from ?test where {`+head+`};`).OrderedGraphPatternClauses(),
+		Body:  parseTestStatement(t, body),
+	}
+}
+
+func mustParseTriple(t *testing.T, s string) *triple.Triple {
+	tpl, err := triple.ParseTriple(s, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpl
+}
+
+func TestBackwardRules(t *testing.T) {
+	s := NewRuleStore(populateTestStore(t))
+	ctx := context.Background()
+	rs := []*Rule{
+		testRule(t, "grandparent", "?test", `?a "grandparent_of"@[] ?c`, `select ?a, ?c from ?test where {?a "parent_of"@[] ?b . ?b "parent_of"@[] ?c};`),
+		testRule(t, "parent", "?test", `?a "ancestor_of"@[] ?b`, `select ?a, ?b from ?test where {?a "parent_of"@[] ?b};`),
+		testRule(t, "ancestor", "?test", `?a "ancestor_of"@[] ?c`, `select ?a, ?c from ?test where {?a "parent_of"@[] ?b . ?b "ancestor_of"@[] ?c};`),
+	}
+	for _, r := range rs {
+		if err := s.RegisterRule(ctx, r); err != nil {
+			t.Fatalf("planner.RegisterRule failed to register rule %s with error %v", r.Name, err)
+		}
+	}
+	testTable := []struct {
+		q    string
+		nrws int
+	}{
+		{
+			q:    `select ?a, ?c from ?test where {?a "grandparent_of"@[] ?c};`,
+			nrws: 2,
+		},
+		{
+			q:    `select ?c from ?test where {/u<joe> "ancestor_of"@[] ?c};`,
+			nrws: 4,
+		},
+		{
+			q:    `select ?a, ?c from ?test where {?a "ancestor_of"@[] ?c};`,
+			nrws: 6,
+		},
+		{
+			q:    `select ?a from ?test where {?a "ancestor_of"@[] /u<eve> . ?a "grandparent_of"@[] /u<eve>};`,
+			nrws: 1,
+		},
+	}
+	for _, entry := range testTable {
+		if got, want := executeTestStatement(t, s, entry.q), entry.nrws; got != want {
+			t.Errorf("planner.Excecute(%q) returned the wrong number of rows; got %d, want %d", entry.q, got, want)
+		}
+	}
+	// Derived triples are not stored.
+	if got, want := executeTestStatement(t, s.Store, `select ?a, ?c from ?test where {?a "ancestor_of"@[] ?c};`), 0; got != want {
+		t.Errorf("rules should not have stored their triples; got %d, want %d", got, want)
+	}
+	if err := s.UnregisterRule("grandparent"); err != nil {
+		t.Fatalf("planner.UnregisterRule failed with error %v", err)
+	}
+	if got, want := executeTestStatement(t, s, `select ?a, ?c from ?test where {?a "grandparent_of"@[] ?c};`), 0; got != want {
+		t.Errorf("unregistered rules should not derive triples; got %d, want %d", got, want)
+	}
+	if got, want := len(s.Rules()), 2; got != want {
+		t.Errorf("planner.Rules returned the wrong number of rules; got %d, want %d", got, want)
+	}
+}
+
+func TestRecursiveRules(t *testing.T) {
+	reach := `select ?a, ?b from ?chain where {?a "next"@[] ?b};`
+	testTable := []struct {
+		body      string
+		seminaive bool
+	}{
+		{
+			body:      `select ?a, ?c from ?chain where {?a "next"@[] ?b . ?b "reaches"@[] ?c};`,
+			seminaive: true,
+		},
+		{
+			body:      `select ?a, ?c from ?chain where {?a "reaches"@[] ?b . ?b "reaches"@[] ?c};`,
+			seminaive: true,
+		},
+		{
+			body:      `select ?a, ?c from ?chain where {?a "next"@[] ?b . ?b "reaches"@[] ?c} limit "1000"^^type:int64;`,
+			seminaive: false,
+		},
+	}
+	ctx := context.Background()
+	for _, entry := range testTable {
+		ms := memory.NewStore()
+		g, err := ms.NewGraph("?chain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ts []*triple.Triple
+		for i := 0; i < 9; i++ {
+			ts = append(ts, mustParseTriple(t, fmt.Sprintf("/u<n%d>\t\"next\"@[]\t/u<n%d>", i, i+1)))
+		}
+		if err := g.AddTriples(ts); err != nil {
+			t.Fatal(err)
+		}
+		s := NewRuleStore(ms)
+		rs := []*Rule{
+			testRule(t, "reach", "?chain", `?a "reaches"@[] ?b`, reach),
+			testRule(t, "transitive", "?chain", `?a "reaches"@[] ?c`, entry.body),
+		}
+		if got, want := seminaive(rs[1].Body), entry.seminaive; got != want {
+			t.Errorf("seminaive(%q) returned the wrong value; got %v, want %v", entry.body, got, want)
+		}
+		for _, r := range rs {
+			if err := s.RegisterRule(ctx, r); err != nil {
+				t.Fatalf("planner.RegisterRule failed to register rule %s with error %v", r.Name, err)
+			}
+		}
+		if got, want := executeTestStatement(t, s, `select ?a, ?b from ?chain where {?a "reaches"@[] ?b};`), 45; got != want {
+			t.Errorf("rule %q derived the wrong number of triples; got %d, want %d", entry.body, got, want)
+		}
+		if got, want := executeTestStatement(t, s, `select ?b from ?chain where {/u<n6> "reaches"@[] ?b};`), 3; got != want {
+			t.Errorf("rule %q derived the wrong number of triples for /u<n6>; got %d, want %d", entry.body, got, want)
+		}
+	}
+}
+
+func TestRuleStoreRelease(t *testing.T) {
+	ctx := context.Background()
+	s := NewRuleStore(populateTestStore(t))
+	if _, err := s.NewGraph("?derived"); err != nil {
+		t.Fatal(err)
+	}
+	body := `select ?a, ?c from ?test where {?a "parent_of"@[] ?b . ?b "parent_of"@[] ?c};`
+	if err := s.RegisterRule(ctx, testRule(t, "grandparent", "?derived", `?a "grandparent_of"@[] ?c`, body)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteGraph("?derived"); err != nil {
+		t.Fatalf("planner.RuleStore.DeleteGraph failed with error %v", err)
+	}
+	if got, want := len(s.Rules()), 0; got != want {
+		t.Errorf("deleting the graph of a rule should have unregistered it; got %d rules, want %d", got, want)
+	}
+	if _, err := s.NewGraph("?derived"); err != nil {
+		t.Fatal(err)
+	}
+	r := testRule(t, "grandparent", "?derived", `?a "grandparent_of"@[] ?c`, body)
+	r.Materialized = true
+	if err := s.RegisterRule(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("planner.RuleStore.Close failed with error %v", err)
+	}
+	if got, want := len(s.Rules()), 0; got != want {
+		t.Errorf("closing the store should have unregistered its rules; got %d rules, want %d", got, want)
+	}
+	g, err := s.Graph("?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddTriples([]*triple.Triple{mustParseTriple(t, "/u<eve>\t\"parent_of\"@[]\t/u<zoe>")}); err != nil {
+		t.Fatal(err)
+	}
+	// The materialized triples are kept, but no longer maintained.
+	q := `select ?a, ?c from ?derived where {?a "grandparent_of"@[] ?c};`
+	if got, want := executeTestStatement(t, s, q), 2; got != want {
+		t.Errorf("closing the store changed the materialized triples; got %d, want %d", got, want)
+	}
+}
+
+func TestMaterializedRules(t *testing.T) {
+	testTable := []struct {
+		watched bool
+	}{
+		{watched: true},
+		{watched: false},
+	}
+	ctx := context.Background()
+	q := `select ?a, ?c from ?derived where {?a "grandparent_of"@[] ?c};`
+	for _, entry := range testTable {
+		var s storage.Store = populateTestStore(t)
+		d, err := s.NewGraph("?derived")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Triples already held by the graph are kept once no longer derived.
+		kept := mustParseTriple(t, "/u<joe>\t\"grandparent_of\"@[]\t/u<john>")
+		if err := d.AddTriples([]*triple.Triple{kept}); err != nil {
+			t.Fatal(err)
+		}
+		if !entry.watched {
+			s = &unwatchedStore{s}
+		}
+		rs := NewRuleStore(s)
+		r := testRule(t, "grandparent", "?derived", `?a "grandparent_of"@[] ?c`, `select ?a, ?c from ?test where {?a "parent_of"@[] ?b . ?b "parent_of"@[] ?c};`)
+		r.Materialized = true
+		if err := rs.RegisterRule(ctx, r); err != nil {
+			t.Fatalf("planner.RegisterRule failed to register rule %s with error %v", r.Name, err)
+		}
+		if got, want := executeTestStatement(t, rs, q), 2; got != want {
+			t.Errorf("rule (watched=%v) derived the wrong number of triples; got %d, want %d", entry.watched, got, want)
+		}
+		g, err := s.Graph("?test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tpl := mustParseTriple(t, "/u<eve>\t\"parent_of\"@[]\t/u<zoe>")
+		if err := g.AddTriples([]*triple.Triple{tpl}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := executeTestStatement(t, rs, q), 3; got != want {
+			t.Errorf("rule (watched=%v) derived the wrong number of triples after adding one; got %d, want %d", entry.watched, got, want)
+		}
+		if err := g.RemoveTriples([]*triple.Triple{tpl}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := executeTestStatement(t, rs, q), 2; got != want {
+			t.Errorf("rule (watched=%v) derived the wrong number of triples after removing one; got %d, want %d", entry.watched, got, want)
+		}
+		if err := rs.UnregisterRule(r.Name); err != nil {
+			t.Fatalf("planner.UnregisterRule failed with error %v", err)
+		}
+		if got, want := executeTestStatement(t, rs, q), 1; got != want {
+			t.Errorf("unregistering rule (watched=%v) left the wrong number of triples; got %d, want %d", entry.watched, got, want)
+		}
+	}
+}
+
+func TestRegisterRuleFails(t *testing.T) {
+	body := `select ?a, ?c from ?test where {?a "parent_of"@[] ?b . ?b "parent_of"@[] ?c};`
+	testTable := []struct {
+		r            *Rule
+		materialized bool
+	}{
+		{r: testRule(t, "", "?test", `?a "grandparent_of"@[] ?c`, body)},
+		{r: testRule(t, "missing", "?missing", `?a "grandparent_of"@[] ?c`, body)},
+		{r: testRule(t, "unselected", "?test", `?a "grandparent_of"@[] ?b`, body)},
+		{r: testRule(t, "alias", "?test", `?a "grandparent_of"@[] ?c as ?d`, body)},
+		{r: testRule(t, "bounds", "?test", `?a "grandparent_of"@[,] ?c`, body)},
+		{r: testRule(t, "scoped", "?test", `graph ?test {?a "grandparent_of"@[] ?c}`, body)},
+		{r: testRule(t, "self", "?test", `?a "grandparent_of"@[] ?c`, body), materialized: true},
+		{r: testRule(t, "unbound", "?test", `?a "grandparent_of"@[] ?c`, `select ?a, ?c from ?test where {?a "parent_of"@[] ?b};`)},
+	}
+	for _, entry := range testTable {
+		entry.r.Materialized = entry.materialized
+		if err := NewRuleStore(populateTestStore(t)).RegisterRule(context.Background(), entry.r); err == nil {
+			t.Errorf("planner.RegisterRule should have failed to register rule %q", entry.r.Name)
+		}
+	}
+	s := NewRuleStore(populateTestStore(t))
+	r := testRule(t, "grandparent", "?test", `?a "grandparent_of"@[] ?c`, body)
+	if err := s.RegisterRule(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterRule(context.Background(), r); err == nil {
+		t.Errorf("planner.RegisterRule should have failed to register rule %q twice", r.Name)
+	}
+	if err := s.UnregisterRule("missing"); err == nil {
+		t.Errorf("planner.UnregisterRule should have failed to unregister a missing rule")
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+)
+
+// ResultStream provides the rows resulting from executing a statement one at a
+// time, allowing callers to start consuming them before all the results are
+// computed.
+type ResultStream interface {
+	// Bindings returns the bindings available on the rows of the stream.
+	Bindings() []string
+
+	// Next advances the stream to the next row. It returns false once no more
+	// rows are available or an error was found.
+	Next() bool
+
+	// Row returns the current row of the stream.
+	Row() table.Row
+
+	// Err returns the error found while producing the rows, if any.
+	Err() error
+
+	// Close releases the resources held by the stream. It must always be called
+	// once the caller is done with the stream.
+	Close() error
+}
+
+// rowStream implements ResultStream on top of a row pipeline. It stops
+// returning rows once the provided context is done, or once the limits are
+// exceeded.
+type rowStream struct {
+	ctx    context.Context
+	parent context.Context
+	cancel context.CancelFunc
+	lmts   Limits
+	n      int64
+	bs     []string
+	op     operator
+	r      table.Row
+	err    error
+	done   bool
+	closed bool
+	prof   *Profile
+}
+
+// Bindings returns the bindings available on the rows of the stream.
+func (s *rowStream) Bindings() []string {
+	return s.bs
+}
+
+// Next advances the stream to the next row.
+func (s *rowStream) Next() bool {
+	if s.done || s.closed {
+		return false
+	}
+	r, err := s.op.next()
+	if err == nil {
+		err = s.ctx.Err()
+	}
+	if err == nil && r != nil {
+		s.n++
+		if m := s.lmts.MaxRows; m > 0 && s.n > m {
+			err = &LimitError{Limit: "MaxRows", Max: fmt.Sprint(m)}
+		}
+	}
+	if err != nil {
+		s.err = err
+		if s.parent != nil {
+			s.err = executionTimeError(s.parent, s.lmts, err)
+		}
+	}
+	if err != nil || r == nil {
+		s.r, s.done = nil, true
+		return false
+	}
+	s.r = r
+	return true
+}
+
+// Row returns the current row of the stream.
+func (s *rowStream) Row() table.Row {
+	return s.r
+}
+
+// Err returns the error found while producing the rows, if any.
+func (s *rowStream) Err() error {
+	return s.err
+}
+
+// Close releases the resources held by the stream. Closing an already closed
+// stream is a no-op.
+func (s *rowStream) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed, s.r = true, nil
+	if s.cancel != nil {
+		defer s.cancel()
+	}
+	return s.op.close()
+}
+
+// Profile returns the metrics collected while executing the query, or nil if
+// the stream does not return the results of a query.
+func (s *rowStream) Profile() *Profile {
+	return s.prof
+}
+
+// Stream runs the provided plan and returns a stream of its results. The rows
+// of query plans are produced as they are pulled from the stream, while other
+// plans are run at once.
+func Stream(ctx context.Context, e Excecutor) (ResultStream, error) {
+	if qp, ok := e.(*queryPlan); ok {
+		rs, err := qp.stream(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return rs, nil
+	}
+	tbl, err := e.Excecute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &rowStream{
+		ctx: ctx,
+		bs:  tbl.Bindings(),
+		op:  &scanOperator{ctx: ctx, it: tbl.Iterator(scanChunkSize)},
+	}, nil
+}
+
+// Execute runs the provided statement against the store and returns a stream
+// of the resulting rows. Query results are filtered, sorted, and trimmed as
+// rows are pulled from the stream.
+func Execute(ctx context.Context, store storage.Store, stm *semantic.Statement) (ResultStream, error) {
+	return ExecuteWithLimits(ctx, store, stm, Limits{})
+}
+
+// ExecuteWithLimits runs the provided statement against the store and returns
+// a stream of the resulting rows. Queries fail with a LimitError if they
+// exceed any of the provided limits.
+func ExecuteWithLimits(ctx context.Context, store storage.Store, stm *semantic.Statement, lmts Limits) (ResultStream, error) {
+	p, err := Prepare(stm)
+	if err != nil {
+		return nil, err
+	}
+	return p.Execute(ctx, store, lmts)
+}
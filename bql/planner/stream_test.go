@@ -0,0 +1,115 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+func parseTestStatement(t *testing.T, q string) *semantic.Statement {
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+	}
+	return st
+}
+
+func TestExecute(t *testing.T) {
+	testTable := []struct {
+		q     string
+		nbs   int
+		nRows int
+	}{
+		{
+			q:     `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`,
+			nbs:   2,
+			nRows: 4,
+		},
+		{
+			q:     `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} order by ?o desc limit "3"^^type:int64;`,
+			nbs:   2,
+			nRows: 3,
+		},
+		{
+			q:     `create graph ?foo;`,
+			nbs:   0,
+			nRows: 0,
+		},
+	}
+	for _, entry := range testTable {
+		s := populateTestStore(t)
+		rs, err := Execute(context.Background(), s, parseTestStatement(t, entry.q))
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", entry.q, err)
+		}
+		if got, want := len(rs.Bindings()), entry.nbs; got != want {
+			t.Errorf("planner.Execute returned the wrong number of bindings for query %q; got %d, want %d", entry.q, got, want)
+		}
+		n := 0
+		for rs.Next() {
+			if rs.Row() == nil {
+				t.Errorf("ResultStream.Row returned a nil row after a successful call to Next for query %q", entry.q)
+			}
+			n++
+		}
+		if err := rs.Err(); err != nil {
+			t.Errorf("ResultStream.Err returned error %v for query %q", err, entry.q)
+		}
+		if err := rs.Close(); err != nil {
+			t.Errorf("ResultStream.Close failed with error %v for query %q", err, entry.q)
+		}
+		if got, want := n, entry.nRows; got != want {
+			t.Errorf("planner.Execute streamed the wrong number of rows for query %q; got %d, want %d", entry.q, got, want)
+		}
+	}
+}
+
+func TestExecuteStopsStreaming(t *testing.T) {
+	q := `select ?s, ?o from ?test where {?s "parent_of"@[] ?o} order by ?o;`
+	s := populateTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rs, err := Execute(ctx, s, parseTestStatement(t, q))
+	if err != nil {
+		t.Fatalf("planner.Execute failed for query %q with error %v", q, err)
+	}
+	if !rs.Next() {
+		t.Fatalf("ResultStream.Next should have returned the first row for query %q; got error %v", q, rs.Err())
+	}
+	cancel()
+	if rs.Next() {
+		t.Errorf("ResultStream.Next should not return more rows once the context is cancelled")
+	}
+	if got, want := rs.Err(), context.Canceled; got != want {
+		t.Errorf("ResultStream.Err returned the wrong error; got %v, want %v", got, want)
+	}
+	if err := rs.Close(); err != nil {
+		t.Errorf("ResultStream.Close failed with error %v", err)
+	}
+	if err := rs.Close(); err != nil {
+		t.Errorf("ResultStream.Close should be a no-op on a closed stream; got error %v", err)
+	}
+	if rs.Next() {
+		t.Errorf("ResultStream.Next should not return rows once the stream is closed")
+	}
+}
@@ -0,0 +1,572 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// Delta contains the rows added to and removed from the results of a standing
+// query.
+type Delta struct {
+	// Bindings contains the bindings of the query results.
+	Bindings []string
+
+	// Added contains the rows that became part of the results.
+	Added []table.Row
+
+	// Removed contains the rows that are no longer part of the results.
+	Removed []table.Row
+}
+
+// Subscription keeps a standing query up to date with the changes applied to
+// the graphs it queries.
+type Subscription struct {
+	ctx  context.Context
+	stop context.CancelFunc
+	stm  *semantic.Statement
+	st   storage.Store
+	bs   []string
+	f    func(*Delta)
+	// inc is true if the deltas are computed from the changed triples instead
+	// of executing the query again; see incremental.
+	inc bool
+
+	// rws contains the current results, and keys the number of them for each
+	// row key. They are only accessed by the goroutine processing changes once
+	// the subscription is created.
+	rws  []table.Row
+	keys map[string]int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*storage.Change
+	queued  int64
+	done    int64
+	cancels []func()
+	err     error
+	closed  bool
+}
+
+// Subscribe registers a standing query. The provided function is first called
+// with the current results of the query, and then with the rows added to and
+// removed from them after each change applied to the queried graphs. All the
+// queried graphs must implement storage.Watcher. Changes are queued as they
+// are notified by the graphs, and processed in order by a goroutine owned by
+// the subscription, which also calls the function one delta at a time, so
+// updating the graphs is never blocked by it. Since the graphs may have been
+// updated further by the time a change is processed, intermediate deltas may
+// reflect those updates, but the results always converge to the ones of the
+// query once all the changes are processed. The subscription ends once it is
+// closed, the provided context is done, or the query fails to execute.
+func Subscribe(ctx context.Context, store storage.Store, stm *semantic.Statement, f func(*Delta)) (*Subscription, error) {
+	stm, err := semantic.Rewrite(stm)
+	if err != nil {
+		return nil, err
+	}
+	if stm.Type() != semantic.Query {
+		return nil, fmt.Errorf("planner.Subscribe: only queries can be subscribed to, got %s statement", stm.Type())
+	}
+	if f == nil {
+		return nil, fmt.Errorf("planner.Subscribe: nil delta function")
+	}
+	var ws []storage.Watcher
+	for _, id := range stm.Graphs() {
+		g, err := store.Graph(id)
+		if err != nil {
+			return nil, fmt.Errorf("planner.Subscribe: %v", err)
+		}
+		w, ok := g.(storage.Watcher)
+		if !ok {
+			return nil, fmt.Errorf("planner.Subscribe: graph %q does not provide a change feed", id)
+		}
+		ws = append(ws, w)
+	}
+	bs := append([]string{}, stm.Bindings()...)
+	sort.Strings(bs)
+	ctx, stop := context.WithCancel(ctx)
+	s := &Subscription{
+		ctx:  ctx,
+		stop: stop,
+		stm:  stm,
+		st:   store,
+		bs:   bs,
+		f:    f,
+		inc:  incremental(store, stm),
+		keys: make(map[string]int),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	// Changes notified before the initial results are available are queued,
+	// and processed afterwards. Processing them is idempotent, so the ones
+	// already reflected on the initial results produce no deltas.
+	for _, w := range ws {
+		c, err := w.Watch(s.update)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("planner.Subscribe: %v", err)
+		}
+		s.mu.Lock()
+		s.cancels = append(s.cancels, c)
+		s.mu.Unlock()
+	}
+	d, err := s.refresh()
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("planner.Subscribe: %v", err)
+	}
+	if d != nil {
+		f(d)
+	}
+	go s.process()
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+	return s, nil
+}
+
+// update queues a change applied to one of the queried graphs, if it may
+// affect the results of the query.
+func (s *Subscription) update(c *storage.Change) {
+	if !s.affected(c) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.queue = append(s.queue, c)
+	s.queued++
+	s.cond.Broadcast()
+}
+
+// process computes and notifies the deltas for the queued changes, in order,
+// until the subscription is closed.
+func (s *Subscription) process() {
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		c := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		d, err := s.delta(c)
+		if err == nil && d != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if !closed {
+				s.f(d)
+			}
+		}
+
+		s.mu.Lock()
+		s.done++
+		s.cond.Broadcast()
+		if err != nil {
+			s.err = err
+			s.closeLocked()
+		}
+		closed := s.closed
+		s.mu.Unlock()
+		if closed {
+			return
+		}
+	}
+}
+
+// wait blocks until the changes queued so far have been processed and their
+// deltas notified, or the subscription is closed, and returns the error that
+// ended it, if any.
+func (s *Subscription) wait() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n := s.queued; s.done < n && !s.closed; {
+		s.cond.Wait()
+	}
+	return s.err
+}
+
+// delta returns the changes to the results of the query after the provided
+// change, or nil if they did not change.
+func (s *Subscription) delta(c *storage.Change) (*Delta, error) {
+	if !s.inc {
+		return s.refresh()
+	}
+	d := &Delta{Bindings: append([]string{}, s.bs...)}
+	if len(c.Removed) > 0 {
+		ks, err := s.seedKeys(c.Graph, c.Removed)
+		if err != nil {
+			return nil, err
+		}
+		var rws []table.Row
+		for _, r := range s.rws {
+			if !matchesSeeds(r, ks) {
+				rws = append(rws, r)
+				continue
+			}
+			s.keys[table.RowKey(r, s.bs)]--
+			d.Removed = append(d.Removed, r)
+		}
+		s.rws = rws
+	}
+	if len(c.Added) > 0 {
+		rws, err := derivations(s.ctx, s.st, s.stm, map[string][]*triple.Triple{c.Graph: c.Added})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rws {
+			// Rows derived from several of the added triples, or already
+			// reflected on the results, are only added once.
+			k := table.RowKey(r, s.bs)
+			if s.keys[k] > 0 {
+				continue
+			}
+			s.keys[k]++
+			s.rws = append(s.rws, r)
+			d.Added = append(d.Added, r)
+		}
+	}
+	if len(d.Added) == 0 && len(d.Removed) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// refresh executes the query and returns the differences with the results of
+// its previous execution, or nil if there are none.
+func (s *Subscription) refresh() (*Delta, error) {
+	pln, err := newQueryPlan(s.st, s.stm)
+	if err != nil {
+		return nil, err
+	}
+	tbl, err := pln.Excecute(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range tbl.Bindings() {
+		if !contains(s.bs, b) {
+			s.bs = append(s.bs, b)
+			sort.Strings(s.bs)
+		}
+	}
+	rws := tbl.Rows()
+	if err := tbl.Close(); err != nil {
+		return nil, err
+	}
+	d := &Delta{Bindings: append([]string{}, s.bs...)}
+	d.Added, d.Removed = diffRows(s.bs, s.rws, rws)
+	s.rws = rws
+	s.keys = make(map[string]int, len(rws))
+	for _, r := range rws {
+		s.keys[table.RowKey(r, s.bs)]++
+	}
+	if len(d.Added) == 0 && len(d.Removed) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// incremental returns true if the deltas of the query results can be computed
+// from the changed triples. That requires every result row to be derived from
+// a distinct set of triples, which is identified by the values of its
+// bindings: the query may only have a graph pattern over a single graph whose
+// clauses bind or fix the subject, predicate, and object of the triples they
+// match, and no aggregations, having, limit, or minus clauses. Graphs holding
+// views or rule derivations are not supported either, since they change along
+// with the graphs they are derived from.
+func incremental(store storage.Store, stm *semantic.Statement) bool {
+	if len(stm.Graphs()) != 1 || len(stm.MinusPatterns()) > 0 || len(aggregations(stm)) > 0 || stm.IsLimitSet() {
+		return false
+	}
+	if e := stm.HavingEvaluator(); e != nil {
+		if v, ok := semantic.ConstantValue(e); !ok || !v {
+			return false
+		}
+	}
+	if derived(store, stm.Graphs()[0]) {
+		return false
+	}
+	for _, cls := range stm.OrderedGraphPatternClauses() {
+		if !identifying(cls) {
+			return false
+		}
+	}
+	return true
+}
+
+// identifying returns true if the values bound by the clause identify the
+// triple it matched.
+func identifying(cls *semantic.GraphClause) bool {
+	if cls.PTransitive || cls.PLatest || cls.OEmbedded != nil {
+		return false
+	}
+	return (cls.S != nil || cls.SBinding != "") &&
+		(cls.PBinding != "" || cls.P != nil && !cls.PTemporal) &&
+		(cls.OBinding != "" || cls.O != nil && !cls.OTemporal)
+}
+
+// derived returns true if the graph holds a view or the triples derived by
+// rules.
+func derived(store storage.Store, id string) bool {
+	views.mu.Lock()
+	_, ok := views.m[viewKey{store, id}]
+	views.mu.Unlock()
+	if ok {
+		return true
+	}
+	rs, ok := store.(*RuleStore)
+	return ok && rs.derives(id)
+}
+
+// deltaPlan returns a query plan for the statement against graphs only
+// holding the provided triples, by the ID of the graph they changed on. The
+// rest of the graphs queried are empty.
+func deltaPlan(stm *semantic.Statement, ds map[string][]*triple.Triple) (*queryPlan, error) {
+	st := memory.NewStore()
+	for _, id := range stm.Graphs() {
+		g, err := st.NewGraph(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := g.AddTriples(ds[id]); err != nil {
+			return nil, err
+		}
+	}
+	return preparedQueryPlan(st, stm)
+}
+
+// seeds contains the keys of the values bound by a clause when matching some
+// triples.
+type seeds struct {
+	bs   []string
+	keys map[string]bool
+}
+
+// seedKeys returns, for each clause of the graph pattern, the keys of the
+// values it binds when matching any of the provided triples. Result rows
+// sharing those values were derived from them.
+func (s *Subscription) seedKeys(id string, ts []*triple.Triple) ([]*seeds, error) {
+	dp, err := deltaPlan(s.stm, map[string][]*triple.Triple{id: ts})
+	if err != nil {
+		return nil, err
+	}
+	lo := s.stm.GlobalLookupOptions()
+	var sds []*seeds
+	for _, cls := range dp.cls {
+		tbl, err := dp.fetch(s.ctx, cls, lo, newBindingChecker())
+		if err != nil {
+			return nil, err
+		}
+		if tbl.NumRows() == 0 {
+			continue
+		}
+		sd := &seeds{bs: cls.Bindings(), keys: make(map[string]bool)}
+		sort.Strings(sd.bs)
+		for _, r := range tbl.Rows() {
+			sd.keys[table.RowKey(r, sd.bs)] = true
+		}
+		sds = append(sds, sd)
+	}
+	return sds, nil
+}
+
+// matchesSeeds returns true if the row shares the values bound by any of the
+// clauses with one of their seed keys.
+func matchesSeeds(r table.Row, sds []*seeds) bool {
+	for _, sd := range sds {
+		if sd.keys[table.RowKey(r, sd.bs)] {
+			return true
+		}
+	}
+	return false
+}
+
+// derivations returns the rows of the graph pattern of the statement derived
+// from at least one of the provided triples, by the ID of the graph they were
+// added to. For each clause matching any of them, the rows it produces from
+// them are joined with the data the rest of the clauses match on the store.
+func derivations(ctx context.Context, st storage.Store, stm *semantic.Statement, ds map[string][]*triple.Triple) ([]table.Row, error) {
+	dp, err := deltaPlan(stm, ds)
+	if err != nil {
+		return nil, err
+	}
+	lo := stm.GlobalLookupOptions()
+	var rws []table.Row
+	for _, seed := range dp.cls {
+		tbl, err := dp.fetch(ctx, seed, lo, newBindingChecker())
+		if err != nil {
+			return nil, err
+		}
+		if tbl.NumRows() == 0 {
+			continue
+		}
+		p, err := preparedQueryPlan(st, stm)
+		if err != nil {
+			return nil, err
+		}
+		p.tbl.AddBindings(seed.Bindings())
+		var op operator = &scanOperator{ctx: ctx, rows: tbl.Rows()}
+		for _, cls := range p.cls {
+			if cls != seed {
+				op = p.joinClause(ctx, op, cls, newBindingChecker(), lo, &ClauseProfile{})
+			}
+		}
+		for {
+			r, err := op.next()
+			if err != nil || r == nil {
+				if cerr := op.close(); err == nil {
+					err = cerr
+				}
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+			rws = append(rws, r)
+		}
+	}
+	return rws, nil
+}
+
+// affected returns true if any of the changed triples could match one of the
+// clauses of the query graph pattern or minus patterns.
+func (s *Subscription) affected(c *storage.Change) bool {
+	var cls []*semantic.GraphClause
+	cls = append(cls, s.stm.OrderedGraphPatternClauses()...)
+	for _, m := range s.stm.MinusPatterns() {
+		cls = append(cls, m...)
+	}
+	for _, ts := range [][]*triple.Triple{c.Added, c.Removed} {
+		for _, t := range ts {
+			for _, cl := range cls {
+				if clauseMatches(cl, c.Graph, t) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// clauseMatches returns false if the triple stored on the given graph
+// contradicts any of the values specified on the clause.
+func clauseMatches(cls *semantic.GraphClause, g string, t *triple.Triple) bool {
+	if cls.Graph != "" && cls.Graph != g {
+		return false
+	}
+	if cls.PTransitive {
+		// Any triple using the predicate may link the chains matched.
+		return cls.P.String() == t.P().String()
+	}
+	if cls.S != nil && cls.S.String() != t.S().String() {
+		return false
+	}
+	if cls.P != nil && cls.P.String() != t.P().String() {
+		return false
+	}
+	if cls.PID != "" && t.P().ID() != predicate.ID(cls.PID) {
+		return false
+	}
+	if cls.O != nil && cls.O.GUID() != t.O().GUID() {
+		return false
+	}
+	return true
+}
+
+// diffRows returns the rows of the new results not present on the old ones,
+// and the rows of the old results not present on the new ones. Rows are
+// compared on the provided bindings, and duplicated rows are accounted for.
+func diffRows(bs []string, old, new []table.Row) ([]table.Row, []table.Row) {
+	cnt := make(map[string]int)
+	for _, r := range old {
+		cnt[table.RowKey(r, bs)]++
+	}
+	var added, removed []table.Row
+	for _, r := range new {
+		k := table.RowKey(r, bs)
+		if cnt[k] > 0 {
+			cnt[k]--
+			continue
+		}
+		added = append(added, r)
+	}
+	for _, r := range old {
+		k := table.RowKey(r, bs)
+		if cnt[k] > 0 {
+			cnt[k]--
+			removed = append(removed, r)
+		}
+	}
+	return added, removed
+}
+
+// contains returns true if the binding is in the provided list.
+func contains(bs []string, b string) bool {
+	for _, v := range bs {
+		if v == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the error that ended the subscription, if any.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription. No more deltas are notified once it returns,
+// other than the one being notified when it is called, if any.
+func (s *Subscription) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+	return nil
+}
+
+// closeLocked ends the subscription. It must be called holding the lock.
+func (s *Subscription) closeLocked() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.queue = nil
+	for _, c := range s.cancels {
+		c()
+	}
+	s.stop()
+	s.cond.Broadcast()
+}
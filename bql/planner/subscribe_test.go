@@ -0,0 +1,251 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestSubscribe(t *testing.T) {
+	s := populateTestStore(t)
+	g, err := s.Graph("?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ds []*Delta
+	stm := parseTestStatement(t, `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`)
+	sub, err := Subscribe(context.Background(), s, stm, func(d *Delta) {
+		ds = append(ds, d)
+	})
+	if err != nil {
+		t.Fatalf("planner.Subscribe failed with error %v", err)
+	}
+	newTriple := func(s string) *triple.Triple {
+		tpl, err := triple.ParseTriple(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.ParseTriple failed to parse valid triple %q with error %v", s, err)
+		}
+		return tpl
+	}
+	added := newTriple("/u<mary>\t\"parent_of\"@[]\t/u<amy>")
+	if err := g.AddTriples([]*triple.Triple{added}); err != nil {
+		t.Fatal(err)
+	}
+	// Changes not matching the query do not produce deltas.
+	if err := g.AddTriples([]*triple.Triple{newTriple("/u<mary>\t\"bought\"@[]\t/c<mini>")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RemoveTriples([]*triple.Triple{newTriple("/u<joe>\t\"parent_of\"@[]\t/u<mary>")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.wait(); err != nil {
+		t.Fatalf("Subscription.wait failed with error %v", err)
+	}
+	if err := sub.Close(); err != nil {
+		t.Fatalf("Subscription.Close failed with error %v", err)
+	}
+	if err := g.AddTriples([]*triple.Triple{newTriple("/u<amy>\t\"parent_of\"@[]\t/u<tim>")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Err(); err != nil {
+		t.Errorf("Subscription.Err returned error %v", err)
+	}
+
+	want := []struct {
+		added, removed int
+		s, o           string
+	}{
+		{added: 4},
+		{added: 1, s: "/u<mary>", o: "/u<amy>"},
+		{removed: 1, s: "/u<joe>", o: "/u<mary>"},
+	}
+	if got, want := len(ds), len(want); got != want {
+		t.Fatalf("planner.Subscribe notified the wrong number of deltas; got %d, want %d", got, want)
+	}
+	for i, d := range ds {
+		if got, want := len(d.Added), want[i].added; got != want {
+			t.Errorf("delta %d contains the wrong number of added rows; got %d, want %d", i, got, want)
+		}
+		if got, want := len(d.Removed), want[i].removed; got != want {
+			t.Errorf("delta %d contains the wrong number of removed rows; got %d, want %d", i, got, want)
+		}
+		if want[i].s == "" {
+			continue
+		}
+		rws := append(d.Added, d.Removed...)
+		if got, want := rws[0]["?s"].String(), want[i].s; got != want {
+			t.Errorf("delta %d returned the wrong value for ?s; got %q, want %q", i, got, want)
+		}
+		if got, want := rws[0]["?o"].String(), want[i].o; got != want {
+			t.Errorf("delta %d returned the wrong value for ?o; got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestSubscribeIncremental(t *testing.T) {
+	testTable := []struct {
+		q     string
+		inc   bool
+		delta []int
+	}{
+		{
+			q:     `select ?s, ?c, ?g from ?test where {?s "parent_of"@[] ?c . ?c "parent_of"@[] ?g};`,
+			inc:   true,
+			delta: []int{2, 1, 1, -1},
+		},
+		{
+			q:     `select ?s, count(?c) as ?n from ?test where {?s "parent_of"@[] ?c} group by ?s;`,
+			inc:   false,
+			delta: []int{2, 1, 1, 1, -1},
+		},
+	}
+	newTriple := func(s string) *triple.Triple {
+		tpl, err := triple.ParseTriple(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.ParseTriple failed to parse valid triple %q with error %v", s, err)
+		}
+		return tpl
+	}
+	for _, entry := range testTable {
+		s := populateTestStore(t)
+		g, err := s.Graph("?test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ds []*Delta
+		stm := parseTestStatement(t, entry.q)
+		sub, err := Subscribe(context.Background(), s, stm, func(d *Delta) {
+			ds = append(ds, d)
+		})
+		if err != nil {
+			t.Fatalf("planner.Subscribe(%q) failed with error %v", entry.q, err)
+		}
+		if got, want := sub.inc, entry.inc; got != want {
+			t.Errorf("planner.Subscribe(%q) computes deltas incrementally %v; want %v", entry.q, got, want)
+		}
+		for _, c := range []struct {
+			add bool
+			t   string
+		}{
+			{true, "/u<mary>\t\"parent_of\"@[]\t/u<amy>"},
+			{true, "/u<amy>\t\"parent_of\"@[]\t/u<tim>"},
+			{false, "/u<peter>\t\"parent_of\"@[]\t/u<john>"},
+		} {
+			ts := []*triple.Triple{newTriple(c.t)}
+			if c.add {
+				err = g.AddTriples(ts)
+			} else {
+				err = g.RemoveTriples(ts)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Waiting for each change to be processed makes the deltas
+			// deterministic.
+			if err := sub.wait(); err != nil {
+				t.Fatalf("Subscription.wait failed for query %q with error %v", entry.q, err)
+			}
+		}
+		var got []int
+		for _, d := range ds {
+			if len(d.Added) > 0 {
+				got = append(got, len(d.Added))
+			}
+			if len(d.Removed) > 0 {
+				got = append(got, -len(d.Removed))
+			}
+		}
+		if !reflect.DeepEqual(got, entry.delta) {
+			t.Errorf("planner.Subscribe(%q) notified the wrong deltas; got %v, want %v", entry.q, got, entry.delta)
+		}
+		pln, err := newQueryPlan(s, stm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tbl, err := pln.Excecute(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added, removed := diffRows(sub.bs, sub.rws, tbl.Rows()); len(added) > 0 || len(removed) > 0 {
+			t.Errorf("planner.Subscribe(%q) kept results differing from the query ones; missing %v, extra %v", entry.q, added, removed)
+		}
+		sub.Close()
+	}
+}
+
+func TestSubscribeDoesNotBlockUpdates(t *testing.T) {
+	s := populateTestStore(t)
+	g, err := s.Graph("?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	n := 0
+	stm := parseTestStatement(t, `select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`)
+	sub, err := Subscribe(context.Background(), s, stm, func(d *Delta) {
+		if n++; n > 1 {
+			<-release
+		}
+	})
+	if err != nil {
+		t.Fatalf("planner.Subscribe failed with error %v", err)
+	}
+	defer sub.Close()
+	for _, o := range []string{"/u<amy>", "/u<tim>"} {
+		tpl, err := triple.ParseTriple("/u<mary>\t\"parent_of\"@[]\t"+o, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Updates return while the subscription is blocked notifying the
+		// first delta.
+		if err := g.AddTriples([]*triple.Triple{tpl}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	if err := sub.wait(); err != nil {
+		t.Fatalf("Subscription.wait failed with error %v", err)
+	}
+	if got, want := n, 3; got != want {
+		t.Errorf("planner.Subscribe notified %d deltas; want %d", got, want)
+	}
+}
+
+func TestSubscribeRejectsNonQueries(t *testing.T) {
+	s := populateTestStore(t)
+	stm := parseTestStatement(t, `create graph ?foo;`)
+	if _, err := Subscribe(context.Background(), s, stm, func(*Delta) {}); err == nil {
+		t.Errorf("planner.Subscribe should have failed for a create statement")
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	a := table.Row{"?x": &table.Cell{S: "a"}}
+	b := table.Row{"?x": &table.Cell{S: "b"}}
+	c := table.Row{"?x": &table.Cell{S: "c"}}
+	added, removed := diffRows([]string{"?x"}, []table.Row{a, a, b}, []table.Row{a, c})
+	if got, want := len(added), 1; got != want || table.RowKey(added[0], []string{"?x"}) != table.RowKey(c, []string{"?x"}) {
+		t.Errorf("diffRows returned the wrong added rows; got %v, want [%v]", added, c)
+	}
+	if got, want := len(removed), 2; got != want {
+		t.Errorf("diffRows returned the wrong number of removed rows; got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// viewKey identifies a view by the store holding its graph and its ID.
+type viewKey struct {
+	store storage.Store
+	id    string
+}
+
+// views contains the views registered on each store.
+var views = struct {
+	mu sync.Mutex
+	m  map[viewKey]*view
+}{
+	m: make(map[viewKey]*view),
+}
+
+// view keeps a graph materializing the triples derived from the results of a
+// query. The graph contains the triples instantiated by the head clauses for
+// each of the result rows: the graph pattern of the query for views, or the
+// head of materialized rules. Views over graphs providing a change feed are
+// maintained incrementally; otherwise, their query is executed again each time
+// the view is accessed.
+type view struct {
+	store storage.Store
+	stm   *semantic.Statement
+	head  []*semantic.GraphClause
+	g     storage.Graph
+
+	mu   sync.Mutex
+	sub  *Subscription
+	rws  []table.Row
+	refs map[string]int
+	// added contains the derived triples added to the graph, by GUID. Derived
+	// triples the graph already held are not removed once no longer derived.
+	added map[string]*triple.Triple
+	err   error
+}
+
+// createViewPlan encapsulates the sequence of instructions that need to be
+// excecuted in order to satisfy the exceution of a valid create view BQL
+// statement.
+type createViewPlan struct {
+	stm   *semantic.Statement
+	store storage.Store
+}
+
+// Execute creates the graph of the view and registers it.
+func (p *createViewPlan) Excecute(ctx context.Context) (*table.Table, error) {
+	t, err := table.New([]string{})
+	if err != nil {
+		return nil, err
+	}
+	id := p.stm.View()
+	for _, g := range p.stm.Graphs() {
+		if g == id {
+			return nil, fmt.Errorf("planner.Excecute: view %s cannot query itself", id)
+		}
+	}
+	watched := true
+	for _, id := range p.stm.Graphs() {
+		g, err := p.store.Graph(id)
+		if err != nil {
+			return nil, fmt.Errorf("planner.Excecute: %v", err)
+		}
+		if _, ok := g.(storage.Watcher); !ok {
+			watched = false
+		}
+	}
+	g, err := p.store.NewGraph(id)
+	if err != nil {
+		return nil, err
+	}
+	// The view query is executed as a regular query.
+	stm := &semantic.Statement{}
+	*stm = *p.stm
+	stm.BindType(semantic.Query)
+	v := &view{
+		store: p.store,
+		stm:   stm,
+		head:  stm.OrderedGraphPatternClauses(),
+		g:     g,
+		refs:  make(map[string]int),
+		added: make(map[string]*triple.Triple),
+	}
+	if watched {
+		v.sub, err = Subscribe(context.Background(), p.store, stm, v.apply)
+	} else {
+		err = v.refresh(ctx)
+	}
+	if err == nil {
+		err = v.failure()
+	}
+	if err != nil {
+		if v.sub != nil {
+			v.sub.Close()
+		}
+		p.store.DeleteGraph(id)
+		return nil, fmt.Errorf("planner.Excecute: failed to create view %s with error %v", id, err)
+	}
+	views.mu.Lock()
+	defer views.mu.Unlock()
+	views.m[viewKey{p.store, id}] = v
+	return t, nil
+}
+
+// refresh executes the view query and applies the changes to its results.
+func (v *view) refresh(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	pln, err := newQueryPlan(v.store, v.stm)
+	if err != nil {
+		return err
+	}
+	tbl, err := pln.Excecute(ctx)
+	if err != nil {
+		return err
+	}
+	bs := tbl.Bindings()
+	rws := tbl.Rows()
+	if err := tbl.Close(); err != nil {
+		return err
+	}
+	d := &Delta{Bindings: bs}
+	d.Added, d.Removed = diffRows(bs, v.rws, rws)
+	v.rws = rws
+	v.applyLocked(d)
+	return v.err
+}
+
+// apply updates the triples of the view graph with the rows added to and
+// removed from the view results.
+func (v *view) apply(d *Delta) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.applyLocked(d)
+}
+
+// applyLocked updates the triples of the view graph with the provided delta.
+// A triple is kept on the graph as long as one of the result rows produces
+// it. It must be called holding the lock.
+func (v *view) applyLocked(d *Delta) {
+	var add, rm []*triple.Triple
+	for _, r := range d.Added {
+		ts, err := rowTriples(v.head, r)
+		if err != nil {
+			v.err = err
+			return
+		}
+		for _, t := range ts {
+			v.refs[t.GUID()]++
+			if v.refs[t.GUID()] > 1 {
+				continue
+			}
+			ok, err := v.g.Exist(t)
+			if err != nil {
+				v.err = err
+				return
+			}
+			if !ok {
+				v.added[t.GUID()] = t
+				add = append(add, t)
+			}
+		}
+	}
+	for _, r := range d.Removed {
+		ts, err := rowTriples(v.head, r)
+		if err != nil {
+			v.err = err
+			return
+		}
+		for _, t := range ts {
+			v.refs[t.GUID()]--
+			if v.refs[t.GUID()] == 0 {
+				delete(v.refs, t.GUID())
+				if _, ok := v.added[t.GUID()]; ok {
+					delete(v.added, t.GUID())
+					rm = append(rm, t)
+				}
+			}
+		}
+	}
+	if err := v.g.RemoveTriples(rm); err != nil {
+		v.err = err
+		return
+	}
+	if err := v.g.AddTriples(add); err != nil {
+		v.err = err
+	}
+}
+
+// failure returns the error that prevented the view from being maintained,
+// if any.
+func (v *view) failure() error {
+	v.mu.Lock()
+	err := v.err
+	v.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if v.sub != nil {
+		return v.sub.Err()
+	}
+	return nil
+}
+
+// update brings the view up to date, executing its query again if it is not
+// maintained incrementally or waiting for the pending changes to be applied
+// otherwise, and fails if it could not be maintained.
+func (v *view) update(ctx context.Context) error {
+	if v.sub == nil {
+		return v.refresh(ctx)
+	}
+	if err := v.sub.wait(); err != nil {
+		return err
+	}
+	return v.failure()
+}
+
+// close stops maintaining the view.
+func (v *view) close() {
+	if v.sub != nil {
+		v.sub.Close()
+	}
+}
+
+// clear stops maintaining the view and removes the triples it derived from
+// its graph.
+func (v *view) clear() error {
+	v.close()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var rm []*triple.Triple
+	for _, t := range v.added {
+		rm = append(rm, t)
+	}
+	v.rws, v.refs, v.added = nil, make(map[string]int), make(map[string]*triple.Triple)
+	return v.g.RemoveTriples(rm)
+}
+
+// refreshViews brings up to date the views among the provided graphs that are
+// not maintained incrementally, and fails if any of them could not be
+// maintained.
+func refreshViews(ctx context.Context, store storage.Store, gs []string) error {
+	for _, id := range gs {
+		views.mu.Lock()
+		v, ok := views.m[viewKey{store, id}]
+		views.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if err := v.update(ctx); err != nil {
+			return fmt.Errorf("view %s is not up to date: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// dropView stops maintaining the view, if the graph belongs to one. The views
+// querying the graph are dropped too, deleting their graphs, since their
+// triples can no longer be derived.
+func dropView(store storage.Store, id string) error {
+	views.mu.Lock()
+	var deps []string
+	for k, v := range views.m {
+		if k.store != store {
+			continue
+		}
+		drop := k.id == id
+		for _, g := range v.stm.Graphs() {
+			if !drop && g == id {
+				drop = true
+				deps = append(deps, k.id)
+			}
+		}
+		if drop {
+			v.close()
+			delete(views.m, k)
+		}
+	}
+	views.mu.Unlock()
+	for _, d := range deps {
+		if err := dropView(store, d); err != nil {
+			return err
+		}
+		if err := store.DeleteGraph(d); err != nil {
+			return fmt.Errorf("failed to drop view %s over %s; %v", d, id, err)
+		}
+	}
+	return nil
+}
+
+// rowTriples returns the triples matched by the provided clauses for the
+// result row.
+func rowTriples(head []*semantic.GraphClause, r table.Row) ([]*triple.Triple, error) {
+	var ts []*triple.Triple
+	for _, cls := range head {
+		t, err := clauseTriple(cls, r)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			ts = append(ts, t)
+		}
+	}
+	return ts, nil
+}
+
+// clauseTriple returns the triple matched by the clause once its bindings are
+// replaced by the values available on the row. It returns nil if the row does
+// not fully specify the triple.
+func clauseTriple(cls *semantic.GraphClause, r table.Row) (*triple.Triple, error) {
+	s := cls.S
+	if s == nil {
+		if v := getBindedValueForComponent(r, []string{cls.SBinding, cls.SAlias}); v != nil {
+			s = v.N
+		}
+	}
+	p := cls.P
+	if p == nil {
+		if v := getBindedValueForComponent(r, []string{cls.PBinding, cls.PAlias}); v != nil {
+			p = v.P
+		}
+	}
+	if p == nil && cls.PID != "" {
+		np, err := idPredicate(cls.PID, r, cls.PAnchorBinding, cls.PAnchorAlias)
+		if err != nil {
+			return nil, err
+		}
+		p = np
+	}
+	o := cls.O
+	if o == nil && cls.OEmbedded != nil {
+		eo, err := embeddedObject(cls.OEmbedded, r)
+		if err != nil {
+			return nil, err
+		}
+		o = eo
+	}
+	if o == nil {
+		if v := getBindedValueForComponent(r, []string{cls.OBinding, cls.OAlias}); v != nil {
+			co, err := cellToObject(v)
+			if err != nil {
+				return nil, err
+			}
+			o = co
+		}
+	}
+	if o == nil && cls.OID != "" {
+		op, err := idPredicate(cls.OID, r, cls.OAnchorBinding, cls.OAnchorAlias)
+		if err != nil {
+			return nil, err
+		}
+		if op != nil {
+			o = triple.NewPredicateObject(op)
+		}
+	}
+	if s == nil || p == nil || o == nil {
+		return nil, nil
+	}
+	return triple.New(s, p, o)
+}
+
+// idPredicate returns the predicate with the provided ID anchored at the time
+// bound to the anchor bindings on the row. It returns nil if no time is bound.
+func idPredicate(id string, r table.Row, bs ...string) (*predicate.Predicate, error) {
+	v := getBindedValueForComponent(r, bs)
+	if v == nil || v.T == nil {
+		return nil, nil
+	}
+	return predicate.NewTemporal(id, *v.T)
+}
@@ -0,0 +1,128 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// unwatchedStore hides the change feed of the graphs of the wrapped store.
+type unwatchedStore struct {
+	storage.Store
+}
+
+// unwatchedGraph hides the change feed of the wrapped graph.
+type unwatchedGraph struct {
+	storage.Graph
+}
+
+func (s *unwatchedStore) Graph(id string) (storage.Graph, error) {
+	g, err := s.Store.Graph(id)
+	if err != nil {
+		return nil, err
+	}
+	return &unwatchedGraph{g}, nil
+}
+
+func executeTestStatement(t *testing.T, s storage.Store, q string) int {
+	pln, err := New(s, parseTestStatement(t, q))
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid plan for %q with error %v", q, err)
+	}
+	tbl, err := pln.Excecute(context.Background())
+	if err != nil {
+		t.Fatalf("planner.Excecute failed for %q with error %v", q, err)
+	}
+	return tbl.NumRows()
+}
+
+func TestCreateView(t *testing.T) {
+	testTable := []struct {
+		watched bool
+	}{
+		{watched: true},
+		{watched: false},
+	}
+	for _, entry := range testTable {
+		s := populateTestStore(t)
+		if !entry.watched {
+			s = &unwatchedStore{s}
+		}
+		executeTestStatement(t, s, `create view ?v as select ?s, ?t, ?o from ?test where {?s "parent_of"@[] ?o. ?o "bought"@[?t] ?c};`)
+		q := `select ?s, ?p, ?o from ?v where {?s ?p ?o};`
+		if got, want := executeTestStatement(t, s, q), 5; got != want {
+			t.Errorf("view ?v (watched=%v) contains the wrong number of triples; got %d, want %d", entry.watched, got, want)
+		}
+		g, err := s.Graph("?test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tpl, err := triple.ParseTriple("/u<peter>\t\"bought\"@[2016-05-01T00:00:00-08:00]\t/c<model 3>", literal.DefaultBuilder())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.AddTriples([]*triple.Triple{tpl}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := executeTestStatement(t, s, q), 6; got != want {
+			t.Errorf("view ?v (watched=%v) contains the wrong number of triples after adding one; got %d, want %d", entry.watched, got, want)
+		}
+		if err := g.RemoveTriples([]*triple.Triple{tpl}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := executeTestStatement(t, s, q), 5; got != want {
+			t.Errorf("view ?v (watched=%v) contains the wrong number of triples after removing one; got %d, want %d", entry.watched, got, want)
+		}
+		// Views can be queried as any other graph.
+		if got, want := executeTestStatement(t, s, `select ?t from ?v where {/u<peter> "bought"@[?t] ?c};`), 4; got != want {
+			t.Errorf("view ?v (watched=%v) returned the wrong number of rows; got %d, want %d", entry.watched, got, want)
+		}
+		executeTestStatement(t, s, `drop graph ?v;`)
+		if _, ok := views.m[viewKey{s, "?v"}]; ok {
+			t.Errorf("drop graph should have unregistered view ?v (watched=%v)", entry.watched)
+		}
+		// Dropping a graph a view queries drops the view.
+		executeTestStatement(t, s, `create view ?w as select ?s, ?o from ?test where {?s "parent_of"@[] ?o};`)
+		executeTestStatement(t, s, `drop graph ?test;`)
+		if _, ok := views.m[viewKey{s, "?w"}]; ok {
+			t.Errorf("dropping ?test should have unregistered view ?w (watched=%v)", entry.watched)
+		}
+		if _, err := s.Graph("?w"); err == nil {
+			t.Errorf("dropping ?test should have deleted the graph of view ?w (watched=%v)", entry.watched)
+		}
+	}
+}
+
+func TestCreateViewFails(t *testing.T) {
+	testTable := []string{
+		`create view ?test as select ?s from ?test where {?s "parent_of"@[] ?o};`,
+		`create view ?v as select ?s from ?missing where {?s "parent_of"@[] ?o};`,
+	}
+	for _, q := range testTable {
+		s := populateTestStore(t)
+		pln, err := New(s, parseTestStatement(t, q))
+		if err != nil {
+			t.Fatalf("planner.New failed to create a valid plan for %q with error %v", q, err)
+		}
+		if _, err := pln.Excecute(context.Background()); err == nil {
+			t.Errorf("planner.Excecute should have failed for %q", q)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// ParseRule returns the rule deriving triples into the provided graph
+// described by src. Rules are written as head :- body, where both the head and
+// the body are graph patterns enclosed in brackets, such as
+//
+//	{?a "skip_level"@[] ?c} :- {?a "manages"@[] ?b . ?b "manages"@[] ?c}
+//
+// The body is matched against the provided graph, and the head is
+// instantiated with the values bound by each of its matches. All the bindings
+// of the head must be bound by the body. The returned rule is not
+// materialized.
+func ParseRule(name, graph, src string) (*planner.Rule, error) {
+	spans, _ := lexer.Scan(src, lexer.Options{})
+	end := -1
+	for _, s := range spans {
+		if s.Type == lexer.ItemRBracket {
+			end = s.End
+			break
+		}
+	}
+	if end < 0 || !strings.HasPrefix(strings.TrimSpace(src[end:]), ":-") {
+		return nil, fmt.Errorf("bql.ParseRule: rule %q should be written as {head} :- {body}", src)
+	}
+	head, body := src[:end], strings.TrimPrefix(strings.TrimSpace(src[end:]), ":-")
+	from := graph
+	if !strings.HasPrefix(graph, "?") {
+		from = strconv.Quote(graph)
+	}
+	// The head is parsed as a graph pattern, whose bindings are the ones the
+	// body needs to select.
+	hs, err := parseRuleQuery(fmt.Sprintf("select ?_ from %s where %s;", from, head))
+	if err != nil {
+		return nil, fmt.Errorf("bql.ParseRule: failed to parse the head of rule %q with error %v", src, err)
+	}
+	bs := hs.Bindings()
+	if len(bs) == 0 {
+		return nil, fmt.Errorf("bql.ParseRule: the head of rule %q has no bindings", src)
+	}
+	sort.Strings(bs)
+	stm, err := parseRuleQuery(fmt.Sprintf("select %s from %s where %s;", strings.Join(bs, ", "), from, body))
+	if err != nil {
+		return nil, fmt.Errorf("bql.ParseRule: failed to parse the body of rule %q with error %v", src, err)
+	}
+	if err := stm.Validate(); err != nil {
+		return nil, fmt.Errorf("bql.ParseRule: invalid rule %q; %v", src, err)
+	}
+	return &planner.Rule{
+		Name:  name,
+		Graph: graph,
+		Head:  hs.OrderedGraphPatternClauses(),
+		Body:  stm,
+	}, nil
+}
+
+// parseRuleQuery parses the query used to describe part of a rule.
+func parseRuleQuery(q string) (*semantic.Statement, error) {
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		return nil, err
+	}
+	stm := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(q, p.LookAhead()), stm); err != nil {
+		return nil, err
+	}
+	return stm, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestParseRule(t *testing.T) {
+	s := memory.NewStore()
+	g, err := s.NewGraph("/org")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"/org\" with error %v", err)
+	}
+	b := bytes.NewBufferString("/u<ann>\t\"manages\"@[]\t/u<bob>\n/u<bob>\t\"manages\"@[]\t/u<cid>\n/u<bob>\t\"manages\"@[]\t/u<dan>\n")
+	if _, err := io.ReadIntoGraph(g, b, literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	src := `{?a "skip_level"@[] ?c} :- {?a "manages"@[] ?b . ?b "manages"@[] ?c}`
+	r, err := ParseRule("skip_level", "/org", src)
+	if err != nil {
+		t.Fatalf("bql.ParseRule(%q) failed with error %v", src, err)
+	}
+	rs := planner.NewRuleStore(s)
+	if err := rs.RegisterRule(context.Background(), r); err != nil {
+		t.Fatalf("planner.RuleStore.RegisterRule failed with error %v", err)
+	}
+	c, err := NewCache(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := `select ?c from "/org" where {/u<ann> "skip_level"@[] ?c};`
+	pln, err := c.Plan(rs, q)
+	if err != nil {
+		t.Fatalf("Cache.Plan(%q) failed with error %v", q, err)
+	}
+	tbl, err := pln.Excecute(context.Background())
+	if err != nil {
+		t.Fatalf("Excecute failed for query %q with error %v", q, err)
+	}
+	if got, want := tbl.NumRows(), 2; got != want {
+		t.Errorf("rule %q derived the wrong number of triples; got %d, want %d", src, got, want)
+	}
+}
+
+func TestParseRuleFails(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`{?a "skip_level"@[] ?c}`,
+		`{?a "skip_level"@[] ?c} {?a "manages"@[] ?b . ?b "manages"@[] ?c}`,
+		`{?a "skip_level"@[] ?c} :- {?a "manages"@[] ?b}`,
+		`{/u<ann> "skip_level"@[] /u<cid>} :- {?a "manages"@[] ?b}`,
+		`{?a "skip_level"@[] ?c} :- {?a "manages"@[] ?b . ?b "manages"@[]}`,
+	} {
+		if _, err := ParseRule("skip_level", "/org", src); err == nil {
+			t.Errorf("bql.ParseRule(%q) should have failed", src)
+		}
+	}
+}
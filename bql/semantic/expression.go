@@ -0,0 +1,746 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// Evaluator computes the boolean value of an expression for a given row.
+// Expressions involving null values follow three-valued logic; rows for which
+// an expression is unknown do not satisfy it.
+type Evaluator interface {
+	// Evaluate returns the value of the expression for the provided row.
+	Evaluate(r table.Row) (bool, error)
+}
+
+// truth is the value of an expression under three-valued logic.
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+// truthOf returns the truth value of the provided boolean.
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// evaluator computes the three-valued logic value of an expression.
+type evaluator interface {
+	evaluate(r table.Row) (truth, error)
+}
+
+// evaluateTruth returns the three-valued logic value of the provided
+// evaluator for the given row.
+func evaluateTruth(e Evaluator, r table.Row) (truth, error) {
+	if ev, ok := e.(evaluator); ok {
+		return ev.evaluate(r)
+	}
+	b, err := e.Evaluate(r)
+	return truthOf(b), err
+}
+
+// operand computes the cell value of an expression operand for a given row.
+type operand interface {
+	value(r table.Row) (*table.Cell, error)
+}
+
+// bindingOperand returns the value bound to a binding. Bindings not available
+// on the row are null.
+type bindingOperand string
+
+func (b bindingOperand) value(r table.Row) (*table.Cell, error) {
+	c, ok := r[string(b)]
+	if !ok || c.IsNull() {
+		return table.NullCell(), nil
+	}
+	return c, nil
+}
+
+// literalOperand returns a constant literal.
+type literalOperand struct {
+	c *table.Cell
+}
+
+func (l *literalOperand) value(table.Row) (*table.Cell, error) {
+	return l.c, nil
+}
+
+// castOperand converts the value of the wrapped operand to a literal type.
+type castOperand struct {
+	o operand
+	t literal.Type
+}
+
+func (c *castOperand) value(r table.Row) (*table.Cell, error) {
+	v, err := c.o.value(r)
+	if err != nil {
+		return nil, err
+	}
+	if v.IsNull() {
+		return v, nil
+	}
+	l, err := Cast(v, c.t)
+	if err != nil {
+		return nil, err
+	}
+	return &table.Cell{L: l}, nil
+}
+
+// strlenOperand returns the number of characters of the textual value of the
+// wrapped operand as an int64 literal.
+type strlenOperand struct {
+	o operand
+}
+
+func (s *strlenOperand) value(r table.Row) (*table.Cell, error) {
+	v, err := s.o.value(r)
+	if err != nil {
+		return nil, err
+	}
+	if v.IsNull() {
+		return v, nil
+	}
+	var txt string
+	switch {
+	case v.L != nil && v.L.Type() == literal.Text:
+		txt, err = v.L.Text()
+		if err != nil {
+			return nil, err
+		}
+	case v.S != "":
+		txt = v.S
+	default:
+		return nil, fmt.Errorf("cannot compute the length of non textual value %s", v)
+	}
+	l, err := literal.DefaultBuilder().Build(literal.Int64, int64(utf8.RuneCountInString(txt)))
+	if err != nil {
+		return nil, err
+	}
+	return &table.Cell{L: l}, nil
+}
+
+// arithmeticOperand combines the numeric values of two operands. Null values
+// result in null values.
+type arithmeticOperand struct {
+	op          literal.Operator
+	left, right operand
+}
+
+func (a *arithmeticOperand) value(r table.Row) (*table.Cell, error) {
+	l, err := a.left.value(r)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := a.right.value(r)
+	if err != nil {
+		return nil, err
+	}
+	if l.IsNull() || rv.IsNull() {
+		return table.NullCell(), nil
+	}
+	if l.L == nil || rv.L == nil {
+		return nil, fmt.Errorf("cannot compute %s %s %s of non numeric values", l, a.op, rv)
+	}
+	res, err := literal.Arithmetic(a.op, l.L, rv.L)
+	if err != nil {
+		return nil, err
+	}
+	return &table.Cell{L: res}, nil
+}
+
+// booleanEvaluator evaluates a single operand as a boolean value.
+type booleanEvaluator struct {
+	o operand
+}
+
+func (e *booleanEvaluator) Evaluate(r table.Row) (bool, error) {
+	v, err := e.evaluate(r)
+	return v == truthTrue, err
+}
+
+func (e *booleanEvaluator) evaluate(r table.Row) (truth, error) {
+	c, err := e.o.value(r)
+	if err != nil {
+		return truthFalse, err
+	}
+	if c.IsNull() {
+		return truthUnknown, nil
+	}
+	if c.L == nil || c.L.Type() != literal.Bool {
+		return truthFalse, fmt.Errorf("cannot use %s as a boolean value", c)
+	}
+	b, err := c.L.Bool()
+	return truthOf(b), err
+}
+
+// comparisonEvaluator compares two operands.
+type comparisonEvaluator struct {
+	op          lexer.TokenType
+	left, right operand
+}
+
+func (e *comparisonEvaluator) Evaluate(r table.Row) (bool, error) {
+	v, err := e.evaluate(r)
+	return v == truthTrue, err
+}
+
+func (e *comparisonEvaluator) evaluate(r table.Row) (truth, error) {
+	l, err := e.left.value(r)
+	if err != nil {
+		return truthFalse, err
+	}
+	rv, err := e.right.value(r)
+	if err != nil {
+		return truthFalse, err
+	}
+	if l.IsNull() || rv.IsNull() {
+		return truthUnknown, nil
+	}
+	cmp, err := CompareCells(l, rv)
+	if err != nil {
+		return truthFalse, err
+	}
+	switch e.op {
+	case lexer.ItemEQ:
+		return truthOf(cmp == 0), nil
+	case lexer.ItemLT:
+		return truthOf(cmp < 0), nil
+	case lexer.ItemGT:
+		return truthOf(cmp > 0), nil
+	}
+	return truthFalse, fmt.Errorf("unknown comparison operator %v", e.op)
+}
+
+// booleanOpEvaluator combines evaluators using and, or, and not.
+type booleanOpEvaluator struct {
+	op          lexer.TokenType
+	left, right Evaluator
+}
+
+func (e *booleanOpEvaluator) Evaluate(r table.Row) (bool, error) {
+	v, err := e.evaluate(r)
+	return v == truthTrue, err
+}
+
+// evaluate combines the values of the operands using Kleene's three-valued
+// logic: not unknown is unknown, false and unknown is false, and true or
+// unknown is true.
+func (e *booleanOpEvaluator) evaluate(r table.Row) (truth, error) {
+	l, err := evaluateTruth(e.left, r)
+	if err != nil {
+		return truthFalse, err
+	}
+	switch e.op {
+	case lexer.ItemNot:
+		switch l {
+		case truthTrue:
+			return truthFalse, nil
+		case truthFalse:
+			return truthTrue, nil
+		}
+		return truthUnknown, nil
+	case lexer.ItemAnd:
+		if l == truthFalse {
+			return truthFalse, nil
+		}
+	case lexer.ItemOr:
+		if l == truthTrue {
+			return truthTrue, nil
+		}
+	default:
+		return truthFalse, fmt.Errorf("unknown boolean operator %v", e.op)
+	}
+	rv, err := evaluateTruth(e.right, r)
+	if err != nil {
+		return truthFalse, err
+	}
+	if rv == l {
+		return l, nil
+	}
+	switch e.op {
+	case lexer.ItemAnd:
+		if rv == truthFalse {
+			return truthFalse, nil
+		}
+	case lexer.ItemOr:
+		if rv == truthTrue {
+			return truthTrue, nil
+		}
+	}
+	return truthUnknown, nil
+}
+
+// expressionParser builds evaluators out of a list of consumed tokens using
+// the usual precedence rules: multiplications bind tighter than additions and
+// subtractions, which bind tighter than comparisons, which bind tighter than
+// not, which binds tighter than and, which binds tighter than or.
+type expressionParser struct {
+	tkns []*lexer.Token
+	pos  int
+}
+
+func (p *expressionParser) peek() lexer.TokenType {
+	if p.pos >= len(p.tkns) {
+		return lexer.ItemEOF
+	}
+	return p.tkns[p.pos].Type
+}
+
+func (p *expressionParser) expect(tt lexer.TokenType) (*lexer.Token, error) {
+	if p.peek() != tt {
+		return nil, fmt.Errorf("expected %v in expression, got %v instead", tt, p.peek())
+	}
+	p.pos++
+	return p.tkns[p.pos-1], nil
+}
+
+func (p *expressionParser) parseOr() (Evaluator, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == lexer.ItemOr {
+		p.pos++
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &booleanOpEvaluator{op: lexer.ItemOr, left: l, right: r}
+	}
+	return l, nil
+}
+
+func (p *expressionParser) parseAnd() (Evaluator, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == lexer.ItemAnd {
+		p.pos++
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &booleanOpEvaluator{op: lexer.ItemAnd, left: l, right: r}
+	}
+	return l, nil
+}
+
+func (p *expressionParser) parseUnary() (Evaluator, error) {
+	if p.peek() == lexer.ItemNot {
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &booleanOpEvaluator{op: lexer.ItemNot, left: e}, nil
+	}
+	if p.peek() == lexer.ItemLPar {
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(lexer.ItemRPar); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	l, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case lexer.ItemEQ, lexer.ItemLT, lexer.ItemGT:
+		p.pos++
+		r, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &comparisonEvaluator{op: op, left: l, right: r}, nil
+	}
+	return &booleanEvaluator{o: l}, nil
+}
+
+// arithmeticOperators maps the tokens of arithmetic operations to the literal
+// operators computing them.
+var arithmeticOperators = map[lexer.TokenType]literal.Operator{
+	lexer.ItemPlus:  literal.Add,
+	lexer.ItemMinus: literal.Subtract,
+	lexer.ItemStar:  literal.Multiply,
+}
+
+func (p *expressionParser) parseOperand() (operand, error) {
+	l, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == lexer.ItemPlus || op == lexer.ItemMinus; op = p.peek() {
+		p.pos++
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l = &arithmeticOperand{op: arithmeticOperators[op], left: l, right: r}
+	}
+	return l, nil
+}
+
+func (p *expressionParser) parseProduct() (operand, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == lexer.ItemStar {
+		p.pos++
+		r, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		l = &arithmeticOperand{op: literal.Multiply, left: l, right: r}
+	}
+	return l, nil
+}
+
+func (p *expressionParser) parsePrimary() (operand, error) {
+	switch p.peek() {
+	case lexer.ItemBinding:
+		p.pos++
+		return bindingOperand(p.tkns[p.pos-1].Text), nil
+	case lexer.ItemLiteral:
+		p.pos++
+		l, err := literal.DefaultBuilder().Parse(p.tkns[p.pos-1].Text)
+		if err != nil {
+			return nil, err
+		}
+		return &literalOperand{c: &table.Cell{L: l}}, nil
+	case lexer.ItemCast:
+		p.pos++
+		if _, err := p.expect(lexer.ItemLPar); err != nil {
+			return nil, err
+		}
+		o, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(lexer.ItemAs); err != nil {
+			return nil, err
+		}
+		tkn, err := p.expect(lexer.ItemLiteralType)
+		if err != nil {
+			return nil, err
+		}
+		t, err := literalTypeFromName(tkn.Text)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(lexer.ItemRPar); err != nil {
+			return nil, err
+		}
+		return &castOperand{o: o, t: t}, nil
+	case lexer.ItemStrLen:
+		p.pos++
+		if _, err := p.expect(lexer.ItemLPar); err != nil {
+			return nil, err
+		}
+		o, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(lexer.ItemRPar); err != nil {
+			return nil, err
+		}
+		return &strlenOperand{o: o}, nil
+	}
+	return nil, fmt.Errorf("expected a binding, literal, cast, or strlen in expression, got %v instead", p.peek())
+}
+
+// NewEvaluator builds an evaluator out of the provided consumed elements.
+// Symbols are ignored.
+func NewEvaluator(ces []ConsumedElement) (Evaluator, error) {
+	p := &expressionParser{}
+	for _, ce := range ces {
+		if !ce.IsSymbol() {
+			p.tkns = append(p.tkns, ce.Token())
+		}
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("semantic.NewEvaluator: %v", err)
+	}
+	if p.pos != len(p.tkns) {
+		return nil, fmt.Errorf("semantic.NewEvaluator: unexpected token %v at the end of expression", p.tkns[p.pos])
+	}
+	return fold(e), nil
+}
+
+// constantEvaluator always evaluates to the same value.
+type constantEvaluator bool
+
+func (c constantEvaluator) Evaluate(table.Row) (bool, error) {
+	return bool(c), nil
+}
+
+// ConstantValue returns the value of the evaluator and true if it does not
+// depend on the values of the rows it evaluates.
+func ConstantValue(e Evaluator) (bool, bool) {
+	c, ok := e.(constantEvaluator)
+	return bool(c), ok
+}
+
+// constantOperand returns true if the value of the operand does not depend on
+// the values of the row.
+func constantOperand(o operand) bool {
+	switch v := o.(type) {
+	case *literalOperand:
+		return true
+	case *castOperand:
+		return constantOperand(v.o)
+	case *strlenOperand:
+		return constantOperand(v.o)
+	case *arithmeticOperand:
+		return constantOperand(v.left) && constantOperand(v.right)
+	}
+	return false
+}
+
+// fold replaces the parts of the expression that do not depend on the values
+// of the rows by their value. Expressions that fail to evaluate are kept so
+// their errors are reported when the rows are evaluated. Boolean operators
+// only skip their right hand side when the left one decides the value, as they
+// do when evaluating rows.
+func fold(e Evaluator) Evaluator {
+	switch v := e.(type) {
+	case *booleanEvaluator:
+		if constantOperand(v.o) {
+			if b, err := v.Evaluate(nil); err == nil {
+				return constantEvaluator(b)
+			}
+		}
+	case *comparisonEvaluator:
+		if constantOperand(v.left) && constantOperand(v.right) {
+			if b, err := v.Evaluate(nil); err == nil {
+				return constantEvaluator(b)
+			}
+		}
+	case *booleanOpEvaluator:
+		l := fold(v.left)
+		var r Evaluator
+		if v.right != nil {
+			r = fold(v.right)
+		}
+		lc, lok := ConstantValue(l)
+		switch {
+		case v.op == lexer.ItemNot && lok:
+			return constantEvaluator(!lc)
+		case v.op == lexer.ItemAnd && lok:
+			if !lc {
+				return constantEvaluator(false)
+			}
+			return r
+		case v.op == lexer.ItemOr && lok:
+			if lc {
+				return constantEvaluator(true)
+			}
+			return r
+		}
+		return &booleanOpEvaluator{op: v.op, left: l, right: r}
+	}
+	return e
+}
+
+// NewValuer builds a function that computes the value of a single operand,
+// such as strlen(?name), for a given row. Symbols are ignored.
+func NewValuer(ces []ConsumedElement) (func(table.Row) (*table.Cell, error), error) {
+	p := &expressionParser{}
+	for _, ce := range ces {
+		if !ce.IsSymbol() {
+			p.tkns = append(p.tkns, ce.Token())
+		}
+	}
+	o, err := p.parseOperand()
+	if err != nil {
+		return nil, fmt.Errorf("semantic.NewValuer: %v", err)
+	}
+	if p.pos != len(p.tkns) {
+		return nil, fmt.Errorf("semantic.NewValuer: unexpected token %v at the end of expression", p.tkns[p.pos])
+	}
+	return o.value, nil
+}
+
+// literalTypeFromName returns the literal type for names such as type:int64.
+func literalTypeFromName(n string) (literal.Type, error) {
+	if t, ok := literal.TypeByName(strings.TrimPrefix(strings.ToLower(n), "type:")); ok {
+		return t, nil
+	}
+	return literal.Bool, fmt.Errorf("unknown literal type %q", n)
+}
+
+// Cast converts the value of the provided cell into a literal of the requested
+// type. Numeric values can be converted between numeric types, and textual
+// values are parsed into the requested type, including registered ones.
+func Cast(c *table.Cell, t literal.Type) (*literal.Literal, error) {
+	b := literal.DefaultBuilder()
+	var v interface{}
+	switch {
+	case c.L != nil:
+		v = c.L.Interface()
+		if c.L.Type().Registered() {
+			// Values of registered types are converted using their lexical form.
+			ls := c.L.String()
+			v = ls[1:strings.LastIndex(ls, `"^^type:`)]
+		}
+	case c.S != "":
+		v = c.S
+	default:
+		v = c.String()
+	}
+	switch t {
+	case literal.Text:
+		switch tv := v.(type) {
+		case string:
+			return b.Build(literal.Text, tv)
+		case []byte:
+			return b.Build(literal.Text, string(tv))
+		}
+		return b.Build(literal.Text, fmt.Sprint(v))
+	case literal.Int64:
+		switch tv := v.(type) {
+		case int64:
+			return b.Build(literal.Int64, tv)
+		case float64:
+			return b.Build(literal.Int64, int64(tv))
+		case bool:
+			if tv {
+				return b.Build(literal.Int64, int64(1))
+			}
+			return b.Build(literal.Int64, int64(0))
+		case string:
+			i, err := strconv.ParseInt(strings.TrimSpace(tv), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot cast %q to %v", tv, t)
+			}
+			return b.Build(literal.Int64, i)
+		}
+	case literal.Float64:
+		switch tv := v.(type) {
+		case int64:
+			return b.Build(literal.Float64, float64(tv))
+		case float64:
+			return b.Build(literal.Float64, tv)
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(tv), 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot cast %q to %v", tv, t)
+			}
+			return b.Build(literal.Float64, f)
+		}
+	case literal.Bool:
+		switch tv := v.(type) {
+		case bool:
+			return b.Build(literal.Bool, tv)
+		case int64:
+			return b.Build(literal.Bool, tv != 0)
+		case string:
+			bv, err := strconv.ParseBool(strings.TrimSpace(tv))
+			if err != nil {
+				return nil, fmt.Errorf("cannot cast %q to %v", tv, t)
+			}
+			return b.Build(literal.Bool, bv)
+		}
+	case literal.Blob:
+		switch tv := v.(type) {
+		case []byte:
+			return b.Build(literal.Blob, tv)
+		case string:
+			return b.Build(literal.Blob, []byte(tv))
+		}
+	case literal.Float32:
+		switch tv := v.(type) {
+		case int64:
+			return b.Build(literal.Float32, float32(tv))
+		case float32:
+			return b.Build(literal.Float32, tv)
+		case float64:
+			return b.Build(literal.Float32, float32(tv))
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(tv), 32)
+			if err != nil {
+				return nil, fmt.Errorf("cannot cast %q to %v", tv, t)
+			}
+			return b.Build(literal.Float32, float32(f))
+		}
+	case literal.Decimal:
+		switch tv := v.(type) {
+		case int64:
+			return b.Build(literal.Decimal, new(big.Rat).SetInt64(tv))
+		case *big.Rat:
+			return b.Build(literal.Decimal, tv)
+		}
+		if l, err := b.Parse(fmt.Sprintf("%q^^type:decimal", strings.TrimSpace(fmt.Sprint(v)))); err == nil {
+			return l, nil
+		}
+	default:
+		// Durations, dates, and registered types are parsed from their textual
+		// representation.
+		if l, err := b.Parse(fmt.Sprintf("%q^^type:%v", fmt.Sprint(v), t)); err == nil && l != nil {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot cast %s to %v", c, t)
+}
+
+// CompareCells returns -1, 0, or 1 if the first cell is smaller, equal, or
+// bigger than the second one. Numeric literals are compared by value regardless
+// of their type. Cells holding values of incompatible
+// types cannot be compared and return an error.
+func CompareCells(c1, c2 *table.Cell) (int, error) {
+	switch {
+	case c1.L != nil && c2.L != nil:
+		return literal.Compare(c1.L, c2.L)
+	case c1.T != nil && c2.T != nil:
+		switch {
+		case c1.T.Before(*c2.T):
+			return -1, nil
+		case c1.T.After(*c2.T):
+			return 1, nil
+		}
+		return 0, nil
+	case c1.S != "" && c2.S != "",
+		c1.N != nil && c2.N != nil,
+		c1.P != nil && c2.P != nil:
+		return strings.Compare(c1.String(), c2.String()), nil
+	case c1.S != "" && c2.L != nil && c2.L.Type() == literal.Text:
+		return strings.Compare(c1.S, c2.L.Interface().(string)), nil
+	case c2.S != "" && c1.L != nil && c1.L.Type() == literal.Text:
+		return strings.Compare(c1.L.Interface().(string), c2.S), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s and %s", c1, c2)
+}
@@ -0,0 +1,320 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func testConsumedElements(t *testing.T, s string) []ConsumedElement {
+	var ces []ConsumedElement
+	for tkn := range lexer.New(s, 0) {
+		if tkn.Type == lexer.ItemError {
+			t.Fatalf("lexer.New failed to tokenize %q with error %v", s, tkn.ErrorMessage)
+		}
+		if tkn.Type == lexer.ItemEOF {
+			break
+		}
+		tt := tkn
+		ces = append(ces, NewConsumedToken(&tt))
+	}
+	return ces
+}
+
+func TestEvaluator(t *testing.T) {
+	b := literal.DefaultBuilder()
+	i, err := b.Parse(`"5"^^type:int64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := b.Parse(`"2.5"^^type:float64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := b.Parse(`"true"^^type:bool`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := table.Row{
+		"?i": &table.Cell{L: i},
+		"?f": &table.Cell{L: f},
+		"?b": &table.Cell{L: tr},
+		"?s": &table.Cell{S: "7"},
+		"?n": &table.Cell{S: "héllo"},
+	}
+	testTable := []struct {
+		expr string
+		want bool
+	}{
+		{`?i = "5"^^type:int64`, true},
+		{`?i = "5.0"^^type:float64`, true},
+		{`?i > ?f`, true},
+		{`?f < "3"^^type:int64`, true},
+		{`not ?i < ?f`, true},
+		{`?b`, true},
+		{`not ?b or ?i > "4.9"^^type:float64`, true},
+		{`?b and ?i < "5"^^type:int64`, false},
+		{`(?b and ?i = ?i) or ?f > ?i`, true},
+		{`cast(?f as type:int64) = "2"^^type:int64`, true},
+		{`cast(?i as type:float64) > "4.99"^^type:float64`, true},
+		{`cast(?s as type:int64) > ?i`, true},
+		{`cast(?i as type:text) = "5"^^type:text`, true},
+		{`CAST(?i AS type:bool)`, true},
+		{`strlen(?n) = "5"^^type:int64`, true},
+		{`strlen(cast(?i as type:text)) < "2"^^type:int64`, true},
+		{`?i < "5.1"^^type:decimal`, true},
+		{`cast(?f as type:decimal) = "2.5"^^type:decimal`, true},
+		{`cast(?i as type:float32) = "5"^^type:float32`, true},
+		{`cast("2015-07-19"^^type:text as type:date) < "2015-07-20"^^type:date`, true},
+		{`?i + "1"^^type:int64 = "6"^^type:int64`, true},
+		{`?i * ?f = "12.5"^^type:float64`, true},
+		{`?i - "2"^^type:int64 * "2"^^type:int64 = "1"^^type:int64`, true},
+		{`?f * "2"^^type:int64 - ?i < "0.1"^^type:decimal`, true},
+		{`not ?i - ?f > "2.5"^^type:float64`, true},
+	}
+	for _, entry := range testTable {
+		e, err := NewEvaluator(testConsumedElements(t, entry.expr))
+		if err != nil {
+			t.Errorf("semantic.NewEvaluator failed to compile %q with error %v", entry.expr, err)
+			continue
+		}
+		got, err := e.Evaluate(r)
+		if err != nil {
+			t.Errorf("Evaluate failed for %q with error %v", entry.expr, err)
+			continue
+		}
+		if got != entry.want {
+			t.Errorf("Evaluate returned the wrong value for %q; got %v, want %v", entry.expr, got, entry.want)
+		}
+	}
+}
+
+func TestEvaluatorCustomLiterals(t *testing.T) {
+	if _, err := literal.Register(literal.TypeDefinition{
+		Name:   "version",
+		Parse:  func(s string) (interface{}, error) { return strconv.Atoi(strings.TrimPrefix(s, "v")) },
+		Format: func(v interface{}) string { return fmt.Sprintf("v%d", v) },
+		Compare: func(a, b interface{}) int {
+			return a.(int) - b.(int)
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	v, err := literal.DefaultBuilder().Parse(`"v3"^^type:version`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := table.Row{
+		"?v": &table.Cell{L: v},
+		"?s": &table.Cell{S: "v2"},
+	}
+	testTable := []struct {
+		expr string
+		want bool
+	}{
+		{`?v = "v3"^^type:version`, true},
+		{`?v > "v10"^^type:version`, false},
+		{`cast(?s as type:version) < ?v`, true},
+		{`cast(?v as type:text) = "v3"^^type:text`, true},
+	}
+	for _, entry := range testTable {
+		e, err := NewEvaluator(testConsumedElements(t, entry.expr))
+		if err != nil {
+			t.Errorf("semantic.NewEvaluator failed to compile %q with error %v", entry.expr, err)
+			continue
+		}
+		got, err := e.Evaluate(r)
+		if err != nil {
+			t.Errorf("Evaluate failed for %q with error %v", entry.expr, err)
+			continue
+		}
+		if got != entry.want {
+			t.Errorf("Evaluate returned the wrong value for %q; got %v, want %v", entry.expr, got, entry.want)
+		}
+	}
+}
+
+func TestEvaluatorErrors(t *testing.T) {
+	b := literal.DefaultBuilder()
+	i, err := b.Parse(`"5"^^type:int64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := table.Row{"?i": &table.Cell{L: i}}
+	// Reject malformed expressions.
+	for _, expr := range []string{
+		`?i =`,
+		`?i ?i`,
+		`(?i`,
+		`cast(?i type:int64)`,
+		`cast(?i as ?i)`,
+	} {
+		if _, err := NewEvaluator(testConsumedElements(t, expr)); err == nil {
+			t.Errorf("semantic.NewEvaluator should have failed to compile %q", expr)
+		}
+	}
+	// Reject expressions that cannot be evaluated.
+	for _, expr := range []string{
+		`?i`,
+		`?i < "foo"^^type:text`,
+		`cast(?i as type:blob) = ?i`,
+		`strlen(?i) > "1"^^type:int64`,
+		`?i + "1"^^type:text > ?i`,
+		`?i * "9223372036854775807"^^type:int64 > ?i`,
+	} {
+		e, err := NewEvaluator(testConsumedElements(t, expr))
+		if err != nil {
+			t.Errorf("semantic.NewEvaluator failed to compile %q with error %v", expr, err)
+			continue
+		}
+		if got, err := e.Evaluate(r); err == nil {
+			t.Errorf("Evaluate should have failed for %q; got %v instead", expr, got)
+		}
+	}
+}
+
+func TestEvaluatorNulls(t *testing.T) {
+	b := literal.DefaultBuilder()
+	i, err := b.Parse(`"5"^^type:int64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := b.Parse(`"true"^^type:bool`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := table.Row{
+		"?i":    &table.Cell{L: i},
+		"?b":    &table.Cell{L: tr},
+		"?null": table.NullCell(),
+	}
+	testTable := []struct {
+		expr string
+		want bool
+	}{
+		{`?null = ?i`, false},
+		{`not ?null = ?i`, false},
+		{`?missing < ?i`, false},
+		{`not ?missing > ?i`, false},
+		{`?null`, false},
+		{`not ?null`, false},
+		{`?null = ?null`, false},
+		{`cast(?null as type:int64) = ?i`, false},
+		{`strlen(?null) > "1"^^type:int64`, false},
+		{`?null + ?i < ?i`, false},
+		{`?null = ?i or ?b`, true},
+		{`?b or ?null = ?i`, true},
+		{`?null = ?i and not ?b`, false},
+		{`not (?null = ?i and not ?b)`, true},
+		{`not (?null = ?i and ?b)`, false},
+		{`not (?null = ?i or not ?b)`, false},
+	}
+	for _, entry := range testTable {
+		e, err := NewEvaluator(testConsumedElements(t, entry.expr))
+		if err != nil {
+			t.Errorf("semantic.NewEvaluator failed to compile %q with error %v", entry.expr, err)
+			continue
+		}
+		got, err := e.Evaluate(r)
+		if err != nil {
+			t.Errorf("Evaluate failed for %q with error %v", entry.expr, err)
+			continue
+		}
+		if got != entry.want {
+			t.Errorf("Evaluate returned the wrong value for %q; got %v, want %v", entry.expr, got, entry.want)
+		}
+	}
+}
+
+func TestEvaluatorConstantFolding(t *testing.T) {
+	testTable := []struct {
+		expr     string
+		constant bool
+		want     bool
+	}{
+		{expr: `"1"^^type:int64 > "2"^^type:int64`, constant: true, want: false},
+		{expr: `"true"^^type:bool`, constant: true, want: true},
+		{expr: `not "1"^^type:int64 = "1"^^type:int64`, constant: true, want: false},
+		{expr: `"1"^^type:int64 > "2"^^type:int64 and ?i > "1"^^type:int64`, constant: true, want: false},
+		{expr: `"1"^^type:int64 < "2"^^type:int64 or ?i > "1"^^type:int64`, constant: true, want: true},
+		{expr: `strlen("abc"^^type:text) = "3"^^type:int64`, constant: true, want: true},
+		{expr: `cast("2.5"^^type:float64 as type:int64) = "2"^^type:int64`, constant: true, want: true},
+		{expr: `"2"^^type:int64 * "3"^^type:int64 = "6"^^type:int64`, constant: true, want: true},
+		// The left hand side is evaluated first and may fail, so the expression
+		// cannot be folded.
+		{expr: `?i > "1"^^type:int64 and "1"^^type:int64 > "2"^^type:int64`},
+		// Failing expressions are kept to report their errors.
+		{expr: `"1"^^type:int64 < "foo"^^type:text`},
+		{expr: `?i = ?i`},
+	}
+	for _, entry := range testTable {
+		e, err := NewEvaluator(testConsumedElements(t, entry.expr))
+		if err != nil {
+			t.Errorf("semantic.NewEvaluator failed to compile %q with error %v", entry.expr, err)
+			continue
+		}
+		got, ok := ConstantValue(e)
+		if ok != entry.constant {
+			t.Errorf("semantic.ConstantValue(%q) returned constant %v; want %v", entry.expr, ok, entry.constant)
+			continue
+		}
+		if ok && got != entry.want {
+			t.Errorf("semantic.ConstantValue(%q) returned %v; want %v", entry.expr, got, entry.want)
+		}
+	}
+}
+
+func TestValuer(t *testing.T) {
+	r := table.Row{"?n": &table.Cell{S: "héllo"}}
+	testTable := []struct {
+		expr string
+		want string
+	}{
+		{`?n`, "héllo"},
+		{`strlen(?n)`, `"5"^^type:int64`},
+		{`cast(strlen(?n) as type:text)`, `"5"^^type:text`},
+	}
+	for _, entry := range testTable {
+		v, err := NewValuer(testConsumedElements(t, entry.expr))
+		if err != nil {
+			t.Errorf("semantic.NewValuer failed to compile %q with error %v", entry.expr, err)
+			continue
+		}
+		got, err := v(r)
+		if err != nil {
+			t.Errorf("semantic.NewValuer returned a function that failed for %q with error %v", entry.expr, err)
+			continue
+		}
+		if got.String() != entry.want {
+			t.Errorf("semantic.NewValuer computed the wrong value for %q; got %v, want %v", entry.expr, got, entry.want)
+		}
+	}
+	for _, expr := range []string{
+		`strlen(?n`,
+		`?n ?n`,
+		`?n = ?n`,
+	} {
+		if _, err := NewValuer(testConsumedElements(t, expr)); err == nil {
+			t.Errorf("semantic.NewValuer should have failed to compile %q", expr)
+		}
+	}
+}
@@ -17,10 +17,12 @@ package semantic
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/table"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
@@ -46,21 +48,74 @@ func TypeBindingClauseHook(t StatementType) ClauseHook {
 }
 
 // dataAccumulator creates a element hook that tracks fully formed triples and
-// adds them to the Statement when fully formed.
+// adds them to the Statement when fully formed. Embedded triples are tracked
+// using a stack of the subjects and predicates of the enclosing triples.
 func dataAccumulator(b literal.Builder) ElementHook {
+	type frame struct {
+		s *node.Node
+		p *predicate.Predicate
+	}
 	var (
-		hook ElementHook
-		s    *node.Node
-		p    *predicate.Predicate
-		o    *triple.Object
+		hook     ElementHook
+		s        *node.Node
+		p        *predicate.Predicate
+		embedded *triple.Triple
+		stack    []frame
 	)
 
+	// flush uses the provided object to complete the current triple.
+	flush := func(st *Statement, o *triple.Object) error {
+		if ta := st.DefaultTimeAnchor(); ta != nil && p.Type() == predicate.Immutable {
+			tp, err := predicate.NewTemporal(string(p.ID()), *ta)
+			if err != nil {
+				return err
+			}
+			p = tp
+		}
+		trpl, err := triple.New(s, p, o)
+		if err != nil {
+			return err
+		}
+		s, p = nil, nil
+		if len(stack) == 0 {
+			st.AddData(trpl)
+			return nil
+		}
+		embedded = trpl
+		return nil
+	}
+
 	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
 		if ce.IsSymbol() {
 			return hook, nil
 		}
 		tkn := ce.Token()
-		if tkn.Type != lexer.ItemNode && tkn.Type != lexer.ItemPredicate && tkn.Type != lexer.ItemLiteral {
+		switch tkn.Type {
+		case lexer.ItemInsert, lexer.ItemDelete:
+			// Reset the state left by previous statements that failed.
+			s, p, embedded, stack = nil, nil, nil, nil
+			return hook, nil
+		case lexer.ItemLTriple:
+			if s == nil || p == nil {
+				return nil, fmt.Errorf("hook.DataAccumulator requires embedded triples to be used as objects, got %v instead", tkn)
+			}
+			stack = append(stack, frame{s, p})
+			s, p, embedded = nil, nil, nil
+			return hook, nil
+		case lexer.ItemRTriple:
+			if len(stack) == 0 || embedded == nil {
+				return nil, fmt.Errorf("hook.DataAccumulator found an incomplete embedded triple")
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			o := triple.NewTripleObject(embedded)
+			s, p, embedded = f.s, f.p, nil
+			if err := flush(st, o); err != nil {
+				return nil, err
+			}
+			return hook, nil
+		case lexer.ItemNode, lexer.ItemPredicate, lexer.ItemPredicateBound, lexer.ItemLiteral:
+		default:
 			return hook, nil
 		}
 		if s == nil {
@@ -75,7 +130,7 @@ func dataAccumulator(b literal.Builder) ElementHook {
 			return hook, nil
 		}
 		if p == nil {
-			if tkn.Type != lexer.ItemPredicate {
+			if tkn.Type != lexer.ItemPredicate && tkn.Type != lexer.ItemPredicateBound {
 				return nil, fmt.Errorf("hook.DataAccumulator requires a predicate to create a predicate, got %v instead", tkn)
 			}
 			tmp, err := predicate.Parse(tkn.Text)
@@ -85,21 +140,14 @@ func dataAccumulator(b literal.Builder) ElementHook {
 			p = tmp
 			return hook, nil
 		}
-		if o == nil {
-			tmp, err := triple.ParseObject(tkn.Text, b)
-			if err != nil {
-				return nil, err
-			}
-			o = tmp
-			trpl, err := triple.New(s, p, o)
-			if err != nil {
-				return nil, err
-			}
-			st.AddData(trpl)
-			s, p, o = nil, nil, nil
-			return hook, nil
+		o, err := triple.ParseObject(tkn.Text, b)
+		if err != nil {
+			return nil, err
+		}
+		if err := flush(st, o); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("hook.DataAccumulator has failed to flush the triple %s, %s, %s", s, p, o)
+		return hook, nil
 	}
 	return hook
 }
@@ -124,6 +172,12 @@ var (
 	// wich contains the initial reset of the working clause hook for where clauses.
 	wich ClauseHook
 
+	// wmch contains the minus pattern hook for where clauses.
+	wmch ClauseHook
+
+	// wgch contains the graph scope hook for where clauses.
+	wgch ElementHook
+
 	// wsch contains the where clause subject hook.
 	wsch ElementHook
 
@@ -132,6 +186,57 @@ var (
 
 	// woch contains the where clause subject hook.
 	woch ElementHook
+
+	// weth contains the where clause embedded triple hook.
+	weth ElementHook
+
+	// gteh contains the global time bound element hook.
+	gteh ElementHook
+
+	// gtch contains the global time bound anchor clause hook.
+	gtch ClauseHook
+
+	// vach contains the select clause projection accumulator hook.
+	vach ElementHook
+
+	// gbch contains the group by bindings hook.
+	gbch ElementHook
+
+	// obch contains the order by bindings hook.
+	obch ElementHook
+
+	// obkh contains the order by key compilation clause hook.
+	obkh ClauseHook
+
+	// lmch contains the limit collection hook.
+	lmch ElementHook
+
+	// asoh contains the as of time collection hook.
+	asoh ElementHook
+
+	// grah contains the granularity collection hook.
+	grah ElementHook
+
+	// iach contains the insert default time anchor hook.
+	iach ElementHook
+
+	// hich contains the query hint collection hook.
+	hich ElementHook
+
+	// sdeh contains the select distinct hook.
+	sdeh ElementHook
+
+	// cveh contains the create view hook.
+	cveh ElementHook
+
+	// hveh contains the having expression element hook.
+	hveh ElementHook
+
+	// hvch contains the having expression compilation clause hook.
+	hvch ClauseHook
+
+	// prdh contains the prefix declaration hook.
+	prdh ElementHook
 )
 
 func init() {
@@ -139,9 +244,25 @@ func init() {
 	gach = graphAccumulator()
 	wnch = whereNextWorkingClause()
 	wich = whereInitWorkingClause()
+	wmch = whereMinusClause()
+	wgch = whereGraphClause()
 	wsch = whereSubjectClause()
 	wpch = wherePredicateClause()
 	woch = whereObjectClause()
+	weth = whereEmbeddedTripleClause()
+	gteh, gtch = globalTimeBound()
+	vach = varAccumulator()
+	gbch = groupByBindings()
+	obch, obkh = orderByBindings()
+	lmch = limitCollection()
+	asoh = asOf()
+	grah = granularity()
+	hich = hintCollection()
+	sdeh = selectDistinct()
+	cveh = createView()
+	iach = insertAnchor()
+	hveh, hvch = havingExpression()
+	prdh = prefixDeclaration()
 
 	predicateRegexp = regexp.MustCompile(`^"(.+)"@\["?([^\]"]*)"?\]$`)
 	boundRegexp = regexp.MustCompile(`^"(.+)"@\["?([^\]"]*)"?,"?([^\]"]*)"?\]$`)
@@ -167,6 +288,18 @@ func WhereNextWorkingClauseHook() ClauseHook {
 	return wnch
 }
 
+// WhereMinusClauseHook returns the singleton for starting minus graph
+// patterns.
+func WhereMinusClauseHook() ClauseHook {
+	return wmch
+}
+
+// WhereGraphClauseHook returns the singleton for scoping where clauses to a
+// graph.
+func WhereGraphClauseHook() ElementHook {
+	return wgch
+}
+
 // WhereSubjectClauseHook returnce the singleton for working clause hooks that
 // populates the subject.
 func WhereSubjectClauseHook() ElementHook {
@@ -185,6 +318,103 @@ func WhereObjectClauseHook() ElementHook {
 	return woch
 }
 
+// WhereEmbeddedTripleClauseHook returns the singleton for working clause hooks
+// that populates the embedded triple of the object.
+func WhereEmbeddedTripleClauseHook() ElementHook {
+	return weth
+}
+
+// GlobalTimeBoundHook returns the singleton for the element hook that collects
+// the global time bounds of a statement.
+func GlobalTimeBoundHook() ElementHook {
+	return gteh
+}
+
+// GlobalTimeBoundAnchorHook returns the singleton for the clause hook that
+// applies each completed global time bound anchor to the statement.
+func GlobalTimeBoundAnchorHook() ClauseHook {
+	return gtch
+}
+
+// VarAccumulatorHook returns the singleton for collecting the bindings listed
+// on the select clause.
+func VarAccumulatorHook() ElementHook {
+	return vach
+}
+
+// GroupByBindingsHook returns the singleton for collecting group by bindings.
+func GroupByBindingsHook() ElementHook {
+	return gbch
+}
+
+// OrderByBindingsHook returns the singleton for collecting order by bindings.
+func OrderByBindingsHook() ElementHook {
+	return obch
+}
+
+// OrderByKeyHook returns the singleton for compiling order by keys.
+func OrderByKeyHook() ClauseHook {
+	return obkh
+}
+
+// LimitCollectionHook returns the singleton for collecting the limit clause.
+func LimitCollectionHook() ElementHook {
+	return lmch
+}
+
+// AsOfHook returns the singleton for collecting the time the statement
+// queries the state of the graphs at.
+func AsOfHook() ElementHook {
+	return asoh
+}
+
+// GranularityHook returns the singleton for collecting the granularity time
+// anchors are matched at.
+func GranularityHook() ElementHook {
+	return grah
+}
+
+// InsertAnchorHook returns the singleton for collecting the default time
+// anchor of insert statements.
+func InsertAnchorHook() ElementHook {
+	return iach
+}
+
+// HintCollectionHook returns the singleton for collecting query hints.
+func HintCollectionHook() ElementHook {
+	return hich
+}
+
+// SelectDistinctHook returns the singleton for marking statements as only
+// returning distinct rows.
+func SelectDistinctHook() ElementHook {
+	return sdeh
+}
+
+// CreateViewHook returns the singleton for collecting the name of the view
+// defined by a create view statement.
+func CreateViewHook() ElementHook {
+	return cveh
+}
+
+// HavingExpressionHook returns the singleton for collecting the tokens of the
+// having clause.
+func HavingExpressionHook() ElementHook {
+	return hveh
+}
+
+// HavingEvaluatorHook returns the singleton for the clause hook that compiles
+// the collected having clause into an evaluator.
+func HavingEvaluatorHook() ClauseHook {
+	return hvch
+}
+
+// PrefixDeclarationHook returns the singleton for collecting the namespace
+// prefixes declared by a statement.
+func PrefixDeclarationHook() ElementHook {
+	return prdh
+}
+
 // graphAccumulator returns an element hook that keeps track of the graphs
 // listed in a statement.
 func graphAccumulator() ElementHook {
@@ -200,8 +430,18 @@ func graphAccumulator() ElementHook {
 		case lexer.ItemBinding:
 			st.AddGraph(strings.TrimSpace(tkn.Text))
 			return hook, nil
+		case lexer.ItemString:
+			g, err := strconv.Unquote(strings.TrimSpace(tkn.Text))
+			if err != nil {
+				return nil, fmt.Errorf("hook.GrapAccumulator failed to parse graph name %s with error %v", tkn.Text, err)
+			}
+			if g == "" {
+				return nil, fmt.Errorf("hook.GrapAccumulator requires graph names to not be empty")
+			}
+			st.AddGraph(g)
+			return hook, nil
 		default:
-			return nil, fmt.Errorf("hook.GrapAccumulator requires a binding to refer to a graph, got %v instead", tkn)
+			return nil, fmt.Errorf("hook.GrapAccumulator requires a binding or a quoted name to refer to a graph, got %v instead", tkn)
 		}
 	}
 	return hook
@@ -228,12 +468,48 @@ func whereInitWorkingClause() ClauseHook {
 	return f
 }
 
+// whereMinusClause flushes the current working clause and starts a new minus
+// graph pattern.
+func whereMinusClause() ClauseHook {
+	var f ClauseHook
+	f = func(stm *Statement, _ Symbol) (ClauseHook, error) {
+		stm.AddWorkingGrpahClause()
+		stm.AddMinusPattern()
+		return f, nil
+	}
+	return f
+}
+
+// whereGraphClause returns an element hook that scopes the graph clauses
+// enclosed in a graph block to the provided graph or graph binding.
+func whereGraphClause() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemBinding:
+			st.graphScopes = append(st.graphScopes, strings.TrimSpace(tkn.Text))
+		case lexer.ItemRBracket:
+			if len(st.graphScopes) == 0 {
+				return nil, fmt.Errorf("hook.WhereGraphClause found the end of a graph block without its beginning")
+			}
+			st.graphScopes = st.graphScopes[:len(st.graphScopes)-1]
+		}
+		return f, nil
+	}
+	return f
+}
+
 // whereSubjectClause returns an element hook that updates the subject
 // modifiers on the working graph clause.
 func whereSubjectClause() ElementHook {
 	var (
 		f            ElementHook
 		lastNopToken *lexer.Token
+		cls          *GraphClause
 	)
 	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
 		if ce.IsSymbol() {
@@ -241,6 +517,11 @@ func whereSubjectClause() ElementHook {
 		}
 		tkn := ce.Token()
 		c := st.WorkingClause()
+		if c != cls {
+			// Tokens left by a previous clause, such as the ones of a failed
+			// statement, do not apply to a new one.
+			cls, lastNopToken = c, nil
+		}
 		switch tkn.Type {
 		case lexer.ItemNode:
 			if c.S != nil {
@@ -253,6 +534,17 @@ func whereSubjectClause() ElementHook {
 			c.S = n
 			lastNopToken = nil
 			return f, nil
+		case lexer.ItemNodeType:
+			if c.SType != nil {
+				return nil, fmt.Errorf("UNDER type filter for subject has already being assined on %v", st)
+			}
+			t, err := node.NewType(tkn.Text)
+			if err != nil {
+				return nil, err
+			}
+			c.SType = t
+			lastNopToken = nil
+			return f, nil
 		case lexer.ItemBinding:
 			if lastNopToken == nil {
 				if c.SBinding != "" {
@@ -323,6 +615,19 @@ func processPredicate(c *GraphClause, ce ConsumedElement, lastNopToken *lexer.To
 	return nil, pID, pAnchorBinding, temporal, nil
 }
 
+// periodBounds returns the inclusive time bounds covered by the provided
+// predicate if it is anchored with reduced precision.
+func periodBounds(p *predicate.Predicate) (*time.Time, *time.Time, bool) {
+	if p == nil || p.Type() != predicate.Temporal || p.Granularity() == predicate.Instant {
+		return nil, nil, false
+	}
+	lb, ub, err := p.TimeSpan()
+	if err != nil {
+		return nil, nil, false
+	}
+	return &lb, &ub, true
+}
+
 // processPredicateBound updates the working graph clause if threre is an
 // available predcicate bound.
 func processPredicateBound(c *GraphClause, ce ConsumedElement, lastNopToken *lexer.Token) (string, string, string, *time.Time, *time.Time, bool, error) {
@@ -346,7 +651,7 @@ func processPredicateBound(c *GraphClause, ce ConsumedElement, lastNopToken *lex
 	} else {
 		stl := strings.TrimSpace(tl)
 		if stl != "" {
-			ptl, err := time.Parse(time.RFC3339Nano, stl)
+			ptl, _, err := predicate.ParseTimeAnchor(stl)
 			if err != nil {
 				return "", "", "", nil, nil, false, fmt.Errorf("predicate.Parse failed to parse time anchor %s in %s with error %v", tl, raw, err)
 			}
@@ -359,10 +664,12 @@ func processPredicateBound(c *GraphClause, ce ConsumedElement, lastNopToken *lex
 	} else {
 		stu := strings.TrimSpace(tu)
 		if stu != "" {
-			ptu, err := time.Parse(time.RFC3339Nano, stu)
+			ptu, g, err := predicate.ParseTimeAnchor(stu)
 			if err != nil {
 				return "", "", "", nil, nil, false, fmt.Errorf("predicate.Parse failed to parse time anchor %s in %s with error %v", tu, raw, err)
 			}
+			// Reduced-precision upper bounds include their whole period.
+			ptu = g.Last(ptu)
 			pUpperBound = &ptu
 		}
 	}
@@ -381,6 +688,7 @@ func wherePredicateClause() ElementHook {
 	var (
 		f            ElementHook
 		lastNopToken *lexer.Token
+		cls          *GraphClause
 	)
 	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
 		if ce.IsSymbol() {
@@ -388,6 +696,11 @@ func wherePredicateClause() ElementHook {
 		}
 		tkn := ce.Token()
 		c := st.WorkingClause()
+		if c != cls {
+			// Tokens left by a previous clause, such as the ones of a failed
+			// statement, do not apply to a new one.
+			cls, lastNopToken = c, nil
+		}
 		switch tkn.Type {
 		case lexer.ItemPredicate:
 			lastNopToken = nil
@@ -398,6 +711,11 @@ func wherePredicateClause() ElementHook {
 			if err != nil {
 				return nil, err
 			}
+			if lb, ub, ok := periodBounds(p); ok {
+				// Reduced-precision predicates match any anchor in their period.
+				c.PID, c.PLowerBound, c.PUpperBound, c.PTemporal = string(p.ID()), lb, ub, true
+				return f, nil
+			}
 			c.P, c.PID, c.PAnchorBinding, c.PTemporal = p, pID, pAnchorBinding, pTemporal
 			return f, nil
 		case lexer.ItemPredicateBound:
@@ -411,6 +729,18 @@ func wherePredicateClause() ElementHook {
 			}
 			c.PID, c.PLowerBoundAlias, c.PUpperBoundAlias, c.PLowerBound, c.PUpperBound, c.PTemporal = pID, pLowerBoundAlias, pUpperBoundAlias, pLowerBound, pUpperBound, pTemp
 			return f, nil
+		case lexer.ItemPlus, lexer.ItemStar:
+			if c.P == nil {
+				return nil, fmt.Errorf("path modifier %s requires a fully specified predicate on %v", tkn.Text, st)
+			}
+			c.PTransitive, c.PReflexive = true, tkn.Type == lexer.ItemStar
+			return f, nil
+		case lexer.ItemLatest:
+			if c.P != nil || c.PTransitive {
+				return nil, fmt.Errorf("latest modifier requires a predicate time bound or binding on %v", st)
+			}
+			c.PLatest = true
+			return f, nil
 		case lexer.ItemBinding:
 			if lastNopToken == nil {
 				if c.PBinding != "" {
@@ -436,7 +766,7 @@ func wherePredicateClause() ElementHook {
 				}
 				c.PAnchorAlias = tkn.Text
 			default:
-				return nil, fmt.Errorf("binding %q found after invalid token %s", tkn.Text, lastNopToken)
+				return nil, fmt.Errorf("binding %q found after invalid token %s", tkn.Text, lastNopToken.Type)
 			}
 			lastNopToken = nil
 			return f, nil
@@ -453,6 +783,7 @@ func whereObjectClause() ElementHook {
 	var (
 		f            ElementHook
 		lastNopToken *lexer.Token
+		cls          *GraphClause
 	)
 	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
 		if ce.IsSymbol() {
@@ -460,7 +791,34 @@ func whereObjectClause() ElementHook {
 		}
 		tkn := ce.Token()
 		c := st.WorkingClause()
+		if c != cls {
+			// Tokens left by a previous clause, such as the ones of a failed
+			// statement, do not apply to a new one.
+			cls, lastNopToken = c, nil
+		}
 		switch tkn.Type {
+		case lexer.ItemLTriple:
+			lastNopToken = nil
+			if c.O != nil || c.OEmbedded != nil {
+				return nil, fmt.Errorf("invalid embedded triple for object on graph clause since already set to %s", c.O)
+			}
+			c.OEmbedded = &GraphClause{}
+			return f, nil
+		case lexer.ItemRTriple:
+			lastNopToken = nil
+			e := c.OEmbedded
+			if e == nil {
+				return nil, fmt.Errorf("found end of embedded triple without its beginning on graph clause")
+			}
+			if e.S != nil && e.P != nil && e.O != nil {
+				// Fully specified embedded triples are just regular objects.
+				t, err := triple.New(e.S, e.P, e.O)
+				if err != nil {
+					return nil, err
+				}
+				c.O, c.OEmbedded = triple.NewTripleObject(t), nil
+			}
+			return f, nil
 		case lexer.ItemNode, lexer.ItemLiteral:
 			lastNopToken = nil
 			if c.O != nil {
@@ -485,6 +843,11 @@ func whereObjectClause() ElementHook {
 			if err != nil {
 				return nil, err
 			}
+			if lb, ub, ok := periodBounds(pred); ok {
+				// Reduced-precision predicates match any anchor in their period.
+				c.OID, c.OLowerBound, c.OUpperBound, c.OTemporal = string(pred.ID()), lb, ub, true
+				return f, nil
+			}
 			if pred != nil {
 				c.O = triple.NewPredicateObject(pred)
 			}
@@ -533,7 +896,7 @@ func whereObjectClause() ElementHook {
 				}
 				c.OAnchorAlias = tkn.Text
 			default:
-				return nil, fmt.Errorf("binding %q found after invalid token %s", tkn.Text, lastNopToken)
+				return nil, fmt.Errorf("binding %q found after invalid token %s", tkn.Text, lastNopToken.Type)
 			}
 			return f, nil
 		}
@@ -542,3 +905,528 @@ func whereObjectClause() ElementHook {
 	}
 	return f
 }
+
+// whereEmbeddedTripleClause returns an element hook that populates the
+// embedded triple pattern of the object of the working graph clause. Elements
+// are expected in subject, predicate, and object order.
+func whereEmbeddedTripleClause() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		e := st.WorkingClause().OEmbedded
+		if e == nil {
+			return nil, fmt.Errorf("hook.WhereEmbeddedTripleClause found %v outside an embedded triple", tkn)
+		}
+		hasS, hasP := e.S != nil || e.SBinding != "", e.P != nil || e.PBinding != ""
+		switch tkn.Type {
+		case lexer.ItemNode:
+			n, err := ToNode(ce)
+			if err != nil {
+				return nil, err
+			}
+			if !hasS {
+				e.S = n
+			} else {
+				e.O = triple.NewNodeObject(n)
+			}
+		case lexer.ItemPredicate:
+			p, err := ToPredicate(ce)
+			if err != nil {
+				return nil, err
+			}
+			if !hasP {
+				e.P = p
+			} else {
+				e.O = triple.NewPredicateObject(p)
+			}
+		case lexer.ItemLiteral:
+			l, err := ToLiteral(ce)
+			if err != nil {
+				return nil, err
+			}
+			e.O = triple.NewLiteralObject(l)
+		case lexer.ItemBinding:
+			switch {
+			case !hasS:
+				e.SBinding = tkn.Text
+			case !hasP:
+				e.PBinding = tkn.Text
+			default:
+				e.OBinding = tkn.Text
+			}
+		}
+		return f, nil
+	}
+	return f
+}
+
+// globalTimeBound returns the element hook and the anchor clause hook that
+// together collect the before, after, between, and at global time bounds of a
+// statement. An anchor is either a temporal predicate or the now keyword
+// optionally shifted by a duration literal. Reduced-precision anchors bound
+// the whole period they cover. Composed bounds are intersected.
+func globalTimeBound() (ElementHook, ClauseHook) {
+	var (
+		eh      ElementHook
+		ch      ClauseHook
+		op      lexer.TokenType
+		sign    lexer.TokenType
+		anchor  *time.Time
+		width   time.Duration
+		anchors []time.Time
+		lasts   []time.Time
+	)
+	eh = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return eh, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemBefore, lexer.ItemAfter, lexer.ItemBetween, lexer.ItemAt:
+			op, sign, anchor, anchors, lasts = tkn.Type, lexer.ItemError, nil, nil, nil
+		case lexer.ItemOr:
+			return nil, fmt.Errorf("hook.GlobalTimeBound does not support or composition of time bounds, got %v", tkn)
+		case lexer.ItemPredicate:
+			p, err := ToPredicate(ce)
+			if err != nil {
+				return nil, err
+			}
+			first, last, err := p.TimeSpan()
+			if err != nil {
+				return nil, fmt.Errorf("hook.GlobalTimeBound requires a temporal predicate, got %s instead", p)
+			}
+			anchor, width = &first, last.Sub(first)
+		case lexer.ItemNow:
+			n := time.Now()
+			anchor, width = &n, 0
+		case lexer.ItemPlus, lexer.ItemMinus:
+			sign = tkn.Type
+		case lexer.ItemLiteral:
+			l, err := ToLiteral(ce)
+			if err != nil {
+				return nil, err
+			}
+			d, err := l.Duration()
+			if err != nil {
+				return nil, fmt.Errorf("hook.GlobalTimeBound requires a duration literal to shift now, got %s instead", l)
+			}
+			if anchor == nil {
+				return nil, fmt.Errorf("hook.GlobalTimeBound found duration %s without a time anchor", l)
+			}
+			if sign == lexer.ItemMinus {
+				d = -d
+			}
+			t := anchor.Add(d)
+			anchor = &t
+		}
+		return eh, nil
+	}
+	ch = func(st *Statement, _ Symbol) (ClauseHook, error) {
+		if anchor == nil {
+			return nil, fmt.Errorf("hook.GlobalTimeBound missing time anchor for %v bound", op)
+		}
+		anchors, lasts = append(anchors, *anchor), append(lasts, anchor.Add(width))
+		anchor, width, sign = nil, 0, lexer.ItemError
+		switch {
+		case op == lexer.ItemBefore && len(anchors) == 1:
+			st.AddGlobalTimeBounds(nil, &lasts[0])
+		case op == lexer.ItemAfter && len(anchors) == 1:
+			st.AddGlobalTimeBounds(&anchors[0], nil)
+		case op == lexer.ItemAt && len(anchors) == 1:
+			st.AddGlobalTimeBounds(&anchors[0], &lasts[0])
+		case op == lexer.ItemBetween && len(anchors) == 2:
+			if anchors[1].Before(anchors[0]) {
+				return nil, fmt.Errorf("hook.GlobalTimeBound invalid between bounds; %v is after %v", anchors[0], anchors[1])
+			}
+			st.AddGlobalTimeBounds(&anchors[0], &lasts[1])
+		}
+		return ch, nil
+	}
+	return eh, ch
+}
+
+// varAccumulator returns an element hook that collects the bindings listed on
+// the select clause, their aliases, and how they are aggregated.
+func varAccumulator() ElementHook {
+	var (
+		f      ElementHook
+		lastAs bool
+	)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		var last *Projection
+		if n := len(st.projection); n > 0 {
+			last = st.projection[n-1]
+		}
+		switch tkn.Type {
+		case lexer.ItemCount:
+			st.projection = append(st.projection, &Projection{Aggregate: true, Kind: table.Count})
+		case lexer.ItemSum:
+			st.projection = append(st.projection, &Projection{Aggregate: true, Kind: table.Sum})
+		case lexer.ItemDistinct:
+			if last == nil || !last.Aggregate || last.Binding != "" {
+				return nil, fmt.Errorf("hook.VarAccumulator found distinct outside of an aggregation on %v", st)
+			}
+			last.Distinct = true
+		case lexer.ItemAs:
+			lastAs = true
+		case lexer.ItemBinding:
+			switch {
+			case lastAs:
+				if last == nil || last.Alias != "" {
+					return nil, fmt.Errorf("hook.VarAccumulator found alias %s with no binding to apply to on %v", tkn.Text, st)
+				}
+				last.Alias, lastAs = tkn.Text, false
+			case last != nil && last.Aggregate && last.Binding == "":
+				last.Binding = tkn.Text
+			default:
+				st.projection = append(st.projection, &Projection{Binding: tkn.Text})
+			}
+		}
+		return f, nil
+	}
+	return f
+}
+
+// groupByBindings returns an element hook that collects the bindings listed
+// on the group by clause.
+func groupByBindings() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		if tkn := ce.Token(); tkn.Type == lexer.ItemBinding {
+			st.groupBy = append(st.groupBy, tkn.Text)
+		}
+		return f, nil
+	}
+	return f
+}
+
+// orderByBindings returns an element hook that collects the sort keys and
+// their directions, and a clause hook that compiles each collected key into
+// a sort configuration.
+func orderByBindings() (ElementHook, ClauseHook) {
+	var (
+		eh ElementHook
+		ch ClauseHook
+	)
+	eh = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return eh, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemOrder, lexer.ItemBy, lexer.ItemComma:
+		case lexer.ItemAsc, lexer.ItemDesc:
+			if len(st.orderBy) == 0 {
+				return nil, fmt.Errorf("hook.OrderByBindings found direction %v without a binding", tkn)
+			}
+			st.orderBy[len(st.orderBy)-1].Desc = tkn.Type == lexer.ItemDesc
+		default:
+			st.orderByTokens = append(st.orderByTokens, ce)
+		}
+		return eh, nil
+	}
+	ch = func(st *Statement, _ Symbol) (ClauseHook, error) {
+		tkns := st.orderByTokens
+		st.orderByTokens = nil
+		if len(tkns) == 1 && tkns[0].Token().Type == lexer.ItemBinding {
+			st.orderBy = append(st.orderBy, table.SortConfig{Binding: tkns[0].Token().Text})
+			return ch, nil
+		}
+		v, err := NewValuer(tkns)
+		if err != nil {
+			return nil, fmt.Errorf("hook.OrderByKey failed to compile the order by key; %v", err)
+		}
+		st.orderBy = append(st.orderBy, table.SortConfig{Value: v})
+		return ch, nil
+	}
+	return eh, ch
+}
+
+// limitCollection returns an element hook that collects the limit clause.
+func limitCollection() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemLiteral:
+			l, err := ToLiteral(ce)
+			if err != nil {
+				return nil, err
+			}
+			v, err := l.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("hook.LimitCollection requires an int64 literal, got %s instead", l)
+			}
+			if v < 0 {
+				return nil, fmt.Errorf("hook.LimitCollection requires a non negative limit, got %d instead", v)
+			}
+			st.limitSet, st.limit = true, v
+		case lexer.ItemPer:
+			if len(st.groupBy) == 0 {
+				return nil, fmt.Errorf("hook.LimitCollection requires a group by clause to limit per group")
+			}
+			st.limitPerGroup = true
+		}
+		return f, nil
+	}
+	return f
+}
+
+// asOf returns an element hook that collects the time of the as of clause.
+// Reduced-precision times stand for the last instant of the period they
+// cover, so as of "2015" returns the state of the graphs at the end of 2015.
+func asOf() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() || ce.Token().Type != lexer.ItemString {
+			return f, nil
+		}
+		tkn := ce.Token()
+		txt, err := strconv.Unquote(tkn.Text)
+		if err != nil {
+			return nil, fmt.Errorf("hook.AsOf failed to unquote %s with error %v", tkn.Text, err)
+		}
+		t, g, err := predicate.ParseTimeAnchor(txt)
+		if err != nil {
+			return nil, fmt.Errorf("hook.AsOf requires a valid time, got %s instead; %v", tkn.Text, err)
+		}
+		st.SetAsOf(g.Last(t))
+		return f, nil
+	}
+	return f
+}
+
+// granularity returns an element hook that collects the granularity of the
+// granularity clause.
+func granularity() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() || ce.Token().Type != lexer.ItemString {
+			return f, nil
+		}
+		tkn := ce.Token()
+		txt, err := strconv.Unquote(tkn.Text)
+		if err != nil {
+			return nil, fmt.Errorf("hook.Granularity failed to unquote %s with error %v", tkn.Text, err)
+		}
+		g, err := predicate.ParseGranularity(txt)
+		if err != nil {
+			return nil, fmt.Errorf("hook.Granularity requires one of year, month, day, hour, minute, or second, got %s instead", tkn.Text)
+		}
+		st.SetGranularity(g)
+		return f, nil
+	}
+	return f
+}
+
+// insertAnchor returns an element hook that collects the default time anchor
+// used to insert data with immutable predicates.
+func insertAnchor() ElementHook {
+	var (
+		f    ElementHook
+		sign lexer.TokenType
+	)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemPredicate:
+			p, err := ToPredicate(ce)
+			if err != nil {
+				return nil, err
+			}
+			ta, err := p.TimeAnchor()
+			if err != nil {
+				return nil, fmt.Errorf("hook.InsertAnchor requires a temporal predicate, got %s instead", p)
+			}
+			st.SetDefaultTimeAnchor(ta)
+		case lexer.ItemNow:
+			n := time.Now()
+			st.SetDefaultTimeAnchor(&n)
+		case lexer.ItemPlus, lexer.ItemMinus:
+			sign = tkn.Type
+		case lexer.ItemLiteral:
+			l, err := ToLiteral(ce)
+			if err != nil {
+				return nil, err
+			}
+			d, err := l.Duration()
+			if err != nil {
+				return nil, fmt.Errorf("hook.InsertAnchor requires a duration literal to shift now, got %s instead", l)
+			}
+			ta := st.DefaultTimeAnchor()
+			if ta == nil {
+				return nil, fmt.Errorf("hook.InsertAnchor found duration %s without a time anchor", l)
+			}
+			if sign == lexer.ItemMinus {
+				d = -d
+			}
+			t := ta.Add(d)
+			st.SetDefaultTimeAnchor(&t)
+		}
+		return f, nil
+	}
+	return f
+}
+
+// hintCollection returns an element hook that collects the query hints
+// provided on the with hint clause.
+func hintCollection() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemHintName:
+			name := strings.ToLower(tkn.Text)
+			switch name {
+			case "ordered":
+				st.hints.Ordered = true
+			case "noprefetch":
+				st.hints.NoPrefetch = true
+			case "dedup":
+				st.hints.Dedup = true
+			case "maxrows", "sortbuffer":
+				// The value will be provided by the next literal.
+				st.pendingHint = name
+			default:
+				return nil, fmt.Errorf("hook.HintCollection found unknown hint %q", tkn.Text)
+			}
+		case lexer.ItemLiteral:
+			if st.pendingHint == "" {
+				return nil, fmt.Errorf("hook.HintCollection found unexpected hint value %s", tkn.Text)
+			}
+			l, err := ToLiteral(ce)
+			if err != nil {
+				return nil, err
+			}
+			v, err := l.Int64()
+			if err != nil || v <= 0 {
+				return nil, fmt.Errorf("hook.HintCollection hint %s requires a positive int64 literal, got %s instead", st.pendingHint, l)
+			}
+			switch st.pendingHint {
+			case "maxrows":
+				st.hints.MaxRows = v
+			case "sortbuffer":
+				st.hints.SortBuffer = v
+			}
+			st.pendingHint = ""
+		case lexer.ItemComma, lexer.ItemRPar:
+			if st.pendingHint != "" {
+				return nil, fmt.Errorf("hook.HintCollection hint %s requires a value", st.pendingHint)
+			}
+		}
+		return f, nil
+	}
+	return f
+}
+
+// selectDistinct returns an element hook that marks the statement as only
+// returning distinct rows when the distinct keyword follows select.
+func selectDistinct() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		if tkn := ce.Token(); tkn.Type != lexer.ItemDistinct {
+			return nil, fmt.Errorf("hook.SelectDistinct expected the distinct keyword, got %s instead", tkn.Type)
+		}
+		st.SetDistinct()
+		return f, nil
+	}
+	return f
+}
+
+// createView returns an element hook that collects the name of the view
+// defined by a create view statement.
+func createView() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		if tkn.Type != lexer.ItemBinding {
+			return f, nil
+		}
+		if st.View() != "" {
+			return nil, fmt.Errorf("hook.CreateView: view already named %s, got %s", st.View(), tkn.Text)
+		}
+		st.SetView(tkn.Text)
+		return f, nil
+	}
+	return f
+}
+
+// havingExpression returns an element hook that collects the tokens of the
+// having clause and a clause hook that compiles them into an evaluator once
+// the clause is complete.
+func havingExpression() (ElementHook, ClauseHook) {
+	var (
+		eh ElementHook
+		ch ClauseHook
+	)
+	eh = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() || ce.Token().Type == lexer.ItemHaving {
+			return eh, nil
+		}
+		st.havingTokens = append(st.havingTokens, ce)
+		return eh, nil
+	}
+	ch = func(st *Statement, _ Symbol) (ClauseHook, error) {
+		if len(st.havingTokens) == 0 {
+			return ch, nil
+		}
+		e, err := NewEvaluator(st.havingTokens)
+		if err != nil {
+			return nil, fmt.Errorf("hook.HavingExpression failed to compile the having clause; %v", err)
+		}
+		st.having, st.havingTokens = e, nil
+		return ch, nil
+	}
+	return eh, ch
+}
+
+// prefixDeclaration returns an element hook that collects the namespace
+// prefixes declared on the statement.
+func prefixDeclaration() ElementHook {
+	var (
+		f    ElementHook
+		name string
+	)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemPrefixName:
+			name = tkn.Text
+		case lexer.ItemNodeType:
+			if err := st.AddPrefix(name, tkn.Text); err != nil {
+				return nil, err
+			}
+			name = ""
+		}
+		return f, nil
+	}
+	return f
+}
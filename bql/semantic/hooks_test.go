@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/table"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
@@ -88,6 +89,88 @@ func TestDataAccumulatorHook(t *testing.T) {
 	}
 }
 
+func TestDataAccumulatorHookEmbeddedTriples(t *testing.T) {
+	st := &Statement{}
+	in := `/_<s> "p"@[] <</_<a> "q"@[] <</_<b> "r"@[] "1"^^type:int64>>>> . /_<s> "p"@[] /_<o>`
+	hook := dataAccumulator(literal.DefaultBuilder())
+	for _, ce := range testConsumedElements(t, in) {
+		var err error
+		if hook, err = hook(st, ce); err != nil {
+			t.Fatalf("semantic.DataAccumulator hook should have never failed for %v with error %v", ce, err)
+		}
+	}
+	data := st.Data()
+	if len(data) != 2 {
+		t.Fatalf("semantic.DataAccumulator hook should have produced 2 triples; instead produced %v", data)
+	}
+	if got, want := data[0].O().String(), `<</_<a> "q"@[] <</_<b> "r"@[] "1"^^type:int64>>>>`; got != want {
+		t.Errorf("semantic.DataAccumulator hook failed to parse embedded triple; got %v, want %v", got, want)
+	}
+	if got, want := data[1].O().String(), "/_<o>"; got != want {
+		t.Errorf("semantic.DataAccumulator hook failed to parse object after embedded triple; got %v, want %v", got, want)
+	}
+}
+
+func TestInsertAnchorHook(t *testing.T) {
+	want, err := time.Parse(time.RFC3339Nano, "2015-01-01T00:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &Statement{}
+	for _, ce := range testConsumedElements(t, `at "backfill"@[2015-01-01T00:00:00Z]`) {
+		if _, err := insertAnchor()(st, ce); err != nil {
+			t.Fatalf("semantic.InsertAnchor hook should have never failed for %v with error %v", ce, err)
+		}
+	}
+	if got := st.DefaultTimeAnchor(); got == nil || !got.Equal(want) {
+		t.Fatalf("semantic.InsertAnchor hook collected the wrong anchor; got %v, want %v", got, want)
+	}
+	in := `/_<s> "p"@[] <</_<a> "q"@[] /_<b>>> . /_<s> "p"@[1975-01-01T00:01:01.999999999Z] /_<o>`
+	hook := dataAccumulator(literal.DefaultBuilder())
+	for _, ce := range testConsumedElements(t, in) {
+		if hook, err = hook(st, ce); err != nil {
+			t.Fatalf("semantic.DataAccumulator hook should have never failed for %v with error %v", ce, err)
+		}
+	}
+	var got []string
+	for _, trpl := range st.Data() {
+		got = append(got, trpl.String())
+	}
+	wantData := []string{
+		`/_<s>	"p"@[2015-01-01T00:00:00Z]	<</_<a> "q"@[2015-01-01T00:00:00Z] /_<b>>>`,
+		`/_<s>	"p"@[1975-01-01T00:01:01.999999999Z]	/_<o>`,
+	}
+	if !reflect.DeepEqual(got, wantData) {
+		t.Errorf("semantic.DataAccumulator hook failed to apply the default time anchor; got %q, want %q", got, wantData)
+	}
+
+	// Shift now by a duration.
+	st = &Statement{}
+	before := time.Now().Add(-time.Hour)
+	ah := insertAnchor()
+	for _, ce := range testConsumedElements(t, `at now - "1h"^^type:duration`) {
+		if ah, err = ah(st, ce); err != nil {
+			t.Fatalf("semantic.InsertAnchor hook should have never failed for %v with error %v", ce, err)
+		}
+	}
+	if got := st.DefaultTimeAnchor(); got == nil || got.Before(before) || got.After(time.Now().Add(-time.Hour)) {
+		t.Errorf("semantic.InsertAnchor hook failed to shift now by one hour; got %v", got)
+	}
+
+	// Reject non temporal anchors.
+	for _, in := range []string{`at "backfill"@[]`, `at "1"^^type:int64`} {
+		st, failed := &Statement{}, false
+		for _, ce := range testConsumedElements(t, in) {
+			if _, err := insertAnchor()(st, ce); err != nil {
+				failed = true
+			}
+		}
+		if !failed {
+			t.Errorf("semantic.InsertAnchor hook should have failed for %q", in)
+		}
+	}
+}
+
 func TestSemanticAcceptInsertDelete(t *testing.T) {
 	st := &Statement{}
 	ces := []ConsumedElement{
@@ -313,6 +396,8 @@ func TestWherePredicatClauseHook(t *testing.T) {
 	if err != nil {
 		t.Fatalf("time.Parse failed to parse valid upper time bound with error %v", err)
 	}
+	julyFirst := time.Date(2015, time.July, 1, 0, 0, 0, 0, time.UTC)
+	julyLast := time.Date(2015, time.July, 31, 23, 59, 59, 999999999, time.UTC)
 	runTabulatedClauseHookTest(t, "semantic.wherePredicateClause", f, []testTable{
 		{
 			valid: true,
@@ -553,6 +638,168 @@ func TestWherePredicatClauseHook(t *testing.T) {
 			},
 			want: &GraphClause{},
 		},
+		{
+			valid: true,
+			id:    "valid transitive predicate",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicate,
+					Text: `"foo"@[2015-07-19T13:12:04.669618843-07:00]`,
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPlus,
+					Text: "+",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				P:           p,
+				PTemporal:   true,
+				PTransitive: true,
+			},
+		},
+		{
+			valid: true,
+			id:    "valid reflexive transitive predicate",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicate,
+					Text: `"foo"@[2015-07-19T13:12:04.669618843-07:00]`,
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemStar,
+					Text: "*",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				P:           p,
+				PTemporal:   true,
+				PTransitive: true,
+				PReflexive:  true,
+			},
+		},
+		{
+			valid: false,
+			id:    "invalid transitive predicate with binding",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicate,
+					Text: `"foo"@[?foo]`,
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPlus,
+					Text: "+",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{},
+		},
+		{
+			valid: true,
+			id:    "reduced precision predicate",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicate,
+					Text: `"foo"@[2015-07]`,
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				PID:         "foo",
+				PLowerBound: &julyFirst,
+				PUpperBound: &julyLast,
+				PTemporal:   true,
+			},
+		},
+		{
+			valid: true,
+			id:    "reduced precision bounds",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicateBound,
+					Text: `"foo"@[2015-07,2015-07]`,
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				PID:         "foo",
+				PLowerBound: &julyFirst,
+				PUpperBound: &julyLast,
+				PTemporal:   true,
+			},
+		},
+		{
+			valid: true,
+			id:    "latest reduced precision predicate",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicate,
+					Text: `"foo"@[2015-07]`,
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLatest,
+					Text: "latest",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				PID:         "foo",
+				PLowerBound: &julyFirst,
+				PUpperBound: &julyLast,
+				PTemporal:   true,
+				PLatest:     true,
+			},
+		},
+		{
+			valid: true,
+			id:    "latest predicate binding",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemBinding,
+					Text: "?foo",
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLatest,
+					Text: "latest",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				PBinding: "?foo",
+				PLatest:  true,
+			},
+		},
+		{
+			valid: false,
+			id:    "invalid latest fully specified predicate",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicate,
+					Text: `"foo"@[2015-07-19T13:12:04.669618843-07:00]`,
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLatest,
+					Text: "latest",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{},
+		},
 	})
 }
 
@@ -944,3 +1191,457 @@ func TestWhereObjectClauseHook(t *testing.T) {
 		},
 	})
 }
+
+func TestGlobalTimeBoundHook(t *testing.T) {
+	tkn := func(tt lexer.TokenType, text string) ConsumedElement {
+		return NewConsumedToken(&lexer.Token{Type: tt, Text: text})
+	}
+	table := []struct {
+		id    string
+		valid bool
+		ces   []ConsumedElement
+		lower bool
+		upper bool
+	}{
+		{
+			id:    "before predicate",
+			valid: true,
+			ces: []ConsumedElement{
+				tkn(lexer.ItemBefore, "before"),
+				tkn(lexer.ItemPredicate, `"foo"@[2015-07-19T13:12:04.669618843-07:00]`),
+			},
+			upper: true,
+		},
+		{
+			id:    "after now minus duration",
+			valid: true,
+			ces: []ConsumedElement{
+				tkn(lexer.ItemAfter, "after"),
+				tkn(lexer.ItemNow, "now"),
+				NewConsumedSymbol("FOO"),
+				tkn(lexer.ItemMinus, "-"),
+				tkn(lexer.ItemLiteral, `"24h"^^type:duration`),
+			},
+			lower: true,
+		},
+		{
+			id:    "between now minus and plus duration",
+			valid: true,
+			ces: []ConsumedElement{
+				tkn(lexer.ItemBetween, "between"),
+				tkn(lexer.ItemNow, "now"),
+				tkn(lexer.ItemMinus, "-"),
+				tkn(lexer.ItemLiteral, `"1h"^^type:duration`),
+				tkn(lexer.ItemComma, ","),
+				tkn(lexer.ItemNow, "now"),
+				tkn(lexer.ItemPlus, "+"),
+				tkn(lexer.ItemLiteral, `"1h"^^type:duration`),
+			},
+			lower: true,
+			upper: true,
+		},
+		{
+			id: "immutable predicate",
+			ces: []ConsumedElement{
+				tkn(lexer.ItemBefore, "before"),
+				tkn(lexer.ItemPredicate, `"foo"@[]`),
+			},
+		},
+		{
+			id: "non duration offset",
+			ces: []ConsumedElement{
+				tkn(lexer.ItemBefore, "before"),
+				tkn(lexer.ItemNow, "now"),
+				tkn(lexer.ItemMinus, "-"),
+				tkn(lexer.ItemLiteral, `"1"^^type:int64`),
+			},
+		},
+		{
+			id: "or composition",
+			ces: []ConsumedElement{
+				tkn(lexer.ItemBefore, "before"),
+				tkn(lexer.ItemNow, "now"),
+				tkn(lexer.ItemOr, "or"),
+			},
+		},
+	}
+	for _, entry := range table {
+		st := &Statement{}
+		eh, ch := globalTimeBound()
+		var err error
+		for _, ce := range entry.ces {
+			if !ce.IsSymbol() && ce.Token().Type == lexer.ItemComma {
+				if _, err = ch(st, "GLOBAL_TIME_BOUND_ANCHOR"); err != nil {
+					break
+				}
+			}
+			if _, err = eh(st, ce); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			_, err = ch(st, "GLOBAL_TIME_BOUND_ANCHOR")
+		}
+		if entry.valid && err != nil {
+			t.Errorf("semantic.GlobalTimeBound case %q should have never failed with error %v", entry.id, err)
+			continue
+		}
+		if !entry.valid {
+			if err == nil {
+				t.Errorf("semantic.GlobalTimeBound failed to reject invalid case %q", entry.id)
+			}
+			continue
+		}
+		lo := st.GlobalLookupOptions()
+		if got, want := lo.LowerAnchor != nil, entry.lower; got != want {
+			t.Errorf("semantic.GlobalTimeBound case %q set lower anchor %v; want set %v", entry.id, lo.LowerAnchor, want)
+		}
+		if got, want := lo.UpperAnchor != nil, entry.upper; got != want {
+			t.Errorf("semantic.GlobalTimeBound case %q set upper anchor %v; want set %v", entry.id, lo.UpperAnchor, want)
+		}
+		if lo.LowerAnchor != nil && lo.UpperAnchor != nil && lo.LowerAnchor.After(*lo.UpperAnchor) {
+			t.Errorf("semantic.GlobalTimeBound case %q produced reversed bounds %v", entry.id, lo)
+		}
+	}
+}
+
+func TestGroupOrderAndLimitHooks(t *testing.T) {
+	tkn := func(tt lexer.TokenType, text string) ConsumedElement {
+		return NewConsumedToken(&lexer.Token{Type: tt, Text: text})
+	}
+	st := &Statement{}
+	for _, ce := range []ConsumedElement{
+		tkn(lexer.ItemGroup, "group"),
+		tkn(lexer.ItemBy, "by"),
+		tkn(lexer.ItemBinding, "?s"),
+		NewConsumedSymbol("FOO"),
+		tkn(lexer.ItemComma, ","),
+		tkn(lexer.ItemBinding, "?p"),
+	} {
+		if _, err := groupByBindings()(st, ce); err != nil {
+			t.Errorf("semantic.GroupByBindings should have never failed for %v with error %v", ce, err)
+		}
+	}
+	if got, want := st.GroupBy(), []string{"?s", "?p"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.GroupByBindings collected the wrong bindings; got %v, want %v", got, want)
+	}
+	obeh, obch := orderByBindings()
+	for _, key := range [][]ConsumedElement{
+		{tkn(lexer.ItemOrder, "order"), tkn(lexer.ItemBy, "by"), tkn(lexer.ItemBinding, "?s")},
+		{tkn(lexer.ItemDesc, "desc"), tkn(lexer.ItemComma, ","), tkn(lexer.ItemBinding, "?p")},
+		{tkn(lexer.ItemAsc, "asc"), tkn(lexer.ItemComma, ","), tkn(lexer.ItemBinding, "?o")},
+	} {
+		for _, ce := range key {
+			if _, err := obeh(st, ce); err != nil {
+				t.Errorf("semantic.OrderByBindings should have never failed for %v with error %v", ce, err)
+			}
+		}
+		if _, err := obch(st, "ORDER_BY_KEY"); err != nil {
+			t.Errorf("semantic.OrderByKey should have never failed for %v with error %v", key, err)
+		}
+	}
+	want := []table.SortConfig{{Binding: "?s", Desc: true}, {Binding: "?p"}, {Binding: "?o"}}
+	if got := st.OrderBy(); !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.OrderByBindings collected the wrong configuration; got %v, want %v", got, want)
+	}
+	for _, ce := range []ConsumedElement{
+		tkn(lexer.ItemLimit, "limit"),
+		tkn(lexer.ItemLiteral, `"3"^^type:int64`),
+		NewConsumedSymbol("FOO"),
+		tkn(lexer.ItemPer, "per"),
+		tkn(lexer.ItemGroup, "group"),
+	} {
+		if _, err := limitCollection()(st, ce); err != nil {
+			t.Errorf("semantic.LimitCollection should have never failed for %v with error %v", ce, err)
+		}
+	}
+	if !st.IsLimitSet() || st.Limit() != 3 || !st.IsLimitPerGroup() {
+		t.Errorf("semantic.LimitCollection failed to collect limit 3 per group; got %v, %d, %v", st.IsLimitSet(), st.Limit(), st.IsLimitPerGroup())
+	}
+
+	// Invalid limits.
+	for _, ces := range [][]ConsumedElement{
+		{tkn(lexer.ItemLiteral, `"3"^^type:text`)},
+		{tkn(lexer.ItemLiteral, `"-1"^^type:int64`)},
+		{tkn(lexer.ItemLiteral, `"3"^^type:int64`), tkn(lexer.ItemPer, "per")},
+	} {
+		st, failed := &Statement{}, false
+		for _, ce := range ces {
+			if _, err := limitCollection()(st, ce); err != nil {
+				failed = true
+			}
+		}
+		if !failed {
+			t.Errorf("semantic.LimitCollection failed to reject invalid limit %v", ces)
+		}
+	}
+}
+
+func TestWhereEmbeddedTripleClauseHook(t *testing.T) {
+	testTable := []struct {
+		in       string
+		o        string
+		embedded *GraphClause
+	}{
+		{
+			in: `<</_<a> "p"@[] /_<b>>>`,
+			o:  `<</_<a> "p"@[] /_<b>>>`,
+		},
+		{
+			in:       `<<?s "p"@[] ?o>>`,
+			embedded: &GraphClause{SBinding: "?s", OBinding: "?o"},
+		},
+		{
+			in:       `<</_<a> ?p "p"@[]>>`,
+			embedded: &GraphClause{PBinding: "?p"},
+		},
+	}
+	for _, entry := range testTable {
+		st := &Statement{}
+		st.ResetWorkingGraphClause()
+		for _, ce := range testConsumedElements(t, entry.in) {
+			f := whereEmbeddedTripleClause()
+			if tt := ce.Token().Type; tt == lexer.ItemLTriple || tt == lexer.ItemRTriple {
+				f = whereObjectClause()
+			}
+			if _, err := f(st, ce); err != nil {
+				t.Fatalf("semantic.WhereEmbeddedTripleClause failed for %q with error %v", entry.in, err)
+			}
+		}
+		c := st.WorkingClause()
+		if entry.o != "" {
+			if c.O == nil || c.O.String() != entry.o || c.OEmbedded != nil {
+				t.Errorf("semantic.WhereEmbeddedTripleClause failed to build object for %q; got %v, %v", entry.in, c.O, c.OEmbedded)
+			}
+			continue
+		}
+		e := c.OEmbedded
+		if c.O != nil || e == nil {
+			t.Fatalf("semantic.WhereEmbeddedTripleClause failed to build embedded pattern for %q; got %v, %v", entry.in, c.O, e)
+		}
+		if e.SBinding != entry.embedded.SBinding || e.PBinding != entry.embedded.PBinding || e.OBinding != entry.embedded.OBinding {
+			t.Errorf("semantic.WhereEmbeddedTripleClause returned the wrong bindings for %q; got %+v, want %+v", entry.in, e, entry.embedded)
+		}
+		for _, b := range []string{entry.embedded.SBinding, entry.embedded.PBinding, entry.embedded.OBinding} {
+			if _, ok := c.BindingsMap()[b]; b != "" && !ok {
+				t.Errorf("graphClause.BindingsMap is missing embedded binding %q for %q", b, entry.in)
+			}
+		}
+	}
+}
+
+func TestWhereGraphClauseHook(t *testing.T) {
+	st := &Statement{}
+	st.AddGraph("?a")
+	st.ResetWorkingGraphClause()
+	f := whereGraphClause()
+	scope := func(g string) {
+		if _, err := f(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemBinding, Text: g})); err != nil {
+			t.Fatalf("semantic.WhereGraphClause failed to scope %q with error %v", g, err)
+		}
+	}
+	unscope := func() {
+		if _, err := f(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemRBracket, Text: "}"})); err != nil {
+			t.Fatalf("semantic.WhereGraphClause failed to close scope with error %v", err)
+		}
+	}
+	add := func(s string) {
+		st.WorkingClause().SBinding = s
+		st.AddWorkingGrpahClause()
+	}
+	add("?x")
+	scope("?a")
+	add("?y")
+	scope("?g")
+	add("?z")
+	unscope()
+	unscope()
+	st.AddWorkingGrpahClause()
+	want := map[string][2]string{
+		"?x": {"", ""},
+		"?y": {"?a", ""},
+		"?z": {"", "?g"},
+	}
+	cls := st.SortedGraphPatternClauses()
+	if len(cls) != len(want) {
+		t.Fatalf("semantic.WhereGraphClause produced the wrong clauses; got %v", cls)
+	}
+	for _, c := range cls {
+		if got := [2]string{c.Graph, c.GBinding}; got != want[c.SBinding] {
+			t.Errorf("semantic.WhereGraphClause scoped %q wrongly; got %v, want %v", c.SBinding, got, want[c.SBinding])
+		}
+	}
+	if _, ok := st.BindingsMap()["?g"]; !ok {
+		t.Errorf("statement.BindingsMap should include graph binding ?g; got %v", st.BindingsMap())
+	}
+	if _, err := f(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemRBracket, Text: "}"})); err == nil {
+		t.Errorf("semantic.WhereGraphClause should have failed to close an unopened scope")
+	}
+}
+
+func TestHintCollectionHook(t *testing.T) {
+	tkn := func(tt lexer.TokenType, text string) ConsumedElement {
+		return NewConsumedToken(&lexer.Token{Type: tt, Text: text})
+	}
+	st := &Statement{}
+	for _, ce := range []ConsumedElement{
+		tkn(lexer.ItemWith, "with"),
+		tkn(lexer.ItemHint, "hint"),
+		tkn(lexer.ItemLPar, "("),
+		tkn(lexer.ItemHintName, "Ordered"),
+		NewConsumedSymbol("HINT_VALUE"),
+		tkn(lexer.ItemComma, ","),
+		tkn(lexer.ItemHintName, "maxrows"),
+		tkn(lexer.ItemLiteral, `"10"^^type:int64`),
+		tkn(lexer.ItemComma, ","),
+		tkn(lexer.ItemHintName, "noprefetch"),
+		tkn(lexer.ItemComma, ","),
+		tkn(lexer.ItemHintName, "SortBuffer"),
+		tkn(lexer.ItemLiteral, `"100"^^type:int64`),
+		tkn(lexer.ItemComma, ","),
+		tkn(lexer.ItemHintName, "dedup"),
+		tkn(lexer.ItemRPar, ")"),
+	} {
+		if _, err := hintCollection()(st, ce); err != nil {
+			t.Errorf("semantic.HintCollection should have never failed for %v with error %v", ce, err)
+		}
+	}
+	if got, want := *st.Hints(), (QueryHints{Ordered: true, NoPrefetch: true, MaxRows: 10, SortBuffer: 100, Dedup: true}); got != want {
+		t.Errorf("semantic.HintCollection collected the wrong hints; got %+v, want %+v", got, want)
+	}
+
+	// Invalid hints.
+	for _, ces := range [][]ConsumedElement{
+		{tkn(lexer.ItemHintName, "ordered"), tkn(lexer.ItemLiteral, `"1"^^type:int64`)},
+		{tkn(lexer.ItemHintName, "maxrows"), tkn(lexer.ItemRPar, ")")},
+		{tkn(lexer.ItemHintName, "maxrows"), tkn(lexer.ItemLiteral, `"0"^^type:int64`)},
+		{tkn(lexer.ItemHintName, "maxrows"), tkn(lexer.ItemLiteral, `"1"^^type:text`)},
+		{tkn(lexer.ItemHintName, "sortbuffer"), tkn(lexer.ItemComma, ",")},
+		{tkn(lexer.ItemHintName, "fast")},
+	} {
+		st, failed := &Statement{}, false
+		h := hintCollection()
+		for _, ce := range ces {
+			if _, err := h(st, ce); err != nil {
+				failed = true
+			}
+		}
+		if !failed {
+			t.Errorf("semantic.HintCollection failed to reject invalid hints %v", ces)
+		}
+	}
+}
+
+func TestAsOfHook(t *testing.T) {
+	table := []struct {
+		in   string
+		want time.Time
+	}{
+		{`"2015-06-01T10:00:00Z"`, time.Date(2015, time.June, 1, 10, 0, 0, 0, time.UTC)},
+		{`"2015"`, time.Date(2015, time.December, 31, 23, 59, 59, 999999999, time.UTC)},
+		{`"2015-06-01"`, time.Date(2015, time.June, 1, 23, 59, 59, 999999999, time.UTC)},
+	}
+	for _, entry := range table {
+		st := &Statement{}
+		h := asOf()
+		for _, ce := range []ConsumedElement{
+			NewConsumedToken(&lexer.Token{Type: lexer.ItemAs, Text: "as"}),
+			NewConsumedToken(&lexer.Token{Type: lexer.ItemOf, Text: "of"}),
+			NewConsumedToken(&lexer.Token{Type: lexer.ItemString, Text: entry.in}),
+		} {
+			if _, err := h(st, ce); err != nil {
+				t.Fatalf("semantic.AsOf failed to consume %v with error %v", ce, err)
+			}
+		}
+		if got := st.AsOf(); got == nil || !got.Equal(entry.want) {
+			t.Errorf("semantic.AsOf for %s collected %v; want %v", entry.in, got, entry.want)
+		}
+		if got := st.GlobalLookupOptions().UpperAnchor; got == nil || !got.Equal(entry.want) {
+			t.Errorf("semantic.AsOf for %s set the upper global time bound to %v; want %v", entry.in, got, entry.want)
+		}
+	}
+	for _, in := range []string{`"yesterday"`, `"2015-13"`, `2015`} {
+		if _, err := asOf()(&Statement{}, NewConsumedToken(&lexer.Token{Type: lexer.ItemString, Text: in})); err == nil {
+			t.Errorf("semantic.AsOf should have rejected %s", in)
+		}
+	}
+}
+
+func TestGranularityHook(t *testing.T) {
+	table := []struct {
+		in   string
+		want predicate.Granularity
+	}{
+		{`"day"`, predicate.Day},
+		{`"HOUR"`, predicate.Hour},
+		{`"Second"`, predicate.Second},
+	}
+	for _, entry := range table {
+		st := &Statement{}
+		h := granularity()
+		for _, ce := range []ConsumedElement{
+			NewConsumedToken(&lexer.Token{Type: lexer.ItemGranularity, Text: "granularity"}),
+			NewConsumedToken(&lexer.Token{Type: lexer.ItemString, Text: entry.in}),
+		} {
+			if _, err := h(st, ce); err != nil {
+				t.Fatalf("semantic.Granularity failed to consume %v with error %v", ce, err)
+			}
+		}
+		if got := st.Granularity(); got != entry.want {
+			t.Errorf("semantic.Granularity for %s collected %v; want %v", entry.in, got, entry.want)
+		}
+		if got := st.GlobalLookupOptions().Granularity; got != entry.want {
+			t.Errorf("semantic.Granularity for %s set the lookup granularity to %v; want %v", entry.in, got, entry.want)
+		}
+	}
+	for _, in := range []string{`"week"`, `""`, `day`} {
+		if _, err := granularity()(&Statement{}, NewConsumedToken(&lexer.Token{Type: lexer.ItemString, Text: in})); err == nil {
+			t.Errorf("semantic.Granularity should have rejected %s", in)
+		}
+	}
+}
+
+func TestSelectDistinctHook(t *testing.T) {
+	st := &Statement{}
+	h := selectDistinct()
+	if _, err := h(st, NewConsumedSymbol("SELECT_DISTINCT")); err != nil {
+		t.Errorf("semantic.SelectDistinct should never fail for symbols; got error %v", err)
+	}
+	if st.IsDistinct() {
+		t.Errorf("semantic.SelectDistinct should not mark the statement as distinct before consuming the distinct keyword")
+	}
+	if _, err := h(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemDistinct, Text: "distinct"})); err != nil {
+		t.Errorf("semantic.SelectDistinct failed to consume the distinct keyword with error %v", err)
+	}
+	if !st.IsDistinct() {
+		t.Errorf("semantic.SelectDistinct should have marked the statement as distinct")
+	}
+	if _, err := h(&Statement{}, NewConsumedToken(&lexer.Token{Type: lexer.ItemBinding, Text: "?s"})); err == nil {
+		t.Errorf("semantic.SelectDistinct should have rejected tokens other than distinct")
+	}
+}
+
+func TestCreateViewHook(t *testing.T) {
+	st := &Statement{}
+	h := createView()
+	tkns := []*lexer.Token{
+		{Type: lexer.ItemView, Text: "view"},
+		{Type: lexer.ItemBinding, Text: "?v"},
+		{Type: lexer.ItemAs, Text: "as"},
+		{Type: lexer.ItemQuery, Text: "select"},
+	}
+	for _, tkn := range tkns {
+		if _, err := h(st, NewConsumedToken(tkn)); err != nil {
+			t.Errorf("semantic.CreateView failed to consume token %v with error %v", tkn, err)
+		}
+	}
+	if _, err := h(st, NewConsumedSymbol("VARS")); err != nil {
+		t.Errorf("semantic.CreateView should never fail for symbols; got error %v", err)
+	}
+	if got, want := st.View(), "?v"; got != want {
+		t.Errorf("semantic.CreateView collected the wrong view name; got %q, want %q", got, want)
+	}
+	if _, err := h(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemBinding, Text: "?w"})); err == nil {
+		t.Errorf("semantic.CreateView should have rejected a second view name")
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// OutputBindings returns the bindings of the rows returned by a query
+// statement, in the order they are listed on the select clause. Aliased
+// projections are returned using their alias.
+func (s *Statement) OutputBindings() []string {
+	var bs []string
+	for _, prj := range s.projection {
+		if prj.Alias != "" {
+			bs = append(bs, prj.Alias)
+			continue
+		}
+		bs = append(bs, prj.Binding)
+	}
+	return bs
+}
+
+// OutputSchema returns the types of the values bound to the output bindings,
+// as inferred from the position of the bindings in the graph pattern. Bindings
+// whose type cannot be known before executing the query are declared as
+// table.AnyType.
+func (s *Statement) OutputSchema() table.Schema {
+	bts := s.BindingTypes()
+	sch := make(table.Schema)
+	for _, prj := range s.projection {
+		ct := bts[prj.Binding]
+		if prj.Aggregate {
+			switch prj.Kind {
+			case table.Count:
+				ct = table.Int64Type
+			case table.Avg:
+				ct = table.Float64Type
+			}
+		}
+		b := prj.Binding
+		if prj.Alias != "" {
+			b = prj.Alias
+		}
+		sch[b] = ct
+	}
+	return sch
+}
+
+// BindingTypes returns the types of the values bound to each binding of the
+// graph pattern. Bindings used in positions that bind different types of
+// values are declared as table.AnyType.
+func (s *Statement) BindingTypes() table.Schema {
+	sch := make(table.Schema)
+	add := func(b string, ct table.CellType) {
+		if b == "" {
+			return
+		}
+		ot, ok := sch[b]
+		switch {
+		case !ok || ot == table.AnyType:
+			sch[b] = ct
+		case ct != table.AnyType && ct != ot:
+			sch[b] = table.AnyType
+		}
+	}
+	for _, cls := range s.pattern {
+		if cls == nil {
+			continue
+		}
+		add(cls.GBinding, table.StringType)
+		add(cls.SBinding, table.NodeType)
+		add(cls.SAlias, table.NodeType)
+		add(cls.STypeAlias, table.StringType)
+		add(cls.SIDAlias, table.StringType)
+		add(cls.PBinding, table.PredicateType)
+		add(cls.PAlias, table.PredicateType)
+		add(cls.PIDAlias, table.StringType)
+		add(cls.PAnchorBinding, table.TimeType)
+		add(cls.PAnchorAlias, table.TimeType)
+		add(cls.PLowerBoundAlias, table.TimeType)
+		add(cls.PUpperBoundAlias, table.TimeType)
+		ot := table.AnyType
+		if cls.OEmbedded != nil {
+			ot = table.TripleType
+			add(cls.OEmbedded.SBinding, table.NodeType)
+			add(cls.OEmbedded.PBinding, table.PredicateType)
+			add(cls.OEmbedded.OBinding, table.AnyType)
+		}
+		add(cls.OBinding, ot)
+		add(cls.OAlias, ot)
+		add(cls.OTypeAlias, table.StringType)
+		add(cls.OIDAlias, table.StringType)
+		add(cls.OAnchorBinding, table.TimeType)
+		add(cls.OAnchorAlias, table.TimeType)
+		add(cls.OLowerBoundAlias, table.TimeType)
+		add(cls.OUpperBoundAlias, table.TimeType)
+	}
+	return sch
+}
+
+// ReadGraphs returns the graphs a statement retrieves data from.
+func (s *Statement) ReadGraphs() []string {
+	switch s.sType {
+	case Query, Analyze, CreateView:
+		return s.graphs
+	}
+	return nil
+}
+
+// WrittenGraphs returns the graphs a statement creates, removes, or modifies.
+func (s *Statement) WrittenGraphs() []string {
+	switch s.sType {
+	case Insert, Delete, Create, Drop:
+		return s.graphs
+	case CreateView:
+		if s.view != "" {
+			return []string{s.view}
+		}
+	}
+	return nil
+}
+
+// Constants contains the unique constant values referenced by a statement.
+type Constants struct {
+	Nodes      []*node.Node
+	Predicates []*predicate.Predicate
+	Literals   []*literal.Literal
+
+	// PredicateIDs contains the IDs of the predicates, including the ones only
+	// referenced by a time bound, such as "bought"@[2016,].
+	PredicateIDs []string
+}
+
+// Constants returns the nodes, predicates, and literals referenced by the
+// statement data and the graph clauses of its where and minus patterns.
+func (s *Statement) Constants() *Constants {
+	cs := &Constants{}
+	seen := make(map[string]bool)
+	once := func(k string) bool {
+		if seen[k] {
+			return false
+		}
+		seen[k] = true
+		return true
+	}
+	addNode := func(n *node.Node) {
+		if n != nil && once("n"+n.String()) {
+			cs.Nodes = append(cs.Nodes, n)
+		}
+	}
+	addPredicateID := func(id string) {
+		if id != "" && once("i"+id) {
+			cs.PredicateIDs = append(cs.PredicateIDs, id)
+		}
+	}
+	addPredicate := func(p *predicate.Predicate) {
+		if p == nil {
+			return
+		}
+		addPredicateID(string(p.ID()))
+		if once("p" + p.String()) {
+			cs.Predicates = append(cs.Predicates, p)
+		}
+	}
+	var addTriple func(t *triple.Triple)
+	addObject := func(o *triple.Object) {
+		if o == nil {
+			return
+		}
+		if n, ok := o.AsNode(); ok {
+			addNode(n)
+		}
+		if p, ok := o.AsPredicate(); ok {
+			addPredicate(p)
+		}
+		if l, ok := o.AsLiteral(); ok && once("l"+l.String()) {
+			cs.Literals = append(cs.Literals, l)
+		}
+		if t, ok := o.AsTriple(); ok {
+			addTriple(t)
+		}
+	}
+	addTriple = func(t *triple.Triple) {
+		addNode(t.S())
+		addPredicate(t.P())
+		addObject(t.O())
+	}
+	var addClause func(cls *GraphClause)
+	addClause = func(cls *GraphClause) {
+		if cls == nil {
+			return
+		}
+		addNode(cls.S)
+		addPredicate(cls.P)
+		addPredicateID(cls.PID)
+		addObject(cls.O)
+		addPredicateID(cls.OID)
+		addClause(cls.OEmbedded)
+	}
+	for _, t := range s.data {
+		addTriple(t)
+	}
+	for _, cls := range s.pattern {
+		addClause(cls)
+	}
+	for _, m := range s.minus {
+		for _, cls := range m {
+			addClause(cls)
+		}
+	}
+	return cs
+}
@@ -0,0 +1,123 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+func TestOutputBindingsAndSchema(t *testing.T) {
+	st := &Statement{
+		sType: Query,
+		pattern: []*GraphClause{
+			{SBinding: "?s", PBinding: "?p", OBinding: "?o", PAnchorAlias: "?t"},
+			{SBinding: "?o", PIDAlias: "?id", OBinding: "?x"},
+		},
+		projection: []*Projection{
+			{Binding: "?s", Alias: "?person"},
+			{Binding: "?o"},
+			{Binding: "?x"},
+			{Binding: "?t"},
+			{Binding: "?id"},
+			{Binding: "?p", Aggregate: true, Kind: table.Count, Alias: "?n"},
+		},
+	}
+	if got, want := st.OutputBindings(), []string{"?person", "?o", "?x", "?t", "?id", "?n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.OutputBindings returned %v; want %v", got, want)
+	}
+	want := table.Schema{
+		"?person": table.NodeType,
+		"?o":      table.NodeType,
+		"?x":      table.AnyType,
+		"?t":      table.TimeType,
+		"?id":     table.StringType,
+		"?n":      table.Int64Type,
+	}
+	if got := st.OutputSchema(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.OutputSchema returned %v; want %v", got, want)
+	}
+}
+
+func TestReadAndWrittenGraphs(t *testing.T) {
+	testTable := []struct {
+		st      StatementType
+		view    string
+		read    []string
+		written []string
+	}{
+		{st: Query, read: []string{"?a", "?b"}},
+		{st: Analyze, read: []string{"?a", "?b"}},
+		{st: Insert, written: []string{"?a", "?b"}},
+		{st: Drop, written: []string{"?a", "?b"}},
+		{st: CreateView, view: "?v", read: []string{"?a", "?b"}, written: []string{"?v"}},
+	}
+	for _, entry := range testTable {
+		st := &Statement{sType: entry.st, graphs: []string{"?a", "?b"}, view: entry.view}
+		if got := st.ReadGraphs(); !reflect.DeepEqual(got, entry.read) {
+			t.Errorf("Statement.ReadGraphs returned %v for %v; want %v", got, entry.st, entry.read)
+		}
+		if got := st.WrittenGraphs(); !reflect.DeepEqual(got, entry.written) {
+			t.Errorf("Statement.WrittenGraphs returned %v for %v; want %v", got, entry.st, entry.written)
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.Parse(`"knows"@[]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := literal.DefaultBuilder().Parse(`"42"^^type:int64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := triple.New(n, p, triple.NewLiteralObject(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &Statement{
+		sType: Query,
+		data:  []*triple.Triple{tr},
+		pattern: []*GraphClause{
+			{S: n, P: p, OBinding: "?o"},
+			{SBinding: "?o", PID: "bought", OBinding: "?x"},
+		},
+		minus: [][]*GraphClause{{{SBinding: "?o", P: p, O: triple.NewNodeObject(n)}}},
+	}
+	cs := st.Constants()
+	if got, want := cs.Nodes, []*node.Node{n}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.Constants returned nodes %v; want %v", got, want)
+	}
+	if got, want := cs.Predicates, []*predicate.Predicate{p}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.Constants returned predicates %v; want %v", got, want)
+	}
+	if got, want := cs.Literals, []*literal.Literal{l}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.Constants returned literals %v; want %v", got, want)
+	}
+	if got, want := cs.PredicateIDs, []string{"knows", "bought"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.Constants returned predicate IDs %v; want %v", got, want)
+	}
+}
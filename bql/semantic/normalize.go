@@ -0,0 +1,196 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// CompareClauses returns -1, 0, or 1 if the first clause is more, equally, or
+// less specific than the second one. Clauses with the same specificity are
+// ordered by their keys, so the order does not depend on how they were
+// written.
+func CompareClauses(a, b *GraphClause) int {
+	if sa, sb := a.Specificity(), b.Specificity(); sa != sb {
+		if sa > sb {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.Key(), b.Key())
+}
+
+// SortClauses sorts the provided clauses in place using CompareClauses.
+func SortClauses(cls []*GraphClause) {
+	sort.SliceStable(cls, func(i, j int) bool {
+		return CompareClauses(cls[i], cls[j]) < 0
+	})
+}
+
+// Key returns the canonical text of the clause. Fields are listed in a fixed
+// order, empty ones are omitted, and constants are written in their canonical
+// form, so equivalent clauses share the same key.
+func (c *GraphClause) Key() string {
+	var fs []string
+	add := func(name, v string) {
+		if v != "" {
+			fs = append(fs, name+"="+v)
+		}
+	}
+	addTime := func(name string, t *time.Time) {
+		if t != nil {
+			add(name, t.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	addFlag := func(name string, v bool) {
+		if v {
+			add(name, "true")
+		}
+	}
+
+	add("graph", c.Graph)
+	add("gbinding", c.GBinding)
+
+	if c.S != nil {
+		add("s", c.S.String())
+	}
+	add("sbinding", c.SBinding)
+	add("salias", c.SAlias)
+	add("stypealias", c.STypeAlias)
+	add("sidalias", c.SIDAlias)
+	if c.SType != nil {
+		add("stype", c.SType.String())
+	}
+
+	if c.P != nil {
+		add("p", c.P.CanonicalString())
+	}
+	add("pid", c.PID)
+	add("pbinding", c.PBinding)
+	add("palias", c.PAlias)
+	add("pidalias", c.PIDAlias)
+	add("panchorbinding", c.PAnchorBinding)
+	add("panchoralias", c.PAnchorAlias)
+	addTime("plowerbound", c.PLowerBound)
+	addTime("pupperbound", c.PUpperBound)
+	add("plowerboundalias", c.PLowerBoundAlias)
+	add("pupperboundalias", c.PUpperBoundAlias)
+	addFlag("ptemporal", c.PTemporal)
+	addFlag("ptransitive", c.PTransitive)
+	addFlag("preflexive", c.PReflexive)
+	addFlag("platest", c.PLatest)
+
+	if c.O != nil {
+		add("o", c.O.CanonicalString())
+	}
+	add("obinding", c.OBinding)
+	add("oalias", c.OAlias)
+	add("oid", c.OID)
+	add("otypealias", c.OTypeAlias)
+	add("oidalias", c.OIDAlias)
+	add("oanchorbinding", c.OAnchorBinding)
+	add("oanchoralias", c.OAnchorAlias)
+	addTime("olowerbound", c.OLowerBound)
+	addTime("oupperbound", c.OUpperBound)
+	add("olowerboundalias", c.OLowerBoundAlias)
+	add("oupperboundalias", c.OUpperBoundAlias)
+	addFlag("otemporal", c.OTemporal)
+	if c.OEmbedded != nil {
+		add("oembedded", "{"+c.OEmbedded.Key()+"}")
+	}
+	return strings.Join(fs, " ")
+}
+
+// Normalize returns an equivalent copy of the clause in canonical form. Time
+// anchors and bounds are expressed in UTC, and time bounds whose lower and
+// upper values match are folded into a constant temporal predicate, which
+// increases the specificity of the clause.
+func (c *GraphClause) Normalize() *GraphClause {
+	n := *c
+	if n.P != nil {
+		n.P = n.P.UTC()
+	}
+	if n.O != nil {
+		if p, ok := n.O.AsPredicate(); ok {
+			n.O = triple.NewPredicateObject(p.UTC())
+		}
+	}
+	n.PLowerBound, n.PUpperBound = utc(n.PLowerBound), utc(n.PUpperBound)
+	n.OLowerBound, n.OUpperBound = utc(n.OLowerBound), utc(n.OUpperBound)
+
+	if n.P == nil && n.PID != "" && !n.PTransitive && !n.PLatest && n.PLowerBoundAlias == "" && n.PUpperBoundAlias == "" {
+		if t, ok := sameInstant(n.PLowerBound, n.PUpperBound); ok {
+			if p, err := predicate.NewTemporal(n.PID, t); err == nil {
+				n.P, n.PID, n.PLowerBound, n.PUpperBound, n.PTemporal = p, "", nil, nil, true
+			}
+		}
+	}
+	if n.O == nil && n.OEmbedded == nil && n.OID != "" && n.OLowerBoundAlias == "" && n.OUpperBoundAlias == "" {
+		if t, ok := sameInstant(n.OLowerBound, n.OUpperBound); ok {
+			if p, err := predicate.NewTemporal(n.OID, t); err == nil {
+				n.O, n.OID, n.OLowerBound, n.OUpperBound, n.OTemporal = triple.NewPredicateObject(p), "", nil, nil, true
+			}
+		}
+	}
+	if c.OEmbedded != nil {
+		n.OEmbedded = c.OEmbedded.Normalize()
+	}
+	return &n
+}
+
+// Normalize returns a copy of the statement with all the clauses of its where
+// and minus patterns normalized. Clauses keep the order they were written in,
+// since it is honored by the ordered hint. Normalize has the signature of a
+// Rewriter, so it can be registered to normalize all statements before they
+// are planned.
+func Normalize(s *Statement) (*Statement, error) {
+	c := s.Clone()
+	normalizeClauses(c.pattern)
+	for _, m := range c.minus {
+		normalizeClauses(m)
+	}
+	return c, nil
+}
+
+// normalizeClauses replaces the provided clauses by their normalized version.
+func normalizeClauses(cls []*GraphClause) {
+	for i, cl := range cls {
+		if cl != nil {
+			cls[i] = cl.Normalize()
+		}
+	}
+}
+
+// utc returns a copy of the provided time expressed in UTC.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// sameInstant returns the instant both bounds refer to, if any.
+func sameInstant(lb, ub *time.Time) (time.Time, bool) {
+	if lb == nil || ub == nil || !lb.Equal(*ub) {
+		return time.Time{}, false
+	}
+	return *lb, true
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+func TestGraphClauseNormalize(t *testing.T) {
+	pst := time.FixedZone("PST", -8*3600)
+	t1 := time.Date(2016, 1, 1, 0, 0, 0, 0, pst)
+	t2 := time.Date(2016, 2, 1, 0, 0, 0, 0, pst)
+	p, err := predicate.NewTemporal("bought", t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		in   *GraphClause
+		spc  int
+		want string
+	}{
+		{
+			in:   &GraphClause{SBinding: "?s", P: p, PTemporal: true, OBinding: "?o"},
+			spc:  1,
+			want: `sbinding=?s p="bought"@[2016-01-01T08:00:00Z] ptemporal=true obinding=?o`,
+		},
+		{
+			in:   &GraphClause{SBinding: "?s", PID: "bought", PLowerBound: &t1, PUpperBound: &t1, PTemporal: true, OBinding: "?o"},
+			spc:  1,
+			want: `sbinding=?s p="bought"@[2016-01-01T08:00:00Z] ptemporal=true obinding=?o`,
+		},
+		{
+			in:   &GraphClause{SBinding: "?s", PID: "bought", PLowerBound: &t1, PUpperBound: &t2, PTemporal: true, OBinding: "?o"},
+			spc:  0,
+			want: `sbinding=?s pid=bought plowerbound=2016-01-01T08:00:00Z pupperbound=2016-02-01T08:00:00Z ptemporal=true obinding=?o`,
+		},
+		{
+			in:   &GraphClause{SBinding: "?s", PID: "bought", PLowerBound: &t1, PUpperBound: &t1, PLowerBoundAlias: "?l", PTemporal: true},
+			spc:  0,
+			want: `sbinding=?s pid=bought plowerbound=2016-01-01T08:00:00Z pupperbound=2016-01-01T08:00:00Z plowerboundalias=?l ptemporal=true`,
+		},
+		{
+			in:   &GraphClause{SBinding: "?s", PBinding: "?p", OID: "bought", OLowerBound: &t1, OUpperBound: &t1, OTemporal: true},
+			spc:  1,
+			want: `sbinding=?s pbinding=?p o="bought"@[2016-01-01T08:00:00Z] otemporal=true`,
+		},
+	}
+	for _, entry := range testTable {
+		got := entry.in.Normalize()
+		if got.Specificity() != entry.spc || got.Key() != entry.want {
+			t.Errorf("GraphClause.Normalize(%v) returned specificity %d and key %q; want %d and %q", entry.in, got.Specificity(), got.Key(), entry.spc, entry.want)
+		}
+	}
+	// Normalizing returns a copy.
+	in := &GraphClause{PID: "bought", PLowerBound: &t1, PUpperBound: &t1}
+	if in.Normalize(); in.P != nil || in.PLowerBound.Location() != pst {
+		t.Errorf("GraphClause.Normalize modified the original clause %v", in)
+	}
+}
+
+func TestGraphClauseKey(t *testing.T) {
+	p, err := predicate.NewTemporal("bought", time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pst, err := predicate.NewTemporal("bought", time.Date(2015, 12, 31, 16, 0, 0, 0, time.FixedZone("PST", -8*3600)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &GraphClause{SBinding: "?s", P: p, O: triple.NewPredicateObject(p)}
+	b := &GraphClause{SBinding: "?s", P: pst, O: triple.NewPredicateObject(pst)}
+	if a.Key() != b.Key() {
+		t.Errorf("GraphClause.Key should not depend on time zones; got %q and %q", a.Key(), b.Key())
+	}
+	if c := (&GraphClause{SBinding: "?x", P: p}); a.Key() == c.Key() {
+		t.Errorf("GraphClause.Key returned the same key %q for different clauses", a.Key())
+	}
+}
+
+func TestSortClauses(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := []*GraphClause{
+		{SBinding: "?b", PBinding: "?p", OBinding: "?o"},
+		{S: n, PBinding: "?p", OBinding: "?o"},
+		{SBinding: "?a", PBinding: "?p", OBinding: "?o"},
+	}
+	SortClauses(cls)
+	var got []string
+	for _, c := range cls {
+		got = append(got, c.Key())
+	}
+	want := []string{
+		"s=/u<joe> pbinding=?p obinding=?o",
+		"sbinding=?a pbinding=?p obinding=?o",
+		"sbinding=?b pbinding=?p obinding=?o",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SortClauses returned %q; want %q", got, want)
+			break
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t1 := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	st := &Statement{
+		sType:   Query,
+		pattern: []*GraphClause{{SBinding: "?s", PID: "bought", PLowerBound: &t1, PUpperBound: &t1, OBinding: "?o"}},
+		minus:   [][]*GraphClause{{{SBinding: "?s", PID: "sold", PLowerBound: &t1, PUpperBound: &t1, OBinding: "?o"}}},
+	}
+	got, err := Normalize(st)
+	if err != nil {
+		t.Fatalf("Normalize failed with error %v", err)
+	}
+	if got.pattern[0].P == nil || got.minus[0][0].P == nil {
+		t.Errorf("Normalize failed to fold the time bounds of %v", got)
+	}
+	if st.pattern[0].P != nil || st.minus[0][0].P != nil {
+		t.Errorf("Normalize modified the original statement")
+	}
+}
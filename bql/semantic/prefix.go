@@ -0,0 +1,112 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/triple/node"
+)
+
+// AddPrefix declares a namespace prefix for the statement. Once declared,
+// prefixed nodes such as foaf:<joe>, node types such as foaf:person, and
+// predicate IDs such as "foaf:knows" get expanded using the provided node
+// type during semantic analysis.
+func (s *Statement) AddPrefix(name, expansion string) error {
+	name = strings.TrimSuffix(name, ":")
+	if !validPrefixName(name) {
+		return fmt.Errorf("semantic.AddPrefix: invalid prefix name %q", name)
+	}
+	if _, err := node.NewType(expansion); err != nil {
+		return fmt.Errorf("semantic.AddPrefix: invalid expansion for prefix %q; %v", name, err)
+	}
+	if s.prefixes == nil {
+		s.prefixes = make(map[string]string)
+	}
+	if _, ok := s.prefixes[name]; ok {
+		return fmt.Errorf("semantic.AddPrefix: prefix %q already declared", name)
+	}
+	s.prefixes[name] = expansion
+	return nil
+}
+
+// Prefixes returns the namespace prefixes declared on the statement indexed by
+// name.
+func (s *Statement) Prefixes() map[string]string {
+	return s.prefixes
+}
+
+// ExpandPrefixes returns the provided token with all the declared prefixes it
+// uses expanded. Tokens that do not use prefixes are returned untouched.
+// Prefixed nodes and node types must use declared prefixes, but predicate IDs
+// that do not start with a declared prefix are left as is since colons are
+// valid in predicate IDs.
+func (s *Statement) ExpandPrefixes(tkn *lexer.Token) (*lexer.Token, error) {
+	switch tkn.Type {
+	case lexer.ItemNode, lexer.ItemNodeType:
+		if strings.HasPrefix(tkn.Text, "/") {
+			return tkn, nil
+		}
+		idx := strings.Index(tkn.Text, ":")
+		if idx < 0 {
+			return tkn, nil
+		}
+		exp, ok := s.prefixes[tkn.Text[:idx]]
+		if !ok {
+			return nil, fmt.Errorf("semantic.ExpandPrefixes: undeclared prefix %q in %q", tkn.Text[:idx], tkn.Text)
+		}
+		rest := tkn.Text[idx+1:]
+		if rest != "" && !strings.HasPrefix(rest, "<") {
+			rest = "/" + rest
+		}
+		return &lexer.Token{Type: tkn.Type, Text: exp + rest}, nil
+	case lexer.ItemPredicate, lexer.ItemPredicateBound:
+		end := strings.LastIndex(tkn.Text, `"@[`)
+		if len(s.prefixes) == 0 || !strings.HasPrefix(tkn.Text, `"`) || end < 1 {
+			return tkn, nil
+		}
+		id := tkn.Text[1:end]
+		idx := strings.Index(id, ":")
+		if idx < 0 {
+			return tkn, nil
+		}
+		exp, ok := s.prefixes[id[:idx]]
+		if !ok {
+			return tkn, nil
+		}
+		return &lexer.Token{Type: tkn.Type, Text: `"` + exp + "/" + id[idx+1:] + tkn.Text[end:]}, nil
+	}
+	return tkn, nil
+}
+
+// validPrefixName returns true if the name is made of letters, digits, and
+// underscores, starting with a letter.
+func validPrefixName(name string) bool {
+	if name == "" || strings.EqualFold(name, "type") {
+		return false
+	}
+	for i, r := range name {
+		if i == 0 && !unicode.IsLetter(r) {
+			return false
+		}
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"testing"
+
+	"github.com/google/badwolf/bql/lexer"
+)
+
+func TestAddPrefix(t *testing.T) {
+	st := &Statement{}
+	if err := st.AddPrefix("foaf:", "/foaf"); err != nil {
+		t.Fatalf("semantic.AddPrefix(foaf:, /foaf) failed with error %v", err)
+	}
+	if got, want := st.Prefixes()["foaf"], "/foaf"; got != want {
+		t.Errorf("semantic.Prefixes returned %q for foaf; want %q", got, want)
+	}
+	table := []struct {
+		name, exp string
+	}{
+		{"foaf", "/other"},
+		{"1foaf", "/foaf"},
+		{"type", "/foaf"},
+		{"ex", "foaf"},
+		{"ex", "/foaf/"},
+	}
+	for _, entry := range table {
+		if err := st.AddPrefix(entry.name, entry.exp); err == nil {
+			t.Errorf("semantic.AddPrefix(%q, %q) should have failed", entry.name, entry.exp)
+		}
+	}
+}
+
+func TestExpandPrefixes(t *testing.T) {
+	st := &Statement{}
+	if err := st.AddPrefix("foaf", "/foaf"); err != nil {
+		t.Fatalf("semantic.AddPrefix(foaf, /foaf) failed with error %v", err)
+	}
+	table := []struct {
+		in   lexer.Token
+		want string
+	}{
+		{lexer.Token{Type: lexer.ItemNode, Text: "foaf:<joe>"}, "/foaf<joe>"},
+		{lexer.Token{Type: lexer.ItemNode, Text: "foaf:person<joe>"}, "/foaf/person<joe>"},
+		{lexer.Token{Type: lexer.ItemNode, Text: "/u<foaf:joe>"}, "/u<foaf:joe>"},
+		{lexer.Token{Type: lexer.ItemNodeType, Text: "foaf:person"}, "/foaf/person"},
+		{lexer.Token{Type: lexer.ItemPredicate, Text: `"foaf:knows"@[]`}, `"/foaf/knows"@[]`},
+		{lexer.Token{Type: lexer.ItemPredicate, Text: `"foaf:knows"@[?t]`}, `"/foaf/knows"@[?t]`},
+		{lexer.Token{Type: lexer.ItemPredicateBound, Text: `"foaf:knows"@[,]`}, `"/foaf/knows"@[,]`},
+		{lexer.Token{Type: lexer.ItemPredicate, Text: `"ex:knows"@[]`}, `"ex:knows"@[]`},
+		{lexer.Token{Type: lexer.ItemLiteral, Text: `"foaf:x"^^type:text`}, `"foaf:x"^^type:text`},
+	}
+	for _, entry := range table {
+		in := entry.in
+		got, err := st.ExpandPrefixes(&in)
+		if err != nil {
+			t.Errorf("semantic.ExpandPrefixes(%q) failed with error %v", entry.in.Text, err)
+			continue
+		}
+		if got.Text != entry.want || got.Type != entry.in.Type {
+			t.Errorf("semantic.ExpandPrefixes(%q) returned %q; want %q", entry.in.Text, got.Text, entry.want)
+		}
+	}
+	if _, err := st.ExpandPrefixes(&lexer.Token{Type: lexer.ItemNode, Text: "ex:<joe>"}); err == nil {
+		t.Errorf("semantic.ExpandPrefixes should fail for undeclared prefix on ex:<joe>")
+	}
+}
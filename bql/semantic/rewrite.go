@@ -0,0 +1,132 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Rewriter transforms a statement once its semantic analysis is complete, and
+// before it gets planned. Rewriters must not modify the provided statement,
+// since it may be shared, for instance by a statement cache; instead, they
+// should return a modified clone, or the same statement if it does not need
+// to change.
+type Rewriter func(*Statement) (*Statement, error)
+
+// rewriter is a registered rewrite pass.
+type rewriter struct {
+	name string
+	r    Rewriter
+}
+
+var (
+	rwMu      sync.RWMutex
+	rewriters []rewriter
+)
+
+// RegisterRewriter adds a rewrite pass, such as a macro expansion or the
+// renaming of deprecated predicates, to the ones applied by Rewrite. Passes are
+// applied in the order they were registered, and should be registered during
+// initialization. Registering two passes with the same name fails.
+func RegisterRewriter(name string, r Rewriter) error {
+	if name == "" || r == nil {
+		return fmt.Errorf("semantic.RegisterRewriter: rewriters require a name and a function")
+	}
+	rwMu.Lock()
+	defer rwMu.Unlock()
+	for _, rw := range rewriters {
+		if rw.name == name {
+			return fmt.Errorf("semantic.RegisterRewriter: rewriter %q is already registered", name)
+		}
+	}
+	rewriters = append(rewriters, rewriter{name: name, r: r})
+	return nil
+}
+
+// Rewrite applies all the registered rewrite passes to the provided statement
+// and returns the resulting one.
+func Rewrite(s *Statement) (*Statement, error) {
+	rwMu.RLock()
+	defer rwMu.RUnlock()
+	for _, rw := range rewriters {
+		ns, err := rw.r(s)
+		if err != nil {
+			return nil, fmt.Errorf("semantic.Rewrite: rewriter %q failed with error %v", rw.name, err)
+		}
+		if ns == nil {
+			return nil, fmt.Errorf("semantic.Rewrite: rewriter %q returned no statement", rw.name)
+		}
+		s = ns
+	}
+	return s, nil
+}
+
+// Clone returns a copy of the statement that can be modified without altering
+// the original one. Triples, predicates, and other immutable values are
+// shared by both statements.
+func (s *Statement) Clone() *Statement {
+	c := *s
+	c.graphs = append([]string(nil), s.graphs...)
+	c.data = append(c.data[:0:0], s.data...)
+	c.pattern = cloneClauses(s.pattern)
+	c.minus = nil
+	for _, m := range s.minus {
+		c.minus = append(c.minus, cloneClauses(m))
+	}
+	c.graphScopes = append([]string(nil), s.graphScopes...)
+	c.projection = nil
+	for _, prj := range s.projection {
+		p := *prj
+		c.projection = append(c.projection, &p)
+	}
+	c.groupBy = append([]string(nil), s.groupBy...)
+	c.orderBy = append(c.orderBy[:0:0], s.orderBy...)
+	c.orderByTokens = append(c.orderByTokens[:0:0], s.orderByTokens...)
+	c.havingTokens = append(c.havingTokens[:0:0], s.havingTokens...)
+	c.tokens = append(c.tokens[:0:0], s.tokens...)
+	if s.prefixes != nil {
+		c.prefixes = make(map[string]string)
+		for k, v := range s.prefixes {
+			c.prefixes[k] = v
+		}
+	}
+	return &c
+}
+
+// cloneClauses returns a copy of the provided graph clauses.
+func cloneClauses(cls []*GraphClause) []*GraphClause {
+	var res []*GraphClause
+	for _, cl := range cls {
+		if cl == nil {
+			res = append(res, nil)
+			continue
+		}
+		c := *cl
+		if cl.OEmbedded != nil {
+			e := *cl.OEmbedded
+			c.OEmbedded = &e
+		}
+		res = append(res, &c)
+	}
+	return res
+}
+
+// SetGraphs replaces the graphs listed on the statement, allowing rewriters to
+// redirect statements to other graphs. Graph clauses scoped to a graph keep
+// referring to it.
+func (s *Statement) SetGraphs(gs []string) {
+	s.graphs = gs
+}
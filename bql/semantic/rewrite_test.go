@@ -0,0 +1,70 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	rename := func(s *Statement) (*Statement, error) {
+		if len(s.Graphs()) == 0 || s.Graphs()[0] != "?deprecated" {
+			return s, nil
+		}
+		c := s.Clone()
+		c.SetGraphs([]string{"?current"})
+		for _, cls := range c.GraphPatternClauses() {
+			if cls.Graph == "?deprecated" {
+				cls.Graph = "?current"
+			}
+		}
+		return c, nil
+	}
+	if err := RegisterRewriter("rename-deprecated", rename); err != nil {
+		t.Fatalf("RegisterRewriter failed with error %v", err)
+	}
+	if err := RegisterRewriter("rename-deprecated", rename); err == nil {
+		t.Errorf("RegisterRewriter should have failed to register the same name twice")
+	}
+	if err := RegisterRewriter("fail", func(s *Statement) (*Statement, error) {
+		if len(s.Graphs()) > 0 && s.Graphs()[0] == "?fail" {
+			return nil, errors.New("failed")
+		}
+		return s, nil
+	}); err != nil {
+		t.Fatalf("RegisterRewriter failed with error %v", err)
+	}
+
+	st := &Statement{sType: Query, graphs: []string{"?deprecated"}, pattern: []*GraphClause{{Graph: "?deprecated", SBinding: "?s"}}}
+	got, err := Rewrite(st)
+	if err != nil {
+		t.Fatalf("Rewrite failed with error %v", err)
+	}
+	if want := []string{"?current"}; !reflect.DeepEqual(got.Graphs(), want) || got.GraphPatternClauses()[0].Graph != "?current" {
+		t.Errorf("Rewrite returned graphs %v and clause %v; want %v", got.Graphs(), got.GraphPatternClauses()[0], want)
+	}
+	if st.Graphs()[0] != "?deprecated" || st.GraphPatternClauses()[0].Graph != "?deprecated" {
+		t.Errorf("Rewrite modified the original statement")
+	}
+	other := &Statement{sType: Query, graphs: []string{"?other"}}
+	if got, err := Rewrite(other); err != nil || got != other {
+		t.Errorf("Rewrite should have returned the same statement; got %v, %v", got, err)
+	}
+	if _, err := Rewrite(&Statement{sType: Query, graphs: []string{"?fail"}}); err == nil {
+		t.Errorf("Rewrite should have failed when a rewriter fails")
+	}
+}
@@ -23,6 +23,9 @@ import (
 	"sort"
 	"time"
 
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/node"
 	"github.com/google/badwolf/triple/predicate"
@@ -42,6 +45,12 @@ const (
 	Create
 	// Drop statement.
 	Drop
+	// Analyze statement.
+	Analyze
+	// CreateView statement.
+	CreateView
+	// Extension statement, added by a grammar extension.
+	Extension
 )
 
 // String provides a readable version of the StatementType.
@@ -57,6 +66,12 @@ func (t StatementType) String() string {
 		return "CREATE"
 	case Drop:
 		return "DROP"
+	case Analyze:
+		return "ANALYZE"
+	case CreateView:
+		return "CREATE VIEW"
+	case Extension:
+		return "EXTENSION"
 	default:
 		return "UNKNOWN"
 	}
@@ -68,16 +83,81 @@ type Statement struct {
 	graphs        []string
 	data          []*triple.Triple
 	pattern       []*GraphClause
+	minus         [][]*GraphClause
+	inMinus       bool
+	graphScopes   []string
 	workingClause *GraphClause
+	lookupOptions storage.LookupOptions
+	projection    []*Projection
+	groupBy       []string
+	orderBy       []table.SortConfig
+	orderByTokens []ConsumedElement
+	limitSet      bool
+	limit         int64
+	limitPerGroup bool
+	prefetchHints storage.PrefetchHints
+	havingTokens  []ConsumedElement
+	having        Evaluator
+	hints         QueryHints
+	distinct      bool
+	view          string
+	pendingHint   string
+	anchor        *time.Time
+	asOf          *time.Time
+	granularity   predicate.Granularity
+	prefixes      map[string]string
+	tokens        []lexer.Token
+	extension     interface{}
+}
+
+// QueryHints contains the statement level hints provided on the with hint
+// clause. Hints allow to override the planner decisions for a given query.
+type QueryHints struct {
+	// Ordered forces the planner to process the graph pattern clauses in the
+	// order they were written instead of sorting them by specificity.
+	Ordered bool
+
+	// NoPrefetch prevents the planner from passing prefetch hints to the
+	// queried graphs.
+	NoPrefetch bool
+
+	// MaxRows, if positive, is the maximum number of rows the planner may hold
+	// while executing the query. Queries that exceed it fail.
+	MaxRows int64
+
+	// SortBuffer, if positive, is the maximum number of rows sorts may hold in
+	// memory. Larger results are sorted in runs spilled to disk.
+	SortBuffer int64
+
+	// Dedup makes the planner merge the identical rows retrieved from different
+	// graphs.
+	Dedup bool
+}
+
+// Projection represents a binding listed on the select clause. Aggregated
+// projections compute their value for each group of rows using the values
+// bound to the binding.
+type Projection struct {
+	Binding   string
+	Alias     string
+	Aggregate bool
+	Kind      table.AggregationKind
+	Distinct  bool
 }
 
 // GraphClause represents a clause of a graph pattern in a where clause.
 type GraphClause struct {
+	Graph    string
+	GBinding string
+
 	S          *node.Node
 	SBinding   string
 	SAlias     string
 	STypeAlias string
 	SIDAlias   string
+	// SType, when set, only matches subjects whose type is SType or lives
+	// under it in the type hierarchy.
+	SType *node.Type
 
 	P                *predicate.Predicate
 	PID              string
@@ -92,6 +172,17 @@ type GraphClause struct {
 	PUpperBoundAlias string
 	PTemporal        bool
 
+	// PTransitive makes the clause match chains of one or more triples using
+	// predicate P, as in "parent_of"@[]+. PReflexive, set for "parent_of"@[]*,
+	// also matches the empty chain that links a node to itself.
+	PTransitive bool
+	PReflexive  bool
+
+	// PLatest makes the clause only match, for each subject and predicate ID,
+	// the temporal triples with the latest time anchor within the time bounds
+	// of the clause, as in "lives_in"@[,] latest.
+	PLatest bool
+
 	O                *triple.Object
 	OBinding         string
 	OAlias           string
@@ -105,9 +196,15 @@ type GraphClause struct {
 	OLowerBoundAlias string
 	OUpperBoundAlias string
 	OTemporal        bool
+
+	// OEmbedded contains the pattern of the embedded triple the object should
+	// box. Only its S, SBinding, P, PBinding, O, and OBinding are used.
+	OEmbedded *GraphClause
 }
 
-// Specificity return
+// Specificity returns the number of positions of the clause, out of the
+// subject, the predicate, and the object, fixed to a constant value. More
+// specific clauses are expected to match fewer triples.
 func (c *GraphClause) Specificity() int {
 	s := 0
 	if c.S != nil {
@@ -126,6 +223,7 @@ func (c *GraphClause) Specificity() int {
 func (c *GraphClause) BindingsMap() map[string]int {
 	bm := make(map[string]int)
 
+	addToBindings(bm, c.GBinding)
 	addToBindings(bm, c.SBinding)
 	addToBindings(bm, c.SAlias)
 	addToBindings(bm, c.STypeAlias)
@@ -145,6 +243,11 @@ func (c *GraphClause) BindingsMap() map[string]int {
 	addToBindings(bm, c.OAnchorBinding)
 	addToBindings(bm, c.OLowerBoundAlias)
 	addToBindings(bm, c.OUpperBoundAlias)
+	if c.OEmbedded != nil {
+		addToBindings(bm, c.OEmbedded.SBinding)
+		addToBindings(bm, c.OEmbedded.PBinding)
+		addToBindings(bm, c.OEmbedded.OBinding)
+	}
 
 	return bm
 }
@@ -173,11 +276,35 @@ func (s *Statement) Type() StatementType {
 	return s.sType
 }
 
+// SetExtensionData sets the data collected by the hooks of a grammar
+// extension for the statement.
+func (s *Statement) SetExtensionData(d interface{}) {
+	s.extension = d
+}
+
+// ExtensionData returns the data collected by the hooks of a grammar extension
+// for the statement.
+func (s *Statement) ExtensionData() interface{} {
+	return s.extension
+}
+
 // AddGraph adds a graph to a given https://critique.corp.google.com/#review/101398527statement.
 func (s *Statement) AddGraph(g string) {
 	s.graphs = append(s.graphs, g)
 }
 
+// SetDefaultTimeAnchor sets the time anchor used to turn the immutable
+// predicates of the statement data into temporal ones.
+func (s *Statement) SetDefaultTimeAnchor(t *time.Time) {
+	s.anchor = t
+}
+
+// DefaultTimeAnchor returns the default time anchor for the statement data, or
+// nil if none was provided.
+func (s *Statement) DefaultTimeAnchor() *time.Time {
+	return s.anchor
+}
+
 // Graphs returns the list of graphs listed on the statement.
 func (s *Statement) Graphs() []string {
 	return s.graphs
@@ -211,12 +338,207 @@ func (s *Statement) WorkingClause() *GraphClause {
 // AddWorkingGrpahClause add the current working graph clause to the set of
 // clauses that form the graph pattern.
 func (s *Statement) AddWorkingGrpahClause() {
+	if n := len(s.graphScopes); n > 0 && s.workingClause != nil && !s.workingClause.IsEmpty() {
+		s.scopeToGraph(s.workingClause, s.graphScopes[n-1])
+	}
 	if s.workingClause != nil || !s.workingClause.IsEmpty() {
-		s.pattern = append(s.pattern, s.workingClause)
+		if s.inMinus {
+			last := len(s.minus) - 1
+			s.minus[last] = append(s.minus[last], s.workingClause)
+		} else {
+			s.pattern = append(s.pattern, s.workingClause)
+		}
 	}
 	s.ResetWorkingGraphClause()
 }
 
+// scopeToGraph restricts the clause to the provided graph if it is one of the
+// statement graphs. Otherwise, the graph is treated as a binding that will hold
+// the graph each matched triple comes from.
+func (s *Statement) scopeToGraph(cls *GraphClause, g string) {
+	for _, sg := range s.graphs {
+		if sg == g {
+			cls.Graph = g
+			return
+		}
+	}
+	cls.GBinding = g
+}
+
+// AddMinusPattern starts a new minus graph pattern. All graph clauses added
+// afterwards become part of it.
+func (s *Statement) AddMinusPattern() {
+	s.minus = append(s.minus, nil)
+	s.inMinus = true
+}
+
+// MinusPatterns returns the graph patterns, each one sorted by specificity,
+// whose matching solutions should be removed from the statement results.
+func (s *Statement) MinusPatterns() [][]*GraphClause {
+	var ptrns [][]*GraphClause
+	for _, m := range s.minus {
+		if cls := sortedClauses(m); len(cls) > 0 {
+			ptrns = append(ptrns, cls)
+		}
+	}
+	return ptrns
+}
+
+// AddGlobalTimeBounds narrows the global time bounds of the statement to the
+// provided ones. Nil bounds leave the current ones untouched.
+func (s *Statement) AddGlobalTimeBounds(lower, upper *time.Time) {
+	if lower != nil {
+		if s.lookupOptions.LowerAnchor == nil || lower.After(*s.lookupOptions.LowerAnchor) {
+			s.lookupOptions.LowerAnchor = lower
+		}
+	}
+	if upper != nil {
+		if s.lookupOptions.UpperAnchor == nil || upper.Before(*s.lookupOptions.UpperAnchor) {
+			s.lookupOptions.UpperAnchor = upper
+		}
+	}
+}
+
+// SetAsOf makes the statement query the state of the graphs at the provided
+// time. Only the temporal facts anchored at or before it are considered, and
+// only the latest of them for each subject and predicate ID hold. It also
+// narrows the upper global time bound of the statement to the provided time.
+func (s *Statement) SetAsOf(t time.Time) {
+	s.asOf = &t
+	s.AddGlobalTimeBounds(nil, &t)
+}
+
+// AsOf returns the time the statement queries the state of the graphs at, or
+// nil if it queries all the facts available.
+func (s *Statement) AsOf() *time.Time {
+	return s.asOf
+}
+
+// SetGranularity makes the statement treat the time anchors that fall within
+// the same period of the provided granularity as equal. It also sets the
+// granularity of the global lookup options of the statement.
+func (s *Statement) SetGranularity(g predicate.Granularity) {
+	s.granularity = g
+	s.lookupOptions.Granularity = g
+}
+
+// Granularity returns the granularity time anchors are matched at. Instant
+// granularity matches time anchors exactly.
+func (s *Statement) Granularity() predicate.Granularity {
+	return s.granularity
+}
+
+// GlobalLookupOptions returns a copy of the lookup options derived from the
+// global time bounds of the statement.
+func (s *Statement) GlobalLookupOptions() *storage.LookupOptions {
+	lo := s.lookupOptions
+	return &lo
+}
+
+// Projections returns the bindings listed on the select clause.
+func (s *Statement) Projections() []*Projection {
+	return s.projection
+}
+
+// GroupBy returns the bindings listed on the group by clause.
+func (s *Statement) GroupBy() []string {
+	return s.groupBy
+}
+
+// OrderBy returns the sort configuration listed on the order by clause.
+func (s *Statement) OrderBy() []table.SortConfig {
+	return s.orderBy
+}
+
+// IsLimitSet returns true if the statement provides a limit clause.
+func (s *Statement) IsLimitSet() bool {
+	return s.limitSet
+}
+
+// Limit returns the maximum number of rows requested.
+func (s *Statement) Limit() int64 {
+	return s.limit
+}
+
+// IsLimitPerGroup returns true if the limit applies to each group of rows
+// listed on the group by clause instead of to the whole result.
+func (s *Statement) IsLimitPerGroup() bool {
+	return s.limitPerGroup
+}
+
+// HavingEvaluator returns the evaluator for the having clause, or nil if the
+// statement does not provide one.
+func (s *Statement) HavingEvaluator() Evaluator {
+	return s.having
+}
+
+// SetDistinct marks the statement as only returning distinct rows.
+func (s *Statement) SetDistinct() {
+	s.distinct = true
+}
+
+// IsDistinct returns true if the statement only returns distinct rows.
+func (s *Statement) IsDistinct() bool {
+	return s.distinct
+}
+
+// SetView sets the name of the view defined by the statement.
+func (s *Statement) SetView(v string) {
+	s.view = v
+}
+
+// View returns the name of the view defined by the statement, if any.
+func (s *Statement) View() string {
+	return s.view
+}
+
+// Hints returns the query hints provided on the statement.
+func (s *Statement) Hints() *QueryHints {
+	return &s.hints
+}
+
+// AddPrefetchHints declares extra subjects and predicates that the statement
+// is expected to touch.
+func (s *Statement) AddPrefetchHints(h *storage.PrefetchHints) {
+	s.prefetchHints.Subjects = append(s.prefetchHints.Subjects, h.Subjects...)
+	s.prefetchHints.Predicates = append(s.prefetchHints.Predicates, h.Predicates...)
+}
+
+// PrefetchHints returns the declared prefetch hints together with the subjects
+// and predicates fully specified on the graph pattern clauses. Returned hints
+// contain no duplicates.
+func (s *Statement) PrefetchHints() *storage.PrefetchHints {
+	h := &storage.PrefetchHints{}
+	sSeen, pSeen := make(map[string]bool), make(map[string]bool)
+	addSubject := func(n *node.Node) {
+		if n == nil || sSeen[n.GUID()] {
+			return
+		}
+		sSeen[n.GUID()] = true
+		h.Subjects = append(h.Subjects, n)
+	}
+	addPredicate := func(p *predicate.Predicate) {
+		if p == nil || pSeen[p.GUID()] {
+			return
+		}
+		pSeen[p.GUID()] = true
+		h.Predicates = append(h.Predicates, p)
+	}
+	for _, n := range s.prefetchHints.Subjects {
+		addSubject(n)
+	}
+	for _, p := range s.prefetchHints.Predicates {
+		addPredicate(p)
+	}
+	for _, cls := range s.pattern {
+		if cls != nil {
+			addSubject(cls.S)
+			addPredicate(cls.P)
+		}
+	}
+	return h
+}
+
 // addtoBindings add the binding if not empty.
 func addToBindings(bs map[string]int, b string) {
 	if b != "" {
@@ -231,6 +553,7 @@ func (s *Statement) BindingsMap() map[string]int {
 
 	for _, cls := range s.pattern {
 		if cls != nil {
+			addToBindings(bm, cls.GBinding)
 			addToBindings(bm, cls.SBinding)
 			addToBindings(bm, cls.SAlias)
 			addToBindings(bm, cls.STypeAlias)
@@ -250,6 +573,11 @@ func (s *Statement) BindingsMap() map[string]int {
 			addToBindings(bm, cls.OAnchorBinding)
 			addToBindings(bm, cls.OLowerBoundAlias)
 			addToBindings(bm, cls.OUpperBoundAlias)
+			if cls.OEmbedded != nil {
+				addToBindings(bm, cls.OEmbedded.SBinding)
+				addToBindings(bm, cls.OEmbedded.PBinding)
+				addToBindings(bm, cls.OEmbedded.OBinding)
+			}
 		}
 	}
 	return bm
@@ -285,13 +613,30 @@ func (s bySpecificity) Less(i, j int) bool {
 
 // SortedGraphPatternClauses return the list of graph pattern clauses
 func (s *Statement) SortedGraphPatternClauses() []*GraphClause {
+	return sortedClauses(s.pattern)
+}
+
+// OrderedGraphPatternClauses returns the non empty graph pattern clauses in
+// the order they were written on the statement.
+func (s *Statement) OrderedGraphPatternClauses() []*GraphClause {
+	return nonEmptyClauses(s.pattern)
+}
+
+// sortedClauses returns the non empty provided clauses sorted by specificity.
+func sortedClauses(cs []*GraphClause) []*GraphClause {
+	ptrns := nonEmptyClauses(cs)
+	// Clauses with the same specificity keep the order they were written in.
+	sort.Stable(bySpecificity(ptrns))
+	return ptrns
+}
+
+// nonEmptyClauses returns the provided clauses without the empty ones.
+func nonEmptyClauses(cs []*GraphClause) []*GraphClause {
 	var ptrns []*GraphClause
-	// Filter empty clauses.
-	for _, cls := range s.pattern {
+	for _, cls := range cs {
 		if cls != nil && !cls.IsEmpty() {
 			ptrns = append(ptrns, cls)
 		}
 	}
-	sort.Sort(bySpecificity(ptrns))
 	return ptrns
 }
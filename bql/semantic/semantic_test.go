@@ -16,8 +16,11 @@ package semantic
 
 import (
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/google/badwolf/storage"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
@@ -54,6 +57,23 @@ func TestStatementAddData(t *testing.T) {
 	}
 }
 
+func TestStatementAddGlobalTimeBounds(t *testing.T) {
+	t0 := time.Now()
+	t1, t2, t3 := t0.Add(time.Hour), t0.Add(2*time.Hour), t0.Add(3*time.Hour)
+	st := &Statement{}
+	st.AddGlobalTimeBounds(&t0, &t3)
+	st.AddGlobalTimeBounds(&t1, nil)
+	st.AddGlobalTimeBounds(nil, &t2)
+	st.AddGlobalTimeBounds(&t0, &t3)
+	lo := st.GlobalLookupOptions()
+	if got, want := lo.LowerAnchor, &t1; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.AddGlobalTimeBounds returned the wrong lower anchor; got %v, want %v", got, want)
+	}
+	if got, want := lo.UpperAnchor, &t2; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.AddGlobalTimeBounds returned the wrong upper anchor; got %v, want %v", got, want)
+	}
+}
+
 func TestGraphClauseSpecificity(t *testing.T) {
 	table := []struct {
 		gc   *GraphClause
@@ -91,7 +111,7 @@ func TestBindingListing(t *testing.T) {
 	stm.ResetWorkingGraphClause()
 	for i := 0; i < 10; i++ {
 		wcls := stm.WorkingClause()
-		v := string(i)
+		v := strconv.Itoa(i)
 		cls := &GraphClause{
 			SBinding:         "?" + v,
 			SAlias:           "?" + v,
@@ -168,3 +188,60 @@ func TestSortedGraphPatternClauses(t *testing.T) {
 		spc--
 	}
 }
+
+func TestMinusPatterns(t *testing.T) {
+	s := &Statement{}
+	s.ResetWorkingGraphClause()
+	s.WorkingClause().SBinding = "?s"
+	s.AddWorkingGrpahClause()
+	s.AddMinusPattern()
+	s.WorkingClause().SBinding = "?s"
+	s.WorkingClause().OBinding = "?o"
+	s.AddWorkingGrpahClause()
+	s.AddWorkingGrpahClause()
+	s.AddMinusPattern()
+	if got, want := len(s.SortedGraphPatternClauses()), 1; got != want {
+		t.Errorf("statement.SortedGraphPatternClauses returned the wrong number of clauses; got %d, want %d", got, want)
+	}
+	mps := s.MinusPatterns()
+	if got, want := len(mps), 1; got != want {
+		t.Fatalf("statement.MinusPatterns returned the wrong number of patterns; got %d, want %d", got, want)
+	}
+	if got, want := len(mps[0]), 1; got != want {
+		t.Errorf("statement.MinusPatterns returned the wrong number of clauses; got %d, want %d", got, want)
+	}
+	if _, ok := s.BindingsMap()["?o"]; ok {
+		t.Errorf("statement.BindingsMap should not include bindings only used in minus patterns; got %v", s.BindingsMap())
+	}
+}
+
+func TestPrefetchHints(t *testing.T) {
+	n, err := node.Parse("/_<foo>")
+	if err != nil {
+		t.Fatalf("node.Parse failed with error %v", err)
+	}
+	p, err := predicate.Parse(`"bar"@[]`)
+	if err != nil {
+		t.Fatalf("predicate.Parse failed with error %v", err)
+	}
+	xn, err := node.Parse("/_<extra>")
+	if err != nil {
+		t.Fatalf("node.Parse failed with error %v", err)
+	}
+	st := &Statement{}
+	st.ResetWorkingGraphClause()
+	st.WorkingClause().S = n
+	st.WorkingClause().P = p
+	st.AddWorkingGrpahClause()
+	st.WorkingClause().S = n
+	st.WorkingClause().OBinding = "?o"
+	st.AddWorkingGrpahClause()
+	st.AddPrefetchHints(&storage.PrefetchHints{Subjects: []*node.Node{xn, n}})
+	h := st.PrefetchHints()
+	if got, want := h.Subjects, []*node.Node{xn, n}; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.PrefetchHints returned the wrong subjects; got %v, want %v", got, want)
+	}
+	if got, want := h.Predicates, []*predicate.Predicate{p}; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.PrefetchHints returned the wrong predicates; got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"strings"
+
+	"github.com/google/badwolf/bql/lexer"
+)
+
+// AddToken records a token consumed while parsing the statement.
+func (s *Statement) AddToken(tkn *lexer.Token) {
+	s.tokens = append(s.tokens, *tkn)
+}
+
+// Tokens returns the tokens consumed while parsing the statement.
+func (s *Statement) Tokens() []*lexer.Token {
+	var res []*lexer.Token
+	for i := range s.tokens {
+		res = append(res, &s.tokens[i])
+	}
+	return res
+}
+
+// String returns the BQL text of the parsed statement in a single line. The
+// text can be parsed again to obtain an equivalent statement.
+func (s *Statement) String() string {
+	var b strings.Builder
+	for i, tkn := range s.tokens {
+		if i > 0 && !noSpaceBefore(tkn.Type) && !noSpaceAfter(s.tokens[i-1].Type) {
+			b.WriteString(" ")
+		}
+		b.WriteString(tkn.Text)
+	}
+	return b.String()
+}
+
+// noSpaceBefore returns true if the token is written next to the previous one.
+func noSpaceBefore(tt lexer.TokenType) bool {
+	switch tt {
+	case lexer.ItemComma, lexer.ItemSemicolon, lexer.ItemRPar, lexer.ItemRTriple:
+		return true
+	}
+	return false
+}
+
+// noSpaceAfter returns true if the next token is written next to the token.
+func noSpaceAfter(tt lexer.TokenType) bool {
+	switch tt {
+	case lexer.ItemLPar, lexer.ItemLTriple, lexer.ItemCount, lexer.ItemSum, lexer.ItemCast, lexer.ItemStrLen, lexer.ItemHint:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationErrorKind identifies the check a statement failed to pass.
+type ValidationErrorKind int8
+
+const (
+	// UnboundProjection indicates that a binding listed on the select clause
+	// is not bound by the where clause.
+	UnboundProjection ValidationErrorKind = iota
+	// UnboundGroupBy indicates that a binding listed on the group by clause is
+	// neither bound by the where clause nor an alias of the select clause.
+	UnboundGroupBy
+	// UngroupedProjection indicates that a statement that aggregates bindings
+	// also selects a binding that is neither aggregated nor grouped.
+	UngroupedProjection
+	// AliasCollision indicates that an alias of the select clause is already
+	// bound by the where clause or by a previous alias.
+	AliasCollision
+)
+
+// String returns a readable name for the validation error kind.
+func (k ValidationErrorKind) String() string {
+	switch k {
+	case UnboundProjection:
+		return "UNBOUND_PROJECTION"
+	case UnboundGroupBy:
+		return "UNBOUND_GROUP_BY"
+	case UngroupedProjection:
+		return "UNGROUPED_PROJECTION"
+	case AliasCollision:
+		return "ALIAS_COLLISION"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ValidationError describes a semantic issue found on a parsed statement.
+type ValidationError struct {
+	// Kind contains the check that failed.
+	Kind ValidationErrorKind
+
+	// Binding contains the offending binding.
+	Binding string
+}
+
+// Error returns the description of the validation error.
+func (e *ValidationError) Error() string {
+	switch e.Kind {
+	case UnboundProjection:
+		return fmt.Sprintf("selected binding %s is not bound in the where clause", e.Binding)
+	case UnboundGroupBy:
+		return fmt.Sprintf("group by binding %s is not bound in the where clause nor selected as an alias", e.Binding)
+	case UngroupedProjection:
+		return fmt.Sprintf("selected binding %s must be aggregated or listed in the group by clause", e.Binding)
+	case AliasCollision:
+		return fmt.Sprintf("alias %s is already bound", e.Binding)
+	default:
+		return fmt.Sprintf("invalid binding %s", e.Binding)
+	}
+}
+
+// ValidationErrors contains all the issues found validating a statement.
+type ValidationErrors []*ValidationError
+
+// Error returns the description of all the validation errors.
+func (errs ValidationErrors) Error() string {
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return "semantic.Statement.Validate: " + strings.Join(msgs, "; ")
+}
+
+// Has returns true if any of the errors is of the provided kind.
+func (errs ValidationErrors) Has(k ValidationErrorKind) bool {
+	for _, err := range errs {
+		if err.Kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the bindings used by a query statement are consistent
+// once it has been fully parsed. It returns ValidationErrors listing all the
+// issues found, or nil if the statement is valid. Statements other than
+// queries are always valid.
+func (s *Statement) Validate() error {
+	if s.sType != Query {
+		return nil
+	}
+	var errs ValidationErrors
+	add := func(k ValidationErrorKind, b string) {
+		errs = append(errs, &ValidationError{Kind: k, Binding: b})
+	}
+	bm := s.BindingsMap()
+	aliases := make(map[string]bool)
+	aggregated := false
+	for _, prj := range s.projection {
+		if prj.Binding != "" && bm[prj.Binding] == 0 {
+			add(UnboundProjection, prj.Binding)
+		}
+		if prj.Alias != "" {
+			if bm[prj.Alias] > 0 || aliases[prj.Alias] {
+				add(AliasCollision, prj.Alias)
+			}
+			aliases[prj.Alias] = true
+		}
+		aggregated = aggregated || prj.Aggregate
+	}
+	grouped := make(map[string]bool)
+	for _, b := range s.groupBy {
+		grouped[b] = true
+		if bm[b] == 0 && !aliases[b] {
+			add(UnboundGroupBy, b)
+		}
+	}
+	if aggregated {
+		for _, prj := range s.projection {
+			if !prj.Aggregate && !grouped[prj.Binding] && (prj.Alias == "" || !grouped[prj.Alias]) {
+				add(UngroupedProjection, prj.Binding)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/bql/table"
+)
+
+func TestValidate(t *testing.T) {
+	pattern := []*GraphClause{{SBinding: "?s", PBinding: "?p", OBinding: "?o"}}
+	testTable := []struct {
+		prj     []*Projection
+		groupBy []string
+		want    ValidationErrors
+	}{
+		{
+			prj: []*Projection{{Binding: "?s"}, {Binding: "?o", Alias: "?x"}},
+		},
+		{
+			prj:     []*Projection{{Binding: "?s", Alias: "?x"}, {Binding: "?o", Aggregate: true, Kind: table.Count, Alias: "?n"}},
+			groupBy: []string{"?x"},
+		},
+		{
+			prj:  []*Projection{{Binding: "?s"}, {Binding: "?foo"}},
+			want: ValidationErrors{{Kind: UnboundProjection, Binding: "?foo"}},
+		},
+		{
+			prj:     []*Projection{{Binding: "?s"}},
+			groupBy: []string{"?s", "?bar"},
+			want:    ValidationErrors{{Kind: UnboundGroupBy, Binding: "?bar"}},
+		},
+		{
+			prj:  []*Projection{{Binding: "?s"}, {Binding: "?o", Aggregate: true, Kind: table.Sum}},
+			want: ValidationErrors{{Kind: UngroupedProjection, Binding: "?s"}},
+		},
+		{
+			prj: []*Projection{{Binding: "?s", Alias: "?p"}, {Binding: "?o", Alias: "?n"}, {Binding: "?o", Alias: "?n"}},
+			want: ValidationErrors{
+				{Kind: AliasCollision, Binding: "?p"},
+				{Kind: AliasCollision, Binding: "?n"},
+			},
+		},
+	}
+	for i, entry := range testTable {
+		st := &Statement{sType: Query, pattern: pattern, projection: entry.prj, groupBy: entry.groupBy}
+		err := st.Validate()
+		if entry.want == nil {
+			if err != nil {
+				t.Errorf("Statement.Validate failed for case %d with error %v", i, err)
+			}
+			continue
+		}
+		if got, ok := err.(ValidationErrors); !ok || !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("Statement.Validate returned %v for case %d; want %v", err, i, entry.want)
+		}
+	}
+}
+
+func TestValidateIgnoresNonQueries(t *testing.T) {
+	st := &Statement{sType: Insert, projection: []*Projection{{Binding: "?foo"}}}
+	if err := st.Validate(); err != nil {
+		t.Errorf("Statement.Validate should not validate non query statements; got %v", err)
+	}
+}
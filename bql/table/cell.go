@@ -0,0 +1,97 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+)
+
+// literalOf returns the literal of the cell if it holds one of the requested
+// type.
+func (c *Cell) literalOf(t literal.Type) (*literal.Literal, error) {
+	if c.IsNull() {
+		return nil, fmt.Errorf("cannot get a %v value out of a null cell", t)
+	}
+	if c.L == nil || c.L.Type() != t {
+		return nil, fmt.Errorf("cell %s does not hold a %v literal", c, t)
+	}
+	return c.L, nil
+}
+
+// Int64 returns the value of the cell if it holds an int64 literal.
+func (c *Cell) Int64() (int64, error) {
+	l, err := c.literalOf(literal.Int64)
+	if err != nil {
+		return 0, err
+	}
+	return l.Int64()
+}
+
+// Float64 returns the value of the cell if it holds a float64 literal.
+func (c *Cell) Float64() (float64, error) {
+	l, err := c.literalOf(literal.Float64)
+	if err != nil {
+		return 0, err
+	}
+	return l.Float64()
+}
+
+// Bool returns the value of the cell if it holds a bool literal.
+func (c *Cell) Bool() (bool, error) {
+	l, err := c.literalOf(literal.Bool)
+	if err != nil {
+		return false, err
+	}
+	return l.Bool()
+}
+
+// Text returns the value of the cell if it holds a text literal or a string,
+// such as the ones used for node IDs or counts.
+func (c *Cell) Text() (string, error) {
+	if !c.IsNull() && c.S != "" {
+		return c.S, nil
+	}
+	l, err := c.literalOf(literal.Text)
+	if err != nil {
+		return "", err
+	}
+	return l.Text()
+}
+
+// Time returns the value of the cell if it holds a time anchor.
+func (c *Cell) Time() (time.Time, error) {
+	if c.IsNull() {
+		return time.Time{}, fmt.Errorf("cannot get a time anchor out of a null cell")
+	}
+	if c.S != "" || c.T == nil {
+		return time.Time{}, fmt.Errorf("cell %s does not hold a time anchor", c)
+	}
+	return *c.T, nil
+}
+
+// NodeValue returns the node held by the cell.
+func (c *Cell) NodeValue() (*node.Node, error) {
+	if c.IsNull() {
+		return nil, fmt.Errorf("cannot get a node out of a null cell")
+	}
+	if c.S != "" || c.N == nil {
+		return nil, fmt.Errorf("cell %s does not hold a node", c)
+	}
+	return c.N, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+)
+
+func TestCellAccessors(t *testing.T) {
+	b := literal.DefaultBuilder()
+	lc := func(s string) *Cell {
+		l, err := b.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Cell{L: l}
+	}
+	n := node.NewBlankNode()
+	ts := time.Date(2015, 7, 19, 13, 12, 4, 0, time.UTC)
+	cells := map[string]*Cell{
+		"int64":   lc(`"5"^^type:int64`),
+		"float64": lc(`"2.5"^^type:float64`),
+		"bool":    lc(`"true"^^type:bool`),
+		"text":    lc(`"héllo"^^type:text`),
+		"string":  {S: "7"},
+		"time":    {T: &ts},
+		"node":    {N: n},
+		"null":    NullCell(),
+	}
+	accessors := map[string]func(*Cell) (interface{}, error){
+		"int64":   func(c *Cell) (interface{}, error) { return c.Int64() },
+		"float64": func(c *Cell) (interface{}, error) { return c.Float64() },
+		"bool":    func(c *Cell) (interface{}, error) { return c.Bool() },
+		"text":    func(c *Cell) (interface{}, error) { return c.Text() },
+		"time":    func(c *Cell) (interface{}, error) { return c.Time() },
+		"node":    func(c *Cell) (interface{}, error) { return c.NodeValue() },
+	}
+	want := map[string]map[string]interface{}{
+		"int64":   {"int64": int64(5)},
+		"float64": {"float64": 2.5},
+		"bool":    {"bool": true},
+		"text":    {"text": "héllo", "string": "7"},
+		"time":    {"time": ts},
+		"node":    {"node": n},
+	}
+	for an, a := range accessors {
+		for cn, c := range cells {
+			got, err := a(c)
+			w, ok := want[an][cn]
+			if !ok {
+				if err == nil {
+					t.Errorf("Cell.%s should have failed for the %s cell; got %v", an, cn, got)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("Cell.%s failed for the %s cell with error %v", an, cn, err)
+				continue
+			}
+			if got != w {
+				t.Errorf("Cell.%s returned the wrong value for the %s cell; got %v, want %v", an, cn, got, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// maxEncodedLen bounds the length of encoded strings and blobs, so corrupted
+// inputs do not trigger huge allocations.
+const maxEncodedLen = 1 << 30
+
+// Kinds of the cells on the binary row encoding.
+const (
+	kindString byte = iota + 1
+	kindNode
+	kindPredicate
+	kindLiteral
+	kindTime
+	kindTriple
+)
+
+// rowEncoder writes rows using a compact binary encoding. Each row is written
+// as the number of its non null cells followed by each binding and cell.
+// Bindings are written by name the first time they are used, and by the
+// position they were first written at afterwards.
+type rowEncoder struct {
+	w   *bufio.Writer
+	ids map[string]uint64
+	buf [binary.MaxVarintLen64]byte
+}
+
+// newRowEncoder returns an encoder writing to the provided writer.
+func newRowEncoder(w *bufio.Writer) *rowEncoder {
+	return &rowEncoder{w: w, ids: make(map[string]uint64)}
+}
+
+func (e *rowEncoder) uvarint(v uint64) {
+	e.w.Write(e.buf[:binary.PutUvarint(e.buf[:], v)])
+}
+
+func (e *rowEncoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.w.Write(b)
+}
+
+func (e *rowEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.w.WriteString(s)
+}
+
+// encode writes the provided row.
+func (e *rowEncoder) encode(r Row) error {
+	cnt := 0
+	for _, c := range r {
+		if !c.IsNull() {
+			cnt++
+		}
+	}
+	e.uvarint(uint64(cnt))
+	for b, c := range r {
+		if c.IsNull() {
+			continue
+		}
+		id, ok := e.ids[b]
+		if !ok {
+			id = uint64(len(e.ids))
+			e.ids[b] = id
+		}
+		e.uvarint(id)
+		if !ok {
+			e.string(b)
+		}
+		if err := e.cell(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cell writes the kind and the binary encoding of the provided cell.
+func (e *rowEncoder) cell(c *Cell) error {
+	var (
+		k byte
+		m encoding.BinaryMarshaler
+	)
+	switch {
+	case c.S != "":
+		e.w.WriteByte(kindString)
+		e.string(c.S)
+		return nil
+	case c.N != nil:
+		k, m = kindNode, c.N
+	case c.P != nil:
+		k, m = kindPredicate, c.P
+	case c.L != nil:
+		k, m = kindLiteral, c.L
+	case c.T != nil:
+		k, m = kindTime, c.T
+	case c.E != nil:
+		k, m = kindTriple, c.E
+	default:
+		return nil
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	e.w.WriteByte(k)
+	e.bytes(b)
+	return nil
+}
+
+// rowDecoder reads the rows written by a rowEncoder.
+type rowDecoder struct {
+	r     *bufio.Reader
+	names []string
+}
+
+// newRowDecoder returns a decoder reading from the provided reader.
+func newRowDecoder(r *bufio.Reader) *rowDecoder {
+	return &rowDecoder{r: r}
+}
+
+func (d *rowDecoder) bytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxEncodedLen {
+		return nil, fmt.Errorf("invalid encoded length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (d *rowDecoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+// decode returns the next row, or nil if there are no more rows to read.
+func (d *rowDecoder) decode() (Row, error) {
+	cnt, err := binary.ReadUvarint(d.r)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := make(Row, cnt)
+	for i := uint64(0); i < cnt; i++ {
+		id, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch {
+		case id == uint64(len(d.names)):
+			b, err := d.string()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			d.names = append(d.names, b)
+		case id > uint64(len(d.names)):
+			return nil, fmt.Errorf("invalid encoded binding %d", id)
+		}
+		c, err := d.cell()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		r[d.names[id]] = c
+	}
+	return r, nil
+}
+
+// cell reads the kind and the binary encoding of the next cell.
+func (d *rowDecoder) cell() (*Cell, error) {
+	k, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.bytes()
+	if err != nil {
+		return nil, err
+	}
+	c := &Cell{}
+	var u encoding.BinaryUnmarshaler
+	switch k {
+	case kindString:
+		c.S = string(b)
+		return c, nil
+	case kindNode:
+		c.N = &node.Node{}
+		u = c.N
+	case kindPredicate:
+		c.P = &predicate.Predicate{}
+		u = c.P
+	case kindLiteral:
+		c.L = &literal.Literal{}
+		u = c.L
+	case kindTime:
+		c.T = &time.Time{}
+		u = c.T
+	case kindTriple:
+		c.E = &triple.Triple{}
+		u = c.E
+	default:
+		return nil, fmt.Errorf("invalid encoded cell kind %d", k)
+	}
+	if err := u.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// unexpectedEOF turns the end of the input found in the middle of a row into
+// an error.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
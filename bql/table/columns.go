@@ -0,0 +1,352 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// Tables store their rows by columns. Each binding keeps a column with the
+// values of all the rows in typed slices, avoiding the overhead of one map and
+// one cell per row. Columns are sparse: they only grow up to the last row
+// holding a value for the binding, and rows beyond their length hold null
+// cells. Cells and row maps are only built when requested, and they are copies
+// of the stored values.
+
+// cellFields records which fields of a cell hold a value.
+type cellFields uint8
+
+const (
+	hasS cellFields = 1 << iota
+	hasN
+	hasP
+	hasL
+	hasT
+	hasE
+)
+
+// column holds the values bound to a binding for the rows of a table. Each
+// field of the cells is kept on its own slice indexed by row, which is only
+// allocated once a cell holding that field is stored, so columns holding a
+// single type of value only grow the slice of that type. fs records the
+// fields held by each row; rows holding none are null.
+type column struct {
+	fs []cellFields
+	s  []string
+	n  []*node.Node
+	p  []*predicate.Predicate
+	l  []*literal.Literal
+	t  []time.Time
+	e  []*triple.Triple
+}
+
+// len returns the number of rows of the column, including the null ones.
+func (c *column) len() int {
+	return len(c.fs)
+}
+
+// isNull returns true if row i holds no value.
+func (c *column) isNull(i int) bool {
+	return i < 0 || i >= len(c.fs) || c.fs[i] == 0
+}
+
+// value returns the cell stored for row i, which must not be null. Its time
+// anchor, if any, points into the column, so the cell must not escape the
+// table; use cell instead.
+func (c *column) value(i int) Cell {
+	var v Cell
+	f := c.fs[i]
+	if f&hasS != 0 {
+		v.S = c.s[i]
+	}
+	if f&hasN != 0 {
+		v.N = c.n[i]
+	}
+	if f&hasP != 0 {
+		v.P = c.p[i]
+	}
+	if f&hasL != 0 {
+		v.L = c.l[i]
+	}
+	if f&hasT != 0 {
+		v.T = &c.t[i]
+	}
+	if f&hasE != 0 {
+		v.E = c.e[i]
+	}
+	return v
+}
+
+// cell returns a copy of the cell stored for row i, or nil if it is null.
+func (c *column) cell(i int) *Cell {
+	if c.isNull(i) {
+		return nil
+	}
+	v := c.value(i)
+	if v.T != nil {
+		t := *v.T
+		v.T = &t
+	}
+	return &v
+}
+
+// add stores the provided cell as the value of row i, padding the column with
+// null rows as needed. Rows can only be added after the last one.
+func (c *column) add(i int, v *Cell) {
+	for len(c.fs) < i {
+		c.fs = append(c.fs, 0)
+	}
+	var f cellFields
+	if v.S != "" {
+		f |= hasS
+		for len(c.s) < i {
+			c.s = append(c.s, "")
+		}
+		c.s = append(c.s, v.S)
+	}
+	if v.N != nil {
+		f |= hasN
+		for len(c.n) < i {
+			c.n = append(c.n, nil)
+		}
+		c.n = append(c.n, v.N)
+	}
+	if v.P != nil {
+		f |= hasP
+		for len(c.p) < i {
+			c.p = append(c.p, nil)
+		}
+		c.p = append(c.p, v.P)
+	}
+	if v.L != nil {
+		f |= hasL
+		for len(c.l) < i {
+			c.l = append(c.l, nil)
+		}
+		c.l = append(c.l, v.L)
+	}
+	if v.T != nil {
+		f |= hasT
+		for len(c.t) < i {
+			c.t = append(c.t, time.Time{})
+		}
+		c.t = append(c.t, *v.T)
+	}
+	if v.E != nil {
+		f |= hasE
+		for len(c.e) < i {
+			c.e = append(c.e, nil)
+		}
+		c.e = append(c.e, v.E)
+	}
+	c.fs = append(c.fs, f)
+}
+
+// addFrom stores the value of row j of the provided column as the value of
+// row i, as add does. Null values are not stored.
+func (c *column) addFrom(i int, src *column, j int) {
+	if src.isNull(j) {
+		return
+	}
+	v := src.value(j)
+	c.add(i, &v)
+}
+
+// delete removes row i from the column.
+func (c *column) delete(i int) {
+	if i >= len(c.fs) {
+		return
+	}
+	c.fs = append(c.fs[:i], c.fs[i+1:]...)
+	if i < len(c.s) {
+		c.s = append(c.s[:i], c.s[i+1:]...)
+	}
+	if i < len(c.n) {
+		c.n = append(c.n[:i], c.n[i+1:]...)
+	}
+	if i < len(c.p) {
+		c.p = append(c.p[:i], c.p[i+1:]...)
+	}
+	if i < len(c.l) {
+		c.l = append(c.l[:i], c.l[i+1:]...)
+	}
+	if i < len(c.t) {
+		c.t = append(c.t[:i], c.t[i+1:]...)
+	}
+	if i < len(c.e) {
+		c.e = append(c.e[:i], c.e[i+1:]...)
+	}
+}
+
+// truncate keeps at most the first n rows of the column.
+func (c *column) truncate(n int) {
+	if len(c.fs) > n {
+		c.fs = c.fs[:n]
+	}
+	if len(c.s) > n {
+		c.s = c.s[:n]
+	}
+	if len(c.n) > n {
+		c.n = c.n[:n]
+	}
+	if len(c.p) > n {
+		c.p = c.p[:n]
+	}
+	if len(c.l) > n {
+		c.l = c.l[:n]
+	}
+	if len(c.t) > n {
+		c.t = c.t[:n]
+	}
+	if len(c.e) > n {
+		c.e = c.e[:n]
+	}
+}
+
+// cells provides the cells of a row by binding.
+type cells interface {
+	Cell(b string) *Cell
+}
+
+// Cell returns the cell bound to the provided binding, or nil if the row does
+// not have it.
+func (r Row) Cell(b string) *Cell {
+	return r[b]
+}
+
+// RowView provides access to the cells of a table row without building its
+// Row map.
+type RowView struct {
+	t *Table
+	i int
+}
+
+// View returns a view of the row at position i. Rows start at 0.
+func (t *Table) View(i int) RowView {
+	t.load()
+	return RowView{t: t, i: i}
+}
+
+// Cell returns a copy of the cell bound to the provided binding, or nil if it
+// is null.
+func (v RowView) Cell(b string) *Cell {
+	return v.t.cell(v.i, b)
+}
+
+// Row returns the Row map for the view.
+func (v RowView) Row() Row {
+	return v.t.row(v.i)
+}
+
+// cell returns a copy of the cell of row i bound to the provided binding, or
+// nil if it is null.
+func (t *Table) cell(i int, b string) *Cell {
+	col, ok := t.cols[b]
+	if !ok {
+		return nil
+	}
+	return col.cell(i)
+}
+
+// row builds the Row map for the row at position i.
+func (t *Table) row(i int) Row {
+	r := make(Row, len(t.bs))
+	for b, col := range t.cols {
+		if c := col.cell(i); c != nil {
+			r[b] = c
+		}
+	}
+	return r
+}
+
+// rows builds the Row maps for all the rows of the table.
+func (t *Table) rows() []Row {
+	rs := make([]Row, t.n)
+	for i := range rs {
+		rs[i] = t.row(i)
+	}
+	return rs
+}
+
+// reset removes all the rows from the table.
+func (t *Table) reset() {
+	t.cols = make(map[string]*column)
+	t.n = 0
+}
+
+// appendCell adds the provided cell to the column of the binding as the value
+// for row i, padding the column with null cells as needed.
+func (t *Table) appendCell(b string, i int, c *Cell) {
+	col, ok := t.cols[b]
+	if !ok {
+		col = &column{}
+		t.cols[b] = col
+	}
+	col.add(i, c)
+}
+
+// selectRows keeps only the rows at the provided positions, in the provided
+// order.
+func (t *Table) selectRows(idx []int) {
+	cols := make(map[string]*column, len(t.cols))
+	for b, col := range t.cols {
+		nc := &column{}
+		for k, i := range idx {
+			nc.addFrom(k, col, i)
+		}
+		if nc.len() > 0 {
+			cols[b] = nc
+		}
+	}
+	t.cols, t.n = cols, len(idx)
+}
+
+// joinRows replaces the rows of the table with the merge of the pairs of rows
+// of both tables at the provided positions. As MergeRows does, the cells of
+// the provided table override the ones of the current one unless they are
+// null. Negative positions stand for rows with only null cells.
+func (t *Table) joinRows(t2 *Table, pairs [][2]int) {
+	cols := make(map[string]*column, len(t.cols)+len(t2.cols))
+	add := func(b string) {
+		if _, ok := cols[b]; ok {
+			return
+		}
+		c1, c2 := t.cols[b], t2.cols[b]
+		nc := &column{}
+		for k, p := range pairs {
+			switch {
+			case c2 != nil && !c2.isNull(p[1]):
+				nc.addFrom(k, c2, p[1])
+			case c1 != nil && !c1.isNull(p[0]):
+				nc.addFrom(k, c1, p[0])
+			}
+		}
+		if nc.len() > 0 {
+			cols[b] = nc
+		}
+	}
+	for b := range t.cols {
+		add(b)
+	}
+	for b := range t2.cols {
+		add(b)
+	}
+	t.cols, t.n = cols, len(pairs)
+}
@@ -0,0 +1,249 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+func TestColumnarRows(t *testing.T) {
+	tbl, err := New([]string{"?s", "?o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?s": &Cell{S: "a"}})
+	tbl.AddRow(Row{"?s": &Cell{S: "b"}, "?o": NullCell()})
+	tbl.AddRow(Row{"?s": &Cell{S: "c"}, "?o": &Cell{S: "x"}, "?g": &Cell{S: "g"}})
+	want := []Row{
+		{"?s": &Cell{S: "a"}},
+		{"?s": &Cell{S: "b"}},
+		{"?s": &Cell{S: "c"}, "?o": &Cell{S: "x"}, "?g": &Cell{S: "g"}},
+	}
+	if got := tbl.Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Rows returned the wrong rows; got %v, want %v", got, want)
+	}
+	for i, r := range want {
+		v := tbl.View(i)
+		for _, b := range []string{"?s", "?o", "?g"} {
+			if got, want := v.Cell(b), r[b]; !reflect.DeepEqual(got, want) {
+				t.Errorf("RowView(%d).Cell(%q) returned the wrong cell; got %v, want %v", i, b, got, want)
+			}
+		}
+		if got := v.Row(); !reflect.DeepEqual(got, r) {
+			t.Errorf("RowView(%d).Row returned the wrong row; got %v, want %v", i, got, r)
+		}
+	}
+	// Changing the built rows does not change the table.
+	r, _ := tbl.Row(0)
+	r["?o"] = &Cell{S: "y"}
+	if c := tbl.View(0).Cell("?o"); c != nil {
+		t.Errorf("table.Row returned a row sharing the table storage; got %v", c)
+	}
+	// Nor does changing the cells returned.
+	r["?s"].S = "z"
+	tbl.View(1).Cell("?s").S = "z"
+	for i, want := range []string{"a", "b"} {
+		if got := tbl.View(i).Cell("?s").S; got != want {
+			t.Errorf("table returned a cell sharing the table storage; got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestColumnarTypedValues(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.Parse(`"knows"@[]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := literal.DefaultBuilder().Build(literal.Int64, int64(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := triple.New(n, p, triple.NewNodeObject(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	tbl, err := New([]string{"?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{"?v": &Cell{N: n}},
+		{"?v": &Cell{S: "foo"}},
+		{},
+		{"?v": &Cell{P: p}},
+		{"?v": &Cell{L: l}},
+		{"?v": &Cell{T: &ts}},
+		{"?v": &Cell{E: tr}},
+		{"?v": &Cell{S: "bar", N: n}},
+	}
+	for _, r := range want {
+		tbl.AddRow(r)
+	}
+	if got := tbl.Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Rows returned the wrong rows; got %v, want %v", got, want)
+	}
+	// Time anchors are copied too.
+	*tbl.View(5).Cell("?v").T = ts.Add(time.Hour)
+	if got := tbl.View(5).Cell("?v").T; !got.Equal(ts) {
+		t.Errorf("table returned a time anchor sharing the table storage; got %v, want %v", got, ts)
+	}
+	if err := tbl.DeleteRow(1); err != nil {
+		t.Fatal(err)
+	}
+	tbl.Limit(5)
+	if got, want := tbl.Rows(), append(append([]Row{}, want[:1]...), want[2:6]...); !reflect.DeepEqual(got, want) {
+		t.Errorf("table returned the wrong rows after deleting and limiting them; got %v, want %v", got, want)
+	}
+}
+
+func TestColumnarRowsAreStable(t *testing.T) {
+	tbl, err := New([]string{"?s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		tbl.AddRow(Row{"?s": &Cell{S: s}})
+	}
+	rws := tbl.Rows()
+	if err := tbl.DeleteRow(0); err != nil {
+		t.Fatal(err)
+	}
+	tbl.Limit(1)
+	tbl.AddRow(Row{"?s": &Cell{S: "d"}})
+	var got []string
+	for _, r := range rws {
+		got = append(got, r["?s"].S)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("table changes altered the rows already built; got %v, want %v", got, want)
+	}
+	got = nil
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?s"].S)
+	}
+	if want := []string{"b", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("table returned the wrong rows; got %v, want %v", got, want)
+	}
+}
+
+// benchmarkSize is the number of rows of the tables used by benchmarks.
+const benchmarkSize = 100000
+
+// benchmarkRows returns rows binding a node, a predicate, and a literal, as
+// the rows retrieved for graph pattern clauses do.
+func benchmarkRows(b *testing.B) []Row {
+	p, err := predicate.Parse(`"follows"@[]`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var rs []Row
+	for i := 0; i < benchmarkSize; i++ {
+		n, err := node.Parse(fmt.Sprintf("/u<%d>", i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		l, err := literal.DefaultBuilder().Build(literal.Int64, int64(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		rs = append(rs, Row{"?s": &Cell{N: n}, "?p": &Cell{P: p}, "?o": &Cell{L: l}})
+	}
+	return rs
+}
+
+// heapObjects returns the number of objects alive on the heap.
+func heapObjects() uint64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	return ms.HeapObjects
+}
+
+// benchmarkTable measures adding the provided rows to a new table holder and
+// then reading the cells of every row, which is how intermediate results are
+// used. Besides the allocations, it reports the objects the holder keeps alive,
+// which the garbage collector needs to scan as long as the results are used.
+func benchmarkTable(b *testing.B, add func(Row), scan func(func(cells)), reset func()) {
+	rs := benchmarkRows(b)
+	bs := []string{"?s", "?p", "?o"}
+	var objs uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reset()
+		b.StopTimer()
+		before := heapObjects()
+		b.StartTimer()
+		for _, r := range rs {
+			add(r)
+		}
+		b.StopTimer()
+		objs += heapObjects() - before
+		b.StartTimer()
+		scan(func(r cells) {
+			for _, bnd := range bs {
+				r.Cell(bnd)
+			}
+		})
+	}
+	b.ReportMetric(float64(objs)/float64(b.N), "objects/op")
+}
+
+func BenchmarkColumnarTable(b *testing.B) {
+	var tbl *Table
+	benchmarkTable(b, func(r Row) {
+		tbl.AddRow(r)
+	}, func(f func(cells)) {
+		for i := 0; i < tbl.NumRows(); i++ {
+			f(tbl.View(i))
+		}
+	}, func() {
+		tbl, _ = New([]string{"?s", "?p", "?o"})
+	})
+}
+
+// BenchmarkRowMaps measures holding the same rows as a slice of Row maps with
+// their own cells, as tables did before storing their rows by columns.
+func BenchmarkRowMaps(b *testing.B) {
+	var rws []Row
+	benchmarkTable(b, func(r Row) {
+		m := make(Row, len(r))
+		for bnd, c := range r {
+			cc := *c
+			m[bnd] = &cc
+		}
+		rws = append(rws, m)
+	}, func(f func(cells)) {
+		for _, r := range rws {
+			f(r)
+		}
+	}, func() {
+		rws = nil
+	})
+}
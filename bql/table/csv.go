@@ -0,0 +1,97 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+// CSVOptions configures how tables are encoded as comma or tab separated
+// values.
+type CSVOptions struct {
+	// Comma is the field separator. It defaults to a comma.
+	Comma rune
+	// NoHeader suppresses the first record containing the table bindings.
+	NoHeader bool
+	// TimeFormat is the layout used to format time anchor cells. It defaults
+	// to time.RFC3339Nano.
+	TimeFormat string
+	// TypedHeader appends the declared type of each binding to its name on
+	// the header, as in ?age:int64. Bindings without a declared type are
+	// left as is.
+	TypedHeader bool
+}
+
+// ToCSV writes the table to the provided writer as comma separated values.
+// Unlike ToText, fields containing separators, quotes, or line breaks are
+// properly quoted, and null cells are written as empty fields.
+func (t *Table) ToCSV(w io.Writer, opts CSVOptions) error {
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	tf := opts.TimeFormat
+	if tf == "" {
+		tf = time.RFC3339Nano
+	}
+	if !opts.NoHeader {
+		hdr := t.bs
+		if opts.TypedHeader {
+			hdr = make([]string, len(t.bs))
+			for i, b := range t.bs {
+				hdr[i] = b
+				if ct := t.Type(b); ct != AnyType {
+					hdr[i] = b + ":" + ct.String()
+				}
+			}
+		}
+		if err := cw.Write(hdr); err != nil {
+			return err
+		}
+	}
+	rec := make([]string, len(t.bs))
+	err := t.walk(-1, func(r cells) error {
+		for i, b := range t.bs {
+			rec[i] = csvField(r.Cell(b), tf)
+		}
+		return cw.Write(rec)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ToTSV writes the table to the provided writer as tab separated values. It
+// behaves as ToCSV, ignoring the provided separator.
+func (t *Table) ToTSV(w io.Writer, opts CSVOptions) error {
+	opts.Comma = '\t'
+	return t.ToCSV(w, opts)
+}
+
+// csvField returns the textual value of the cell formatting time anchors with
+// the provided layout.
+func csvField(c *Cell, tf string) string {
+	switch {
+	case c.IsNull():
+		return ""
+	case c.S == "" && c.T != nil:
+		return c.T.Format(tf)
+	}
+	return c.String()
+}
@@ -0,0 +1,84 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestToCSV(t *testing.T) {
+	ts := time.Date(2015, 7, 19, 13, 12, 4, 0, time.UTC)
+	tbl, err := New([]string{"?s", "?t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?s": &Cell{S: "plain"}, "?t": &Cell{T: &ts}})
+	tbl.AddRow(Row{"?s": &Cell{S: "a,b\t\"c\"\nd"}, "?t": NullCell()})
+	tbl.AddRow(Row{"?s": &Cell{S: "missing"}})
+	testTable := []struct {
+		id   string
+		tsv  bool
+		opts CSVOptions
+		want string
+	}{
+		{
+			id:   "csv",
+			want: "?s,?t\nplain,2015-07-19T13:12:04Z\n\"a,b\t\"\"c\"\"\nd\",\nmissing,\n",
+		},
+		{
+			id:   "no header",
+			opts: CSVOptions{NoHeader: true, TimeFormat: "2006-01-02"},
+			want: "plain,2015-07-19\n\"a,b\t\"\"c\"\"\nd\",\nmissing,\n",
+		},
+		{
+			id:   "semicolon",
+			opts: CSVOptions{Comma: ';'},
+			want: "?s;?t\nplain;2015-07-19T13:12:04Z\n\"a,b\t\"\"c\"\"\nd\";\nmissing;\n",
+		},
+		{
+			id:   "tsv",
+			tsv:  true,
+			opts: CSVOptions{Comma: ';'},
+			want: "?s\t?t\nplain\t2015-07-19T13:12:04Z\n\"a,b\t\"\"c\"\"\nd\"\t\nmissing\t\n",
+		},
+	}
+	for _, entry := range testTable {
+		var b bytes.Buffer
+		f := tbl.ToCSV
+		if entry.tsv {
+			f = tbl.ToTSV
+		}
+		if err := f(&b, entry.opts); err != nil {
+			t.Errorf("%s: table encoding failed with error %v", entry.id, err)
+			continue
+		}
+		if got := b.String(); got != entry.want {
+			t.Errorf("%s: table encoding returned the wrong output; got %q, want %q", entry.id, got, entry.want)
+		}
+	}
+}
+
+func TestToCSVErrors(t *testing.T) {
+	tbl, err := New([]string{"?s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := tbl.ToCSV(&b, CSVOptions{Comma: '"'}); err == nil {
+		t.Errorf("table.ToCSV should have rejected an invalid separator")
+	}
+}
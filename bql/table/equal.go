@@ -0,0 +1,100 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// Equal returns true if both cells hold the same value. Null cells are only
+// equal to other null cells. Cells of different kinds are never equal, not
+// even if they print the same. Time anchors, including the ones of temporal
+// predicates, are equal if they stand for the same instant regardless of their
+// location.
+func (c *Cell) Equal(o *Cell) bool {
+	if c.IsNull() || o.IsNull() {
+		return c.IsNull() && o.IsNull()
+	}
+	if c == o {
+		return true
+	}
+	return cellRank(c) == cellRank(o) && canonicalText(c) == canonicalText(o)
+}
+
+// Hash returns a hash of the value of the cell. Equal cells have the same
+// hash.
+func (c *Cell) Hash() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(cellKey(c)))
+	return h.Sum64()
+}
+
+// Equal returns true if both rows hold equal cells for the same bindings.
+// Null cells are the same as missing ones.
+func (r Row) Equal(o Row) bool {
+	for b, c := range r {
+		if !c.IsNull() && !c.Equal(o[b]) {
+			return false
+		}
+	}
+	for b, c := range o {
+		if !c.IsNull() && r[b].IsNull() {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a hash of the bindings and values of the row. Equal rows have
+// the same hash.
+func (r Row) Hash() uint64 {
+	var bs []string
+	for b, c := range r {
+		if !c.IsNull() {
+			bs = append(bs, b)
+		}
+	}
+	sort.Strings(bs)
+	h := fnv.New64a()
+	for _, b := range bs {
+		fmt.Fprintf(h, "%d:%s", len(b), b)
+		h.Write([]byte(cellKey(r[b])))
+	}
+	return h.Sum64()
+}
+
+// canonicalText returns the textual value of the cell with all its time
+// anchors expressed in UTC, so cells of the same kind holding equal values
+// share the same text.
+func canonicalText(c *Cell) string {
+	switch {
+	case c.S != "":
+		return c.S
+	case c.N != nil:
+		return c.N.String()
+	case c.P != nil:
+		return c.P.CanonicalString()
+	case c.L != nil:
+		return c.L.String()
+	case c.T != nil:
+		return c.T.UTC().Format(time.RFC3339Nano)
+	case c.E != nil:
+		return c.E.CanonicalString()
+	}
+	return ""
+}
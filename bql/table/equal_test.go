@@ -0,0 +1,141 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+func TestCellEqual(t *testing.T) {
+	b := literal.DefaultBuilder()
+	lc := func(s string) *Cell {
+		l, err := b.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Cell{L: l}
+	}
+	nc := func(s string) *Cell {
+		n, err := node.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Cell{N: n}
+	}
+	ts := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	other := ts.In(time.FixedZone("PST", -8*3600))
+	later := ts.Add(time.Second)
+	pc := func(id string, ta *time.Time) *Cell {
+		if ta == nil {
+			p, err := predicate.NewImmutable(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return &Cell{P: p}
+		}
+		p, err := predicate.NewTemporal(id, *ta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Cell{P: p}
+	}
+	tc := func(ta time.Time) *Cell {
+		s, err := node.Parse("/u<a>")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := predicate.NewTemporal("knows", ta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, err := triple.New(s, p, triple.NewNodeObject(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Cell{E: tr}
+	}
+	testTable := []struct {
+		c1, c2 *Cell
+		want   bool
+	}{
+		{nil, nil, true},
+		{nil, NullCell(), true},
+		{NullCell(), &Cell{S: "a"}, false},
+		{&Cell{S: "a"}, &Cell{S: "a"}, true},
+		{&Cell{S: "a"}, &Cell{S: "b"}, false},
+		{&Cell{S: "a"}, lc(`"a"^^type:text`), false},
+		{lc(`"a"^^type:text`), lc(`"a"^^type:text`), true},
+		{lc(`"1"^^type:int64`), lc(`"1"^^type:float64`), false},
+		{lc(`"1"^^type:int64`), lc(`"1"^^type:int64`), true},
+		{nc("/u<a>"), nc("/u<a>"), true},
+		{nc("/u<a>"), nc("/v<a>"), false},
+		{pc("p", nil), pc("p", nil), true},
+		{pc("p", nil), pc("p", &ts), false},
+		{pc("p", &ts), pc("p", &other), true},
+		{pc("p", &ts), pc("p", &later), false},
+		{&Cell{T: &ts}, &Cell{T: &other}, true},
+		{&Cell{T: &ts}, &Cell{T: &later}, false},
+		{tc(ts), tc(other), true},
+		{tc(ts), tc(later), false},
+	}
+	for _, entry := range testTable {
+		if got := entry.c1.Equal(entry.c2); got != entry.want {
+			t.Errorf("Cell(%v).Equal(%v) returned the wrong value; got %v, want %v", entry.c1, entry.c2, got, entry.want)
+		}
+		if got := entry.c2.Equal(entry.c1); got != entry.want {
+			t.Errorf("Cell(%v).Equal(%v) is not symmetric; got %v, want %v", entry.c2, entry.c1, got, entry.want)
+		}
+		if entry.want && entry.c1.Hash() != entry.c2.Hash() {
+			t.Errorf("Cell(%v).Hash and Cell(%v).Hash should be the same for equal cells", entry.c1, entry.c2)
+		}
+	}
+}
+
+func TestRowEqual(t *testing.T) {
+	ts := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	other := ts.In(time.FixedZone("PST", -8*3600))
+	testTable := []struct {
+		r1, r2 Row
+		want   bool
+	}{
+		{Row{}, Row{}, true},
+		{Row{"?s": &Cell{S: "a"}}, Row{"?s": &Cell{S: "a"}}, true},
+		{Row{"?s": &Cell{S: "a"}, "?o": NullCell()}, Row{"?s": &Cell{S: "a"}}, true},
+		{Row{"?s": &Cell{S: "a"}, "?t": &Cell{T: &ts}}, Row{"?t": &Cell{T: &other}, "?s": &Cell{S: "a"}}, true},
+		{Row{"?s": &Cell{S: "a"}}, Row{"?s": &Cell{S: "b"}}, false},
+		{Row{"?s": &Cell{S: "a"}}, Row{"?o": &Cell{S: "a"}}, false},
+		{Row{"?s": &Cell{S: "a"}}, Row{"?s": &Cell{S: "a"}, "?o": &Cell{S: "b"}}, false},
+	}
+	for _, entry := range testTable {
+		if got := entry.r1.Equal(entry.r2); got != entry.want {
+			t.Errorf("Row(%v).Equal(%v) returned the wrong value; got %v, want %v", entry.r1, entry.r2, got, entry.want)
+		}
+		if got := entry.r2.Equal(entry.r1); got != entry.want {
+			t.Errorf("Row(%v).Equal(%v) is not symmetric; got %v, want %v", entry.r2, entry.r1, got, entry.want)
+		}
+		if entry.want && entry.r1.Hash() != entry.r2.Hash() {
+			t.Errorf("Row(%v).Hash and Row(%v).Hash should be the same for equal rows", entry.r1, entry.r2)
+		}
+		if !entry.want && entry.r1.Hash() == entry.r2.Hash() {
+			t.Errorf("Row(%v).Hash and Row(%v).Hash should differ", entry.r1, entry.r2)
+		}
+	}
+}
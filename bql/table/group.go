@@ -0,0 +1,272 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/google/badwolf/triple/literal"
+)
+
+// AggregationKind names the function used to aggregate the values bound on
+// each group of rows.
+type AggregationKind int
+
+const (
+	// Count counts the values bound.
+	Count AggregationKind = iota
+	// Sum adds the numeric values bound.
+	Sum
+	// Min returns the smallest value bound.
+	Min
+	// Max returns the biggest value bound.
+	Max
+	// Avg returns the average of the numeric values bound.
+	Avg
+)
+
+// String returns the name of the aggregation.
+func (k AggregationKind) String() string {
+	switch k {
+	case Count:
+		return "count"
+	case Sum:
+		return "sum"
+	case Min:
+		return "min"
+	case Max:
+		return "max"
+	case Avg:
+		return "avg"
+	default:
+		return "unknown"
+	}
+}
+
+// Aggregation describes a value computed for each group of rows.
+type Aggregation struct {
+	// Kind contains the function used to aggregate the values.
+	Kind AggregationKind
+	// Binding contains the binding whose values are aggregated.
+	Binding string
+	// Alias contains the binding the aggregated value is bound to.
+	Alias string
+	// Distinct makes the aggregation ignore duplicated values.
+	Distinct bool
+}
+
+// Group returns a new table with one row for each group of rows sharing the
+// same values for the provided bindings, in the order the groups are first
+// found. Each row contains the values of the grouping bindings and the ones
+// computed by the aggregations. Rows missing the aggregated binding are
+// ignored by the aggregation. Min and max use the same collation as Sort,
+// while sum and avg fail if any value is not an int64 or float64 literal. If
+// no grouping bindings are provided all rows form a single group, even if the
+// table is empty. Aggregations with no value to compute, such as the minimum
+// of an empty group, leave their alias unbound. The returned table declares
+// the types of the grouping bindings and of the aggregations whose type is
+// known up front.
+func (t *Table) Group(bs []string, aggs []Aggregation) (*Table, error) {
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	out := append([]string{}, bs...)
+	types := make(Schema)
+	for _, b := range bs {
+		if ct := t.types[b]; ct != AnyType {
+			types[b] = ct
+		}
+	}
+	seen := make(map[string]bool)
+	for _, b := range bs {
+		if seen[b] {
+			return nil, fmt.Errorf("table.Group: duplicated grouping binding %q", b)
+		}
+		seen[b] = true
+	}
+	for _, a := range aggs {
+		if a.Alias == "" || seen[a.Alias] {
+			return nil, fmt.Errorf("table.Group: invalid alias %q for %s(%s)", a.Alias, a.Kind, a.Binding)
+		}
+		seen[a.Alias] = true
+		out = append(out, a.Alias)
+		ct, err := aggregationType(a, t.types[a.Binding])
+		if err != nil {
+			return nil, err
+		}
+		if ct != AnyType {
+			types[a.Alias] = ct
+		}
+	}
+	res, err := New(out, types)
+	if err != nil {
+		return nil, err
+	}
+	type group struct {
+		row  Row
+		vals [][]*Cell
+	}
+	newGroup := func(r cells) *group {
+		g := &group{row: make(Row), vals: make([][]*Cell, len(aggs))}
+		for _, b := range bs {
+			if c := r.Cell(b); !c.IsNull() {
+				g.row[b] = c
+			}
+		}
+		return g
+	}
+	idx := make(map[string]*group)
+	var gs []*group
+	for j := 0; j < t.n; j++ {
+		r := t.View(j)
+		k := rowKey(r, bs)
+		g, ok := idx[k]
+		if !ok {
+			g = newGroup(r)
+			idx[k] = g
+			gs = append(gs, g)
+		}
+		for i, a := range aggs {
+			if c := r.Cell(a.Binding); !c.IsNull() {
+				g.vals[i] = append(g.vals[i], c)
+			}
+		}
+	}
+	if len(bs) == 0 && len(gs) == 0 {
+		gs = append(gs, newGroup(Row{}))
+	}
+	for _, g := range gs {
+		for i, a := range aggs {
+			vs := g.vals[i]
+			if a.Distinct {
+				vs = distinctCells(vs)
+			}
+			c, err := aggregate(a, t.types[a.Binding], vs)
+			if err != nil {
+				return nil, err
+			}
+			if c != nil {
+				g.row[a.Alias] = c
+			}
+		}
+		res.AddRow(g.row)
+	}
+	return res, nil
+}
+
+// distinctCells returns the provided cells without the duplicated ones.
+func distinctCells(cs []*Cell) []*Cell {
+	var res []*Cell
+	seen := make(map[string]bool)
+	for _, c := range cs {
+		k := cellKey(c)
+		if !seen[k] {
+			seen[k] = true
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// aggregationType returns the type of the values computed by the aggregation
+// given the declared type of the aggregated binding, or AnyType if it cannot
+// be known up front. It fails if the declared type cannot be aggregated.
+func aggregationType(a Aggregation, ct CellType) (CellType, error) {
+	switch a.Kind {
+	case Count:
+		return Int64Type, nil
+	case Sum, Avg:
+		switch ct {
+		case AnyType, Int64Type, Float32Type, Float64Type, DecimalType:
+		default:
+			return AnyType, fmt.Errorf("table.Group: cannot %s binding %q declared as %v", a.Kind, a.Binding, ct)
+		}
+		if a.Kind == Avg {
+			return Float64Type, nil
+		}
+		return ct, nil
+	case Min, Max:
+		return ct, nil
+	}
+	return AnyType, nil
+}
+
+// aggregate computes the provided aggregation over the given values, bound to
+// a binding declared with the provided type. It returns nil if there is no
+// value to compute.
+func aggregate(a Aggregation, ct CellType, vs []*Cell) (*Cell, error) {
+	switch a.Kind {
+	case Count:
+		return literalCell(literal.Int64, int64(len(vs)))
+	case Sum, Avg:
+		sum, err := zeroSum(ct)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range vs {
+			if cellRank(c) != rankNumber {
+				return nil, fmt.Errorf("table.Group: cannot %s non numeric value %v bound to %q", a.Kind, c, a.Binding)
+			}
+			if sum, err = literal.Arithmetic(literal.Add, sum, c.L); err != nil {
+				return nil, fmt.Errorf("table.Group: cannot %s values bound to %q; %v", a.Kind, a.Binding, err)
+			}
+		}
+		if a.Kind == Avg {
+			if len(vs) == 0 {
+				return nil, nil
+			}
+			f, _ := sum.AsFloat64()
+			return literalCell(literal.Float64, f/float64(len(vs)))
+		}
+		return &Cell{L: sum}, nil
+	case Min, Max:
+		var res *Cell
+		for _, c := range vs {
+			cmp := compareCells(c, res)
+			if res == nil || a.Kind == Min && cmp < 0 || a.Kind == Max && cmp > 0 {
+				res = c
+			}
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("table.Group: unknown aggregation %s", a.Kind)
+}
+
+// zeroSum returns the value sums of a binding declared with the provided type
+// start from, so sums of no values still have the declared type. Sums of
+// bindings with no declared type start from an int64 zero.
+func zeroSum(ct CellType) (*literal.Literal, error) {
+	b := literal.DefaultBuilder()
+	switch ct {
+	case Float32Type:
+		return b.Build(literal.Float32, float32(0))
+	case Float64Type:
+		return b.Build(literal.Float64, float64(0))
+	case DecimalType:
+		return b.Build(literal.Decimal, new(big.Rat))
+	}
+	return b.Build(literal.Int64, int64(0))
+}
+
+// literalCell returns a cell containing the literal of the provided type and
+// value.
+func literalCell(t literal.Type, v interface{}) (*Cell, error) {
+	l, err := literal.DefaultBuilder().Build(t, v)
+	if err != nil {
+		return nil, err
+	}
+	return &Cell{L: l}, nil
+}
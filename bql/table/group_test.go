@@ -0,0 +1,210 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestGroup(t *testing.T) {
+	b := literal.DefaultBuilder()
+	lc := func(t literal.Type, v interface{}) *Cell {
+		l, err := b.Build(t, v)
+		if err != nil {
+			panic(err)
+		}
+		return &Cell{L: l}
+	}
+	tbl, err := New([]string{"?k", "?v", "?f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []Row{
+		{"?k": {S: "a"}, "?v": lc(literal.Int64, int64(1)), "?f": lc(literal.Float64, 0.5)},
+		{"?k": {S: "b"}, "?v": lc(literal.Int64, int64(5)), "?f": NullCell()},
+		{"?k": {S: "a"}, "?v": lc(literal.Int64, int64(3)), "?f": lc(literal.Float64, 1.5)},
+		{"?k": {S: "a"}, "?v": lc(literal.Int64, int64(3))},
+	} {
+		tbl.AddRow(r)
+	}
+	empty, err := New([]string{"?k", "?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	typed, err := New([]string{"?f", "?d", "?i"}, Schema{"?f": Float32Type, "?d": DecimalType, "?i": Int64Type})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decimals, err := New([]string{"?k", "?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []*big.Rat{big.NewRat(1, 10), big.NewRat(2, 10)} {
+		decimals.AddRow(Row{"?k": {S: "a"}, "?v": lc(literal.Decimal, v)})
+	}
+	testTable := []struct {
+		id   string
+		tbl  *Table
+		bs   []string
+		aggs []Aggregation
+		want []string
+	}{
+		{
+			id:   "count",
+			tbl:  tbl,
+			bs:   []string{"?k"},
+			aggs: []Aggregation{{Kind: Count, Binding: "?v", Alias: "?n"}},
+			want: []string{`a	"3"^^type:int64`, `b	"1"^^type:int64`},
+		},
+		{
+			id:   "count distinct",
+			tbl:  tbl,
+			bs:   []string{"?k"},
+			aggs: []Aggregation{{Kind: Count, Binding: "?v", Alias: "?n", Distinct: true}},
+			want: []string{`a	"2"^^type:int64`, `b	"1"^^type:int64`},
+		},
+		{
+			id:  "sum",
+			tbl: tbl,
+			bs:  []string{"?k"},
+			aggs: []Aggregation{
+				{Kind: Sum, Binding: "?v", Alias: "?s"},
+				{Kind: Sum, Binding: "?v", Alias: "?d", Distinct: true},
+				{Kind: Sum, Binding: "?f", Alias: "?sf"},
+			},
+			want: []string{
+				`a	"7"^^type:int64	"4"^^type:int64	"2"^^type:float64`,
+				`b	"5"^^type:int64	"5"^^type:int64	"0"^^type:int64`,
+			},
+		},
+		{
+			id:   "exact decimal sum",
+			tbl:  decimals,
+			bs:   []string{"?k"},
+			aggs: []Aggregation{{Kind: Sum, Binding: "?v", Alias: "?s"}},
+			want: []string{`a	"0.3"^^type:decimal`},
+		},
+		{
+			id:  "min max avg",
+			tbl: tbl,
+			bs:  []string{"?k"},
+			aggs: []Aggregation{
+				{Kind: Min, Binding: "?v", Alias: "?min"},
+				{Kind: Max, Binding: "?v", Alias: "?max"},
+				{Kind: Avg, Binding: "?f", Alias: "?avg"},
+			},
+			want: []string{
+				`a	"1"^^type:int64	"3"^^type:int64	"1"^^type:float64`,
+				`b	"5"^^type:int64	"5"^^type:int64	<NULL>`,
+			},
+		},
+		{
+			id:   "no grouping bindings",
+			tbl:  tbl,
+			aggs: []Aggregation{{Kind: Count, Binding: "?k", Alias: "?n"}},
+			want: []string{`"4"^^type:int64`},
+		},
+		{
+			id:   "no grouping bindings on empty table",
+			tbl:  empty,
+			aggs: []Aggregation{{Kind: Count, Binding: "?v", Alias: "?n"}, {Kind: Max, Binding: "?v", Alias: "?m"}},
+			want: []string{`"0"^^type:int64	<NULL>`},
+		},
+		{
+			id:  "sums of no values keep the declared type",
+			tbl: typed,
+			aggs: []Aggregation{
+				{Kind: Sum, Binding: "?f", Alias: "?sf"},
+				{Kind: Sum, Binding: "?d", Alias: "?sd"},
+				{Kind: Sum, Binding: "?i", Alias: "?si"},
+			},
+			want: []string{`"0"^^type:float32	"0"^^type:decimal	"0"^^type:int64`},
+		},
+		{
+			id:   "empty table",
+			tbl:  empty,
+			bs:   []string{"?k"},
+			aggs: []Aggregation{{Kind: Count, Binding: "?v", Alias: "?n"}},
+		},
+		{
+			id:   "distinct rows",
+			tbl:  tbl,
+			bs:   []string{"?k", "?v"},
+			want: []string{`a	"1"^^type:int64`, `b	"5"^^type:int64`, `a	"3"^^type:int64`},
+		},
+	}
+	for _, entry := range testTable {
+		got, err := entry.tbl.Group(entry.bs, entry.aggs)
+		if err != nil {
+			t.Errorf("table.Group failed for %q with error %v", entry.id, err)
+			continue
+		}
+		var rws []string
+		for _, r := range got.Rows() {
+			var buf bytes.Buffer
+			if err := r.ToTextLine(&buf, got.Bindings(), "\t"); err != nil {
+				t.Fatal(err)
+			}
+			rws = append(rws, buf.String())
+		}
+		if !reflect.DeepEqual(rws, entry.want) {
+			t.Errorf("table.Group returned the wrong rows for %q; got %q, want %q", entry.id, rws, entry.want)
+		}
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	tbl, err := New([]string{"?k", "?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?k": {S: "a"}, "?v": {S: "b"}})
+	testTable := []struct {
+		id   string
+		bs   []string
+		aggs []Aggregation
+	}{
+		{id: "duplicated grouping binding", bs: []string{"?k", "?k"}},
+		{id: "missing alias", aggs: []Aggregation{{Kind: Count, Binding: "?v"}}},
+		{id: "alias clash", bs: []string{"?k"}, aggs: []Aggregation{{Kind: Count, Binding: "?v", Alias: "?k"}}},
+		{id: "non numeric sum", bs: []string{"?k"}, aggs: []Aggregation{{Kind: Sum, Binding: "?v", Alias: "?s"}}},
+		{id: "non numeric avg", aggs: []Aggregation{{Kind: Avg, Binding: "?v", Alias: "?s"}}},
+	}
+	for _, entry := range testTable {
+		if _, err := tbl.Group(entry.bs, entry.aggs); err == nil {
+			t.Errorf("table.Group should have failed for %q", entry.id)
+		}
+	}
+	overflow, err := New([]string{"?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		l, err := literal.DefaultBuilder().Build(literal.Int64, int64(math.MaxInt64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		overflow.AddRow(Row{"?v": {L: l}})
+	}
+	if _, err := overflow.Group(nil, []Aggregation{{Kind: Sum, Binding: "?v", Alias: "?s"}}); err == nil {
+		t.Errorf("table.Group should have failed to sum overflowing int64 values")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import "fmt"
+
+// RowIterator returns the rows of a table in chunks, building the rows of one
+// chunk at a time. Rows spilled to disk are read one chunk at a time too. The
+// table should not have rows removed while iterating.
+type RowIterator struct {
+	t    *Table
+	next int
+	size int
+	run  *Run
+	rr   *runReader
+}
+
+// Iterator returns an iterator over the rows of the table that returns up to
+// size rows at a time. Non positive sizes return one row at a time.
+func (t *Table) Iterator(size int) *RowIterator {
+	if size <= 0 {
+		size = 1
+	}
+	return &RowIterator{t: t, size: size}
+}
+
+// Next returns the next chunk of rows. It returns no rows once all the rows of
+// the table have been returned. Rows added to the table while iterating are
+// also returned. It fails if rows were removed from the table while iterating.
+func (it *RowIterator) Next() ([]Row, error) {
+	if n := it.t.NumRows(); it.next > n {
+		return nil, fmt.Errorf("table.RowIterator cannot continue after %d rows; the table was truncated to %d rows", it.next, n)
+	}
+	var rs []Row
+	for len(rs) < it.size {
+		if it.next < it.t.n {
+			rs = append(rs, it.t.row(it.next))
+			it.next++
+			continue
+		}
+		r, err := it.readSpilled()
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			break
+		}
+		rs = append(rs, r)
+		it.next++
+	}
+	return rs, nil
+}
+
+// readSpilled returns the next row spilled to disk, or nil if there are none
+// left. The file holding the spilled rows is only kept open while reading
+// them.
+func (it *RowIterator) readSpilled() (Row, error) {
+	if it.run != it.t.spilled {
+		// The spilled rows were loaded into memory or dropped.
+		it.closeSpilled()
+	}
+	if it.t.spilled == nil {
+		return nil, nil
+	}
+	if it.rr == nil {
+		rr, err := it.t.spilled.open()
+		if err != nil {
+			return nil, err
+		}
+		it.run, it.rr = it.t.spilled, rr
+		for i := it.t.n; i < it.next; i++ {
+			if _, err := rr.read(); err != nil {
+				it.closeSpilled()
+				return nil, err
+			}
+		}
+	}
+	r, err := it.rr.read()
+	if r == nil || err != nil {
+		it.closeSpilled()
+	}
+	return r, err
+}
+
+// closeSpilled closes the reader of the spilled rows, if open.
+func (it *RowIterator) closeSpilled() {
+	if it.rr != nil {
+		it.rr.close()
+		it.rr, it.run = nil, nil
+	}
+}
+
+// ChunkedAppend adds to the table the rows returned by the provided function,
+// which is called until it returns no rows or fails. Rows are added one chunk
+// at a time, so producers only need to hold a chunk of rows at once. The rows
+// of a table iterator can be appended to another one by providing the
+// iterator Next method. Rows added before a failure are kept.
+func (t *Table) ChunkedAppend(next func() ([]Row, error)) error {
+	for {
+		rs, err := next()
+		if err != nil {
+			return err
+		}
+		if len(rs) == 0 {
+			return nil
+		}
+		for _, r := range rs {
+			t.AddRow(r)
+		}
+	}
+}
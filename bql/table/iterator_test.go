@@ -0,0 +1,87 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRowIterator(t *testing.T) {
+	tbl := testSortTable(t)
+	want := tbl.Rows()
+	for _, size := range []int{-1, 0, 1, 2, 5, 10} {
+		it := tbl.Iterator(size)
+		var got []Row
+		for {
+			rs, err := it.Next()
+			if err != nil {
+				t.Fatalf("RowIterator.Next failed with error %v", err)
+			}
+			if len(rs) == 0 {
+				break
+			}
+			if size > 0 && len(rs) > size {
+				t.Errorf("RowIterator.Next returned %d rows; want at most %d", len(rs), size)
+			}
+			got = append(got, rs...)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("table.Iterator(%d) returned the wrong rows; got %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestRowIteratorTruncated(t *testing.T) {
+	tbl := testSortTable(t)
+	it := tbl.Iterator(3)
+	if _, err := it.Next(); err != nil {
+		t.Fatalf("RowIterator.Next failed with error %v", err)
+	}
+	tbl.Truncate()
+	if _, err := it.Next(); err == nil {
+		t.Errorf("RowIterator.Next should have failed after the table was truncated")
+	}
+}
+
+func TestChunkedAppend(t *testing.T) {
+	src := testSortTable(t)
+	tbl, err := New(src.Bindings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.ChunkedAppend(src.Iterator(2).Next); err != nil {
+		t.Fatalf("table.ChunkedAppend failed with error %v", err)
+	}
+	if got, want := tbl.Rows(), src.Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("table.ChunkedAppend added the wrong rows; got %v, want %v", got, want)
+	}
+	// Rows added before a failure are kept.
+	calls := 0
+	err = tbl.ChunkedAppend(func() ([]Row, error) {
+		calls++
+		if calls > 1 {
+			return nil, errors.New("boom")
+		}
+		return []Row{{"?s": &Cell{S: "z"}}}, nil
+	})
+	if err == nil {
+		t.Errorf("table.ChunkedAppend should have propagated the producer error")
+	}
+	if got, want := tbl.NumRows(), src.NumRows()+1; got != want {
+		t.Errorf("table.ChunkedAppend returned the wrong number of rows; got %d, want %d", got, want)
+	}
+}
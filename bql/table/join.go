@@ -0,0 +1,293 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// JoinKind indicates how the rows of two tables are combined by a join.
+type JoinKind int
+
+const (
+	// InnerJoin returns the merge of each pair of compatible rows.
+	InnerJoin JoinKind = iota
+	// LeftOuterJoin returns the inner join rows plus the rows of the left
+	// table that are not compatible with any row of the right one, which
+	// hold null cells for the bindings of the right table.
+	LeftOuterJoin
+	// SemiJoin returns the rows of the left table compatible with at least one
+	// row of the right one.
+	SemiJoin
+	// AntiJoin returns the rows of the left table not compatible with any row
+	// of the right one.
+	AntiJoin
+)
+
+// String returns a readable representation of the join kind.
+func (k JoinKind) String() string {
+	switch k {
+	case InnerJoin:
+		return "inner"
+	case LeftOuterJoin:
+		return "left outer"
+	case SemiJoin:
+		return "semi"
+	case AntiJoin:
+		return "anti"
+	}
+	return fmt.Sprintf("unknown(%d)", int(k))
+}
+
+// sharedBindings returns the bindings of the table also available on the
+// provided one.
+func (t *Table) sharedBindings(t2 *Table) []string {
+	var shared []string
+	for _, b := range t.bs {
+		if t2.mbs[b] {
+			shared = append(shared, b)
+		}
+	}
+	return shared
+}
+
+// Join joins the table with the provided one using a hash join. Two rows are
+// compatible if they agree on the values of the provided bindings, which must
+// be available on both tables; if none are provided, the bindings shared by
+// both tables are used. A null cell agrees with any value. Inner and left
+// outer joins add the bindings of the provided table and merge the cells of
+// compatible rows; semi and anti joins keep the table bindings and rows
+// unchanged. Rows retain the order of the table. The join stops and returns
+// the context error once the provided context is done.
+func (t *Table) Join(ctx context.Context, t2 *Table, kind JoinKind, on []string) error {
+	if err := t.load(); err != nil {
+		return err
+	}
+	if err := t2.load(); err != nil {
+		return err
+	}
+	on, err := t.joinBindings(t2, on)
+	if err != nil {
+		return err
+	}
+	pairs, err := t.hashPairs(ctx, t2, on)
+	if err != nil {
+		return err
+	}
+	return t.applyJoin(t2, kind, pairs, true)
+}
+
+// SortedMergeJoin behaves as Join, but it finds the compatible rows by sorting
+// both tables on the join bindings and merging them. Inner and left outer
+// joins return the merged rows sorted by the values of the join bindings,
+// followed by the ones involving null join values and, for left outer joins,
+// the rows without matches. Semi and anti joins retain the order of the table.
+func (t *Table) SortedMergeJoin(ctx context.Context, t2 *Table, kind JoinKind, on []string) error {
+	if err := t.load(); err != nil {
+		return err
+	}
+	if err := t2.load(); err != nil {
+		return err
+	}
+	on, err := t.joinBindings(t2, on)
+	if err != nil {
+		return err
+	}
+	pairs, err := t.mergePairs(ctx, t2, on)
+	if err != nil {
+		return err
+	}
+	return t.applyJoin(t2, kind, pairs, false)
+}
+
+// joinBindings returns the bindings to join both tables on.
+func (t *Table) joinBindings(t2 *Table, on []string) ([]string, error) {
+	if len(on) == 0 {
+		return t.sharedBindings(t2), nil
+	}
+	for _, b := range on {
+		if !t.mbs[b] || !t2.mbs[b] {
+			return nil, fmt.Errorf("table.Join requires binding %q to be available on both tables; instead got %v and %v", b, t.bs, t2.bs)
+		}
+	}
+	return on, nil
+}
+
+// hashPairs returns the positions of all the pairs of compatible rows of both
+// tables by hashing the rows of the provided one.
+func (t *Table) hashPairs(ctx context.Context, t2 *Table, on []string) ([][2]int, error) {
+	// Build the hash table using the provided table rows.
+	idx := make(map[string][]int)
+	var nulls []int
+	for j := 0; j < t2.n; j++ {
+		if k, ok := joinKey(t2.View(j), on); ok {
+			idx[k] = append(idx[k], j)
+		} else {
+			nulls = append(nulls, j)
+		}
+	}
+	// Probe the hash table with the current rows.
+	var pairs [][2]int
+	for i := 0; i < t.n; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r1 := t.View(i)
+		k, ok := joinKey(r1, on)
+		if !ok {
+			// Rows with null join cells need to be checked against all rows.
+			for j := 0; j < t2.n; j++ {
+				if compatibleRows(r1, t2.View(j), on) {
+					pairs = append(pairs, [2]int{i, j})
+				}
+			}
+			continue
+		}
+		for _, j := range idx[k] {
+			pairs = append(pairs, [2]int{i, j})
+		}
+		for _, j := range nulls {
+			if compatibleRows(r1, t2.View(j), on) {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+	return pairs, nil
+}
+
+// keyedRow is a row position and its join key.
+type keyedRow struct {
+	i int
+	k string
+}
+
+// sortedKeys returns the rows of the table with no null join cells sorted by
+// their join key, and the positions of the remaining ones.
+func (t *Table) sortedKeys(on []string) ([]keyedRow, []int) {
+	var (
+		krs   []keyedRow
+		nulls []int
+	)
+	for i := 0; i < t.n; i++ {
+		if k, ok := joinKey(t.View(i), on); ok {
+			krs = append(krs, keyedRow{i: i, k: k})
+		} else {
+			nulls = append(nulls, i)
+		}
+	}
+	sort.SliceStable(krs, func(a, b int) bool { return krs[a].k < krs[b].k })
+	return krs, nulls
+}
+
+// mergePairs returns the positions of all the pairs of compatible rows of
+// both tables by merging both tables sorted by their join keys. Pairs
+// involving rows with null join cells are listed last.
+func (t *Table) mergePairs(ctx context.Context, t2 *Table, on []string) ([][2]int, error) {
+	l, lnulls := t.sortedKeys(on)
+	r, rnulls := t2.sortedKeys(on)
+	var pairs [][2]int
+	for a, b := 0, 0; a < len(l) && b < len(r); {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		switch {
+		case l[a].k < r[b].k:
+			a++
+		case l[a].k > r[b].k:
+			b++
+		default:
+			// Pair the runs of rows sharing the same key.
+			ea, eb := a, b
+			for ea < len(l) && l[ea].k == l[a].k {
+				ea++
+			}
+			for eb < len(r) && r[eb].k == r[b].k {
+				eb++
+			}
+			for _, lr := range l[a:ea] {
+				for _, rr := range r[b:eb] {
+					pairs = append(pairs, [2]int{lr.i, rr.i})
+				}
+			}
+			a, b = ea, eb
+		}
+	}
+	// Rows with null join cells need to be checked against all rows.
+	for _, lr := range l {
+		for _, j := range rnulls {
+			if compatibleRows(t.View(lr.i), t2.View(j), on) {
+				pairs = append(pairs, [2]int{lr.i, j})
+			}
+		}
+	}
+	for _, i := range lnulls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for j := 0; j < t2.n; j++ {
+			if compatibleRows(t.View(i), t2.View(j), on) {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+	return pairs, nil
+}
+
+// applyJoin updates the table with the result of the join of the requested
+// kind given the positions of the compatible rows of both tables. If ordered
+// is true, the rows of a left outer join retain the order of the table.
+func (t *Table) applyJoin(t2 *Table, kind JoinKind, pairs [][2]int, ordered bool) error {
+	switch kind {
+	case InnerJoin:
+		t.AddBindings(t2.bs)
+		t.addTypes(t2.types)
+		t.joinRows(t2, pairs)
+	case LeftOuterJoin:
+		matched := make([]bool, t.n)
+		for _, p := range pairs {
+			matched[p[0]] = true
+		}
+		for i, m := range matched {
+			if !m {
+				pairs = append(pairs, [2]int{i, -1})
+			}
+		}
+		if ordered {
+			sort.SliceStable(pairs, func(a, b int) bool { return pairs[a][0] < pairs[b][0] })
+		}
+		t.AddBindings(t2.bs)
+		t.addTypes(t2.types)
+		t.joinRows(t2, pairs)
+	case SemiJoin, AntiJoin:
+		matched := make([]bool, t.n)
+		for _, p := range pairs {
+			matched[p[0]] = true
+		}
+		var idx []int
+		for i, m := range matched {
+			if m == (kind == SemiJoin) {
+				idx = append(idx, i)
+			}
+		}
+		if len(idx) < t.n {
+			t.selectRows(idx)
+		}
+	default:
+		return fmt.Errorf("table.Join does not support %v joins", kind)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	newTable := func(bs []string, rows ...string) *Table {
+		tbl, err := New(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, vs := range rows {
+			r := Row{}
+			for i, v := range strings.Fields(vs) {
+				if v != "_" {
+					r[bs[i]] = &Cell{S: v}
+				}
+			}
+			tbl.AddRow(r)
+		}
+		return tbl
+	}
+	left := func() *Table {
+		return newTable([]string{"?s", "?o"}, "b 2", "a 1", "c _", "d 9")
+	}
+	right := func() *Table {
+		return newTable([]string{"?o", "?x"}, "2 x", "1 y", "1 z", "3 q")
+	}
+	withNulls := func() *Table {
+		return newTable([]string{"?o", "?x"}, "2 x", "_ w")
+	}
+	pair := func() *Table {
+		return newTable([]string{"?s", "?o"}, "a 1", "b 2")
+	}
+	testTable := []struct {
+		id    string
+		t, t2 *Table
+		kind  JoinKind
+		merge bool
+		want  []string
+	}{
+		{
+			id:   "hash inner",
+			t:    left(),
+			t2:   right(),
+			kind: InnerJoin,
+			want: []string{"b 2 x", "a 1 y", "a 1 z", "c 2 x", "c 1 y", "c 1 z", "c 3 q"},
+		},
+		{
+			id:    "merge inner",
+			t:     left(),
+			t2:    right(),
+			kind:  InnerJoin,
+			merge: true,
+			want:  []string{"a 1 y", "a 1 z", "b 2 x", "c 2 x", "c 1 y", "c 1 z", "c 3 q"},
+		},
+		{
+			id:   "hash left outer",
+			t:    newTable([]string{"?s", "?o"}, "d 9", "b 2"),
+			t2:   right(),
+			kind: LeftOuterJoin,
+			want: []string{"d 9 _", "b 2 x"},
+		},
+		{
+			id:    "merge left outer",
+			t:     newTable([]string{"?s", "?o"}, "d 9", "b 2"),
+			t2:    right(),
+			kind:  LeftOuterJoin,
+			merge: true,
+			want:  []string{"b 2 x", "d 9 _"},
+		},
+		{
+			id:   "hash semi",
+			t:    left(),
+			t2:   right(),
+			kind: SemiJoin,
+			want: []string{"b 2", "a 1", "c _"},
+		},
+		{
+			id:    "merge semi",
+			t:     left(),
+			t2:    right(),
+			kind:  SemiJoin,
+			merge: true,
+			want:  []string{"b 2", "a 1", "c _"},
+		},
+		{
+			id:   "hash anti",
+			t:    left(),
+			t2:   right(),
+			kind: AntiJoin,
+			want: []string{"d 9"},
+		},
+		{
+			id:    "merge anti",
+			t:     left(),
+			t2:    right(),
+			kind:  AntiJoin,
+			merge: true,
+			want:  []string{"d 9"},
+		},
+		{
+			id:   "hash inner with null join values",
+			t:    pair(),
+			t2:   withNulls(),
+			kind: InnerJoin,
+			want: []string{"a 1 w", "b 2 x", "b 2 w"},
+		},
+		{
+			id:    "merge inner with null join values",
+			t:     pair(),
+			t2:    withNulls(),
+			kind:  InnerJoin,
+			merge: true,
+			want:  []string{"b 2 x", "a 1 w", "b 2 w"},
+		},
+		{
+			id:   "anti with null join values",
+			t:    pair(),
+			t2:   withNulls(),
+			kind: AntiJoin,
+		},
+		{
+			id:   "cross product",
+			t:    newTable([]string{"?s"}, "a", "b"),
+			t2:   newTable([]string{"?x"}, "x", "y"),
+			kind: InnerJoin,
+			want: []string{"a x", "a y", "b x", "b y"},
+		},
+		{
+			id:   "semi without rows to join",
+			t:    newTable([]string{"?s"}, "a", "b"),
+			t2:   newTable([]string{"?x"}),
+			kind: SemiJoin,
+		},
+	}
+	for _, entry := range testTable {
+		join := entry.t.Join
+		if entry.merge {
+			join = entry.t.SortedMergeJoin
+		}
+		if err := join(context.Background(), entry.t2, entry.kind, nil); err != nil {
+			t.Errorf("%s: join failed with error %v", entry.id, err)
+			continue
+		}
+		var got []string
+		for i := 0; i < entry.t.NumRows(); i++ {
+			var vs []string
+			for _, b := range entry.t.Bindings() {
+				v := "_"
+				if c := entry.t.View(i).Cell(b); c != nil {
+					v = c.S
+				}
+				vs = append(vs, v)
+			}
+			got = append(got, strings.Join(vs, " "))
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("%s: join returned the wrong rows; got %v, want %v", entry.id, got, entry.want)
+		}
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	newTable := func(bs ...string) *Table {
+		tbl, err := New(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tbl
+	}
+	ctx := context.Background()
+	if err := newTable("?s").Join(ctx, newTable("?o"), InnerJoin, []string{"?s"}); err == nil {
+		t.Errorf("table.Join should have failed to join on a binding missing on the provided table")
+	}
+	if err := newTable("?s").SortedMergeJoin(ctx, newTable("?s"), InnerJoin, []string{"?o"}); err == nil {
+		t.Errorf("table.SortedMergeJoin should have failed to join on an unknown binding")
+	}
+	if err := newTable("?s").Join(ctx, newTable("?s"), JoinKind(42), nil); err == nil {
+		t.Errorf("table.Join should have failed for an unknown join kind")
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	tbl := newTable("?s")
+	tbl.AddRow(Row{"?s": &Cell{S: "a"}})
+	if err := tbl.SortedMergeJoin(cctx, tbl, InnerJoin, nil); err == nil {
+		t.Errorf("table.SortedMergeJoin should have failed for a cancelled context")
+	}
+}
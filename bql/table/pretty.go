@@ -0,0 +1,118 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ellipsis is appended to the values truncated by ToPrettyText.
+const ellipsis = "..."
+
+// ToPrettyText converts the table into an aligned boxed text version suitable
+// for interactive use. Values longer than maxColWidth characters are truncated
+// and end with an ellipsis, and at most maxRows rows are rendered. Non
+// positive limits render values and rows in full. A footer reports the number
+// of rows of the table and, if any were left out, how many were rendered.
+func (t *Table) ToPrettyText(maxColWidth, maxRows int) (*bytes.Buffer, error) {
+	total := t.NumRows()
+	n := total
+	if maxRows > 0 && maxRows < n {
+		n = maxRows
+	}
+	// Collect the rendered values and the width of each column.
+	ws := make([]int, len(t.bs))
+	hdr := make([]string, len(t.bs))
+	for i, b := range t.bs {
+		hdr[i] = prettyValue(b, maxColWidth)
+		ws[i] = utf8.RuneCountInString(hdr[i])
+	}
+	vss := make([][]string, 0, n)
+	err := t.walk(n, func(r cells) error {
+		vs := make([]string, len(t.bs))
+		for i, b := range t.bs {
+			vs[i] = prettyValue(r.Cell(b).String(), maxColWidth)
+			if w := utf8.RuneCountInString(vs[i]); w > ws[i] {
+				ws[i] = w
+			}
+		}
+		vss = append(vss, vs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Render the table.
+	res := &bytes.Buffer{}
+	sep := &bytes.Buffer{}
+	sep.WriteString("+")
+	for _, w := range ws {
+		sep.WriteString(strings.Repeat("-", w+2))
+		sep.WriteString("+")
+	}
+	sep.WriteString("\n")
+	line := func(vs []string) {
+		res.WriteString("|")
+		for i, v := range vs {
+			res.WriteString(" ")
+			res.WriteString(v)
+			res.WriteString(strings.Repeat(" ", ws[i]-utf8.RuneCountInString(v)+1))
+			res.WriteString("|")
+		}
+		res.WriteString("\n")
+	}
+	res.Write(sep.Bytes())
+	line(hdr)
+	res.Write(sep.Bytes())
+	for _, vs := range vss {
+		line(vs)
+	}
+	if n > 0 {
+		res.Write(sep.Bytes())
+	}
+	rows := "rows"
+	if total == 1 {
+		rows = "row"
+	}
+	if n < total {
+		fmt.Fprintf(res, "%d %s (%d shown)\n", total, rows, n)
+	} else {
+		fmt.Fprintf(res, "%d %s\n", total, rows)
+	}
+	return res, nil
+}
+
+// prettyValue returns the provided value on a single line, truncated to the
+// maximum width if positive.
+func prettyValue(v string, max int) string {
+	v = strings.Map(func(r rune) rune {
+		switch r {
+		case '\n', '\r', '\t':
+			return ' '
+		}
+		return r
+	}, v)
+	if max <= 0 || utf8.RuneCountInString(v) <= max {
+		return v
+	}
+	rs := []rune(v)
+	if max <= len(ellipsis) {
+		return string(rs[:max])
+	}
+	return string(rs[:max-len(ellipsis)]) + ellipsis
+}
@@ -0,0 +1,115 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import "testing"
+
+func TestToPrettyText(t *testing.T) {
+	tbl, err := New([]string{"?name", "?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?name": &Cell{S: "héllo"}, "?v": &Cell{S: "1"}})
+	tbl.AddRow(Row{"?name": &Cell{S: "a very\nlong value"}})
+	tbl.AddRow(Row{"?name": &Cell{S: "c"}, "?v": &Cell{S: "3"}})
+	one, err := New([]string{"?s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	one.AddRow(Row{"?s": &Cell{S: "x"}})
+	empty, err := New([]string{"?s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		id      string
+		tbl     *Table
+		width   int
+		maxRows int
+		want    string
+	}{
+		{
+			id:  "unlimited",
+			tbl: tbl,
+			want: "" +
+				"+-------------------+--------+\n" +
+				"| ?name             | ?v     |\n" +
+				"+-------------------+--------+\n" +
+				"| héllo             | 1      |\n" +
+				"| a very long value | <NULL> |\n" +
+				"| c                 | 3      |\n" +
+				"+-------------------+--------+\n" +
+				"3 rows\n",
+		},
+		{
+			id:      "truncated",
+			tbl:     tbl,
+			width:   8,
+			maxRows: 2,
+			want: "" +
+				"+----------+--------+\n" +
+				"| ?name    | ?v     |\n" +
+				"+----------+--------+\n" +
+				"| héllo    | 1      |\n" +
+				"| a ver... | <NULL> |\n" +
+				"+----------+--------+\n" +
+				"3 rows (2 shown)\n",
+		},
+		{
+			id:    "narrow",
+			tbl:   tbl,
+			width: 2,
+			want: "" +
+				"+----+----+\n" +
+				"| ?n | ?v |\n" +
+				"+----+----+\n" +
+				"| hé | 1  |\n" +
+				"| a  | <N |\n" +
+				"| c  | 3  |\n" +
+				"+----+----+\n" +
+				"3 rows\n",
+		},
+		{
+			id:  "single row",
+			tbl: one,
+			want: "" +
+				"+----+\n" +
+				"| ?s |\n" +
+				"+----+\n" +
+				"| x  |\n" +
+				"+----+\n" +
+				"1 row\n",
+		},
+		{
+			id:  "empty",
+			tbl: empty,
+			want: "" +
+				"+----+\n" +
+				"| ?s |\n" +
+				"+----+\n" +
+				"0 rows\n",
+		},
+	}
+	for _, entry := range testTable {
+		got, err := entry.tbl.ToPrettyText(entry.width, entry.maxRows)
+		if err != nil {
+			t.Errorf("%s: table.ToPrettyText failed with error %v", entry.id, err)
+			continue
+		}
+		if got.String() != entry.want {
+			t.Errorf("%s: table.ToPrettyText returned the wrong text; got\n%s\nwant\n%s", entry.id, got, entry.want)
+		}
+	}
+}
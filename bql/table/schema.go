@@ -0,0 +1,218 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"fmt"
+
+	"github.com/google/badwolf/triple/literal"
+)
+
+// CellType identifies the kind of value held by a cell.
+type CellType int
+
+const (
+	// AnyType accepts cells holding any kind of value.
+	AnyType CellType = iota
+	// StringType identifies cells holding a string.
+	StringType
+	// NodeType identifies cells holding a node.
+	NodeType
+	// PredicateType identifies cells holding a predicate.
+	PredicateType
+	// TimeType identifies cells holding a time anchor.
+	TimeType
+	// TripleType identifies cells holding an embedded triple.
+	TripleType
+	// BoolType identifies cells holding a bool literal.
+	BoolType
+	// Int64Type identifies cells holding an int64 literal.
+	Int64Type
+	// Float64Type identifies cells holding a float64 literal.
+	Float64Type
+	// TextType identifies cells holding a text literal.
+	TextType
+	// BlobType identifies cells holding a blob literal.
+	BlobType
+	// DurationType identifies cells holding a duration literal.
+	DurationType
+	// Float32Type identifies cells holding a float32 literal.
+	Float32Type
+	// DecimalType identifies cells holding a decimal literal.
+	DecimalType
+	// DateType identifies cells holding a date literal.
+	DateType
+)
+
+// String returns a readable representation of the cell type.
+func (ct CellType) String() string {
+	switch ct {
+	case AnyType:
+		return "any"
+	case StringType:
+		return "string"
+	case NodeType:
+		return "node"
+	case PredicateType:
+		return "predicate"
+	case TimeType:
+		return "time"
+	case TripleType:
+		return "triple"
+	case BoolType:
+		return "bool"
+	case Int64Type:
+		return "int64"
+	case Float64Type:
+		return "float64"
+	case TextType:
+		return "text"
+	case BlobType:
+		return "blob"
+	case DurationType:
+		return "duration"
+	case Float32Type:
+		return "float32"
+	case DecimalType:
+		return "decimal"
+	case DateType:
+		return "date"
+	}
+	return fmt.Sprintf("unknown(%d)", int(ct))
+}
+
+// Type returns the type of the value held by the cell. Null cells return
+// AnyType.
+func (c *Cell) Type() CellType {
+	switch {
+	case c.IsNull():
+		return AnyType
+	case c.S != "":
+		return StringType
+	case c.N != nil:
+		return NodeType
+	case c.P != nil:
+		return PredicateType
+	case c.L != nil:
+		switch c.L.Type() {
+		case literal.Bool:
+			return BoolType
+		case literal.Int64:
+			return Int64Type
+		case literal.Float64:
+			return Float64Type
+		case literal.Text:
+			return TextType
+		case literal.Blob:
+			return BlobType
+		case literal.Duration:
+			return DurationType
+		case literal.Float32:
+			return Float32Type
+		case literal.Decimal:
+			return DecimalType
+		case literal.Date:
+			return DateType
+		}
+	case c.T != nil:
+		return TimeType
+	case c.E != nil:
+		return TripleType
+	}
+	return AnyType
+}
+
+// Schema declares the types of the cells bound to some bindings of a table.
+type Schema map[string]CellType
+
+// mergeSchemas returns the union of the provided schemas. It fails if they
+// declare types for unknown bindings or conflicting types for a binding.
+func mergeSchemas(mbs map[string]bool, ss []Schema) (Schema, error) {
+	var res Schema
+	for _, s := range ss {
+		for b, ct := range s {
+			if !mbs[b] {
+				return nil, fmt.Errorf("table.New cannot declare the type of unknown binding %q", b)
+			}
+			if ct == AnyType {
+				continue
+			}
+			if res == nil {
+				res = make(Schema)
+			}
+			if ot, ok := res[b]; ok && ot != ct {
+				return nil, fmt.Errorf("table.New cannot declare binding %q as both %v and %v", b, ot, ct)
+			}
+			res[b] = ct
+		}
+	}
+	return res, nil
+}
+
+// Schema returns the types declared for the bindings of the table. Bindings
+// without a declared type are not listed.
+func (t *Table) Schema() Schema {
+	s := make(Schema, len(t.types))
+	for b, ct := range t.types {
+		s[b] = ct
+	}
+	return s
+}
+
+// Type returns the type declared for the binding, or AnyType if none was
+// declared.
+func (t *Table) Type(b string) CellType {
+	return t.types[b]
+}
+
+// CheckRow returns an error if any of the cells of the row does not match the
+// type declared for its binding. Null cells match any type.
+func (t *Table) CheckRow(r Row) error {
+	for b, ct := range t.types {
+		c := r[b]
+		if c.IsNull() {
+			continue
+		}
+		if got := c.Type(); got != ct {
+			return fmt.Errorf("binding %q requires %v values; got %v value %s instead", b, ct, got, c)
+		}
+	}
+	return nil
+}
+
+// AddCheckedRow adds a row to the end of a table after checking that its cells
+// match the declared types of their bindings. Unlike AddRow, it fails for rows
+// that do not match.
+func (t *Table) AddCheckedRow(r Row) error {
+	if err := t.CheckRow(r); err != nil {
+		return err
+	}
+	t.AddRow(r)
+	return nil
+}
+
+// addTypes declares the types of the provided schema for the bindings that do
+// not have a type declared yet.
+func (t *Table) addTypes(s Schema) {
+	for b, ct := range s {
+		if _, ok := t.types[b]; ok || ct == AnyType {
+			continue
+		}
+		if t.types == nil {
+			t.types = make(Schema)
+		}
+		t.types[b] = ct
+	}
+}
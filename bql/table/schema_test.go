@@ -0,0 +1,164 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+)
+
+func TestCellType(t *testing.T) {
+	b := literal.DefaultBuilder()
+	lc := func(s string) *Cell {
+		l, err := b.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Cell{L: l}
+	}
+	now := time.Now()
+	testTable := []struct {
+		c    *Cell
+		want CellType
+	}{
+		{nil, AnyType},
+		{NullCell(), AnyType},
+		{&Cell{S: "a"}, StringType},
+		{&Cell{N: node.NewBlankNode()}, NodeType},
+		{&Cell{T: &now}, TimeType},
+		{lc(`"true"^^type:bool`), BoolType},
+		{lc(`"1"^^type:int64`), Int64Type},
+		{lc(`"1"^^type:float64`), Float64Type},
+		{lc(`"a"^^type:text`), TextType},
+		{lc(`"1s"^^type:duration`), DurationType},
+		{lc(`"1.5"^^type:float32`), Float32Type},
+		{lc(`"1.5"^^type:decimal`), DecimalType},
+		{lc(`"2015-07-19"^^type:date`), DateType},
+	}
+	for _, entry := range testTable {
+		if got := entry.c.Type(); got != entry.want {
+			t.Errorf("Cell(%v).Type returned the wrong type; got %v, want %v", entry.c, got, entry.want)
+		}
+	}
+}
+
+func TestNewWithSchema(t *testing.T) {
+	bs := []string{"?s", "?age"}
+	tbl, err := New(bs, Schema{"?s": NodeType}, Schema{"?age": Int64Type, "?s": NodeType})
+	if err != nil {
+		t.Fatalf("table.New failed with error %v", err)
+	}
+	if got, want := tbl.Schema(), (Schema{"?s": NodeType, "?age": Int64Type}); !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Schema returned the wrong schema; got %v, want %v", got, want)
+	}
+	for _, ss := range [][]Schema{
+		{{"?unknown": NodeType}},
+		{{"?s": NodeType}, {"?s": TextType}},
+	} {
+		if _, err := New(bs, ss...); err == nil {
+			t.Errorf("table.New(%v, %v) should have failed", bs, ss)
+		}
+	}
+}
+
+func TestAddCheckedRow(t *testing.T) {
+	b := literal.DefaultBuilder()
+	i, err := b.Parse(`"5"^^type:int64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := b.Parse(`"5"^^type:float64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := node.NewBlankNode()
+	tbl, err := New([]string{"?s", "?age", "?x"}, Schema{"?s": NodeType, "?age": Int64Type})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []Row{
+		{"?s": &Cell{N: n}, "?age": &Cell{L: i}, "?x": &Cell{S: "anything"}},
+		{"?s": &Cell{N: n}, "?age": NullCell()},
+		{},
+	} {
+		if err := tbl.AddCheckedRow(r); err != nil {
+			t.Errorf("table.AddCheckedRow(%v) failed with error %v", r, err)
+		}
+	}
+	for _, r := range []Row{
+		{"?s": &Cell{S: "a"}},
+		{"?s": &Cell{N: n}, "?age": &Cell{L: f}},
+	} {
+		if err := tbl.AddCheckedRow(r); err == nil {
+			t.Errorf("table.AddCheckedRow(%v) should have failed", r)
+		}
+	}
+	if got, want := tbl.NumRows(), 3; got != want {
+		t.Errorf("table.AddCheckedRow added the wrong number of rows; got %d, want %d", got, want)
+	}
+}
+
+func TestSchemaPropagation(t *testing.T) {
+	t1, err := New([]string{"?s", "?age", "?name"}, Schema{"?s": NodeType, "?age": Int64Type, "?name": TextType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := New([]string{"?s", "?t"}, Schema{"?t": TimeType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := t1.Join(context.Background(), t2, LeftOuterJoin, nil); err != nil {
+		t.Fatalf("table.Join failed with error %v", err)
+	}
+	if got, want := t1.Type("?t"), TimeType; got != want {
+		t.Errorf("table.Join failed to declare the type of ?t; got %v, want %v", got, want)
+	}
+	g, err := t1.Group([]string{"?s"}, []Aggregation{
+		{Kind: Count, Binding: "?name", Alias: "?n"},
+		{Kind: Sum, Binding: "?age", Alias: "?sum"},
+		{Kind: Avg, Binding: "?age", Alias: "?avg"},
+		{Kind: Max, Binding: "?name", Alias: "?max"},
+		{Kind: Min, Binding: "?t", Alias: "?min"},
+	})
+	if err != nil {
+		t.Fatalf("table.Group failed with error %v", err)
+	}
+	want := Schema{"?s": NodeType, "?n": Int64Type, "?sum": Int64Type, "?avg": Float64Type, "?max": TextType, "?min": TimeType}
+	if got := g.Schema(); !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Group returned the wrong schema; got %v, want %v", got, want)
+	}
+	if _, err := t1.Group(nil, []Aggregation{{Kind: Sum, Binding: "?name", Alias: "?sum"}}); err == nil {
+		t.Errorf("table.Group should have failed to sum a text binding")
+	}
+	if err := g.Project([]string{"?avg", "?s"}); err != nil {
+		t.Fatalf("table.Project failed with error %v", err)
+	}
+	if got, want := g.Schema(), (Schema{"?s": NodeType, "?avg": Float64Type}); !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Project returned the wrong schema; got %v, want %v", got, want)
+	}
+	var buf bytes.Buffer
+	if err := g.ToCSV(&buf, CSVOptions{TypedHeader: true}); err != nil {
+		t.Fatalf("table.ToCSV failed with error %v", err)
+	}
+	if got, want := buf.String(), "?avg:float64,?s:node\n"; got != want {
+		t.Errorf("table.ToCSV returned the wrong typed header; got %q, want %q", got, want)
+	}
+}
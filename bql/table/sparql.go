@@ -0,0 +1,235 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+)
+
+// sparqlNS is the namespace of the SPARQL Query Results XML Format.
+const sparqlNS = "http://www.w3.org/2005/sparql-results#"
+
+// sparqlTerm is the SPARQL results representation of a cell. Value is a string
+// for all terms but embedded triples, which hold a *sparqlTriple.
+type sparqlTerm struct {
+	Type     string      `json:"type"`
+	Value    interface{} `json:"value"`
+	Datatype string      `json:"datatype,omitempty"`
+}
+
+// sparqlTriple is the SPARQL-star results representation of an embedded
+// triple.
+type sparqlTriple struct {
+	Subject   *sparqlTerm `json:"subject"`
+	Predicate *sparqlTerm `json:"predicate"`
+	Object    *sparqlTerm `json:"object"`
+}
+
+// sparqlVars returns the SPARQL variable names of the provided bindings.
+func sparqlVars(bs []string) []string {
+	var vs []string
+	for _, b := range bs {
+		vs = append(vs, strings.TrimPrefix(b, "?"))
+	}
+	return vs
+}
+
+// ToSPARQLJSON writes the table to the provided writer using the W3C SPARQL
+// 1.1 Query Results JSON Format. Null cells are left unbound.
+func (t *Table) ToSPARQLJSON(w io.Writer) error {
+	type results struct {
+		Bindings []map[string]*sparqlTerm `json:"bindings"`
+	}
+	res := struct {
+		Head struct {
+			Vars []string `json:"vars"`
+		} `json:"head"`
+		Results results `json:"results"`
+	}{}
+	res.Head.Vars = sparqlVars(t.bs)
+	if res.Head.Vars == nil {
+		res.Head.Vars = []string{}
+	}
+	res.Results.Bindings = []map[string]*sparqlTerm{}
+	err := t.walk(-1, func(r cells) error {
+		m := make(map[string]*sparqlTerm)
+		for i, b := range t.bs {
+			if c := r.Cell(b); !c.IsNull() {
+				st, err := sparqlCell(c)
+				if err != nil {
+					return err
+				}
+				m[res.Head.Vars[i]] = st
+			}
+		}
+		res.Results.Bindings = append(res.Results.Bindings, m)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(res)
+}
+
+// ToSPARQLXML writes the table to the provided writer using the W3C SPARQL
+// Query Results XML Format. Null cells are left unbound.
+func (t *Table) ToSPARQLXML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	vs := sparqlVars(t.bs)
+	bw.WriteString(xml.Header)
+	fmt.Fprintf(bw, "<sparql xmlns=%q>\n<head>\n", sparqlNS)
+	for _, v := range vs {
+		fmt.Fprintf(bw, "<variable name=\"%s\"/>\n", xmlEscape(v))
+	}
+	bw.WriteString("</head>\n<results>\n")
+	err := t.walk(-1, func(r cells) error {
+		bw.WriteString("<result>\n")
+		for i, b := range t.bs {
+			c := r.Cell(b)
+			if c.IsNull() {
+				continue
+			}
+			st, err := sparqlCell(c)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(bw, "<binding name=\"%s\">", xmlEscape(vs[i]))
+			writeSPARQLXMLTerm(bw, st)
+			bw.WriteString("</binding>\n")
+		}
+		bw.WriteString("</result>\n")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteString("</results>\n</sparql>\n")
+	return bw.Flush()
+}
+
+// writeSPARQLXMLTerm writes the XML representation of the provided term.
+func writeSPARQLXMLTerm(bw *bufio.Writer, st *sparqlTerm) {
+	if tr, ok := st.Value.(*sparqlTriple); ok {
+		bw.WriteString("<triple><subject>")
+		writeSPARQLXMLTerm(bw, tr.Subject)
+		bw.WriteString("</subject><predicate>")
+		writeSPARQLXMLTerm(bw, tr.Predicate)
+		bw.WriteString("</predicate><object>")
+		writeSPARQLXMLTerm(bw, tr.Object)
+		bw.WriteString("</object></triple>")
+		return
+	}
+	v := xmlEscape(st.Value.(string))
+	switch {
+	case st.Datatype != "":
+		fmt.Fprintf(bw, "<literal datatype=\"%s\">%s</literal>", xmlEscape(st.Datatype), v)
+	default:
+		fmt.Fprintf(bw, "<%s>%s</%s>", st.Type, v, st.Type)
+	}
+}
+
+// xmlEscape returns the provided text escaped for XML character data and
+// attribute values.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// sparqlCell returns the SPARQL term for the provided cell. Nodes are mapped to
+// IRIs, or blank nodes if they are of type /_, predicates to IRIs, and
+// literals and time anchors to typed literals using the XML Schema datatypes.
+// Embedded triples are mapped to SPARQL-star triple terms.
+func sparqlCell(c *Cell) (*sparqlTerm, error) {
+	switch {
+	case c.S != "":
+		return &sparqlTerm{Type: "literal", Value: c.S}, nil
+	case c.N != nil:
+		return sparqlNode(c.N), nil
+	case c.P != nil:
+		return &sparqlTerm{Type: "uri", Value: c.P.String()}, nil
+	case c.L != nil:
+		return sparqlLiteral(c.L)
+	case c.T != nil:
+		return &sparqlTerm{Type: "literal", Value: c.T.Format(time.RFC3339Nano), Datatype: triple.XSDNamespace + "dateTime"}, nil
+	case c.E != nil:
+		return sparqlTripleTerm(c.E)
+	}
+	return nil, fmt.Errorf("cannot represent null cells as SPARQL terms")
+}
+
+// sparqlNode returns the SPARQL term for the provided node.
+func sparqlNode(n *node.Node) *sparqlTerm {
+	if n.Type().String() == "/_" {
+		return &sparqlTerm{Type: "bnode", Value: n.ID().String()}
+	}
+	return &sparqlTerm{Type: "uri", Value: n.String()}
+}
+
+// sparqlLiteral returns the SPARQL term for the provided literal, typed with
+// the same XML Schema datatypes used for RDF literals. Text literals are mapped
+// to plain literals.
+func sparqlLiteral(l *literal.Literal) (*sparqlTerm, error) {
+	v, dt, err := triple.RDFLexicalForm(l)
+	if err != nil {
+		return nil, fmt.Errorf("cannot represent literal %s as a SPARQL term; %v", l, err)
+	}
+	return &sparqlTerm{Type: "literal", Value: v, Datatype: dt}, nil
+}
+
+// sparqlTripleTerm returns the SPARQL-star term for the provided triple.
+func sparqlTripleTerm(t *triple.Triple) (*sparqlTerm, error) {
+	o, err := sparqlObject(t.O())
+	if err != nil {
+		return nil, err
+	}
+	return &sparqlTerm{
+		Type: "triple",
+		Value: &sparqlTriple{
+			Subject:   sparqlNode(t.S()),
+			Predicate: &sparqlTerm{Type: "uri", Value: t.P().String()},
+			Object:    o,
+		},
+	}, nil
+}
+
+// sparqlObject returns the SPARQL term for the provided triple object.
+func sparqlObject(o *triple.Object) (*sparqlTerm, error) {
+	switch o.Kind() {
+	case triple.NodeKind:
+		n, _ := o.AsNode()
+		return sparqlNode(n), nil
+	case triple.PredicateKind:
+		p, _ := o.AsPredicate()
+		return &sparqlTerm{Type: "uri", Value: p.String()}, nil
+	case triple.LiteralKind:
+		l, _ := o.AsLiteral()
+		return sparqlLiteral(l)
+	case triple.TripleKind:
+		t, _ := o.AsTriple()
+		return sparqlTripleTerm(t)
+	}
+	return nil, fmt.Errorf("unknown object type in object %q", o)
+}
@@ -0,0 +1,164 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+func testSPARQLTable(t *testing.T) *Table {
+	b := literal.DefaultBuilder()
+	lc := func(s string) *Cell {
+		l, err := b.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Cell{L: l}
+	}
+	n, err := node.Parse("/u<john>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bn, err := node.Parse("/_<b1>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.NewImmutable("knows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := triple.New(n, p, triple.NewNodeObject(bn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2015, 7, 19, 13, 12, 4, 0, time.UTC)
+	tbl, err := New([]string{"?s", "?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []Row{
+		{"?s": &Cell{N: n}, "?v": lc(`"5"^^type:int64`)},
+		{"?s": &Cell{N: bn}, "?v": lc(`"a<b"^^type:text`)},
+		{"?s": &Cell{P: p}, "?v": &Cell{T: &ts}},
+		{"?s": &Cell{E: tr}, "?v": NullCell()},
+		{"?v": lc(`"1m30s"^^type:duration`)},
+		{"?s": &Cell{S: "plain"}, "?v": lc(`"true"^^type:bool`)},
+	} {
+		tbl.AddRow(r)
+	}
+	return tbl
+}
+
+func TestToSPARQLJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := testSPARQLTable(t).ToSPARQLJSON(&b); err != nil {
+		t.Fatalf("table.ToSPARQLJSON failed with error %v", err)
+	}
+	var got interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("table.ToSPARQLJSON returned invalid JSON %q; %v", b.String(), err)
+	}
+	var want interface{}
+	if err := json.Unmarshal([]byte(`{
+		"head": {"vars": ["s", "v"]},
+		"results": {"bindings": [
+			{"s": {"type": "uri", "value": "/u<john>"}, "v": {"type": "literal", "value": "5", "datatype": "http://www.w3.org/2001/XMLSchema#long"}},
+			{"s": {"type": "bnode", "value": "b1"}, "v": {"type": "literal", "value": "a<b"}},
+			{"s": {"type": "uri", "value": "\"knows\"@[]"}, "v": {"type": "literal", "value": "2015-07-19T13:12:04Z", "datatype": "http://www.w3.org/2001/XMLSchema#dateTime"}},
+			{"s": {"type": "triple", "value": {
+				"subject": {"type": "uri", "value": "/u<john>"},
+				"predicate": {"type": "uri", "value": "\"knows\"@[]"},
+				"object": {"type": "bnode", "value": "b1"}}}},
+			{"v": {"type": "literal", "value": "PT90S", "datatype": "http://www.w3.org/2001/XMLSchema#duration"}},
+			{"s": {"type": "literal", "value": "plain"}, "v": {"type": "literal", "value": "true", "datatype": "http://www.w3.org/2001/XMLSchema#boolean"}}
+		]}
+	}`), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("table.ToSPARQLJSON returned the wrong results; got %s", b.String())
+	}
+}
+
+func TestToSPARQLJSONEmpty(t *testing.T) {
+	tbl, err := New([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := tbl.ToSPARQLJSON(&b); err != nil {
+		t.Fatalf("table.ToSPARQLJSON failed with error %v", err)
+	}
+	if got, want := b.String(), "{\"head\":{\"vars\":[]},\"results\":{\"bindings\":[]}}\n"; got != want {
+		t.Errorf("table.ToSPARQLJSON returned the wrong results; got %q, want %q", got, want)
+	}
+}
+
+func TestToSPARQLXML(t *testing.T) {
+	var b bytes.Buffer
+	if err := testSPARQLTable(t).ToSPARQLXML(&b); err != nil {
+		t.Fatalf("table.ToSPARQLXML failed with error %v", err)
+	}
+	want := xml.Header + `<sparql xmlns="http://www.w3.org/2005/sparql-results#">
+<head>
+<variable name="s"/>
+<variable name="v"/>
+</head>
+<results>
+<result>
+<binding name="s"><uri>/u&lt;john&gt;</uri></binding>
+<binding name="v"><literal datatype="http://www.w3.org/2001/XMLSchema#long">5</literal></binding>
+</result>
+<result>
+<binding name="s"><bnode>b1</bnode></binding>
+<binding name="v"><literal>a&lt;b</literal></binding>
+</result>
+<result>
+<binding name="s"><uri>&#34;knows&#34;@[]</uri></binding>
+<binding name="v"><literal datatype="http://www.w3.org/2001/XMLSchema#dateTime">2015-07-19T13:12:04Z</literal></binding>
+</result>
+<result>
+<binding name="s"><triple><subject><uri>/u&lt;john&gt;</uri></subject><predicate><uri>&#34;knows&#34;@[]</uri></predicate><object><bnode>b1</bnode></object></triple></binding>
+</result>
+<result>
+<binding name="v"><literal datatype="http://www.w3.org/2001/XMLSchema#duration">PT90S</literal></binding>
+</result>
+<result>
+<binding name="s"><literal>plain</literal></binding>
+<binding name="v"><literal datatype="http://www.w3.org/2001/XMLSchema#boolean">true</literal></binding>
+</result>
+</results>
+</sparql>
+`
+	if got := b.String(); got != want {
+		t.Errorf("table.ToSPARQLXML returned the wrong results; got\n%s\nwant\n%s", got, want)
+	}
+	var doc struct {
+		XMLName xml.Name `xml:"sparql"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Errorf("table.ToSPARQLXML returned invalid XML; %v", err)
+	}
+}
@@ -0,0 +1,465 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// Run contains rows written to a temporary file. Rows are read back in the
+// order they were written once the run is rewound.
+type Run struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *rowEncoder
+	dec *rowDecoder
+	n   int
+}
+
+// NewRun returns an empty run backed by a new temporary file whose name starts
+// with the provided prefix.
+func NewRun(prefix string) (*Run, error) {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &Run{f: f, w: w, enc: newRowEncoder(w)}, nil
+}
+
+// Write appends the row to the run.
+func (r *Run) Write(row Row) error {
+	if r.enc == nil {
+		return fmt.Errorf("cannot write to run %q after being rewound", r.f.Name())
+	}
+	if err := r.enc.encode(row); err != nil {
+		return err
+	}
+	r.n++
+	return nil
+}
+
+// Name returns the name of the temporary file backing the run.
+func (r *Run) Name() string {
+	return r.f.Name()
+}
+
+// Len returns the number of rows written to the run.
+func (r *Run) Len() int {
+	return r.n
+}
+
+// Rewind flushes the rows written and prepares the run to read them from the
+// start. No more rows can be written afterwards.
+func (r *Run) Rewind() error {
+	if r.enc != nil {
+		if err := r.w.Flush(); err != nil {
+			return err
+		}
+		r.enc = nil
+	}
+	if _, err := r.f.Seek(0, 0); err != nil {
+		return err
+	}
+	r.dec = newRowDecoder(bufio.NewReader(r.f))
+	return nil
+}
+
+// Read returns the next row of a rewound run, or nil once all of them have
+// been read.
+func (r *Run) Read() (Row, error) {
+	if r.dec == nil {
+		return nil, fmt.Errorf("cannot read from run %q before being rewound", r.f.Name())
+	}
+	return r.dec.decode()
+}
+
+// Close releases the run and removes its temporary file.
+func (r *Run) Close() error {
+	err := r.f.Close()
+	if rerr := os.Remove(r.f.Name()); rerr != nil && err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// runReader reads the rows of a run independently of the run and of other
+// readers.
+type runReader struct {
+	f   *os.File
+	dec *rowDecoder
+}
+
+// open returns a reader for the rows written to the run so far. Unlike Rewind,
+// more rows can still be written to the run afterwards.
+func (r *Run) open() (*runReader, error) {
+	if r.enc != nil {
+		if err := r.w.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(r.f.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &runReader{f: f, dec: newRowDecoder(bufio.NewReader(f))}, nil
+}
+
+// read returns the next row, or nil once all of them have been read.
+func (rr *runReader) read() (Row, error) {
+	return rr.dec.decode()
+}
+
+// close releases the reader.
+func (rr *runReader) close() error {
+	return rr.f.Close()
+}
+
+// SpillRow adds a row to the end of the table, keeping it on a temporary file
+// instead of memory. Spilled rows are read back from disk one at a time when
+// the table is iterated or written out, and they are only loaded into memory
+// by the operations that need random access to the rows. Rows added with
+// AddRow after spilling some also load them first to keep the row order.
+// Tables with spilled rows should be closed once no longer needed.
+func (t *Table) SpillRow(r Row) error {
+	if t.spilled == nil {
+		run, err := NewRun("badwolf-table-")
+		if err != nil {
+			return err
+		}
+		t.spilled = run
+	}
+	return t.spilled.Write(r)
+}
+
+// Close removes the temporary file holding the rows spilled to disk, if any,
+// which are dropped from the table. It returns the first error found while
+// loading spilled rows into memory.
+func (t *Table) Close() error {
+	if t.spilled != nil {
+		if err := t.spilled.Close(); err != nil && t.err == nil {
+			t.err = err
+		}
+		t.spilled = nil
+	}
+	return t.err
+}
+
+// load moves the rows spilled to disk, if any, into memory. Errors are also
+// kept to be returned by Close, since some of the operations that need to
+// load the rows cannot report them.
+func (t *Table) load() error {
+	if t.spilled == nil {
+		return nil
+	}
+	run := t.spilled
+	t.spilled = nil
+	err := readRun(run, -1, func(r Row) error {
+		t.AddRow(r)
+		return nil
+	})
+	if cerr := run.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to load the rows spilled to %q; %v", run.Name(), err)
+		if t.err == nil {
+			t.err = err
+		}
+	}
+	return err
+}
+
+// walk calls f with the cells of the first n rows of the table in order, or of
+// all of them if n is negative. Spilled rows are read from disk one at a time
+// without loading them into memory.
+func (t *Table) walk(n int, f func(cells) error) error {
+	for i := 0; i < t.n; i++ {
+		if i == n {
+			return nil
+		}
+		if err := f(RowView{t: t, i: i}); err != nil {
+			return err
+		}
+	}
+	if t.spilled == nil || (n >= 0 && n <= t.n) {
+		return nil
+	}
+	if n >= 0 {
+		n -= t.n
+	}
+	return readRun(t.spilled, n, func(r Row) error {
+		return f(r)
+	})
+}
+
+// readRun calls f with the first n rows of the run, or all of them if n is
+// negative.
+func readRun(run *Run, n int, f func(Row) error) error {
+	rr, err := run.open()
+	if err != nil {
+		return err
+	}
+	defer rr.close()
+	for i := 0; i != n; i++ {
+		r, err := rr.read()
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			return nil
+		}
+		if err := f(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spillMagic identifies the files written by Table.Spill.
+const spillMagic = "badwolf-table\x01"
+
+// Spill writes the table to a new temporary file in the provided directory and
+// returns its path. If dir is the empty string, the default directory for
+// temporary files is used. The file contains the bindings, the declared types,
+// and the rows of the table using the same compact binary encoding as runs.
+// The table can be loaded back using LoadSpilled.
+func (t *Table) Spill(dir string) (string, error) {
+	f, err := ioutil.TempFile(dir, "badwolf-table-")
+	if err != nil {
+		return "", err
+	}
+	if err := t.writeSpilled(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("table.Spill failed to write %q: %v", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// writeSpilled writes the encoded table to the provided writer.
+func (t *Table) writeSpilled(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := newRowEncoder(bw)
+	bw.WriteString(spillMagic)
+	enc.uvarint(uint64(len(t.bs)))
+	for _, b := range t.bs {
+		enc.string(b)
+	}
+	var tbs []string
+	for b := range t.types {
+		tbs = append(tbs, b)
+	}
+	sort.Strings(tbs)
+	enc.uvarint(uint64(len(tbs)))
+	for _, b := range tbs {
+		enc.string(b)
+		enc.uvarint(uint64(t.types[b]))
+	}
+	enc.uvarint(uint64(t.NumRows()))
+	err := t.walk(-1, func(r cells) error {
+		return enc.encode(toRow(r))
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// LoadSpilled returns the table stored on the provided file written by
+// Table.Spill. The file is not removed.
+func LoadSpilled(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := readSpilled(f)
+	if err != nil {
+		return nil, fmt.Errorf("table.LoadSpilled failed to read %q: %v", path, err)
+	}
+	return t, nil
+}
+
+// readSpilled returns the table encoded on the provided reader.
+func readSpilled(r io.Reader) (*Table, error) {
+	dec := newRowDecoder(bufio.NewReader(r))
+	magic := make([]byte, len(spillMagic))
+	if _, err := io.ReadFull(dec.r, magic); err != nil || string(magic) != spillMagic {
+		return nil, fmt.Errorf("not a spilled table")
+	}
+	nbs, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	var bs []string
+	for i := uint64(0); i < nbs; i++ {
+		b, err := dec.string()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		bs = append(bs, b)
+	}
+	nts, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	s := make(Schema)
+	for i := uint64(0); i < nts; i++ {
+		b, err := dec.string()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		ct, err := binary.ReadUvarint(dec.r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if ct > uint64(DateType) {
+			return nil, fmt.Errorf("invalid type %d for binding %q", ct, b)
+		}
+		s[b] = CellType(ct)
+	}
+	n, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	t, err := New(bs, s)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < n; i++ {
+		r, err := dec.decode()
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		t.AddRow(r)
+	}
+	return t, nil
+}
+
+// Product contains the dot product of two tables spilled to runs on disk. Its
+// rows are read back one at a time, so it never needs to be held in memory.
+type Product struct {
+	bs   []string
+	n    int
+	runs []*Run
+}
+
+// SpillDotProduct computes the dot product with the provided table without
+// modifying any of them. The resulting rows are written to runs of at most
+// runSize rows each. It stops and returns the context error once the provided
+// context is done.
+func (t *Table) SpillDotProduct(ctx context.Context, t2 *Table, runSize int) (*Product, error) {
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	if err := t2.load(); err != nil {
+		return nil, err
+	}
+	if !disjointBinding(t.mbs, t2.mbs) {
+		return nil, fmt.Errorf("DotProduct operations requires disjoint bindingts; instead got %v and %v", t.mbs, t2.mbs)
+	}
+	if runSize <= 0 {
+		return nil, fmt.Errorf("invalid run size %d; it must be positive", runSize)
+	}
+	p := &Product{bs: append(append([]string{}, t.bs...), t2.bs...)}
+	var run *Run
+	r2s := t2.rows()
+	for i := 0; i < t.n; i++ {
+		if err := ctx.Err(); err != nil {
+			p.Close()
+			return nil, err
+		}
+		r1 := t.row(i)
+		for _, r2 := range r2s {
+			if run == nil || run.Len() >= runSize {
+				nr, err := NewRun("badwolf-product-")
+				if err != nil {
+					p.Close()
+					return nil, err
+				}
+				run = nr
+				p.runs = append(p.runs, run)
+			}
+			if err := run.Write(MergeRows([]Row{r1, r2})); err != nil {
+				p.Close()
+				return nil, err
+			}
+			p.n++
+		}
+	}
+	for _, run := range p.runs {
+		if err := run.Rewind(); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Bindings returns the bindings of the product rows.
+func (p *Product) Bindings() []string {
+	return p.bs
+}
+
+// NumRows returns the number of rows of the product.
+func (p *Product) NumRows() int {
+	return p.n
+}
+
+// Next returns the next row of the product, or nil once all of them have been
+// read. The runs already read are released.
+func (p *Product) Next() (Row, error) {
+	for len(p.runs) > 0 {
+		r, err := p.runs[0].Read()
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			return r, nil
+		}
+		if err := p.runs[0].Close(); err != nil {
+			return nil, err
+		}
+		p.runs = p.runs[1:]
+	}
+	return nil, nil
+}
+
+// Close releases all the runs of the product.
+func (p *Product) Close() error {
+	var err error
+	for _, run := range p.runs {
+		if rerr := run.Close(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	p.runs = nil
+	return err
+}
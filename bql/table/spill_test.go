@@ -0,0 +1,393 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+func TestRun(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.Parse(`"parent_of"@[2015-01-01T00:00:00Z]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := literal.DefaultBuilder().Parse(`"1.5"^^type:float64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := triple.New(n, p, triple.NewLiteralObject(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	rws := []Row{
+		{
+			"?s": &Cell{S: "foo"},
+			"?n": &Cell{N: n},
+			"?p": &Cell{P: p},
+		},
+		{
+			"?l": &Cell{L: l},
+			"?t": &Cell{T: &ts},
+			"?e": &Cell{E: tr},
+		},
+	}
+	run, err := NewRun("badwolf-test-")
+	if err != nil {
+		t.Fatalf("table.NewRun failed with error %v", err)
+	}
+	for _, r := range rws {
+		if err := run.Write(r); err != nil {
+			t.Fatalf("run.Write failed with error %v", err)
+		}
+	}
+	if got, want := run.Len(), len(rws); got != want {
+		t.Errorf("run.Len returned the wrong number of rows; got %d, want %d", got, want)
+	}
+	if _, err := run.Read(); err == nil {
+		t.Errorf("run.Read should have failed before rewinding the run")
+	}
+	if err := run.Rewind(); err != nil {
+		t.Fatalf("run.Rewind failed with error %v", err)
+	}
+	if err := run.Write(rws[0]); err == nil {
+		t.Errorf("run.Write should have failed after rewinding the run")
+	}
+	for i, want := range rws {
+		got, err := run.Read()
+		if err != nil {
+			t.Fatalf("run.Read failed with error %v", err)
+		}
+		for b, c := range want {
+			if got[b].String() != c.String() {
+				t.Errorf("run.Read returned the wrong cell for %s on row %d; got %v, want %v", b, i, got[b], c)
+			}
+		}
+	}
+	if r, err := run.Read(); r != nil || err != nil {
+		t.Errorf("run.Read should have returned no more rows; got %v, %v", r, err)
+	}
+	if err := run.Close(); err != nil {
+		t.Fatalf("run.Close failed with error %v", err)
+	}
+	if _, err := os.Stat(run.Name()); !os.IsNotExist(err) {
+		t.Errorf("run.Close failed to remove %q", run.Name())
+	}
+}
+
+func TestSpillDotProduct(t *testing.T) {
+	testTable := []struct {
+		t       *Table
+		t2      *Table
+		runSize int
+		runs    int
+	}{
+		{
+			t:       testDotTable(t, []string{"?foo"}, 3),
+			t2:      testDotTable(t, []string{"?bar"}, 3),
+			runSize: 4,
+			runs:    3,
+		},
+		{
+			t:       testDotTable(t, []string{"?foo"}, 3),
+			t2:      testDotTable(t, []string{"?bar", "?other"}, 6),
+			runSize: 100,
+			runs:    1,
+		},
+		{
+			t:       testDotTable(t, []string{"?foo"}, 3),
+			t2:      testDotTable(t, []string{"?bar"}, 0),
+			runSize: 4,
+			runs:    0,
+		},
+	}
+	for _, entry := range testTable {
+		prd, err := entry.t.SpillDotProduct(context.Background(), entry.t2, entry.runSize)
+		if err != nil {
+			t.Fatalf("table.SpillDotProduct failed with error %v", err)
+		}
+		if got, want := len(prd.runs), entry.runs; got != want {
+			t.Errorf("table.SpillDotProduct wrote the wrong number of runs; got %d, want %d", got, want)
+		}
+		var files []string
+		for _, run := range prd.runs {
+			files = append(files, run.Name())
+		}
+		var got []Row
+		for {
+			r, err := prd.Next()
+			if err != nil {
+				t.Fatalf("product.Next failed with error %v", err)
+			}
+			if r == nil {
+				break
+			}
+			got = append(got, r)
+		}
+		if err := entry.t.DotProduct(entry.t2); err != nil {
+			t.Fatalf("table.DotProduct failed with error %v", err)
+		}
+		if want := entry.t.Rows(); len(got) != len(want) || len(got) != prd.NumRows() {
+			t.Errorf("product returned the wrong number of rows; got %d, want %d", len(got), len(want))
+		} else {
+			for i := range got {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Errorf("product returned the wrong row at position %d; got %v, want %v", i, got[i], want[i])
+				}
+			}
+		}
+		if got, want := len(prd.Bindings()), len(entry.t.Bindings()); got != want {
+			t.Errorf("product returned the wrong number of bindings; got %d, want %d", got, want)
+		}
+		if err := prd.Close(); err != nil {
+			t.Fatalf("product.Close failed with error %v", err)
+		}
+		for _, f := range files {
+			if _, err := os.Stat(f); !os.IsNotExist(err) {
+				t.Errorf("product failed to remove run %q", f)
+			}
+		}
+	}
+}
+
+func TestSpillDotProductErrors(t *testing.T) {
+	t1 := testDotTable(t, []string{"?foo"}, 3)
+	if _, err := t1.SpillDotProduct(context.Background(), testDotTable(t, []string{"?foo"}, 3), 10); err == nil {
+		t.Errorf("table.SpillDotProduct should have failed for tables sharing bindings")
+	}
+	if _, err := t1.SpillDotProduct(context.Background(), testDotTable(t, []string{"?bar"}, 3), 0); err == nil {
+		t.Errorf("table.SpillDotProduct should have failed for an invalid run size")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := t1.SpillDotProduct(ctx, testDotTable(t, []string{"?bar"}, 3), 10); err != context.Canceled {
+		t.Errorf("table.SpillDotProduct should have failed with %v for a done context; got %v", context.Canceled, err)
+	}
+}
+
+func TestSpill(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip, err := predicate.Parse(`"knows"@[]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := predicate.Parse(`"parent_of"@[2015-01-01T00:00:00.000000001Z]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := triple.New(n, ip, triple.NewNodeObject(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ls []*literal.Literal
+	for _, v := range []interface{}{true, int64(-42), 1.5, "some text", []byte("blob"), 3 * time.Second} {
+		var typ literal.Type
+		switch v.(type) {
+		case bool:
+			typ = literal.Bool
+		case int64:
+			typ = literal.Int64
+		case float64:
+			typ = literal.Float64
+		case string:
+			typ = literal.Text
+		case []byte:
+			typ = literal.Blob
+		case time.Duration:
+			typ = literal.Duration
+		}
+		l, err := literal.DefaultBuilder().Build(typ, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls = append(ls, l)
+	}
+	ts := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	tbl, err := New([]string{"?a", "?b"}, Schema{"?a": StringType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?a": &Cell{S: "foo"}, "?b": &Cell{N: n}})
+	tbl.AddRow(Row{"?a": &Cell{S: "bar"}, "?b": &Cell{P: ip}})
+	tbl.AddRow(Row{"?b": &Cell{P: tp}})
+	tbl.AddRow(Row{"?a": NullCell(), "?b": &Cell{T: &ts}})
+	tbl.AddRow(Row{"?b": &Cell{E: tr}})
+	for _, l := range ls {
+		tbl.AddRow(Row{"?b": &Cell{L: l}})
+	}
+	tbl.AddRow(Row{})
+
+	dir, err := ioutil.TempDir("", "badwolf-spill-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path, err := tbl.Spill(dir)
+	if err != nil {
+		t.Fatalf("table.Spill failed with error %v", err)
+	}
+	if got, want := filepath.Dir(path), dir; got != want {
+		t.Errorf("table.Spill wrote to the wrong directory; got %q, want %q", got, want)
+	}
+	got, err := LoadSpilled(path)
+	if err != nil {
+		t.Fatalf("table.LoadSpilled failed with error %v", err)
+	}
+	if !reflect.DeepEqual(got.Bindings(), tbl.Bindings()) {
+		t.Errorf("table.LoadSpilled returned the wrong bindings; got %v, want %v", got.Bindings(), tbl.Bindings())
+	}
+	if !reflect.DeepEqual(got.Schema(), tbl.Schema()) {
+		t.Errorf("table.LoadSpilled returned the wrong schema; got %v, want %v", got.Schema(), tbl.Schema())
+	}
+	if got, want := got.NumRows(), tbl.NumRows(); got != want {
+		t.Fatalf("table.LoadSpilled returned the wrong number of rows; got %d, want %d", got, want)
+	}
+	for i, want := range tbl.Rows() {
+		if r, _ := got.Row(i); !r.Equal(want) {
+			t.Errorf("table.LoadSpilled returned the wrong row at position %d; got %v, want %v", i, r, want)
+		}
+	}
+}
+
+func TestLoadSpilledErrors(t *testing.T) {
+	tbl, err := New([]string{"?a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?a": &Cell{S: "foo"}})
+	tbl.AddRow(Row{"?a": &Cell{S: "bar"}})
+	path, err := tbl.Spill("")
+	if err != nil {
+		t.Fatalf("table.Spill failed with error %v", err)
+	}
+	defer os.Remove(path)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		desc string
+		data []byte
+	}{
+		{"empty file", nil},
+		{"bad magic", append([]byte("not-a-table"), b[len(spillMagic):]...)},
+		{"truncated header", b[:len(spillMagic)+2]},
+		{"truncated rows", b[:len(b)-2]},
+	}
+	for _, entry := range testTable {
+		if err := ioutil.WriteFile(path, entry.data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSpilled(path); err == nil {
+			t.Errorf("table.LoadSpilled should have failed for %s", entry.desc)
+		}
+	}
+	if _, err := LoadSpilled(path + "-missing"); err == nil {
+		t.Errorf("table.LoadSpilled should have failed for a missing file")
+	}
+}
+
+func TestSpillRow(t *testing.T) {
+	tbl, err := New([]string{"?a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []Row
+	for i, s := range []string{"a", "b", "c", "d", "e"} {
+		r := Row{"?a": &Cell{S: s}}
+		want = append(want, r)
+		if i < 2 {
+			tbl.AddRow(r)
+			continue
+		}
+		if err := tbl.SpillRow(r); err != nil {
+			t.Fatalf("table.SpillRow failed with error %v", err)
+		}
+	}
+	path := tbl.spilled.Name()
+	if got, want := tbl.NumRows(), len(want); got != want {
+		t.Errorf("table.NumRows returned the wrong number of rows; got %d, want %d", got, want)
+	}
+	// Iterating and writing out the table reads the spilled rows from disk.
+	it := tbl.Iterator(2)
+	var got []Row
+	for {
+		rs, err := it.Next()
+		if err != nil {
+			t.Fatalf("RowIterator.Next failed with error %v", err)
+		}
+		if len(rs) == 0 {
+			break
+		}
+		got = append(got, rs...)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("table.Iterator returned the wrong number of rows; got %d, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("table.Iterator returned the wrong row at position %d; got %v, want %v", i, got[i], want[i])
+		}
+	}
+	txt, err := tbl.ToPrettyText(0, 3)
+	if err != nil {
+		t.Fatalf("table.ToPrettyText failed with error %v", err)
+	}
+	if want := "| c  |\n+----+\n5 rows (3 shown)\n"; !strings.HasSuffix(txt.String(), want) {
+		t.Errorf("table.ToPrettyText returned the wrong text; got\n%s\nwant it to end with\n%s", txt, want)
+	}
+	if tbl.spilled == nil || tbl.n != 2 {
+		t.Errorf("writing out the table should not have loaded the spilled rows")
+	}
+	// Random access loads the spilled rows and removes their file.
+	if r, ok := tbl.Row(4); !ok || !r.Equal(want[4]) {
+		t.Errorf("table.Row(4) returned the wrong row; got %v, want %v", r, want[4])
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("loading the spilled rows should have removed %q; got error %v", path, err)
+	}
+	if err := tbl.SpillRow(Row{"?a": &Cell{S: "f"}}); err != nil {
+		t.Fatalf("table.SpillRow failed with error %v", err)
+	}
+	path = tbl.spilled.Name()
+	if err := tbl.Close(); err != nil {
+		t.Errorf("table.Close failed with error %v", err)
+	}
+	if got, want := tbl.NumRows(), len(want); got != want {
+		t.Errorf("table.Close should have dropped the spilled rows; got %d rows, want %d", got, want)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("table.Close should have removed %q; got error %v", path, err)
+	}
+}
@@ -17,10 +17,13 @@ package table
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
 	"github.com/google/badwolf/triple/predicate"
@@ -30,14 +33,24 @@ import (
 // safe for concurrency. You should take appropiate precautions if you want to
 // access it concurrently and wrap to properly control concurrent operations.
 type Table struct {
-	bs   []string
-	mbs  map[string]bool
-	data []Row
+	bs    []string
+	mbs   map[string]bool
+	cols  map[string]*column
+	n     int
+	types Schema
+
+	// spilled contains the rows kept on disk after the ones held in memory,
+	// if any, and err the first error found loading them.
+	spilled *Run
+	err     error
 }
 
 // New returns a new table that can hold data for the the given bindings. The,
-// table creation will fail if there are repeated bindings.
-func New(bs []string) (*Table, error) {
+// table creation will fail if there are repeated bindings. Optionally, the
+// types of the cells of some bindings can be declared using schemas; the table
+// creation will fail if they declare types for unknown bindings or conflicting
+// types for the same binding.
+func New(bs []string, ss ...Schema) (*Table, error) {
 	m := make(map[string]bool)
 	for _, b := range bs {
 		m[b] = true
@@ -45,23 +58,45 @@ func New(bs []string) (*Table, error) {
 	if len(m) != len(bs) {
 		return nil, fmt.Errorf("table.New does not allow duplicated bindings in %s", bs)
 	}
+	types, err := mergeSchemas(m, ss)
+	if err != nil {
+		return nil, err
+	}
 	return &Table{
-		bs:  bs,
-		mbs: m,
+		bs:    bs,
+		mbs:   m,
+		cols:  make(map[string]*column),
+		types: types,
 	}, nil
 }
 
-// Cell contains one of the possible values that form rows.
+// Cell contains one of the possible values that form rows. A cell holding no
+// value is null; null cells represent unbound values, for instance the columns
+// left empty by an outer join.
 type Cell struct {
 	S string
 	N *node.Node
 	P *predicate.Predicate
 	L *literal.Literal
 	T *time.Time
+	E *triple.Triple
+}
+
+// NullCell returns a new null cell.
+func NullCell() *Cell {
+	return &Cell{}
+}
+
+// IsNull returns true if the cell holds no value. A nil cell is also null.
+func (c *Cell) IsNull() bool {
+	return c == nil || *c == Cell{}
 }
 
 // String returns a readable representation of a cell.
 func (c *Cell) String() string {
+	if c == nil {
+		return "<NULL>"
+	}
 	if c.S != "" {
 		return c.S
 	}
@@ -77,6 +112,9 @@ func (c *Cell) String() string {
 	if c.T != nil {
 		return c.T.Format(time.RFC3339Nano)
 	}
+	if c.E != nil {
+		return triple.NewTripleObject(c.E).String()
+	}
 	return "<NULL>"
 }
 
@@ -110,28 +148,48 @@ func (r Row) ToTextLine(res *bytes.Buffer, bs []string, sep string) error {
 // AddRow adds a row to the end of a table. For preformance reasons, it does not
 // check that all bindindgs are set, nor that they are declared on table
 // creation. BQL builds valid tables, if you plan to create tables on your own
-// you should be carful to provide valid rows.
+// you should be carful to provide valid rows. The cells of the row are copied
+// into the table columns, and null cells are not stored.
 func (t *Table) AddRow(r Row) {
-	t.data = append(t.data, r)
+	t.load()
+	if t.cols == nil {
+		t.cols = make(map[string]*column)
+	}
+	for b, c := range r {
+		if !c.IsNull() {
+			t.appendCell(b, t.n, c)
+		}
+	}
+	t.n++
 }
 
-// NumRows returns the number of rows currently available on the table.
+// NumRows returns the number of rows currently available on the table,
+// including the ones spilled to disk.
 func (t *Table) NumRows() int {
-	return len(t.data)
+	if t.spilled != nil {
+		return t.n + t.spilled.Len()
+	}
+	return t.n
 }
 
 // Row returns the requested row. Rows start at 0. Also, if you request a row
-// beyond it will return nil, and the ok boolean will be false.
+// beyond it will return nil, and the ok boolean will be false. The returned
+// row is built out of the table columns, so changing it does not change the
+// table.
 func (t *Table) Row(i int) (Row, bool) {
-	if i >= len(t.data) {
+	t.load()
+	if i < 0 || i >= t.n {
 		return nil, false
 	}
-	return t.data[i], true
+	return t.row(i), true
 }
 
-// Rows returns all the available rows.
+// Rows returns all the available rows. As Row does, it builds the rows out of
+// the table columns. Use View to access the cells of large tables without
+// building all their rows.
 func (t *Table) Rows() []Row {
-	return t.data
+	t.load()
+	return t.rows()
 }
 
 // AddBindings add the new binings provided to the table.
@@ -160,18 +218,21 @@ func (t *Table) ToText(sep string) (*bytes.Buffer, error) {
 	res, row := &bytes.Buffer{}, &bytes.Buffer{}
 	res.WriteString(strings.Join(t.bs, sep))
 	res.WriteString("\n")
-	for _, r := range t.data {
-		err := r.ToTextLine(row, t.bs, sep)
-		if err != nil {
-			return nil, err
+	err := t.walk(-1, func(r cells) error {
+		if err := toRow(r).ToTextLine(row, t.bs, sep); err != nil {
+			return err
 		}
 		if _, err := res.Write(row.Bytes()); err != nil {
-			return nil, err
+			return err
 		}
 		if _, err := res.WriteString("\n"); err != nil {
-			return nil, err
+			return err
 		}
 		row.Reset()
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return res, nil
 }
@@ -201,13 +262,33 @@ func equalBindings(b1, b2 map[string]bool) bool {
 // AppendTable appends the content of the provided table. It will fail it the
 // target table is not empty and the binidngs do not match.
 func (t *Table) AppendTable(t2 *Table) error {
+	if err := t.load(); err != nil {
+		return err
+	}
+	if err := t2.load(); err != nil {
+		return err
+	}
 	if len(t.Bindings()) > 0 && !equalBindings(t.mbs, t2.mbs) {
 		return fmt.Errorf("AppendTable can only append to an empty table or equally binded table; intead got %v and %v", t.bs, t2.bs)
 	}
 	if len(t.Bindings()) == 0 {
 		t.bs, t.mbs = t2.bs, t2.mbs
+		t.addTypes(t2.types)
+	}
+	if t.cols == nil {
+		t.cols = make(map[string]*column)
 	}
-	t.data = append(t.data, t2.data...)
+	for b, col := range t2.cols {
+		nc, ok := t.cols[b]
+		if !ok {
+			nc = &column{}
+			t.cols[b] = nc
+		}
+		for j, n := 0, col.len(); j < n; j++ {
+			nc.addFrom(t.n+j, col, j)
+		}
+	}
+	t.n += t2.n
 	return nil
 }
 
@@ -229,11 +310,15 @@ func disjointBinding(b1, b2 map[string]bool) bool {
 	return true
 }
 
-// MergeRows takes a list of rors and returns a new map containing both.
+// MergeRows takes a list of rors and returns a new map containing both. Null
+// cells never override the values already merged for the same binding.
 func MergeRows(ms []Row) Row {
 	res := make(map[string]*Cell)
 	for _, om := range ms {
 		for k, v := range om {
+			if v.IsNull() && !res[k].IsNull() {
+				continue
+			}
 			res[k] = v
 		}
 	}
@@ -242,6 +327,18 @@ func MergeRows(ms []Row) Row {
 
 // DotProduct does the doot product with the provided tatble
 func (t *Table) DotProduct(t2 *Table) error {
+	return t.dotProduct(context.Background(), t2)
+}
+
+// dotProduct does the dot product with the provided table. It stops and
+// returns the context error once the provided context is done.
+func (t *Table) dotProduct(ctx context.Context, t2 *Table) error {
+	if err := t.load(); err != nil {
+		return err
+	}
+	if err := t2.load(); err != nil {
+		return err
+	}
 	if !disjointBinding(t.mbs, t2.mbs) {
 		return fmt.Errorf("DotProduct operations requires disjoint bindingts; instead got %v and %v", t.mbs, t2.mbs)
 	}
@@ -259,26 +356,520 @@ func (t *Table) DotProduct(t2 *Table) error {
 		t.bs = append(t.bs, k)
 	}
 	// Update the data.
-	td := t.data
-	t.data = []Row{}
-	for _, r1 := range td {
-		for _, r2 := range t2.data {
-			t.data = append(t.data, MergeRows([]Row{r1, r2}))
+	var pairs [][2]int
+	for i := 0; i < t.n; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for j := 0; j < t2.n; j++ {
+			pairs = append(pairs, [2]int{i, j})
 		}
 	}
+	t.joinRows(t2, pairs)
 	return nil
 }
 
+// joinKey returns the key used to hash join the provided row on the given
+// bindings. It returns false if the row lacks any of the bindings or holds a
+// null cell for it.
+func joinKey(r cells, bs []string) (string, bool) {
+	for _, b := range bs {
+		if r.Cell(b).IsNull() {
+			return "", false
+		}
+	}
+	return rowKey(r, bs), true
+}
+
+// RowKey returns a key for the values of the provided row on the given
+// bindings. Two rows have the same key only if they hold identical cells for
+// all the bindings.
+func RowKey(r Row, bs []string) string {
+	return rowKey(r, bs)
+}
+
+// rowKey returns the key for the values of the provided row cells on the
+// given bindings.
+func rowKey(r cells, bs []string) string {
+	var k bytes.Buffer
+	for _, b := range bs {
+		k.WriteString(cellKey(r.Cell(b)))
+	}
+	return k.String()
+}
+
+// cellKey returns a key for the value of the provided cell. Two cells have the
+// same key only if they are equal. All null cells share the same key.
+func cellKey(c *Cell) string {
+	if c.IsNull() {
+		return "-;"
+	}
+	v := canonicalText(c)
+	return fmt.Sprintf("%d:%d:%s;", cellRank(c), len(v), v)
+}
+
+// Distinct removes the duplicated rows of the table, keeping the first
+// occurrence of each of them. Tables without duplicated rows are left
+// untouched.
+func (t *Table) Distinct() {
+	t.load()
+	seen := make(map[string]bool)
+	var idx []int
+	for i := 0; i < t.n; i++ {
+		k := rowKey(t.View(i), t.bs)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		idx = append(idx, i)
+	}
+	if len(idx) < t.n {
+		t.selectRows(idx)
+	}
+}
+
+// HashJoin joins the table with the provided one. Rows are merged only if
+// they agree on the values of all the bindings shared by both tables. A null
+// cell agrees with any value, so rows holding nulls on shared bindings are
+// merged with all the rows compatible on the remaining ones. If the tables do
+// not share any binding, it falls back to the dot product. The join stops and
+// returns the context error once the provided context is done.
+func (t *Table) HashJoin(ctx context.Context, t2 *Table) error {
+	return t.Join(ctx, t2, InnerJoin, t.sharedBindings(t2))
+}
+
 // DeleteRow removes the row at position i from the table.
 func (t *Table) DeleteRow(i int) error {
-	if i < 0 || i >= len(t.data) {
-		return fmt.Errorf("cannot delete row %d from a table with %d rows", i, len(t.data))
+	if err := t.load(); err != nil {
+		return err
+	}
+	if i < 0 || i >= t.n {
+		return fmt.Errorf("cannot delete row %d from a table with %d rows", i, t.n)
+	}
+	for _, col := range t.cols {
+		col.delete(i)
 	}
-	t.data = append(t.data[:i], t.data[i+1:]...)
+	t.n--
 	return nil
 }
 
 // Truncate flushes all the data away. It still retains all set bindings.
 func (t *Table) Truncate() {
-	t.data = []Row{}
+	t.Close()
+	t.reset()
+}
+
+// SortConfig contains the binding to sort by and the direction of the sort.
+// If Value is provided, rows are sorted by the cell it computes for each row
+// instead of the one bound to the binding.
+type SortConfig struct {
+	Binding string
+	Desc    bool
+	Value   func(Row) (*Cell, error)
+}
+
+// Cell collation ranks. Cells of different kinds are sorted by their rank;
+// cells of the same kind are sorted by their value.
+const (
+	rankMissing = iota
+	rankBool
+	rankNumber
+	rankDuration
+	rankDate
+	rankTime
+	rankText
+	rankBlob
+	rankCustom
+	rankNode
+	rankPredicate
+	rankTriple
+)
+
+// cellRank returns the collation rank of the provided cell.
+func cellRank(c *Cell) int {
+	switch {
+	case c.IsNull():
+		return rankMissing
+	case c.S != "":
+		return rankText
+	case c.N != nil:
+		return rankNode
+	case c.P != nil:
+		return rankPredicate
+	case c.T != nil:
+		return rankTime
+	case c.E != nil:
+		return rankTriple
+	case c.L != nil:
+		switch c.L.Type() {
+		case literal.Bool:
+			return rankBool
+		case literal.Int64, literal.Float32, literal.Float64, literal.Decimal:
+			return rankNumber
+		case literal.Duration:
+			return rankDuration
+		case literal.Date:
+			return rankDate
+		case literal.Text:
+			return rankText
+		case literal.Blob:
+			return rankBlob
+		default:
+			return rankCustom
+		}
+	}
+	return rankMissing
+}
+
+// compareOrdered returns -1 if less, 1 if more, and 0 otherwise.
+func compareOrdered(less, more bool) int {
+	switch {
+	case less:
+		return -1
+	case more:
+		return 1
+	}
+	return 0
+}
+
+// cellText returns the textual value of a text cell.
+func cellText(c *Cell) string {
+	if c.L != nil {
+		v, _ := c.L.Text()
+		return v
+	}
+	return c.S
+}
+
+// compareCells returns -1, 0, or 1 if the first cell is smaller, equal, or
+// bigger than the second one. Cells of different kinds are collated in the
+// following order: missing cells, booleans, numbers, durations, dates, times,
+// texts, blobs, custom literals, nodes, predicates, and embedded triples.
+// Within the same kind, false sorts before true, numbers are compared
+// numerically regardless of their literal type, dates and times
+// chronologically, texts and blobs lexicographically, custom literals by type
+// name and then using their type definition, nodes by type and then ID,
+// predicates by ID and then with immutable predicates before temporal ones
+// sorted by their time anchors, and embedded triples by their string
+// representation.
+func compareCells(c1, c2 *Cell) int {
+	r1, r2 := cellRank(c1), cellRank(c2)
+	if r1 != r2 {
+		return compareOrdered(r1 < r2, r1 > r2)
+	}
+	switch r1 {
+	case rankMissing:
+		return 0
+	case rankBool, rankNumber, rankDuration, rankDate, rankBlob:
+		cmp, _ := literal.Compare(c1.L, c2.L)
+		return cmp
+	case rankTime:
+		return compareOrdered(c1.T.Before(*c2.T), c1.T.After(*c2.T))
+	case rankText:
+		return strings.Compare(cellText(c1), cellText(c2))
+	case rankCustom:
+		if cmp := strings.Compare(c1.L.Type().String(), c2.L.Type().String()); cmp != 0 {
+			return cmp
+		}
+		if cmp, err := literal.Compare(c1.L, c2.L); err == nil {
+			return cmp
+		}
+	case rankNode:
+		return compareNodes(c1.N, c2.N)
+	case rankPredicate:
+		return predicate.Compare(c1.P, c2.P)
+	}
+	return strings.Compare(c1.String(), c2.String())
+}
+
+// compareNodes returns -1, 0, or 1 if the first node sorts before, together
+// with, or after the second one. Nodes are sorted by type and then by ID.
+func compareNodes(n1, n2 *node.Node) int {
+	if cmp := strings.Compare(n1.Type().String(), n2.Type().String()); cmp != 0 {
+		return cmp
+	}
+	return strings.Compare(n1.ID().String(), n2.ID().String())
+}
+
+// rowSorter sorts row positions based on the provided sort configuration. The
+// sort keys are precomputed for each row.
+type rowSorter struct {
+	idx  []int
+	keys [][]*Cell
+	cfg  []SortConfig
+}
+
+func (s *rowSorter) Len() int {
+	return len(s.idx)
+}
+
+func (s *rowSorter) Swap(i, j int) {
+	s.idx[i], s.idx[j] = s.idx[j], s.idx[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func (s *rowSorter) Less(i, j int) bool {
+	return compareKeys(s.keys[i], s.keys[j], s.cfg) < 0
+}
+
+// sortKeys returns the cells to sort the provided row by. Rows are only built
+// if a sort key needs to compute its value.
+func sortKeys(r cells, cfg []SortConfig) ([]*Cell, error) {
+	keys := make([]*Cell, len(cfg))
+	var row Row
+	for k, c := range cfg {
+		if c.Value == nil {
+			keys[k] = r.Cell(c.Binding)
+			continue
+		}
+		if row == nil {
+			row = toRow(r)
+		}
+		v, err := c.Value(row)
+		if err != nil {
+			return nil, err
+		}
+		keys[k] = v
+	}
+	return keys, nil
+}
+
+// compareKeys returns -1, 0, or 1 if the first sort keys should be sorted
+// before, together with, or after the second ones.
+func compareKeys(k1, k2 []*Cell, cfg []SortConfig) int {
+	for k, c := range cfg {
+		cmp := compareCells(k1[k], k2[k])
+		if cmp == 0 {
+			continue
+		}
+		if c.Desc {
+			return -cmp
+		}
+		return cmp
+	}
+	return 0
+}
+
+// toRow returns the Row map for the provided row cells.
+func toRow(r cells) Row {
+	switch v := r.(type) {
+	case Row:
+		return v
+	case RowView:
+		return v.Row()
+	}
+	return nil
+}
+
+// CompareRows returns -1, 0, or 1 if the first row should be sorted before,
+// together with, or after the second one using the provided configuration.
+func CompareRows(r1, r2 Row, cfg []SortConfig) (int, error) {
+	k1, err := sortKeys(r1, cfg)
+	if err != nil {
+		return 0, err
+	}
+	k2, err := sortKeys(r2, cfg)
+	if err != nil {
+		return 0, err
+	}
+	return compareKeys(k1, k2, cfg), nil
+}
+
+// Sort sorts the rows of the table using the provided configuration. Rows
+// that compare equal retain their relative order. It will fail if the value
+// of a sort key cannot be computed for a row.
+func (t *Table) Sort(cfg []SortConfig) error {
+	if err := t.load(); err != nil {
+		return err
+	}
+	if len(cfg) == 0 {
+		return nil
+	}
+	idx, keys := make([]int, t.n), make([][]*Cell, t.n)
+	for i := range idx {
+		k, err := sortKeys(t.View(i), cfg)
+		if err != nil {
+			return err
+		}
+		idx[i], keys[i] = i, k
+	}
+	sort.Stable(&rowSorter{idx: idx, keys: keys, cfg: cfg})
+	t.selectRows(idx)
+	return nil
+}
+
+// Limit keeps at most the first n rows of the table.
+func (t *Table) Limit(n int64) {
+	t.load()
+	if n < 0 {
+		n = 0
+	}
+	if n >= int64(t.n) {
+		return
+	}
+	for _, col := range t.cols {
+		col.truncate(int(n))
+	}
+	t.n = int(n)
+}
+
+// LimitPerGroup keeps at most the first n rows of each group of rows that share
+// the same values for the provided bindings. The relative order of the
+// retained rows is preserved.
+func (t *Table) LimitPerGroup(bs []string, n int64) {
+	t.load()
+	cnts := make(map[string]int64)
+	var idx []int
+	for i := 0; i < t.n; i++ {
+		k := rowKey(t.View(i), bs)
+		if cnts[k] >= n {
+			continue
+		}
+		cnts[k]++
+		idx = append(idx, i)
+	}
+	t.selectRows(idx)
+}
+
+// Filter keeps only the rows of the table for which the provided function
+// returns true. The relative order of the retained rows is preserved. If all
+// rows are retained the table is left untouched.
+func (t *Table) Filter(f func(Row) (bool, error)) error {
+	return t.FilterView(func(v RowView) (bool, error) {
+		return f(v.Row())
+	})
+}
+
+// FilterView behaves as Filter, but the provided function receives a view of
+// each row instead, avoiding building the rows of the table.
+func (t *Table) FilterView(f func(RowView) (bool, error)) error {
+	if err := t.load(); err != nil {
+		return err
+	}
+	var idx []int
+	for i := 0; i < t.n; i++ {
+		ok, err := f(t.View(i))
+		if err != nil {
+			return err
+		}
+		if ok {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) < t.n {
+		t.selectRows(idx)
+	}
+	return nil
+}
+
+// Project keeps only the provided bindings on the table, in the provided
+// order. The cells of the retained bindings are not copied. It will fail if
+// any of the bindings is not available on the table or is repeated.
+func (t *Table) Project(bs []string) error {
+	if err := t.load(); err != nil {
+		return err
+	}
+	m := make(map[string]bool, len(bs))
+	for _, b := range bs {
+		if !t.mbs[b] {
+			return fmt.Errorf("table.Project cannot project unknown binding %q; available bindings are %v", b, t.bs)
+		}
+		if m[b] {
+			return fmt.Errorf("table.Project does not allow duplicated bindings in %v", bs)
+		}
+		m[b] = true
+	}
+	cols, types := make(map[string]*column, len(bs)), make(Schema)
+	for _, b := range bs {
+		if col, ok := t.cols[b]; ok {
+			cols[b] = col
+		}
+		if ct, ok := t.types[b]; ok {
+			types[b] = ct
+		}
+	}
+	t.bs, t.mbs, t.cols, t.types = append([]string{}, bs...), m, cols, types
+	return nil
+}
+
+// Minus removes all the rows of the table that are compatible with at least one
+// row of the provided table. Two rows are compatible if they share at least one
+// binding not null on both of them and all their shared bindings hold the same
+// values or a null. If both tables have no bindings in common no rows are
+// removed.
+func (t *Table) Minus(t2 *Table) {
+	t.load()
+	t2.load()
+	var shared []string
+	for _, b := range t.bs {
+		if t2.mbs[b] {
+			shared = append(shared, b)
+		}
+	}
+	if len(shared) == 0 {
+		return
+	}
+	var idx []int
+	for i := 0; i < t.n; i++ {
+		if !t2.subtracts(t.View(i), shared) {
+			idx = append(idx, i)
+		}
+	}
+	t.selectRows(idx)
+}
+
+// Subtracts returns true if Minus would remove the provided row from a table
+// with the given bindings when subtracting this table, that is, if any of its
+// rows agrees with it on all the shared bindings and both rows bind them.
+// This allows subtracting the table from rows as they are streamed.
+func (t *Table) Subtracts(bs []string, r Row) bool {
+	t.load()
+	var shared []string
+	for _, b := range bs {
+		if t.mbs[b] {
+			shared = append(shared, b)
+		}
+	}
+	if len(shared) == 0 {
+		return false
+	}
+	return t.subtracts(r, shared)
+}
+
+// subtracts returns true if any row of the table is compatible with the
+// provided one on the shared bindings, and both rows bind all of them.
+func (t *Table) subtracts(r cells, shared []string) bool {
+	for j := 0; j < t.n; j++ {
+		r2 := t.View(j)
+		if compatibleRows(r, r2, shared) && boundOnBoth(r, r2, shared) {
+			return true
+		}
+	}
+	return false
+}
+
+// compatibleRows returns true if both rows hold the same values for the
+// provided bindings. Null cells are compatible with any value.
+func compatibleRows(r1, r2 cells, bs []string) bool {
+	for _, b := range bs {
+		c1, c2 := r1.Cell(b), r2.Cell(b)
+		if c1.IsNull() || c2.IsNull() {
+			continue
+		}
+		if !c1.Equal(c2) {
+			return false
+		}
+	}
+	return true
+}
+
+// boundOnBoth returns true if at least one of the provided bindings is not null
+// on both rows.
+func boundOnBoth(r1, r2 cells, bs []string) bool {
+	for _, b := range bs {
+		if !r1.Cell(b).IsNull() && !r2.Cell(b).IsNull() {
+			return true
+		}
+	}
+	return false
 }
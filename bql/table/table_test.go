@@ -16,9 +16,13 @@ package table
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -56,15 +60,22 @@ func TestCellString(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create literal with error %v", err)
 	}
+	lt, err := node.NewNodeFromStrings("/u", "a<b")
+	if err != nil {
+		t.Fatalf("failed to create node with error %v", err)
+	}
 	testTable := []struct {
 		c    *Cell
 		want string
 	}{
 		{c: &Cell{S: "foo"}, want: `foo`},
 		{c: &Cell{N: n}, want: n.String()},
+		{c: &Cell{N: lt}, want: `/u<a\<b>`},
 		{c: &Cell{P: p}, want: p.String()},
 		{c: &Cell{L: l}, want: l.String()},
 		{c: &Cell{T: &now}, want: now.Format(time.RFC3339Nano)},
+		{c: NullCell(), want: "<NULL>"},
+		{c: nil, want: "<NULL>"},
 	}
 	for _, entry := range testTable {
 		if got := entry.c.String(); got != entry.want {
@@ -73,6 +84,35 @@ func TestCellString(t *testing.T) {
 	}
 }
 
+func TestCellIsNull(t *testing.T) {
+	testTable := []struct {
+		c    *Cell
+		want bool
+	}{
+		{c: nil, want: true},
+		{c: NullCell(), want: true},
+		{c: &Cell{}, want: true},
+		{c: &Cell{S: "foo"}, want: false},
+		{c: &Cell{N: node.NewBlankNode()}, want: false},
+	}
+	for _, entry := range testTable {
+		if got := entry.c.IsNull(); got != entry.want {
+			t.Errorf("Cell(%v).IsNull returned the wrong value; got %v, want %v", entry.c, got, entry.want)
+		}
+	}
+}
+
+func TestMergeRowsNulls(t *testing.T) {
+	got := MergeRows([]Row{
+		{"?s": &Cell{S: "a"}, "?o": NullCell()},
+		{"?s": NullCell(), "?o": &Cell{S: "b"}, "?x": NullCell()},
+	})
+	want := Row{"?s": &Cell{S: "a"}, "?o": &Cell{S: "b"}, "?x": NullCell()}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("table.MergeRows returned the wrong row; got %v, want %v", got, want)
+	}
+}
+
 func TestRowToTextLine(t *testing.T) {
 	r, b := make(Row), &bytes.Buffer{}
 	r["?foo"] = &Cell{S: "foo"}
@@ -244,7 +284,9 @@ func TestAppendTable(t *testing.T) {
 	newNonEmpty := func(twice bool) *Table {
 		tbl := testTable(t)
 		if twice {
-			tbl.data = append(tbl.data, tbl.data...)
+			for _, r := range tbl.Rows() {
+				tbl.AddRow(r)
+			}
 		}
 		return tbl
 	}
@@ -397,6 +439,117 @@ func TestDotProductContent(t *testing.T) {
 	}
 }
 
+func TestHashJoin(t *testing.T) {
+	newTable := func(bs []string, rows ...[]string) *Table {
+		tbl, err := New(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, vs := range rows {
+			r := Row{}
+			for i, b := range bs {
+				r[b] = &Cell{S: vs[i]}
+			}
+			tbl.AddRow(r)
+		}
+		return tbl
+	}
+	testTable := []struct {
+		t    *Table
+		t2   *Table
+		bs   int
+		want []string
+	}{
+		{
+			t:    newTable([]string{"?s", "?o"}, []string{"a", "b"}, []string{"a", "c"}, []string{"d", "e"}),
+			t2:   newTable([]string{"?o", "?x"}, []string{"b", "1"}, []string{"b", "2"}, []string{"e", "3"}, []string{"f", "4"}),
+			bs:   3,
+			want: []string{"a b 1", "a b 2", "d e 3"},
+		},
+		{
+			t:    newTable([]string{"?s", "?o"}, []string{"a", "b"}, []string{"c", "d"}),
+			t2:   newTable([]string{"?o", "?s"}, []string{"b", "a"}, []string{"b", "c"}),
+			bs:   2,
+			want: []string{"a b"},
+		},
+		{
+			t:    newTable([]string{"?s"}, []string{"a"}),
+			t2:   newTable([]string{"?o"}, []string{"b"}, []string{"c"}),
+			bs:   2,
+			want: []string{"a b", "a c"},
+		},
+		{
+			// Empty values are null and compatible with any value.
+			t:    newTable([]string{"?s", "?o"}, []string{"a", ""}, []string{"d", "e"}),
+			t2:   newTable([]string{"?o", "?x"}, []string{"b", "1"}, []string{"e", "3"}),
+			bs:   3,
+			want: []string{"a b 1", "a e 3", "d e 3"},
+		},
+		{
+			t:    newTable([]string{"?s", "?o"}, []string{"a", "b"}, []string{"c", "d"}),
+			t2:   newTable([]string{"?o", "?x"}, []string{"", "1"}, []string{"d", "2"}),
+			bs:   3,
+			want: []string{"a b 1", "c d 2", "c d 1"},
+		},
+	}
+	for _, entry := range testTable {
+		if err := entry.t.HashJoin(context.Background(), entry.t2); err != nil {
+			t.Errorf("table.HashJoin failed to join %s to %s with error %v", entry.t2, entry.t, err)
+			continue
+		}
+		if got, want := len(entry.t.Bindings()), entry.bs; got != want {
+			t.Errorf("table.HashJoin returned the wrong number of bindings; got %d, want %d", got, want)
+		}
+		var got []string
+		for _, r := range entry.t.Rows() {
+			var vs []string
+			for _, b := range []string{"?s", "?o", "?x"} {
+				if c, ok := r[b]; ok {
+					vs = append(vs, c.S)
+				}
+			}
+			got = append(got, strings.Join(vs, " "))
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("table.HashJoin returned the wrong rows; got %v, want %v", got, entry.want)
+		}
+	}
+}
+
+func TestJoinCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	t1 := testDotTable(t, []string{"?foo"}, 3)
+	if err := t1.HashJoin(ctx, testDotTable(t, []string{"?bar"}, 3)); err != context.Canceled {
+		t.Errorf("HashJoin should have failed with %v for a cancelled context; got %v", context.Canceled, err)
+	}
+	t2 := testDotTable(t, []string{"?foo", "?bar"}, 3)
+	if err := t2.HashJoin(ctx, testDotTable(t, []string{"?foo"}, 3)); err != context.Canceled {
+		t.Errorf("HashJoin should have failed with %v for a cancelled context; got %v", context.Canceled, err)
+	}
+}
+
+func TestHashJoinTimeCells(t *testing.T) {
+	ts := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	other := ts.In(time.FixedZone("PST", -8*3600))
+	t1, err := New([]string{"?t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1.AddRow(Row{"?t": &Cell{T: &ts}})
+	t2, err := New([]string{"?t", "?s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2.AddRow(Row{"?t": &Cell{T: &other}, "?s": &Cell{S: "a"}})
+	if err := t1.HashJoin(context.Background(), t2); err != nil {
+		t.Fatalf("table.HashJoin failed with error %v", err)
+	}
+	if got, want := len(t1.Rows()), 1; got != want {
+		t.Errorf("table.HashJoin failed to join equal time anchors in different zones; got %d rows, want %d", got, want)
+	}
+}
+
 func TestDeleteRow(t *testing.T) {
 	testTable := []struct {
 		t   *Table
@@ -450,3 +603,373 @@ func TestTruncate(t *testing.T) {
 		t.Errorf("Failed to create a table with %d rows instead of %v", got, want)
 	}
 }
+
+func testSortTable(t *testing.T) *Table {
+	tbl, err := New([]string{"?s", "?t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now()
+	for i, s := range []string{"b", "a", "b", "a", "b"} {
+		ts := base.Add(time.Duration(i) * time.Hour)
+		tbl.AddRow(Row{
+			"?s": &Cell{S: s},
+			"?t": &Cell{T: &ts},
+		})
+	}
+	return tbl
+}
+
+func TestSort(t *testing.T) {
+	tbl := testSortTable(t)
+	if err := tbl.Sort([]SortConfig{{Binding: "?s"}, {Binding: "?t", Desc: true}}); err != nil {
+		t.Fatalf("table.Sort should have never failed; %v", err)
+	}
+	var got []string
+	for i, r := range tbl.Rows() {
+		got = append(got, r["?s"].S)
+		if i > 0 && r["?s"].S == tbl.Rows()[i-1]["?s"].S && r["?t"].T.After(*tbl.Rows()[i-1]["?t"].T) {
+			t.Errorf("table.Sort failed to sort descending times for row %d; got %v", i, tbl)
+		}
+	}
+	if want := []string{"a", "a", "b", "b", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Sort failed to sort by ?s; got %v, want %v", got, want)
+	}
+}
+
+func TestSortCollation(t *testing.T) {
+	b := literal.DefaultBuilder()
+	mustLiteral := func(t literal.Type, v interface{}) *Cell {
+		l, err := b.Build(t, v)
+		if err != nil {
+			panic(err)
+		}
+		return &Cell{L: l}
+	}
+	mustNode := func(s string) *Cell {
+		n, err := node.Parse(s)
+		if err != nil {
+			panic(err)
+		}
+		return &Cell{N: n}
+	}
+	mustPredicate := func(s string) *Cell {
+		p, err := predicate.Parse(s)
+		if err != nil {
+			panic(err)
+		}
+		return &Cell{P: p}
+	}
+	ts := time.Now()
+	// Cells listed in their expected ascending collation order.
+	want := []*Cell{
+		nil,
+		mustLiteral(literal.Bool, false),
+		mustLiteral(literal.Bool, true),
+		mustLiteral(literal.Int64, int64(-3)),
+		mustLiteral(literal.Float64, float64(1.5)),
+		mustLiteral(literal.Decimal, big.NewRat(7, 4)),
+		mustLiteral(literal.Int64, int64(2)),
+		mustLiteral(literal.Float32, float32(2.5)),
+		mustLiteral(literal.Int64, int64(10)),
+		mustLiteral(literal.Duration, time.Second),
+		mustLiteral(literal.Date, time.Date(2015, time.July, 19, 0, 0, 0, 0, time.UTC)),
+		{T: &ts},
+		{S: "a"},
+		mustLiteral(literal.Text, "b"),
+		mustLiteral(literal.Blob, []byte("a")),
+		mustNode("/_<a>"),
+		mustNode("/u<b>"),
+		mustNode("/u/x<a>"),
+		mustPredicate(`"a"@[2016-01-01T00:00:00Z]`),
+		mustPredicate(`"p"@[]`),
+		mustPredicate(`"p"@[2015-01-01T00:30:00+01:00]`),
+		mustPredicate(`"p"@[2015-01-01T00:00:00Z]`),
+	}
+	tbl, err := New([]string{"?v", "?i"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		r := Row{"?i": mustLiteral(literal.Int64, int64(i))}
+		if want[i] != nil {
+			r["?v"] = want[i]
+		}
+		tbl.AddRow(r)
+	}
+	if err := tbl.Sort([]SortConfig{{Binding: "?v"}}); err != nil {
+		t.Fatalf("table.Sort should have never failed; %v", err)
+	}
+	for i, r := range tbl.Rows() {
+		if got := r["?v"]; !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("table.Sort collated row %d wrongly; got %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestSortCustomLiterals(t *testing.T) {
+	level, err := literal.Register(literal.TypeDefinition{
+		Name:    "level",
+		Parse:   func(s string) (interface{}, error) { return strconv.Atoi(s) },
+		Format:  func(v interface{}) string { return strconv.Itoa(v.(int)) },
+		Compare: func(a, b interface{}) int { return a.(int) - b.(int) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := literal.DefaultBuilder()
+	tbl, err := New([]string{"?v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []int{10, 2, 7} {
+		l, err := b.Build(level, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tbl.AddRow(Row{"?v": &Cell{L: l}})
+	}
+	blob, err := b.Build(literal.Blob, []byte("z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?v": &Cell{L: blob}})
+	if err := tbl.Sort([]SortConfig{{Binding: "?v"}}); err != nil {
+		t.Fatalf("table.Sort should have never failed; %v", err)
+	}
+	var got []string
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?v"].String())
+	}
+	want := []string{`"[122]"^^type:blob`, `"2"^^type:level`, `"7"^^type:level`, `"10"^^type:level`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Sort collated custom literals wrongly; got %v, want %v", got, want)
+	}
+}
+
+func TestSortValue(t *testing.T) {
+	tbl := testSortTable(t)
+	first := func(r Row) (*Cell, error) {
+		return r["?s"], nil
+	}
+	if err := tbl.Sort([]SortConfig{{Value: first, Desc: true}}); err != nil {
+		t.Fatalf("table.Sort should have never failed; %v", err)
+	}
+	var got []string
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?s"].S)
+	}
+	if want := []string{"b", "b", "b", "a", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Sort failed to sort by computed value; got %v, want %v", got, want)
+	}
+	fail := func(Row) (*Cell, error) { return nil, errors.New("boom") }
+	if err := tbl.Sort([]SortConfig{{Value: fail}}); err == nil {
+		t.Errorf("table.Sort should have failed to compute the sort key")
+	}
+}
+
+func TestLimit(t *testing.T) {
+	testTable := []struct {
+		n    int64
+		want int
+	}{
+		{-1, 0},
+		{0, 0},
+		{2, 2},
+		{5, 5},
+		{10, 5},
+	}
+	for _, entry := range testTable {
+		tbl := testSortTable(t)
+		tbl.Limit(entry.n)
+		if got := len(tbl.Rows()); got != entry.want {
+			t.Errorf("table.Limit(%d) returned the wrong number of rows; got %d, want %d", entry.n, got, entry.want)
+		}
+	}
+}
+
+func TestLimitPerGroup(t *testing.T) {
+	testTable := []struct {
+		n    int64
+		want []string
+	}{
+		{0, nil},
+		{1, []string{"b", "a"}},
+		{2, []string{"b", "a", "b", "a"}},
+		{3, []string{"b", "a", "b", "a", "b"}},
+	}
+	for _, entry := range testTable {
+		tbl := testSortTable(t)
+		tbl.LimitPerGroup([]string{"?s"}, entry.n)
+		var got []string
+		for _, r := range tbl.Rows() {
+			got = append(got, r["?s"].S)
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("table.LimitPerGroup(%d) returned the wrong rows; got %v, want %v", entry.n, got, entry.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tbl := testSortTable(t)
+	if err := tbl.Filter(func(r Row) (bool, error) { return r["?s"].S == "a", nil }); err != nil {
+		t.Fatalf("table.Filter failed with error %v", err)
+	}
+	if got, want := len(tbl.Rows()), 2; got != want {
+		t.Errorf("table.Filter returned the wrong number of rows; got %d, want %d", got, want)
+	}
+	tbl = testSortTable(t)
+	if err := tbl.Filter(func(Row) (bool, error) { return false, fmt.Errorf("boom") }); err == nil {
+		t.Errorf("table.Filter should have propagated the filter error")
+	}
+}
+
+func TestFilterView(t *testing.T) {
+	tbl := testSortTable(t)
+	if err := tbl.FilterView(func(v RowView) (bool, error) { return v.Cell("?s").S == "b", nil }); err != nil {
+		t.Fatalf("table.FilterView failed with error %v", err)
+	}
+	if got, want := tbl.NumRows(), 3; got != want {
+		t.Errorf("table.FilterView returned the wrong number of rows; got %d, want %d", got, want)
+	}
+	// Keeping all rows leaves the table untouched.
+	cols := tbl.cols
+	if err := tbl.FilterView(func(RowView) (bool, error) { return true, nil }); err != nil {
+		t.Fatalf("table.FilterView failed with error %v", err)
+	}
+	if got, want := tbl.NumRows(), 3; got != want {
+		t.Errorf("table.FilterView returned the wrong number of rows; got %d, want %d", got, want)
+	}
+	if !reflect.DeepEqual(tbl.cols, cols) || tbl.cols["?s"] != cols["?s"] {
+		t.Errorf("table.FilterView copied the table rows when keeping all of them")
+	}
+}
+
+func TestProject(t *testing.T) {
+	tbl, err := New([]string{"?s", "?p", "?o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?s": &Cell{S: "a"}, "?p": &Cell{S: "b"}, "?o": &Cell{S: "c"}})
+	tbl.AddRow(Row{"?s": &Cell{S: "d"}, "?o": &Cell{S: "f"}, "?x": &Cell{S: "x"}})
+	col := tbl.cols["?o"]
+	if err := tbl.Project([]string{"?o", "?s"}); err != nil {
+		t.Fatalf("table.Project failed with error %v", err)
+	}
+	if got, want := tbl.Bindings(), []string{"?o", "?s"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Project returned the wrong bindings; got %v, want %v", got, want)
+	}
+	if tbl.HasBinding("?p") {
+		t.Errorf("table.Project should have removed binding ?p")
+	}
+	want := []Row{
+		{"?s": &Cell{S: "a"}, "?o": &Cell{S: "c"}},
+		{"?s": &Cell{S: "d"}, "?o": &Cell{S: "f"}},
+	}
+	if got := tbl.Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Project returned the wrong rows; got %v, want %v", got, want)
+	}
+	if tbl.cols["?o"] != col {
+		t.Errorf("table.Project should not copy the cells of the retained bindings")
+	}
+	for _, bs := range [][]string{{"?p"}, {"?s", "?s"}, {"?unknown"}} {
+		if err := tbl.Project(bs); err == nil {
+			t.Errorf("table.Project(%v) should have failed", bs)
+		}
+	}
+}
+
+func TestMinus(t *testing.T) {
+	newTable := func(bs []string, rows ...Row) *Table {
+		tbl, err := New(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range rows {
+			tbl.AddRow(r)
+		}
+		return tbl
+	}
+	testTable := []struct {
+		t2   *Table
+		want []string
+	}{
+		{
+			t2:   newTable([]string{"?s"}, Row{"?s": &Cell{S: "a"}}),
+			want: []string{"b"},
+		},
+		{
+			t2:   newTable([]string{"?s", "?x"}, Row{"?s": &Cell{S: "b"}, "?x": &Cell{S: "x"}}),
+			want: []string{"a"},
+		},
+		{
+			t2:   newTable([]string{"?x"}, Row{"?x": &Cell{S: "a"}}),
+			want: []string{"a", "b"},
+		},
+		{
+			t2:   newTable([]string{"?s"}),
+			want: []string{"a", "b"},
+		},
+		{
+			// Null cells never make rows share a binding.
+			t2:   newTable([]string{"?s"}, Row{"?s": NullCell()}),
+			want: []string{"a", "b"},
+		},
+		{
+			t2:   newTable([]string{"?s", "?x"}, Row{"?s": NullCell(), "?x": &Cell{S: "x"}}, Row{"?s": &Cell{S: "a"}, "?x": NullCell()}),
+			want: []string{"b"},
+		},
+	}
+	for _, entry := range testTable {
+		tbl := newTable([]string{"?s"}, Row{"?s": &Cell{S: "a"}}, Row{"?s": &Cell{S: "b"}})
+		var streamed []string
+		for _, r := range tbl.Rows() {
+			if !entry.t2.Subtracts(tbl.Bindings(), r) {
+				streamed = append(streamed, r["?s"].S)
+			}
+		}
+		tbl.Minus(entry.t2)
+		var got []string
+		for _, r := range tbl.Rows() {
+			got = append(got, r["?s"].S)
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("table.Minus(%v) returned the wrong rows; got %v, want %v", entry.t2, got, entry.want)
+		}
+		if !reflect.DeepEqual(streamed, entry.want) {
+			t.Errorf("table.Subtracts(%v) kept the wrong rows; got %v, want %v", entry.t2, streamed, entry.want)
+		}
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	ts, err := time.Parse(time.RFC3339, "2015-07-19T13:12:04Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsLocal := ts.In(time.FixedZone("PDT", -7*3600))
+	tbl, err := New([]string{"?s", "?t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []Row{
+		{"?s": &Cell{S: "a"}, "?t": &Cell{T: &ts}},
+		{"?s": &Cell{S: "b"}, "?t": &Cell{T: &ts}},
+		{"?s": &Cell{S: "a"}, "?t": &Cell{T: &tsLocal}},
+		{"?s": &Cell{S: "a"}},
+		{"?s": &Cell{S: "a"}},
+	} {
+		tbl.AddRow(r)
+	}
+	tbl.Distinct()
+	if got, want := tbl.NumRows(), 3; got != want {
+		t.Fatalf("table.Distinct returned the wrong number of rows; got %d, want %d", got, want)
+	}
+	if got, want := tbl.Rows()[1]["?s"].S, "b"; got != want {
+		t.Errorf("table.Distinct should keep the first occurrence of each row in order; got %q, want %q", got, want)
+	}
+	if RowKey(Row{}, []string{"?s"}) == RowKey(Row{"?s": &Cell{S: "-"}}, []string{"?s"}) {
+		t.Errorf("table.RowKey should not return the same key for missing and set cells")
+	}
+}
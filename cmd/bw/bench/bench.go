@@ -0,0 +1,84 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench implements the command that benchmarks the store.
+package bench
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/badwolf/bench"
+	"github.com/google/badwolf/cmd/bw/command"
+	"github.com/google/badwolf/storage"
+)
+
+// New returns the command that benchmarks the store using a synthetic graph.
+func New() *command.Command {
+	return &command.Command{
+		Run:       run,
+		UsageLine: "bench [-graph g] [-users n] [-fan_out n] [-temporal_density f] [-seed n] [-runs n]",
+		Short:     "benchmarks the store using a synthetic graph.",
+		Long: `Generates a synthetic graph of users that follow other users, runs the
+standard query suite against it, and reports the latency percentiles of each
+query. The suite contains point lookups, one and two hop joins, temporal
+ranges, and a full scan aggregation. The same seed always generates the same
+graph, so runs against different drivers or builds are comparable. The
+generated graph is dropped once done.`,
+	}
+}
+
+// run benchmarks the store using the options provided on the arguments.
+func run(ctx context.Context, store storage.Store, args []string) int {
+	def := bench.DefaultGraphOptions()
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	id := fs.String("graph", "?bench", "name of the generated graph; it must not exist")
+	users := fs.Int("users", def.Users, "number of users in the graph")
+	fanOut := fs.Int("fan_out", def.FanOut, "number of users each user follows")
+	density := fs.Float64("temporal_density", def.TemporalDensity, "fraction of the follow edges that use temporal predicates")
+	seed := fs.Int64("seed", def.Seed, "seed of the random generator")
+	runs := fs.Int("runs", 100, "number of times each query is run")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	opts := def
+	opts.Users, opts.FanOut, opts.TemporalDensity, opts.Seed = *users, *fanOut, *density, *seed
+
+	g, err := store.NewGraph(*id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer store.DeleteGraph(*id)
+	start := time.Now()
+	n, err := bench.Generate(g, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Generated %d triples in %v\n\n", n, time.Since(start))
+	rs, err := bench.Run(ctx, store, bench.Suite(*id, opts), *runs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := bench.WriteReport(os.Stdout, rs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
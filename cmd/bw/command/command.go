@@ -0,0 +1,56 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package command contains the definition of the commands offered by the bw
+// tool.
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/badwolf/storage"
+)
+
+// Command describes a bw command.
+type Command struct {
+	// Run executes the command against the provided store. The args are the
+	// arguments after the command name. It returns the exit code of the tool.
+	Run func(ctx context.Context, store storage.Store, args []string) int
+
+	// UsageLine contains the name of the command followed by its arguments.
+	UsageLine string
+
+	// Short contains a one line description of the command.
+	Short string
+
+	// Long contains the detailed description of the command.
+	Long string
+}
+
+// Name returns the name of the command, the first word of its usage line.
+func (c *Command) Name() string {
+	name := c.UsageLine
+	if i := strings.Index(name, " "); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// Usage writes the usage line and the detailed description of the command.
+func (c *Command) Usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: bw %s\n\n%s\n", c.UsageLine, strings.TrimSpace(c.Long))
+}
@@ -0,0 +1,195 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diffstores implements the command that compares the graphs of two
+// stores.
+package diffstores
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/badwolf"
+	"github.com/google/badwolf/cmd/bw/command"
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+)
+
+// New returns the command that compares the graphs of two stores.
+func New() *command.Command {
+	return &command.Command{
+		Run:       run,
+		UsageLine: "diffstores [-a conn] -b conn [-graphs g1,g2,...]",
+		Short:     "compares the graphs of two stores.",
+		Long: `Compares the triples of the graphs held by two stores, as when validating a
+migration. Stores are selected using connection strings, such as memory://; if
+-a is not provided, the store selected by the -driver flag of the tool is
+used. Unless a comma separated list of graphs is provided, all the graphs of
+both stores are compared, which requires both stores to list their graphs.
+Graphs only found on one store are compared against an empty graph.
+
+Triples are compared by their canonical hash. Triples of the first store
+missing from the second one are reported prefixed by -, and triples only found
+on the second store prefixed by +. Triples that differ between stores are
+reported as missing and extra. The command exits with status 1 if any
+difference is found.`,
+	}
+}
+
+// run compares the stores using the options provided on the arguments.
+func run(ctx context.Context, store storage.Store, args []string) int {
+	fs := flag.NewFlagSet("diffstores", flag.ContinueOnError)
+	ca := fs.String("a", "", "connection string of the first store; defaults to the store of the tool")
+	cb := fs.String("b", "", "connection string of the second store")
+	graphs := fs.String("graphs", "", "comma separated graphs to compare; defaults to all the graphs of both stores")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *cb == "" || fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "diffstores: expected the connection string of the second store")
+		return 2
+	}
+	a := store
+	if *ca != "" {
+		db, err := badwolf.Open(*ca)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer db.Close()
+		a = db.Store()
+	}
+	db, err := badwolf.Open(*cb)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	var ids []string
+	if *graphs != "" {
+		ids = strings.Split(*graphs, ",")
+	}
+	equal, err := diffStores(ctx, os.Stdout, a, db.Store(), ids)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !equal {
+		return 1
+	}
+	return 0
+}
+
+// diffStores writes the differences found between the provided graphs of both
+// stores, or all their graphs if none is provided, and returns true if none
+// was found.
+func diffStores(ctx context.Context, w io.Writer, a, b storage.Store, ids []string) (bool, error) {
+	if len(ids) == 0 {
+		var err error
+		if ids, err = graphNames(a, b); err != nil {
+			return false, err
+		}
+	}
+	equal := true
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		ga, err := graphOrEmpty(a, id)
+		if err != nil {
+			return false, err
+		}
+		gb, err := graphOrEmpty(b, id)
+		if err != nil {
+			return false, err
+		}
+		d, err := bio.DiffGraphs(ga, gb)
+		if err != nil {
+			return false, fmt.Errorf("diffstores: failed to compare graph %s with error %v", id, err)
+		}
+		if d.Equal() {
+			continue
+		}
+		equal = false
+		fmt.Fprintf(w, "graph %s: %d missing, %d extra\n", id, len(d.Missing), len(d.Extra))
+		for _, t := range d.Missing {
+			fmt.Fprintf(w, "- %s\n", t)
+		}
+		for _, t := range d.Extra {
+			fmt.Fprintf(w, "+ %s\n", t)
+		}
+	}
+	return equal, nil
+}
+
+// graphNames returns the sorted names of the graphs held by any of the stores.
+func graphNames(ss ...storage.Store) ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, s := range ss {
+		gl, ok := s.(storage.GraphLister)
+		if !ok {
+			return nil, fmt.Errorf("diffstores: store %s cannot list its graphs; provide the graphs to compare", s.Name())
+		}
+		ns, err := gl.GraphNames()
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range ns {
+			if !seen[n] {
+				seen[n] = true
+				ids = append(ids, n)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// graphOrEmpty returns the graph of the store, or an empty graph if the store
+// does not hold it.
+func graphOrEmpty(s storage.Store, id string) (storage.Graph, error) {
+	g, err := s.Graph(id)
+	if err == nil {
+		return g, nil
+	}
+	if ok, lerr := holds(s, id); lerr != nil || ok {
+		return nil, err
+	}
+	return memory.NewStore().NewGraph(id)
+}
+
+// holds returns true if the graph is listed by the store.
+func holds(s storage.Store, id string) (bool, error) {
+	gl, ok := s.(storage.GraphLister)
+	if !ok {
+		return false, nil
+	}
+	ns, err := gl.GraphNames()
+	if err != nil {
+		return false, err
+	}
+	for _, n := range ns {
+		if n == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
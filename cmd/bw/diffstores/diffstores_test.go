@@ -0,0 +1,90 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diffstores
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/badwolf"
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// testStore returns a store holding the provided triples on the graph.
+func testStore(t *testing.T, id, data string) storage.Store {
+	s := memory.NewStore()
+	g, err := s.NewGraph(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bio.ReadIntoGraph(g, strings.NewReader(data), literal.DefaultBuilder()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDiffStores(t *testing.T) {
+	ctx := context.Background()
+	a := testStore(t, "?family", "/u<joe>\t\"parent_of\"@[]\t/u<mary>\n/u<joe>\t\"parent_of\"@[]\t/u<peter>\n")
+	b := testStore(t, "?family", "/u<joe>\t\"parent_of\"@[]\t/u<mary>\n/u<joe>\t\"parent_of\"@[]\t/u<eve>\n")
+	if _, err := b.NewGraph("?empty"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	equal, err := diffStores(ctx, &buf, a, b, nil)
+	if err != nil {
+		t.Fatalf("diffStores failed with error %v", err)
+	}
+	if equal {
+		t.Errorf("diffStores should have found differences")
+	}
+	want := "graph ?family: 1 missing, 1 extra\n- /u<joe>\t\"parent_of\"@[]\t/u<peter>\n+ /u<joe>\t\"parent_of\"@[]\t/u<eve>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("diffStores wrote\n%s\nwant\n%s", got, want)
+	}
+	buf.Reset()
+	if equal, err := diffStores(ctx, &buf, a, a, nil); err != nil || !equal || buf.Len() != 0 {
+		t.Errorf("diffStores should have found no differences comparing a store with itself; got %v, %v, %q", equal, err, buf.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	a := testStore(t, "?family", "/u<joe>\t\"parent_of\"@[]\t/u<mary>\n")
+	b := testStore(t, "?family", "/u<joe>\t\"parent_of\"@[]\t/u<mary>\n")
+	if err := badwolf.Register("diffstores_test", func(string) (storage.Store, error) { return b, nil }); err != nil {
+		t.Fatal(err)
+	}
+	table := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-b", "diffstores_test://"}, 0},
+		{[]string{"-a", "memory://", "-b", "diffstores_test://"}, 1},
+		{[]string{"-a", "memory://", "-b", "diffstores_test://", "-graphs", "?other"}, 0},
+		{[]string{"-b", "unknown://"}, 1},
+		{[]string{}, 2},
+	}
+	for _, entry := range table {
+		if got := run(ctx, a, entry.args); got != entry.want {
+			t.Errorf("diffstores %v returned %d, want %d", entry.args, got, entry.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export implements the command that exports graphs into files.
+package export
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/cmd/bw/command"
+	"github.com/google/badwolf/io/bulk"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+)
+
+// New returns the command that exports a graph, or the triples selected by a
+// query, into a file.
+func New() *command.Command {
+	return &command.Command{
+		Run:       run,
+		UsageLine: "export [-graph g | -query bql] [-format f] [-output file]",
+		Short:     "exports the triples of a graph.",
+		Long: `Writes the triples of a graph to the standard output, or to the provided file.
+The format may be text, ntriples, csv, json, or binary; if none is provided,
+it is inferred from the extension of the output file as the load command does,
+and defaults to text. Files exported as text, csv, json, or binary can be
+loaded back with the load command without losing any information.
+
+Instead of the whole graph, the triples selected by a BQL query can be
+exported. The query must bind the subject, predicate, and object of the
+triples to ?s, ?p, and ?o, as in
+
+  bw export -query 'select ?s, ?p, ?o from ?family where {?s ?p ?o . ?s "parent_of"@[] ?c};'
+
+Triples are written as they are read, so exporting large graphs does not
+require holding them in memory.`,
+	}
+}
+
+// run exports the graph using the options provided on the arguments.
+func run(ctx context.Context, store storage.Store, args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	id := fs.String("graph", "", "graph to export")
+	format := fs.String("format", "", "format of the output: text, ntriples, csv, json, or binary")
+	query := fs.String("query", "", "BQL query binding ?s, ?p, and ?o to the triples to export, instead of the whole graph")
+	output := fs.String("output", "", "file to write to; defaults to the standard output")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *id == "" && *query == "" || fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "export: expected a graph or a query to export")
+		return 2
+	}
+	f := bulk.FileFormat(*output)
+	if *format != "" {
+		var err error
+		if f, err = bulk.ParseFormat(*format); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		ts   storage.Triples
+		errc = make(chan error, 1)
+	)
+	if *query != "" {
+		ts = queryTriples(ctx, store, *query, errc)
+	} else {
+		g, err := store.Graph(*id)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if ts, err = g.Triples(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		close(errc)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		out, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer out.Close()
+		w = out
+	}
+	n, err := bulk.Export(ctx, w, ts, bulk.Options{Format: f})
+	if err != nil {
+		// Stop the query producing the triples, if any.
+		cancel()
+	}
+	if qerr := <-errc; err == nil {
+		err = qerr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d triples to %s\n", n, *output)
+	}
+	return 0
+}
+
+// queryTriples runs the provided query and returns the triples bound to ?s, ?p,
+// and ?o on each row as they are produced. The error of the query, if any, is
+// sent to errc once the returned channel is closed.
+func queryTriples(ctx context.Context, store storage.Store, q string, errc chan<- error) storage.Triples {
+	ts := make(chan *triple.Triple)
+	go func() {
+		defer close(errc)
+		defer close(ts)
+		errc <- func() error {
+			c, err := bql.NewCache(1)
+			if err != nil {
+				return err
+			}
+			stm, err := c.Statement(q)
+			if err != nil {
+				return err
+			}
+			bs := make(map[string]bool)
+			for _, b := range stm.OutputBindings() {
+				bs[b] = true
+			}
+			if !bs["?s"] || !bs["?p"] || !bs["?o"] {
+				return errBindings
+			}
+			rs, err := planner.Execute(ctx, store, stm)
+			if err != nil {
+				return err
+			}
+			defer rs.Close()
+			for rs.Next() {
+				t, err := rowTriple(rs.Row())
+				if err != nil {
+					return err
+				}
+				select {
+				case ts <- t:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return rs.Err()
+		}()
+	}()
+	return ts
+}
+
+// errBindings is returned for queries that do not bind triples.
+var errBindings = errors.New("export: the query must bind ?s to nodes, ?p to predicates, and ?o to objects")
+
+// rowTriple returns the triple bound to ?s, ?p, and ?o on the row.
+func rowTriple(r table.Row) (*triple.Triple, error) {
+	s, p, o := r["?s"], r["?p"], r["?o"]
+	if s == nil || s.N == nil || p == nil || p.P == nil || o == nil {
+		return nil, errBindings
+	}
+	var obj *triple.Object
+	switch {
+	case o.N != nil:
+		obj = triple.NewNodeObject(o.N)
+	case o.P != nil:
+		obj = triple.NewPredicateObject(o.P)
+	case o.L != nil:
+		obj = triple.NewLiteralObject(o.L)
+	case o.E != nil:
+		obj = triple.NewTripleObject(o.E)
+	default:
+		return nil, fmt.Errorf("export: cannot export %v as an object", o)
+	}
+	return triple.New(s.N, p.P, obj)
+}
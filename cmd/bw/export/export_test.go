@@ -0,0 +1,84 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	g, err := store.NewGraph("?family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := `/u<joe> "parent_of"@[] /u<mary>
+/u<joe> "name"@[] "Joe"^^type:text
+/u<mary> "parent_of"@[] /u<peter>`
+	if _, err := bio.ReadIntoGraph(g, strings.NewReader(data), literal.DefaultBuilder()); err != nil {
+		t.Fatal(err)
+	}
+	table := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"-graph", "?family", "-format", "text"},
+			want: strings.Split(strings.ReplaceAll(data, " ", "\t"), "\n"),
+		},
+		{
+			args: []string{"-query", `select ?s, ?p, ?o from ?family where {?s ?p ?o . ?o "parent_of"@[] ?c};`},
+			want: []string{`{"s":"/u<joe>","p":"\"parent_of\"@[]","o":"/u<mary>"}`},
+		},
+	}
+	for i, entry := range table {
+		out := filepath.Join(t.TempDir(), "out.json")
+		if got := run(ctx, store, append(entry.args, "-output", out)); got != 0 {
+			t.Errorf("export %v returned %d, want 0", entry.args, got)
+			continue
+		}
+		bs, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Split(strings.TrimSpace(string(bs)), "\n")
+		sort.Strings(got)
+		sort.Strings(entry.want)
+		if strings.Join(got, "\n") != strings.Join(entry.want, "\n") {
+			t.Errorf("export case %d wrote\n%s\nwant\n%s", i, strings.Join(got, "\n"), strings.Join(entry.want, "\n"))
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-graph", "?missing"},
+		{"-query", `select ?s from ?family where {?s ?p ?o};`},
+		{"-graph", "?family", "-format", "xml"},
+		{},
+	} {
+		if got := run(ctx, store, append(args, "-output", filepath.Join(t.TempDir(), "out"))); got == 0 {
+			t.Errorf("export %v should have failed", args)
+		}
+	}
+}
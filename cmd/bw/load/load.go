@@ -0,0 +1,107 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package load implements the command that bulk loads files into graphs.
+package load
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/badwolf/cmd/bw/command"
+	"github.com/google/badwolf/io/bulk"
+	"github.com/google/badwolf/storage"
+)
+
+// New returns the command that bulk loads a file into a graph.
+func New() *command.Command {
+	return &command.Command{
+		Run:       run,
+		UsageLine: "load [-format f] [-workers n] [-batch_size n] [-max_errors n] [-checkpoint file] <file> <graph>",
+		Short:     "bulk loads a file of triples into a graph.",
+		Long: `Loads the triples of a file into a graph, creating the graph if needed. Files
+may be text files with a triple per line, N-Triples files, CSV files with
+subject, predicate, and object fields, JSON files with an object per line, or
+binary files as written by the export command. The format is inferred from the
+file extension unless provided: .nt files are N-Triples, .csv files are CSV,
+.json and .jsonl files are JSON, .bin files are binary, and other files are
+text. Lines are parsed in parallel. Lines that fail to parse
+are reported with their line number, and fail the load once more than
+-max_errors are found. If a checkpoint file is provided, a failed load can be
+resumed after the lines already loaded by running the command again.`,
+	}
+}
+
+// run loads the file using the options provided on the arguments.
+func run(ctx context.Context, store storage.Store, args []string) int {
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	format := fs.String("format", "", "format of the file: text, ntriples, csv, json, or binary; inferred from the extension if empty")
+	workers := fs.Int("workers", 0, "number of parsing workers; defaults to the number of CPUs")
+	batchSize := fs.Int("batch_size", 1000, "number of lines added to the graph at once")
+	maxErrors := fs.Int("max_errors", 0, "number of lines that may fail to parse before the load fails")
+	checkpoint := fs.String("checkpoint", "", "file recording the progress of the load, used to resume it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "load: expected a file and a graph name")
+		return 2
+	}
+	path, id := fs.Arg(0), fs.Arg(1)
+	f := bulk.FileFormat(path)
+	if *format != "" {
+		var err error
+		if f, err = bulk.ParseFormat(*format); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer in.Close()
+	g, err := store.Graph(id)
+	if err != nil {
+		if g, err = store.NewGraph(id); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	stats, err := bulk.Load(ctx, g, in, bulk.Options{
+		Format:     f,
+		Workers:    *workers,
+		BatchSize:  *batchSize,
+		MaxErrors:  *maxErrors,
+		Checkpoint: *checkpoint,
+	})
+	if stats != nil {
+		for _, perr := range stats.Errors {
+			fmt.Fprintf(os.Stderr, "%s:%d: %v\n", path, perr.Line, perr.Err)
+		}
+		if stats.Resumed > 0 {
+			fmt.Fprintf(os.Stderr, "Resumed after line %d\n", stats.Resumed)
+		}
+		fmt.Fprintf(os.Stderr, "Loaded %d triples from %d lines into %s in %v (%.0f triples/s); %d lines skipped\n",
+			stats.Triples, stats.Lines, id, stats.Elapsed, stats.Throughput(), len(stats.Errors))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
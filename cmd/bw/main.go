@@ -0,0 +1,154 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command bw provides command line access to BadWolf stores. Run bw help to
+// list the available commands.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/badwolf/cmd/bw/bench"
+	"github.com/google/badwolf/cmd/bw/command"
+	"github.com/google/badwolf/cmd/bw/diffstores"
+	"github.com/google/badwolf/cmd/bw/export"
+	"github.com/google/badwolf/cmd/bw/load"
+	"github.com/google/badwolf/cmd/bw/repl"
+	"github.com/google/badwolf/cmd/bw/serve"
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+)
+
+var (
+	driver      = flag.String("driver", "memory", "storage driver used to create the store; one of "+strings.Join(driverNames(), ", "))
+	maxColWidth = flag.Int("max_col_width", 64, "maximum width of the rendered table columns; non positive values do not truncate them")
+	maxRows     = flag.Int("max_rows", 1000, "maximum number of rendered table rows; non positive values render all rows")
+	codec       = flag.String("codec", "", "codec used to dump and restore the graphs of the store, such as gzip; empty uses none")
+	history     = flag.String("history", defaultHistoryFile(), "file keeping the history of the statements run interactively; empty disables it")
+)
+
+// drivers contains the constructors of the available stores by driver name.
+var drivers = map[string]func(opts storage.Options) (storage.Store, error){
+	"memory": func(opts storage.Options) (storage.Store, error) {
+		return memory.NewStoreWithOptions(opts), nil
+	},
+}
+
+// driverNames returns the sorted names of the available drivers.
+func driverNames() []string {
+	var ns []string
+	for n := range drivers {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// defaultHistoryFile returns the history file in the home of the user, if any.
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".bw_history")
+}
+
+// commands returns the commands offered by the tool.
+func commands() []*command.Command {
+	return []*command.Command{
+		repl.New(repl.Options{
+			MaxColWidth: *maxColWidth,
+			MaxRows:     *maxRows,
+			HistoryFile: *history,
+		}),
+		bench.New(),
+		diffstores.New(),
+		export.New(),
+		load.New(),
+		serve.New(),
+	}
+}
+
+// usage writes the usage of the tool and the list of commands.
+func usage(cmds []*command.Command) {
+	fmt.Fprintf(os.Stderr, "usage: bw [flags] <command> [arguments]\n\nCommands:\n")
+	for _, c := range cmds {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.Name(), c.Short)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse bw help <command> for more information about a command.\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Parse()
+	os.Exit(run(flag.Args()))
+}
+
+// run executes the command named by the first argument, or starts an
+// interactive session if none is provided, and returns the exit code.
+func run(args []string) int {
+	cmds := commands()
+	if len(args) == 0 {
+		args = []string{"repl"}
+	}
+	if args[0] == "help" {
+		if len(args) == 1 {
+			usage(cmds)
+			return 0
+		}
+		for _, c := range cmds {
+			if c.Name() == args[1] {
+				c.Usage(os.Stdout)
+				return 0
+			}
+		}
+		fmt.Fprintf(os.Stderr, "bw: unknown command %q\n", args[1])
+		return 2
+	}
+	for _, c := range cmds {
+		if c.Name() != args[0] {
+			continue
+		}
+		newStore, ok := drivers[*driver]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "bw: unknown storage driver %q; available drivers are %s\n", *driver, strings.Join(driverNames(), ", "))
+			return 2
+		}
+		if *codec != "" {
+			if _, err := bio.GetCodec(*codec); err != nil {
+				fmt.Fprintf(os.Stderr, "bw: %v\n", err)
+				return 2
+			}
+		}
+		store, err := newStore(storage.Options{Codec: *codec})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bw: failed to create the %s store with error %v\n", *driver, err)
+			return 2
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return c.Run(ctx, store, args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "bw: unknown command %q\n", args[0])
+	usage(cmds)
+	return 2
+}
@@ -0,0 +1,406 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repl implements an interactive shell to run BQL statements.
+package repl
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/cmd/bw/command"
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/io/bulk"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple/literal"
+)
+
+const (
+	prompt     = "bql> "
+	contPrompt = "...> "
+
+	// cacheSize contains the number of parsed queries kept by the session.
+	cacheSize = 128
+
+	help = `Statements end with ; and may span multiple lines. Commands:
+  \help                  Shows this help.
+  \quit                  Ends the session.
+  \reset                 Discards the statement being typed.
+  \timing                Toggles reporting the time each statement takes.
+  \history               Lists the statements run so far.
+  \graphs                Lists the graphs in the store.
+  \load <file> <graph>   Loads the triples in the file into the graph, creating
+                         it if needed. Files ending in .gz are decompressed.
+                         .nt, .csv, .json, and .bin files are read as the load
+                         command does; other files are decoded using the codec
+                         of the store.
+  \dump <graph> <file>   Writes the triples of the graph into the file, encoded
+                         using the codec of the store.`
+)
+
+// Options configures an interactive session.
+type Options struct {
+	// MaxColWidth and MaxRows limit the size of the rendered tables. Non
+	// positive values render tables in full.
+	MaxColWidth int
+	MaxRows     int
+
+	// HistoryFile, if not empty, names the file statements are loaded from when
+	// the session starts and appended to as they are run.
+	HistoryFile string
+}
+
+// New returns the command that starts an interactive session on the standard
+// input and output.
+func New(opts Options) *command.Command {
+	return &command.Command{
+		Run: func(ctx context.Context, store storage.Store, args []string) int {
+			return Run(ctx, store, os.Stdin, os.Stdout, opts)
+		},
+		UsageLine: "repl",
+		Short:     "starts an interactive BQL shell.",
+		Long: `Starts an interactive shell that runs the BQL statements typed against the
+selected store. Type \help once started to list the available commands.`,
+	}
+}
+
+// session contains the state of an interactive session.
+type session struct {
+	store   storage.Store
+	cache   *bql.Cache
+	out     io.Writer
+	opts    Options
+	timing  bool
+	history []string
+	buf     strings.Builder
+}
+
+// Run reads statements and commands from the input until it is exhausted, the
+// session is ended, or the context is cancelled, writing their results to the
+// output. It returns the exit code of the tool.
+func Run(ctx context.Context, store storage.Store, in io.Reader, out io.Writer, opts Options) int {
+	cache, err := bql.NewCache(cacheSize)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 2
+	}
+	s := &session{
+		store: store,
+		cache: cache,
+		out:   out,
+		opts:  opts,
+	}
+	if err := s.loadHistory(); err != nil {
+		fmt.Fprintf(out, "[ERROR] failed to load history: %v\n", err)
+	}
+	// Lines are read on their own goroutine, so cancelling the context ends the
+	// session without waiting for more input.
+	lines := make(chan string)
+	var rerr error
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(in)
+		sc.Buffer(nil, 1024*1024)
+		for sc.Scan() {
+			select {
+			case lines <- sc.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		rerr = sc.Err()
+	}()
+	for {
+		if s.buf.Len() == 0 {
+			fmt.Fprint(out, prompt)
+		} else {
+			fmt.Fprint(out, contPrompt)
+		}
+		var (
+			line string
+			ok   bool
+		)
+		select {
+		case line, ok = <-lines:
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return 1
+		}
+		if !ok {
+			break
+		}
+		// BQL lines never start with a backslash, hence commands can be run
+		// while typing a statement.
+		if l := strings.TrimSpace(line); strings.HasPrefix(l, `\`) {
+			if done := s.command(ctx, l); done {
+				return 0
+			}
+			continue
+		}
+		s.buf.WriteString(line)
+		s.buf.WriteString("\n")
+		for _, q := range s.statements() {
+			s.execute(ctx, q)
+		}
+	}
+	fmt.Fprintln(out)
+	if rerr != nil {
+		fmt.Fprintf(out, "[ERROR] failed to read input: %v\n", rerr)
+		return 1
+	}
+	return 0
+}
+
+// statements removes the complete statements from the buffer and returns
+// them. Statements end on a semicolon outside quoted values. Text the lexer
+// cannot make sense of is returned as a statement once its line ends with a
+// semicolon, so the parser reports the error.
+func (s *session) statements() []string {
+	text := s.buf.String()
+	spans, _ := lexer.Scan(text, lexer.Options{})
+	var (
+		qs    []string
+		start int
+	)
+	for _, sp := range spans {
+		if sp.Type == lexer.ItemError {
+			if rest := strings.TrimSpace(text[start:]); strings.HasSuffix(rest, ";") {
+				qs = append(qs, rest)
+				start = len(text)
+			}
+			break
+		}
+		if sp.Type == lexer.ItemSemicolon {
+			qs = append(qs, strings.TrimSpace(text[start:sp.End]))
+			start = sp.End
+		}
+	}
+	rest := text[start:]
+	s.buf.Reset()
+	if strings.TrimSpace(rest) != "" {
+		s.buf.WriteString(rest)
+	}
+	return qs
+}
+
+// execute runs the provided statement and writes its results.
+func (s *session) execute(ctx context.Context, q string) {
+	s.addHistory(q)
+	if s.timing {
+		defer func(start time.Time) {
+			fmt.Fprintf(s.out, "Time: %v\n", time.Since(start))
+		}(time.Now())
+	}
+	pln, err := s.cache.Plan(s.store, q)
+	if err != nil {
+		fmt.Fprintf(s.out, "[ERROR] %v\n", err)
+		return
+	}
+	tbl, err := pln.Excecute(ctx)
+	if err != nil {
+		fmt.Fprintf(s.out, "[ERROR] %v\n", err)
+		return
+	}
+	if tbl != nil {
+		defer tbl.Close()
+	}
+	if tbl != nil && len(tbl.Bindings()) > 0 {
+		txt, err := tbl.ToPrettyText(s.opts.MaxColWidth, s.opts.MaxRows)
+		if err != nil {
+			fmt.Fprintf(s.out, "[ERROR] %v\n", err)
+			return
+		}
+		fmt.Fprint(s.out, txt.String())
+		return
+	}
+	fmt.Fprintln(s.out, "OK")
+}
+
+// command runs a shell command. It returns true if the session should end.
+func (s *session) command(ctx context.Context, l string) bool {
+	args := strings.Fields(l)
+	switch args[0] {
+	case `\quit`, `\q`:
+		return true
+	case `\help`, `\h`:
+		fmt.Fprintln(s.out, help)
+	case `\reset`:
+		s.buf.Reset()
+	case `\timing`:
+		s.timing = !s.timing
+		if s.timing {
+			fmt.Fprintln(s.out, "Timing is on.")
+		} else {
+			fmt.Fprintln(s.out, "Timing is off.")
+		}
+	case `\history`:
+		for i, q := range s.history {
+			fmt.Fprintf(s.out, "%5d  %s\n", i+1, q)
+		}
+	case `\graphs`:
+		gl, ok := s.store.(storage.GraphLister)
+		if !ok {
+			fmt.Fprintln(s.out, "[ERROR] the store cannot list its graphs")
+			break
+		}
+		ids, err := gl.GraphNames()
+		if err != nil {
+			fmt.Fprintf(s.out, "[ERROR] %v\n", err)
+			break
+		}
+		for _, id := range ids {
+			fmt.Fprintln(s.out, id)
+		}
+	case `\load`:
+		if len(args) != 3 {
+			fmt.Fprintln(s.out, `[ERROR] usage: \load <file> <graph>`)
+			break
+		}
+		start := time.Now()
+		n, err := s.load(ctx, args[1], args[2])
+		if err != nil {
+			fmt.Fprintf(s.out, "[ERROR] %v\n", err)
+			break
+		}
+		fmt.Fprintf(s.out, "Loaded %d triples into graph %s\n", n, args[2])
+		if s.timing {
+			fmt.Fprintf(s.out, "Time: %v\n", time.Since(start))
+		}
+	case `\dump`:
+		if len(args) != 3 {
+			fmt.Fprintln(s.out, `[ERROR] usage: \dump <graph> <file>`)
+			break
+		}
+		start := time.Now()
+		n, err := s.dump(args[1], args[2])
+		if err != nil {
+			fmt.Fprintf(s.out, "[ERROR] %v\n", err)
+			break
+		}
+		fmt.Fprintf(s.out, "Dumped %d triples of graph %s\n", n, args[1])
+		if s.timing {
+			fmt.Fprintf(s.out, "Time: %v\n", time.Since(start))
+		}
+	default:
+		fmt.Fprintf(s.out, "[ERROR] unknown command %s; type \\help to list the available ones\n", args[0])
+	}
+	return false
+}
+
+// load reads the triples in the file into the graph, creating the graph if it
+// does not exist. The format of the file is inferred from its extension, once
+// the .gz extension of compressed files is removed, as the load command does.
+// Text files are decoded using the codec of the store. It returns the number
+// of triples read.
+func (s *session) load(ctx context.Context, path, id string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	gz := strings.HasSuffix(path, ".gz")
+	format := bulk.FileFormat(strings.TrimSuffix(path, ".gz"))
+	if format == bulk.Text && !gz {
+		return bio.RestoreGraph(f, s.store, id, literal.DefaultBuilder())
+	}
+	g, err := s.store.Graph(id)
+	if err != nil {
+		if g, err = s.store.NewGraph(id); err != nil {
+			return 0, err
+		}
+	}
+	if format == bulk.Text {
+		c, err := bio.GetCodec("gzip")
+		if err != nil {
+			return 0, err
+		}
+		return bio.ReadIntoGraphWithCodec(g, f, literal.DefaultBuilder(), c)
+	}
+	var r io.Reader = f
+	if gz {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	stats, err := bulk.Load(ctx, g, r, bulk.Options{Format: format})
+	if stats == nil {
+		return 0, err
+	}
+	return stats.Triples, err
+}
+
+// dump writes the triples of the graph into the file. It returns the number of
+// triples written.
+func (s *session) dump(id, path string) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := bio.DumpGraph(f, s.store, id)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// loadHistory loads the statements stored in the history file, if any.
+func (s *session) loadHistory() error {
+	if s.opts.HistoryFile == "" {
+		return nil
+	}
+	f, err := os.Open(s.opts.HistoryFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		if l := strings.TrimSpace(sc.Text()); l != "" {
+			s.history = append(s.history, l)
+		}
+	}
+	return sc.Err()
+}
+
+// addHistory records the statement in a single line, appending it to the
+// history file if one was provided.
+func (s *session) addHistory(q string) {
+	q = strings.Join(strings.Fields(q), " ")
+	s.history = append(s.history, q)
+	if s.opts.HistoryFile == "" {
+		return
+	}
+	f, err := os.OpenFile(s.opts.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprintf(s.out, "[ERROR] failed to save history: %v\n", err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, q)
+}
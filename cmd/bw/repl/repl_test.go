@@ -0,0 +1,120 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/badwolf/storage/memory"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	txt := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(txt, []byte("/u<joe> \"knows\"@[] /u<mary>\n/u<joe> \"knows\"@[] /u<peter>\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	nts := "<http://example.org/joe> <http://example.org/knows> <http://example.org/mary> .\n" +
+		"<http://example.org/joe> <http://example.org/age> \"42\"^^<http://www.w3.org/2001/XMLSchema#integer> .\n"
+	nt := filepath.Join(dir, "data.nt")
+	if err := os.WriteFile(nt, []byte(nts), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(nts))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ntgz := filepath.Join(dir, "data.nt.gz")
+	if err := os.WriteFile(ntgz, gz.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	hst := filepath.Join(dir, "history")
+	in := strings.Join([]string{
+		`\load ` + txt + ` ?test`,
+		`\load ` + nt + ` ?nt`,
+		`\load ` + ntgz + ` ?ntgz`,
+		`\graphs`,
+		`\dump ?test ` + filepath.Join(dir, "dump"),
+		`select ?o`,
+		`from ?test`,
+		`where {/u<joe> "knows"@[] ?o}`,
+		`order by ?o;`,
+		`create graph ?other; drop graph ?other;`,
+		`\timing`,
+		`select ?s from ?missing where {?s ?p ?o};`,
+		`select ?s from`,
+		`\reset`,
+		`\history`,
+		`\unknown`,
+		`\quit`,
+		`select ?s from ?test where {?s ?p ?o};`,
+	}, "\n")
+	out := &bytes.Buffer{}
+	if got, want := Run(context.Background(), memory.NewStore(), strings.NewReader(in), out, Options{HistoryFile: hst}), 0; got != want {
+		t.Fatalf("repl.Run returned exit code %d, want %d; output:\n%s", got, want, out)
+	}
+	for _, want := range []string{
+		"Loaded 2 triples into graph ?test",
+		"Loaded 2 triples into graph ?nt",
+		"Loaded 2 triples into graph ?ntgz",
+		"?test\n",
+		"Dumped 2 triples of graph ?test",
+		"| /u<mary>  |",
+		"| /u<peter> |",
+		"OK\nOK\n",
+		"Timing is on.",
+		"[ERROR]",
+		"Time: ",
+		"    1  select ?o from ?test where {/u<joe> \"knows\"@[] ?o} order by ?o;",
+		"    3  drop graph ?other;",
+		"unknown command \\unknown",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("repl.Run output does not contain %q; output:\n%s", want, out)
+		}
+	}
+	// Statements after \quit are not run.
+	if got, want := strings.Count(out.String(), "/u<joe>"), 1; got != want {
+		t.Errorf("repl.Run ran statements after the session ended; output:\n%s", out)
+	}
+	// The history is kept across sessions.
+	out.Reset()
+	Run(context.Background(), memory.NewStore(), strings.NewReader(`\history`), out, Options{HistoryFile: hst})
+	if !strings.Contains(out.String(), "    4  select ?s from ?missing where {?s ?p ?o};") {
+		t.Errorf("repl.Run failed to load the history file; output:\n%s", out)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	defer r.Close()
+	if got, want := Run(ctx, memory.NewStore(), r, &bytes.Buffer{}, Options{}), 1; got != want {
+		t.Errorf("repl.Run returned exit code %d for a cancelled context, want %d", got, want)
+	}
+}
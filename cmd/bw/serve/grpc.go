@@ -0,0 +1,73 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build grpc
+
+package serve
+
+import (
+	"context"
+	"net"
+
+	"github.com/google/badwolf/audit"
+	"github.com/google/badwolf/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func init() {
+	newGRPCServer = func(lis net.Listener, svc *rpc.Service, errs chan<- error) grpcServer {
+		gs := grpc.NewServer(grpc.UnaryInterceptor(unaryCaller), grpc.StreamInterceptor(streamCaller))
+		rpc.RegisterGRPC(gs, svc)
+		go func() {
+			errs <- gs.Serve(lis)
+		}()
+		return gs
+	}
+}
+
+// peerCaller returns a context identifying the caller of a gRPC call by its IP
+// address, as withCaller does for HTTP requests.
+func peerCaller(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return audit.WithCaller(ctx, host)
+}
+
+// unaryCaller identifies the caller of unary gRPC calls.
+func unaryCaller(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+	return h(peerCaller(ctx), req)
+}
+
+// callerStream overrides the context of a gRPC stream.
+type callerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context identifying the caller.
+func (s *callerStream) Context() context.Context {
+	return s.ctx
+}
+
+// streamCaller identifies the caller of streaming gRPC calls.
+func streamCaller(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+	return h(srv, &callerStream{ServerStream: ss, ctx: peerCaller(ss.Context())})
+}
@@ -0,0 +1,359 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package serve implements the command that serves a store over HTTP.
+package serve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/google/badwolf/audit"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/cmd/bw/command"
+	"github.com/google/badwolf/metrics"
+	"github.com/google/badwolf/rpc"
+	"github.com/google/badwolf/server"
+	"github.com/google/badwolf/storage"
+)
+
+// New returns the command that serves the store over HTTP until interrupted.
+func New() *command.Command {
+	return &command.Command{
+		Run:       run,
+		UsageLine: "serve [-addr host:port] [-grpc_addr host:port] [-timeout d] [-max_rows n] [-max_concurrent n [-max_per_caller n] [-max_queued n] [-queue_timeout d]] [-metrics] [-audit_log file [-redact]] [-drain_timeout d] [-cache_size n] [-config file]",
+		Short:     "serves the store over HTTP.",
+		Long: `Serves the store over HTTP until interrupted. BQL statements are run by
+posting them to /query, graphs are managed via /graphs, and /health reports
+whether the server can serve requests. See the server
+package for the details of each endpoint. If -grpc_addr is set, the
+QueryService defined in package rpc is also served over gRPC on that address,
+using the -timeout and -max_rows the command started with; it requires bw to
+be built with the grpc build tag. If -max_concurrent or
+-max_per_caller are set, statements wait to be admitted while those limits are
+reached, and fail once -max_queued statements are waiting or after waiting for
+-queue_timeout. Callers are identified by their IP address. If -metrics is set, the server
+metrics are published via expvar at /debug/vars. If -audit_log is set, an
+entry describing every executed statement is appended to the file as a JSON
+object per line; - writes them to the standard error. -redact hides the values
+of the literals on the logged statements. Once interrupted, the server stops
+accepting requests and waits up to -drain_timeout for the ones in flight.
+-config names a JSON object setting any of -timeout, -max_rows, -cache_size,
+-max_concurrent, -max_per_caller, -max_queued, -queue_timeout, -redact, and
+-drain_timeout, as in {"max_rows": 1000, "timeout": "30s"}. The file is read
+again when the command receives SIGHUP, applying the new values without
+restarting the server and reporting each change on the standard error.`,
+	}
+}
+
+// flags contains the values of the flags of the command.
+type flags struct {
+	fs            *flag.FlagSet
+	addr          *string
+	grpcAddr      *string
+	config        *string
+	timeout       *time.Duration
+	maxRows       *int64
+	cacheSize     *int
+	maxConcurrent *int
+	maxPerCaller  *int
+	maxQueued     *int
+	queueTimeout  *time.Duration
+	metrics       *bool
+	auditLog      *string
+	redact        *bool
+	drain         *time.Duration
+}
+
+// reloadable contains the flags that may be set on the configuration file,
+// which is read again when the command receives SIGHUP.
+var reloadable = map[string]bool{
+	"timeout":        true,
+	"max_rows":       true,
+	"cache_size":     true,
+	"max_concurrent": true,
+	"max_per_caller": true,
+	"max_queued":     true,
+	"queue_timeout":  true,
+	"redact":         true,
+	"drain_timeout":  true,
+}
+
+// parseFlags parses the arguments, and then applies the configuration file if
+// one is provided.
+func parseFlags(args []string) (*flags, error) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	f := &flags{
+		fs:            fs,
+		addr:          fs.String("addr", "localhost:8080", "address to listen on"),
+		grpcAddr:      fs.String("grpc_addr", "", "address to serve the gRPC QueryService on; empty disables it"),
+		config:        fs.String("config", "", "JSON file setting the reloadable flags; read again on SIGHUP"),
+		timeout:       fs.Duration("timeout", time.Minute, "maximum time a request may take to run its statement; zero disables it"),
+		maxRows:       fs.Int64("max_rows", 100000, "maximum number of rows a query may return; zero disables it"),
+		cacheSize:     fs.Int("cache_size", 1024, "number of parsed queries kept"),
+		maxConcurrent: fs.Int("max_concurrent", 0, "maximum number of statements running at once; zero disables it"),
+		maxPerCaller:  fs.Int("max_per_caller", 0, "maximum number of statements a caller may run at once; zero disables it"),
+		maxQueued:     fs.Int("max_queued", 0, "maximum number of statements waiting to be admitted; zero disables it"),
+		queueTimeout:  fs.Duration("queue_timeout", 0, "maximum time a statement may wait to be admitted; zero disables it"),
+		metrics:       fs.Bool("metrics", false, "publish the server metrics at /debug/vars"),
+		auditLog:      fs.String("audit_log", "", "file to append the audit entries to; - for the standard error"),
+		redact:        fs.Bool("redact", false, "hide the values of literals on the audit entries"),
+		drain:         fs.Duration("drain_timeout", 30*time.Second, "maximum time to wait for the requests in flight when interrupted"),
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *f.config == "" {
+		return f, nil
+	}
+	b, err := os.ReadFile(*f.config)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// Numbers are kept as written, so large ones are not turned into floats.
+	dec.UseNumber()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode the configuration file %q with error %v", *f.config, err)
+	}
+	for k, v := range cfg {
+		if !reloadable[k] {
+			return nil, fmt.Errorf("flag -%s cannot be set on the configuration file", k)
+		}
+		if err := fs.Set(k, fmt.Sprint(v)); err != nil {
+			return nil, fmt.Errorf("invalid value %v for flag -%s on the configuration file; %v", v, k, err)
+		}
+	}
+	return f, nil
+}
+
+// admission returns the options of the admission controller.
+func (f *flags) admission() planner.AdmissionOptions {
+	return planner.AdmissionOptions{
+		MaxConcurrent: *f.maxConcurrent,
+		MaxPerCaller:  *f.maxPerCaller,
+		MaxQueued:     *f.maxQueued,
+		QueueTimeout:  *f.queueTimeout,
+	}
+}
+
+// changes returns a description of the flags whose values differ from the
+// ones in old.
+func (f *flags) changes(old *flags) []string {
+	var res []string
+	f.fs.VisitAll(func(fl *flag.Flag) {
+		if o := old.fs.Lookup(fl.Name).Value.String(); o != fl.Value.String() {
+			res = append(res, fmt.Sprintf("-%s %s -> %s", fl.Name, o, fl.Value))
+		}
+	})
+	return res
+}
+
+// run serves the store using the options provided on the arguments.
+func run(ctx context.Context, store storage.Store, args []string) int {
+	f, err := parseFlags(args)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 2
+	}
+	adm, err := planner.NewAdmission(f.admission())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var logger audit.Logger
+	switch *f.auditLog {
+	case "":
+	case "-":
+		logger = audit.NewJSONLogger(os.Stderr)
+	default:
+		fl, err := os.OpenFile(*f.auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer fl.Close()
+		logger = audit.NewJSONLogger(fl)
+	}
+	// options returns the server options for the provided flags.
+	options := func(f *flags) server.Options {
+		opts := server.Options{Timeout: *f.timeout, MaxRows: *f.maxRows, CacheSize: *f.cacheSize, Admission: adm, Audit: logger}
+		if logger != nil && *f.redact {
+			opts.Audit = audit.Redacting(logger)
+		}
+		return opts
+	}
+	opts := options(f)
+	var mc *metrics.Counters
+	if *f.metrics {
+		mc = metrics.NewCounters()
+		opts.Metrics = mc
+	}
+	s, err := server.New(store, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var h http.Handler = s
+	if mc != nil {
+		expvar.Publish("badwolf", mc.Var())
+		// http.ServeMux cleans paths, which would break the graph names with
+		// escaped slashes.
+		vars := expvar.Handler()
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/debug/vars" {
+				vars.ServeHTTP(w, r)
+				return
+			}
+			s.ServeHTTP(w, r)
+		})
+	}
+	hs := &http.Server{Addr: *f.addr, Handler: withCaller(h)}
+	errs := make(chan error, 2)
+	go func() {
+		errs <- hs.ListenAndServe()
+	}()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	fmt.Fprintf(os.Stderr, "Serving on http://%s\n", *f.addr)
+	var gs grpcServer
+	if *f.grpcAddr != "" {
+		if newGRPCServer == nil {
+			fmt.Fprintln(os.Stderr, "-grpc_addr requires bw to be built with the grpc build tag")
+			return 2
+		}
+		svc, err := rpc.NewService(store, rpc.Options{
+			Limits:    planner.Limits{MaxRows: *f.maxRows, MaxExecutionTime: *f.timeout},
+			Admission: adm,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		lis, err := net.Listen("tcp", *f.grpcAddr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		gs = newGRPCServer(lis, svc, errs)
+		defer gs.Stop()
+		fmt.Fprintf(os.Stderr, "Serving gRPC on %s\n", *f.grpcAddr)
+	}
+	for done := false; !done; {
+		select {
+		case err := <-errs:
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		case <-hup:
+			nf, err := reload(s, adm, f, options, args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to reload the configuration; %v\n", err)
+				continue
+			}
+			f = nf
+		case <-ctx.Done():
+			done = true
+		}
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), *f.drain)
+	defer cancel()
+	fmt.Fprintln(os.Stderr, "Draining")
+	if err := s.Drain(sctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := hs.Shutdown(sctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if gs != nil {
+		// Calls in flight may finish until the drain timeout expires.
+		stopped := make(chan struct{})
+		go func() {
+			gs.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-sctx.Done():
+			fmt.Fprintln(os.Stderr, sctx.Err())
+			return 1
+		}
+	}
+	return 0
+}
+
+// reload parses the arguments and the configuration file again, and applies
+// the resulting options to the running server. The changed flags are reported
+// on the standard error. On failure, the server keeps its current options.
+func reload(s *server.Server, adm *planner.Admission, old *flags, options func(*flags) server.Options, args []string) (*flags, error) {
+	f, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+	// The arguments do not change, hence only the reloadable flags may.
+	cs := f.changes(old)
+	if len(cs) == 0 {
+		fmt.Fprintln(os.Stderr, "Reloaded the configuration; nothing changed")
+		return f, nil
+	}
+	if err := s.Reload(options(f)); err != nil {
+		return nil, err
+	}
+	if err := adm.SetOptions(f.admission()); err != nil {
+		return nil, err
+	}
+	for _, c := range cs {
+		fmt.Fprintf(os.Stderr, "Reloaded %s\n", c)
+	}
+	return f, nil
+}
+
+// withCaller returns a handler that identifies the caller of each request by
+// its IP address, for the audit entries and per caller quotas.
+func withCaller(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		h.ServeHTTP(w, r.WithContext(audit.WithCaller(r.Context(), host)))
+	})
+}
+
+// grpcServer is the part of a gRPC server needed to stop it.
+type grpcServer interface {
+	Stop()
+	GracefulStop()
+}
+
+// newGRPCServer serves the service over gRPC on the listener, sending the
+// error that stops serving it to errs. It is nil unless the command is built
+// with the grpc build tag, which keeps the gRPC dependencies out of the
+// default build.
+var newGRPCServer func(lis net.Listener, svc *rpc.Service, errs chan<- error) grpcServer
@@ -0,0 +1,65 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.json")
+	args := []string{"-max_rows", "10", "-config", cfg}
+	if _, err := parseFlags(args); err == nil {
+		t.Errorf("parseFlags should have failed for a missing configuration file")
+	}
+	table := []struct {
+		config  string
+		changes []string
+		fail    bool
+	}{
+		{config: `{}`},
+		{config: `{"max_rows": 2000000, "timeout": "30s", "redact": true}`, changes: []string{"-max_rows 10 -> 2000000", "-redact false -> true", "-timeout 1m0s -> 30s"}},
+		{config: `{"addr": "localhost:1234"}`, fail: true},
+		{config: `{"max_rows": "many"}`, fail: true},
+		{config: `[]`, fail: true},
+	}
+	for _, entry := range table {
+		if err := os.WriteFile(cfg, []byte(`{}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		old, err := parseFlags(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(cfg, []byte(entry.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := parseFlags(args)
+		if got, want := err != nil, entry.fail; got != want {
+			t.Errorf("parseFlags with configuration %s returned error %v; want failure %v", entry.config, err, want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := f.changes(old); !reflect.DeepEqual(got, entry.changes) {
+			t.Errorf("changes for configuration %s returned %q; want %q", entry.config, got, entry.changes)
+		}
+	}
+}
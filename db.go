@@ -0,0 +1,317 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package badwolf provides a high level API to embed BadWolf stores in Go
+// programs. It hides the parsing, planning, and execution of BQL statements
+// behind a DB type:
+//
+//	db, err := badwolf.Open("memory://")
+//	if err != nil {
+//		return err
+//	}
+//	rows, err := db.Query(ctx, `select ?o from ?g where {$1 "knows"@[] ?o};`, joe)
+//	...
+//
+// Stores are selected using connection strings of the form driver://options,
+// where the options are interpreted by the driver. The memory driver is
+// always available, and accepts the options understood by ParseOptions, as in
+// memory://codec=gzip; other drivers register themselves using Register.
+package badwolf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/badwolf/audit"
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+)
+
+// cacheSize contains the number of parsed statements kept by each DB.
+const cacheSize = 1024
+
+// Driver creates a store given the options of a connection string, which are
+// everything after driver:// on it.
+type Driver func(options string) (storage.Store, error)
+
+var (
+	// driversMu guards drivers.
+	driversMu sync.RWMutex
+	// drivers contains the registered drivers by name.
+	drivers = map[string]Driver{
+		"memory": func(options string) (storage.Store, error) {
+			opts, err := ParseOptions(options)
+			if err != nil {
+				return nil, err
+			}
+			return memory.NewStoreWithOptions(opts), nil
+		},
+	}
+)
+
+// ParseOptions returns the store options found on the options of a connection
+// string, which are formatted as a URL query, as in codec=gzip. The codec must
+// be registered in the io package. Other options are ignored, so drivers can
+// define their own.
+func ParseOptions(options string) (storage.Options, error) {
+	v, err := url.ParseQuery(options)
+	if err != nil {
+		return storage.Options{}, fmt.Errorf("badwolf.ParseOptions: invalid options %q; %v", options, err)
+	}
+	opts := storage.Options{Codec: v.Get("codec")}
+	if opts.Codec != "" {
+		if _, err := bio.GetCodec(opts.Codec); err != nil {
+			return storage.Options{}, fmt.Errorf("badwolf.ParseOptions: %v", err)
+		}
+	}
+	return opts, nil
+}
+
+// Register makes a driver available to Open under the provided name. It fails
+// if a driver is already registered under the same name.
+func Register(name string, d Driver) error {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if name == "" || d == nil {
+		return fmt.Errorf("badwolf.Register: invalid driver %q", name)
+	}
+	if _, ok := drivers[name]; ok {
+		return fmt.Errorf("badwolf.Register: driver %q already registered", name)
+	}
+	drivers[name] = d
+	return nil
+}
+
+// Drivers returns the sorted names of the registered drivers.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	var ns []string
+	for n := range drivers {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// DB runs BQL statements against a store. It is safe for concurrent use,
+// including changing its settings while statements run; each statement uses
+// the settings in place when it started.
+type DB struct {
+	store storage.Store
+	cache *bql.Cache
+
+	mu   sync.RWMutex
+	sets settings
+}
+
+// settings contains the settings applied to the statements run by a DB.
+type settings struct {
+	lmts planner.Limits
+	log  audit.Logger
+	plcy *bql.Policy
+	adm  *planner.Admission
+}
+
+// Open returns a DB for the store described by the provided connection
+// string, such as memory://. The driver name alone, as in memory, is also
+// accepted.
+func Open(conn string) (*DB, error) {
+	name, opts := conn, ""
+	if i := strings.Index(conn, "://"); i >= 0 {
+		name, opts = conn[:i], conn[i+3:]
+	}
+	driversMu.RLock()
+	d, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("badwolf.Open: unknown driver %q; use one of %s", name, strings.Join(Drivers(), ", "))
+	}
+	s, err := d(opts)
+	if err != nil {
+		return nil, fmt.Errorf("badwolf.Open: %v", err)
+	}
+	return New(s)
+}
+
+// New returns a DB for the provided store.
+func New(s storage.Store) (*DB, error) {
+	c, err := bql.NewCache(cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{store: s, cache: c}, nil
+}
+
+// SetLimits sets the limits enforced on the statements run afterwards.
+func (db *DB) SetLimits(lmts planner.Limits) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.sets.lmts = lmts
+}
+
+// SetAuditLogger sets the logger that receives an entry for every statement
+// run afterwards. Queries are logged once their rows are closed. A nil logger
+// disables the logging.
+func (db *DB) SetAuditLogger(l audit.Logger) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.sets.log = l
+}
+
+// SetPolicy sets the policy that authorizes the statements run afterwards,
+// using the roles set on their context via bql.WithRoles. A nil policy allows
+// every statement.
+func (db *DB) SetPolicy(p *bql.Policy) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.sets.plcy = p
+}
+
+// SetAdmission sets the admission controller that decides when the statements
+// run afterwards may start, identifying their callers via audit.Caller. Query
+// slots are released once their rows are closed. A nil controller admits all
+// statements right away.
+func (db *DB) SetAdmission(a *planner.Admission) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.sets.adm = a
+}
+
+// settings returns a copy of the current settings.
+func (db *DB) settings() settings {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.sets
+}
+
+// admit waits until the statement may start, returning the function that
+// releases its slot.
+func (s settings) admit(ctx context.Context) (func(), error) {
+	if s.adm == nil {
+		return func() {}, nil
+	}
+	return s.adm.Admit(ctx, audit.Caller(ctx))
+}
+
+// Store returns the store the statements run against.
+func (db *DB) Store() storage.Store {
+	return db.store
+}
+
+// Close closes the store if it implements io.Closer.
+func (db *DB) Close() error {
+	if c, ok := db.store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// statement returns the prepared statement after binding the arguments to its
+// placeholders, checking the caller is allowed to run it; see Query.
+func (db *DB) statement(ctx context.Context, sets settings, q string, args []interface{}) (string, *planner.Prepared, error) {
+	q, err := bindArgs(q, args)
+	if err != nil {
+		return "", nil, err
+	}
+	prep, err := db.cache.Prepare(q)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := sets.plcy.Authorize(ctx, prep.Statement()); err != nil {
+		sets.audit(ctx, q, prep.Statement(), time.Now(), 0, err)
+		return "", nil, err
+	}
+	return q, prep, nil
+}
+
+// Query runs the provided BQL query and returns its rows as they are produced.
+// The arguments are bound to the $1, $2, ... placeholders of the statement,
+// in order. Arguments may be nodes, predicates, literals, strings, integers,
+// floats, booleans, and times. Each argument is bound as a single value, so it
+// cannot alter the statement; text values BQL cannot represent, such as the
+// ones containing quotes, are rejected. The rows must be closed once done.
+func (db *DB) Query(ctx context.Context, q string, args ...interface{}) (*Rows, error) {
+	sets := db.settings()
+	q, prep, err := db.statement(ctx, sets, q, args)
+	if err != nil {
+		return nil, err
+	}
+	stm := prep.Statement()
+	if stm.Type() != semantic.Query {
+		return nil, fmt.Errorf("badwolf.DB.Query: expected a query statement; use Exec to run %s statements", stm.Type())
+	}
+	release, err := sets.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	rs, err := prep.Execute(ctx, db.store, sets.lmts)
+	if err != nil {
+		release()
+		sets.audit(ctx, q, stm, start, 0, err)
+		return nil, err
+	}
+	return &Rows{
+		rs:   rs,
+		cols: stm.OutputBindings(),
+		done: func(n int, err error) {
+			release()
+			sets.audit(ctx, q, stm, start, n, err)
+		},
+	}, nil
+}
+
+// Exec runs the provided BQL statement, such as an insert or a graph creation,
+// discarding its results. Arguments are bound as Query does.
+func (db *DB) Exec(ctx context.Context, q string, args ...interface{}) error {
+	sets := db.settings()
+	q, prep, err := db.statement(ctx, sets, q, args)
+	if err != nil {
+		return err
+	}
+	pln, err := prep.Plan(db.store, sets.lmts)
+	if err != nil {
+		return err
+	}
+	release, err := sets.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	start := time.Now()
+	tbl, err := pln.Excecute(ctx)
+	if err == nil && tbl != nil {
+		err = tbl.Close()
+	}
+	sets.audit(ctx, q, prep.Statement(), start, 0, err)
+	return err
+}
+
+// audit logs the execution of the statement, if a logger is set.
+func (s settings) audit(ctx context.Context, q string, stm *semantic.Statement, start time.Time, rows int, err error) {
+	if s.log != nil {
+		s.log.Log(audit.NewEntry(ctx, q, stm, start, rows, err))
+	}
+}
@@ -0,0 +1,425 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badwolf
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/audit"
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// testDB returns a DB backed by a memory store holding a small graph.
+func testDB(ctx context.Context, t *testing.T) *DB {
+	db, err := Open("memory://")
+	if err != nil {
+		t.Fatalf("badwolf.Open(memory://) failed; %v", err)
+	}
+	for _, q := range []string{
+		`create graph ?people;`,
+		`insert data into ?people {
+			/u<joe> "knows"@[] /u<mary> .
+			/u<joe> "age"@[] "42"^^type:int64 .
+			/u<mary> "age"@[] "37"^^type:int64 .
+			/u<mary> "name"@[] "Mary"^^type:text
+		};`,
+	} {
+		if err := db.Exec(ctx, q); err != nil {
+			t.Fatalf("DB.Exec(%q) failed; %v", q, err)
+		}
+	}
+	return db
+}
+
+func TestOpen(t *testing.T) {
+	for _, conn := range []string{"memory", "memory://", "memory://ignored"} {
+		db, err := Open(conn)
+		if err != nil {
+			t.Errorf("badwolf.Open(%q) failed; %v", conn, err)
+			continue
+		}
+		if err := db.Close(); err != nil {
+			t.Errorf("DB.Close failed; %v", err)
+		}
+	}
+	if _, err := Open("unknown://foo"); err == nil {
+		t.Errorf("badwolf.Open(unknown://foo) should have failed for an unregistered driver")
+	}
+	if _, err := Open("memory://codec=unknown"); err == nil {
+		t.Errorf("badwolf.Open(memory://codec=unknown) should have failed for an unregistered codec")
+	}
+}
+
+func TestOpenCodec(t *testing.T) {
+	db, err := Open("memory://codec=gzip")
+	if err != nil {
+		t.Fatalf("badwolf.Open(memory://codec=gzip) failed; %v", err)
+	}
+	c, err := bio.StoreCodec(db.Store())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Name(), "gzip"; got != want {
+		t.Errorf("badwolf.Open(memory://codec=gzip) returned a store with the wrong codec; got %q, want %q", got, want)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var got string
+	d := func(opts string) (storage.Store, error) {
+		got = opts
+		return memory.NewStore(), nil
+	}
+	if err := Register("testregister", d); err != nil {
+		t.Fatalf("badwolf.Register failed; %v", err)
+	}
+	if err := Register("testregister", d); err == nil {
+		t.Errorf("badwolf.Register should have rejected a duplicated driver name")
+	}
+	if _, err := Open("testregister://some/path"); err != nil {
+		t.Fatalf("badwolf.Open failed; %v", err)
+	}
+	if want := "some/path"; got != want {
+		t.Errorf("badwolf.Open passed options %q to the driver; want %q", got, want)
+	}
+	found := false
+	for _, n := range Drivers() {
+		found = found || n == "testregister"
+	}
+	if !found {
+		t.Errorf("badwolf.Drivers() = %v; missing testregister", Drivers())
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	joe, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(ctx, `select ?o, ?age from ?people where {$1 "knows"@[] ?o . ?o "age"@[] ?age};`, joe)
+	if err != nil {
+		t.Fatalf("DB.Query failed; %v", err)
+	}
+	defer rows.Close()
+	if got, want := rows.Columns(), []string{"?o", "?age"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rows.Columns() = %v; want %v", got, want)
+	}
+	var (
+		n   int
+		o   *node.Node
+		age int64
+	)
+	for rows.Next() {
+		if err := rows.Scan(&o, &age); err != nil {
+			t.Fatalf("Rows.Scan failed; %v", err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Rows.Err() = %v", err)
+	}
+	if n != 1 || o.String() != "/u<mary>" || age != 37 {
+		t.Errorf("DB.Query returned %d rows, last (%v, %d); want 1 row (/u<mary>, 37)", n, o, age)
+	}
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	rows, err := db.Query(ctx, `select ?s, ?name from ?people where {?s "name"@[] ?name};`)
+	if err != nil {
+		t.Fatalf("DB.Query failed; %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("DB.Query returned no rows; %v", rows.Err())
+	}
+	var s, name string
+	if err := rows.Scan(&s, &name); err != nil {
+		t.Fatalf("Rows.Scan failed; %v", err)
+	}
+	if s != "/u<mary>" || name != "Mary" {
+		t.Errorf("Rows.Scan returned (%q, %q); want (/u<mary>, Mary)", s, name)
+	}
+	var raw interface{}
+	if err := rows.Scan(&raw, &raw); err != nil || raw != "Mary" {
+		t.Errorf("Rows.Scan returned %v, %v; want Mary", raw, err)
+	}
+	var n int64
+	if err := rows.Scan(&s, &n); err == nil {
+		t.Errorf("Rows.Scan should have failed scanning text into an int64")
+	}
+	if err := rows.Scan(&s); err == nil {
+		t.Errorf("Rows.Scan should have failed given fewer destinations than columns")
+	}
+}
+
+func TestQueryArguments(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	table := []struct {
+		q    string
+		args []interface{}
+		want int
+	}{
+		{`select ?s from ?people where {?s "age"@[] $1};`, []interface{}{42}, 1},
+		{`select ?s from ?people where {?s "age"@[] $1};`, []interface{}{int64(37)}, 1},
+		{`select ?s from ?people where {?s "age"@[] $1};`, []interface{}{int64(7)}, 0},
+		{`select ?s from ?people where {?s "name"@[] $1};`, []interface{}{"Mary"}, 1},
+		{`select ?s from ?people where {?s $1 ?o};`, []interface{}{agePredicate(t)}, 2},
+		// Quotes on predicate IDs are escaped, so they are bound as a whole.
+		{`select ?s from ?people where {?s $1 ?o};`, []interface{}{immutablePredicate(t, `age"@[] ?o . ?s "name`)}, 0},
+	}
+	for _, entry := range table {
+		rows, err := db.Query(ctx, entry.q, entry.args...)
+		if err != nil {
+			t.Errorf("DB.Query(%q, %v) failed; %v", entry.q, entry.args, err)
+			continue
+		}
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			t.Errorf("DB.Query(%q, %v) failed iterating; %v", entry.q, entry.args, err)
+		}
+		rows.Close()
+		if n != entry.want {
+			t.Errorf("DB.Query(%q, %v) returned %d rows; want %d", entry.q, entry.args, n, entry.want)
+		}
+	}
+}
+
+func TestQueryArgumentsShareStatements(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	q := `select ?a from ?people where {$1 "age"@[] ?a};`
+	for _, entry := range []struct {
+		s    string
+		want string
+	}{
+		{"joe", `"42"^^type:int64`},
+		{"mary", `"37"^^type:int64`},
+	} {
+		n, err := node.NewNodeFromStrings("/u", entry.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := db.Query(ctx, q, n)
+		if err != nil {
+			t.Fatalf("DB.Query(%q, %v) failed; %v", q, n, err)
+		}
+		if !rows.Next() {
+			t.Fatalf("DB.Query(%q, %v) returned no rows; %v", q, n, rows.Err())
+		}
+		if got := rows.Row()["?a"].String(); got != entry.want {
+			t.Errorf("DB.Query(%q, %v) returned %s; want %s", q, n, got, entry.want)
+		}
+		rows.Close()
+	}
+	if got, want := db.cache.Len(), 1; got != want {
+		t.Errorf("DB.Query should have shared the statement for queries only differing in their arguments; got %d cached statements, want %d", got, want)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	table := []struct {
+		q    string
+		args []interface{}
+	}{
+		{`select ?s from ?people where {?s "age"@[] $2};`, []interface{}{1}},
+		{`select ?s from ?people where {?s "age"@[] ?o};`, []interface{}{1}},
+		{`select ?s from ?people where {?s "name"@[] $1};`, []interface{}{`"quoted"`}},
+		{`select ?s from ?people where {?s "name"@[] $1};`, []interface{}{textLiteral(t, `Mary"^^type:text . ?s ?p ?o . ?s "name"@[] "Mary`)}},
+		{`select ?s from ?people where {?s "name"@[] $1};`, []interface{}{struct{}{}}},
+		{`create graph ?other;`, nil},
+		{`select ?s from where;`, nil},
+	}
+	for _, entry := range table {
+		if rows, err := db.Query(ctx, entry.q, entry.args...); err == nil {
+			rows.Close()
+			t.Errorf("DB.Query(%q, %v) should have failed", entry.q, entry.args)
+		}
+	}
+}
+
+func TestBindArgsSkipsQuotedText(t *testing.T) {
+	got, err := bindArgs(`select ?s from ?g where {?s "$1"@[] $1};`, []interface{}{"a"})
+	if err != nil {
+		t.Fatalf("bindArgs failed; %v", err)
+	}
+	if want := `select ?s from ?g where {?s "$1"@[] "a"^^type:text};`; got != want {
+		t.Errorf("bindArgs returned %q; want %q", got, want)
+	}
+}
+
+func TestExec(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	if err := db.Exec(ctx, `delete data from ?people {/u<joe> "age"@[] $1};`, 42); err != nil {
+		t.Fatalf("DB.Exec failed; %v", err)
+	}
+	rows, err := db.Query(ctx, `select ?a from ?people where {/u<joe> "age"@[] ?a};`)
+	if err != nil {
+		t.Fatalf("DB.Query failed; %v", err)
+	}
+	defer rows.Close()
+	if rows.Next() {
+		t.Errorf("DB.Exec should have deleted the age of /u<joe>; got %v", rows.Row())
+	}
+	if err := db.Exec(ctx, `drop graph ?missing;`); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("DB.Exec should have failed dropping a missing graph; got %v", err)
+	}
+}
+
+func TestAuditLogger(t *testing.T) {
+	ctx := audit.WithCaller(context.Background(), "joe")
+	db := testDB(ctx, t)
+	var es []*audit.Entry
+	db.SetAuditLogger(audit.LoggerFunc(func(e *audit.Entry) {
+		es = append(es, e)
+	}))
+	rows, err := db.Query(ctx, `select ?s from ?people where {?s "age"@[] ?a};`)
+	if err != nil {
+		t.Fatalf("DB.Query failed; %v", err)
+	}
+	for rows.Next() {
+	}
+	if len(es) != 0 {
+		t.Errorf("DB.Query should only log queries once their rows are closed")
+	}
+	rows.Close()
+	rows.Close()
+	if err := db.Exec(ctx, `create graph ?other;`); err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 2 {
+		t.Fatalf("DB logged %d entries; want 2", len(es))
+	}
+	if e := es[0]; e.Caller != "joe" || e.Rows != 2 || e.Type != semantic.Query {
+		t.Errorf("DB logged %+v for the query; want 2 rows from joe", e)
+	}
+	if e := es[1]; e.Type != semantic.Create || !reflect.DeepEqual(e.WrittenGraphs, []string{"?other"}) {
+		t.Errorf("DB logged %+v for the graph creation", e)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	db.SetPolicy(&bql.Policy{
+		Roles: map[string]*bql.Role{
+			"reader": {Statements: []semantic.StatementType{semantic.Query}, Read: []string{"?people"}},
+		},
+	})
+	ctx = bql.WithRoles(ctx, "reader")
+	rows, err := db.Query(ctx, `select ?s from ?people where {?s ?p ?o};`)
+	if err != nil {
+		t.Fatalf("DB.Query should have been authorized; %v", err)
+	}
+	rows.Close()
+	err = db.Exec(ctx, `drop graph ?people;`)
+	if ae, ok := err.(*bql.AuthorizationError); !ok || ae.Rule() != "statement DROP" {
+		t.Errorf("DB.Exec returned %v; want a denial of DROP statements", err)
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	a, err := planner.NewAdmission(planner.AdmissionOptions{MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetAdmission(a)
+	q := `select ?s from ?people where {?s ?p ?o};`
+	rows, err := db.Query(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Query(ctx, q); err == nil {
+		t.Errorf("DB.Query should not have been admitted while the rows of another query are open")
+	}
+	rows.Close()
+	rows, err = db.Query(ctx, q)
+	if err != nil {
+		t.Fatalf("DB.Query should have been admitted once the other rows were closed; %v", err)
+	}
+	rows.Close()
+}
+
+func TestSettingsWhileRunning(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			db.SetLimits(planner.Limits{MaxRows: int64(10 + i)})
+			db.SetAuditLogger(audit.LoggerFunc(func(*audit.Entry) {}))
+			db.SetPolicy(nil)
+			db.SetAdmission(nil)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		rows, err := db.Query(ctx, `select ?s from ?people where {?s ?p ?o};`)
+		if err != nil {
+			t.Fatalf("DB.Query failed while changing the settings; %v", err)
+		}
+		rows.Close()
+	}
+	<-done
+}
+
+// agePredicate returns the predicate used to bind ages.
+func textLiteral(t *testing.T, v string) *literal.Literal {
+	l, err := literal.DefaultBuilder().Build(literal.Text, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func agePredicate(t *testing.T) *predicate.Predicate {
+	p, err := predicate.Parse(`"age"@[]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func immutablePredicate(t *testing.T, id string) *predicate.Predicate {
+	p, err := predicate.NewImmutable(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
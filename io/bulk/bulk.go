@@ -0,0 +1,518 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bulk loads large files of triples into graphs, and exports graphs
+// into files. Lines are parsed in parallel by several workers, while the
+// parsed triples are added to the graph in batches following the order of the
+// file. Loads can be resumed after a failure using checkpoints.
+package bulk
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+const (
+	// defaultBatchSize contains the number of lines per batch if none is
+	// provided.
+	defaultBatchSize = 1000
+
+	// maxLineBytes contains the maximum length of a line.
+	maxLineBytes = 16 << 20
+)
+
+// Format identifies the format of the loaded files.
+type Format int8
+
+const (
+	// Text files contain a triple per line using the standard serialized
+	// format, as read by triple.Reader.
+	Text Format = iota
+	// NTriples files contain N-Triples statements, as parsed by
+	// triple.ParseNTriple. Comment lines starting with # are ignored.
+	NTriples
+	// CSV files contain a triple per line, with the subject, predicate, and
+	// object in their standard serialized format on separate fields. Records
+	// may not span several lines. A subject,predicate,object header is
+	// ignored.
+	CSV
+	// JSON files contain a JSON object per line, with the subject, predicate,
+	// and object in their standard serialized format on the s, p, and o
+	// fields.
+	JSON
+	// Binary files contain a record per triple, holding the length of its
+	// binary encoding as an unsigned varint followed by the encoding itself.
+	// Records are numbered as lines.
+	Binary
+)
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case Text:
+		return "text"
+	case NTriples:
+		return "ntriples"
+	case CSV:
+		return "csv"
+	case JSON:
+		return "json"
+	case Binary:
+		return "binary"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseFormat returns the format with the provided name.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range []Format{Text, NTriples, CSV, JSON, Binary} {
+		if strings.EqualFold(s, f.String()) {
+			return f, nil
+		}
+	}
+	return Text, fmt.Errorf("bulk.ParseFormat: unknown format %q; use text, ntriples, csv, json, or binary", s)
+}
+
+// FileFormat returns the format inferred from the extension of the provided
+// file name: .nt files are N-Triples, .csv files are CSV, .json and .jsonl
+// files are JSON, .bin files are binary, and other files are text.
+func FileFormat(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".nt":
+		return NTriples
+	case ".csv":
+		return CSV
+	case ".json", ".jsonl":
+		return JSON
+	case ".bin":
+		return Binary
+	}
+	return Text
+}
+
+// Options configures a load.
+type Options struct {
+	// Format contains the format of the loaded or exported file.
+	Format Format
+
+	// Workers contains the number of goroutines parsing lines. It defaults to
+	// the number of CPUs.
+	Workers int
+
+	// BatchSize contains the number of lines parsed and added to the graph at
+	// once. It defaults to 1000.
+	BatchSize int
+
+	// MaxErrors contains the number of lines that may fail to parse before the
+	// load fails. Lines that fail to parse are skipped and reported on the
+	// stats. Zero fails on the first error.
+	MaxErrors int
+
+	// Mapping maps the IRIs of N-Triples files to nodes and predicates, and
+	// back. A nil mapping uses the default values.
+	Mapping *triple.NTriplesMapping
+
+	// Builder builds the literals. It defaults to literal.DefaultBuilder.
+	Builder literal.Builder
+
+	// Checkpoint contains the path of the checkpoint file, if any. It records
+	// the number of lines whose triples were added to the graph after each
+	// batch. A load that finds it resumes after those lines, and removes it
+	// once it succeeds. The blank nodes of N-Triples files are only mapped
+	// consistently within each run.
+	Checkpoint string
+
+	// Progress, if provided, is called with the stats of the load after each
+	// batch is added to the graph.
+	Progress func(*Stats)
+}
+
+// Stats describes the progress of a load.
+type Stats struct {
+	// Lines contains the number of lines processed, including the lines
+	// skipped on resumption.
+	Lines int
+
+	// Resumed contains the number of lines skipped because the checkpoint
+	// recorded them as loaded.
+	Resumed int
+
+	// Triples contains the number of triples added to the graph.
+	Triples int
+
+	// Errors contains the lines that failed to parse and were skipped.
+	Errors []*triple.ParseError
+
+	// Elapsed contains the time spent loading.
+	Elapsed time.Duration
+}
+
+// Throughput returns the number of triples added per second.
+func (s *Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Triples) / s.Elapsed.Seconds()
+}
+
+// batch contains a sequence of lines of the file and their parsed triples.
+type batch struct {
+	seq   int
+	first int
+	lines []string
+	ts    []*triple.Triple
+	errs  []*triple.ParseError
+}
+
+// Load reads the file on the provided reader into the graph, and returns the
+// stats of the load. On failure, the triples of the batches before the one
+// that failed would have also been added to the graph, and recorded on the
+// checkpoint if any. Lines that fail to parse beyond the ones allowed by the
+// options fail the load with a *triple.ParseError.
+func Load(ctx context.Context, g storage.Graph, r io.Reader, opts Options) (*Stats, error) {
+	if opts.Workers < 0 || opts.BatchSize < 0 || opts.MaxErrors < 0 {
+		return nil, fmt.Errorf("bulk.Load: invalid negative options %+v", opts)
+	}
+	if opts.Workers == 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.Builder == nil {
+		opts.Builder = literal.DefaultBuilder()
+	}
+	if opts.Format == NTriples && opts.Mapping == nil {
+		opts.Mapping = &triple.NTriplesMapping{}
+	}
+	if opts.Format == NTriples && opts.Mapping.BlankNodes == nil {
+		m := *opts.Mapping
+		m.BlankNodes = triple.NewBlankNodeScope()
+		opts.Mapping = &m
+	}
+	start := time.Now()
+	stats := &Stats{}
+	if opts.Checkpoint != "" {
+		n, err := readCheckpoint(opts.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		stats.Lines, stats.Resumed = n, n
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Each batch holds a token until added to the graph, which bounds the
+	// number of batches in memory no matter how fast lines are parsed.
+	tokens := make(chan struct{}, 2*opts.Workers)
+	pending := make(chan *batch)
+	parsed := make(chan *batch)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(pending)
+		readErr <- readBatches(ctx, r, opts.Format, stats.Resumed, opts.BatchSize, tokens, pending)
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range pending {
+				parse(b, opts)
+				select {
+				case parsed <- b:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(parsed)
+	}()
+
+	err := commit(ctx, g, parsed, tokens, stats, start, opts)
+	cancel()
+	for range parsed {
+	}
+	stats.Elapsed = time.Since(start)
+	if err != nil {
+		return stats, err
+	}
+	if err := <-readErr; err != nil {
+		return stats, fmt.Errorf("bulk.Load: failed to read line %d with error %v", stats.Lines+1, err)
+	}
+	if opts.Checkpoint != "" {
+		if err := os.Remove(opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// readBatches splits the lines after the skipped ones into batches, and sends
+// them to be parsed once a token is available for them.
+func readBatches(ctx context.Context, r io.Reader, f Format, skip, size int, tokens chan<- struct{}, pending chan<- *batch) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxLineBytes)
+	if f == Binary {
+		s.Split(splitRecords)
+	}
+	line, seq := 0, 0
+	b := &batch{first: skip + 1}
+	send := func() bool {
+		select {
+		case tokens <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+		select {
+		case pending <- b:
+		case <-ctx.Done():
+			return false
+		}
+		seq++
+		b = &batch{seq: seq, first: line + 1}
+		return true
+	}
+	for s.Scan() {
+		if line++; line <= skip {
+			continue
+		}
+		b.lines = append(b.lines, s.Text())
+		if len(b.lines) == size && !send() {
+			return nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if len(b.lines) > 0 {
+		send()
+	}
+	return nil
+}
+
+// parse parses the lines of the batch.
+func parse(b *batch, opts Options) {
+	if opts.Format == Text {
+		tr := triple.NewReader(strings.NewReader(strings.Join(b.lines, "\n")), opts.Builder)
+		tr.SkipErrors(true)
+		for {
+			t, err := tr.Read()
+			if err != nil {
+				break
+			}
+			b.ts = append(b.ts, t)
+		}
+		for _, perr := range tr.Errors() {
+			perr.Line += b.first - 1
+			b.errs = append(b.errs, perr)
+		}
+		return
+	}
+	for i, l := range b.lines {
+		if opts.Format != Binary && strings.TrimSpace(l) == "" {
+			continue
+		}
+		var (
+			t   *triple.Triple
+			err error
+		)
+		switch opts.Format {
+		case NTriples:
+			if strings.HasPrefix(strings.TrimSpace(l), "#") {
+				continue
+			}
+			t, err = triple.ParseNTriple(l, opts.Mapping, opts.Builder)
+		case CSV:
+			if b.first+i == 1 && isCSVHeader(l) {
+				continue
+			}
+			t, err = parseCSV(l, opts.Builder)
+		case JSON:
+			t, err = parseJSON(l, opts.Builder)
+		case Binary:
+			t = &triple.Triple{}
+			if err = t.UnmarshalBinary([]byte(l)); err != nil {
+				// Binary records are not worth reporting as text.
+				l = ""
+			}
+		}
+		if err != nil {
+			b.errs = append(b.errs, &triple.ParseError{Line: b.first + i, Column: 1, Text: l, Err: err})
+			continue
+		}
+		b.ts = append(b.ts, t)
+	}
+}
+
+// isCSVHeader returns true if the line is the header of a CSV file.
+func isCSVHeader(l string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(l), ""), "subject,predicate,object")
+}
+
+// parseCSV parses a CSV record into a triple.
+func parseCSV(l string, b literal.Builder) (*triple.Triple, error) {
+	rec, err := csv.NewReader(strings.NewReader(l)).Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(rec) != 3 {
+		return nil, fmt.Errorf("expected 3 fields, found %d", len(rec))
+	}
+	return parseFields(rec[0], rec[1], rec[2], b)
+}
+
+// jsonTriple is the JSON encoding of a triple.
+type jsonTriple struct {
+	S string `json:"s"`
+	P string `json:"p"`
+	O string `json:"o"`
+}
+
+// parseJSON parses a JSON object into a triple.
+func parseJSON(l string, b literal.Builder) (*triple.Triple, error) {
+	var jt jsonTriple
+	if err := json.Unmarshal([]byte(l), &jt); err != nil {
+		return nil, err
+	}
+	return parseFields(jt.S, jt.P, jt.O, b)
+}
+
+// parseFields parses the serialized subject, predicate, and object of a
+// triple.
+func parseFields(sf, pf, of string, b literal.Builder) (*triple.Triple, error) {
+	s, err := node.Parse(strings.TrimSpace(sf))
+	if err != nil {
+		return nil, err
+	}
+	p, err := predicate.Parse(strings.TrimSpace(pf))
+	if err != nil {
+		return nil, err
+	}
+	o, err := triple.ParseObject(strings.TrimSpace(of), b)
+	if err != nil {
+		return nil, err
+	}
+	return triple.New(s, p, o)
+}
+
+// splitRecords is a bufio.SplitFunc that splits the records of binary files.
+func splitRecords(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+	l, k := binary.Uvarint(data)
+	switch {
+	case k < 0 || l > maxLineBytes:
+		return 0, nil, errors.New("invalid binary record length")
+	case k == 0 || uint64(len(data)-k) < l:
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	end := k + int(l)
+	return end, data[k:end], nil
+}
+
+// commit adds the triples of the parsed batches to the graph following the
+// order of the file, updating the stats and the checkpoint after each batch.
+func commit(ctx context.Context, g storage.Graph, parsed <-chan *batch, tokens <-chan struct{}, stats *Stats, start time.Time, opts Options) error {
+	waiting := make(map[int]*batch)
+	next := 0
+	for b := range parsed {
+		waiting[b.seq] = b
+		for b, ok := waiting[next]; ok; b, ok = waiting[next] {
+			delete(waiting, next)
+			next++
+			for _, perr := range b.errs {
+				if len(stats.Errors) == opts.MaxErrors {
+					return perr
+				}
+				stats.Errors = append(stats.Errors, perr)
+			}
+			if len(b.ts) > 0 {
+				if err := g.AddTriples(b.ts); err != nil {
+					return err
+				}
+			}
+			stats.Lines += len(b.lines)
+			stats.Triples += len(b.ts)
+			stats.Elapsed = time.Since(start)
+			if opts.Checkpoint != "" {
+				if err := writeCheckpoint(opts.Checkpoint, stats.Lines); err != nil {
+					return err
+				}
+			}
+			if opts.Progress != nil {
+				opts.Progress(stats)
+			}
+			<-tokens
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// readCheckpoint returns the number of lines recorded on the checkpoint, or
+// zero if there is none.
+func readCheckpoint(path string) (int, error) {
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(bs)))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bulk.Load: invalid checkpoint %q in %s", bs, path)
+	}
+	return n, nil
+}
+
+// writeCheckpoint records the number of loaded lines on the checkpoint. The
+// checkpoint is replaced atomically, so it is never left half written.
+func writeCheckpoint(path string, lines int) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(lines)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
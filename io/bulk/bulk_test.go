@@ -0,0 +1,187 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+)
+
+// newGraph returns a new empty graph.
+func newGraph(t *testing.T) storage.Graph {
+	t.Helper()
+	g, err := memory.NewStore().NewGraph("?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// count returns the number of triples in the graph.
+func count(t *testing.T, g storage.Graph) int {
+	t.Helper()
+	ts, err := g.Triples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for range ts {
+		n++
+	}
+	return n
+}
+
+func TestLoad(t *testing.T) {
+	table := []struct {
+		format    Format
+		in        string
+		maxErrors int
+		triples   int
+		errLines  []int
+	}{
+		{
+			format: Text,
+			in: `/u<joe> "knows"@[] /u<mary>
+
+/u<joe> "knows"@[] /u<peter>
+/u<mary> "age"@[] "31"^^type:int64`,
+			triples: 3,
+		},
+		{
+			format:    Text,
+			in:        "/u<joe> \"knows\"@[] /u<mary>\nnot a triple\n/u<joe> \"knows\"@[] /u<peter>\nbroken",
+			maxErrors: 5,
+			triples:   2,
+			errLines:  []int{2, 4},
+		},
+		{
+			format: NTriples,
+			in: `# People.
+<http://example.org/joe> <http://example.org/knows> <http://example.org/mary> .
+_:a <http://example.org/knows> _:b .
+<http://example.org/joe> <http://example.org/name> "Joe" .`,
+			triples: 3,
+		},
+		{
+			format: CSV,
+			in: `subject, predicate, object
+/u<joe>,"""knows""@[]",/u<mary>
+/u<joe>,"""age""@[]","""31""^^type:int64"
+/u<joe>,/u<mary>`,
+			maxErrors: 1,
+			triples:   2,
+			errLines:  []int{4},
+		},
+	}
+	for _, entry := range table {
+		for _, workers := range []int{1, 4} {
+			g := newGraph(t)
+			stats, err := Load(context.Background(), g, strings.NewReader(entry.in), Options{
+				Format:    entry.format,
+				Workers:   workers,
+				BatchSize: 1,
+				MaxErrors: entry.maxErrors,
+			})
+			if err != nil {
+				t.Errorf("bulk.Load(%s, %d workers) failed with error %v", entry.format, workers, err)
+				continue
+			}
+			if got, want := stats.Triples, entry.triples; got != want || count(t, g) != want {
+				t.Errorf("bulk.Load(%s, %d workers) added %d triples, want %d", entry.format, workers, got, want)
+			}
+			if got, want := stats.Lines, strings.Count(entry.in, "\n")+1; got != want {
+				t.Errorf("bulk.Load(%s, %d workers) processed %d lines, want %d", entry.format, workers, got, want)
+			}
+			var lines []int
+			for _, perr := range stats.Errors {
+				lines = append(lines, perr.Line)
+			}
+			if len(lines) != len(entry.errLines) || len(lines) > 0 && (lines[0] != entry.errLines[0] || lines[len(lines)-1] != entry.errLines[len(entry.errLines)-1]) {
+				t.Errorf("bulk.Load(%s, %d workers) reported errors on lines %v, want %v", entry.format, workers, lines, entry.errLines)
+			}
+		}
+	}
+}
+
+func TestLoadFailsOnErrors(t *testing.T) {
+	in := "/u<joe> \"knows\"@[] /u<mary>\n/u<joe> \"knows\"@[] /u<peter>\nbroken\n/u<mary> \"knows\"@[] /u<peter>"
+	g := newGraph(t)
+	stats, err := Load(context.Background(), g, strings.NewReader(in), Options{BatchSize: 1, Workers: 3})
+	var perr *triple.ParseError
+	if !errors.As(err, &perr) || perr.Line != 3 {
+		t.Fatalf("bulk.Load should have failed with a parse error on line 3; got %v", err)
+	}
+	if got, want := stats.Triples, 2; got != want || count(t, g) != want {
+		t.Errorf("bulk.Load added %d triples before failing, want %d", got, want)
+	}
+}
+
+func TestLoadResumesFromCheckpoint(t *testing.T) {
+	cp := filepath.Join(t.TempDir(), "load.checkpoint")
+	lines := []string{
+		`/u<joe> "knows"@[] /u<mary>`,
+		`/u<joe> "knows"@[] /u<peter>`,
+		`broken`,
+		`/u<mary> "knows"@[] /u<peter>`,
+	}
+	g := newGraph(t)
+	opts := Options{BatchSize: 1, Workers: 2, Checkpoint: cp}
+	if _, err := Load(context.Background(), g, strings.NewReader(strings.Join(lines, "\n")), opts); err == nil {
+		t.Fatalf("bulk.Load should have failed to parse line 3")
+	}
+	if n, err := readCheckpoint(cp); err != nil || n != 2 {
+		t.Fatalf("bulk.Load checkpointed %d lines with error %v, want 2", n, err)
+	}
+
+	lines[2] = `/u<peter> "knows"@[] /u<joe>`
+	stats, err := Load(context.Background(), g, strings.NewReader(strings.Join(lines, "\n")), opts)
+	if err != nil {
+		t.Fatalf("bulk.Load failed to resume with error %v", err)
+	}
+	if stats.Resumed != 2 || stats.Triples != 2 || stats.Lines != 4 || count(t, g) != 4 {
+		t.Errorf("bulk.Load resumed with stats %+v and %d triples; want 2 resumed lines and 4 triples", stats, count(t, g))
+	}
+	if _, err := os.Stat(cp); !os.IsNotExist(err) {
+		t.Errorf("bulk.Load should have removed the checkpoint once done; %v", err)
+	}
+}
+
+func TestLoadCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in := strings.Repeat("/u<joe> \"knows\"@[] /u<mary>\n", 100)
+	if _, err := Load(ctx, newGraph(t), strings.NewReader(in), Options{BatchSize: 1}); err == nil {
+		t.Errorf("bulk.Load should have failed given a canceled context")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{Text, NTriples, CSV, JSON, Binary} {
+		if got, err := ParseFormat(f.String()); err != nil || got != f {
+			t.Errorf("bulk.ParseFormat(%q) returned %v, %v; want %v", f, got, err, f)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Errorf("bulk.ParseFormat should have rejected an unknown format")
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulk
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+)
+
+// Export writes the provided triples to the writer using the format of the
+// options, and returns the number of triples written. Triples are written as
+// they are received, so the producer is only ahead of the writer by the
+// buffer of the channel. On failure, the remaining triples are drained in the
+// background so the producer can finish. Only the Format and Mapping options
+// are used.
+func Export(ctx context.Context, w io.Writer, ts storage.Triples, opts Options) (cnt int, err error) {
+	defer func() {
+		if err != nil {
+			go func() {
+				for range ts {
+				}
+			}()
+		}
+	}()
+	bw := bufio.NewWriter(w)
+	write, err := tripleWriter(bw, opts)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		var (
+			t  *triple.Triple
+			ok bool
+		)
+		select {
+		case t, ok = <-ts:
+		case <-ctx.Done():
+			return cnt, ctx.Err()
+		}
+		if !ok {
+			break
+		}
+		if err := write(t); err != nil {
+			return cnt, fmt.Errorf("bulk.Export failed to write triple %d: %v", cnt+1, err)
+		}
+		cnt++
+	}
+	if opts.Format == CSV {
+		// The CSV writer buffers the records on its own writer.
+		if err := write(nil); err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, bw.Flush()
+}
+
+// tripleWriter returns a function that writes a triple using the format of the
+// options. CSV writers flush their buffered records when given a nil triple.
+func tripleWriter(w io.Writer, opts Options) (func(*triple.Triple) error, error) {
+	switch opts.Format {
+	case Text:
+		return func(t *triple.Triple) error {
+			_, err := fmt.Fprintln(w, t)
+			return err
+		}, nil
+	case NTriples:
+		nw := triple.NewNTriplesWriter(w, opts.Mapping)
+		return nw.Write, nil
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"subject", "predicate", "object"}); err != nil {
+			return nil, err
+		}
+		return func(t *triple.Triple) error {
+			if t == nil {
+				cw.Flush()
+				return cw.Error()
+			}
+			return cw.Write([]string{t.S().String(), t.P().String(), t.O().String()})
+		}, nil
+	case JSON:
+		enc := json.NewEncoder(w)
+		// Nodes, such as /u<joe>, are easier to read unescaped.
+		enc.SetEscapeHTML(false)
+		return func(t *triple.Triple) error {
+			return enc.Encode(&jsonTriple{S: t.S().String(), P: t.P().String(), O: t.O().String()})
+		}, nil
+	case Binary:
+		return func(t *triple.Triple) error {
+			b, err := t.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(appendUvarint(nil, uint64(len(b))), b...))
+			return err
+		}, nil
+	}
+	return nil, fmt.Errorf("bulk.Export: unknown format %v", opts.Format)
+}
+
+// appendUvarint appends the unsigned varint encoding of the provided value.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	const data = `/u<joe> "knows"@[] /u<mary>
+/u<joe> "name"@[] "Joe, \"the\" first"^^type:text
+/u<mary> "age"@[] "31"^^type:int64
+/u<mary> "met"@[2016-01-01T00:00:00Z] /u<peter>
+/u<peter> "says"@[] <</u<joe> "knows"@[] /u<mary>>>`
+	src := newGraph(t)
+	if _, err := bio.ReadIntoGraph(src, strings.NewReader(data), literal.DefaultBuilder()); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []Format{Text, CSV, JSON, Binary} {
+		ts, err := src.Triples()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		n, err := Export(context.Background(), &buf, ts, Options{Format: f})
+		if err != nil || n != 5 {
+			t.Errorf("bulk.Export(%s) returned %d, %v; want 5 triples", f, n, err)
+			continue
+		}
+		dst := newGraph(t)
+		if _, err := Load(context.Background(), dst, &buf, Options{Format: f, BatchSize: 2}); err != nil {
+			t.Errorf("bulk.Load(%s) failed to load the exported triples with error %v", f, err)
+			continue
+		}
+		d, err := bio.DiffGraphs(src, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Equal() {
+			t.Errorf("bulk.Export(%s) did not round trip; diff %+v", f, d)
+		}
+	}
+}
+
+func TestExportNTriples(t *testing.T) {
+	src := newGraph(t)
+	if _, err := bio.ReadIntoGraph(src, strings.NewReader(`/u<joe> "knows"@[] /u<mary>`), literal.DefaultBuilder()); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := src.Triples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	m := &triple.NTriplesMapping{NodeTypes: map[string]string{"http://example.org/u/": "/u"}, PredicatePrefix: "http://example.org/"}
+	if _, err := Export(context.Background(), &buf, ts, Options{Format: NTriples, Mapping: m}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "<http://example.org/u/joe> <http://example.org/knows> <http://example.org/u/mary> .\n"; got != want {
+		t.Errorf("bulk.Export(ntriples) wrote %q, want %q", got, want)
+	}
+
+	// Temporal predicates have no N-Triples counterpart.
+	if _, err := bio.ReadIntoGraph(src, strings.NewReader(`/u<joe> "met"@[2016-01-01T00:00:00Z] /u<mary>`), literal.DefaultBuilder()); err != nil {
+		t.Fatal(err)
+	}
+	if ts, err = src.Triples(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Export(context.Background(), &bytes.Buffer{}, ts, Options{Format: NTriples, Mapping: m}); err == nil {
+		t.Errorf("bulk.Export(ntriples) should have failed to write a temporal predicate")
+	}
+}
+
+func TestExportCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Export(ctx, &bytes.Buffer{}, make(chan *triple.Triple), Options{}); err == nil {
+		t.Errorf("bulk.Export should have failed given a canceled context")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package io
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// Codec provides the mechanics to transparently encode and decode the
+// serialized representation of a graph. Codecs are typically used to compress
+// graph snapshots.
+type Codec interface {
+	// Name returns the name the codec is registered under.
+	Name() string
+
+	// NewWriter wraps the provided writer. The returned writer must be closed
+	// to flush all the encoded data.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader wraps the provided reader to decode its contents.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// noneCodec implements the identity codec.
+type noneCodec struct{}
+
+// nopWriteCloser adds a no-op Close method to a writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// Name returns the name of the codec.
+func (noneCodec) Name() string {
+	return "none"
+}
+
+// NewWriter returns the provided writer unchanged.
+func (noneCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+// NewReader returns the provided reader unchanged.
+func (noneCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+// gzipCodec implements a gzip compression codec.
+type gzipCodec struct {
+	level int
+}
+
+// Name returns the name of the codec.
+func (c *gzipCodec) Name() string {
+	return "gzip"
+}
+
+// NewWriter returns a gzip compressing writer.
+func (c *gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+// NewReader returns a gzip decompressing reader.
+func (c *gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// NoneCodec returns the codec that leaves the data untouched.
+func NoneCodec() Codec {
+	return noneCodec{}
+}
+
+// NewGzipCodec returns a gzip codec that uses the provided compression level.
+// Valid levels are the ones accepted by the compress/gzip package.
+func NewGzipCodec(level int) (Codec, error) {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, fmt.Errorf("io.NewGzipCodec: invalid compression level %d", level)
+	}
+	return &gzipCodec{level: level}, nil
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		"none": noneCodec{},
+		"gzip": &gzipCodec{level: gzip.DefaultCompression},
+	}
+)
+
+// RegisterCodec makes the provided codec available by name. It allows plugging
+// codecs, such as snappy, that depend on third party packages. Registering a
+// codec under an already used name fails.
+func RegisterCodec(c Codec) error {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, ok := codecs[c.Name()]; ok {
+		return fmt.Errorf("io.RegisterCodec: codec %q is already registered", c.Name())
+	}
+	codecs[c.Name()] = c
+	return nil
+}
+
+// GetCodec returns the codec registered under the provided name.
+func GetCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("io.GetCodec: unknown codec %q", name)
+	}
+	return c, nil
+}
+
+// ReadIntoGraphWithCodec works as ReadIntoGraph but decodes the contents of the
+// reader using the provided codec first.
+func ReadIntoGraphWithCodec(g storage.Graph, r io.Reader, b literal.Builder, c Codec) (int, error) {
+	dr, err := c.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	cnt, err := ReadIntoGraph(g, dr, b)
+	if cerr := dr.Close(); err == nil {
+		err = cerr
+	}
+	return cnt, err
+}
+
+// WriteGraphWithCodec works as WriteGraph but encodes the serialized triples
+// using the provided codec.
+func WriteGraphWithCodec(w io.Writer, g storage.Graph, c Codec) (int, error) {
+	ew, err := c.NewWriter(w)
+	if err != nil {
+		return 0, err
+	}
+	cnt, err := WriteGraph(ew, g)
+	if cerr := ew.Close(); err == nil {
+		err = cerr
+	}
+	return cnt, err
+}
+
+// StoreCodec returns the codec configured on the store, or the codec that
+// leaves the data untouched if the store has none.
+func StoreCodec(s storage.Store) (Codec, error) {
+	if c, ok := s.(storage.Configured); ok && c.Options().Codec != "" {
+		return GetCodec(c.Options().Codec)
+	}
+	return NoneCodec(), nil
+}
+
+// DumpGraph writes the triples of the graph of the store encoded using the
+// codec configured on the store. It returns the number of triples written.
+func DumpGraph(w io.Writer, s storage.Store, id string) (int, error) {
+	c, err := StoreCodec(s)
+	if err != nil {
+		return 0, err
+	}
+	g, err := s.Graph(id)
+	if err != nil {
+		return 0, err
+	}
+	return WriteGraphWithCodec(w, g, c)
+}
+
+// RestoreGraph reads the triples written by DumpGraph into the graph of the
+// store, creating the graph if it does not exist. The contents of the reader
+// are decoded using the codec configured on the store. It returns the number
+// of triples read.
+func RestoreGraph(r io.Reader, s storage.Store, id string, b literal.Builder) (int, error) {
+	c, err := StoreCodec(s)
+	if err != nil {
+		return 0, err
+	}
+	g, err := s.Graph(id)
+	if err != nil {
+		if g, err = s.NewGraph(id); err != nil {
+			return 0, err
+		}
+	}
+	return ReadIntoGraphWithCodec(g, r, b, c)
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package io
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	gz, err := NewGzipCodec(gzip.BestCompression)
+	if err != nil {
+		t.Fatalf("io.NewGzipCodec failed with error %v", err)
+	}
+	ts := getTestTriples(t)
+	for _, c := range []Codec{NoneCodec(), gz} {
+		g, err := memory.NewStore().NewGraph("?src")
+		if err != nil {
+			t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+		}
+		if err := g.AddTriples(ts); err != nil {
+			t.Fatalf("storage.AddTriples should have not fail to add triples with error %v", err)
+		}
+		var buffer bytes.Buffer
+		cnt, err := WriteGraphWithCodec(&buffer, g, c)
+		if err != nil || cnt != len(ts) {
+			t.Errorf("io.WriteGraphWithCodec(%q) returned %d, %v; want %d, nil", c.Name(), cnt, err, len(ts))
+		}
+		g2, err := memory.NewStore().NewGraph("?dst")
+		if err != nil {
+			t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+		}
+		cnt, err = ReadIntoGraphWithCodec(g2, &buffer, literal.DefaultBuilder(), c)
+		if err != nil || cnt != len(ts) {
+			t.Errorf("io.ReadIntoGraphWithCodec(%q) returned %d, %v; want %d, nil", c.Name(), cnt, err, len(ts))
+		}
+		d, err := DiffGraphs(g, g2)
+		if err != nil {
+			t.Fatalf("io.DiffGraphs failed with error %v", err)
+		}
+		if !d.Equal() {
+			t.Errorf("codec %q failed to round trip the graph; got diff %+v", c.Name(), d)
+		}
+	}
+}
+
+func TestGzipCodecRejectsInvalidData(t *testing.T) {
+	c, err := GetCodec("gzip")
+	if err != nil {
+		t.Fatalf("io.GetCodec failed to return the gzip codec with error %v", err)
+	}
+	g, err := memory.NewStore().NewGraph("?test")
+	if err != nil {
+		t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+	}
+	if _, err := ReadIntoGraphWithCodec(g, bytes.NewBufferString("not compressed"), literal.DefaultBuilder(), c); err == nil {
+		t.Errorf("io.ReadIntoGraphWithCodec should have failed to decode uncompressed data")
+	}
+	if _, err := NewGzipCodec(42); err == nil {
+		t.Errorf("io.NewGzipCodec should have rejected an invalid compression level")
+	}
+}
+
+type testCodec struct {
+	noneCodec
+}
+
+func (testCodec) Name() string {
+	return "test"
+}
+
+func TestRegisterCodec(t *testing.T) {
+	if _, err := GetCodec("test"); err == nil {
+		t.Errorf("io.GetCodec should have failed for an unregistered codec")
+	}
+	if err := RegisterCodec(testCodec{}); err != nil {
+		t.Errorf("io.RegisterCodec failed with error %v", err)
+	}
+	if err := RegisterCodec(testCodec{}); err == nil {
+		t.Errorf("io.RegisterCodec should have failed to register the same codec twice")
+	}
+	if c, err := GetCodec("test"); err != nil || c.Name() != "test" {
+		t.Errorf("io.GetCodec returned %v, %v; want the test codec", c, err)
+	}
+	for _, n := range []string{"none", "gzip"} {
+		if _, err := GetCodec(n); err != nil {
+			t.Errorf("io.GetCodec failed to return builtin codec %q with error %v", n, err)
+		}
+	}
+}
+
+func TestDumpAndRestoreGraph(t *testing.T) {
+	ts := getTestTriples(t)
+	for _, codec := range []string{"", "none", "gzip"} {
+		s := memory.NewStoreWithOptions(storage.Options{Codec: codec})
+		g, err := s.NewGraph("?src")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.AddTriples(ts); err != nil {
+			t.Fatal(err)
+		}
+		var buffer bytes.Buffer
+		if cnt, err := DumpGraph(&buffer, s, "?src"); err != nil || cnt != len(ts) {
+			t.Fatalf("io.DumpGraph(%q) returned %d, %v; want %d, nil", codec, cnt, err, len(ts))
+		}
+		if codec == "gzip" {
+			if _, err := gzip.NewReader(bytes.NewReader(buffer.Bytes())); err != nil {
+				t.Errorf("io.DumpGraph should have used the gzip codec of the store; %v", err)
+			}
+		}
+		if cnt, err := RestoreGraph(&buffer, s, "?dst", literal.DefaultBuilder()); err != nil || cnt != len(ts) {
+			t.Fatalf("io.RestoreGraph(%q) returned %d, %v; want %d, nil", codec, cnt, err, len(ts))
+		}
+		g2, err := s.Graph("?dst")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, err := DiffGraphs(g, g2); err != nil || !d.Equal() {
+			t.Errorf("codec %q failed to round trip the graph; got diff %+v, %v", codec, d, err)
+		}
+	}
+	s := memory.NewStoreWithOptions(storage.Options{Codec: "unknown"})
+	if _, err := s.NewGraph("?src"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DumpGraph(&bytes.Buffer{}, s, "?src"); err == nil {
+		t.Errorf("io.DumpGraph should have failed for an unknown codec")
+	}
+}
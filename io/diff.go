@@ -0,0 +1,96 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package io
+
+import (
+	"sort"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+)
+
+// Diff contains the differences found between two graphs. Since triples are
+// identified by their canonical GUID, a triple that differs between graphs is
+// reported as missing from one of them and extra on the other.
+type Diff struct {
+	// Missing contains the triples available on the first graph but not on
+	// the second one.
+	Missing []*triple.Triple
+	// Extra contains the triples available on the second graph but not on
+	// the first one.
+	Extra []*triple.Triple
+}
+
+// Equal returns true if no differences were found.
+func (d *Diff) Equal() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0
+}
+
+// DiffGraphs compares the triples stored in the two provided graphs. Only the
+// canonical GUIDs of the first graph are kept in memory while the second graph
+// is streamed; the first graph is then streamed again to retrieve the triples
+// missing from the second one. Both lists of the returned diff are sorted by
+// triple GUID.
+func DiffGraphs(a, b storage.Graph) (*Diff, error) {
+	ats, err := a.Triples()
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]bool)
+	for t := range ats {
+		pending[t.GUID()] = true
+	}
+	bts, err := b.Triples()
+	if err != nil {
+		return nil, err
+	}
+	d := &Diff{}
+	for t := range bts {
+		guid := t.GUID()
+		if pending[guid] {
+			delete(pending, guid)
+			continue
+		}
+		d.Extra = append(d.Extra, t)
+	}
+	if len(pending) > 0 {
+		if ats, err = a.Triples(); err != nil {
+			return nil, err
+		}
+		for t := range ats {
+			if pending[t.GUID()] {
+				d.Missing = append(d.Missing, t)
+			}
+		}
+	}
+	sort.Sort(byGUID(d.Missing))
+	sort.Sort(byGUID(d.Extra))
+	return d, nil
+}
+
+// byGUID allows sorting triples by their GUID.
+type byGUID []*triple.Triple
+
+func (s byGUID) Len() int {
+	return len(s)
+}
+
+func (s byGUID) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byGUID) Less(i, j int) bool {
+	return s[i].GUID() < s[j].GUID()
+}
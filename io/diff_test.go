@@ -0,0 +1,72 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package io
+
+import (
+	"testing"
+
+	"github.com/google/badwolf/storage/memory"
+)
+
+func TestDiffGraphs(t *testing.T) {
+	ts := getTestTriples(t)
+	s := memory.NewStore()
+	a, err := s.NewGraph("?a")
+	if err != nil {
+		t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+	}
+	b, err := s.NewGraph("?b")
+	if err != nil {
+		t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+	}
+	if err := a.AddTriples(ts[:4]); err != nil {
+		t.Fatalf("storage.AddTriples should have not fail to add triples with error %v", err)
+	}
+	if err := b.AddTriples(ts[2:]); err != nil {
+		t.Fatalf("storage.AddTriples should have not fail to add triples with error %v", err)
+	}
+	d, err := DiffGraphs(a, b)
+	if err != nil {
+		t.Fatalf("io.DiffGraphs failed with error %v", err)
+	}
+	if d.Equal() {
+		t.Errorf("io.DiffGraphs should have found differences between %v and %v", ts[:4], ts[2:])
+	}
+	if got, want := len(d.Missing), 2; got != want {
+		t.Errorf("io.DiffGraphs returned the wrong number of missing triples; got %d, want %d", got, want)
+	}
+	if got, want := len(d.Extra), 2; got != want {
+		t.Errorf("io.DiffGraphs returned the wrong number of extra triples; got %d, want %d", got, want)
+	}
+	missing := map[string]bool{ts[0].GUID(): true, ts[1].GUID(): true}
+	for _, trpl := range d.Missing {
+		if !missing[trpl.GUID()] {
+			t.Errorf("io.DiffGraphs reported unexpected missing triple %s", trpl)
+		}
+	}
+	extra := map[string]bool{ts[4].GUID(): true, ts[5].GUID(): true}
+	for _, trpl := range d.Extra {
+		if !extra[trpl.GUID()] {
+			t.Errorf("io.DiffGraphs reported unexpected extra triple %s", trpl)
+		}
+	}
+	d, err = DiffGraphs(a, a)
+	if err != nil {
+		t.Fatalf("io.DiffGraphs failed with error %v", err)
+	}
+	if !d.Equal() {
+		t.Errorf("io.DiffGraphs should have found no differences for the same graph; got %+v", d)
+	}
+}
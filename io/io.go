@@ -16,10 +16,8 @@
 package io
 
 import (
-	"bufio"
 	"fmt"
 	"io"
-	"strings"
 
 	"github.com/google/badwolf/storage"
 	"github.com/google/badwolf/triple"
@@ -29,24 +27,24 @@ import (
 // ReadIntoGraph reads a graph out of the provided reader. The data on the
 // reader is interpret as text. Each line represents one triple using the
 // standard serialized format. ReadIntoGraph will stop if fails to Parse
-// a triple on the stream. The triples read till then would have also been
-// added to the graph. The int value returns the number of triples added
+// a triple on the stream, returning a *triple.ParseError with the position of
+// the failure. The triples read till then would have also been added to the
+// graph. The int value returns the number of triples added
 func ReadIntoGraph(g storage.Graph, r io.Reader, b literal.Builder) (int, error) {
-	cnt, scanner := 0, bufio.NewScanner(r)
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		text := strings.TrimSpace(scanner.Text())
-		if text == "" {
-			continue
+	cnt, tr := 0, triple.NewReader(r, b)
+	for {
+		t, err := tr.Read()
+		if err == io.EOF {
+			return cnt, nil
 		}
-		t, err := triple.ParseTriple(text, b)
 		if err != nil {
 			return cnt, err
 		}
+		if err := g.AddTriples([]*triple.Triple{t}); err != nil {
+			return cnt, err
+		}
 		cnt++
-		g.AddTriples([]*triple.Triple{t})
 	}
-	return cnt, nil
 }
 
 // WriteGraph serializes the graph into the writer where each triple is
@@ -0,0 +1,644 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonld converts between JSON-LD documents and BadWolf triples.
+// Documents are expanded using their inline contexts before extracting their
+// triples, and triples are exported as flattened documents with no context.
+package jsonld
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+const (
+	rdfNS   = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rdfType = rdfNS + "type"
+)
+
+// term contains the definition of a context term.
+type term struct {
+	id        string
+	typ       string
+	container string
+}
+
+// context contains the active context used to expand a document.
+type context struct {
+	terms map[string]*term
+	vocab string
+	base  string
+}
+
+// merge returns a new context resulting from applying the provided local
+// context to the current one. Remote contexts are not supported.
+func (c *context) merge(local interface{}) (*context, error) {
+	nc := &context{terms: make(map[string]*term), vocab: c.vocab, base: c.base}
+	for k, v := range c.terms {
+		nc.terms[k] = v
+	}
+	var ls []interface{}
+	if a, ok := local.([]interface{}); ok {
+		ls = a
+	} else {
+		ls = []interface{}{local}
+	}
+	for _, l := range ls {
+		switch lc := l.(type) {
+		case nil:
+			nc = &context{terms: make(map[string]*term)}
+		case string:
+			return nil, fmt.Errorf("remote context %q is not supported", lc)
+		case map[string]interface{}:
+			if err := nc.define(lc); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid context %v", l)
+		}
+	}
+	return nc, nil
+}
+
+// define adds the terms of the provided local context.
+func (c *context) define(lc map[string]interface{}) error {
+	if v, ok := lc["@base"]; ok {
+		b, ok := v.(string)
+		if !ok && v != nil {
+			return fmt.Errorf("invalid @base %v", v)
+		}
+		if b != "" {
+			var err error
+			if b, err = c.resolve(b); err != nil {
+				return err
+			}
+		}
+		c.base = b
+	}
+	if v, ok := lc["@vocab"]; ok {
+		vs, ok := v.(string)
+		if !ok && v != nil {
+			return fmt.Errorf("invalid @vocab %v", v)
+		}
+		if vs != "" {
+			vs = c.expand(vs, true)
+		}
+		c.vocab = vs
+	}
+	// Terms may be defined using other terms of the same context, hence they
+	// are defined in several passes until no more can be resolved.
+	pending := make(map[string]interface{})
+	for k, v := range lc {
+		if !strings.HasPrefix(k, "@") {
+			pending[k] = v
+		}
+	}
+	for len(pending) > 0 {
+		progress := false
+		for k, v := range pending {
+			t, err := c.term(k, v, pending)
+			if err != nil {
+				return err
+			}
+			if t == nil {
+				continue
+			}
+			c.terms[k] = t
+			delete(pending, k)
+			progress = true
+		}
+		if !progress {
+			for k := range pending {
+				return fmt.Errorf("cyclic definition of term %q", k)
+			}
+		}
+	}
+	return nil
+}
+
+// term returns the definition of the provided term, or nil if it depends on
+// other terms still pending to be defined.
+func (c *context) term(k string, v interface{}, pending map[string]interface{}) (*term, error) {
+	t := &term{}
+	switch d := v.(type) {
+	case nil:
+		return &term{}, nil
+	case string:
+		t.id = d
+	case map[string]interface{}:
+		if _, ok := d["@reverse"]; ok {
+			return nil, fmt.Errorf("reverse term %q is not supported", k)
+		}
+		if id, ok := d["@id"].(string); ok {
+			t.id = id
+		}
+		if typ, ok := d["@type"].(string); ok {
+			t.typ = typ
+		}
+		if cn, ok := d["@container"].(string); ok {
+			t.container = cn
+		}
+	default:
+		return nil, fmt.Errorf("invalid definition for term %q", k)
+	}
+	if t.id == "" {
+		t.id = k
+	}
+	for _, s := range []string{t.id, t.typ} {
+		if i := strings.Index(s, ":"); i > 0 {
+			if _, ok := pending[s[:i]]; ok && s[:i] != k {
+				return nil, nil
+			}
+		}
+	}
+	if t.id == k && !strings.Contains(k, ":") {
+		if c.vocab == "" {
+			return nil, fmt.Errorf("term %q does not expand to an IRI", k)
+		}
+		t.id = c.vocab + k
+	} else {
+		t.id = c.expand(t.id, true)
+	}
+	if t.typ != "" && t.typ != "@id" && t.typ != "@vocab" {
+		t.typ = c.expand(t.typ, true)
+	}
+	return t, nil
+}
+
+// expand returns the IRI for the provided value. Values relative to the
+// vocabulary are expanded using the terms and the vocabulary mapping; other
+// values are resolved against the base. It returns an empty string if the
+// value cannot be expanded to an IRI.
+func (c *context) expand(v string, vocab bool) string {
+	if strings.HasPrefix(v, "@") {
+		return v
+	}
+	if t, ok := c.terms[v]; ok && vocab {
+		return t.id
+	}
+	if i := strings.Index(v, ":"); i >= 0 {
+		prefix, suffix := v[:i], v[i+1:]
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return v
+		}
+		if t, ok := c.terms[prefix]; ok && t.id != "" {
+			return t.id + suffix
+		}
+		if u, err := url.Parse(v); err == nil && u.IsAbs() {
+			return v
+		}
+	}
+	if vocab {
+		if c.vocab == "" {
+			return ""
+		}
+		return c.vocab + v
+	}
+	r, err := c.resolve(v)
+	if err != nil {
+		return ""
+	}
+	return r
+}
+
+// resolve returns the provided IRI resolved against the base.
+func (c *context) resolve(iri string) (string, error) {
+	if c.base == "" {
+		return iri, nil
+	}
+	u, err := url.Parse(iri)
+	if err != nil {
+		return "", fmt.Errorf("invalid IRI %q: %v", iri, err)
+	}
+	b, err := url.Parse(c.base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base IRI %q: %v", c.base, err)
+	}
+	return b.ResolveReference(u).String(), nil
+}
+
+// decoder extracts the triples of an expanded document.
+type decoder struct {
+	m      *triple.NTriplesMapping
+	b      literal.Builder
+	blanks *triple.BlankNodeScope
+	ts     []*triple.Triple
+}
+
+// Decode reads the JSON-LD document on the provided reader and returns its
+// triples. IRIs are mapped to nodes and predicates using the provided mapping
+// in the same way N-Triples statements are; see triple.ParseNTriple. Blank
+// node identifiers are scoped to the document, so each identifier becomes a
+// new blank node that does not collide with the ones of other documents. Node
+// objects with no identifier become new blank nodes too. Named graphs are merged into the
+// default one. Remote contexts are not supported.
+func Decode(r io.Reader, m *triple.NTriplesMapping, b literal.Builder) ([]*triple.Triple, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("jsonld.Decode failed to parse the document: %v", err)
+	}
+	d := &decoder{m: m, b: b, blanks: triple.NewBlankNodeScope()}
+	if err := d.nodes(doc, &context{terms: make(map[string]*term)}); err != nil {
+		return nil, fmt.Errorf("jsonld.Decode failed to extract the triples: %v", err)
+	}
+	return d.ts, nil
+}
+
+// nodes processes the provided node objects.
+func (d *decoder) nodes(v interface{}, ctx *context) error {
+	switch n := v.(type) {
+	case []interface{}:
+		for _, e := range n {
+			if err := d.nodes(e, ctx); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		_, err := d.node(n, ctx)
+		return err
+	default:
+		return fmt.Errorf("invalid node object %v", v)
+	}
+	return nil
+}
+
+// node processes the provided node object and returns its subject.
+func (d *decoder) node(obj map[string]interface{}, ctx *context) (*node.Node, error) {
+	if lc, ok := obj["@context"]; ok {
+		nc, err := ctx.merge(lc)
+		if err != nil {
+			return nil, err
+		}
+		ctx = nc
+	}
+	var ks []string
+	for k := range obj {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	var s *node.Node
+	for _, k := range ks {
+		if ctx.expand(k, true) != "@id" {
+			continue
+		}
+		ids, ok := obj[k].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid @id %v", obj[k])
+		}
+		n, err := d.iriNode(ctx.expand(ids, false))
+		if err != nil {
+			return nil, err
+		}
+		s = n
+	}
+	if s == nil {
+		s = node.NewBlankNode()
+	}
+	for _, k := range ks {
+		v, piri := obj[k], ctx.expand(k, true)
+		switch piri {
+		case "@context", "@id", "@index":
+			continue
+		case "@graph":
+			if err := d.nodes(v, ctx); err != nil {
+				return nil, err
+			}
+			continue
+		case "@type":
+			p, err := d.m.Predicate(rdfType)
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range asArray(v) {
+				ts, ok := t.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid @type %v", t)
+				}
+				n, err := d.iriNode(ctx.expand(ts, true))
+				if err != nil {
+					return nil, err
+				}
+				if err := d.emit(s, p, triple.NewNodeObject(n)); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		case "@reverse":
+			return nil, fmt.Errorf("reverse properties are not supported")
+		}
+		if piri == "" || strings.HasPrefix(piri, "@") || strings.HasPrefix(piri, "_:") {
+			// Properties not mapping to IRIs are dropped.
+			continue
+		}
+		p, err := d.m.Predicate(piri)
+		if err != nil {
+			return nil, err
+		}
+		t := ctx.terms[k]
+		if t == nil {
+			t = &term{}
+		}
+		if t.container == "@list" {
+			v = map[string]interface{}{"@list": v}
+		}
+		for _, e := range flatten(v) {
+			o, err := d.value(e, t, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %q: %v", k, err)
+			}
+			if o == nil {
+				continue
+			}
+			if err := d.emit(s, p, o); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// value returns the object for the provided value, or nil if it is null.
+func (d *decoder) value(v interface{}, t *term, ctx *context) (*triple.Object, error) {
+	switch e := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		switch t.typ {
+		case "@id", "@vocab":
+			n, err := d.iriNode(ctx.expand(e, t.typ == "@vocab"))
+			if err != nil {
+				return nil, err
+			}
+			return triple.NewNodeObject(n), nil
+		case "":
+			return d.literal(e, "")
+		}
+		return d.literal(e, t.typ)
+	case json.Number, bool:
+		return d.native(e, t.typ)
+	case []interface{}:
+		return d.list(e, ctx)
+	case map[string]interface{}:
+		if l, ok := e["@list"]; ok {
+			return d.list(asArray(l), ctx)
+		}
+		if sv, ok := e["@value"]; ok {
+			dt := ""
+			if typ, ok := e["@type"].(string); ok {
+				dt = ctx.expand(typ, true)
+			}
+			if s, ok := sv.(string); ok {
+				return d.literal(s, dt)
+			}
+			return d.native(sv, dt)
+		}
+		if sv, ok := e["@set"]; ok {
+			vs := asArray(sv)
+			if len(vs) != 1 {
+				return nil, fmt.Errorf("sets with %d values cannot be nested", len(vs))
+			}
+			return d.value(vs[0], t, ctx)
+		}
+		n, err := d.node(e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return triple.NewNodeObject(n), nil
+	}
+	return nil, fmt.Errorf("invalid value %v", v)
+}
+
+// native returns the literal for the provided JSON number or boolean.
+func (d *decoder) native(v interface{}, dt string) (*triple.Object, error) {
+	switch e := v.(type) {
+	case bool:
+		l, err := d.b.Build(literal.Bool, e)
+		if err != nil {
+			return nil, err
+		}
+		return triple.NewLiteralObject(l), nil
+	case json.Number:
+		if dt == "" {
+			dt = triple.XSDNamespace + "integer"
+			if strings.ContainsAny(string(e), ".eE") {
+				dt = triple.XSDNamespace + "double"
+			}
+		}
+		return d.literal(string(e), dt)
+	}
+	return nil, fmt.Errorf("invalid value %v", v)
+}
+
+// literal returns the literal for the provided lexical form and datatype.
+func (d *decoder) literal(v, dt string) (*triple.Object, error) {
+	l, err := triple.RDFLiteral(v, dt, d.b)
+	if err != nil {
+		return nil, err
+	}
+	return triple.NewLiteralObject(l), nil
+}
+
+// list expands the provided values into a chain of rdf:first and rdf:rest
+// triples and returns its head. Empty lists are rdf:nil.
+func (d *decoder) list(vs []interface{}, ctx *context) (*triple.Object, error) {
+	first, err := d.m.Predicate(rdfNS + "first")
+	if err != nil {
+		return nil, err
+	}
+	rest, err := d.m.Predicate(rdfNS + "rest")
+	if err != nil {
+		return nil, err
+	}
+	next, err := d.m.Node(rdfNS + "nil")
+	if err != nil {
+		return nil, err
+	}
+	for i := len(vs) - 1; i >= 0; i-- {
+		o, err := d.value(vs[i], &term{}, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if o == nil {
+			continue
+		}
+		n := node.NewBlankNode()
+		if err := d.emit(n, first, o); err != nil {
+			return nil, err
+		}
+		if err := d.emit(n, rest, triple.NewNodeObject(next)); err != nil {
+			return nil, err
+		}
+		next = n
+	}
+	return triple.NewNodeObject(next), nil
+}
+
+// iriNode returns the node for the provided IRI or blank node identifier.
+func (d *decoder) iriNode(iri string) (*node.Node, error) {
+	if iri == "" {
+		return nil, fmt.Errorf("value does not expand to an IRI")
+	}
+	if strings.HasPrefix(iri, "_:") {
+		return d.blanks.Label(iri[2:]), nil
+	}
+	return d.m.Node(iri)
+}
+
+// emit adds the triple for the provided subject, predicate, and object.
+func (d *decoder) emit(s *node.Node, p *predicate.Predicate, o *triple.Object) error {
+	t, err := triple.New(s, p, o)
+	if err != nil {
+		return err
+	}
+	d.ts = append(d.ts, t)
+	return nil
+}
+
+// asArray returns the provided value as an array.
+func asArray(v interface{}) []interface{} {
+	if a, ok := v.([]interface{}); ok {
+		return a
+	}
+	return []interface{}{v}
+}
+
+// flatten returns the provided value as an array, flattening any nested
+// arrays.
+func flatten(v interface{}) []interface{} {
+	a, ok := v.([]interface{})
+	if !ok {
+		return []interface{}{v}
+	}
+	var vs []interface{}
+	for _, e := range a {
+		vs = append(vs, flatten(e)...)
+	}
+	return vs
+}
+
+// Encode writes the provided triples as a flattened JSON-LD document. Each
+// subject is written as a separate node object of the top level @graph,
+// using absolute IRIs and no context. Literals other than text are written
+// with their xsd datatype. It fails for triples that have no RDF counterpart,
+// such as the ones with temporal predicates or embedded triples.
+func Encode(w io.Writer, ts []*triple.Triple, m *triple.NTriplesMapping) error {
+	var (
+		objs []map[string]interface{}
+		idx  = make(map[string]int)
+	)
+	for _, t := range ts {
+		id, err := nodeID(t.S(), m)
+		if err != nil {
+			return fmt.Errorf("jsonld.Encode failed to encode %v: %v", t, err)
+		}
+		i, ok := idx[id]
+		if !ok {
+			i = len(objs)
+			idx[id] = i
+			objs = append(objs, map[string]interface{}{"@id": id})
+		}
+		piri, err := m.PredicateIRI(t.P())
+		if err != nil {
+			return fmt.Errorf("jsonld.Encode failed to encode %v: %v", t, err)
+		}
+		var v interface{}
+		if n, ok := t.O().AsNode(); ok {
+			oid, err := nodeID(n, m)
+			if err != nil {
+				return fmt.Errorf("jsonld.Encode failed to encode %v: %v", t, err)
+			}
+			if piri == rdfType {
+				objs[i]["@type"] = append(asStrings(objs[i]["@type"]), oid)
+				continue
+			}
+			v = map[string]interface{}{"@id": oid}
+		} else if l, ok := t.O().AsLiteral(); ok {
+			lv, dt, err := triple.RDFLexicalForm(l)
+			if err != nil {
+				return fmt.Errorf("jsonld.Encode failed to encode %v: %v", t, err)
+			}
+			lo := map[string]interface{}{"@value": lv}
+			if dt != "" {
+				lo["@type"] = dt
+			}
+			v = lo
+		} else {
+			return fmt.Errorf("jsonld.Encode failed to encode %v: object %v cannot be represented in JSON-LD", t, t.O())
+		}
+		vs, _ := objs[i][piri].([]interface{})
+		objs[i][piri] = append(vs, v)
+	}
+	if objs == nil {
+		objs = []map[string]interface{}{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{"@graph": objs})
+}
+
+// asStrings returns the provided value as a slice of strings.
+func asStrings(v interface{}) []string {
+	ss, _ := v.([]string)
+	return ss
+}
+
+// nodeID returns the JSON-LD identifier of the provided node.
+func nodeID(n *node.Node, m *triple.NTriplesMapping) (string, error) {
+	if n.Type().String() == "/_" {
+		return "_:" + n.ID().String(), nil
+	}
+	return m.NodeIRI(n)
+}
+
+// ReadIntoGraph reads the JSON-LD document on the provided reader into the
+// graph. It returns the number of triples added.
+func ReadIntoGraph(g storage.Graph, r io.Reader, m *triple.NTriplesMapping, b literal.Builder) (int, error) {
+	ts, err := Decode(r, m, b)
+	if err != nil {
+		return 0, err
+	}
+	if err := g.AddTriples(ts); err != nil {
+		return 0, err
+	}
+	return len(ts), nil
+}
+
+// WriteGraph writes the triples of the provided graph as a flattened JSON-LD
+// document. It returns the number of triples written.
+func WriteGraph(w io.Writer, g storage.Graph, m *triple.NTriplesMapping) (int, error) {
+	tc, err := g.Triples()
+	if err != nil {
+		return 0, err
+	}
+	var ts []*triple.Triple
+	for t := range tc {
+		ts = append(ts, t)
+	}
+	if err := Encode(w, ts, m); err != nil {
+		return 0, err
+	}
+	return len(ts), nil
+}
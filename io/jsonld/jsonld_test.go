@@ -0,0 +1,284 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonld
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func testMapping() *triple.NTriplesMapping {
+	return &triple.NTriplesMapping{
+		NodeTypes:       map[string]string{"http://example.org/people/": "/person"},
+		PredicatePrefix: "http://schema.org/",
+	}
+}
+
+var blankRE = regexp.MustCompile(`/_<[^>]*>`)
+
+// tripleStrings returns the sorted strings of the provided triples. Blank node
+// IDs are replaced by b0, b1, ... in the order they first appear.
+func tripleStrings(ts []*triple.Triple) []string {
+	var ss []string
+	ids := make(map[string]string)
+	for _, t := range ts {
+		ss = append(ss, blankRE.ReplaceAllStringFunc(t.String(), func(b string) string {
+			if _, ok := ids[b]; !ok {
+				ids[b] = fmt.Sprintf("/_<b%d>", len(ids))
+			}
+			return ids[b]
+		}))
+	}
+	sort.Strings(ss)
+	return ss
+}
+
+func TestDecode(t *testing.T) {
+	table := []struct {
+		doc  string
+		want []string
+	}{
+		{
+			doc: `{
+				"@context": {
+					"@vocab": "http://schema.org/",
+					"p": "http://example.org/people/",
+					"knows": {"@type": "@id"},
+					"born": {"@id": "birthDate", "@type": "http://www.w3.org/2001/XMLSchema#duration"},
+					"id": "@id",
+					"ignored": null
+				},
+				"id": "p:joe",
+				"@type": "Person",
+				"name": ["Joe", {"@value": "José", "@language": "es"}],
+				"age": 42,
+				"height": 1.8,
+				"alive": true,
+				"born": "PT1S",
+				"weight": {"@value": "75", "@type": "http://www.w3.org/2001/XMLSchema#int"},
+				"knows": ["p:mary", "http://example.org/people/peter"],
+				"ignored": "x",
+				"unmapped:thing": "y"
+			}`,
+			want: []string{
+				"/person<joe>\t\"age\"@[]\t\"42\"^^type:int64",
+				"/person<joe>\t\"alive\"@[]\t\"true\"^^type:bool",
+				"/person<joe>\t\"birthDate\"@[]\t\"1s\"^^type:duration",
+				"/person<joe>\t\"height\"@[]\t\"1.8\"^^type:float64",
+				"/person<joe>\t\"http://www.w3.org/1999/02/22-rdf-syntax-ns#type\"@[]\t/iri<http://schema.org/Person>",
+				"/person<joe>\t\"knows\"@[]\t/person<mary>",
+				"/person<joe>\t\"knows\"@[]\t/person<peter>",
+				"/person<joe>\t\"name\"@[]\t\"Joe\"^^type:text",
+				"/person<joe>\t\"name\"@[]\t\"José\"^^type:text",
+				"/person<joe>\t\"unmapped:thing\"@[]\t\"y\"^^type:text",
+				"/person<joe>\t\"weight\"@[]\t\"75\"^^type:int64",
+			},
+		},
+		{
+			doc: `{
+				"@context": {"@base": "http://example.org/people/", "s": "http://schema.org/"},
+				"@graph": [
+					{"@id": "joe", "s:knows": {"@id": "_:b0"}},
+					{"@id": "_:b0", "s:name": "Anon"}
+				]
+			}`,
+			want: []string{
+				"/_<b0>\t\"name\"@[]\t\"Anon\"^^type:text",
+				"/person<joe>\t\"knows\"@[]\t/_<b0>",
+			},
+		},
+		{
+			doc: `[
+				{"@id": "http://a.org/s", "http://schema.org/list": {"@list": []}},
+				{"@id": "http://a.org/t", "http://schema.org/v": null}
+			]`,
+			want: []string{
+				"/iri<http://a.org/s>\t\"list\"@[]\t/iri<http://www.w3.org/1999/02/22-rdf-syntax-ns#nil>",
+			},
+		},
+	}
+	for _, entry := range table {
+		ts, err := Decode(strings.NewReader(entry.doc), testMapping(), literal.DefaultBuilder())
+		if err != nil {
+			t.Errorf("jsonld.Decode(%s) failed with error %v", entry.doc, err)
+			continue
+		}
+		got := tripleStrings(ts)
+		if strings.Join(got, "\n") != strings.Join(entry.want, "\n") {
+			t.Errorf("jsonld.Decode(%s) returned the wrong triples;\ngot  %q\nwant %q", entry.doc, got, entry.want)
+		}
+	}
+}
+
+func TestDecodeNested(t *testing.T) {
+	doc := `{
+		"@context": {"s": "http://schema.org/", "items": {"@id": "s:items", "@container": "@list"}},
+		"@id": "http://example.org/people/joe",
+		"s:address": {"s:city": "Zürich"},
+		"items": ["a", "b"]
+	}`
+	ts, err := Decode(strings.NewReader(doc), testMapping(), literal.DefaultBuilder())
+	if err != nil {
+		t.Fatalf("jsonld.Decode failed with error %v", err)
+	}
+	// The address and its city, and the list items head plus two cells with
+	// their first and rest.
+	if got, want := len(ts), 7; got != want {
+		t.Errorf("jsonld.Decode returned the wrong number of triples; got %d, want %d\n%q", got, want, tripleStrings(ts))
+	}
+	preds := make(map[string]int)
+	for _, tr := range ts {
+		preds[string(tr.P().ID())]++
+	}
+	for p, n := range map[string]int{
+		"address": 1,
+		"city":    1,
+		"items":   1,
+		"http://www.w3.org/1999/02/22-rdf-syntax-ns#first": 2,
+		"http://www.w3.org/1999/02/22-rdf-syntax-ns#rest":  2,
+	} {
+		if preds[p] != n {
+			t.Errorf("jsonld.Decode returned %d triples for %q; want %d", preds[p], p, n)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	table := []string{
+		`not json`,
+		`"a string"`,
+		`{"@context": "http://schema.org/", "name": "x"}`,
+		`{"@context": {"name": "name"}, "name": "x"}`,
+		`{"@context": {"a": "b:x", "b": "a:y"}, "a": "x"}`,
+		`{"@id": 42}`,
+		`{"@id": "http://a.org/s", "@reverse": {}}`,
+		`{"@id": "http://a.org/s", "http://a.org/p": {"@value": "x", "@type": "http://www.w3.org/2001/XMLSchema#integer"}}`,
+	}
+	for _, doc := range table {
+		if _, err := Decode(strings.NewReader(doc), nil, literal.DefaultBuilder()); err == nil {
+			t.Errorf("jsonld.Decode(%s) should have failed", doc)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	doc := `{
+		"@context": {"s": "http://schema.org/", "p": "http://example.org/people/"},
+		"@id": "p:joe",
+		"@type": "s:Person",
+		"s:name": "Joe",
+		"s:age": 42,
+		"s:height": 1.5,
+		"s:alive": false,
+		"s:knows": [{"@id": "p:mary"}, {"@id": "_:x"}],
+		"s:data": {"@value": "aGk=", "@type": "http://www.w3.org/2001/XMLSchema#base64Binary"}
+	}`
+	ts, err := Decode(strings.NewReader(doc), testMapping(), literal.DefaultBuilder())
+	if err != nil {
+		t.Fatalf("jsonld.Decode failed with error %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, ts, testMapping()); err != nil {
+		t.Fatalf("jsonld.Encode failed with error %v", err)
+	}
+	if strings.Contains(buf.String(), "@context") {
+		t.Errorf("jsonld.Encode should not write a context; got %s", buf.String())
+	}
+	back, err := Decode(&buf, testMapping(), literal.DefaultBuilder())
+	if err != nil {
+		t.Fatalf("jsonld.Decode failed to read the encoded document with error %v", err)
+	}
+	got, want := tripleStrings(back), tripleStrings(ts)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("JSON-LD round trip failed;\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	table := []string{
+		"/user<joe>\t\"knows\"@[]\t/iri<http://a.org/o>",
+		"/iri<http://a.org/s>\t\"knows\"@[2015-01-01T00:00:00Z]\t/iri<http://a.org/o>",
+		"/iri<http://a.org/s>\t\"knows\"@[]\t\"knows\"@[]",
+	}
+	for _, line := range table {
+		tr, err := triple.ParseTriple(line, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Encode(&bytes.Buffer{}, []*triple.Triple{tr}, nil); err == nil {
+			t.Errorf("jsonld.Encode(%v) should have failed", tr)
+		}
+	}
+}
+
+func TestGraphs(t *testing.T) {
+	doc := `{"@id": "http://a.org/s", "http://a.org/p": [{"@id": "http://a.org/o"}, "text"]}`
+	g, err := memory.NewStore().NewGraph("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnt, err := ReadIntoGraph(g, strings.NewReader(doc), nil, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatalf("jsonld.ReadIntoGraph failed with error %v", err)
+	}
+	if got, want := cnt, 2; got != want {
+		t.Errorf("jsonld.ReadIntoGraph added the wrong number of triples; got %d, want %d", got, want)
+	}
+	var buf bytes.Buffer
+	cnt, err = WriteGraph(&buf, g, nil)
+	if err != nil {
+		t.Fatalf("jsonld.WriteGraph failed with error %v", err)
+	}
+	if got, want := cnt, 2; got != want {
+		t.Errorf("jsonld.WriteGraph wrote the wrong number of triples; got %d, want %d", got, want)
+	}
+	ts, err := Decode(&buf, nil, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatalf("jsonld.Decode failed to read %q with error %v", buf.String(), err)
+	}
+	if got, want := len(ts), 2; got != want {
+		t.Errorf("jsonld.Decode returned the wrong number of triples; got %d, want %d", got, want)
+	}
+}
+
+func TestDecodeBlankNodeScope(t *testing.T) {
+	doc := `{"@id": "_:x", "http://a.org/p": {"@id": "_:x"}}`
+	t1, err := Decode(strings.NewReader(doc), nil, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := Decode(strings.NewReader(doc), nil, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := t1[0].O().Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1[0].S().String() != o.String() {
+		t.Errorf("the same blank node identifier should map to the same node; got %v and %v", t1[0].S(), o)
+	}
+	if t1[0].S().String() == t2[0].S().String() {
+		t.Errorf("blank nodes of different documents should not collide; got %v twice", t1[0].S())
+	}
+}
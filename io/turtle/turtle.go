@@ -0,0 +1,753 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package turtle reads Turtle documents into BadWolf triples. Documents are
+// parsed as a stream, one statement at a time, so arbitrarily large documents
+// can be loaded without holding them in memory.
+package turtle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+const rdfNS = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// Reader streams the triples of a Turtle document. IRIs are mapped to nodes
+// and predicates using the provided mapping in the same way N-Triples
+// statements are; see triple.ParseNTriple. Blank node labels are scoped to the
+// document, so each label becomes a new BadWolf blank node that does not
+// collide with the ones of other documents. Anonymous blank nodes and the ones
+// used to build lists are new blank nodes too.
+type Reader struct {
+	r        *bufio.Reader
+	m        *triple.NTriplesMapping
+	b        literal.Builder
+	base     string
+	prefixes map[string]string
+	line     int
+	blanks   *triple.BlankNodeScope
+	pending  []*triple.Triple
+	err      error
+}
+
+// NewReader returns a reader for the Turtle document on the provided reader.
+func NewReader(r io.Reader, m *triple.NTriplesMapping, b literal.Builder) *Reader {
+	return &Reader{
+		r:        bufio.NewReader(r),
+		m:        m,
+		b:        b,
+		prefixes: make(map[string]string),
+		blanks:   triple.NewBlankNodeScope(),
+		line:     1,
+	}
+}
+
+// Read returns the next batch of at most n triples. Only the statements needed
+// to fill the batch are parsed. It returns io.EOF once all the triples of the
+// document have been returned. The triples parsed before a syntax error are
+// returned before the error itself.
+func (r *Reader) Read(n int) ([]*triple.Triple, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("turtle.Reader.Read requires a positive batch size; got %d", n)
+	}
+	for len(r.pending) < n && r.err == nil {
+		if err := r.statement(); err != nil {
+			if err != io.EOF {
+				err = fmt.Errorf("turtle.Reader failed to parse line %d: %v", r.line, err)
+			}
+			r.err = err
+		}
+	}
+	if len(r.pending) == 0 {
+		return nil, r.err
+	}
+	if n > len(r.pending) {
+		n = len(r.pending)
+	}
+	ts := append([]*triple.Triple{}, r.pending[:n]...)
+	r.pending = append(r.pending[:0], r.pending[n:]...)
+	return ts, nil
+}
+
+// ReadIntoGraph reads the Turtle document on the provided reader into the
+// graph, adding its triples in batches of the provided size. It returns the
+// number of triples added. If it fails, the triples read till then would have
+// also been added to the graph.
+func ReadIntoGraph(g storage.Graph, rd io.Reader, m *triple.NTriplesMapping, b literal.Builder, batchSize int) (int, error) {
+	cnt, r := 0, NewReader(rd, m, b)
+	for {
+		ts, err := r.Read(batchSize)
+		if err == io.EOF {
+			return cnt, nil
+		}
+		if err != nil {
+			return cnt, err
+		}
+		if err := g.AddTriples(ts); err != nil {
+			return cnt, err
+		}
+		cnt += len(ts)
+	}
+}
+
+// statement parses the next directive or triples statement. It returns io.EOF
+// if there are no more statements.
+func (r *Reader) statement() error {
+	if err := r.skip(); err != nil {
+		return err
+	}
+	c, err := r.peek()
+	if err != nil {
+		return err
+	}
+	switch {
+	case c == '@', r.keyword("PREFIX"), r.keyword("BASE"):
+		err = r.directive()
+	default:
+		err = r.triples()
+	}
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// keyword returns true if the next word is the provided case insensitive
+// keyword.
+func (r *Reader) keyword(k string) bool {
+	bs, _ := r.r.Peek(len(k) + 1)
+	return len(bs) == len(k)+1 && strings.EqualFold(string(bs[:len(k)]), k) && isSpace(bs[len(k)])
+}
+
+// directive parses a prefix or base directive, either in its Turtle or its
+// SPARQL form.
+func (r *Reader) directive() error {
+	sparql := true
+	if c, _ := r.peek(); c == '@' {
+		r.readByte()
+		sparql = false
+	}
+	w, err := r.name()
+	if err != nil {
+		return err
+	}
+	if err := r.skip(); err != nil {
+		return err
+	}
+	switch {
+	case !sparql && w == "prefix", sparql && strings.EqualFold(w, "PREFIX"):
+		pn, err := r.name()
+		if err != nil {
+			return err
+		}
+		if strings.Index(pn, ":") != len(pn)-1 {
+			return fmt.Errorf("invalid prefix %q", pn)
+		}
+		if err := r.skip(); err != nil {
+			return err
+		}
+		iri, err := r.iriRef()
+		if err != nil {
+			return err
+		}
+		r.prefixes[strings.TrimSuffix(pn, ":")] = iri
+	case !sparql && w == "base", sparql && strings.EqualFold(w, "BASE"):
+		iri, err := r.iriRef()
+		if err != nil {
+			return err
+		}
+		r.base = iri
+	default:
+		return fmt.Errorf("unknown directive %q", w)
+	}
+	if sparql {
+		return nil
+	}
+	return r.expect('.')
+}
+
+// triples parses a triples statement.
+func (r *Reader) triples() error {
+	c, err := r.peek()
+	if err != nil {
+		return err
+	}
+	s, err := r.subject()
+	if err != nil {
+		return err
+	}
+	if err := r.skip(); err != nil {
+		return err
+	}
+	// A blank node property list can be a statement on its own.
+	if nc, _ := r.peek(); nc == '.' && c == '[' {
+		r.readByte()
+		return nil
+	}
+	if err := r.predicateObjectList(s); err != nil {
+		return err
+	}
+	return r.expect('.')
+}
+
+// predicateObjectList parses the predicates and objects of the provided
+// subject.
+func (r *Reader) predicateObjectList(s *node.Node) error {
+	for {
+		if err := r.skip(); err != nil {
+			return err
+		}
+		p, err := r.verb()
+		if err != nil {
+			return err
+		}
+		if err := r.objectList(s, p); err != nil {
+			return err
+		}
+		if c, _ := r.peek(); c != ';' {
+			return nil
+		}
+		for {
+			if c, _ := r.peek(); c != ';' {
+				break
+			}
+			r.readByte()
+			if err := r.skip(); err != nil {
+				return err
+			}
+		}
+		if c, _ := r.peek(); c == '.' || c == ']' {
+			return nil
+		}
+	}
+}
+
+// objectList parses the comma separated objects of the provided subject and
+// predicate.
+func (r *Reader) objectList(s *node.Node, p *predicate.Predicate) error {
+	for {
+		if err := r.skip(); err != nil {
+			return err
+		}
+		o, err := r.object()
+		if err != nil {
+			return err
+		}
+		if err := r.emit(s, p, o); err != nil {
+			return err
+		}
+		if err := r.skip(); err != nil {
+			return err
+		}
+		if c, _ := r.peek(); c != ',' {
+			return nil
+		}
+		r.readByte()
+	}
+}
+
+// emit queues the triple for the provided subject, predicate, and object.
+func (r *Reader) emit(s *node.Node, p *predicate.Predicate, o *triple.Object) error {
+	t, err := triple.New(s, p, o)
+	if err != nil {
+		return err
+	}
+	r.pending = append(r.pending, t)
+	return nil
+}
+
+// verb parses a predicate, including the "a" shorthand for rdf:type.
+func (r *Reader) verb() (*predicate.Predicate, error) {
+	if bs, _ := r.r.Peek(2); len(bs) == 2 && bs[0] == 'a' && !isNameByte(bs[1]) {
+		r.readByte()
+		return r.m.Predicate(rdfNS + "type")
+	}
+	iri, err := r.iri()
+	if err != nil {
+		return nil, err
+	}
+	return r.m.Predicate(iri)
+}
+
+// subject parses the subject of a triples statement.
+func (r *Reader) subject() (*node.Node, error) {
+	c, err := r.peek()
+	if err != nil {
+		return nil, err
+	}
+	switch c {
+	case '[':
+		return r.blankNodePropertyList()
+	case '(':
+		return r.collection()
+	case '_':
+		return r.blankNode()
+	}
+	iri, err := r.iri()
+	if err != nil {
+		return nil, err
+	}
+	return r.m.Node(iri)
+}
+
+// object parses an object.
+func (r *Reader) object() (*triple.Object, error) {
+	c, err := r.peek()
+	if err != nil {
+		return nil, err
+	}
+	var l *literal.Literal
+	switch {
+	case c == '"' || c == '\'':
+		l, err = r.literal()
+	case c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.':
+		l, err = r.number()
+	case r.isBoolean():
+		w, _ := r.name()
+		l, err = r.b.Build(literal.Bool, w == "true")
+	default:
+		n, err := r.subject()
+		if err != nil {
+			return nil, err
+		}
+		return triple.NewNodeObject(n), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return triple.NewLiteralObject(l), nil
+}
+
+// isBoolean returns true if the next word is a boolean literal.
+func (r *Reader) isBoolean() bool {
+	for _, k := range []string{"true", "false"} {
+		bs, _ := r.r.Peek(len(k) + 1)
+		if strings.HasPrefix(string(bs), k) && (len(bs) == len(k) || !isNameByte(bs[len(k)])) {
+			return true
+		}
+	}
+	return false
+}
+
+// blankNode parses a labeled blank node.
+func (r *Reader) blankNode() (*node.Node, error) {
+	if bs, _ := r.r.Peek(2); string(bs) != "_:" {
+		return nil, fmt.Errorf("invalid blank node")
+	}
+	r.readByte()
+	r.readByte()
+	l, err := r.name()
+	if err != nil {
+		return nil, err
+	}
+	return r.blanks.Label(l), nil
+}
+
+// blankNodePropertyList parses an anonymous blank node and the triples
+// describing it.
+func (r *Reader) blankNodePropertyList() (*node.Node, error) {
+	r.readByte()
+	n := node.NewBlankNode()
+	if err := r.skip(); err != nil {
+		return nil, err
+	}
+	if c, _ := r.peek(); c != ']' {
+		if err := r.predicateObjectList(n); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.expect(']'); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// collection parses a list of objects into a chain of rdf:first and rdf:rest
+// triples, and returns its head. Empty lists are rdf:nil.
+func (r *Reader) collection() (*node.Node, error) {
+	r.readByte()
+	first, err := r.m.Predicate(rdfNS + "first")
+	if err != nil {
+		return nil, err
+	}
+	rest, err := r.m.Predicate(rdfNS + "rest")
+	if err != nil {
+		return nil, err
+	}
+	var head, cur *node.Node
+	for {
+		if err := r.skip(); err != nil {
+			return nil, err
+		}
+		c, err := r.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == ')' {
+			r.readByte()
+			break
+		}
+		o, err := r.object()
+		if err != nil {
+			return nil, err
+		}
+		n := node.NewBlankNode()
+		if head == nil {
+			head = n
+		} else if err := r.emit(cur, rest, triple.NewNodeObject(n)); err != nil {
+			return nil, err
+		}
+		if err := r.emit(n, first, o); err != nil {
+			return nil, err
+		}
+		cur = n
+	}
+	nl, err := r.m.Node(rdfNS + "nil")
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nl, nil
+	}
+	if err := r.emit(cur, rest, triple.NewNodeObject(nl)); err != nil {
+		return nil, err
+	}
+	return head, nil
+}
+
+// iri parses an IRI reference or a prefixed name, and returns the absolute IRI.
+func (r *Reader) iri() (string, error) {
+	c, err := r.peek()
+	if err != nil {
+		return "", err
+	}
+	if c == '<' {
+		return r.iriRef()
+	}
+	pn, err := r.name()
+	if err != nil {
+		return "", err
+	}
+	i := strings.Index(pn, ":")
+	if i < 0 {
+		return "", fmt.Errorf("invalid prefixed name %q", pn)
+	}
+	ns, ok := r.prefixes[pn[:i]]
+	if !ok {
+		return "", fmt.Errorf("undeclared prefix %q", pn[:i])
+	}
+	return ns + pn[i+1:], nil
+}
+
+// iriRef parses an IRI enclosed in angle brackets, and resolves it against the
+// current base.
+func (r *Reader) iriRef() (string, error) {
+	if err := r.expect('<'); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for {
+		c, err := r.readByte()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case c == '>':
+			return r.resolve(sb.String())
+		case c == '\\':
+			rn, err := r.uchar()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(rn)
+		case c <= ' ' || strings.IndexByte("<\"{}|^`", c) >= 0:
+			return "", fmt.Errorf("invalid character %q in IRI", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// resolve returns the absolute version of the provided IRI using the current
+// base.
+func (r *Reader) resolve(iri string) (string, error) {
+	if r.base == "" {
+		return iri, nil
+	}
+	u, err := url.Parse(iri)
+	if err != nil {
+		return "", fmt.Errorf("invalid IRI %q: %v", iri, err)
+	}
+	if u.IsAbs() {
+		return iri, nil
+	}
+	b, err := url.Parse(r.base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base IRI %q: %v", r.base, err)
+	}
+	return b.ResolveReference(u).String(), nil
+}
+
+// name parses a prefixed name, a blank node label, or a keyword. Escaped
+// characters are unescaped, and dots are not allowed at the end.
+func (r *Reader) name() (string, error) {
+	var sb strings.Builder
+	for {
+		bs, _ := r.r.Peek(2)
+		if len(bs) == 0 {
+			break
+		}
+		c := bs[0]
+		if c == '.' && (len(bs) < 2 || !isNameByte(bs[1]) && bs[1] != '.') {
+			break
+		}
+		if c == '\\' {
+			r.readByte()
+			e, err := r.readByte()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteByte(e)
+			continue
+		}
+		if !isNameByte(c) && c != '.' {
+			break
+		}
+		r.readByte()
+		sb.WriteByte(c)
+	}
+	if sb.Len() == 0 {
+		c, _ := r.peek()
+		return "", fmt.Errorf("unexpected character %q", c)
+	}
+	return sb.String(), nil
+}
+
+// literal parses a quoted literal, with its optional language tag or datatype.
+func (r *Reader) literal() (*literal.Literal, error) {
+	q, _ := r.readByte()
+	long := false
+	if bs, _ := r.r.Peek(2); len(bs) == 2 && bs[0] == q && bs[1] == q {
+		r.readByte()
+		r.readByte()
+		long = true
+	}
+	var sb strings.Builder
+	for {
+		c, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == q {
+			if !long {
+				break
+			}
+			if bs, _ := r.r.Peek(2); len(bs) == 2 && bs[0] == q && bs[1] == q {
+				r.readByte()
+				r.readByte()
+				break
+			}
+		}
+		switch {
+		case c == '\\':
+			bs, _ := r.r.Peek(1)
+			if len(bs) == 1 {
+				if e := strings.IndexByte("tbnrf\"'\\", bs[0]); e >= 0 {
+					r.readByte()
+					sb.WriteByte("\t\b\n\r\f\"'\\"[e])
+					continue
+				}
+			}
+			rn, err := r.uchar()
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteRune(rn)
+		case !long && (c == '\n' || c == '\r'):
+			return nil, fmt.Errorf("unescaped line break in literal")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	v, dt := sb.String(), ""
+	if bs, _ := r.r.Peek(2); len(bs) > 0 && bs[0] == '@' {
+		r.readByte()
+		lang, err := r.name()
+		if err != nil {
+			return nil, fmt.Errorf("invalid language tag: %v", err)
+		}
+		if strings.ContainsAny(lang, ":.") {
+			return nil, fmt.Errorf("invalid language tag %q", lang)
+		}
+	} else if string(bs) == "^^" {
+		r.readByte()
+		r.readByte()
+		var err error
+		if dt, err = r.iri(); err != nil {
+			return nil, fmt.Errorf("invalid datatype: %v", err)
+		}
+	}
+	return triple.RDFLiteral(v, dt, r.b)
+}
+
+// number parses an integer, decimal, or double literal.
+func (r *Reader) number() (*literal.Literal, error) {
+	var sb strings.Builder
+	digits := func() {
+		for {
+			c, err := r.peek()
+			if err != nil || c < '0' || c > '9' {
+				return
+			}
+			r.readByte()
+			sb.WriteByte(c)
+		}
+	}
+	if c, _ := r.peek(); c == '+' || c == '-' {
+		r.readByte()
+		sb.WriteByte(c)
+	}
+	digits()
+	dt := "integer"
+	if bs, _ := r.r.Peek(2); len(bs) == 2 && bs[0] == '.' && bs[1] >= '0' && bs[1] <= '9' {
+		r.readByte()
+		sb.WriteByte('.')
+		digits()
+		dt = "decimal"
+	}
+	if c, _ := r.peek(); c == 'e' || c == 'E' {
+		r.readByte()
+		sb.WriteByte(c)
+		if c, _ := r.peek(); c == '+' || c == '-' {
+			r.readByte()
+			sb.WriteByte(c)
+		}
+		digits()
+		dt = "double"
+	}
+	v := sb.String()
+	if strings.Trim(v, "+-.eE") == "" {
+		return nil, fmt.Errorf("invalid number %q", v)
+	}
+	return triple.RDFLiteral(v, triple.XSDNamespace+dt, r.b)
+}
+
+// uchar parses the rest of a \u or \U escaped code point.
+func (r *Reader) uchar() (rune, error) {
+	c, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	switch c {
+	case 'u':
+		n = 4
+	case 'U':
+		n = 8
+	default:
+		return 0, fmt.Errorf("invalid escape sequence \\%c", c)
+	}
+	bs := make([]byte, n)
+	for i := range bs {
+		if bs[i], err = r.readByte(); err != nil {
+			return 0, err
+		}
+	}
+	v, err := strconv.ParseUint(string(bs), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(v)) {
+		return 0, fmt.Errorf("invalid escape sequence \\%c%s", c, bs)
+	}
+	return rune(v), nil
+}
+
+// skip skips spaces and comments.
+func (r *Reader) skip() error {
+	for {
+		c, err := r.peek()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case isSpace(c):
+			r.readByte()
+		case c == '#':
+			for c != '\n' {
+				if c, err = r.readByte(); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// expect skips spaces and comments, and then reads the provided character.
+func (r *Reader) expect(e byte) error {
+	if err := r.skip(); err != nil {
+		return err
+	}
+	c, err := r.readByte()
+	if err != nil {
+		return err
+	}
+	if c != e {
+		return fmt.Errorf("expected %q; found %q", e, c)
+	}
+	return nil
+}
+
+func (r *Reader) peek() (byte, error) {
+	bs, err := r.r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	return bs[0], nil
+}
+
+func (r *Reader) readByte() (byte, error) {
+	c, err := r.r.ReadByte()
+	if err == nil && c == '\n' {
+		r.line++
+	}
+	return c, err
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// isNameByte returns true if the byte can be part of a name. Non ASCII bytes
+// are accepted as part of UTF-8 encoded letters.
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-' || c == ':' || c == '%' || c == '\\' || c >= utf8.RuneSelf
+}
@@ -0,0 +1,258 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package turtle
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func readAll(t *testing.T, doc string, batch int) ([]*triple.Triple, error) {
+	r := NewReader(strings.NewReader(doc), &triple.NTriplesMapping{
+		NodeTypes:       map[string]string{"http://example.org/people/": "/person"},
+		PredicatePrefix: "http://example.org/rel/",
+	}, literal.DefaultBuilder())
+	var ts []*triple.Triple
+	for {
+		b, err := r.Read(batch)
+		if err == io.EOF {
+			return ts, nil
+		}
+		if err != nil {
+			return ts, err
+		}
+		if len(b) > batch {
+			t.Errorf("turtle.Reader.Read returned %d triples; want at most %d", len(b), batch)
+		}
+		ts = append(ts, b...)
+	}
+}
+
+var blankRE = regexp.MustCompile(`/_<[^>]*>`)
+
+// tripleStrings returns the sorted strings of the provided triples. Blank node
+// IDs are replaced by b0, b1, ... in the order they first appear.
+func tripleStrings(ts []*triple.Triple) []string {
+	var ss []string
+	ids := make(map[string]string)
+	for _, t := range ts {
+		ss = append(ss, blankRE.ReplaceAllStringFunc(t.String(), func(b string) string {
+			if _, ok := ids[b]; !ok {
+				ids[b] = fmt.Sprintf("/_<b%d>", len(ids))
+			}
+			return ids[b]
+		}))
+	}
+	sort.Strings(ss)
+	return ss
+}
+
+func TestReader(t *testing.T) {
+	table := []struct {
+		doc  string
+		want []string
+	}{
+		{
+			doc: `
+				@prefix p: <http://example.org/people/> .
+				@prefix rel: <http://example.org/rel/> .
+				# A comment.
+				p:joe rel:knows p:mary, p:peter ;
+				      rel:age 42 ;
+				      rel:height 1.8 ;
+				      rel:weight 7.5e1 ;
+				      rel:alive true ;
+				      rel:name "Joe"@en ;
+				      rel:bio """Multi
+line""" ;
+				      rel:nick 'jo\'e' ;
+				      rel:born "2015-01-01"^^<http://www.w3.org/2001/XMLSchema#date> ;
+				      a p:Person .`,
+			want: []string{
+				"/person<joe>\t\"age\"@[]\t\"42\"^^type:int64",
+				"/person<joe>\t\"alive\"@[]\t\"true\"^^type:bool",
+				"/person<joe>\t\"bio\"@[]\t\"Multi\nline\"^^type:text",
+				"/person<joe>\t\"born\"@[]\t\"2015-01-01\"^^type:date",
+				"/person<joe>\t\"height\"@[]\t\"1.8\"^^type:decimal",
+				"/person<joe>\t\"http://www.w3.org/1999/02/22-rdf-syntax-ns#type\"@[]\t/person<Person>",
+				"/person<joe>\t\"knows\"@[]\t/person<mary>",
+				"/person<joe>\t\"knows\"@[]\t/person<peter>",
+				"/person<joe>\t\"name\"@[]\t\"Joe\"^^type:text",
+				"/person<joe>\t\"nick\"@[]\t\"jo'e\"^^type:text",
+				"/person<joe>\t\"weight\"@[]\t\"75\"^^type:float64",
+			},
+		},
+		{
+			doc: `
+				BASE <http://example.org/people/>
+				PREFIX : <http://example.org/rel/>
+				<joe> :knows <mary> .
+				@base <http://other.org/a/b> .
+				<../c> :knows _:x.
+				_:x :knows <#d> ;; .`,
+			want: []string{
+				"/_<b0>\t\"knows\"@[]\t/iri<http://other.org/a/b#d>",
+				"/iri<http://other.org/c>\t\"knows\"@[]\t/_<b0>",
+				"/person<joe>\t\"knows\"@[]\t/person<mary>",
+			},
+		},
+		{
+			doc:  `<http://a.org/s> <http://a.org/p> () .`,
+			want: []string{"/iri<http://a.org/s>\t\"http://a.org/p\"@[]\t/iri<http://www.w3.org/1999/02/22-rdf-syntax-ns#nil>"},
+		},
+		{
+			doc:  "",
+			want: nil,
+		},
+	}
+	for _, entry := range table {
+		for _, batch := range []int{1, 2, 100} {
+			ts, err := readAll(t, entry.doc, batch)
+			if err != nil {
+				t.Errorf("turtle.Reader failed to read %q with error %v", entry.doc, err)
+				continue
+			}
+			got := tripleStrings(ts)
+			if strings.Join(got, "\n") != strings.Join(entry.want, "\n") {
+				t.Errorf("turtle.Reader returned the wrong triples for %q; got %q, want %q", entry.doc, got, entry.want)
+			}
+		}
+	}
+}
+
+func TestReaderBlankNodes(t *testing.T) {
+	doc := `
+		@prefix rel: <http://example.org/rel/> .
+		<http://example.org/people/joe> rel:likes ( "a" [ rel:name "b" ] ) .
+		[ rel:name "c" ] .
+		[] rel:name "d" .`
+	ts, err := readAll(t, doc, 3)
+	if err != nil {
+		t.Fatalf("turtle.Reader failed with error %v", err)
+	}
+	// Two list cells with their first and rest, the likes triple, and the three
+	// blank nodes naming triples.
+	if got, want := len(ts), 8; got != want {
+		t.Fatalf("turtle.Reader returned the wrong number of triples; got %d, want %d\n%q", got, want, tripleStrings(ts))
+	}
+	next := make(map[string]*triple.Triple)
+	for _, tr := range ts {
+		next[tr.S().String()+string(tr.P().ID())] = tr
+	}
+	head := ts[len(ts)-1]
+	for _, tr := range ts {
+		if string(tr.P().ID()) == "likes" {
+			head = tr
+		}
+	}
+	n, err := head.O().Node()
+	if err != nil {
+		t.Fatalf("the list head should be a node; got %v", head.O())
+	}
+	var items []string
+	for n.ID().String() != "http://www.w3.org/1999/02/22-rdf-syntax-ns#nil" {
+		f, ok := next[n.String()+"http://www.w3.org/1999/02/22-rdf-syntax-ns#first"]
+		if !ok {
+			t.Fatalf("missing rdf:first for %v", n)
+		}
+		items = append(items, f.O().String())
+		r, ok := next[n.String()+"http://www.w3.org/1999/02/22-rdf-syntax-ns#rest"]
+		if !ok {
+			t.Fatalf("missing rdf:rest for %v", n)
+		}
+		if n, err = r.O().Node(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := len(items), 2; got != want || items[0] != `"a"^^type:text` {
+		t.Errorf("turtle.Reader returned the wrong list items; got %v", items)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	table := []string{
+		`<http://a.org/s> <http://a.org/p> <http://a.org/o>`,
+		`<http://a.org/s> <http://a.org/p> .`,
+		`x:s <http://a.org/p> <http://a.org/o> .`,
+		`@prefix x <http://a.org/> .`,
+		`@unknown <http://a.org/> .`,
+		`<http://a.org/s> <http://a.org/p> "unterminated .`,
+		`<http://a.org/s> <http://a.org/p> ( "a" .`,
+		`<http://a.org/s> <http://a.org/p> [ <http://a.org/q> "a" .`,
+		`<http://a.org/s> <http://a.org/p> "x"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+		`<http://a.org/s x> <http://a.org/p> <http://a.org/o> .`,
+	}
+	for _, doc := range table {
+		if _, err := readAll(t, doc, 10); err == nil || err == io.EOF {
+			t.Errorf("turtle.Reader should have failed to read %q", doc)
+		}
+	}
+	r := NewReader(strings.NewReader(""), nil, literal.DefaultBuilder())
+	if _, err := r.Read(0); err == nil {
+		t.Errorf("turtle.Reader.Read should have failed for an invalid batch size")
+	}
+}
+
+func TestReadIntoGraph(t *testing.T) {
+	doc := `
+		@prefix ex: <http://example.org/> .
+		ex:a ex:p ex:b, ex:c, ex:d .
+		ex:b ex:p ex:c .`
+	g, err := memory.NewStore().NewGraph("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnt, err := ReadIntoGraph(g, strings.NewReader(doc), nil, literal.DefaultBuilder(), 2)
+	if err != nil {
+		t.Fatalf("turtle.ReadIntoGraph failed with error %v", err)
+	}
+	if got, want := cnt, 4; got != want {
+		t.Errorf("turtle.ReadIntoGraph added the wrong number of triples; got %d, want %d", got, want)
+	}
+	ts, err := g.Triples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for range ts {
+		n++
+	}
+	if n != cnt {
+		t.Errorf("the graph contains %d triples; want %d", n, cnt)
+	}
+}
+
+func TestReaderBlankNodeScope(t *testing.T) {
+	doc := `_:x <http://a.org/p> "a" .`
+	t1, err := readAll(t, doc, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := readAll(t, doc, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1[0].S().String() == t2[0].S().String() {
+		t.Errorf("blank nodes of different documents should not collide; got %v twice", t1[0].S())
+	}
+}
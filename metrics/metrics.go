@@ -0,0 +1,276 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics provides optional instrumentation hooks for BadWolf. Events
+// are reported to a Collector, which servers can implement to forward them to
+// their monitoring system of choice, such as Prometheus, without the rest of
+// BadWolf depending on it. Counters provides a Collector that aggregates the
+// events and publishes them via expvar.
+package metrics
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/google/badwolf/bql/semantic"
+)
+
+// Operation identifies a storage operation.
+type Operation int8
+
+const (
+	// NewGraph operations create graphs.
+	NewGraph Operation = iota
+	// Graph operations retrieve existing graphs.
+	Graph
+	// DeleteGraph operations remove graphs.
+	DeleteGraph
+	// GraphNames operations list the graphs of a store.
+	GraphNames
+	// AddTriples operations add triples to a graph.
+	AddTriples
+	// RemoveTriples operations remove triples from a graph.
+	RemoveTriples
+	// Objects lookups retrieve the objects of a subject and predicate.
+	Objects
+	// Subjects lookups retrieve the subjects of a predicate and object.
+	Subjects
+	// PredicatesForSubject lookups retrieve the predicates of a subject.
+	PredicatesForSubject
+	// PredicatesForObject lookups retrieve the predicates of an object.
+	PredicatesForObject
+	// PredicatesForSubjectAndObject lookups retrieve the predicates linking a
+	// subject and an object.
+	PredicatesForSubjectAndObject
+	// TriplesForSubject lookups retrieve the triples of a subject.
+	TriplesForSubject
+	// TriplesForPredicate lookups retrieve the triples of a predicate.
+	TriplesForPredicate
+	// TriplesForObject lookups retrieve the triples of an object.
+	TriplesForObject
+	// TriplesForSubjectAndPredicate lookups retrieve the triples of a subject
+	// and predicate.
+	TriplesForSubjectAndPredicate
+	// TriplesForPredicateAndObject lookups retrieve the triples of a predicate
+	// and object.
+	TriplesForPredicateAndObject
+	// Exist lookups check if a triple is stored.
+	Exist
+	// Triples lookups retrieve all the triples of a graph.
+	Triples
+)
+
+// String returns a readable name for the operation.
+func (o Operation) String() string {
+	switch o {
+	case NewGraph:
+		return "NEW_GRAPH"
+	case Graph:
+		return "GRAPH"
+	case DeleteGraph:
+		return "DELETE_GRAPH"
+	case GraphNames:
+		return "GRAPH_NAMES"
+	case AddTriples:
+		return "ADD_TRIPLES"
+	case RemoveTriples:
+		return "REMOVE_TRIPLES"
+	case Objects:
+		return "OBJECTS"
+	case Subjects:
+		return "SUBJECTS"
+	case PredicatesForSubject:
+		return "PREDICATES_FOR_SUBJECT"
+	case PredicatesForObject:
+		return "PREDICATES_FOR_OBJECT"
+	case PredicatesForSubjectAndObject:
+		return "PREDICATES_FOR_SUBJECT_AND_OBJECT"
+	case TriplesForSubject:
+		return "TRIPLES_FOR_SUBJECT"
+	case TriplesForPredicate:
+		return "TRIPLES_FOR_PREDICATE"
+	case TriplesForObject:
+		return "TRIPLES_FOR_OBJECT"
+	case TriplesForSubjectAndPredicate:
+		return "TRIPLES_FOR_SUBJECT_AND_PREDICATE"
+	case TriplesForPredicateAndObject:
+		return "TRIPLES_FOR_PREDICATE_AND_OBJECT"
+	case Exist:
+		return "EXIST"
+	case Triples:
+		return "TRIPLES"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Collector receives the events reported by instrumented components.
+// Implementations must be safe for concurrent use and should return quickly,
+// since they are called inline.
+type Collector interface {
+	// Statement is called once a statement has been executed, with the number
+	// of rows it returned and the time it took. Failed statements report the
+	// error found.
+	Statement(t semantic.StatementType, rows int, d time.Duration, err error)
+
+	// StorageOperation is called once a storage operation returns. Lookups
+	// returning a channel are reported once the channel is drained and
+	// closed, so their latency includes producing all the results; lookups
+	// whose channels are never drained are not reported.
+	StorageOperation(op Operation, d time.Duration, err error)
+
+	// CacheLookup is called each time a parsed statement is looked up on a
+	// cache, reporting if it was found.
+	CacheLookup(hit bool)
+}
+
+// OperationStats contains the aggregated metrics of a kind of operation.
+type OperationStats struct {
+	Count   int64
+	Errors  int64
+	Latency time.Duration
+	Max     time.Duration
+}
+
+// MeanLatency returns the average latency of the operations.
+func (s OperationStats) MeanLatency() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Count)
+}
+
+// Snapshot contains the metrics aggregated by Counters at a point in time.
+// Statements and operations are keyed by their readable names.
+type Snapshot struct {
+	Statements  map[string]OperationStats
+	Rows        int64
+	Operations  map[string]OperationStats
+	CacheHits   int64
+	CacheMisses int64
+}
+
+// CacheHitRate returns the fraction of cache lookups that found the statement.
+func (s *Snapshot) CacheHitRate() float64 {
+	if n := s.CacheHits + s.CacheMisses; n > 0 {
+		return float64(s.CacheHits) / float64(n)
+	}
+	return 0
+}
+
+// Counters is a Collector that aggregates the reported events in memory. It
+// is safe for concurrent use.
+type Counters struct {
+	mu sync.Mutex
+	s  Snapshot
+}
+
+// NewCounters returns a new empty set of counters.
+func NewCounters() *Counters {
+	return &Counters{
+		s: Snapshot{
+			Statements: make(map[string]OperationStats),
+			Operations: make(map[string]OperationStats),
+		},
+	}
+}
+
+// add records an operation on the provided map.
+func add(m map[string]OperationStats, k string, d time.Duration, err error) {
+	s := m[k]
+	s.Count++
+	if err != nil {
+		s.Errors++
+	}
+	s.Latency += d
+	if d > s.Max {
+		s.Max = d
+	}
+	m[k] = s
+}
+
+// Statement records an executed statement.
+func (c *Counters) Statement(t semantic.StatementType, rows int, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	add(c.s.Statements, t.String(), d, err)
+	c.s.Rows += int64(rows)
+}
+
+// StorageOperation records a storage operation.
+func (c *Counters) StorageOperation(op Operation, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	add(c.s.Operations, op.String(), d, err)
+}
+
+// CacheLookup records a cache lookup.
+func (c *Counters) CacheLookup(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.s.CacheHits++
+	} else {
+		c.s.CacheMisses++
+	}
+}
+
+// Snapshot returns a copy of the current metrics.
+func (c *Counters) Snapshot() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.s
+	s.Statements = make(map[string]OperationStats, len(c.s.Statements))
+	for k, v := range c.s.Statements {
+		s.Statements[k] = v
+	}
+	s.Operations = make(map[string]OperationStats, len(c.s.Operations))
+	for k, v := range c.s.Operations {
+		s.Operations[k] = v
+	}
+	return &s
+}
+
+// Var returns an expvar variable exposing the current metrics, to be
+// published as in expvar.Publish("badwolf", c.Var()). Latencies are reported
+// in seconds.
+func (c *Counters) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		s := c.Snapshot()
+		return map[string]interface{}{
+			"statements":     statsVar(s.Statements),
+			"rows":           s.Rows,
+			"operations":     statsVar(s.Operations),
+			"cache_hits":     s.CacheHits,
+			"cache_misses":   s.CacheMisses,
+			"cache_hit_rate": s.CacheHitRate(),
+		}
+	})
+}
+
+// statsVar returns the readable representation of the provided stats.
+func statsVar(m map[string]OperationStats) map[string]interface{} {
+	res := make(map[string]interface{}, len(m))
+	for k, s := range m {
+		res[k] = map[string]interface{}{
+			"count":                s.Count,
+			"errors":               s.Errors,
+			"latency_seconds":      s.Latency.Seconds(),
+			"mean_latency_seconds": s.MeanLatency().Seconds(),
+			"max_latency_seconds":  s.Max.Seconds(),
+		}
+	}
+	return res
+}
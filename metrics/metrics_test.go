@@ -0,0 +1,145 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestCounters(t *testing.T) {
+	c := NewCounters()
+	c.Statement(semantic.Query, 3, time.Second, nil)
+	c.Statement(semantic.Query, 0, 3*time.Second, errors.New("boom"))
+	c.Statement(semantic.Insert, 0, time.Second, nil)
+	c.StorageOperation(Objects, time.Millisecond, nil)
+	c.CacheLookup(true)
+	c.CacheLookup(true)
+	c.CacheLookup(true)
+	c.CacheLookup(false)
+
+	s := c.Snapshot()
+	want := OperationStats{Count: 2, Errors: 1, Latency: 4 * time.Second, Max: 3 * time.Second}
+	if got := s.Statements["QUERY"]; got != want {
+		t.Errorf("Counters.Snapshot() queries = %+v; want %+v", got, want)
+	}
+	if got, want := s.Statements["QUERY"].MeanLatency(), 2*time.Second; got != want {
+		t.Errorf("OperationStats.MeanLatency() = %v; want %v", got, want)
+	}
+	if s.Statements["INSERT"].Count != 1 || s.Operations["OBJECTS"].Count != 1 || s.Rows != 3 {
+		t.Errorf("Counters.Snapshot() = %+v; missing events", s)
+	}
+	if got, want := s.CacheHitRate(), 0.75; got != want {
+		t.Errorf("Snapshot.CacheHitRate() = %v; want %v", got, want)
+	}
+
+	// Snapshots are not affected by later events.
+	c.Statement(semantic.Query, 1, time.Second, nil)
+	if s.Statements["QUERY"].Count != 2 {
+		t.Errorf("Counters.Snapshot() should return a copy of the metrics")
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(c.Var().String()), &v); err != nil {
+		t.Fatalf("Counters.Var() returned invalid JSON; %v", err)
+	}
+	if v["rows"] != float64(4) || v["cache_hit_rate"] != 0.75 {
+		t.Errorf("Counters.Var() = %v; want 4 rows and a 0.75 cache hit rate", v)
+	}
+}
+
+func TestStore(t *testing.T) {
+	c := NewCounters()
+	s := Store(memory.NewStore(), c)
+	g, err := s.NewGraph("?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl, err := triple.ParseTriple(`/u<joe>	"knows"@[]	/u<mary>`, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddTriples([]*triple.Triple{tpl}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := g.Exist(tpl); !ok || err != nil {
+		t.Errorf("Graph.Exist returned %v, %v; want true", ok, err)
+	}
+	if _, err := s.Graph("?missing"); err == nil {
+		t.Errorf("Store.Graph should have failed for a missing graph")
+	}
+	ids, err := s.(storage.GraphLister).GraphNames()
+	if err != nil || len(ids) != 1 {
+		t.Errorf("GraphLister.GraphNames returned %v, %v; want [?test]", ids, err)
+	}
+	if _, ok := g.(storage.Watcher); !ok {
+		t.Errorf("instrumented graphs should provide the change feed of the memory graphs")
+	}
+	if err := g.(storage.StatsKeeper).SetStats(&storage.GraphStats{Triples: 1}); err != nil {
+		t.Errorf("StatsKeeper.SetStats failed; %v", err)
+	}
+
+	ops := c.Snapshot().Operations
+	for op, want := range map[Operation]OperationStats{
+		NewGraph:   {Count: 1},
+		AddTriples: {Count: 1},
+		Exist:      {Count: 1},
+		Graph:      {Count: 1, Errors: 1},
+		GraphNames: {Count: 1},
+	} {
+		got := ops[op.String()]
+		if got.Count != want.Count || got.Errors != want.Errors {
+			t.Errorf("Store reported %d %s operations with %d errors; want %d and %d", got.Count, op, got.Errors, want.Count, want.Errors)
+		}
+	}
+}
+
+func TestStoreLookupLatency(t *testing.T) {
+	c := NewCounters()
+	s := &store{s: memory.NewStore(), c: c}
+	tpl, err := triple.ParseTriple(`/u<joe>	"knows"@[]	/u<mary>`, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := make(chan *triple.Triple)
+	res, err := s.triples(Triples, time.Now(), ts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		ts <- tpl
+		close(ts)
+	}()
+	n := 0
+	for range res {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("the instrumented lookup returned %d triples; want 1", n)
+	}
+	got := c.Snapshot().Operations[Triples.String()]
+	if got.Count != 1 || got.Latency < 20*time.Millisecond {
+		t.Errorf("Store reported %d lookups taking %v; want 1 taking at least 20ms", got.Count, got.Latency)
+	}
+}
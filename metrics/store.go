@@ -0,0 +1,355 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// Store returns a store that reports to the collector the kind, latency, and
+// outcome of every operation run against the provided store and its graphs.
+// Lookups are reported once the channels they return are closed, so their
+// latency covers producing all their results. The returned store lists graphs,
+// and its graphs provide change feeds, only if the wrapped ones do. The rest
+// of the optional storage interfaces are always implemented and forwarded when
+// supported.
+func Store(s storage.Store, c Collector) storage.Store {
+	ws := &store{s: s, c: c}
+	if _, ok := s.(storage.GraphLister); ok {
+		return &listingStore{ws}
+	}
+	return ws
+}
+
+// store reports the operations of a store.
+type store struct {
+	s storage.Store
+	c Collector
+}
+
+// done reports the operation started at the provided time.
+func (s *store) done(op Operation, start time.Time, err error) {
+	s.c.StorageOperation(op, time.Since(start), err)
+}
+
+// triples forwards the triples returned by a lookup, reporting the lookup once
+// they are all received and the wrapped channel is closed.
+func (s *store) triples(op Operation, start time.Time, ts storage.Triples, err error) (storage.Triples, error) {
+	if err != nil {
+		s.done(op, start, err)
+		return ts, err
+	}
+	res := make(chan *triple.Triple, cap(ts))
+	go func() {
+		for t := range ts {
+			res <- t
+		}
+		s.done(op, start, nil)
+		close(res)
+	}()
+	return res, nil
+}
+
+// nodes forwards the nodes returned by a lookup; see triples.
+func (s *store) nodes(op Operation, start time.Time, ns storage.Nodes, err error) (storage.Nodes, error) {
+	if err != nil {
+		s.done(op, start, err)
+		return ns, err
+	}
+	res := make(chan *node.Node, cap(ns))
+	go func() {
+		for n := range ns {
+			res <- n
+		}
+		s.done(op, start, nil)
+		close(res)
+	}()
+	return res, nil
+}
+
+// predicates forwards the predicates returned by a lookup; see triples.
+func (s *store) predicates(op Operation, start time.Time, ps storage.Predicates, err error) (storage.Predicates, error) {
+	if err != nil {
+		s.done(op, start, err)
+		return ps, err
+	}
+	res := make(chan *predicate.Predicate, cap(ps))
+	go func() {
+		for p := range ps {
+			res <- p
+		}
+		s.done(op, start, nil)
+		close(res)
+	}()
+	return res, nil
+}
+
+// objects forwards the objects returned by a lookup; see triples.
+func (s *store) objects(op Operation, start time.Time, obs storage.Objects, err error) (storage.Objects, error) {
+	if err != nil {
+		s.done(op, start, err)
+		return obs, err
+	}
+	res := make(chan *triple.Object, cap(obs))
+	go func() {
+		for o := range obs {
+			res <- o
+		}
+		s.done(op, start, nil)
+		close(res)
+	}()
+	return res, nil
+}
+
+// graph returns the instrumented version of the provided graph.
+func (s *store) graph(g storage.Graph) storage.Graph {
+	wg := &graph{g: g, s: s}
+	if _, ok := g.(storage.Watcher); ok {
+		return &watchedGraph{wg}
+	}
+	return wg
+}
+
+// Name returns the name of the wrapped store.
+func (s *store) Name() string {
+	return s.s.Name()
+}
+
+// Version returns the version of the wrapped store.
+func (s *store) Version() string {
+	return s.s.Version()
+}
+
+// NewGraph creates a new graph.
+func (s *store) NewGraph(id string) (storage.Graph, error) {
+	start := time.Now()
+	g, err := s.s.NewGraph(id)
+	s.done(NewGraph, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return s.graph(g), nil
+}
+
+// Graph returns an existing graph.
+func (s *store) Graph(id string) (storage.Graph, error) {
+	start := time.Now()
+	g, err := s.s.Graph(id)
+	s.done(Graph, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return s.graph(g), nil
+}
+
+// DeleteGraph deletes an existing graph.
+func (s *store) DeleteGraph(id string) error {
+	start := time.Now()
+	err := s.s.DeleteGraph(id)
+	s.done(DeleteGraph, start, err)
+	return err
+}
+
+// CheckHealth checks the health of the wrapped store, if it supports it.
+func (s *store) CheckHealth() error {
+	if hc, ok := s.s.(storage.HealthChecker); ok {
+		return hc.CheckHealth()
+	}
+	return nil
+}
+
+// listingStore reports the operations of a store able to list its graphs.
+type listingStore struct {
+	*store
+}
+
+// GraphNames returns the IDs of all the graphs in the store.
+func (s *listingStore) GraphNames() ([]string, error) {
+	start := time.Now()
+	ids, err := s.s.(storage.GraphLister).GraphNames()
+	s.done(GraphNames, start, err)
+	return ids, err
+}
+
+// graph reports the operations of a graph.
+type graph struct {
+	g storage.Graph
+	s *store
+}
+
+// ID returns the id of the graph.
+func (g *graph) ID() string {
+	return g.g.ID()
+}
+
+// AddTriples adds the triples to the graph.
+func (g *graph) AddTriples(ts []*triple.Triple) error {
+	start := time.Now()
+	err := g.g.AddTriples(ts)
+	g.s.done(AddTriples, start, err)
+	return err
+}
+
+// RemoveTriples removes the triples from the graph.
+func (g *graph) RemoveTriples(ts []*triple.Triple) error {
+	start := time.Now()
+	err := g.g.RemoveTriples(ts)
+	g.s.done(RemoveTriples, start, err)
+	return err
+}
+
+// Objects returns the objects for the given subject and predicate.
+func (g *graph) Objects(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Objects, error) {
+	start := time.Now()
+	res, err := g.g.Objects(s, p, lo)
+	return g.s.objects(Objects, start, res, err)
+}
+
+// Subjects returns the subjects for the given predicate and object.
+func (g *graph) Subjects(p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions) (storage.Nodes, error) {
+	start := time.Now()
+	res, err := g.g.Subjects(p, o, lo)
+	return g.s.nodes(Subjects, start, res, err)
+}
+
+// PredicatesForSubject returns the predicates of the given subject.
+func (g *graph) PredicatesForSubject(s *node.Node, lo *storage.LookupOptions) (storage.Predicates, error) {
+	start := time.Now()
+	res, err := g.g.PredicatesForSubject(s, lo)
+	return g.s.predicates(PredicatesForSubject, start, res, err)
+}
+
+// PredicatesForObject returns the predicates of the given object.
+func (g *graph) PredicatesForObject(o *triple.Object, lo *storage.LookupOptions) (storage.Predicates, error) {
+	start := time.Now()
+	res, err := g.g.PredicatesForObject(o, lo)
+	return g.s.predicates(PredicatesForObject, start, res, err)
+}
+
+// PredicatesForSubjectAndObject returns the predicates linking the given
+// subject and object.
+func (g *graph) PredicatesForSubjectAndObject(s *node.Node, o *triple.Object, lo *storage.LookupOptions) (storage.Predicates, error) {
+	start := time.Now()
+	res, err := g.g.PredicatesForSubjectAndObject(s, o, lo)
+	return g.s.predicates(PredicatesForSubjectAndObject, start, res, err)
+}
+
+// TriplesForSubject returns the triples of the given subject.
+func (g *graph) TriplesForSubject(s *node.Node, lo *storage.LookupOptions) (storage.Triples, error) {
+	start := time.Now()
+	res, err := g.g.TriplesForSubject(s, lo)
+	return g.s.triples(TriplesForSubject, start, res, err)
+}
+
+// TriplesForPredicate returns the triples of the given predicate.
+func (g *graph) TriplesForPredicate(p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	start := time.Now()
+	res, err := g.g.TriplesForPredicate(p, lo)
+	return g.s.triples(TriplesForPredicate, start, res, err)
+}
+
+// TriplesForObject returns the triples of the given object.
+func (g *graph) TriplesForObject(o *triple.Object, lo *storage.LookupOptions) (storage.Triples, error) {
+	start := time.Now()
+	res, err := g.g.TriplesForObject(o, lo)
+	return g.s.triples(TriplesForObject, start, res, err)
+}
+
+// TriplesForSubjectAndPredicate returns the triples of the given subject and
+// predicate.
+func (g *graph) TriplesForSubjectAndPredicate(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	start := time.Now()
+	res, err := g.g.TriplesForSubjectAndPredicate(s, p, lo)
+	return g.s.triples(TriplesForSubjectAndPredicate, start, res, err)
+}
+
+// TriplesForPredicateAndObject returns the triples of the given predicate and
+// object.
+func (g *graph) TriplesForPredicateAndObject(p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions) (storage.Triples, error) {
+	start := time.Now()
+	res, err := g.g.TriplesForPredicateAndObject(p, o, lo)
+	return g.s.triples(TriplesForPredicateAndObject, start, res, err)
+}
+
+// Exist checks if the provided triple is stored on the graph.
+func (g *graph) Exist(t *triple.Triple) (bool, error) {
+	start := time.Now()
+	ok, err := g.g.Exist(t)
+	g.s.done(Exist, start, err)
+	return ok, err
+}
+
+// Triples returns all the triples of the graph.
+func (g *graph) Triples() (storage.Triples, error) {
+	start := time.Now()
+	res, err := g.g.Triples()
+	return g.s.triples(Triples, start, res, err)
+}
+
+// Prefetch forwards the hints to the wrapped graph, if it supports them.
+func (g *graph) Prefetch(h *storage.PrefetchHints) error {
+	if pf, ok := g.g.(storage.Prefetcher); ok {
+		return pf.Prefetch(h)
+	}
+	return nil
+}
+
+// SetStats stores the statistics on the wrapped graph, if it supports it.
+func (g *graph) SetStats(s *storage.GraphStats) error {
+	if k, ok := g.g.(storage.StatsKeeper); ok {
+		return k.SetStats(s)
+	}
+	return fmt.Errorf("metrics: graph %q does not support storing statistics", g.ID())
+}
+
+// Stats returns the statistics stored on the wrapped graph, if any.
+func (g *graph) Stats() (*storage.GraphStats, error) {
+	if k, ok := g.g.(storage.StatsKeeper); ok {
+		return k.Stats()
+	}
+	return nil, nil
+}
+
+// SetValidator sets the validator of the wrapped graph, if it supports it.
+func (g *graph) SetValidator(v triple.Validator) error {
+	if ve, ok := g.g.(storage.ValidationEnforcer); ok {
+		return ve.SetValidator(v)
+	}
+	return fmt.Errorf("metrics: graph %q does not support validating triples", g.ID())
+}
+
+// Validator returns the validator of the wrapped graph, if any.
+func (g *graph) Validator() triple.Validator {
+	if ve, ok := g.g.(storage.ValidationEnforcer); ok {
+		return ve.Validator()
+	}
+	return nil
+}
+
+// watchedGraph reports the operations of a graph providing a change feed.
+type watchedGraph struct {
+	*graph
+}
+
+// Watch registers the function on the change feed of the wrapped graph.
+func (g *watchedGraph) Watch(f func(c *storage.Change)) (func(), error) {
+	return g.g.(storage.Watcher).Watch(f)
+}
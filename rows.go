@@ -0,0 +1,193 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badwolf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// Rows iterates over the rows returned by a query. Rows are not safe for
+// concurrent use.
+type Rows struct {
+	rs   planner.ResultStream
+	cols []string
+	n    int
+
+	// done is called the first time the rows are closed.
+	done func(n int, err error)
+}
+
+// Columns returns the bindings of the returned rows, in the order they are
+// listed on the select clause.
+func (r *Rows) Columns() []string {
+	return r.cols
+}
+
+// Next advances to the next row. It returns false once all rows have been
+// returned or an error is found; Err tells both cases apart.
+func (r *Rows) Next() bool {
+	if !r.rs.Next() {
+		return false
+	}
+	r.n++
+	return true
+}
+
+// Row returns the current row.
+func (r *Rows) Row() table.Row {
+	return r.rs.Row()
+}
+
+// Scan copies the values of the current row into the provided destinations,
+// one per column. Supported destinations are pointers to string, int64,
+// float64, bool, time.Time, interface{}, and to *node.Node,
+// *predicate.Predicate, *literal.Literal, *triple.Triple, and *table.Cell.
+// Null values leave the destination untouched.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.cols) {
+		return fmt.Errorf("badwolf.Rows.Scan: expected %d destinations, got %d", len(r.cols), len(dest))
+	}
+	row := r.rs.Row()
+	for i, d := range dest {
+		if err := scan(row[r.cols[i]], d); err != nil {
+			return fmt.Errorf("badwolf.Rows.Scan: column %s; %v", r.cols[i], err)
+		}
+	}
+	return nil
+}
+
+// Err returns the error found while iterating, if any.
+func (r *Rows) Err() error {
+	return r.rs.Err()
+}
+
+// Close releases the resources held by the rows. It is safe to call it more
+// than once.
+func (r *Rows) Close() error {
+	err := r.rs.Close()
+	if r.done != nil {
+		r.done(r.n, r.rs.Err())
+		r.done = nil
+	}
+	return err
+}
+
+// scan copies the value of the cell into the provided destination.
+func scan(c *table.Cell, dest interface{}) error {
+	if c2, ok := dest.(**table.Cell); ok {
+		*c2 = c
+		return nil
+	}
+	if c.IsNull() {
+		return nil
+	}
+	switch d := dest.(type) {
+	case *interface{}:
+		*d = value(c)
+	case *string:
+		switch {
+		case c.L != nil && c.L.Type() == literal.Text:
+			s, err := c.L.Text()
+			if err != nil {
+				return err
+			}
+			*d = s
+		default:
+			*d = c.String()
+		}
+	case **node.Node:
+		if c.N == nil {
+			return fmt.Errorf("cannot scan %s into a node", c)
+		}
+		*d = c.N
+	case **predicate.Predicate:
+		if c.P == nil {
+			return fmt.Errorf("cannot scan %s into a predicate", c)
+		}
+		*d = c.P
+	case **literal.Literal:
+		if c.L == nil {
+			return fmt.Errorf("cannot scan %s into a literal", c)
+		}
+		*d = c.L
+	case **triple.Triple:
+		if c.E == nil {
+			return fmt.Errorf("cannot scan %s into a triple", c)
+		}
+		*d = c.E
+	case *time.Time:
+		if c.T == nil {
+			return fmt.Errorf("cannot scan %s into a time", c)
+		}
+		*d = *c.T
+	case *int64:
+		if c.L == nil || c.L.Type() != literal.Int64 {
+			return fmt.Errorf("cannot scan %s into an int64", c)
+		}
+		v, err := c.L.Int64()
+		if err != nil {
+			return err
+		}
+		*d = v
+	case *float64:
+		if c.L == nil || !c.L.IsNumeric() {
+			return fmt.Errorf("cannot scan %s into a float64", c)
+		}
+		v, err := c.L.AsFloat64()
+		if err != nil {
+			return err
+		}
+		*d = v
+	case *bool:
+		if c.L == nil || c.L.Type() != literal.Bool {
+			return fmt.Errorf("cannot scan %s into a bool", c)
+		}
+		v, err := c.L.Bool()
+		if err != nil {
+			return err
+		}
+		*d = v
+	default:
+		return fmt.Errorf("unsupported destination type %T", dest)
+	}
+	return nil
+}
+
+// value returns the Go value held by a non null cell. Literals are returned
+// as their Go value, everything else as is.
+func value(c *table.Cell) interface{} {
+	switch {
+	case c.S != "":
+		return c.S
+	case c.N != nil:
+		return c.N
+	case c.P != nil:
+		return c.P
+	case c.L != nil:
+		return c.L.Interface()
+	case c.T != nil:
+		return *c.T
+	default:
+		return c.E
+	}
+}
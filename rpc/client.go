@@ -0,0 +1,153 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"context"
+	"io"
+)
+
+// Client is a client of a service, either running in-process or remotely over
+// gRPC. Both kinds of clients return the same rows and errors, so code written
+// against one can switch to the other.
+type Client struct {
+	svc    *Service
+	buffer int
+	remote remoteService
+}
+
+// remoteService calls a service running in another process. It is implemented
+// over gRPC when the package is built with the grpc build tag.
+type remoteService interface {
+	ExecuteQuery(ctx context.Context, req *QueryRequest) (*RowStream, error)
+	Mutate(ctx context.Context, req *MutateRequest) (*MutateResponse, error)
+	CreateGraph(ctx context.Context, req *GraphRequest) (*GraphResponse, error)
+	DropGraph(ctx context.Context, req *GraphRequest) (*GraphResponse, error)
+	ListGraphs(ctx context.Context, req *ListGraphsRequest) (*ListGraphsResponse, error)
+}
+
+// NewLocalClient returns a client that calls the provided service directly.
+// Up to buffer rows are produced ahead of the ones received; the query stalls
+// once the buffer is full until more rows are received.
+func NewLocalClient(svc *Service, buffer int) *Client {
+	if buffer < 0 {
+		buffer = 0
+	}
+	return &Client{svc: svc, buffer: buffer}
+}
+
+// RowStream is the client side of the ExecuteQuery stream.
+type RowStream struct {
+	ctx    context.Context
+	rows   chan *Row
+	err    error
+	done   chan struct{}
+	remote interface {
+		Recv() (*Row, error)
+	}
+}
+
+// Recv returns the next row of the stream. It returns io.EOF once all the rows
+// have been received, or the error that ended the query. No more rows are
+// returned once the context of the call is done.
+func (s *RowStream) Recv() (*Row, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.remote != nil {
+		return s.remote.Recv()
+	}
+	if r, ok := <-s.rows; ok {
+		return r, nil
+	}
+	<-s.done
+	if s.err != nil {
+		return nil, s.err
+	}
+	return nil, io.EOF
+}
+
+// rowSender implements RowSender on top of the channel of a row stream.
+type rowSender struct {
+	ctx  context.Context
+	rows chan<- *Row
+}
+
+// Context returns the context of the call.
+func (s *rowSender) Context() context.Context {
+	return s.ctx
+}
+
+// Send blocks until the row is buffered or the call is cancelled.
+func (s *rowSender) Send(r *Row) error {
+	select {
+	case s.rows <- r:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// ExecuteQuery runs the query and returns the stream of its rows. Cancelling
+// the context stops the query; it must be cancelled if the caller stops
+// receiving rows before the stream ends.
+func (c *Client) ExecuteQuery(ctx context.Context, req *QueryRequest) (*RowStream, error) {
+	if c.remote != nil {
+		return c.remote.ExecuteQuery(ctx, req)
+	}
+	rs := &RowStream{
+		ctx:  ctx,
+		rows: make(chan *Row, c.buffer),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(rs.done)
+		defer close(rs.rows)
+		rs.err = c.svc.ExecuteQuery(req, &rowSender{ctx: ctx, rows: rs.rows})
+	}()
+	return rs, nil
+}
+
+// Mutate calls the Mutate method of the service.
+func (c *Client) Mutate(ctx context.Context, req *MutateRequest) (*MutateResponse, error) {
+	if c.remote != nil {
+		return c.remote.Mutate(ctx, req)
+	}
+	return c.svc.Mutate(ctx, req)
+}
+
+// CreateGraph calls the CreateGraph method of the service.
+func (c *Client) CreateGraph(ctx context.Context, req *GraphRequest) (*GraphResponse, error) {
+	if c.remote != nil {
+		return c.remote.CreateGraph(ctx, req)
+	}
+	return c.svc.CreateGraph(ctx, req)
+}
+
+// DropGraph calls the DropGraph method of the service.
+func (c *Client) DropGraph(ctx context.Context, req *GraphRequest) (*GraphResponse, error) {
+	if c.remote != nil {
+		return c.remote.DropGraph(ctx, req)
+	}
+	return c.svc.DropGraph(ctx, req)
+}
+
+// ListGraphs calls the ListGraphs method of the service.
+func (c *Client) ListGraphs(ctx context.Context, req *ListGraphsRequest) (*ListGraphsResponse, error) {
+	if c.remote != nil {
+		return c.remote.ListGraphs(ctx, req)
+	}
+	return c.svc.ListGraphs(ctx, req)
+}
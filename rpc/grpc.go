@@ -0,0 +1,144 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build grpc
+
+package rpc
+
+import (
+	"context"
+
+	"github.com/google/badwolf/rpc/rpcpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer exposes a service over gRPC, converting the generated messages
+// into the ones of the service and back.
+type grpcServer struct {
+	rpcpb.UnimplementedQueryServiceServer
+	svc *Service
+}
+
+// RegisterGRPC registers the service as the QueryService of the provided gRPC
+// server. Errors are returned as gRPC statuses with the codes of the service
+// errors.
+func RegisterGRPC(s grpc.ServiceRegistrar, svc *Service) {
+	rpcpb.RegisterQueryServiceServer(s, &grpcServer{svc: svc})
+}
+
+// grpcRowSender implements RowSender on top of a gRPC stream.
+type grpcRowSender struct {
+	stream rpcpb.QueryService_ExecuteQueryServer
+}
+
+// Context returns the context of the call.
+func (s *grpcRowSender) Context() context.Context {
+	return s.stream.Context()
+}
+
+// Send converts the row and sends it over the stream.
+func (s *grpcRowSender) Send(r *Row) error {
+	return s.stream.Send(rowProto(r))
+}
+
+// ExecuteQuery runs the query and streams its rows.
+func (s *grpcServer) ExecuteQuery(req *rpcpb.QueryRequest, stream rpcpb.QueryService_ExecuteQueryServer) error {
+	err := s.svc.ExecuteQuery(&QueryRequest{Query: req.GetQuery(), MaxRows: req.GetMaxRows()}, &grpcRowSender{stream: stream})
+	return statusError(err)
+}
+
+// Mutate deletes and inserts the requested triples.
+func (s *grpcServer) Mutate(ctx context.Context, req *rpcpb.MutateRequest) (*rpcpb.MutateResponse, error) {
+	res, err := s.svc.Mutate(ctx, &MutateRequest{Graphs: req.GetGraphs(), Insert: req.GetInsert(), Delete: req.GetDelete()})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &rpcpb.MutateResponse{Inserted: res.Inserted, Deleted: res.Deleted}, nil
+}
+
+// CreateGraph creates the requested graph.
+func (s *grpcServer) CreateGraph(ctx context.Context, req *rpcpb.GraphRequest) (*rpcpb.GraphResponse, error) {
+	if _, err := s.svc.CreateGraph(ctx, &GraphRequest{Name: req.GetName()}); err != nil {
+		return nil, statusError(err)
+	}
+	return &rpcpb.GraphResponse{}, nil
+}
+
+// DropGraph drops the requested graph.
+func (s *grpcServer) DropGraph(ctx context.Context, req *rpcpb.GraphRequest) (*rpcpb.GraphResponse, error) {
+	if _, err := s.svc.DropGraph(ctx, &GraphRequest{Name: req.GetName()}); err != nil {
+		return nil, statusError(err)
+	}
+	return &rpcpb.GraphResponse{}, nil
+}
+
+// ListGraphs lists the graphs in the store.
+func (s *grpcServer) ListGraphs(ctx context.Context, req *rpcpb.ListGraphsRequest) (*rpcpb.ListGraphsResponse, error) {
+	res, err := s.svc.ListGraphs(ctx, &ListGraphsRequest{})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &rpcpb.ListGraphsResponse{Names: res.Names}, nil
+}
+
+// statusError converts the errors returned by the service into gRPC statuses.
+// Errors that already are statuses, such as the ones returned by the stream,
+// are returned unchanged.
+func statusError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case context.Canceled, context.DeadlineExceeded:
+		return status.FromContextError(err).Err()
+	}
+	if e, ok := err.(*Error); ok {
+		return status.Error(codes.Code(e.Code), e.Message)
+	}
+	return err
+}
+
+// serviceError converts the statuses returned by a remote service back into
+// service errors. Statuses whose code the service does not use are returned
+// unchanged.
+func serviceError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch c := Code(s.Code()); c {
+	case InvalidArgument, NotFound, AlreadyExists, PermissionDenied, ResourceExhausted, Unimplemented, Internal:
+		return &Error{Code: c, Message: s.Message()}
+	}
+	return err
+}
+
+// rowProto converts a row into its generated message.
+func rowProto(r *Row) *rpcpb.Row {
+	m := &rpcpb.Row{Cells: make(map[string]*rpcpb.Value, len(r.Cells))}
+	for b, v := range r.Cells {
+		m.Cells[b] = &rpcpb.Value{Kind: rpcpb.Value_Kind(v.Kind), Text: v.Text}
+	}
+	return m
+}
+
+// rowFromProto converts a generated row message into a row.
+func rowFromProto(m *rpcpb.Row) *Row {
+	r := &Row{Cells: make(map[string]*Value, len(m.GetCells()))}
+	for b, v := range m.GetCells() {
+		r.Cells[b] = &Value{Kind: ValueKind(v.GetKind()), Text: v.GetText()}
+	}
+	return r
+}
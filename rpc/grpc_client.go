@@ -0,0 +1,98 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build grpc
+
+package rpc
+
+import (
+	"context"
+	"io"
+
+	"github.com/google/badwolf/rpc/rpcpb"
+	"google.golang.org/grpc"
+)
+
+// NewClient returns a client that calls the service registered via
+// RegisterGRPC on the server the provided connection points to. Errors
+// returned by the service are converted back into service errors.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{remote: &grpcRemote{c: rpcpb.NewQueryServiceClient(cc)}}
+}
+
+// grpcRemote calls a service over gRPC, converting the messages of the
+// service into the generated ones and back.
+type grpcRemote struct {
+	c rpcpb.QueryServiceClient
+}
+
+// grpcRowReceiver receives the rows of a gRPC stream.
+type grpcRowReceiver struct {
+	stream rpcpb.QueryService_ExecuteQueryClient
+}
+
+// Recv receives and converts the next row of the stream.
+func (r *grpcRowReceiver) Recv() (*Row, error) {
+	m, err := r.stream.Recv()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return rowFromProto(m), nil
+}
+
+// ExecuteQuery calls the ExecuteQuery method of the remote service.
+func (c *grpcRemote) ExecuteQuery(ctx context.Context, req *QueryRequest) (*RowStream, error) {
+	stream, err := c.c.ExecuteQuery(ctx, &rpcpb.QueryRequest{Query: req.Query, MaxRows: req.MaxRows})
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return &RowStream{ctx: ctx, remote: &grpcRowReceiver{stream: stream}}, nil
+}
+
+// Mutate calls the Mutate method of the remote service.
+func (c *grpcRemote) Mutate(ctx context.Context, req *MutateRequest) (*MutateResponse, error) {
+	res, err := c.c.Mutate(ctx, &rpcpb.MutateRequest{Graphs: req.Graphs, Insert: req.Insert, Delete: req.Delete})
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return &MutateResponse{Inserted: res.GetInserted(), Deleted: res.GetDeleted()}, nil
+}
+
+// CreateGraph calls the CreateGraph method of the remote service.
+func (c *grpcRemote) CreateGraph(ctx context.Context, req *GraphRequest) (*GraphResponse, error) {
+	if _, err := c.c.CreateGraph(ctx, &rpcpb.GraphRequest{Name: req.Name}); err != nil {
+		return nil, serviceError(err)
+	}
+	return &GraphResponse{}, nil
+}
+
+// DropGraph calls the DropGraph method of the remote service.
+func (c *grpcRemote) DropGraph(ctx context.Context, req *GraphRequest) (*GraphResponse, error) {
+	if _, err := c.c.DropGraph(ctx, &rpcpb.GraphRequest{Name: req.Name}); err != nil {
+		return nil, serviceError(err)
+	}
+	return &GraphResponse{}, nil
+}
+
+// ListGraphs calls the ListGraphs method of the remote service.
+func (c *grpcRemote) ListGraphs(ctx context.Context, req *ListGraphsRequest) (*ListGraphsResponse, error) {
+	res, err := c.c.ListGraphs(ctx, &rpcpb.ListGraphsRequest{})
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return &ListGraphsResponse{Names: res.GetNames()}, nil
+}
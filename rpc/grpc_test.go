@@ -0,0 +1,82 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build grpc
+
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func init() {
+	testTransports["grpc"] = grpcClient
+}
+
+// grpcClient serves the service over an in-memory gRPC connection and returns
+// a client for it. The server is stopped once the test ends.
+func grpcClient(t *testing.T, svc *Service) *Client {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterGRPC(s, svc)
+	go s.Serve(lis)
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cc.Close()
+		s.Stop()
+	})
+	return NewClient(cc)
+}
+
+func TestStatusError(t *testing.T) {
+	testTable := []struct {
+		err  error
+		want codes.Code
+	}{
+		{errorf(NotFound, "graph %q does not exist", "?g"), codes.NotFound},
+		{errorf(ResourceExhausted, "too many rows"), codes.ResourceExhausted},
+		{context.Canceled, codes.Canceled},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{status.Error(codes.Unavailable, "gone"), codes.Unavailable},
+	}
+	for _, entry := range testTable {
+		err := statusError(entry.err)
+		if got := status.Code(err); got != entry.want {
+			t.Errorf("statusError(%v) returned code %v; want %v", entry.err, got, entry.want)
+		}
+		if e, ok := entry.err.(*Error); ok {
+			if got := serviceError(err); got.Error() != e.Error() {
+				t.Errorf("serviceError(%v) returned %v; want %v", err, got, e)
+			}
+		}
+	}
+	if err := status.Error(codes.Unavailable, "gone"); serviceError(err) != err {
+		t.Errorf("serviceError should return statuses with codes not used by the service unchanged")
+	}
+}
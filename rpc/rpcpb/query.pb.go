@@ -0,0 +1,649 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build grpc
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: rpc/query.proto
+
+package rpcpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Value_Kind int32
+
+const (
+	Value_NULL      Value_Kind = 0
+	Value_STRING    Value_Kind = 1
+	Value_NODE      Value_Kind = 2
+	Value_PREDICATE Value_Kind = 3
+	Value_LITERAL   Value_Kind = 4
+	Value_TIME      Value_Kind = 5
+	Value_TRIPLE    Value_Kind = 6
+)
+
+// Enum value maps for Value_Kind.
+var (
+	Value_Kind_name = map[int32]string{
+		0: "NULL",
+		1: "STRING",
+		2: "NODE",
+		3: "PREDICATE",
+		4: "LITERAL",
+		5: "TIME",
+		6: "TRIPLE",
+	}
+	Value_Kind_value = map[string]int32{
+		"NULL":      0,
+		"STRING":    1,
+		"NODE":      2,
+		"PREDICATE": 3,
+		"LITERAL":   4,
+		"TIME":      5,
+		"TRIPLE":    6,
+	}
+)
+
+func (x Value_Kind) Enum() *Value_Kind {
+	p := new(Value_Kind)
+	*p = x
+	return p
+}
+
+func (x Value_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Value_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_rpc_query_proto_enumTypes[0].Descriptor()
+}
+
+func (Value_Kind) Type() protoreflect.EnumType {
+	return &file_rpc_query_proto_enumTypes[0]
+}
+
+func (x Value_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Value_Kind.Descriptor instead.
+func (Value_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{2, 0}
+}
+
+type QueryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The BQL query to run.
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// The maximum number of rows the query may return. Zero uses the limit of
+	// the server.
+	MaxRows       int64 `protobuf:"varint,2,opt,name=max_rows,json=maxRows,proto3" json:"max_rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_rpc_query_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *QueryRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetMaxRows() int64 {
+	if x != nil {
+		return x.MaxRows
+	}
+	return 0
+}
+
+// Row contains the values bound to the bindings of a query row.
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cells         map[string]*Value      `protobuf:"bytes,1,rep,name=cells,proto3" json:"cells,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_rpc_query_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Row) GetCells() map[string]*Value {
+	if x != nil {
+		return x.Cells
+	}
+	return nil
+}
+
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Kind  Value_Kind             `protobuf:"varint,1,opt,name=kind,proto3,enum=badwolf.rpc.Value_Kind" json:"kind,omitempty"`
+	// The BQL text of the value, such as /u<joe> or "1"^^type:int64. Times are
+	// formatted using RFC 3339 with nanoseconds.
+	Text          string `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_rpc_query_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Value) GetKind() Value_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return Value_NULL
+}
+
+func (x *Value) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type MutateRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The graphs to mutate.
+	Graphs []string `protobuf:"bytes,1,rep,name=graphs,proto3" json:"graphs,omitempty"`
+	// The triples to insert and delete, one per entry using the standard
+	// serialized format. Deletions are applied first.
+	Insert        []string `protobuf:"bytes,2,rep,name=insert,proto3" json:"insert,omitempty"`
+	Delete        []string `protobuf:"bytes,3,rep,name=delete,proto3" json:"delete,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MutateRequest) Reset() {
+	*x = MutateRequest{}
+	mi := &file_rpc_query_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MutateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MutateRequest) ProtoMessage() {}
+
+func (x *MutateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MutateRequest.ProtoReflect.Descriptor instead.
+func (*MutateRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MutateRequest) GetGraphs() []string {
+	if x != nil {
+		return x.Graphs
+	}
+	return nil
+}
+
+func (x *MutateRequest) GetInsert() []string {
+	if x != nil {
+		return x.Insert
+	}
+	return nil
+}
+
+func (x *MutateRequest) GetDelete() []string {
+	if x != nil {
+		return x.Delete
+	}
+	return nil
+}
+
+type MutateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inserted      int64                  `protobuf:"varint,1,opt,name=inserted,proto3" json:"inserted,omitempty"`
+	Deleted       int64                  `protobuf:"varint,2,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MutateResponse) Reset() {
+	*x = MutateResponse{}
+	mi := &file_rpc_query_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MutateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MutateResponse) ProtoMessage() {}
+
+func (x *MutateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MutateResponse.ProtoReflect.Descriptor instead.
+func (*MutateResponse) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MutateResponse) GetInserted() int64 {
+	if x != nil {
+		return x.Inserted
+	}
+	return 0
+}
+
+func (x *MutateResponse) GetDeleted() int64 {
+	if x != nil {
+		return x.Deleted
+	}
+	return 0
+}
+
+type GraphRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GraphRequest) Reset() {
+	*x = GraphRequest{}
+	mi := &file_rpc_query_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GraphRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GraphRequest) ProtoMessage() {}
+
+func (x *GraphRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GraphRequest.ProtoReflect.Descriptor instead.
+func (*GraphRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GraphRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type GraphResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GraphResponse) Reset() {
+	*x = GraphResponse{}
+	mi := &file_rpc_query_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GraphResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GraphResponse) ProtoMessage() {}
+
+func (x *GraphResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GraphResponse.ProtoReflect.Descriptor instead.
+func (*GraphResponse) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{6}
+}
+
+type ListGraphsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListGraphsRequest) Reset() {
+	*x = ListGraphsRequest{}
+	mi := &file_rpc_query_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListGraphsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListGraphsRequest) ProtoMessage() {}
+
+func (x *ListGraphsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListGraphsRequest.ProtoReflect.Descriptor instead.
+func (*ListGraphsRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{7}
+}
+
+type ListGraphsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Names         []string               `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListGraphsResponse) Reset() {
+	*x = ListGraphsResponse{}
+	mi := &file_rpc_query_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListGraphsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListGraphsResponse) ProtoMessage() {}
+
+func (x *ListGraphsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_query_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListGraphsResponse.ProtoReflect.Descriptor instead.
+func (*ListGraphsResponse) Descriptor() ([]byte, []int) {
+	return file_rpc_query_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListGraphsResponse) GetNames() []string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+var File_rpc_query_proto protoreflect.FileDescriptor
+
+const file_rpc_query_proto_rawDesc = "" +
+	"\n" +
+	"\x0frpc/query.proto\x12\vbadwolf.rpc\"?\n" +
+	"\fQueryRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x19\n" +
+	"\bmax_rows\x18\x02 \x01(\x03R\amaxRows\"\x86\x01\n" +
+	"\x03Row\x121\n" +
+	"\x05cells\x18\x01 \x03(\v2\x1b.badwolf.rpc.Row.CellsEntryR\x05cells\x1aL\n" +
+	"\n" +
+	"CellsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12(\n" +
+	"\x05value\x18\x02 \x01(\v2\x12.badwolf.rpc.ValueR\x05value:\x028\x01\"\xa2\x01\n" +
+	"\x05Value\x12+\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x17.badwolf.rpc.Value.KindR\x04kind\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\"X\n" +
+	"\x04Kind\x12\b\n" +
+	"\x04NULL\x10\x00\x12\n" +
+	"\n" +
+	"\x06STRING\x10\x01\x12\b\n" +
+	"\x04NODE\x10\x02\x12\r\n" +
+	"\tPREDICATE\x10\x03\x12\v\n" +
+	"\aLITERAL\x10\x04\x12\b\n" +
+	"\x04TIME\x10\x05\x12\n" +
+	"\n" +
+	"\x06TRIPLE\x10\x06\"W\n" +
+	"\rMutateRequest\x12\x16\n" +
+	"\x06graphs\x18\x01 \x03(\tR\x06graphs\x12\x16\n" +
+	"\x06insert\x18\x02 \x03(\tR\x06insert\x12\x16\n" +
+	"\x06delete\x18\x03 \x03(\tR\x06delete\"F\n" +
+	"\x0eMutateResponse\x12\x1a\n" +
+	"\binserted\x18\x01 \x01(\x03R\binserted\x12\x18\n" +
+	"\adeleted\x18\x02 \x01(\x03R\adeleted\"\"\n" +
+	"\fGraphRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\x0f\n" +
+	"\rGraphResponse\"\x13\n" +
+	"\x11ListGraphsRequest\"*\n" +
+	"\x12ListGraphsResponse\x12\x14\n" +
+	"\x05names\x18\x01 \x03(\tR\x05names2\xe9\x02\n" +
+	"\fQueryService\x12=\n" +
+	"\fExecuteQuery\x12\x19.badwolf.rpc.QueryRequest\x1a\x10.badwolf.rpc.Row0\x01\x12A\n" +
+	"\x06Mutate\x12\x1a.badwolf.rpc.MutateRequest\x1a\x1b.badwolf.rpc.MutateResponse\x12D\n" +
+	"\vCreateGraph\x12\x19.badwolf.rpc.GraphRequest\x1a\x1a.badwolf.rpc.GraphResponse\x12B\n" +
+	"\tDropGraph\x12\x19.badwolf.rpc.GraphRequest\x1a\x1a.badwolf.rpc.GraphResponse\x12M\n" +
+	"\n" +
+	"ListGraphs\x12\x1e.badwolf.rpc.ListGraphsRequest\x1a\x1f.badwolf.rpc.ListGraphsResponseB%Z#github.com/google/badwolf/rpc/rpcpbb\x06proto3"
+
+var (
+	file_rpc_query_proto_rawDescOnce sync.Once
+	file_rpc_query_proto_rawDescData []byte
+)
+
+func file_rpc_query_proto_rawDescGZIP() []byte {
+	file_rpc_query_proto_rawDescOnce.Do(func() {
+		file_rpc_query_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_rpc_query_proto_rawDesc), len(file_rpc_query_proto_rawDesc)))
+	})
+	return file_rpc_query_proto_rawDescData
+}
+
+var file_rpc_query_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_rpc_query_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_rpc_query_proto_goTypes = []any{
+	(Value_Kind)(0),            // 0: badwolf.rpc.Value.Kind
+	(*QueryRequest)(nil),       // 1: badwolf.rpc.QueryRequest
+	(*Row)(nil),                // 2: badwolf.rpc.Row
+	(*Value)(nil),              // 3: badwolf.rpc.Value
+	(*MutateRequest)(nil),      // 4: badwolf.rpc.MutateRequest
+	(*MutateResponse)(nil),     // 5: badwolf.rpc.MutateResponse
+	(*GraphRequest)(nil),       // 6: badwolf.rpc.GraphRequest
+	(*GraphResponse)(nil),      // 7: badwolf.rpc.GraphResponse
+	(*ListGraphsRequest)(nil),  // 8: badwolf.rpc.ListGraphsRequest
+	(*ListGraphsResponse)(nil), // 9: badwolf.rpc.ListGraphsResponse
+	nil,                        // 10: badwolf.rpc.Row.CellsEntry
+}
+var file_rpc_query_proto_depIdxs = []int32{
+	10, // 0: badwolf.rpc.Row.cells:type_name -> badwolf.rpc.Row.CellsEntry
+	0,  // 1: badwolf.rpc.Value.kind:type_name -> badwolf.rpc.Value.Kind
+	3,  // 2: badwolf.rpc.Row.CellsEntry.value:type_name -> badwolf.rpc.Value
+	1,  // 3: badwolf.rpc.QueryService.ExecuteQuery:input_type -> badwolf.rpc.QueryRequest
+	4,  // 4: badwolf.rpc.QueryService.Mutate:input_type -> badwolf.rpc.MutateRequest
+	6,  // 5: badwolf.rpc.QueryService.CreateGraph:input_type -> badwolf.rpc.GraphRequest
+	6,  // 6: badwolf.rpc.QueryService.DropGraph:input_type -> badwolf.rpc.GraphRequest
+	8,  // 7: badwolf.rpc.QueryService.ListGraphs:input_type -> badwolf.rpc.ListGraphsRequest
+	2,  // 8: badwolf.rpc.QueryService.ExecuteQuery:output_type -> badwolf.rpc.Row
+	5,  // 9: badwolf.rpc.QueryService.Mutate:output_type -> badwolf.rpc.MutateResponse
+	7,  // 10: badwolf.rpc.QueryService.CreateGraph:output_type -> badwolf.rpc.GraphResponse
+	7,  // 11: badwolf.rpc.QueryService.DropGraph:output_type -> badwolf.rpc.GraphResponse
+	9,  // 12: badwolf.rpc.QueryService.ListGraphs:output_type -> badwolf.rpc.ListGraphsResponse
+	8,  // [8:13] is the sub-list for method output_type
+	3,  // [3:8] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_rpc_query_proto_init() }
+func file_rpc_query_proto_init() {
+	if File_rpc_query_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_rpc_query_proto_rawDesc), len(file_rpc_query_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_rpc_query_proto_goTypes,
+		DependencyIndexes: file_rpc_query_proto_depIdxs,
+		EnumInfos:         file_rpc_query_proto_enumTypes,
+		MessageInfos:      file_rpc_query_proto_msgTypes,
+	}.Build()
+	File_rpc_query_proto = out.File
+	file_rpc_query_proto_goTypes = nil
+	file_rpc_query_proto_depIdxs = nil
+}
@@ -0,0 +1,412 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rpc implements the QueryService defined in query.proto. The service
+// itself does not depend on any RPC framework: its methods follow the shape of
+// the server interface generated by protoc-gen-go-grpc into package rpcpb.
+// RegisterGRPC exposes it over gRPC, and NewClient calls it remotely; both are
+// only built with the grpc build tag, so the package does not otherwise depend
+// on the gRPC and protobuf modules. The package also provides an in-process
+// client with the same streaming semantics.
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/badwolf/audit"
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// cacheSize contains the number of parsed queries kept by the service.
+const cacheSize = 1024
+
+// Code classifies the errors returned by the service. Codes match the gRPC
+// status codes of the same name.
+type Code int
+
+const (
+	// InvalidArgument indicates a malformed request.
+	InvalidArgument Code = 3
+	// NotFound indicates that the requested graph does not exist.
+	NotFound Code = 5
+	// AlreadyExists indicates that the graph to create already exists.
+	AlreadyExists Code = 6
+	// PermissionDenied indicates that the caller is not allowed to run the
+	// request.
+	PermissionDenied Code = 7
+	// ResourceExhausted indicates that the request exceeded a limit.
+	ResourceExhausted Code = 8
+	// Unimplemented indicates that the store does not support the request.
+	Unimplemented Code = 12
+	// Internal indicates an unexpected failure of the store.
+	Internal Code = 13
+)
+
+// String returns the name of the code.
+func (c Code) String() string {
+	switch c {
+	case InvalidArgument:
+		return "INVALID_ARGUMENT"
+	case NotFound:
+		return "NOT_FOUND"
+	case AlreadyExists:
+		return "ALREADY_EXISTS"
+	case PermissionDenied:
+		return "PERMISSION_DENIED"
+	case ResourceExhausted:
+		return "RESOURCE_EXHAUSTED"
+	case Unimplemented:
+		return "UNIMPLEMENTED"
+	case Internal:
+		return "INTERNAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Error is the error returned by the service methods.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error: code = %s desc = %s", e.Code, e.Message)
+}
+
+// errorf returns a new service error.
+func errorf(c Code, format string, args ...interface{}) error {
+	return &Error{Code: c, Message: fmt.Sprintf(format, args...)}
+}
+
+// QueryRequest mirrors the QueryRequest message.
+type QueryRequest struct {
+	Query   string
+	MaxRows int64
+}
+
+// ValueKind mirrors the Value.Kind enum.
+type ValueKind int32
+
+const (
+	// NullValue is the kind of unbound values.
+	NullValue ValueKind = iota
+	// StringValue is the kind of plain strings, such as IDs.
+	StringValue
+	// NodeValue is the kind of nodes.
+	NodeValue
+	// PredicateValue is the kind of predicates.
+	PredicateValue
+	// LiteralValue is the kind of literals.
+	LiteralValue
+	// TimeValue is the kind of time anchors.
+	TimeValue
+	// TripleValue is the kind of embedded triples.
+	TripleValue
+)
+
+// Value mirrors the Value message.
+type Value struct {
+	Kind ValueKind
+	Text string
+}
+
+// Row mirrors the Row message.
+type Row struct {
+	Cells map[string]*Value
+}
+
+// MutateRequest mirrors the MutateRequest message.
+type MutateRequest struct {
+	Graphs []string
+	Insert []string
+	Delete []string
+}
+
+// MutateResponse mirrors the MutateResponse message.
+type MutateResponse struct {
+	Inserted int64
+	Deleted  int64
+}
+
+// GraphRequest mirrors the GraphRequest message.
+type GraphRequest struct {
+	Name string
+}
+
+// GraphResponse mirrors the GraphResponse message.
+type GraphResponse struct{}
+
+// ListGraphsRequest mirrors the ListGraphsRequest message.
+type ListGraphsRequest struct{}
+
+// ListGraphsResponse mirrors the ListGraphsResponse message.
+type ListGraphsResponse struct {
+	Names []string
+}
+
+// RowSender is the server side of the ExecuteQuery stream. It matches the
+// stream type generated for server streaming gRPC methods, whose Send blocks
+// while the client is not ready to receive more rows.
+type RowSender interface {
+	Context() context.Context
+	Send(*Row) error
+}
+
+// Options configures the service.
+type Options struct {
+	// Limits contains the limits enforced on every query. Requests may only
+	// lower the maximum number of rows.
+	Limits planner.Limits
+
+	// Policy decides which requests each caller may run, if set. Callers are
+	// assigned their roles by interceptors that set them on the request
+	// context using bql.WithRoles. Graph listings only show the graphs the
+	// caller may read.
+	Policy *bql.Policy
+
+	// Admission decides when queries may start running, if set. Callers are
+	// identified by audit.Caller on the request context. Queries that are not
+	// admitted fail with ResourceExhausted.
+	Admission *planner.Admission
+}
+
+// Service implements the QueryService on top of a store. It is safe for
+// concurrent use.
+type Service struct {
+	store storage.Store
+	cache *bql.Cache
+	opts  Options
+}
+
+// NewService returns a new service for the provided store.
+func NewService(store storage.Store, opts Options) (*Service, error) {
+	cache, err := bql.NewCache(cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{store: store, cache: cache, opts: opts}, nil
+}
+
+// ExecuteQuery runs the query on the request and sends the resulting rows one
+// at a time, as they are pulled from the planner.
+func (s *Service) ExecuteQuery(req *QueryRequest, stream RowSender) error {
+	prep, err := s.cache.Prepare(req.Query)
+	if err != nil {
+		return errorf(InvalidArgument, "%v", err)
+	}
+	stm := prep.Statement()
+	if stm.Type() != semantic.Query {
+		return errorf(InvalidArgument, "ExecuteQuery only accepts queries; use Mutate or the graph management methods instead")
+	}
+	if err := s.opts.Policy.Authorize(stream.Context(), stm); err != nil {
+		return errorf(PermissionDenied, "%v", err)
+	}
+	if req.MaxRows < 0 {
+		return errorf(InvalidArgument, "invalid negative max_rows %d", req.MaxRows)
+	}
+	for _, id := range stm.Graphs() {
+		if _, err := s.store.Graph(id); err != nil {
+			return errorf(NotFound, "graph %q does not exist", id)
+		}
+	}
+	lmts := s.opts.Limits
+	if req.MaxRows > 0 && (lmts.MaxRows == 0 || req.MaxRows < lmts.MaxRows) {
+		lmts.MaxRows = req.MaxRows
+	}
+	if s.opts.Admission != nil {
+		release, err := s.opts.Admission.Admit(stream.Context(), audit.Caller(stream.Context()))
+		if err != nil {
+			return executionError(err)
+		}
+		defer release()
+	}
+	rs, err := prep.Execute(stream.Context(), s.store, lmts)
+	if err != nil {
+		return executionError(err)
+	}
+	defer rs.Close()
+	bs := rs.Bindings()
+	for rs.Next() {
+		if err := stream.Send(rowMessage(rs.Row(), bs)); err != nil {
+			return err
+		}
+	}
+	if err := rs.Err(); err != nil {
+		return executionError(err)
+	}
+	return nil
+}
+
+// executionError classifies the errors found executing a query.
+func executionError(err error) error {
+	switch err.(type) {
+	case *planner.LimitError, *planner.AdmissionError:
+		return errorf(ResourceExhausted, "%v", err)
+	}
+	return errorf(Internal, "%v", err)
+}
+
+// rowMessage converts a table row into a row message.
+func rowMessage(r table.Row, bs []string) *Row {
+	m := &Row{Cells: make(map[string]*Value, len(bs))}
+	for _, b := range bs {
+		m.Cells[b] = valueMessage(r[b])
+	}
+	return m
+}
+
+// valueMessage converts a cell into a value message.
+func valueMessage(c *table.Cell) *Value {
+	switch {
+	case c.IsNull():
+		return &Value{Kind: NullValue}
+	case c.S != "":
+		return &Value{Kind: StringValue, Text: c.S}
+	case c.N != nil:
+		return &Value{Kind: NodeValue, Text: c.N.String()}
+	case c.P != nil:
+		return &Value{Kind: PredicateValue, Text: c.P.String()}
+	case c.L != nil:
+		return &Value{Kind: LiteralValue, Text: c.L.String()}
+	case c.T != nil:
+		return &Value{Kind: TimeValue, Text: c.T.Format(time.RFC3339Nano)}
+	default:
+		return &Value{Kind: TripleValue, Text: c.String()}
+	}
+}
+
+// Mutate deletes and then inserts the triples on the request on each of the
+// requested graphs. All triples are parsed before any graph is modified.
+func (s *Service) Mutate(ctx context.Context, req *MutateRequest) (*MutateResponse, error) {
+	if len(req.Graphs) == 0 {
+		return nil, errorf(InvalidArgument, "no graphs to mutate provided")
+	}
+	for _, id := range req.Graphs {
+		if err := s.opts.Policy.AuthorizeAccess(ctx, bql.Write, id); err != nil {
+			return nil, errorf(PermissionDenied, "%v", err)
+		}
+	}
+	ins, err := parseTriples(req.Insert)
+	if err != nil {
+		return nil, err
+	}
+	del, err := parseTriples(req.Delete)
+	if err != nil {
+		return nil, err
+	}
+	var gs []storage.Graph
+	for _, id := range req.Graphs {
+		g, err := s.store.Graph(id)
+		if err != nil {
+			return nil, errorf(NotFound, "graph %q does not exist", id)
+		}
+		gs = append(gs, g)
+	}
+	res := &MutateResponse{}
+	for _, g := range gs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(del) > 0 {
+			if err := g.RemoveTriples(del); err != nil {
+				return nil, errorf(Internal, "%v", err)
+			}
+			res.Deleted += int64(len(del))
+		}
+		if len(ins) > 0 {
+			if err := g.AddTriples(ins); err != nil {
+				return nil, errorf(Internal, "%v", err)
+			}
+			res.Inserted += int64(len(ins))
+		}
+	}
+	return res, nil
+}
+
+// parseTriples parses the provided serialized triples.
+func parseTriples(txts []string) ([]*triple.Triple, error) {
+	var ts []*triple.Triple
+	for _, txt := range txts {
+		t, err := triple.ParseTriple(txt, literal.DefaultBuilder())
+		if err != nil {
+			return nil, errorf(InvalidArgument, "failed to parse triple %q with error %v", txt, err)
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// CreateGraph creates the requested graph.
+func (s *Service) CreateGraph(ctx context.Context, req *GraphRequest) (*GraphResponse, error) {
+	if req.Name == "" {
+		return nil, errorf(InvalidArgument, "no graph name provided")
+	}
+	if err := s.opts.Policy.AuthorizeAccess(ctx, bql.Write, req.Name); err != nil {
+		return nil, errorf(PermissionDenied, "%v", err)
+	}
+	if _, err := s.store.Graph(req.Name); err == nil {
+		return nil, errorf(AlreadyExists, "graph %q already exists", req.Name)
+	}
+	if _, err := s.store.NewGraph(req.Name); err != nil {
+		return nil, errorf(Internal, "%v", err)
+	}
+	return &GraphResponse{}, nil
+}
+
+// DropGraph drops the requested graph.
+func (s *Service) DropGraph(ctx context.Context, req *GraphRequest) (*GraphResponse, error) {
+	if err := s.opts.Policy.AuthorizeAccess(ctx, bql.Write, req.Name); err != nil {
+		return nil, errorf(PermissionDenied, "%v", err)
+	}
+	if _, err := s.store.Graph(req.Name); err != nil {
+		return nil, errorf(NotFound, "graph %q does not exist", req.Name)
+	}
+	if err := s.store.DeleteGraph(req.Name); err != nil {
+		return nil, errorf(Internal, "%v", err)
+	}
+	return &GraphResponse{}, nil
+}
+
+// ListGraphs lists the graphs in the store. It requires the store to implement
+// storage.GraphLister.
+func (s *Service) ListGraphs(ctx context.Context, req *ListGraphsRequest) (*ListGraphsResponse, error) {
+	gl, ok := s.store.(storage.GraphLister)
+	if !ok {
+		return nil, errorf(Unimplemented, "the store cannot list its graphs")
+	}
+	ids, err := gl.GraphNames()
+	if err != nil {
+		return nil, errorf(Internal, "%v", err)
+	}
+	if s.opts.Policy != nil {
+		var rids []string
+		for _, id := range ids {
+			if s.opts.Policy.AuthorizeAccess(ctx, bql.Read, id) == nil {
+				rids = append(rids, id)
+			}
+		}
+		ids = rids
+	}
+	return &ListGraphsResponse{Names: ids}, nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage/memory"
+)
+
+// code returns the code of a service error, or -1 for other errors.
+func code(err error) Code {
+	if e, ok := err.(*Error); ok {
+		return e.Code
+	}
+	return -1
+}
+
+// testTransports contains the functions returning a client of the provided
+// service for each of the transports built. The gRPC one is only added when
+// built with the grpc build tag.
+var testTransports = map[string]func(*testing.T, *Service) *Client{
+	"local": func(_ *testing.T, svc *Service) *Client { return NewLocalClient(svc, 0) },
+}
+
+// testClients returns a client for each of the transports built, each calling
+// its own service over a store holding graph ?test.
+func testClients(t *testing.T) map[string]*Client {
+	cs := make(map[string]*Client)
+	for kind, newClient := range testTransports {
+		svc, err := NewService(memory.NewStore(), Options{Limits: planner.Limits{MaxRows: 10}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := newClient(t, svc)
+		ctx := context.Background()
+		if _, err := c.CreateGraph(ctx, &GraphRequest{Name: "?test"}); err != nil {
+			t.Fatal(err)
+		}
+		res, err := c.Mutate(ctx, &MutateRequest{
+			Graphs: []string{"?test"},
+			Insert: []string{
+				`/u<joe> "knows"@[] /u<mary>`,
+				`/u<joe> "knows"@[] /u<peter>`,
+				`/u<joe> "age"@[] "42"^^type:int64`,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := res.Inserted, int64(3); got != want {
+			t.Fatalf("%s Client.Mutate inserted %d triples, want %d", kind, got, want)
+		}
+		cs[kind] = c
+	}
+	return cs
+}
+
+func TestExecuteQuery(t *testing.T) {
+	for kind, c := range testClients(t) {
+		rs, err := c.ExecuteQuery(context.Background(), &QueryRequest{Query: `select ?o from ?test where {/u<joe> ?p ?o} order by ?o;`})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Value
+		for {
+			r, err := rs.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s RowStream.Recv failed with error %v", kind, err)
+			}
+			got = append(got, *r.Cells["?o"])
+		}
+		want := []Value{
+			{Kind: LiteralValue, Text: `"42"^^type:int64`},
+			{Kind: NodeValue, Text: "/u<mary>"},
+			{Kind: NodeValue, Text: "/u<peter>"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s Client.ExecuteQuery returned %v, want %v", kind, got, want)
+		}
+	}
+}
+
+func TestExecuteQueryErrors(t *testing.T) {
+	testTable := []struct {
+		req  *QueryRequest
+		want Code
+	}{
+		{&QueryRequest{Query: `select ?o from ?test where {?s ?p ?o}`}, InvalidArgument},
+		{&QueryRequest{Query: `create graph ?other;`}, InvalidArgument},
+		{&QueryRequest{Query: `select ?o from ?missing where {?s ?p ?o};`}, NotFound},
+		{&QueryRequest{Query: `select ?o from ?test where {?s ?p ?o};`, MaxRows: 2}, ResourceExhausted},
+		{&QueryRequest{Query: `select ?o from ?test where {?s ?p ?o};`, MaxRows: -1}, InvalidArgument},
+	}
+	for kind, c := range testClients(t) {
+		for _, entry := range testTable {
+			rs, err := c.ExecuteQuery(context.Background(), entry.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for err == nil {
+				_, err = rs.Recv()
+			}
+			if got := code(err); got != entry.want {
+				t.Errorf("%s Client.ExecuteQuery(%q) failed with error %v; want code %v", kind, entry.req.Query, err, entry.want)
+			}
+		}
+	}
+}
+
+func TestExecuteQueryCancelled(t *testing.T) {
+	for kind, c := range testClients(t) {
+		ctx, cancel := context.WithCancel(context.Background())
+		rs, err := c.ExecuteQuery(ctx, &QueryRequest{Query: `select ?o from ?test where {?s ?p ?o};`})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rs.Recv(); err != nil {
+			t.Fatalf("%s RowStream.Recv failed with error %v", kind, err)
+		}
+		// The stream cannot produce more rows than the ones buffered until
+		// they are received, hence cancelling stops the query before it ends.
+		cancel()
+		for err == nil {
+			_, err = rs.Recv()
+		}
+		if err == io.EOF {
+			t.Errorf("%s RowStream.Recv should have failed after the call was cancelled", kind)
+		}
+	}
+}
+
+func TestGraphManagement(t *testing.T) {
+	ctx := context.Background()
+	for kind, c := range testClients(t) {
+		if _, err := c.CreateGraph(ctx, &GraphRequest{Name: "?test"}); code(err) != AlreadyExists {
+			t.Errorf("%s Client.CreateGraph returned %v; want code %v", kind, err, AlreadyExists)
+		}
+		if _, err := c.CreateGraph(ctx, &GraphRequest{Name: "/prod/users"}); err != nil {
+			t.Errorf("%s Client.CreateGraph failed with error %v", kind, err)
+		}
+		res, err := c.ListGraphs(ctx, &ListGraphsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := res.Names, []string{"/prod/users", "?test"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s Client.ListGraphs returned %v, want %v", kind, got, want)
+		}
+		if _, err := c.DropGraph(ctx, &GraphRequest{Name: "?test"}); err != nil {
+			t.Errorf("%s Client.DropGraph failed with error %v", kind, err)
+		}
+		if _, err := c.DropGraph(ctx, &GraphRequest{Name: "?test"}); code(err) != NotFound {
+			t.Errorf("%s Client.DropGraph returned %v; want code %v", kind, err, NotFound)
+		}
+		if _, err := c.Mutate(ctx, &MutateRequest{Graphs: []string{"/prod/users"}, Insert: []string{"not a triple"}}); code(err) != InvalidArgument {
+			t.Errorf("%s Client.Mutate returned %v; want code %v", kind, err, InvalidArgument)
+		}
+		if _, err := c.Mutate(ctx, &MutateRequest{Graphs: []string{"?test"}}); code(err) != NotFound {
+			t.Errorf("%s Client.Mutate returned %v; want code %v", kind, err, NotFound)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := &bql.Policy{
+		Roles: map[string]*bql.Role{
+			"reader": {Statements: []semantic.StatementType{semantic.Query}, Read: []string{"?public"}},
+		},
+	}
+	svc, err := NewService(memory.NewStore(), Options{Policy: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewLocalClient(svc, 0)
+	ctx := bql.WithRoles(context.Background(), "reader")
+	if _, err := c.CreateGraph(ctx, &GraphRequest{Name: "?public"}); code(err) != PermissionDenied {
+		t.Errorf("Client.CreateGraph returned %v; want code %v", err, PermissionDenied)
+	}
+	if _, err := svc.store.NewGraph("?public"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.store.NewGraph("?private"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Mutate(ctx, &MutateRequest{Graphs: []string{"?public"}, Insert: []string{`/u<a> "p"@[] /u<b>`}}); code(err) != PermissionDenied {
+		t.Errorf("Client.Mutate returned %v; want code %v", err, PermissionDenied)
+	}
+	if _, err := c.DropGraph(ctx, &GraphRequest{Name: "?public"}); code(err) != PermissionDenied {
+		t.Errorf("Client.DropGraph returned %v; want code %v", err, PermissionDenied)
+	}
+	res, err := c.ListGraphs(ctx, &ListGraphsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.Names, []string{"?public"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Client.ListGraphs returned %v, want %v", got, want)
+	}
+	for q, want := range map[string]Code{
+		`select ?s from ?public where {?s ?p ?o};`:  -1,
+		`select ?s from ?private where {?s ?p ?o};`: PermissionDenied,
+	} {
+		rs, err := c.ExecuteQuery(ctx, &QueryRequest{Query: q})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rs.Recv(); err != io.EOF && code(err) != want {
+			t.Errorf("Client.ExecuteQuery(%q) returned %v; want code %v", q, err, want)
+		}
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	a, err := planner.NewAdmission(planner.AdmissionOptions{MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(memory.NewStore(), Options{Admission: a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.store.NewGraph("?test"); err != nil {
+		t.Fatal(err)
+	}
+	c := NewLocalClient(svc, 0)
+	ctx := context.Background()
+	release, err := a.Admit(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &QueryRequest{Query: `select ?s from ?test where {?s ?p ?o};`}
+	rs, err := c.ExecuteQuery(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Recv(); code(err) != ResourceExhausted {
+		t.Errorf("Client.ExecuteQuery returned %v while all slots were taken; want code %v", err, ResourceExhausted)
+	}
+	release()
+	if rs, err = c.ExecuteQuery(ctx, q); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Recv(); err != io.EOF {
+		t.Errorf("Client.ExecuteQuery returned %v; want no rows", err)
+	}
+}
@@ -0,0 +1,991 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server exposes a BadWolf store over HTTP, so it can directly back
+// web applications. It provides the following endpoints:
+//
+//	POST   /query          runs the BQL statement on the request body.
+//	GET    /sparql         runs a SPARQL query following the SPARQL 1.1
+//	POST   /sparql         Protocol; see package sparql for the subset supported.
+//	GET    /graphs         lists the graphs in the store.
+//	PUT    /graphs/<name>  creates a graph.
+//	DELETE /graphs/<name>  drops a graph.
+//	GET    /health         reports whether the server can serve requests.
+//
+// Graph names are the rest of the path after /graphs/, hence graph ?a is
+// managed via /graphs/%3Fa and graph /prod/users via /graphs/%2Fprod/users.
+// Graphs are dropped as a DROP GRAPH statement would, dropping the views over
+// them too.
+//
+// Servers are shut down gracefully using Drain, which stops accepting new
+// requests and waits for the ones in flight to finish. Their options can be
+// changed while they run using Reload.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/badwolf/audit"
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/metrics"
+	"github.com/google/badwolf/sparql"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/tenant"
+)
+
+const (
+	// defaultMaxRequestBytes contains the maximum size of request bodies if
+	// none is provided.
+	defaultMaxRequestBytes = 1 << 20
+
+	// defaultCacheSize contains the number of parsed queries kept by the
+	// server if none is provided.
+	defaultCacheSize = 1024
+)
+
+// Options configures the server.
+type Options struct {
+	// Timeout is the maximum time a request may take to run its statement.
+	// Zero disables it.
+	Timeout time.Duration
+
+	// MaxRows is the maximum number of rows a query may return. Queries that
+	// exceed it fail. Zero disables it.
+	MaxRows int64
+
+	// MaxRequestBytes is the maximum size of request bodies. It defaults to 1MB.
+	MaxRequestBytes int64
+
+	// CacheSize is the number of parsed queries kept by the server. It
+	// defaults to 1024.
+	CacheSize int
+
+	// Metrics receives the statements executed, the storage operations, and
+	// the statement cache lookups of the server, if set.
+	Metrics metrics.Collector
+
+	// Audit receives an entry for every statement executed, if set. Callers
+	// are identified by wrapping the server in a handler that sets them on the
+	// request context using audit.WithCaller.
+	Audit audit.Logger
+
+	// Policy decides which statements and graph operations each request may
+	// run, if set. Callers are assigned their roles by wrapping the server in
+	// a handler that sets them on the request context using bql.WithRoles.
+	// Denied requests fail with 403 Forbidden, and graph listings only show
+	// the graphs the caller may read.
+	Policy *bql.Policy
+
+	// Admission decides when statements may start running, if set. Callers are
+	// identified by audit.Caller. Statements that are not admitted fail with
+	// 429 Too Many Requests.
+	Admission *planner.Admission
+
+	// Tenants partitions the store by tenant, if set. Each request only sees
+	// the graphs of the tenant set on its context using tenant.WithTenant.
+	// Requests without tenant fail with 403 Forbidden, as do the graph
+	// creations exceeding the tenant quota.
+	Tenants *tenant.Options
+}
+
+// Server serves the HTTP endpoints of a store. It is safe for concurrent use.
+type Server struct {
+	store   storage.Store
+	tenants *tenant.Partitioner
+	cache   *bql.Cache
+
+	mu       sync.Mutex
+	opts     Options
+	draining bool
+	inFlight int
+	// idle is closed once the server is draining and no requests are in
+	// flight.
+	idle chan struct{}
+}
+
+// DrainStatus reports the progress of draining a server.
+type DrainStatus struct {
+	// Draining is true once Drain has been called.
+	Draining bool
+
+	// InFlight contains the number of requests still running.
+	InFlight int
+}
+
+// errDraining is returned to the requests received while draining.
+var errDraining = errors.New("the server is shutting down")
+
+// New returns a new server for the provided store.
+func New(store storage.Store, opts Options) (*Server, error) {
+	opts, err := withDefaults(opts)
+	if err != nil {
+		return nil, fmt.Errorf("server.New: %v", err)
+	}
+	cache, err := bql.NewCache(opts.CacheSize)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Metrics != nil {
+		store = metrics.Store(store, opts.Metrics)
+		cache.SetCollector(opts.Metrics)
+	}
+	s := &Server{
+		store: store,
+		cache: cache,
+		opts:  opts,
+	}
+	if opts.Tenants != nil {
+		if s.tenants, err = tenant.New(store, *opts.Tenants); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// withDefaults validates the provided options, and returns them with the
+// defaults applied.
+func withDefaults(opts Options) (Options, error) {
+	if opts.Timeout < 0 || opts.MaxRows < 0 || opts.MaxRequestBytes < 0 || opts.CacheSize < 0 {
+		return opts, fmt.Errorf("invalid negative options %+v", opts)
+	}
+	if opts.MaxRequestBytes == 0 {
+		opts.MaxRequestBytes = defaultMaxRequestBytes
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = defaultCacheSize
+	}
+	return opts, nil
+}
+
+// Reload replaces the options of the server without restarting it. Requests
+// in flight keep running with the options they started with. Metrics and
+// Tenants are fixed when the server is created, hence Reload ignores them.
+// Invalid options are rejected, leaving the server unchanged.
+func (s *Server) Reload(opts Options) error {
+	opts, err := withDefaults(opts)
+	if err != nil {
+		return fmt.Errorf("server.Reload: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	opts.Metrics, opts.Tenants = s.opts.Metrics, s.opts.Tenants
+	if err := s.cache.Resize(opts.CacheSize); err != nil {
+		return err
+	}
+	s.opts = opts
+	return nil
+}
+
+// options returns the options the server currently runs with.
+func (s *Server) options() Options {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts
+}
+
+// storeFor returns the store the request runs against, which is the store of
+// its tenant if the server is partitioned.
+func (s *Server) storeFor(ctx context.Context) (storage.Store, error) {
+	if s.tenants == nil {
+		return s.store, nil
+	}
+	return s.tenants.Store(ctx)
+}
+
+// Drain stops accepting new requests and waits for the ones in flight to
+// finish. Requests received afterwards fail with 503 Service Unavailable. If
+// the context is done before all the requests finish, Drain returns its error
+// and the requests keep running. Drain may be called multiple times.
+func (s *Server) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.draining {
+		s.draining = true
+		s.idle = make(chan struct{})
+		if s.inFlight == 0 {
+			close(s.idle)
+		}
+	}
+	idle := s.idle
+	s.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("server.Drain: %d requests still running; %w", s.DrainStatus().InFlight, ctx.Err())
+	}
+}
+
+// DrainStatus returns whether the server is draining and the number of
+// requests in flight.
+func (s *Server) DrainStatus() DrainStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return DrainStatus{Draining: s.draining, InFlight: s.inFlight}
+}
+
+// begin registers a new request in flight. It returns false if the server is
+// draining, in which case the request must be rejected.
+func (s *Server) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.inFlight++
+	return true
+}
+
+// end registers the end of a request started with begin.
+func (s *Server) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	if s.draining && s.inFlight == 0 {
+		close(s.idle)
+	}
+}
+
+// ServeHTTP dispatches the request to the endpoint handling it. Paths are not
+// cleaned, so graph names may contain escaped slashes.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Health checks keep being answered while draining, so load balancers see
+	// the server is going away.
+	if r.URL.Path == "/health" {
+		s.health(w, r)
+		return
+	}
+	if !s.begin() {
+		w.Header().Set("Connection", "close")
+		writeError(w, http.StatusServiceUnavailable, errDraining)
+		return
+	}
+	defer s.end()
+	switch p := r.URL.Path; {
+	case p == "/query":
+		s.query(w, r)
+	case p == "/sparql":
+		s.sparql(w, r)
+	case p == "/graphs":
+		s.graphs(w, r)
+	case strings.HasPrefix(p, "/graphs/"):
+		s.graph(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown endpoint %s", p))
+	}
+}
+
+// QueryRequest contains the JSON body accepted by the query endpoint. Request
+// bodies of other content types are taken as the text of the statement.
+type QueryRequest struct {
+	Query string `json:"query"`
+}
+
+// QueryResponse contains the JSON encoding of the result of a statement. Rows
+// map bindings to the text of their values; unbound values are null.
+type QueryResponse struct {
+	Bindings []string             `json:"bindings"`
+	Rows     []map[string]*string `json:"rows"`
+}
+
+// ErrorResponse contains the JSON encoding of the errors returned by the
+// endpoints.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// GraphsResponse contains the JSON encoding of the list of graphs.
+type GraphsResponse struct {
+	Graphs []string `json:"graphs"`
+}
+
+// query runs the BQL statement provided on the request. Results are encoded as
+// JSON, or as CSV if requested via the format parameter or the Accept header.
+// Rows are written as they are produced; see writeResults.
+func (s *Server) query(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	q, err := s.statement(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	e, code, err := s.run(r.Context(), q)
+	if err != nil {
+		writeError(w, code, err)
+		return
+	}
+	var rw rowsWriter = &jsonRows{w: w}
+	if wantsCSV(r) {
+		rw = &csvRows{w: w}
+	}
+	started, err := writeResults(w, e, rw)
+	e.finish(err)
+	if err != nil && started {
+		// The response already started, so the client can only learn about
+		// the error by the response being cut short.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// execution keeps track of a statement run on behalf of a request.
+type execution struct {
+	ctx   context.Context
+	opts  Options
+	q     string
+	stm   *semantic.Statement
+	rs    planner.ResultStream
+	start time.Time
+	rows  int
+	// release releases the resources held by the execution, such as its
+	// admission slot.
+	release func()
+}
+
+// finish closes the stream of the execution, reports it to the metrics and
+// audit log, and releases its resources. It returns the status code for the
+// provided error, if any.
+func (e *execution) finish(err error) int {
+	if e.rs != nil {
+		if cerr := e.rs.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if e.opts.Metrics != nil {
+		e.opts.Metrics.Statement(e.stm.Type(), e.rows, time.Since(e.start), err)
+	}
+	if e.opts.Audit != nil {
+		e.opts.Audit.Log(audit.NewEntry(e.ctx, e.q, e.stm, e.start, e.rows, err))
+	}
+	code := http.StatusOK
+	if err != nil {
+		code = executionStatus(e.ctx, err)
+	}
+	e.release()
+	return code
+}
+
+// run starts running the provided BQL statement. On failure, it also returns
+// the status code of the error. Otherwise, the rows of the returned execution
+// must be pulled from its stream, and the execution finished once done.
+func (s *Server) run(ctx context.Context, q string) (*execution, int, error) {
+	prep, err := s.cache.Prepare(q)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return s.start(ctx, q, prep)
+}
+
+// start starts running the prepared statement, whose text is q, as run does.
+func (s *Server) start(ctx context.Context, q string, prep *planner.Prepared) (*execution, int, error) {
+	opts := s.options()
+	e := &execution{opts: opts, q: q, release: func() {}}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		e.release = cancel
+	}
+	e.ctx = ctx
+	fail := func(code int, err error) (*execution, int, error) {
+		e.release()
+		return nil, code, err
+	}
+	e.stm = prep.Statement()
+	if err := opts.Policy.Authorize(ctx, e.stm); err != nil {
+		if opts.Audit != nil {
+			opts.Audit.Log(audit.NewEntry(ctx, q, e.stm, time.Now(), 0, err))
+		}
+		return fail(http.StatusForbidden, err)
+	}
+	store, err := s.storeFor(ctx)
+	if err != nil {
+		return fail(http.StatusForbidden, err)
+	}
+	pln, err := prep.Plan(store, planner.Limits{MaxRows: opts.MaxRows})
+	if err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+	if opts.Admission != nil {
+		release, err := opts.Admission.Admit(ctx, audit.Caller(ctx))
+		if err != nil {
+			return fail(executionStatus(ctx, err), err)
+		}
+		cancel := e.release
+		e.release = func() {
+			release()
+			cancel()
+		}
+	}
+	e.start = time.Now()
+	if e.rs, err = planner.Stream(ctx, pln); err != nil {
+		return nil, e.finish(err), err
+	}
+	return e, http.StatusOK, nil
+}
+
+// execute runs the provided BQL statement and returns all its rows. On
+// failure, it also returns the status code of the error.
+func (s *Server) execute(ctx context.Context, q string) (*table.Table, int, error) {
+	e, code, err := s.run(ctx, q)
+	if err != nil {
+		return nil, code, err
+	}
+	return collect(e)
+}
+
+// collect pulls all the rows of the execution and finishes it. On failure, it
+// also returns the status code of the error.
+func collect(e *execution) (*table.Table, int, error) {
+	tbl, err := table.New(e.rs.Bindings())
+	if err == nil {
+		for e.rs.Next() {
+			tbl.AddRow(e.rs.Row())
+			e.rows++
+		}
+		err = e.rs.Err()
+	}
+	if code := e.finish(err); err != nil {
+		return nil, code, err
+	}
+	return tbl, http.StatusOK, nil
+}
+
+// streamChunkRows contains the number of rows pulled from a stream before
+// writing them to the response. Errors found while pulling the first chunk
+// are reported with their status code, since the response has not started
+// yet.
+const streamChunkRows = 64
+
+// rowsWriter encodes the rows of a query on a response.
+type rowsWriter interface {
+	// begin writes the headers of the response and anything preceding the
+	// rows.
+	begin(bs []string) error
+	// write writes the provided rows.
+	write(rows []table.Row) error
+	// end writes anything following the rows.
+	end() error
+}
+
+// writeResults writes the rows of the execution to the response in chunks of
+// streamChunkRows rows, flushing each one. It returns the first error found
+// producing or writing them, and whether the response had already started
+// then. Errors found before starting the response are written as error
+// responses.
+func writeResults(w http.ResponseWriter, e *execution, rw rowsWriter) (bool, error) {
+	bs := e.rs.Bindings()
+	if bs == nil {
+		bs = []string{}
+	}
+	started := false
+	chunk := make([]table.Row, 0, streamChunkRows)
+	flush := func() error {
+		if !started {
+			started = true
+			if err := rw.begin(bs); err != nil {
+				return err
+			}
+		}
+		if err := rw.write(chunk); err != nil {
+			return err
+		}
+		chunk = chunk[:0]
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+	for e.rs.Next() {
+		e.rows++
+		if chunk = append(chunk, e.rs.Row()); len(chunk) == streamChunkRows {
+			if err := flush(); err != nil {
+				return started, err
+			}
+		}
+	}
+	if err := e.rs.Err(); err != nil {
+		if !started {
+			writeError(w, executionStatus(e.ctx, err), err)
+		}
+		return started, err
+	}
+	if err := flush(); err != nil {
+		return started, err
+	}
+	return started, rw.end()
+}
+
+// jsonRows writes rows as the JSON encoding of a QueryResponse.
+type jsonRows struct {
+	w  http.ResponseWriter
+	bs []string
+	n  int
+}
+
+func (j *jsonRows) begin(bs []string) error {
+	j.bs = bs
+	hdr, err := marshalJSON(bs)
+	if err != nil {
+		return err
+	}
+	j.w.Header().Set("Content-Type", "application/json")
+	j.w.WriteHeader(http.StatusOK)
+	_, err = fmt.Fprintf(j.w, `{"bindings":%s,"rows":[`, hdr)
+	return err
+}
+
+func (j *jsonRows) write(rows []table.Row) error {
+	var buf bytes.Buffer
+	for _, r := range rows {
+		if j.n > 0 {
+			buf.WriteByte(',')
+		}
+		j.n++
+		b, err := marshalJSON(jsonRow(r, j.bs))
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+	_, err := j.w.Write(buf.Bytes())
+	return err
+}
+
+func (j *jsonRows) end() error {
+	_, err := io.WriteString(j.w, "]}\n")
+	return err
+}
+
+// jsonRow returns the JSON encoding of the provided row, mapping each binding
+// to the text of its value or to null if unbound.
+func jsonRow(r table.Row, bs []string) map[string]*string {
+	row := make(map[string]*string, len(bs))
+	for _, b := range bs {
+		if c := r[b]; !c.IsNull() {
+			v := c.String()
+			row[b] = &v
+			continue
+		}
+		row[b] = nil
+	}
+	return row
+}
+
+// marshalJSON returns the JSON encoding of the provided value.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Nodes, such as /u<joe>, are easier to read unescaped.
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// csvRows writes rows as comma separated values, as table.ToCSV does.
+type csvRows struct {
+	w  http.ResponseWriter
+	bs []string
+}
+
+func (c *csvRows) begin(bs []string) error {
+	c.bs = bs
+	tbl, err := table.New(bs)
+	if err != nil {
+		return err
+	}
+	c.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	c.w.WriteHeader(http.StatusOK)
+	return tbl.ToCSV(c.w, table.CSVOptions{})
+}
+
+func (c *csvRows) write(rows []table.Row) error {
+	tbl, err := table.New(c.bs)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		tbl.AddRow(r)
+	}
+	return tbl.ToCSV(c.w, table.CSVOptions{NoHeader: true})
+}
+
+func (c *csvRows) end() error {
+	return nil
+}
+
+// statement returns the text of the statement provided on the request.
+func (s *Server) statement(w http.ResponseWriter, r *http.Request) (string, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.options().MaxRequestBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the request body with error %v", err)
+	}
+	q := string(body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req QueryRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return "", fmt.Errorf("failed to decode the request body with error %v", err)
+		}
+		q = req.Query
+	}
+	if strings.TrimSpace(q) == "" {
+		return "", errors.New("no statement provided")
+	}
+	return q, nil
+}
+
+// executionStatus returns the status code for an error returned executing a
+// statement.
+func executionStatus(ctx context.Context, err error) int {
+	var (
+		le *planner.LimitError
+		ae *planner.AdmissionError
+		qe *tenant.QuotaError
+	)
+	switch {
+	case errors.As(err, &qe):
+		return http.StatusForbidden
+	case errors.As(err, &le):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &ae):
+		return http.StatusTooManyRequests
+	case ctx.Err() == context.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// wantsCSV returns true if the request asks for CSV encoded results.
+func wantsCSV(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+// sparql runs the SPARQL query provided on the request, following the SPARQL
+// 1.1 Protocol. Queries without a FROM clause nor default-graph-uri
+// parameters query all the graphs in the store. Results are encoded using the
+// SPARQL JSON results format, or the XML or CSV ones if requested via the
+// Accept header.
+func (s *Server) sparql(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	q, gs, err := s.sparqlRequest(w, r)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, errUnsupportedMediaType) {
+			code = http.StatusUnsupportedMediaType
+		}
+		writeError(w, code, err)
+		return
+	}
+	store, err := s.storeFor(r.Context())
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	if len(gs) == 0 {
+		if gl, ok := store.(storage.GraphLister); ok {
+			if gs, err = gl.GraphNames(); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			gs = s.readable(r.Context(), gs)
+		}
+	}
+	sq, err := sparql.Translate(q, gs)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	tbl, code, err := s.execute(r.Context(), sq.Statement)
+	if err != nil {
+		writeError(w, code, err)
+		return
+	}
+	accept := r.Header.Get("Accept")
+	xml := strings.Contains(accept, "application/sparql-results+xml") || strings.Contains(accept, "application/xml")
+	if sq.Form == sparql.Ask {
+		if xml {
+			w.Header().Set("Content-Type", "application/sparql-results+xml")
+			sparql.WriteBooleanXML(w, sq.Boolean(tbl))
+			return
+		}
+		w.Header().Set("Content-Type", "application/sparql-results+json")
+		sparql.WriteBooleanJSON(w, sq.Boolean(tbl))
+		return
+	}
+	res, err := sq.Results(tbl)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	switch {
+	case xml:
+		w.Header().Set("Content-Type", "application/sparql-results+xml")
+		res.ToSPARQLXML(w)
+	case strings.Contains(accept, "text/csv"):
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		res.ToCSV(w, table.CSVOptions{})
+	default:
+		w.Header().Set("Content-Type", "application/sparql-results+json")
+		res.ToSPARQLJSON(w)
+	}
+}
+
+// errUnsupportedMediaType is returned by sparqlRequest for POST requests whose
+// body is neither a form nor a query.
+var errUnsupportedMediaType = errors.New("unsupported media type; use application/x-www-form-urlencoded or application/sparql-query")
+
+// sparqlRequest returns the query and the default graphs provided on a SPARQL
+// protocol request. Queries are provided via the query parameter of GET
+// requests and form POST requests, or as the body of application/sparql-query
+// POST requests.
+func (s *Server) sparqlRequest(w http.ResponseWriter, r *http.Request) (string, []string, error) {
+	params := r.URL.Query()
+	q := params.Get("query")
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, s.options().MaxRequestBytes)
+		switch ct := r.Header.Get("Content-Type"); {
+		case strings.HasPrefix(ct, "application/x-www-form-urlencoded"):
+			if err := r.ParseForm(); err != nil {
+				return "", nil, fmt.Errorf("failed to read the request body with error %v", err)
+			}
+			params = r.Form
+			q = r.PostForm.Get("query")
+		case strings.HasPrefix(ct, "application/sparql-query"):
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to read the request body with error %v", err)
+			}
+			q = string(body)
+		default:
+			return "", nil, errUnsupportedMediaType
+		}
+	}
+	if params.Get("update") != "" {
+		return "", nil, errors.New("SPARQL updates are not supported")
+	}
+	if len(params["named-graph-uri"]) > 0 {
+		return "", nil, errors.New("named graphs are not supported")
+	}
+	if strings.TrimSpace(q) == "" {
+		return "", nil, errors.New("no query provided")
+	}
+	return q, params["default-graph-uri"], nil
+}
+
+// graphs lists the graphs in the store.
+func (s *Server) graphs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	store, err := s.storeFor(r.Context())
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	gl, ok := store.(storage.GraphLister)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("the store cannot list its graphs"))
+		return
+	}
+	ids, err := gl.GraphNames()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ids = s.readable(r.Context(), ids)
+	if ids == nil {
+		ids = []string{}
+	}
+	writeJSON(w, http.StatusOK, &GraphsResponse{Graphs: ids})
+}
+
+// graph creates or drops the graph named on the request path.
+func (s *Server) graph(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/graphs/")
+	if id == "" {
+		writeError(w, http.StatusNotFound, errors.New("no graph name provided"))
+		return
+	}
+	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
+		if err := s.options().Policy.AuthorizeAccess(r.Context(), bql.Write, id); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+	store, err := s.storeFor(r.Context())
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	_, gerr := store.Graph(id)
+	switch r.Method {
+	case http.MethodPut:
+		if gerr == nil {
+			writeError(w, http.StatusConflict, fmt.Errorf("graph %q already exists", id))
+			return
+		}
+		if _, err := store.NewGraph(id); err != nil {
+			writeError(w, executionStatus(r.Context(), err), err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if gerr != nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("graph %q does not exist", id))
+			return
+		}
+		// The graph is dropped by running a DROP GRAPH statement, so the views
+		// over it are dropped too, and the deletion is audited and admitted
+		// as any other statement. Graph names are not always valid BQL, hence
+		// the statement is built instead of parsed.
+		stm := &semantic.Statement{}
+		stm.BindType(semantic.Drop)
+		stm.AddGraph(id)
+		prep, err := planner.Prepare(stm)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		e, code, err := s.start(r.Context(), fmt.Sprintf("DROP GRAPH %s;", id), prep)
+		if err == nil {
+			_, code, err = collect(e)
+		}
+		if err != nil {
+			writeError(w, code, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", http.MethodPut+", "+http.MethodDelete)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+// HealthResponse contains the JSON encoding of the health of the server.
+type HealthResponse struct {
+	// Healthy is true if the store is healthy and the server is not draining.
+	Healthy bool `json:"healthy"`
+
+	// Store contains the name and version of the driver backing the store.
+	Store string `json:"store"`
+
+	// StoreError contains the reason why the store is not healthy, if any.
+	StoreError string `json:"store_error,omitempty"`
+
+	// Draining is true once the server stopped accepting requests.
+	Draining bool `json:"draining"`
+
+	// InFlight contains the number of requests running.
+	InFlight int `json:"in_flight"`
+
+	// CachedStatements contains the number of statements in the cache.
+	CachedStatements int `json:"cached_statements"`
+
+	// Running and Queued contain the number of statements running and
+	// waiting to be admitted, if the server has an admission controller.
+	Running int `json:"running"`
+	Queued  int `json:"queued"`
+}
+
+// Health checks the health of the store using storage.CheckHealth, and
+// reports it along with the load of the server. Programs embedding the server
+// may use it to build their own health endpoints.
+func (s *Server) Health() *HealthResponse {
+	h := storage.CheckHealth(s.store)
+	ds := s.DrainStatus()
+	res := &HealthResponse{
+		Healthy:          h.Healthy() && !ds.Draining,
+		Store:            h.Name + " " + h.Version,
+		Draining:         ds.Draining,
+		InFlight:         ds.InFlight,
+		CachedStatements: s.cache.Len(),
+	}
+	if h.Err != nil {
+		res.StoreError = h.Err.Error()
+	}
+	if a := s.options().Admission; a != nil {
+		res.Running, res.Queued = a.Load()
+	}
+	return res
+}
+
+// health reports the health of the server. Unhealthy servers answer with 503
+// Service Unavailable.
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	res := s.Health()
+	code := http.StatusOK
+	if !res.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, res)
+}
+
+// readable returns the provided graphs the caller may read.
+func (s *Server) readable(ctx context.Context, ids []string) []string {
+	p := s.options().Policy
+	if p == nil {
+		return ids
+	}
+	var res []string
+	for _, id := range ids {
+		if p.AuthorizeAccess(ctx, bql.Read, id) == nil {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+// writeJSON writes the provided value as the JSON body of the response.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	// Nodes, such as /u<joe>, are easier to read unescaped.
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// writeError writes the provided error as the JSON body of the response.
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,670 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/audit"
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/metrics"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/storage/tenant"
+)
+
+// do sends a request to the server and returns the recorded response.
+func do(t *testing.T, s *Server, method, target, ctype, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if ctype != "" {
+		r.Header.Set("Content-Type", ctype)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestQuery(t *testing.T) {
+	s, err := New(memory.NewStore(), Options{MaxRows: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`create graph ?test;`,
+		`insert data into ?test {/u<joe> "knows"@[] /u<mary> . /u<joe> "knows"@[] /u<peter> . /u<mary> "knows"@[] /u<peter>};`,
+	} {
+		if w := do(t, s, http.MethodPost, "/query", "text/plain", q); w.Code != http.StatusOK {
+			t.Fatalf("POST /query %q returned %d; %s", q, w.Code, w.Body)
+		}
+	}
+
+	w := do(t, s, http.MethodPost, "/query", "application/json", `{"query": "select ?o from ?test where {/u<joe> \"knows\"@[] ?o} order by ?o;"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /query returned %d; %s", w.Code, w.Body)
+	}
+	var res QueryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range res.Rows {
+		got = append(got, *r["?o"])
+	}
+	if want := []string{"/u<mary>", "/u<peter>"}; !reflect.DeepEqual(res.Bindings, []string{"?o"}) || !reflect.DeepEqual(got, want) {
+		t.Errorf("POST /query returned %s; want rows %v", w.Body, want)
+	}
+
+	w = do(t, s, http.MethodPost, "/query?format=csv", "", `select ?o from ?test where {/u<joe> "knows"@[] ?o} order by ?o;`)
+	if got, want := w.Body.String(), "?o\n/u<mary>\n/u<peter>\n"; w.Code != http.StatusOK || got != want {
+		t.Errorf("POST /query?format=csv returned %d %q; want %q", w.Code, got, want)
+	}
+
+	testTable := []struct {
+		method, body string
+		code         int
+	}{
+		{http.MethodGet, `select ?s from ?test where {?s ?p ?o};`, http.StatusMethodNotAllowed},
+		{http.MethodPost, ``, http.StatusBadRequest},
+		{http.MethodPost, `select ?s from ?test where {?s ?p ?o}`, http.StatusBadRequest},
+		{http.MethodPost, `select ?s from ?missing where {?s ?p ?o};`, http.StatusBadRequest},
+		{http.MethodPost, `select ?s, ?o from ?test where {?s ?p ?o};`, http.StatusUnprocessableEntity},
+	}
+	for _, entry := range testTable {
+		w := do(t, s, entry.method, "/query", "", entry.body)
+		if w.Code != entry.code {
+			t.Errorf("%s /query %q returned %d, want %d", entry.method, entry.body, w.Code, entry.code)
+		}
+		var er ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &er); err != nil || er.Error == "" {
+			t.Errorf("%s /query %q returned no error message; %s", entry.method, entry.body, w.Body)
+		}
+	}
+}
+
+// failingWriter is a response writer whose writes fail.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestQueryStreaming(t *testing.T) {
+	var es []*audit.Entry
+	opts := Options{
+		Audit: audit.LoggerFunc(func(e *audit.Entry) {
+			es = append(es, e)
+		}),
+	}
+	s, err := New(memory.NewStore(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts []string
+	for i := 0; i < 2*streamChunkRows+10; i++ {
+		ts = append(ts, fmt.Sprintf(`/u<joe> "knows"@[] /u<p%03d>`, i))
+	}
+	for _, q := range []string{
+		`create graph ?test;`,
+		`insert data into ?test {` + strings.Join(ts, " . ") + `};`,
+	} {
+		if w := do(t, s, http.MethodPost, "/query", "text/plain", q); w.Code != http.StatusOK {
+			t.Fatalf("POST /query %q returned %d; %s", q, w.Code, w.Body)
+		}
+	}
+	q := `select ?o from ?test where {/u<joe> "knows"@[] ?o} order by ?o;`
+	w := do(t, s, http.MethodPost, "/query", "text/plain", q)
+	var res QueryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("POST /query %q returned invalid JSON %s; %v", q, w.Body, err)
+	}
+	if got, want := len(res.Rows), len(ts); w.Code != http.StatusOK || got != want || *res.Rows[want-1]["?o"] != "/u<p137>" {
+		t.Errorf("POST /query %q returned %d with %d rows; want %d ending in /u<p137>", q, w.Code, got, want)
+	}
+	w = do(t, s, http.MethodPost, "/query?format=csv", "", q)
+	if got, want := strings.Count(w.Body.String(), "\n"), len(ts)+1; got != want {
+		t.Errorf("POST /query?format=csv %q returned %d lines; want %d", q, got, want)
+	}
+
+	// Errors found once the response started abort it.
+	opts.MaxRows = streamChunkRows + 1
+	if err := s.Reload(opts); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	func() {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("POST /query %q exceeding MaxRows after the first chunk should have been aborted; got %v", q, r)
+			}
+		}()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(q)))
+	}()
+	if got, want := w.Body.String(), `{"bindings":["?o"],"rows":[`; w.Code != http.StatusOK || !strings.HasPrefix(got, want) {
+		t.Errorf("POST /query %q should have started streaming the response; got %d %q", q, w.Code, got)
+	}
+
+	// Write errors stop the query.
+	opts.MaxRows = 0
+	if err := s.Reload(opts); err != nil {
+		t.Fatal(err)
+	}
+	es = nil
+	func() {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("POST /query %q failing to write the response should have been aborted; got %v", q, r)
+			}
+		}()
+		s.ServeHTTP(failingWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(q)))
+	}()
+	if len(es) != 1 || es[0].Err == nil || es[0].Rows != streamChunkRows {
+		t.Errorf("POST /query %q failing to write the response logged %+v; want an error after %d rows", q, es, streamChunkRows)
+	}
+}
+
+func TestGraphs(t *testing.T) {
+	s, err := New(memory.NewStore(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodPut, "/graphs/%3Fa", http.StatusCreated},
+		{http.MethodPut, "/graphs/%2Fprod/users", http.StatusCreated},
+		{http.MethodPut, "/graphs/%3Fa", http.StatusConflict},
+		{http.MethodDelete, "/graphs/%3Fb", http.StatusNotFound},
+		{http.MethodPost, "/graphs/%3Fb", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/graphs", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/unknown", http.StatusNotFound},
+	}
+	for _, entry := range testTable {
+		if w := do(t, s, entry.method, entry.target, "", ""); w.Code != entry.code {
+			t.Errorf("%s %s returned %d, want %d; %s", entry.method, entry.target, w.Code, entry.code, w.Body)
+		}
+	}
+	list := func() []string {
+		w := do(t, s, http.MethodGet, "/graphs", "", "")
+		var res GraphsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("GET /graphs returned %d %s", w.Code, w.Body)
+		}
+		return res.Graphs
+	}
+	if got, want := list(), []string{"/prod/users", "?a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GET /graphs returned %v, want %v", got, want)
+	}
+	if w := do(t, s, http.MethodDelete, "/graphs/%3Fa", "", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE /graphs/%%3Fa returned %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if got, want := list(), []string{"/prod/users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GET /graphs returned %v, want %v", got, want)
+	}
+	// Graphs whose names are not valid BQL can be deleted too.
+	if w := do(t, s, http.MethodDelete, "/graphs/%2Fprod/users", "", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE /graphs/%%2Fprod/users returned %d, want %d; %s", w.Code, http.StatusNoContent, w.Body)
+	}
+}
+
+func TestDeleteGraphDropsViews(t *testing.T) {
+	var es []*audit.Entry
+	l := audit.LoggerFunc(func(e *audit.Entry) {
+		es = append(es, e)
+	})
+	store := memory.NewStore()
+	s, err := New(store, Options{Audit: l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`create graph ?people;`,
+		`insert data into ?people {/u<joe> "knows"@[] /u<mary>};`,
+		`create view ?friends as select ?s, ?o from ?people where {?s "knows"@[] ?o};`,
+	} {
+		if w := do(t, s, http.MethodPost, "/query", "", q); w.Code != http.StatusOK {
+			t.Fatalf("POST /query %q returned %d; %s", q, w.Code, w.Body)
+		}
+	}
+	es = nil
+	if w := do(t, s, http.MethodDelete, "/graphs/%3Fpeople", "", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /graphs/%%3Fpeople returned %d, want %d; %s", w.Code, http.StatusNoContent, w.Body)
+	}
+	for _, id := range []string{"?people", "?friends"} {
+		if _, err := store.Graph(id); err == nil {
+			t.Errorf("DELETE /graphs/%%3Fpeople should have deleted graph %s", id)
+		}
+	}
+	if len(es) != 1 {
+		t.Fatalf("server logged %d audit entries for the deletion; want 1", len(es))
+	}
+	if e := es[0]; e.Type != semantic.Drop || !reflect.DeepEqual(e.WrittenGraphs, []string{"?people"}) || e.Err != nil {
+		t.Errorf("server logged %+v for the deletion", e)
+	}
+}
+
+func TestSPARQL(t *testing.T) {
+	s, err := New(memory.NewStore(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`create graph ?test;`,
+		`insert data into ?test {/u<joe> "knows"@[] /u<mary> . /u<joe> "knows"@[] /u<peter>};`,
+	} {
+		if w := do(t, s, http.MethodPost, "/query", "text/plain", q); w.Code != http.StatusOK {
+			t.Fatalf("POST /query %q returned %d; %s", q, w.Code, w.Body)
+		}
+	}
+	sel := `SELECT ?o WHERE { </u/joe> <knows> ?o } ORDER BY ?o`
+	testTable := []struct {
+		method, target, ctype, accept, body string
+		code                                int
+		want                                string
+	}{
+		{
+			method: http.MethodGet,
+			target: "/sparql?query=" + url.QueryEscape(sel),
+			code:   http.StatusOK,
+			want:   `{"head":{"vars":["o"]},"results":{"bindings":[{"o":{"type":"uri","value":"/u\u003cmary\u003e"}},{"o":{"type":"uri","value":"/u\u003cpeter\u003e"}}]}}`,
+		},
+		{
+			method: http.MethodPost,
+			target: "/sparql",
+			ctype:  "application/x-www-form-urlencoded",
+			accept: "text/csv",
+			body:   "default-graph-uri=%3Ftest&query=" + url.QueryEscape(sel),
+			code:   http.StatusOK,
+			want:   "?o\n/u<mary>\n/u<peter>\n",
+		},
+		{
+			method: http.MethodPost,
+			target: "/sparql",
+			ctype:  "application/sparql-query",
+			body:   `ASK FROM <?test> { </u/joe> <knows> </u/peter> }`,
+			code:   http.StatusOK,
+			want:   `{"head":{},"boolean":true}`,
+		},
+		{
+			method: http.MethodPost,
+			target: "/sparql?default-graph-uri=%3Fmissing",
+			ctype:  "application/sparql-query",
+			body:   sel,
+			code:   http.StatusBadRequest,
+		},
+		{method: http.MethodGet, target: "/sparql?query=" + url.QueryEscape(`SELECT ?o WHERE { ?s ?p ?o FILTER(?o) }`), code: http.StatusBadRequest},
+		{method: http.MethodGet, target: "/sparql", code: http.StatusBadRequest},
+		{method: http.MethodPost, target: "/sparql", ctype: "text/plain", body: sel, code: http.StatusUnsupportedMediaType},
+		{method: http.MethodPost, target: "/sparql", ctype: "application/x-www-form-urlencoded", body: "update=CLEAR+ALL", code: http.StatusBadRequest},
+		{method: http.MethodDelete, target: "/sparql", code: http.StatusMethodNotAllowed},
+	}
+	for _, entry := range testTable {
+		r := httptest.NewRequest(entry.method, entry.target, strings.NewReader(entry.body))
+		if entry.ctype != "" {
+			r.Header.Set("Content-Type", entry.ctype)
+		}
+		if entry.accept != "" {
+			r.Header.Set("Accept", entry.accept)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != entry.code {
+			t.Errorf("%s %s returned %d, want %d; %s", entry.method, entry.target, w.Code, entry.code, w.Body)
+			continue
+		}
+		if got := strings.TrimSpace(w.Body.String()); entry.want != "" && got != strings.TrimSpace(entry.want) {
+			t.Errorf("%s %s returned %s, want %s", entry.method, entry.target, got, entry.want)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	mc := metrics.NewCounters()
+	s, err := New(memory.NewStore(), Options{Metrics: mc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`create graph ?test;`,
+		`insert data into ?test {/u<joe> "knows"@[] /u<mary> . /u<joe> "knows"@[] /u<peter>};`,
+		`select ?o from ?test where {/u<joe> "knows"@[] ?o};`,
+		`select ?o from ?test where {/u<joe> "knows"@[] ?o};`,
+	} {
+		if w := do(t, s, http.MethodPost, "/query", "", q); w.Code != http.StatusOK {
+			t.Fatalf("POST /query %q returned %d; %s", q, w.Code, w.Body)
+		}
+	}
+	snap := mc.Snapshot()
+	if got := snap.Statements["QUERY"].Count; got != 2 {
+		t.Errorf("metrics reported %d queries; want 2", got)
+	}
+	if snap.Rows != 4 {
+		t.Errorf("metrics reported %d rows; want 4", snap.Rows)
+	}
+	if snap.CacheHits != 1 {
+		t.Errorf("metrics reported %d cache hits; want 1", snap.CacheHits)
+	}
+	if snap.Operations["NEW_GRAPH"].Count != 1 || snap.Operations["ADD_TRIPLES"].Count == 0 {
+		t.Errorf("metrics reported operations %v; missing graph creation and triple additions", snap.Operations)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	var es []*audit.Entry
+	l := audit.LoggerFunc(func(e *audit.Entry) {
+		es = append(es, e)
+	})
+	s, err := New(memory.NewStore(), Options{Audit: l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeHTTP(w, r.WithContext(audit.WithCaller(r.Context(), r.Header.Get("X-User"))))
+	})
+	for _, q := range []string{
+		`create graph ?test;`,
+		`select ?s from ?test where {?s ?p ?o};`,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(q))
+		r.Header.Set("X-User", "joe")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST /query %q returned %d; %s", q, w.Code, w.Body)
+		}
+	}
+	if len(es) != 2 {
+		t.Fatalf("server logged %d audit entries; want 2", len(es))
+	}
+	if e := es[0]; e.Caller != "joe" || !reflect.DeepEqual(e.WrittenGraphs, []string{"?test"}) {
+		t.Errorf("server logged %+v for the graph creation", e)
+	}
+	if e := es[1]; e.Caller != "joe" || !reflect.DeepEqual(e.ReadGraphs, []string{"?test"}) || e.Rows != 0 {
+		t.Errorf("server logged %+v for the query", e)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := &bql.Policy{
+		Roles: map[string]*bql.Role{
+			"reader": {Statements: []semantic.StatementType{semantic.Query}, Read: []string{"?public"}},
+		},
+	}
+	store := memory.NewStore()
+	for _, id := range []string{"?public", "?private"} {
+		if _, err := store.NewGraph(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := New(store, Options{Policy: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeHTTP(w, r.WithContext(bql.WithRoles(r.Context(), "reader")))
+	})
+	testTable := []struct {
+		method, target, body string
+		code                 int
+	}{
+		{http.MethodPost, "/query", `select ?s from ?public where {?s ?p ?o};`, http.StatusOK},
+		{http.MethodPost, "/query", `select ?s from ?private where {?s ?p ?o};`, http.StatusForbidden},
+		{http.MethodPost, "/query", `create graph ?other;`, http.StatusForbidden},
+		{http.MethodPut, "/graphs/%3Fother", ``, http.StatusForbidden},
+		{http.MethodDelete, "/graphs/%3Fpublic", ``, http.StatusForbidden},
+		{http.MethodGet, "/sparql?query=" + url.QueryEscape(`SELECT ?s WHERE { ?s ?p ?o }`), ``, http.StatusOK},
+	}
+	for _, entry := range testTable {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(entry.method, entry.target, strings.NewReader(entry.body)))
+		if w.Code != entry.code {
+			t.Errorf("%s %s %q returned %d, want %d; %s", entry.method, entry.target, entry.body, w.Code, entry.code, w.Body)
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/graphs", nil))
+	var res GraphsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"?public"}; !reflect.DeepEqual(res.Graphs, want) {
+		t.Errorf("GET /graphs returned %v; want %v", res.Graphs, want)
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	a, err := planner.NewAdmission(planner.AdmissionOptions{MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(memory.NewStore(), Options{Admission: a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := a.Admit(context.Background(), "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := do(t, s, http.MethodPost, "/query", "", `create graph ?test;`); w.Code != http.StatusTooManyRequests {
+		t.Errorf("POST /query returned %d while all slots were taken; want %d", w.Code, http.StatusTooManyRequests)
+	}
+	release()
+	if w := do(t, s, http.MethodPost, "/query", "", `create graph ?test;`); w.Code != http.StatusOK {
+		t.Errorf("POST /query returned %d; want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	s, err := New(memory.NewStore(), Options{Tenants: &tenant.Options{MaxGraphs: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	as := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if name != "" {
+				r = r.WithContext(tenant.WithTenant(r.Context(), name))
+			}
+			s.ServeHTTP(w, r)
+		})
+	}
+	testTable := []struct {
+		tenant, method, target, body string
+		code                         int
+	}{
+		{"acme", http.MethodPost, "/query", `create graph ?people;`, http.StatusOK},
+		{"acme", http.MethodPost, "/query", `insert data into ?people {/u<joe> "knows"@[] /u<mary>};`, http.StatusOK},
+		{"acme", http.MethodPost, "/query", `create graph ?other;`, http.StatusForbidden},
+		{"acme", http.MethodPut, "/graphs/%3Fother", ``, http.StatusForbidden},
+		{"globex", http.MethodPost, "/query", `select ?s from ?people where {?s ?p ?o};`, http.StatusBadRequest},
+		{"globex", http.MethodPut, "/graphs/%3Fpeople", ``, http.StatusCreated},
+		{"", http.MethodPost, "/query", `select ?s from ?people where {?s ?p ?o};`, http.StatusForbidden},
+		{"", http.MethodGet, "/graphs", ``, http.StatusForbidden},
+	}
+	for _, entry := range testTable {
+		w := httptest.NewRecorder()
+		as(entry.tenant).ServeHTTP(w, httptest.NewRequest(entry.method, entry.target, strings.NewReader(entry.body)))
+		if w.Code != entry.code {
+			t.Errorf("%s %s %q as %q returned %d, want %d; %s", entry.method, entry.target, entry.body, entry.tenant, w.Code, entry.code, w.Body)
+		}
+	}
+	w := httptest.NewRecorder()
+	as("acme").ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`select ?s from ?people where {?s ?p ?o};`)))
+	var res QueryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || len(res.Rows) != 1 {
+		t.Errorf("POST /query as acme returned %s; want one row", w.Body)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	s, err := New(memory.NewStore(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a request in flight.
+	if !s.begin() {
+		t.Fatal("begin rejected a request before draining")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain returned %v with a request in flight; want %v", err, context.DeadlineExceeded)
+	}
+	if got, want := s.DrainStatus(), (DrainStatus{Draining: true, InFlight: 1}); got != want {
+		t.Errorf("DrainStatus returned %+v; want %+v", got, want)
+	}
+	if w := do(t, s, http.MethodGet, "/graphs", "", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /graphs while draining returned %d; want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Drain(context.Background())
+	}()
+	s.end()
+	if err := <-done; err != nil {
+		t.Errorf("Drain returned %v once the requests finished", err)
+	}
+	if got, want := s.DrainStatus(), (DrainStatus{Draining: true}); got != want {
+		t.Errorf("DrainStatus returned %+v; want %+v", got, want)
+	}
+	if err := s.Drain(context.Background()); err != nil {
+		t.Errorf("Drain returned %v on a drained server", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	s, err := New(memory.NewStore(), Options{MaxRows: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`create graph ?test;`,
+		`insert data into ?test {/u<joe> "knows"@[] /u<mary> . /u<joe> "knows"@[] /u<peter>};`,
+	} {
+		if w := do(t, s, http.MethodPost, "/query", "text/plain", q); w.Code != http.StatusOK {
+			t.Fatalf("POST /query %q returned %d; %s", q, w.Code, w.Body)
+		}
+	}
+	q := `select ?o from ?test where {/u<joe> "knows"@[] ?o};`
+	if w := do(t, s, http.MethodPost, "/query", "text/plain", q); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("POST /query %q returned %d with MaxRows 1; want %d", q, w.Code, http.StatusUnprocessableEntity)
+	}
+	if err := s.Reload(Options{MaxRows: -1}); err == nil {
+		t.Errorf("Reload should have rejected negative options")
+	}
+	if w := do(t, s, http.MethodPost, "/query", "text/plain", q); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /query %q returned %d after a rejected reload; want %d", q, w.Code, http.StatusUnprocessableEntity)
+	}
+	if err := s.Reload(Options{MaxRows: 2, CacheSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(t, s, http.MethodPost, "/query", "text/plain", q); w.Code != http.StatusOK {
+		t.Errorf("POST /query %q returned %d after raising MaxRows; want %d", q, w.Code, http.StatusOK)
+	}
+	do(t, s, http.MethodPost, "/query", "text/plain", `select ?s from ?test where {?s ?p ?o};`)
+	if got, want := s.cache.Len(), 1; got != want {
+		t.Errorf("the statement cache holds %d entries after resizing it; want %d", got, want)
+	}
+}
+
+// unhealthyStore is a store whose backend cannot be reached.
+type unhealthyStore struct {
+	storage.Store
+}
+
+func (unhealthyStore) CheckHealth() error { return errors.New("backend unreachable") }
+
+func TestHealth(t *testing.T) {
+	healthy, err := New(memory.NewStore(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unhealthy, err := New(unhealthyStore{memory.NewStore()}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	draining, err := New(memory.NewStore(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := draining.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	table := []struct {
+		s    *Server
+		code int
+		want HealthResponse
+	}{
+		{s: healthy, code: http.StatusOK, want: HealthResponse{Healthy: true, Store: "MEMORY_STORE " + memory.NewStore().Version()}},
+		{s: unhealthy, code: http.StatusServiceUnavailable, want: HealthResponse{Store: "MEMORY_STORE " + memory.NewStore().Version(), StoreError: "backend unreachable"}},
+		{s: draining, code: http.StatusServiceUnavailable, want: HealthResponse{Store: "MEMORY_STORE " + memory.NewStore().Version(), Draining: true}},
+	}
+	for i, entry := range table {
+		w := do(t, entry.s, http.MethodGet, "/health", "", "")
+		if w.Code != entry.code {
+			t.Errorf("GET /health on server %d returned %d; want %d", i, w.Code, entry.code)
+		}
+		var got HealthResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != entry.want {
+			t.Errorf("GET /health on server %d returned %+v; want %+v", i, got, entry.want)
+		}
+	}
+	if w := do(t, healthy, http.MethodPost, "/health", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /health returned %d; want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	if _, err := New(memory.NewStore(), Options{MaxRows: -1}); err == nil {
+		t.Errorf("server.New should have rejected negative limits")
+	}
+}
+
+func TestExecutionStatus(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	testTable := []struct {
+		ctx  context.Context
+		err  error
+		want int
+	}{
+		{context.Background(), &planner.LimitError{Limit: "MaxRows", Max: "2"}, http.StatusUnprocessableEntity},
+		{expired, context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{context.Background(), errors.New("failed"), http.StatusInternalServerError},
+	}
+	for _, entry := range testTable {
+		if got := executionStatus(entry.ctx, entry.err); got != entry.want {
+			t.Errorf("executionStatus(%v) returned %d, want %d", entry.err, got, entry.want)
+		}
+	}
+}
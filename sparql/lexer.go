@@ -0,0 +1,219 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind identifies the kind of a SPARQL token.
+type tokenKind int8
+
+const (
+	tokEOF tokenKind = iota
+	// tokIRI tokens hold the text between the angle brackets of an IRI.
+	tokIRI
+	// tokPName tokens hold prefixed names, such as ex:joe.
+	tokPName
+	// tokVar tokens hold the name of a variable, without the ? or $ sign.
+	tokVar
+	// tokBlank tokens hold the label of a blank node, without the _: prefix.
+	tokBlank
+	// tokString tokens hold the unescaped text of a string.
+	tokString
+	// tokLang tokens hold a language tag, without the @ sign.
+	tokLang
+	// tokNumber tokens hold the text of a numeric literal.
+	tokNumber
+	// tokWord tokens hold keywords and other bare words.
+	tokWord
+	// tokPunct tokens hold punctuation, such as {, . or ^^.
+	tokPunct
+)
+
+// token is a lexed piece of a SPARQL query.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// String returns a readable version of the token for error messages.
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokIRI:
+		return "<" + t.text + ">"
+	case tokVar:
+		return "?" + t.text
+	case tokBlank:
+		return "_:" + t.text
+	case tokString:
+		return fmt.Sprintf("%q", t.text)
+	case tokLang:
+		return "@" + t.text
+	}
+	return t.text
+}
+
+// lex splits the provided query into tokens. The returned tokens always end
+// with a tokEOF one.
+func lex(q string) ([]token, error) {
+	var tkns []token
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '<':
+			// IRIs may hold BadWolf nodes, such as </u<joe>>, hence nested angle
+			// brackets are allowed.
+			j, depth := i+1, 1
+			for ; j < len(rs) && depth > 0; j++ {
+				switch rs[j] {
+				case '<':
+					depth++
+				case '>':
+					depth--
+				}
+			}
+			if depth > 0 {
+				return nil, fmt.Errorf("unterminated IRI at offset %d", i)
+			}
+			tkns = append(tkns, token{tokIRI, string(rs[i+1 : j-1]), i})
+			i = j
+		case r == '?' || r == '$':
+			j := i + 1
+			for j < len(rs) && isNameRune(rs[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("missing variable name at offset %d", i)
+			}
+			tkns = append(tkns, token{tokVar, string(rs[i+1 : j]), i})
+			i = j
+		case r == '"' || r == '\'':
+			s, j, err := lexString(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			tkns = append(tkns, token{tokString, s, i})
+			i = j
+		case r == '@':
+			j := i + 1
+			for j < len(rs) && (isNameRune(rs[j]) || rs[j] == '-') {
+				j++
+			}
+			tkns = append(tkns, token{tokLang, string(rs[i+1 : j]), i})
+			i = j
+		case r == '^' && i+1 < len(rs) && rs[i+1] == '^':
+			tkns = append(tkns, token{tokPunct, "^^", i})
+			i += 2
+		case unicode.IsDigit(r) || (r == '+' || r == '-' || r == '.') && i+1 < len(rs) && unicode.IsDigit(rs[i+1]):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || strings.ContainsRune(".eE", rs[j]) || (rs[j] == '+' || rs[j] == '-') && (rs[j-1] == 'e' || rs[j-1] == 'E')) {
+				j++
+			}
+			// A trailing dot terminates the triple pattern instead.
+			if rs[j-1] == '.' {
+				j--
+			}
+			tkns = append(tkns, token{tokNumber, string(rs[i:j]), i})
+			i = j
+		case r == '_' && i+1 < len(rs) && rs[i+1] == ':':
+			j := localNameEnd(rs, i+2)
+			if j == i+2 {
+				return nil, fmt.Errorf("missing blank node label at offset %d", i)
+			}
+			tkns = append(tkns, token{tokBlank, string(rs[i+2 : j]), i})
+			i = j
+		case isNameRune(r) || r == ':':
+			j := i
+			for j < len(rs) && isNameRune(rs[j]) {
+				j++
+			}
+			if j < len(rs) && rs[j] == ':' {
+				j = localNameEnd(rs, j+1)
+				tkns = append(tkns, token{tokPName, string(rs[i:j]), i})
+				i = j
+				continue
+			}
+			tkns = append(tkns, token{tokWord, string(rs[i:j]), i})
+			i = j
+		case strings.ContainsRune("{}().;,*", r):
+			tkns = append(tkns, token{tokPunct, string(r), i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", r, i)
+		}
+	}
+	return append(tkns, token{tokEOF, "", len(rs)}), nil
+}
+
+// isNameRune returns true if the rune can be part of variable names, keywords
+// and prefixes.
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// localNameEnd returns the offset where the local part of a prefixed name or
+// the label of a blank node starting at i ends. Local names cannot end with a
+// dot, which terminates the triple pattern instead.
+func localNameEnd(rs []rune, i int) int {
+	j := i
+	for j < len(rs) && (isNameRune(rs[j]) || rs[j] == '-' || rs[j] == '.') {
+		j++
+	}
+	for j > i && rs[j-1] == '.' {
+		j--
+	}
+	return j
+}
+
+// lexString lexes the string starting at offset i, returning its unescaped
+// text and the offset after its closing quote. Long strings delimited by
+// triple quotes are not supported.
+func lexString(rs []rune, i int) (string, int, error) {
+	q := rs[i]
+	var b strings.Builder
+	for j := i + 1; j < len(rs); j++ {
+		switch r := rs[j]; {
+		case r == q:
+			return b.String(), j + 1, nil
+		case r == '\n' || r == '\r':
+			return "", 0, fmt.Errorf("unterminated string at offset %d", i)
+		case r == '\\':
+			if j++; j == len(rs) {
+				return "", 0, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			esc, ok := map[rune]rune{'t': '\t', 'n': '\n', 'r': '\r', 'b': '\b', 'f': '\f', '"': '"', '\'': '\'', '\\': '\\'}[rs[j]]
+			if !ok {
+				return "", 0, fmt.Errorf("unsupported escape sequence \\%c at offset %d", rs[j], j-1)
+			}
+			b.WriteRune(esc)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at offset %d", i)
+}
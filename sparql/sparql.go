@@ -0,0 +1,524 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sparql translates a practical subset of SPARQL 1.1 queries into BQL
+// statements, so existing SPARQL clients can query a BadWolf store.
+//
+// Supported queries are SELECT and ASK queries with PREFIX declarations, FROM
+// clauses, a group of triple patterns, possibly abbreviated with ; and , and
+// the ORDER BY and LIMIT modifiers. Filters, optional patterns, unions, named
+// graphs, and property paths are not supported.
+//
+// IRIs are mapped to BadWolf terms as follows. IRIs holding the text of a
+// BadWolf node or predicate, such as </u<joe>> or <"knows"@[]>, are mapped to
+// them; these are the IRIs used by the SPARQL results formats of the table
+// package. Other IRIs on subjects and objects are split on their last slash
+// into a node type and ID, hence </u/joe> stands for /u<joe>. Other IRIs on
+// predicates are taken as the ID of an immutable predicate, hence <knows>
+// stands for "knows"@[]. FROM IRIs name the graphs to query.
+package sparql
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+const (
+	// sparqlNS is the namespace of the SPARQL Query Results XML Format.
+	sparqlNS = "http://www.w3.org/2005/sparql-results#"
+	// rdfType is the IRI abbreviated by the a keyword.
+	rdfType = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+)
+
+// Form identifies the kind of a SPARQL query.
+type Form int8
+
+const (
+	// Select queries return the bindings of their variables.
+	Select Form = iota
+	// Ask queries return whether their pattern has any match.
+	Ask
+)
+
+// String returns a readable name for the query form.
+func (f Form) String() string {
+	switch f {
+	case Select:
+		return "SELECT"
+	case Ask:
+		return "ASK"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Query contains a SPARQL query translated into BQL.
+type Query struct {
+	// Form contains the kind of the SPARQL query.
+	Form Form
+
+	// Statement contains the text of the equivalent BQL statement.
+	Statement string
+
+	// Variables contains the names of the variables returned by select
+	// queries, without the leading ?, in the order they were selected.
+	Variables []string
+
+	// bindings contains the BQL bindings holding each of the variables.
+	bindings []string
+}
+
+// Translate returns the BQL translation of the provided SPARQL query. The
+// default graphs are queried if the query has no FROM clause.
+func Translate(q string, defaultGraphs []string) (*Query, error) {
+	tkns, err := lex(q)
+	if err != nil {
+		return nil, fmt.Errorf("sparql.Translate: %v", err)
+	}
+	p := &parser{tkns: tkns, prefixes: make(map[string]string)}
+	if err := p.parse(); err != nil {
+		return nil, fmt.Errorf("sparql.Translate: %v", err)
+	}
+	if len(p.graphs) == 0 {
+		p.graphs = defaultGraphs
+	}
+	res, err := p.translate()
+	if err != nil {
+		return nil, fmt.Errorf("sparql.Translate: %v", err)
+	}
+	return res, nil
+}
+
+// Results returns the table of the results of a select query given the table
+// returned by the BQL statement. The bindings of the returned table are the
+// selected variables.
+func (q *Query) Results(tbl *table.Table) (*table.Table, error) {
+	var bs []string
+	for _, v := range q.Variables {
+		bs = append(bs, "?"+v)
+	}
+	res, err := table.New(bs)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range tbl.Rows() {
+		nr := make(table.Row, len(bs))
+		for i, b := range bs {
+			if c, ok := r[q.bindings[i]]; ok {
+				nr[b] = c
+			}
+		}
+		res.AddRow(nr)
+	}
+	return res, nil
+}
+
+// Boolean returns the result of an ask query given the table returned by the
+// BQL statement.
+func (q *Query) Boolean(tbl *table.Table) bool {
+	return tbl.NumRows() > 0
+}
+
+// WriteBooleanJSON writes the result of an ask query to the provided writer
+// using the W3C SPARQL 1.1 Query Results JSON Format.
+func WriteBooleanJSON(w io.Writer, b bool) error {
+	return json.NewEncoder(w).Encode(struct {
+		Head    struct{} `json:"head"`
+		Boolean bool     `json:"boolean"`
+	}{Boolean: b})
+}
+
+// WriteBooleanXML writes the result of an ask query to the provided writer
+// using the W3C SPARQL Query Results XML Format.
+func WriteBooleanXML(w io.Writer, b bool) error {
+	_, err := fmt.Fprintf(w, "%s<sparql xmlns=%q>\n<head/>\n<boolean>%t</boolean>\n</sparql>\n", xml.Header, sparqlNS, b)
+	return err
+}
+
+// termKind identifies the kind of a term of a triple pattern.
+type termKind int8
+
+const (
+	varTerm termKind = iota
+	blankTerm
+	iriTerm
+	literalTerm
+)
+
+// term is a subject, predicate or object of a triple pattern.
+type term struct {
+	kind termKind
+	text string
+	lit  *literal.Literal
+}
+
+// pattern is a triple pattern.
+type pattern struct {
+	s, p, o term
+}
+
+// orderKey is a condition of the ORDER BY modifier.
+type orderKey struct {
+	variable string
+	desc     bool
+}
+
+// parser parses the tokens of a SPARQL query.
+type parser struct {
+	tkns     []token
+	pos      int
+	prefixes map[string]string
+
+	form     Form
+	distinct bool
+	// vars contains the selected variables; nil selects all of them.
+	vars     []string
+	graphs   []string
+	patterns []pattern
+	order    []orderKey
+	limit    int64
+}
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() token {
+	return p.tkns[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *parser) next() token {
+	t := p.tkns[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isKeyword returns true if the token is the provided keyword, ignoring case.
+func isKeyword(t token, kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, kw)
+}
+
+// isPunct returns true if the token is the provided punctuation.
+func isPunct(t token, s string) bool {
+	return t.kind == tokPunct && t.text == s
+}
+
+// expect consumes the next token, failing if it is not the provided keyword or
+// punctuation.
+func (p *parser) expect(s string) error {
+	t := p.next()
+	if isKeyword(t, s) || isPunct(t, s) {
+		return nil
+	}
+	return fmt.Errorf("expected %s but found %s at offset %d", s, t, t.pos)
+}
+
+// parse parses the whole query.
+func (p *parser) parse() error {
+	p.limit = -1
+	for {
+		t := p.peek()
+		if isKeyword(t, "BASE") {
+			return fmt.Errorf("BASE declarations are not supported")
+		}
+		if !isKeyword(t, "PREFIX") {
+			break
+		}
+		p.next()
+		pn, iri := p.next(), p.next()
+		if pn.kind != tokPName || !strings.HasSuffix(pn.text, ":") || iri.kind != tokIRI {
+			return fmt.Errorf("invalid PREFIX declaration at offset %d", t.pos)
+		}
+		p.prefixes[strings.TrimSuffix(pn.text, ":")] = iri.text
+	}
+	switch t := p.next(); {
+	case isKeyword(t, "SELECT"):
+		if err := p.parseProjection(); err != nil {
+			return err
+		}
+	case isKeyword(t, "ASK"):
+		p.form = Ask
+	case isKeyword(t, "CONSTRUCT"), isKeyword(t, "DESCRIBE"):
+		return fmt.Errorf("%s queries are not supported", strings.ToUpper(t.text))
+	default:
+		return fmt.Errorf("expected SELECT or ASK but found %s at offset %d", t, t.pos)
+	}
+	for isKeyword(p.peek(), "FROM") {
+		p.next()
+		t := p.next()
+		if isKeyword(t, "NAMED") {
+			return fmt.Errorf("FROM NAMED clauses are not supported")
+		}
+		g, err := p.iri(t)
+		if err != nil {
+			return err
+		}
+		p.graphs = append(p.graphs, g)
+	}
+	if isKeyword(p.peek(), "WHERE") {
+		p.next()
+	}
+	if err := p.parseGroup(); err != nil {
+		return err
+	}
+	if err := p.parseModifiers(); err != nil {
+		return err
+	}
+	if t := p.next(); t.kind != tokEOF {
+		return fmt.Errorf("unexpected %s at offset %d", t, t.pos)
+	}
+	return nil
+}
+
+// parseProjection parses the variables listed by a SELECT query.
+func (p *parser) parseProjection() error {
+	if t := p.peek(); isKeyword(t, "DISTINCT") {
+		p.distinct = true
+		p.next()
+	} else if isKeyword(t, "REDUCED") {
+		// Reduced queries may drop any duplicates, including none.
+		p.next()
+	}
+	if isPunct(p.peek(), "*") {
+		p.next()
+		return nil
+	}
+	for p.peek().kind == tokVar {
+		p.vars = append(p.vars, p.next().text)
+	}
+	if t := p.peek(); len(p.vars) == 0 {
+		if isPunct(t, "(") {
+			return fmt.Errorf("projection expressions are not supported")
+		}
+		return fmt.Errorf("expected variables or * but found %s at offset %d", t, t.pos)
+	}
+	return nil
+}
+
+// parseGroup parses the group of triple patterns of the query.
+func (p *parser) parseGroup() error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for !isPunct(p.peek(), "}") {
+		switch t := p.peek(); {
+		case t.kind == tokEOF:
+			return fmt.Errorf("missing } closing the group of patterns")
+		case t.kind == tokWord && !isKeyword(t, "true") && !isKeyword(t, "false"):
+			return fmt.Errorf("%s is not supported", strings.ToUpper(t.text))
+		case isPunct(t, "{"), isPunct(t, "("):
+			return fmt.Errorf("nested groups and collections are not supported")
+		}
+		s, err := p.term()
+		if err != nil {
+			return err
+		}
+		if err := p.parsePredicateObjects(s); err != nil {
+			return err
+		}
+		if !isPunct(p.peek(), ".") {
+			break
+		}
+		p.next()
+	}
+	return p.expect("}")
+}
+
+// parsePredicateObjects parses the predicates and objects of the triple
+// patterns of the provided subject.
+func (p *parser) parsePredicateObjects(s term) error {
+	for {
+		var pr term
+		if t := p.peek(); t.kind == tokWord && t.text == "a" {
+			p.next()
+			pr = term{kind: iriTerm, text: rdfType}
+		} else {
+			var err error
+			if pr, err = p.term(); err != nil {
+				return err
+			}
+		}
+		for {
+			o, err := p.term()
+			if err != nil {
+				return err
+			}
+			p.patterns = append(p.patterns, pattern{s, pr, o})
+			if !isPunct(p.peek(), ",") {
+				break
+			}
+			p.next()
+		}
+		if !isPunct(p.peek(), ";") {
+			return nil
+		}
+		for isPunct(p.peek(), ";") {
+			p.next()
+		}
+		if t := p.peek(); isPunct(t, ".") || isPunct(t, "}") {
+			return nil
+		}
+	}
+}
+
+// parseModifiers parses the ORDER BY, LIMIT and OFFSET modifiers.
+func (p *parser) parseModifiers() error {
+	if isKeyword(p.peek(), "ORDER") {
+		p.next()
+		if err := p.expect("BY"); err != nil {
+			return err
+		}
+		for {
+			t := p.peek()
+			switch {
+			case t.kind == tokVar:
+				p.next()
+				p.order = append(p.order, orderKey{variable: t.text})
+				continue
+			case isKeyword(t, "ASC"), isKeyword(t, "DESC"):
+				p.next()
+				if err := p.expect("("); err != nil {
+					return err
+				}
+				v := p.next()
+				if v.kind != tokVar {
+					return fmt.Errorf("only variables can be ordered, found %s at offset %d", v, v.pos)
+				}
+				if err := p.expect(")"); err != nil {
+					return err
+				}
+				p.order = append(p.order, orderKey{variable: v.text, desc: isKeyword(t, "DESC")})
+				continue
+			}
+			break
+		}
+		if len(p.order) == 0 {
+			return fmt.Errorf("missing ORDER BY conditions")
+		}
+	}
+	for {
+		t := p.peek()
+		if !isKeyword(t, "LIMIT") && !isKeyword(t, "OFFSET") {
+			return nil
+		}
+		p.next()
+		n := p.next()
+		v, err := strconv.ParseInt(n.text, 10, 64)
+		if n.kind != tokNumber || err != nil || v < 0 {
+			return fmt.Errorf("invalid %s %s at offset %d", strings.ToUpper(t.text), n, n.pos)
+		}
+		if isKeyword(t, "OFFSET") {
+			if v != 0 {
+				return fmt.Errorf("OFFSET is not supported")
+			}
+			continue
+		}
+		p.limit = v
+	}
+}
+
+// term parses a variable, blank node, IRI or literal.
+func (p *parser) term() (term, error) {
+	t := p.next()
+	switch t.kind {
+	case tokVar:
+		return term{kind: varTerm, text: t.text}, nil
+	case tokBlank:
+		return term{kind: blankTerm, text: t.text}, nil
+	case tokIRI, tokPName:
+		iri, err := p.iri(t)
+		if err != nil {
+			return term{}, err
+		}
+		return term{kind: iriTerm, text: iri}, nil
+	case tokString:
+		return p.stringLiteral(t.text)
+	case tokNumber:
+		return numericLiteral(t)
+	case tokWord:
+		if isKeyword(t, "true") || isKeyword(t, "false") {
+			l, err := literal.DefaultBuilder().Build(literal.Bool, isKeyword(t, "true"))
+			return term{kind: literalTerm, lit: l}, err
+		}
+	}
+	return term{}, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
+}
+
+// iri returns the IRI of the provided IRI or prefixed name token.
+func (p *parser) iri(t token) (string, error) {
+	switch t.kind {
+	case tokIRI:
+		return t.text, nil
+	case tokPName:
+		i := strings.Index(t.text, ":")
+		ns, ok := p.prefixes[t.text[:i]]
+		if !ok {
+			return "", fmt.Errorf("undeclared prefix %q at offset %d", t.text[:i], t.pos)
+		}
+		return ns + t.text[i+1:], nil
+	}
+	return "", fmt.Errorf("expected an IRI but found %s at offset %d", t, t.pos)
+}
+
+// stringLiteral parses the optional language tag or datatype of a string and
+// returns its literal. Language tags are dropped.
+func (p *parser) stringLiteral(s string) (term, error) {
+	b := literal.DefaultBuilder()
+	if p.peek().kind == tokLang {
+		p.next()
+	}
+	if !isPunct(p.peek(), "^^") {
+		l, err := b.Build(literal.Text, s)
+		return term{kind: literalTerm, lit: l}, err
+	}
+	p.next()
+	t := p.next()
+	dt, err := p.iri(t)
+	if err != nil {
+		return term{}, err
+	}
+	// Datatypes are mapped as they are when loading N-Triples, so queries
+	// match the literals loaded from them.
+	l, err := triple.RDFLiteral(s, dt, b)
+	if err != nil {
+		return term{}, fmt.Errorf("invalid literal %q^^<%s> at offset %d: %v", s, dt, t.pos, err)
+	}
+	return term{kind: literalTerm, lit: l}, nil
+}
+
+// numericLiteral returns the literal of a numeric token. As in SPARQL, numbers
+// with exponents are doubles, numbers with a decimal point are decimals, and
+// the rest are integers.
+func numericLiteral(t token) (term, error) {
+	dt := "integer"
+	switch {
+	case strings.ContainsAny(t.text, "eE"):
+		dt = "double"
+	case strings.Contains(t.text, "."):
+		dt = "decimal"
+	}
+	l, err := triple.RDFLiteral(t.text, triple.XSDNamespace+dt, literal.DefaultBuilder())
+	if err != nil {
+		return term{}, fmt.Errorf("invalid number %s at offset %d", t.text, t.pos)
+	}
+	return term{kind: literalTerm, lit: l}, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/bql"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+)
+
+func TestTranslate(t *testing.T) {
+	table := []struct {
+		q    string
+		want string
+	}{
+		{
+			q: `SELECT ?o WHERE { </u/joe> <knows> ?o }`,
+			want: `SELECT ?o
+FROM "default"
+WHERE {
+  /u<joe> "knows"@[] ?o
+};`,
+		},
+		{
+			q: `PREFIX u: </u/>
+				SELECT DISTINCT ?who ?o2
+				FROM <?test> FROM </prod/users>
+				WHERE { ?who <knows> u:joe, ?o2 ; a ?t . _:b <"parent"@[]> ?who }
+				ORDER BY DESC(?who) ?o2 LIMIT 10`,
+			want: `SELECT DISTINCT ?who, ?sparqla
+FROM ?test, "/prod/users"
+WHERE {
+  ?who "knows"@[] /u<joe> .
+  ?who "knows"@[] ?sparqla .
+  ?who "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"@[] ?t .
+  ?sparqlb "parent"@[] ?who
+}
+ORDER BY ?who DESC, ?sparqla ASC
+LIMIT "10"^^type:int64;`,
+		},
+		{
+			q: `select * where { </u<joe>> $p "Joe", 42, 1.5, true, "7"^^<http://www.w3.org/2001/XMLSchema#long>, "2.5"^^<http://www.w3.org/2001/XMLSchema#float>, "0.1"^^<http://www.w3.org/2001/XMLSchema#decimal>, "hi"@en }`,
+			want: `SELECT ?p
+FROM "default"
+WHERE {
+  /u<joe> ?p "Joe"^^type:text .
+  /u<joe> ?p "42"^^type:int64 .
+  /u<joe> ?p "1.5"^^type:decimal .
+  /u<joe> ?p "true"^^type:bool .
+  /u<joe> ?p "7"^^type:int64 .
+  /u<joe> ?p "2.5"^^type:float32 .
+  /u<joe> ?p "0.1"^^type:decimal .
+  /u<joe> ?p "hi"^^type:text
+};`,
+		},
+		{
+			q: `ASK { </u/joe> <knows> </u/mary> }`,
+			want: `SELECT ?sparqla
+FROM "default"
+WHERE {
+  /u<joe> AS ?sparqla "knows"@[] /u<mary>
+}
+LIMIT "1"^^type:int64;`,
+		},
+	}
+	for _, entry := range table {
+		q, err := Translate(entry.q, []string{"default"})
+		if err != nil {
+			t.Errorf("sparql.Translate(%q) failed with error %v", entry.q, err)
+			continue
+		}
+		if got := q.Statement; got != entry.want {
+			t.Errorf("sparql.Translate(%q) returned\n%s\nwant\n%s", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	for _, q := range []string{
+		``,
+		`SELECT WHERE { ?s ?p ?o }`,
+		`SELECT ?s WHERE { ?s ?p ?o`,
+		`SELECT ?x WHERE { ?s ?p ?o }`,
+		`SELECT * WHERE { </u/joe> <knows> </u/mary> }`,
+		`SELECT ?s WHERE { ?s ?p ?o FILTER(?o > 3) }`,
+		`SELECT ?s WHERE { ?s ?p ?o OPTIONAL { ?o ?p ?s } }`,
+		`SELECT ?s WHERE { ?s ?p ?o } OFFSET 10`,
+		`SELECT ?s WHERE { ?s ?p "a \"quote\"" }`,
+		`SELECT ?s WHERE { ?s ex:p ?o }`,
+		`SELECT ?s WHERE { <http://example.org> ?p ?o }`,
+		`SELECT ?s WHERE { "joe" ?p ?o }`,
+		`SELECT (COUNT(?s) AS ?n) WHERE { ?s ?p ?o }`,
+		`CONSTRUCT { ?s ?p ?o } WHERE { ?s ?p ?o }`,
+		`SELECT ?s FROM NAMED <g> WHERE { ?s ?p ?o }`,
+	} {
+		if _, err := Translate(q, []string{"default"}); err == nil {
+			t.Errorf("sparql.Translate(%q) should have failed", q)
+		}
+	}
+	if _, err := Translate(`SELECT ?s WHERE { ?s ?p ?o }`, nil); err == nil {
+		t.Errorf("sparql.Translate should have failed without graphs to query")
+	}
+}
+
+func TestResults(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	for _, stm := range []string{
+		`create graph ?test;`,
+		`insert data into ?test {/u<joe> "knows"@[] /u<mary> . /u<joe> "knows"@[] /u<peter> . /u<mary> "knows"@[] /u<peter>};`,
+	} {
+		if _, err := runBQL(ctx, s, stm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	table := []struct {
+		q    string
+		vars []string
+		rows int
+	}{
+		{`SELECT ?s1 ?o WHERE { ?s1 <knows> ?o }`, []string{"s1", "o"}, 3},
+		{`SELECT ?o WHERE { </u/joe> <knows> ?o } ORDER BY DESC(?o) LIMIT 1`, []string{"o"}, 1},
+		{`ASK { </u/joe> <knows> </u/mary> }`, nil, 1},
+		{`ASK { </u/peter> <knows> ?x }`, nil, 0},
+	}
+	for _, entry := range table {
+		q, err := Translate(entry.q, []string{"?test"})
+		if err != nil {
+			t.Fatalf("sparql.Translate(%q) failed with error %v", entry.q, err)
+		}
+		tbl, err := runBQL(ctx, s, q.Statement)
+		if err != nil {
+			t.Fatalf("failed to run %q with error %v", q.Statement, err)
+		}
+		if q.Form == Ask {
+			if got, want := q.Boolean(tbl), entry.rows > 0; got != want {
+				t.Errorf("Query.Boolean for %q returned %v; want %v", entry.q, got, want)
+			}
+			continue
+		}
+		res, err := q.Results(tbl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []string
+		for _, v := range entry.vars {
+			want = append(want, "?"+v)
+		}
+		if got := res.Bindings(); !reflect.DeepEqual(got, want) || res.NumRows() != entry.rows {
+			t.Errorf("Query.Results for %q returned bindings %v with %d rows; want %v with %d rows", entry.q, got, res.NumRows(), want, entry.rows)
+		}
+	}
+}
+
+// runBQL runs the provided BQL statement against the store.
+func runBQL(ctx context.Context, s storage.Store, stm string) (*table.Table, error) {
+	c, err := bql.NewCache(1)
+	if err != nil {
+		return nil, err
+	}
+	st, err := c.Statement(stm)
+	if err != nil {
+		return nil, err
+	}
+	p, err := planner.New(s, st)
+	if err != nil {
+		return nil, err
+	}
+	return p.Excecute(ctx)
+}
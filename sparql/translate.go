@@ -0,0 +1,298 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sparql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// translate returns the BQL translation of the parsed query.
+func (p *parser) translate() (*Query, error) {
+	if len(p.graphs) == 0 {
+		return nil, fmt.Errorf("no graph to query; use a FROM clause")
+	}
+	if len(p.patterns) == 0 {
+		return nil, fmt.Errorf("empty graph patterns are not supported")
+	}
+	bn := newBindingNamer(p.patterns)
+	var (
+		clauses []string
+		ask     string
+	)
+	for i, pt := range p.patterns {
+		s, err := bn.subject(pt.s)
+		if err != nil {
+			return nil, err
+		}
+		pr, err := bn.predicate(pt.p)
+		if err != nil {
+			return nil, err
+		}
+		o, err := bn.object(pt.o)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 && p.form == Ask {
+			if ask = bn.first(pt); ask == "" {
+				// BQL queries return at least one binding, so patterns without
+				// any bind the subject of the first one.
+				ask = bn.fresh()
+				s += " AS " + ask
+			}
+		}
+		clauses = append(clauses, s+" "+pr+" "+o)
+	}
+
+	q := &Query{Form: p.form}
+	switch {
+	case p.form == Ask:
+		q.bindings = []string{ask}
+	case p.vars == nil:
+		if len(bn.vars) == 0 {
+			return nil, fmt.Errorf("SELECT * requires the patterns to use variables")
+		}
+		q.Variables = bn.vars
+	default:
+		q.Variables = p.vars
+	}
+	for _, v := range q.Variables {
+		if !bn.bound[v] {
+			return nil, fmt.Errorf("selected variable ?%s is not used in the patterns", v)
+		}
+		q.bindings = append(q.bindings, bn.binding(v))
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	if p.distinct {
+		b.WriteString("DISTINCT ")
+	}
+	b.WriteString(strings.Join(q.bindings, ", "))
+	var gs []string
+	for _, g := range p.graphs {
+		gs = append(gs, graphReference(g))
+	}
+	fmt.Fprintf(&b, "\nFROM %s\nWHERE {\n  %s\n}", strings.Join(gs, ", "), strings.Join(clauses, " .\n  "))
+	if len(p.order) > 0 {
+		var os []string
+		for _, o := range p.order {
+			dir := "ASC"
+			if o.desc {
+				dir = "DESC"
+			}
+			if !bn.bound[o.variable] {
+				return nil, fmt.Errorf("ordered variable ?%s is not used in the patterns", o.variable)
+			}
+			os = append(os, bn.binding(o.variable)+" "+dir)
+		}
+		b.WriteString("\nORDER BY " + strings.Join(os, ", "))
+	}
+	if p.form == Ask {
+		p.limit = 1
+	}
+	if p.limit >= 0 {
+		fmt.Fprintf(&b, "\nLIMIT \"%d\"^^type:int64", p.limit)
+	}
+	b.WriteString(";")
+	q.Statement = b.String()
+	return q, nil
+}
+
+// bindingNamer assigns BQL bindings to the variables and blank nodes of the
+// patterns. BQL bindings may only contain letters, hence variables that
+// contain other characters are given fresh bindings.
+type bindingNamer struct {
+	// vars contains the variables of the patterns in order of appearance.
+	vars []string
+	// bound contains the variables of the patterns.
+	bound map[string]bool
+	// names maps variables, and blank nodes prefixed by _:, to bindings.
+	names map[string]string
+	used  map[string]bool
+	n     int
+}
+
+// newBindingNamer returns a binding namer for the provided patterns.
+func newBindingNamer(ps []pattern) *bindingNamer {
+	bn := &bindingNamer{
+		bound: make(map[string]bool),
+		names: make(map[string]string),
+		used:  make(map[string]bool),
+	}
+	for _, pt := range ps {
+		for _, t := range []term{pt.s, pt.p, pt.o} {
+			if t.kind == varTerm && !bn.bound[t.text] {
+				bn.bound[t.text] = true
+				bn.vars = append(bn.vars, t.text)
+			}
+		}
+	}
+	for _, v := range bn.vars {
+		if isLetters(v) {
+			bn.names[v] = "?" + v
+			bn.used["?"+v] = true
+		}
+	}
+	return bn
+}
+
+// isLetters returns true if the text is a valid BQL binding name.
+func isLetters(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// fresh returns a binding not used by any variable.
+func (bn *bindingNamer) fresh() string {
+	for {
+		bn.n++
+		var sfx []byte
+		for n := bn.n; n > 0; n /= 26 {
+			sfx = append(sfx, byte('a'+(n-1)%26))
+			n--
+		}
+		if b := "?sparql" + string(sfx); !bn.used[b] {
+			bn.used[b] = true
+			return b
+		}
+	}
+}
+
+// first returns the binding of the first variable or blank node of the
+// pattern, or an empty string if it has none.
+func (bn *bindingNamer) first(pt pattern) string {
+	for _, t := range []term{pt.s, pt.p, pt.o} {
+		if b, ok := bn.variable(t); ok {
+			return b
+		}
+	}
+	return ""
+}
+
+// binding returns the binding of the provided variable or blank node key.
+// Bindings of new keys are fresh ones.
+func (bn *bindingNamer) binding(k string) string {
+	b, ok := bn.names[k]
+	if !ok {
+		b = bn.fresh()
+		bn.names[k] = b
+	}
+	return b
+}
+
+// variable returns the binding of a variable or blank node term.
+func (bn *bindingNamer) variable(t term) (string, bool) {
+	switch t.kind {
+	case varTerm:
+		return bn.binding(t.text), true
+	case blankTerm:
+		return bn.binding("_:" + t.text), true
+	}
+	return "", false
+}
+
+// subject returns the BQL text of a subject.
+func (bn *bindingNamer) subject(t term) (string, error) {
+	if b, ok := bn.variable(t); ok {
+		return b, nil
+	}
+	if t.kind != iriTerm {
+		return "", fmt.Errorf("literals cannot be subjects")
+	}
+	n, err := iriNode(t.text)
+	if err != nil {
+		return "", err
+	}
+	return n.String(), nil
+}
+
+// predicate returns the BQL text of a predicate.
+func (bn *bindingNamer) predicate(t term) (string, error) {
+	if b, ok := bn.variable(t); ok {
+		return b, nil
+	}
+	if t.kind != iriTerm {
+		return "", fmt.Errorf("literals cannot be predicates")
+	}
+	p, err := iriPredicate(t.text)
+	if err != nil {
+		return "", err
+	}
+	return p.String(), nil
+}
+
+// object returns the BQL text of an object.
+func (bn *bindingNamer) object(t term) (string, error) {
+	if b, ok := bn.variable(t); ok {
+		return b, nil
+	}
+	if t.kind == literalTerm {
+		if v, err := t.lit.Text(); err == nil && strings.ContainsAny(v, `"\`) {
+			return "", fmt.Errorf("literals containing quotes or backslashes are not supported")
+		}
+		return t.lit.String(), nil
+	}
+	if p, err := predicate.Parse(t.text); err == nil {
+		return p.String(), nil
+	}
+	n, err := iriNode(t.text)
+	if err != nil {
+		return "", err
+	}
+	return n.String(), nil
+}
+
+// iriNode returns the node an IRI stands for.
+func iriNode(iri string) (*node.Node, error) {
+	if n, err := node.Parse(iri); err == nil {
+		return n, nil
+	}
+	if i := strings.LastIndex(iri, "/"); i > 0 {
+		if n, err := node.NewNodeFromStrings(iri[:i], iri[i+1:]); err == nil {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot map IRI <%s> to a node; use </type/id> or </type<id>>", iri)
+}
+
+// iriPredicate returns the predicate an IRI stands for.
+func iriPredicate(iri string) (*predicate.Predicate, error) {
+	if p, err := predicate.Parse(iri); err == nil {
+		return p, nil
+	}
+	if strings.ContainsAny(iri, `"\`) {
+		return nil, fmt.Errorf("cannot map IRI <%s> to a predicate", iri)
+	}
+	return predicate.NewImmutable(iri)
+}
+
+// graphReference returns how a graph is referred to in BQL. Graphs named like
+// bindings are referred to by their name, others by their quoted name.
+func graphReference(id string) string {
+	if strings.HasPrefix(id, "?") && isLetters(id[1:]) {
+		return id
+	}
+	return strconv.Quote(id)
+}
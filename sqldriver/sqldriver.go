@@ -0,0 +1,352 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqldriver exposes BadWolf stores through database/sql. It registers
+// a driver named badwolf whose data source names are the connection strings
+// accepted by badwolf.Open:
+//
+//	db, err := sql.Open("badwolf", "memory://")
+//	...
+//	rows, err := db.QueryContext(ctx, `select ?name from ?g where {$1 "name"@[] ?name};`, joe)
+//
+// All the connections of a sql.DB share the same store. Placeholders are
+// written $1, $2, ..., and can be bound to nodes, predicates, and literals as
+// well as to the usual Go values. Transactions are not supported.
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/badwolf"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// DriverName contains the name the driver is registered with.
+const DriverName = "badwolf"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// errTransactions is returned when a transaction is requested.
+var errTransactions = errors.New("sqldriver: BadWolf does not support transactions")
+
+// Driver implements driver.Driver and driver.DriverContext.
+type Driver struct{}
+
+// Open returns a connection to a new store for the provided connection
+// string. Connections opened via sql.Open share their store instead.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector opens the store for the provided connection string and
+// returns a connector whose connections share it.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	db, err := badwolf.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &connector{db: db, owned: true}, nil
+}
+
+// NewConnector returns a connector for an already opened DB, to be used with
+// sql.OpenDB. Closing the resulting sql.DB does not close the provided DB.
+func NewConnector(db *badwolf.DB) driver.Connector {
+	return &connector{db: db}
+}
+
+// connector implements driver.Connector.
+type connector struct {
+	db    *badwolf.DB
+	owned bool
+}
+
+// Connect returns a new connection to the store.
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+// Driver returns the underlying driver.
+func (c *connector) Driver() driver.Driver {
+	return &Driver{}
+}
+
+// Close closes the store if it was opened by the connector. It is called when
+// the sql.DB is closed.
+func (c *connector) Close() error {
+	if c.owned {
+		return c.db.Close()
+	}
+	return nil
+}
+
+// conn implements driver.Conn and the context aware extensions that allow
+// running statements without preparing them.
+type conn struct {
+	db *badwolf.DB
+}
+
+// Prepare returns a prepared statement.
+func (c *conn) Prepare(q string) (driver.Stmt, error) {
+	return &stmt{c: c, q: q}, nil
+}
+
+// Close does nothing since the store is shared by all connections.
+func (c *conn) Close() error {
+	return nil
+}
+
+// Begin always fails since transactions are not supported.
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errTransactions
+}
+
+// CheckNamedValue accepts nodes, predicates, and literals as arguments, and
+// leaves the conversion of the rest to database/sql.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nv.Name != "" {
+		return fmt.Errorf("sqldriver: named argument %s not supported; use $%d instead", nv.Name, nv.Ordinal)
+	}
+	switch v := nv.Value.(type) {
+	case *node.Node, *predicate.Predicate, *literal.Literal:
+		return nil
+	case Node:
+		nv.Value = v.N
+		return nil
+	case Predicate:
+		nv.Value = v.P
+		return nil
+	case Literal:
+		nv.Value = v.L
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// ExecContext runs a statement discarding its results.
+func (c *conn) ExecContext(ctx context.Context, q string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.Exec(ctx, q, values(args)...); err != nil {
+		return nil, err
+	}
+	return driver.ResultNoRows, nil
+}
+
+// QueryContext runs a query returning its rows.
+func (c *conn) QueryContext(ctx context.Context, q string, args []driver.NamedValue) (driver.Rows, error) {
+	rs, err := c.db.Query(ctx, q, values(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rs: rs}, nil
+}
+
+// values returns the values of the provided arguments sorted by ordinal.
+func values(args []driver.NamedValue) []interface{} {
+	vs := make([]interface{}, len(args))
+	for _, a := range args {
+		vs[a.Ordinal-1] = a.Value
+	}
+	return vs
+}
+
+// stmt implements driver.Stmt. BadWolf statements are parsed once and cached
+// by the DB, so preparing a statement only records its text.
+type stmt struct {
+	c *conn
+	q string
+}
+
+// Close does nothing.
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1 since placeholders are checked when binding arguments.
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+// Exec runs the statement discarding its results.
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+// ExecContext runs the statement discarding its results.
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, s.q, args)
+}
+
+// Query runs the statement returning its rows.
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+// QueryContext runs the statement returning its rows.
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, s.q, args)
+}
+
+// named returns the provided positional values as named values.
+func named(args []driver.Value) []driver.NamedValue {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return nvs
+}
+
+// rows implements driver.Rows.
+type rows struct {
+	rs *badwolf.Rows
+}
+
+// Columns returns the names of the columns, which are the projected bindings
+// without their leading question mark.
+func (r *rows) Columns() []string {
+	var cs []string
+	for _, b := range r.rs.Columns() {
+		cs = append(cs, strings.TrimPrefix(b, "?"))
+	}
+	return cs
+}
+
+// Close releases the resources held by the rows.
+func (r *rows) Close() error {
+	return r.rs.Close()
+}
+
+// Next copies the values of the next row into dest.
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rs.Next() {
+		if err := r.rs.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	row := r.rs.Row()
+	for i, b := range r.rs.Columns() {
+		dest[i] = value(row[b])
+	}
+	return nil
+}
+
+// value returns the driver value for a cell. Boolean, numeric, text, and
+// time values are returned as their Go values; everything else is returned
+// using its BQL representation, which the Node, Predicate, and Literal types
+// scan back.
+func value(c *table.Cell) driver.Value {
+	switch {
+	case c.IsNull():
+		return nil
+	case c.S != "":
+		return c.S
+	case c.T != nil:
+		return *c.T
+	case c.L != nil:
+		switch c.L.Type() {
+		case literal.Bool, literal.Int64, literal.Float64, literal.Text:
+			return c.L.Interface()
+		}
+		return c.L.String()
+	}
+	return c.String()
+}
+
+// Node scans nodes. N is nil if the scanned value is null.
+type Node struct {
+	N *node.Node
+}
+
+// Scan implements sql.Scanner.
+func (n *Node) Scan(src interface{}) error {
+	s, ok, err := text(src)
+	if err != nil || !ok {
+		n.N = nil
+		return err
+	}
+	n.N, err = node.Parse(s)
+	return err
+}
+
+// Predicate scans predicates. P is nil if the scanned value is null.
+type Predicate struct {
+	P *predicate.Predicate
+}
+
+// Scan implements sql.Scanner.
+func (p *Predicate) Scan(src interface{}) error {
+	s, ok, err := text(src)
+	if err != nil || !ok {
+		p.P = nil
+		return err
+	}
+	p.P, err = predicate.Parse(s)
+	return err
+}
+
+// Literal scans literals. L is nil if the scanned value is null.
+type Literal struct {
+	L *literal.Literal
+}
+
+// Scan implements sql.Scanner.
+func (l *Literal) Scan(src interface{}) error {
+	var err error
+	b := literal.DefaultBuilder()
+	switch v := src.(type) {
+	case nil:
+		l.L = nil
+	case bool:
+		l.L, err = b.Build(literal.Bool, v)
+	case int64:
+		l.L, err = b.Build(literal.Int64, v)
+	case float64:
+		l.L, err = b.Build(literal.Float64, v)
+	case string:
+		if l.L, err = b.Parse(v); err != nil {
+			l.L, err = b.Build(literal.Text, v)
+		}
+	default:
+		return fmt.Errorf("sqldriver.Literal.Scan: cannot scan %T into a literal", src)
+	}
+	return err
+}
+
+// text returns the string held by the provided value, and false if it is
+// null.
+func text(src interface{}) (string, bool, error) {
+	switch v := src.(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		return v, true, nil
+	case []byte:
+		return string(v), true, nil
+	}
+	return "", false, fmt.Errorf("sqldriver: cannot scan %T; expected a string", src)
+}
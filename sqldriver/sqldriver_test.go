@@ -0,0 +1,212 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+)
+
+// testDB returns a database/sql handle to a memory store holding a small
+// graph.
+func testDB(ctx context.Context, t *testing.T) *sql.DB {
+	db, err := sql.Open(DriverName, "memory://")
+	if err != nil {
+		t.Fatalf("sql.Open failed; %v", err)
+	}
+	// Force several connections to check that they share the store.
+	db.SetMaxIdleConns(0)
+	for _, q := range []string{
+		`create graph ?people;`,
+		`insert data into ?people {
+			/u<joe> "knows"@[] /u<mary> .
+			/u<joe> "age"@[] "42"^^type:int64 .
+			/u<mary> "age"@[] "37"^^type:int64 .
+			/u<mary> "name"@[] "Mary"^^type:text .
+			/u<mary> "born"@[] "1980-02-03"^^type:date
+		};`,
+	} {
+		if _, err := db.ExecContext(ctx, q); err != nil {
+			t.Fatalf("sql.DB.ExecContext(%q) failed; %v", q, err)
+		}
+	}
+	return db
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	defer db.Close()
+	joe, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(ctx, `select ?o, ?name, ?age, ?born from ?people where {$1 "knows"@[] ?o . ?o "name"@[] ?name . ?o "age"@[] ?age . ?o "born"@[] ?born};`, joe)
+	if err != nil {
+		t.Fatalf("sql.DB.QueryContext failed; %v", err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"o", "name", "age", "born"}; !reflect.DeepEqual(cols, want) {
+		t.Errorf("sql.Rows.Columns() = %v; want %v", cols, want)
+	}
+	if !rows.Next() {
+		t.Fatalf("sql.DB.QueryContext returned no rows; %v", rows.Err())
+	}
+	var (
+		o    Node
+		name string
+		age  int64
+		born Literal
+	)
+	if err := rows.Scan(&o, &name, &age, &born); err != nil {
+		t.Fatalf("sql.Rows.Scan failed; %v", err)
+	}
+	if o.N.String() != "/u<mary>" || name != "Mary" || age != 37 {
+		t.Errorf("sql.Rows.Scan returned (%v, %q, %d); want (/u<mary>, Mary, 37)", o.N, name, age)
+	}
+	if born.L == nil || born.L.Type() != literal.Date {
+		t.Errorf("sql.Rows.Scan returned %v; want a date literal", born.L)
+	}
+	if rows.Next() {
+		t.Errorf("sql.DB.QueryContext returned more than one row")
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("sql.Rows.Err() = %v", err)
+	}
+}
+
+func TestPreparedStatement(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	defer db.Close()
+	stm, err := db.PrepareContext(ctx, `select ?s from ?people where {?s "age"@[] $1};`)
+	if err != nil {
+		t.Fatalf("sql.DB.PrepareContext failed; %v", err)
+	}
+	defer stm.Close()
+	for _, entry := range []struct {
+		age  int
+		want string
+	}{
+		{42, "/u<joe>"},
+		{37, "/u<mary>"},
+		{7, ""},
+	} {
+		var got string
+		err := stm.QueryRowContext(ctx, entry.age).Scan(&got)
+		if entry.want == "" {
+			if err != sql.ErrNoRows {
+				t.Errorf("sql.Stmt.QueryRowContext(%d) = %q, %v; want no rows", entry.age, got, err)
+			}
+			continue
+		}
+		if err != nil || got != entry.want {
+			t.Errorf("sql.Stmt.QueryRowContext(%d) = %q, %v; want %q", entry.age, got, err, entry.want)
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx, t)
+	defer db.Close()
+	mary := Node{}
+	if err := mary.Scan("/u<mary>"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `delete data from ?people {$1 "age"@[] $2};`, mary, 37); err != nil {
+		t.Fatalf("sql.DB.ExecContext failed; %v", err)
+	}
+	var n int64
+	if err := db.QueryRowContext(ctx, `select count(?s) as ?n from ?people where {?s "age"@[] ?a};`).Scan(&n); err != nil {
+		t.Fatalf("sql.DB.QueryRowContext failed; %v", err)
+	}
+	if n != 1 {
+		t.Errorf("sql.DB.ExecContext should have deleted one age; %d left", n)
+	}
+	if _, err := db.BeginTx(ctx, nil); err == nil {
+		t.Errorf("sql.DB.BeginTx should have failed since transactions are not supported")
+	}
+	if _, err := db.ExecContext(ctx, `drop graph ?people;`, sql.Named("g", 1)); err == nil {
+		t.Errorf("sql.DB.ExecContext should have rejected named arguments")
+	}
+}
+
+func TestNewConnector(t *testing.T) {
+	ctx := context.Background()
+	bdb, err := badwolf.Open("memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bdb.Exec(ctx, `create graph ?g;`); err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(NewConnector(bdb))
+	if _, err := db.ExecContext(ctx, `insert data into ?g {/u<a> "p"@[] /u<b>};`); err != nil {
+		t.Fatalf("sql.DB.ExecContext failed; %v", err)
+	}
+	db.Close()
+	rows, err := bdb.Query(ctx, `select ?s from ?g where {?s "p"@[] /u<b>};`)
+	if err != nil {
+		t.Fatalf("badwolf.DB.Query failed after closing the sql.DB; %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Errorf("badwolf.DB.Query should have returned the triple inserted via database/sql")
+	}
+}
+
+func TestScanners(t *testing.T) {
+	var (
+		n Node
+		p Predicate
+		l Literal
+	)
+	if err := n.Scan("/u<joe>"); err != nil || n.N.String() != "/u<joe>" {
+		t.Errorf("Node.Scan returned %v, %v", n.N, err)
+	}
+	if err := n.Scan(nil); err != nil || n.N != nil {
+		t.Errorf("Node.Scan(nil) returned %v, %v; want nil", n.N, err)
+	}
+	if err := n.Scan(int64(1)); err == nil {
+		t.Errorf("Node.Scan should have rejected an int64")
+	}
+	if err := p.Scan(`"knows"@[]`); err != nil || p.P.String() != `"knows"@[]` {
+		t.Errorf("Predicate.Scan returned %v, %v", p.P, err)
+	}
+	for _, entry := range []struct {
+		src  interface{}
+		want string
+	}{
+		{true, `"true"^^type:bool`},
+		{int64(1), `"1"^^type:int64`},
+		{"foo", `"foo"^^type:text`},
+		{`"2.5"^^type:decimal`, `"2.5"^^type:decimal`},
+	} {
+		if err := l.Scan(entry.src); err != nil || l.L.String() != entry.want {
+			t.Errorf("Literal.Scan(%v) returned %v, %v; want %s", entry.src, l.L, err, entry.want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inference
+
+import (
+	"fmt"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// graph expands a graph with the facts entailed by the schema. Writes are
+// applied to the wrapped graph untouched.
+type graph struct {
+	storage.Graph
+	sch *schema
+}
+
+// unbounded returns a copy of the provided lookup options without a maximum
+// number of elements.
+func unbounded(lo *storage.LookupOptions) *storage.LookupOptions {
+	nlo := *lo
+	nlo.MaxElements = 0
+	return &nlo
+}
+
+// lookup retrieves triples from the wrapped graph.
+type lookup func(lo *storage.LookupOptions) (storage.Triples, error)
+
+// expand returns the triples entailed by the ones retrieved by the provided
+// lookups that match the filter, once each. The wrapped graph is asked for all
+// the triples, and the maximum number of elements of the lookup options is
+// enforced on the entailed ones.
+func (g *graph) expand(lo *storage.LookupOptions, match func(t *triple.Triple) bool, ls ...lookup) (storage.Triples, error) {
+	seen := make(map[string]bool)
+	var res []*triple.Triple
+	for _, l := range ls {
+		ts, err := l(unbounded(lo))
+		if err != nil {
+			return nil, err
+		}
+		for t := range ts {
+			for _, et := range g.sch.entailed(t) {
+				if lo.MaxElements > 0 && len(res) >= lo.MaxElements {
+					break
+				}
+				if k := et.GUID(); !seen[k] && match(et) {
+					seen[k] = true
+					res = append(res, et)
+				}
+			}
+		}
+	}
+	out := make(chan *triple.Triple, len(res))
+	for _, t := range res {
+		out <- t
+	}
+	close(out)
+	return out, nil
+}
+
+// all matches any triple.
+func all(*triple.Triple) bool {
+	return true
+}
+
+// samePredicate returns a filter matching the triples with the provided
+// predicate.
+func samePredicate(p *predicate.Predicate) func(t *triple.Triple) bool {
+	return func(t *triple.Triple) bool {
+		return t.P().GUID() == p.GUID()
+	}
+}
+
+// sameObject returns a filter matching the triples with the provided object.
+func sameObject(o *triple.Object) func(t *triple.Triple) bool {
+	return func(t *triple.Triple) bool {
+		return t.O().GUID() == o.GUID()
+	}
+}
+
+// bySubjectAndPredicate returns the lookups retrieving the triples that may
+// entail the ones with the provided subject and predicate.
+func (g *graph) bySubjectAndPredicate(s *node.Node, p *predicate.Predicate) []lookup {
+	var ls []lookup
+	for _, sp := range g.sch.subPredicates(p) {
+		sp := sp
+		ls = append(ls, func(lo *storage.LookupOptions) (storage.Triples, error) {
+			return g.Graph.TriplesForSubjectAndPredicate(s, sp, lo)
+		})
+	}
+	return ls
+}
+
+// byPredicateAndObject returns the lookups retrieving the triples that may
+// entail the ones with the provided predicate and object.
+func (g *graph) byPredicateAndObject(p *predicate.Predicate, o *triple.Object) []lookup {
+	var ls []lookup
+	for _, sp := range g.sch.subPredicates(p) {
+		for _, so := range g.sch.subObjects(o) {
+			sp, so := sp, so
+			ls = append(ls, func(lo *storage.LookupOptions) (storage.Triples, error) {
+				return g.Graph.TriplesForPredicateAndObject(sp, so, lo)
+			})
+		}
+	}
+	return ls
+}
+
+// byObject returns the lookups retrieving the triples that may entail the ones
+// with the provided object.
+func (g *graph) byObject(o *triple.Object) []lookup {
+	var ls []lookup
+	for _, so := range g.sch.subObjects(o) {
+		so := so
+		ls = append(ls, func(lo *storage.LookupOptions) (storage.Triples, error) {
+			return g.Graph.TriplesForObject(so, lo)
+		})
+	}
+	return ls
+}
+
+// predicates returns the unique predicates of the provided triples, up to the
+// maximum number of elements of the lookup options.
+func predicates(ts storage.Triples, lo *storage.LookupOptions) storage.Predicates {
+	seen := make(map[string]bool)
+	var ps []*predicate.Predicate
+	for t := range ts {
+		if k := t.P().GUID(); !seen[k] && (lo.MaxElements <= 0 || len(ps) < lo.MaxElements) {
+			seen[k] = true
+			ps = append(ps, t.P())
+		}
+	}
+	out := make(chan *predicate.Predicate, len(ps))
+	for _, p := range ps {
+		out <- p
+	}
+	close(out)
+	return out
+}
+
+// Objects returns the objects for the provided subject and predicate,
+// including the ones entailed by the schema.
+func (g *graph) Objects(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Objects, error) {
+	ts, err := g.expand(lo, samePredicate(p), g.bySubjectAndPredicate(s, p)...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan *triple.Object, len(ts))
+	for t := range ts {
+		out <- t.O()
+	}
+	close(out)
+	return out, nil
+}
+
+// Subjects returns the subjects for the provided predicate and object,
+// including the ones entailed by the schema.
+func (g *graph) Subjects(p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions) (storage.Nodes, error) {
+	ts, err := g.expand(unbounded(lo), func(t *triple.Triple) bool {
+		return samePredicate(p)(t) && sameObject(o)(t)
+	}, g.byPredicateAndObject(p, o)...)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ns []*node.Node
+	for t := range ts {
+		if k := t.S().GUID(); !seen[k] && (lo.MaxElements <= 0 || len(ns) < lo.MaxElements) {
+			seen[k] = true
+			ns = append(ns, t.S())
+		}
+	}
+	out := make(chan *node.Node, len(ns))
+	for _, n := range ns {
+		out <- n
+	}
+	close(out)
+	return out, nil
+}
+
+// PredicatesForSubject returns the predicates for the provided subject,
+// including the ones entailed by the schema.
+func (g *graph) PredicatesForSubject(s *node.Node, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ts, err := g.TriplesForSubject(s, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return predicates(ts, lo), nil
+}
+
+// PredicatesForObject returns the predicates for the provided object,
+// including the ones entailed by the schema.
+func (g *graph) PredicatesForObject(o *triple.Object, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ts, err := g.TriplesForObject(o, unbounded(lo))
+	if err != nil {
+		return nil, err
+	}
+	return predicates(ts, lo), nil
+}
+
+// PredicatesForSubjectAndObject returns the predicates for the provided
+// subject and object, including the ones entailed by the schema.
+func (g *graph) PredicatesForSubjectAndObject(s *node.Node, o *triple.Object, lo *storage.LookupOptions) (storage.Predicates, error) {
+	ts, err := g.expand(unbounded(lo), sameObject(o), func(lo *storage.LookupOptions) (storage.Triples, error) {
+		return g.Graph.TriplesForSubject(s, lo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return predicates(ts, lo), nil
+}
+
+// TriplesForSubject returns the triples for the provided subject, including
+// the ones entailed by the schema.
+func (g *graph) TriplesForSubject(s *node.Node, lo *storage.LookupOptions) (storage.Triples, error) {
+	return g.expand(lo, all, func(lo *storage.LookupOptions) (storage.Triples, error) {
+		return g.Graph.TriplesForSubject(s, lo)
+	})
+}
+
+// TriplesForPredicate returns the triples for the provided predicate,
+// including the ones entailed by the schema.
+func (g *graph) TriplesForPredicate(p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	var ls []lookup
+	for _, sp := range g.sch.subPredicates(p) {
+		sp := sp
+		ls = append(ls, func(lo *storage.LookupOptions) (storage.Triples, error) {
+			return g.Graph.TriplesForPredicate(sp, lo)
+		})
+	}
+	return g.expand(lo, samePredicate(p), ls...)
+}
+
+// TriplesForObject returns the triples for the provided object, including the
+// ones entailed by the schema.
+func (g *graph) TriplesForObject(o *triple.Object, lo *storage.LookupOptions) (storage.Triples, error) {
+	return g.expand(lo, sameObject(o), g.byObject(o)...)
+}
+
+// TriplesForSubjectAndPredicate returns the triples for the provided subject
+// and predicate, including the ones entailed by the schema.
+func (g *graph) TriplesForSubjectAndPredicate(s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions) (storage.Triples, error) {
+	return g.expand(lo, samePredicate(p), g.bySubjectAndPredicate(s, p)...)
+}
+
+// TriplesForPredicateAndObject returns the triples for the provided predicate
+// and object, including the ones entailed by the schema.
+func (g *graph) TriplesForPredicateAndObject(p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions) (storage.Triples, error) {
+	return g.expand(lo, func(t *triple.Triple) bool {
+		return samePredicate(p)(t) && sameObject(o)(t)
+	}, g.byPredicateAndObject(p, o)...)
+}
+
+// Exist checks if the provided triple is stored on the graph or entailed by
+// the schema.
+func (g *graph) Exist(t *triple.Triple) (bool, error) {
+	ts, err := g.expand(storage.DefaultLookup, sameObject(t.O()), g.bySubjectAndPredicate(t.S(), t.P())...)
+	if err != nil {
+		return false, err
+	}
+	return len(ts) > 0, nil
+}
+
+// Triples returns all the triples of the graph, including the ones entailed
+// by the schema.
+func (g *graph) Triples() (storage.Triples, error) {
+	return g.expand(storage.DefaultLookup, all, func(*storage.LookupOptions) (storage.Triples, error) {
+		return g.Graph.Triples()
+	})
+}
+
+// Prefetch forwards the hints to the wrapped graph, if it supports them.
+func (g *graph) Prefetch(h *storage.PrefetchHints) error {
+	if pf, ok := g.Graph.(storage.Prefetcher); ok {
+		return pf.Prefetch(h)
+	}
+	return nil
+}
+
+// SetStats stores the statistics on the wrapped graph, if it supports it.
+func (g *graph) SetStats(s *storage.GraphStats) error {
+	if k, ok := g.Graph.(storage.StatsKeeper); ok {
+		return k.SetStats(s)
+	}
+	return fmt.Errorf("inference: graph %q does not support storing statistics", g.ID())
+}
+
+// Stats returns the statistics stored on the wrapped graph, if any.
+func (g *graph) Stats() (*storage.GraphStats, error) {
+	if k, ok := g.Graph.(storage.StatsKeeper); ok {
+		return k.Stats()
+	}
+	return nil, nil
+}
+
+// SetValidator sets the validator of the wrapped graph, if it supports it.
+func (g *graph) SetValidator(v triple.Validator) error {
+	if ve, ok := g.Graph.(storage.ValidationEnforcer); ok {
+		return ve.SetValidator(v)
+	}
+	return fmt.Errorf("inference: graph %q does not support validating triples", g.ID())
+}
+
+// Validator returns the validator of the wrapped graph, if any.
+func (g *graph) Validator() triple.Validator {
+	if ve, ok := g.Graph.(storage.ValidationEnforcer); ok {
+		return ve.Validator()
+	}
+	return nil
+}
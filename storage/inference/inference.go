@@ -0,0 +1,309 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inference expands the graphs of a store with the facts entailed by
+// an RDFS-style schema. The schema is kept on a designated graph of the store
+// using two kinds of triples:
+//
+//	/class<dog> "subClassOf"@[] /class<animal>
+//	/predicate<parent_of> "subPropertyOf"@[] /predicate<relative_of>
+//
+// Classes are nodes, and are assigned to subjects using the type predicate,
+// as in /u<rex> "type"@[] /class<dog>. Properties are named by the IDs of the
+// nodes stating them, regardless of the node type. Both relations are
+// transitive.
+//
+// Looking up a super-property also returns the facts asserted with any of its
+// sub-properties, keeping their time anchors, and looking up the members of a
+// class also returns the members of its subclasses. The entailed triples are
+// computed at lookup time, so they always reflect the current data and are
+// never stored.
+package inference
+
+import (
+	"fmt"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+const (
+	// SubClassOf is the ID of the schema predicate stating that the subject
+	// class is a subclass of the object class.
+	SubClassOf = "subClassOf"
+
+	// SubPropertyOf is the ID of the schema predicate stating that the
+	// subject property is a sub-property of the object property.
+	SubPropertyOf = "subPropertyOf"
+
+	// Type is the ID of the predicate assigning classes to subjects.
+	Type = "type"
+)
+
+// New returns a store whose graphs are expanded with the facts entailed by the
+// schema kept on the provided graph of the wrapped store. The schema is read
+// each time a graph is retrieved, so statements see the schema available when
+// they start. Graphs are returned untouched while the schema graph does not
+// exist or is empty, and the schema graph itself is never expanded. The
+// returned store lists graphs only if the wrapped one does.
+func New(s storage.Store, schema string) storage.Store {
+	is := &store{s: s, schema: schema}
+	if _, ok := s.(storage.GraphLister); ok {
+		return &listingStore{is}
+	}
+	return is
+}
+
+// store expands the graphs of the wrapped store.
+type store struct {
+	s      storage.Store
+	schema string
+}
+
+// Name returns the name of the wrapped store.
+func (s *store) Name() string {
+	return s.s.Name()
+}
+
+// Version returns the version of the wrapped store.
+func (s *store) Version() string {
+	return s.s.Version()
+}
+
+// graph returns the expanded version of the provided graph.
+func (s *store) graph(id string, g storage.Graph) (storage.Graph, error) {
+	if id == s.schema {
+		return g, nil
+	}
+	sg, err := s.s.Graph(s.schema)
+	if err != nil {
+		// There is no schema to expand the graph with yet.
+		return g, nil
+	}
+	sch, err := loadSchema(sg)
+	if err != nil {
+		return nil, fmt.Errorf("inference: failed to load schema graph %q; %v", s.schema, err)
+	}
+	if sch.empty() {
+		return g, nil
+	}
+	return &graph{Graph: g, sch: sch}, nil
+}
+
+// NewGraph creates a new graph.
+func (s *store) NewGraph(id string) (storage.Graph, error) {
+	g, err := s.s.NewGraph(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.graph(id, g)
+}
+
+// Graph returns an existing graph, expanded with the facts entailed by the
+// schema.
+func (s *store) Graph(id string) (storage.Graph, error) {
+	g, err := s.s.Graph(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.graph(id, g)
+}
+
+// DeleteGraph deletes an existing graph.
+func (s *store) DeleteGraph(id string) error {
+	return s.s.DeleteGraph(id)
+}
+
+// CheckHealth checks the health of the wrapped store, if it supports it.
+func (s *store) CheckHealth() error {
+	if hc, ok := s.s.(storage.HealthChecker); ok {
+		return hc.CheckHealth()
+	}
+	return nil
+}
+
+// listingStore expands the graphs of a store able to list its graphs.
+type listingStore struct {
+	*store
+}
+
+// GraphNames returns the IDs of all the graphs in the wrapped store.
+func (s *listingStore) GraphNames() ([]string, error) {
+	return s.s.(storage.GraphLister).GraphNames()
+}
+
+// schema contains the transitive closure of the subclass and sub-property
+// relations.
+type schema struct {
+	classes map[string]*node.Node
+
+	superClasses, subClasses       map[string][]string
+	superProperties, subProperties map[string][]string
+}
+
+// loadSchema reads the schema triples stored on the provided graph.
+func loadSchema(g storage.Graph) (*schema, error) {
+	ts, err := g.Triples()
+	if err != nil {
+		return nil, err
+	}
+	classes := make(map[string]*node.Node)
+	supc, subc := make(map[string][]string), make(map[string][]string)
+	supp, subp := make(map[string][]string), make(map[string][]string)
+	for t := range ts {
+		sub, sup := t.S(), t.O()
+		on, ok := sup.AsNode()
+		if !ok {
+			continue
+		}
+		switch string(t.P().ID()) {
+		case SubClassOf:
+			bk, pk := sub.String(), on.String()
+			classes[bk], classes[pk] = sub, on
+			supc[bk] = append(supc[bk], pk)
+			subc[pk] = append(subc[pk], bk)
+		case SubPropertyOf:
+			bk, pk := sub.ID().String(), on.ID().String()
+			supp[bk] = append(supp[bk], pk)
+			subp[pk] = append(subp[pk], bk)
+		}
+	}
+	return &schema{
+		classes:         classes,
+		superClasses:    closure(supc),
+		subClasses:      closure(subc),
+		superProperties: closure(supp),
+		subProperties:   closure(subp),
+	}, nil
+}
+
+// closure returns the keys reachable from each key of the provided edges,
+// starting with the key itself.
+func closure(edges map[string][]string) map[string][]string {
+	res := make(map[string][]string)
+	for k := range edges {
+		seen := map[string]bool{k: true}
+		reach := []string{k}
+		for i := 0; i < len(reach); i++ {
+			for _, n := range edges[reach[i]] {
+				if !seen[n] {
+					seen[n] = true
+					reach = append(reach, n)
+				}
+			}
+		}
+		res[k] = reach
+	}
+	return res
+}
+
+// empty returns true if the schema states no relation.
+func (s *schema) empty() bool {
+	return len(s.superClasses) == 0 && len(s.superProperties) == 0
+}
+
+// related returns the keys related to the provided one, starting with itself.
+func related(m map[string][]string, k string) []string {
+	if r, ok := m[k]; ok {
+		return r
+	}
+	return []string{k}
+}
+
+// nodes returns the class nodes for the provided keys.
+func (s *schema) nodes(ks []string) []*node.Node {
+	var ns []*node.Node
+	for _, k := range ks {
+		ns = append(ns, s.classes[k])
+	}
+	return ns
+}
+
+// entailed returns the provided triple followed by the triples it entails.
+func (s *schema) entailed(t *triple.Triple) []*triple.Triple {
+	res := []*triple.Triple{t}
+	for _, id := range related(s.superProperties, string(t.P().ID())) {
+		p := t.P()
+		if id != string(p.ID()) {
+			np, err := withID(p, id)
+			if err != nil {
+				continue
+			}
+			p = np
+		}
+		os := []*triple.Object{t.O()}
+		if n, ok := t.O().AsNode(); ok && id == Type {
+			os = nil
+			for _, c := range s.nodes(related(s.superClasses, n.String())) {
+				os = append(os, triple.NewNodeObject(c))
+			}
+		}
+		for _, o := range os {
+			if p == t.P() && o.GUID() == t.O().GUID() {
+				continue
+			}
+			if nt, err := triple.New(t.S(), p, o); err == nil {
+				res = append(res, nt)
+			}
+		}
+	}
+	return res
+}
+
+// subPredicates returns the provided predicate followed by the predicates of
+// its sub-properties, anchored at the same time.
+func (s *schema) subPredicates(p *predicate.Predicate) []*predicate.Predicate {
+	ps := []*predicate.Predicate{p}
+	for _, id := range related(s.subProperties, string(p.ID()))[1:] {
+		if np, err := withID(p, id); err == nil {
+			ps = append(ps, np)
+		}
+	}
+	return ps
+}
+
+// subObjects returns the provided object followed by its subclasses, if the
+// object is a class.
+func (s *schema) subObjects(o *triple.Object) []*triple.Object {
+	os := []*triple.Object{o}
+	n, ok := o.AsNode()
+	if !ok {
+		return os
+	}
+	for _, c := range s.nodes(related(s.subClasses, n.String())[1:]) {
+		os = append(os, triple.NewNodeObject(c))
+	}
+	return os
+}
+
+// withID returns a copy of the predicate with the provided ID, anchored at the
+// same time.
+func withID(p *predicate.Predicate, id string) (*predicate.Predicate, error) {
+	if p.Type() == predicate.Immutable {
+		return predicate.NewImmutable(id)
+	}
+	if start, end, ok := p.Interval(); ok {
+		return predicate.NewInterval(id, start, end)
+	}
+	ta, err := p.TimeAnchor()
+	if err != nil {
+		return nil, err
+	}
+	if g := p.Granularity(); g != predicate.Instant {
+		return predicate.NewTemporalWithGranularity(id, *ta, g)
+	}
+	return predicate.NewTemporal(id, *ta)
+}
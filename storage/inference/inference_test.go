@@ -0,0 +1,247 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inference
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// mustTriples parses the provided triples.
+func mustTriples(t *testing.T, ss ...string) []*triple.Triple {
+	var ts []*triple.Triple
+	for _, s := range ss {
+		tr, err := triple.ParseTriple(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.ParseTriple(%q) failed with error %v", s, err)
+		}
+		ts = append(ts, tr)
+	}
+	return ts
+}
+
+// testGraph returns the expanded data graph of a store holding a small class
+// and property hierarchy.
+func testGraph(t *testing.T) storage.Graph {
+	s := New(memory.NewStore(), "?schema")
+	sg, err := s.NewGraph("?schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sg.AddTriples(mustTriples(t,
+		"/class<dog>\t\"subClassOf\"@[]\t/class<mammal>",
+		"/class<mammal>\t\"subClassOf\"@[]\t/class<animal>",
+		"/predicate<parent_of>\t\"subPropertyOf\"@[]\t/predicate<relative_of>",
+		"/predicate<relative_of>\t\"subPropertyOf\"@[]\t/predicate<knows>",
+	)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.NewGraph("?data"); err != nil {
+		t.Fatal(err)
+	}
+	g, err := s.Graph("?data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddTriples(mustTriples(t,
+		"/u<rex>\t\"type\"@[]\t/class<dog>",
+		"/u<tom>\t\"type\"@[]\t/class<animal>",
+		"/u<joe>\t\"parent_of\"@[]\t/u<mary>",
+		"/u<joe>\t\"knows\"@[]\t/u<peter>",
+		"/u<joe>\t\"parent_of\"@[2015-07-19T13:12:04Z]\t/u<ann>",
+	)); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func mustNode(t *testing.T, s string) *node.Node {
+	n, err := node.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func mustPredicate(t *testing.T, s string) *predicate.Predicate {
+	p, err := predicate.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSubjectsOfClasses(t *testing.T) {
+	g := testGraph(t)
+	table := []struct {
+		class string
+		want  []string
+	}{
+		{"/class<dog>", []string{"/u<rex>"}},
+		{"/class<mammal>", []string{"/u<rex>"}},
+		{"/class<animal>", []string{"/u<rex>", "/u<tom>"}},
+		{"/class<cat>", nil},
+	}
+	for _, entry := range table {
+		ns, err := g.Subjects(mustPredicate(t, `"type"@[]`), triple.NewNodeObject(mustNode(t, entry.class)), storage.DefaultLookup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for n := range ns {
+			got = append(got, n.String())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("Graph.Subjects of class %s returned %v; want %v", entry.class, got, entry.want)
+		}
+	}
+}
+
+func TestObjectsOfSuperProperties(t *testing.T) {
+	g := testGraph(t)
+	joe := mustNode(t, "/u<joe>")
+	table := []struct {
+		p    string
+		want []string
+	}{
+		{`"parent_of"@[]`, []string{"/u<mary>"}},
+		{`"relative_of"@[]`, []string{"/u<mary>"}},
+		{`"knows"@[]`, []string{"/u<mary>", "/u<peter>"}},
+		{`"relative_of"@[2015-07-19T13:12:04Z]`, []string{"/u<ann>"}},
+	}
+	for _, entry := range table {
+		os, err := g.Objects(joe, mustPredicate(t, entry.p), storage.DefaultLookup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for o := range os {
+			got = append(got, o.String())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("Graph.Objects for %s returned %v; want %v", entry.p, got, entry.want)
+		}
+	}
+}
+
+func TestEntailedTriples(t *testing.T) {
+	g := testGraph(t)
+	count := func(ts storage.Triples, err error) int {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for range ts {
+			n++
+		}
+		return n
+	}
+	rex, joe := mustNode(t, "/u<rex>"), mustNode(t, "/u<joe>")
+	animal := triple.NewNodeObject(mustNode(t, "/class<animal>"))
+	if got, want := count(g.TriplesForSubject(rex, storage.DefaultLookup)), 3; got != want {
+		t.Errorf("Graph.TriplesForSubject(%v) returned %d triples; want %d", rex, got, want)
+	}
+	if got, want := count(g.TriplesForSubject(rex, &storage.LookupOptions{MaxElements: 2})), 2; got != want {
+		t.Errorf("Graph.TriplesForSubject(%v) should have returned at most %d triples; got %d", rex, want, got)
+	}
+	if got, want := count(g.TriplesForSubject(joe, storage.DefaultLookup)), 7; got != want {
+		t.Errorf("Graph.TriplesForSubject(%v) returned %d triples; want %d", joe, got, want)
+	}
+	if got, want := count(g.TriplesForPredicate(mustPredicate(t, `"knows"@[]`), storage.DefaultLookup)), 2; got != want {
+		t.Errorf("Graph.TriplesForPredicate returned %d triples; want %d", got, want)
+	}
+	if got, want := count(g.TriplesForObject(animal, storage.DefaultLookup)), 2; got != want {
+		t.Errorf("Graph.TriplesForObject(%v) returned %d triples; want %d", animal, got, want)
+	}
+	if got, want := count(g.Triples()), 11; got != want {
+		t.Errorf("Graph.Triples returned %d triples; want %d", got, want)
+	}
+	ps, err := g.PredicatesForSubjectAndObject(joe, triple.NewNodeObject(mustNode(t, "/u<mary>")), storage.DefaultLookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for p := range ps {
+		got = append(got, p.String())
+	}
+	sort.Strings(got)
+	if want := []string{`"knows"@[]`, `"parent_of"@[]`, `"relative_of"@[]`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Graph.PredicatesForSubjectAndObject returned %v; want %v", got, want)
+	}
+	for _, entry := range []struct {
+		t    string
+		want bool
+	}{
+		{"/u<joe>\t\"relative_of\"@[]\t/u<mary>", true},
+		{"/u<joe>\t\"knows\"@[2015-07-19T13:12:04Z]\t/u<ann>", true},
+		{"/u<rex>\t\"type\"@[]\t/class<animal>", true},
+		{"/u<joe>\t\"parent_of\"@[]\t/u<peter>", false},
+		{"/u<tom>\t\"type\"@[]\t/class<dog>", false},
+	} {
+		tr := mustTriples(t, entry.t)[0]
+		if got, err := g.Exist(tr); err != nil || got != entry.want {
+			t.Errorf("Graph.Exist(%v) returned %v, %v; want %v", tr, got, err, entry.want)
+		}
+	}
+}
+
+func TestUnexpandedGraphs(t *testing.T) {
+	s := New(memory.NewStore(), "?schema")
+	if _, ok := s.(storage.GraphLister); !ok {
+		t.Errorf("inference.New should list graphs if the wrapped store does")
+	}
+	g, err := s.NewGraph("?data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.(*graph); ok {
+		t.Errorf("graphs should not be expanded before the schema graph exists")
+	}
+	sg, err := s.NewGraph("?schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, err = s.Graph("?data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.(*graph); ok {
+		t.Errorf("graphs should not be expanded while the schema graph is empty")
+	}
+	if err := sg.AddTriples(mustTriples(t, "/class<dog>\t\"subClassOf\"@[]\t/class<animal>")); err != nil {
+		t.Fatal(err)
+	}
+	if g, err = s.Graph("?data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.(*graph); !ok {
+		t.Errorf("graphs should be expanded once the schema graph states relations")
+	}
+	if sg, err = s.Graph("?schema"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sg.(*graph); ok {
+		t.Errorf("the schema graph should never be expanded")
+	}
+}
@@ -18,6 +18,7 @@ package memory
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -37,15 +38,27 @@ func init() {
 type memoryStore struct {
 	graphs map[string]storage.Graph
 	rwmu   sync.RWMutex
+	opts   storage.Options
 }
 
 // NewStore creates a new memory store.
 func NewStore() storage.Store {
+	return NewStoreWithOptions(storage.Options{})
+}
+
+// NewStoreWithOptions creates a new memory store with the provided options.
+func NewStoreWithOptions(opts storage.Options) storage.Store {
 	return &memoryStore{
 		graphs: make(map[string]storage.Graph),
+		opts:   opts,
 	}
 }
 
+// Options returns the options the store was created with.
+func (s *memoryStore) Options() storage.Options {
+	return s.opts
+}
+
 // Name returns the ID of the backend being used.
 func (s *memoryStore) Name() string {
 	return "MEMORY_STORE"
@@ -89,6 +102,18 @@ func (s *memoryStore) Graph(id string) (storage.Graph, error) {
 	return nil, fmt.Errorf("memory.Graph(%q): graph does not exist", id)
 }
 
+// GraphNames returns the IDs of all the graphs in the store, sorted.
+func (s *memoryStore) GraphNames() ([]string, error) {
+	s.rwmu.RLock()
+	defer s.rwmu.RUnlock()
+	var ids []string
+	for id := range s.graphs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
 // DeleteGraph with delete an existing graph. Deleting a non existing graph
 // should return and error.
 func (s *memoryStore) DeleteGraph(id string) error {
@@ -112,6 +137,18 @@ type memory struct {
 	idxSP map[string]map[string]*triple.Triple
 	idxPO map[string]map[string]*triple.Triple
 	idxSO map[string]map[string]*triple.Triple
+	stats *storage.GraphStats
+	v     triple.Validator
+
+	// wmu protects the registered watchers and the changes pending to be
+	// notified to them, which are queued in the order they were applied. nmu
+	// serializes notifying them, so each change is notified after the previous
+	// ones.
+	wmu      sync.Mutex
+	watchers map[int]func(*storage.Change)
+	nextW    int
+	pending  []*storage.Change
+	nmu      sync.Mutex
 }
 
 // ID returns the id for this graph.
@@ -121,13 +158,22 @@ func (m *memory) ID() string {
 
 // AddTriples adds the triples to the storage.
 func (m *memory) AddTriples(ts []*triple.Triple) error {
+	if v := m.Validator(); v != nil {
+		if err := triple.ValidateAll(v, ts); err != nil {
+			return fmt.Errorf("memory.AddTriples(%q): %v", m.id, err)
+		}
+	}
+	var added []*triple.Triple
+	m.rwmu.Lock()
 	for _, t := range ts {
 		guid := t.GUID()
 		sGUID := t.S().GUID()
 		pGUID := t.P().GUID()
 		oGUID := t.O().GUID()
 		// Update master index
-		m.rwmu.Lock()
+		if _, ok := m.idx[guid]; !ok {
+			added = append(added, t)
+		}
 		m.idx[guid] = t
 
 		if _, ok := m.idxS[sGUID]; !ok {
@@ -162,21 +208,26 @@ func (m *memory) AddTriples(ts []*triple.Triple) error {
 			m.idxSO[key] = make(map[string]*triple.Triple)
 		}
 		m.idxSO[key][guid] = t
-
-		m.rwmu.Unlock()
 	}
+	m.queue(&storage.Change{Graph: m.id, Added: added})
+	m.rwmu.Unlock()
+	m.notify()
 	return nil
 }
 
 // RemoveTriples removes the trilpes from the storage.
 func (m *memory) RemoveTriples(ts []*triple.Triple) error {
+	var removed []*triple.Triple
+	m.rwmu.Lock()
 	for _, t := range ts {
 		guid := t.GUID()
 		sGUID := t.S().GUID()
 		pGUID := t.P().GUID()
 		oGUID := t.O().GUID()
 		// Update master index
-		m.rwmu.Lock()
+		if _, ok := m.idx[guid]; ok {
+			removed = append(removed, t)
+		}
 		delete(m.idx, guid)
 		delete(m.idxS[sGUID], guid)
 		delete(m.idxP[pGUID], guid)
@@ -199,9 +250,10 @@ func (m *memory) RemoveTriples(ts []*triple.Triple) error {
 		if len(m.idxSO[key]) == 0 {
 			delete(m.idxSO, key)
 		}
-
-		m.rwmu.Unlock()
 	}
+	m.queue(&storage.Change{Graph: m.id, Removed: removed})
+	m.rwmu.Unlock()
+	m.notify()
 	return nil
 }
 
@@ -229,22 +281,15 @@ func newChecker(o *storage.LookupOptions) *checker {
 // CheckAndUpdate checks if a predicate should be considered and it also updates
 // the internal state in case counts are needed.
 func (c *checker) CheckAndUpdate(p *predicate.Predicate) bool {
-	if c.max {
-		if c.c <= 0 {
-			return false
-		}
-		c.c--
-	}
-	if p.Type() == predicate.Immutable {
-		return true
-	}
-	t, _ := p.TimeAnchor()
-	if c.o.LowerAnchor != nil && t.Before(*c.o.LowerAnchor) {
+	if c.max && c.c <= 0 {
 		return false
 	}
-	if c.o.UpperAnchor != nil && t.After(*c.o.UpperAnchor) {
+	if !p.WithinBounds(c.o.Granularity.Widen(c.o.LowerAnchor, c.o.UpperAnchor)) {
 		return false
 	}
+	if c.max {
+		c.c--
+	}
 	return true
 }
 
@@ -463,3 +508,101 @@ func (m *memory) Triples() (storage.Triples, error) {
 	}()
 	return triples, nil
 }
+
+// SetStats replaces the statistics stored for the graph.
+func (m *memory) SetStats(s *storage.GraphStats) error {
+	m.rwmu.Lock()
+	defer m.rwmu.Unlock()
+	m.stats = s
+	return nil
+}
+
+// Stats returns the last statistics stored for the graph.
+func (m *memory) Stats() (*storage.GraphStats, error) {
+	m.rwmu.RLock()
+	defer m.rwmu.RUnlock()
+	return m.stats, nil
+}
+
+// SetValidator sets the validator enforced on the triples added to the graph.
+func (m *memory) SetValidator(v triple.Validator) error {
+	m.rwmu.Lock()
+	defer m.rwmu.Unlock()
+	m.v = v
+	return nil
+}
+
+// Validator returns the validator enforced on the triples added to the graph.
+func (m *memory) Validator() triple.Validator {
+	m.rwmu.RLock()
+	defer m.rwmu.RUnlock()
+	return m.v
+}
+
+// Watch registers the function to be called after each change applied to the
+// graph.
+func (m *memory) Watch(f func(c *storage.Change)) (func(), error) {
+	if f == nil {
+		return nil, fmt.Errorf("memory.Watch(%q): nil watcher function", m.id)
+	}
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	if m.watchers == nil {
+		m.watchers = make(map[int]func(*storage.Change))
+	}
+	id := m.nextW
+	m.nextW++
+	m.watchers[id] = f
+	return func() {
+		m.wmu.Lock()
+		defer m.wmu.Unlock()
+		delete(m.watchers, id)
+	}, nil
+}
+
+// queue adds the provided change to the ones pending to be notified, unless
+// it did not add or remove any triple or no watcher is registered. It must be
+// called holding the graph lock, so changes are queued in the order they were
+// applied.
+func (m *memory) queue(c *storage.Change) {
+	if len(c.Added) == 0 && len(c.Removed) == 0 {
+		return
+	}
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	if len(m.watchers) > 0 {
+		m.pending = append(m.pending, c)
+	}
+}
+
+// notify calls the registered watchers with the pending changes, in the order
+// they were queued. Once it returns, the changes queued by the caller have
+// been notified. Watchers are called without holding the graph lock, so
+// updates are not blocked by them.
+func (m *memory) notify() {
+	m.nmu.Lock()
+	defer m.nmu.Unlock()
+	for {
+		m.wmu.Lock()
+		if len(m.pending) == 0 {
+			m.wmu.Unlock()
+			return
+		}
+		c := m.pending[0]
+		m.pending[0] = nil
+		m.pending = m.pending[1:]
+		ids := make([]int, 0, len(m.watchers))
+		for id := range m.watchers {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		fs := make([]func(*storage.Change), 0, len(ids))
+		for _, id := range ids {
+			fs = append(fs, m.watchers[id])
+		}
+		m.wmu.Unlock()
+		for _, f := range fs {
+			f(c)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package memory
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -48,6 +50,26 @@ func TestMemoryStore(t *testing.T) {
 	}
 }
 
+func TestMemoryStoreGraphNames(t *testing.T) {
+	s := NewStore()
+	for _, id := range []string{"?b", "?a", "?c"} {
+		if _, err := s.NewGraph(id); err != nil {
+			t.Fatalf("memoryStore.NewGraph: should never fail to crate a graph; %s", err)
+		}
+	}
+	gl, ok := s.(storage.GraphLister)
+	if !ok {
+		t.Fatalf("memoryStore should implement storage.GraphLister")
+	}
+	ids, err := gl.GraphNames()
+	if err != nil {
+		t.Fatalf("memoryStore.GraphNames: should never fail; %s", err)
+	}
+	if got, want := ids, []string{"?a", "?b", "?c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("memoryStore.GraphNames returned %v; want %v", got, want)
+	}
+}
+
 func TestDefaultLookupChecker(t *testing.T) {
 	dlu := storage.DefaultLookup
 	c := newChecker(dlu)
@@ -84,6 +106,31 @@ func TestLimitedItemsLookupChecker(t *testing.T) {
 	}
 }
 
+func TestLimitedItemsTemporalLookupChecker(t *testing.T) {
+	lpa, err := predicate.Parse("\"foo\"@[2013-07-19T13:12:04.669618843-07:00]")
+	if err != nil {
+		t.Fatalf("Failed to parse fixture predicate with error %v", err)
+	}
+	upa, err := predicate.Parse("\"foo\"@[2015-07-19T13:12:04.669618843-07:00]")
+	if err != nil {
+		t.Fatalf("Failed to parse fixture predicate with error %v", err)
+	}
+	ub, _ := upa.TimeAnchor()
+	blu := &storage.LookupOptions{MaxElements: 1, LowerAnchor: ub}
+	c := newChecker(blu)
+	for i := 0; i < 10; i++ {
+		if c.CheckAndUpdate(lpa) {
+			t.Errorf("Bounded lookup %v should never accept predicate %v", blu, lpa)
+		}
+	}
+	if !c.CheckAndUpdate(upa) {
+		t.Errorf("Rejected predicates should not exhaust bounded lookup %v", blu)
+	}
+	if c.CheckAndUpdate(upa) {
+		t.Errorf("Bounded lookup %v should never succeed after being exahausted", blu)
+	}
+}
+
 func TestTemporalBoundedLookupChecker(t *testing.T) {
 	lpa, err := predicate.Parse("\"foo\"@[2013-07-19T13:12:04.669618843-07:00]")
 	if err != nil {
@@ -125,6 +172,34 @@ func TestTemporalBoundedLookupChecker(t *testing.T) {
 	}
 }
 
+func TestGranularityBoundedLookupChecker(t *testing.T) {
+	morning, err := predicate.Parse("\"foo\"@[2015-07-19T09:00:00Z]")
+	if err != nil {
+		t.Fatalf("Failed to parse fixture predicate with error %v", err)
+	}
+	evening, err := predicate.Parse("\"foo\"@[2015-07-19T21:00:00Z]")
+	if err != nil {
+		t.Fatalf("Failed to parse fixture predicate with error %v", err)
+	}
+	noon := time.Date(2015, 7, 19, 12, 0, 0, 0, time.UTC)
+	table := []struct {
+		lo   *storage.LookupOptions
+		p    *predicate.Predicate
+		want bool
+	}{
+		{&storage.LookupOptions{UpperAnchor: &noon}, evening, false},
+		{&storage.LookupOptions{UpperAnchor: &noon, Granularity: predicate.Day}, evening, true},
+		{&storage.LookupOptions{LowerAnchor: &noon}, morning, false},
+		{&storage.LookupOptions{LowerAnchor: &noon, Granularity: predicate.Day}, morning, true},
+		{&storage.LookupOptions{LowerAnchor: &noon, Granularity: predicate.Hour}, morning, false},
+	}
+	for _, entry := range table {
+		if got := newChecker(entry.lo).CheckAndUpdate(entry.p); got != entry.want {
+			t.Errorf("checker.CheckAndUpdate(%v) with lookup options %+v returned %v; want %v", entry.p, entry.lo, got, entry.want)
+		}
+	}
+}
+
 func getTestTriples(t *testing.T) []*triple.Triple {
 	ts := []*triple.Triple{}
 	ss := []string{
@@ -400,3 +475,130 @@ func TestTriples(t *testing.T) {
 		t.Errorf("g.TriplesForPredicateAndObject(%s, %s) failed to retrieve 1 predicates, got %d instead", ts[0].P(), ts[0].O(), cnt)
 	}
 }
+
+func TestTriplesNormalizeTimeZones(t *testing.T) {
+	var ts []*triple.Triple
+	for _, s := range []string{
+		"/u<john>\t\"met\"@[2015-07-19T20:12:04Z]\t/u<mary>",
+		"/u<john>\t\"met\"@[2015-07-19T13:12:04-07:00]\t/u<mary>",
+	} {
+		trpl, err := triple.ParseTriple(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.ParseTriple(%q) failed with error %v", s, err)
+		}
+		ts = append(ts, trpl)
+	}
+	g, _ := NewStore().NewGraph("test")
+	if err := g.AddTriples(ts); err != nil {
+		t.Errorf("g.AddTriples(_) failed failed to add test triples with error %v", err)
+	}
+	trpls, err := g.Triples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnt := 0
+	for _ = range trpls {
+		cnt++
+	}
+	if cnt != 1 {
+		t.Errorf("g.Triples should have returned 1 triple for the same instant on different time zones, got %d instead", cnt)
+	}
+	for _, trpl := range ts {
+		if b, err := g.Exist(trpl); err != nil || !b {
+			t.Errorf("g.Exist(%s) should have returned true; got %v, %v", trpl, b, err)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ts := getTestTriples(t)
+	g, _ := NewStore().NewGraph("test")
+	w, ok := g.(storage.Watcher)
+	if !ok {
+		t.Fatalf("memory graphs should implement storage.Watcher")
+	}
+	var cs []*storage.Change
+	cancel, err := w.Watch(func(c *storage.Change) {
+		cs = append(cs, c)
+	})
+	if err != nil {
+		t.Fatalf("g.Watch failed with error %v", err)
+	}
+	if err := g.AddTriples(ts[:2]); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	// Triples already stored are not notified again.
+	if err := g.AddTriples(ts[:3]); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	// Removing triples not stored does not produce a change.
+	if err := g.RemoveTriples(ts[4:]); err != nil {
+		t.Fatalf("g.RemoveTriples(_) failed to remove test triples with error %v", err)
+	}
+	if err := g.RemoveTriples(ts[:1]); err != nil {
+		t.Fatalf("g.RemoveTriples(_) failed to remove test triples with error %v", err)
+	}
+	cancel()
+	if err := g.AddTriples(ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	want := []struct {
+		added, removed int
+	}{
+		{added: 2},
+		{added: 1},
+		{removed: 1},
+	}
+	if got, want := len(cs), len(want); got != want {
+		t.Fatalf("g.Watch notified the wrong number of changes; got %d, want %d", got, want)
+	}
+	for i, c := range cs {
+		if c.Graph != "test" {
+			t.Errorf("g.Watch notified change %d for the wrong graph; got %q, want \"test\"", i, c.Graph)
+		}
+		if got, want := len(c.Added), want[i].added; got != want {
+			t.Errorf("g.Watch notified the wrong number of added triples on change %d; got %d, want %d", i, got, want)
+		}
+		if got, want := len(c.Removed), want[i].removed; got != want {
+			t.Errorf("g.Watch notified the wrong number of removed triples on change %d; got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestValidator(t *testing.T) {
+	ts := getTestTriples(t)
+	g, _ := NewStore().NewGraph("test")
+	ve, ok := g.(storage.ValidationEnforcer)
+	if !ok {
+		t.Fatalf("memory graphs should implement storage.ValidationEnforcer")
+	}
+	bad := ts[1]
+	v := triple.ValidatorFunc(func(t *triple.Triple) error {
+		if t.GUID() == bad.GUID() {
+			return fmt.Errorf("rejected %s", t)
+		}
+		return nil
+	})
+	if err := ve.SetValidator(v); err != nil {
+		t.Fatalf("g.SetValidator failed with error %v", err)
+	}
+	if ve.Validator() == nil {
+		t.Errorf("g.Validator should return the validator set")
+	}
+	if err := g.AddTriples(ts[:2]); err == nil {
+		t.Errorf("g.AddTriples(_) should have rejected %s", bad)
+	}
+	// Rejected batches do not add any triple.
+	if ok, err := g.Exist(ts[0]); err != nil || ok {
+		t.Errorf("g.AddTriples(_) added %s from a rejected batch", ts[0])
+	}
+	if err := g.AddTriples(ts[:1]); err != nil {
+		t.Errorf("g.AddTriples(_) failed to add valid triples with error %v", err)
+	}
+	if err := ve.SetValidator(nil); err != nil {
+		t.Fatalf("g.SetValidator failed with error %v", err)
+	}
+	if err := g.AddTriples(ts[:2]); err != nil {
+		t.Errorf("g.AddTriples(_) failed to add triples without a validator with error %v", err)
+	}
+}
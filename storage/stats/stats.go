@@ -0,0 +1,125 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stats collects statistics about the data stored on graphs to guide
+// query planning.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/google/badwolf/storage"
+)
+
+// DefaultMaxSubjects contains the default maximum number of subjects sampled
+// to build the fanout histogram of a graph.
+const DefaultMaxSubjects = 10000
+
+// sample contains the hash of a sampled subject and its number of triples.
+type sample struct {
+	h uint64
+	n int64
+}
+
+// Collect scans all the triples of the provided graph and returns the
+// statistics about its data. Triples are counted per predicate exactly, while
+// the fanout of subjects is tracked for at most maxSubjects sampled subjects.
+// Subjects are sampled by hashing their GUID, and the sample rate is doubled
+// every time the number of sampled subjects exceeds maxSubjects.
+func Collect(ctx context.Context, g storage.Graph, maxSubjects int) (*storage.GraphStats, error) {
+	if maxSubjects <= 0 {
+		return nil, fmt.Errorf("stats.Collect: invalid maximum number of subjects %d; it should be greater than 0", maxSubjects)
+	}
+	ts, err := g.Triples()
+	if err != nil {
+		return nil, fmt.Errorf("stats.Collect: failed to retrieve the triples of graph %q with error %v", g.ID(), err)
+	}
+	s := &storage.GraphStats{
+		Predicates: make(map[string]int64),
+		SampleRate: 1,
+	}
+	smpls := make(map[string]*sample)
+	for t := range ts {
+		if err := ctx.Err(); err != nil {
+			// Keep draining the triples, so the goroutine producing them
+			// does not block forever.
+			go func() {
+				for range ts {
+				}
+			}()
+			return nil, err
+		}
+		s.Triples++
+		s.Predicates[string(t.P().ID())]++
+		guid := t.S().GUID()
+		if smp, ok := smpls[guid]; ok {
+			smp.n++
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(guid))
+		smp := &sample{h: h.Sum64(), n: 1}
+		if smp.h%uint64(s.SampleRate) != 0 {
+			continue
+		}
+		smpls[guid] = smp
+		for len(smpls) > maxSubjects {
+			s.SampleRate *= 2
+			for k, v := range smpls {
+				if v.h%uint64(s.SampleRate) != 0 {
+					delete(smpls, k)
+				}
+			}
+		}
+	}
+	s.Subjects = int64(len(smpls)) * s.SampleRate
+	s.Fanout = fanoutHistogram(smpls, s.SampleRate)
+	s.Collected = time.Now()
+	return s, nil
+}
+
+// fanoutHistogram returns the histogram of the number of triples of the
+// sampled subjects, scaled by the sample rate. Buckets are bounded by powers
+// of two.
+func fanoutHistogram(smpls map[string]*sample, rate int64) []storage.FanoutBucket {
+	var bs []storage.FanoutBucket
+	for _, smp := range smpls {
+		i, max := 0, int64(1)
+		for max < smp.n {
+			i, max = i+1, max*2
+		}
+		for len(bs) <= i {
+			bs = append(bs, storage.FanoutBucket{MaxFanout: int64(1) << uint(len(bs))})
+		}
+		bs[i].Subjects += rate
+	}
+	return bs
+}
+
+// Analyze collects the statistics of the provided graph and stores them on
+// it. It fails if the graph is not able to keep statistics.
+func Analyze(ctx context.Context, g storage.Graph) error {
+	k, ok := g.(storage.StatsKeeper)
+	if !ok {
+		return fmt.Errorf("stats.Analyze: graph %q does not support storing statistics", g.ID())
+	}
+	s, err := Collect(ctx, g, DefaultMaxSubjects)
+	if err != nil {
+		return err
+	}
+	return k.SetStats(s)
+}
@@ -0,0 +1,166 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+const testTriples = `/u<joe>	"parent_of"@[]	/u<mary>
+/u<joe>	"parent_of"@[]	/u<peter>
+/u<joe>	"name"@[]	"Joe"^^type:text
+/u<joe>	"age"@[]	"42"^^type:int64
+/u<joe>	"height"@[]	"180"^^type:int64
+/u<peter>	"parent_of"@[]	/u<john>
+/u<peter>	"name"@[]	"Peter"^^type:text
+/u<mary>	"name"@[]	"Mary"^^type:text
+`
+
+func testGraph(t *testing.T) storage.Graph {
+	g, err := memory.NewStore().NewGraph("?test")
+	if err != nil {
+		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	if _, err := io.ReadIntoGraph(g, bytes.NewBufferString(testTriples), literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	return g
+}
+
+func TestCollect(t *testing.T) {
+	g := testGraph(t)
+	s, err := Collect(context.Background(), g, DefaultMaxSubjects)
+	if err != nil {
+		t.Fatalf("stats.Collect failed with error %v", err)
+	}
+	if got, want := s.Triples, int64(8); got != want {
+		t.Errorf("stats.Collect returned the wrong number of triples; got %d, want %d", got, want)
+	}
+	if got, want := s.Subjects, int64(3); got != want {
+		t.Errorf("stats.Collect returned the wrong number of subjects; got %d, want %d", got, want)
+	}
+	if got, want := s.SampleRate, int64(1); got != want {
+		t.Errorf("stats.Collect returned the wrong sample rate; got %d, want %d", got, want)
+	}
+	wantPredicates := map[string]int64{"parent_of": 3, "name": 3, "age": 1, "height": 1}
+	if got, want := s.Predicates, wantPredicates; !reflect.DeepEqual(got, want) {
+		t.Errorf("stats.Collect returned the wrong predicate frequencies; got %v, want %v", got, want)
+	}
+	wantFanout := []storage.FanoutBucket{{MaxFanout: 1, Subjects: 1}, {MaxFanout: 2, Subjects: 1}, {MaxFanout: 4}, {MaxFanout: 8, Subjects: 1}}
+	if got, want := s.Fanout, wantFanout; !reflect.DeepEqual(got, want) {
+		t.Errorf("stats.Collect returned the wrong fanout histogram; got %v, want %v", got, want)
+	}
+}
+
+func TestCollectSampling(t *testing.T) {
+	g := testGraph(t)
+	s, err := Collect(context.Background(), g, 1)
+	if err != nil {
+		t.Fatalf("stats.Collect failed with error %v", err)
+	}
+	if s.SampleRate < 2 {
+		t.Errorf("stats.Collect should have increased the sample rate to keep a single subject; got %d", s.SampleRate)
+	}
+	if got, want := s.Triples, int64(8); got != want {
+		t.Errorf("stats.Collect should count all the triples while sampling subjects; got %d, want %d", got, want)
+	}
+	var n int64
+	for _, b := range s.Fanout {
+		n += b.Subjects
+	}
+	if got, want := n, s.Subjects; got != want {
+		t.Errorf("stats.Collect returned a fanout histogram inconsistent with the estimated subjects; got %d, want %d", got, want)
+	}
+	if s.Subjects > s.SampleRate {
+		t.Errorf("stats.Collect sampled more than one subject; got %d estimated subjects with sample rate %d", s.Subjects, s.SampleRate)
+	}
+	if _, err := Collect(context.Background(), g, 0); err == nil {
+		t.Errorf("stats.Collect should fail for an invalid maximum number of subjects")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Collect(ctx, g, DefaultMaxSubjects); err != context.Canceled {
+		t.Errorf("stats.Collect should have failed with %v for a cancelled context; got %v", context.Canceled, err)
+	}
+}
+
+// plainGraph hides the optional interfaces implemented by the wrapped graph.
+type plainGraph struct {
+	storage.Graph
+}
+
+// feedGraph returns the triples of the wrapped graph on an unbuffered channel,
+// closing done once all of them have been sent.
+type feedGraph struct {
+	storage.Graph
+	done chan struct{}
+}
+
+func (g *feedGraph) Triples() (storage.Triples, error) {
+	ts, err := g.Graph.Triples()
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan *triple.Triple)
+	go func() {
+		defer close(g.done)
+		defer close(c)
+		for t := range ts {
+			c <- t
+		}
+	}()
+	return c, nil
+}
+
+func TestCollectCancelledDrains(t *testing.T) {
+	g := &feedGraph{Graph: testGraph(t), done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Collect(ctx, g, DefaultMaxSubjects); err != context.Canceled {
+		t.Fatalf("stats.Collect should have failed with %v for a cancelled context; got %v", context.Canceled, err)
+	}
+	select {
+	case <-g.done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("stats.Collect did not drain the triples of the graph after the context was cancelled")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	g := testGraph(t)
+	if err := Analyze(context.Background(), g); err != nil {
+		t.Fatalf("stats.Analyze failed with error %v", err)
+	}
+	s, err := g.(storage.StatsKeeper).Stats()
+	if err != nil {
+		t.Fatalf("StatsKeeper.Stats failed with error %v", err)
+	}
+	if s == nil || s.Triples != 8 {
+		t.Errorf("stats.Analyze stored the wrong statistics; got %v", s)
+	}
+	if err := Analyze(context.Background(), &plainGraph{g}); err == nil {
+		t.Errorf("stats.Analyze should fail for graphs unable to store statistics")
+	}
+}
@@ -42,10 +42,17 @@ type LookupOptions struct {
 	MaxElements int
 
 	// LowerAnchor if provided represents the lower time anchor to be considered.
+	// Both anchors are inclusive, and temporal predicates covering a period or a
+	// validity interval are considered if any of its instants is within them.
 	LowerAnchor *time.Time
 
 	// UpperArnchor if provided represents the upper time anchor to be considered.
 	UpperAnchor *time.Time
+
+	// Granularity if provided widens both time anchors to the whole periods of
+	// the given granularity that contain them, so temporal predicates anchored
+	// in the same period as a bound are considered.
+	Granularity predicate.Granularity
 }
 
 // DefaultLookup provides the default lookup behavior.
@@ -194,3 +201,168 @@ type Graph interface {
 	// Triples allows to iterate over all available triples.
 	Triples() (Triples, error)
 }
+
+// PrefetchHints lists the subjects and predicates a statement is expected to
+// touch during its execution.
+type PrefetchHints struct {
+	// Subjects contains the subjects that are likely to be looked up.
+	Subjects []*node.Node
+
+	// Predicates contains the predicates that are likely to be looked up.
+	Predicates []*predicate.Predicate
+}
+
+// Prefetcher is an optional interface that graphs may implement to warm
+// caches or batch remote reads before the execution of a statement starts.
+// Hints are advisory; drivers are free to ignore any part of them.
+type Prefetcher interface {
+	// Prefetch receives the hints for the statement about to be executed.
+	// Returning an error will abort the execution of the statement.
+	Prefetch(h *PrefetchHints) error
+}
+
+// HealthChecker is an optional interface that stores may implement to report
+// whether their backend is reachable and able to serve requests.
+type HealthChecker interface {
+	// CheckHealth returns nil if the store can serve requests, or an error
+	// describing why it cannot.
+	CheckHealth() error
+}
+
+// Options contains the configuration of a store that is not specific to its
+// driver.
+type Options struct {
+	// Codec contains the name of the codec used to encode the graphs of the
+	// store when they are dumped and restored, as registered by the io
+	// package. Empty uses no codec.
+	Codec string
+}
+
+// Configured is an optional interface that stores may implement to expose the
+// options they were created with.
+type Configured interface {
+	// Options returns the options of the store.
+	Options() Options
+}
+
+// GraphLister is an optional interface that stores may implement to list the
+// graphs they hold.
+type GraphLister interface {
+	// GraphNames returns the IDs of all the graphs in the store, sorted.
+	GraphNames() ([]string, error)
+}
+
+// HealthReport summarizes the health of a store.
+type HealthReport struct {
+	// Name contains the name of the driver backing the store.
+	Name string
+
+	// Version contains the version of the driver backing the store.
+	Version string
+
+	// Err contains the reason why the store is not healthy, if any.
+	Err error
+}
+
+// Healthy returns true if the report did not find any problem.
+func (h *HealthReport) Healthy() bool {
+	return h.Err == nil
+}
+
+// CheckHealth returns the health report for the provided store. Stores that
+// do not implement HealthChecker are assumed to be healthy.
+func CheckHealth(s Store) *HealthReport {
+	h := &HealthReport{
+		Name:    s.Name(),
+		Version: s.Version(),
+	}
+	if hc, ok := s.(HealthChecker); ok {
+		h.Err = hc.CheckHealth()
+	}
+	return h
+}
+
+// GraphStats contains the statistics collected about the data stored on a
+// graph. They are used to estimate the cost of retrieving data while planning
+// queries.
+type GraphStats struct {
+	// Triples contains the number of triples stored on the graph.
+	Triples int64
+
+	// Subjects contains the estimated number of distinct subjects.
+	Subjects int64
+
+	// Predicates contains the number of triples for each predicate ID.
+	Predicates map[string]int64
+
+	// Fanout contains the estimated histogram of the number of triples per
+	// subject.
+	Fanout []FanoutBucket
+
+	// SampleRate indicates that one out of every SampleRate subjects was
+	// sampled to build the fanout histogram.
+	SampleRate int64
+
+	// Collected contains the time when the statistics were collected.
+	Collected time.Time
+}
+
+// FanoutBucket contains the estimated number of subjects with at most
+// MaxFanout triples, and more than the MaxFanout of the previous bucket.
+type FanoutBucket struct {
+	// MaxFanout contains the upper bound of the number of triples per subject.
+	MaxFanout int64
+
+	// Subjects contains the estimated number of subjects in the bucket.
+	Subjects int64
+}
+
+// StatsKeeper is an optional interface that graphs may implement to persist
+// the statistics collected about their data.
+type StatsKeeper interface {
+	// SetStats replaces the statistics stored for the graph.
+	SetStats(s *GraphStats) error
+
+	// Stats returns the last statistics stored for the graph, or nil if none
+	// have been collected yet.
+	Stats() (*GraphStats, error)
+}
+
+// ValidationEnforcer is an optional interface that graphs may implement to
+// reject malformed triples at write time.
+type ValidationEnforcer interface {
+	// SetValidator sets the validator enforced on the triples added to the
+	// graph. AddTriples must fail without adding any triple if any of them is
+	// rejected by the validator. A nil validator disables the validation.
+	SetValidator(v triple.Validator) error
+
+	// Validator returns the validator enforced by the graph, or nil if none
+	// has been set.
+	Validator() triple.Validator
+}
+
+// Change describes the triples added to or removed from a graph by a single
+// update.
+type Change struct {
+	// Graph contains the ID of the modified graph.
+	Graph string
+
+	// Added contains the triples added to the graph that were not already
+	// stored on it.
+	Added []*triple.Triple
+
+	// Removed contains the triples removed from the graph that were stored on
+	// it.
+	Removed []*triple.Triple
+}
+
+// Watcher is an optional interface that graphs may implement to provide a
+// feed of the changes applied to their data.
+type Watcher interface {
+	// Watch registers the provided function to be called after each change
+	// applied to the graph, in the same order they were applied. Changes
+	// that add or remove no triples are not notified. Calling the returned
+	// cancel function stops the notifications. The function must not modify
+	// the graph.
+	Watch(f func(c *Change)) (cancel func(), err error)
+}
@@ -0,0 +1,55 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeStore is a store that does not provide any graphs.
+type fakeStore struct{}
+
+func (fakeStore) Name() string                   { return "fake" }
+func (fakeStore) Version() string                { return "v0" }
+func (fakeStore) NewGraph(string) (Graph, error) { return nil, errors.New("unsupported") }
+func (fakeStore) Graph(string) (Graph, error)    { return nil, errors.New("unsupported") }
+func (fakeStore) DeleteGraph(string) error       { return errors.New("unsupported") }
+
+// unhealthyStore is a store that reports itself as unreachable.
+type unhealthyStore struct {
+	fakeStore
+}
+
+func (unhealthyStore) CheckHealth() error { return errors.New("backend unreachable") }
+
+func TestCheckHealth(t *testing.T) {
+	testTable := []struct {
+		s       Store
+		healthy bool
+	}{
+		{fakeStore{}, true},
+		{unhealthyStore{}, false},
+	}
+	for _, entry := range testTable {
+		h := CheckHealth(entry.s)
+		if got, want := h.Healthy(), entry.healthy; got != want {
+			t.Errorf("storage.CheckHealth returned the wrong health for %T; got %v, want %v", entry.s, got, want)
+		}
+		if h.Name != "fake" || h.Version != "v0" {
+			t.Errorf("storage.CheckHealth failed to report the driver; got %q %q", h.Name, h.Version)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tenant partitions a store by graph namespace, so one process can
+// safely serve many tenants. Each tenant sees a store of its own, holding
+// only its graphs under the names it chose. Graphs are kept on the shared
+// store prefixed by the tenant name, as in acme:?people.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/badwolf/metrics"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+)
+
+// separator splits the tenant prefix from the graph name on the shared store.
+const separator = ":"
+
+// validName matches the valid tenant names.
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tenantKey is the context key the tenant is stored under.
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context carrying the tenant issuing the
+// requests.
+func WithTenant(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, name)
+}
+
+// FromContext returns the tenant stored on the context, and false if there is
+// none.
+func FromContext(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(tenantKey{}).(string)
+	return t, ok
+}
+
+// Options configures the partitioning.
+type Options struct {
+	// MaxGraphs is the maximum number of graphs each tenant may hold. Zero
+	// disables it.
+	MaxGraphs int
+
+	// Metrics returns the collector the storage operations of a tenant are
+	// reported to, keeping the statistics of each tenant apart. Tenants are
+	// not instrumented if it is nil or returns nil.
+	Metrics func(tenant string) metrics.Collector
+}
+
+// QuotaError is returned when a tenant exceeds its quota.
+type QuotaError struct {
+	Tenant    string
+	MaxGraphs int
+}
+
+// Error returns the error message.
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("tenant %q reached its quota of %d graphs", e.Tenant, e.MaxGraphs)
+}
+
+// Partitioner hands out the stores of each tenant. It is safe for concurrent
+// use.
+type Partitioner struct {
+	s    storage.Store
+	opts Options
+
+	mu      sync.Mutex
+	tenants map[string]storage.Store
+}
+
+// New returns a partitioner for the provided shared store. Graph quotas
+// require the store to list its graphs.
+func New(s storage.Store, opts Options) (*Partitioner, error) {
+	if opts.MaxGraphs < 0 {
+		return nil, fmt.Errorf("tenant.New: invalid negative maximum number of graphs %d", opts.MaxGraphs)
+	}
+	if _, ok := s.(storage.GraphLister); !ok && opts.MaxGraphs > 0 {
+		return nil, fmt.Errorf("tenant.New: graph quotas require store %q to list its graphs", s.Name())
+	}
+	return &Partitioner{
+		s:       s,
+		opts:    opts,
+		tenants: make(map[string]storage.Store),
+	}, nil
+}
+
+// Store returns the store of the tenant set on the context. It fails if the
+// context carries no valid tenant.
+func (p *Partitioner) Store(ctx context.Context) (storage.Store, error) {
+	t, ok := FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("tenant.Partitioner.Store: no tenant provided")
+	}
+	return p.Tenant(t)
+}
+
+// Tenant returns the store of the provided tenant. The same store is returned
+// for each call with the same tenant. Tenant names may only contain letters,
+// digits, dashes, and underscores.
+func (p *Partitioner) Tenant(name string) (storage.Store, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("tenant.Partitioner.Tenant: invalid tenant name %q", name)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.tenants[name]; ok {
+		return s, nil
+	}
+	var s storage.Store = &store{
+		s:      p.s,
+		name:   name,
+		prefix: name + separator,
+		max:    p.opts.MaxGraphs,
+	}
+	if _, ok := p.s.(storage.GraphLister); ok {
+		s = &listingStore{s.(*store)}
+	}
+	if p.opts.Metrics != nil {
+		if c := p.opts.Metrics(name); c != nil {
+			s = metrics.Store(s, c)
+		}
+	}
+	p.tenants[name] = s
+	return s, nil
+}
+
+// store contains the graphs of a tenant.
+type store struct {
+	s      storage.Store
+	name   string
+	prefix string
+	max    int
+
+	// mu serializes the graph creations to enforce the quota.
+	mu sync.Mutex
+}
+
+// Name returns the name of the shared store.
+func (s *store) Name() string {
+	return s.s.Name()
+}
+
+// Version returns the version of the shared store.
+func (s *store) Version() string {
+	return s.s.Version()
+}
+
+// graph returns the tenant view of a graph of the shared store.
+func (s *store) graph(id string, g storage.Graph) storage.Graph {
+	tg := &graph{Graph: g, id: id}
+	if _, ok := g.(storage.Watcher); ok {
+		return &watchedGraph{tg}
+	}
+	return tg
+}
+
+// names returns the names of the tenant graphs, sorted.
+func (s *store) names() ([]string, error) {
+	ids, err := s.s.(storage.GraphLister).GraphNames()
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, id := range ids {
+		if strings.HasPrefix(id, s.prefix) {
+			res = append(res, strings.TrimPrefix(id, s.prefix))
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// NewGraph creates a new graph for the tenant, failing with a *QuotaError if
+// the tenant already holds the maximum number of graphs.
+func (s *store) NewGraph(id string) (storage.Graph, error) {
+	if id == "" {
+		return nil, fmt.Errorf("tenant: invalid empty graph name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.max > 0 {
+		ids, err := s.names()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) >= s.max {
+			return nil, &QuotaError{Tenant: s.name, MaxGraphs: s.max}
+		}
+	}
+	g, err := s.s.NewGraph(s.prefix + id)
+	if err != nil {
+		return nil, err
+	}
+	return s.graph(id, g), nil
+}
+
+// Graph returns an existing graph of the tenant.
+func (s *store) Graph(id string) (storage.Graph, error) {
+	g, err := s.s.Graph(s.prefix + id)
+	if err != nil {
+		return nil, err
+	}
+	return s.graph(id, g), nil
+}
+
+// DeleteGraph deletes an existing graph of the tenant.
+func (s *store) DeleteGraph(id string) error {
+	return s.s.DeleteGraph(s.prefix + id)
+}
+
+// CheckHealth checks the health of the shared store, if it supports it.
+func (s *store) CheckHealth() error {
+	if hc, ok := s.s.(storage.HealthChecker); ok {
+		return hc.CheckHealth()
+	}
+	return nil
+}
+
+// listingStore contains the graphs of a tenant on a store able to list its
+// graphs.
+type listingStore struct {
+	*store
+}
+
+// GraphNames returns the names of the tenant graphs, sorted.
+func (s *listingStore) GraphNames() ([]string, error) {
+	return s.names()
+}
+
+// graph is the tenant view of a graph of the shared store. It reports the
+// name given by the tenant as its ID.
+type graph struct {
+	storage.Graph
+	id string
+}
+
+// ID returns the name of the graph for the tenant.
+func (g *graph) ID() string {
+	return g.id
+}
+
+// Prefetch forwards the hints to the shared graph, if it supports them.
+func (g *graph) Prefetch(h *storage.PrefetchHints) error {
+	if pf, ok := g.Graph.(storage.Prefetcher); ok {
+		return pf.Prefetch(h)
+	}
+	return nil
+}
+
+// SetStats stores the statistics on the shared graph, if it supports it.
+func (g *graph) SetStats(s *storage.GraphStats) error {
+	if k, ok := g.Graph.(storage.StatsKeeper); ok {
+		return k.SetStats(s)
+	}
+	return fmt.Errorf("tenant: graph %q does not support storing statistics", g.id)
+}
+
+// Stats returns the statistics stored on the shared graph, if any.
+func (g *graph) Stats() (*storage.GraphStats, error) {
+	if k, ok := g.Graph.(storage.StatsKeeper); ok {
+		return k.Stats()
+	}
+	return nil, nil
+}
+
+// SetValidator sets the validator of the shared graph, if it supports it.
+func (g *graph) SetValidator(v triple.Validator) error {
+	if ve, ok := g.Graph.(storage.ValidationEnforcer); ok {
+		return ve.SetValidator(v)
+	}
+	return fmt.Errorf("tenant: graph %q does not support validating triples", g.id)
+}
+
+// Validator returns the validator of the shared graph, if any.
+func (g *graph) Validator() triple.Validator {
+	if ve, ok := g.Graph.(storage.ValidationEnforcer); ok {
+		return ve.Validator()
+	}
+	return nil
+}
+
+// watchedGraph is the tenant view of a graph providing a change feed.
+type watchedGraph struct {
+	*graph
+}
+
+// Watch registers the function on the change feed of the shared graph. The
+// changes report the name of the graph for the tenant.
+func (g *watchedGraph) Watch(f func(c *storage.Change)) (func(), error) {
+	return g.Graph.(storage.Watcher).Watch(func(c *storage.Change) {
+		tc := *c
+		tc.Graph = g.id
+		f(&tc)
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tenant
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/badwolf/metrics"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestPartitioner(t *testing.T) {
+	shared := memory.NewStore()
+	p, err := New(shared, Options{MaxGraphs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, err := p.Store(WithTenant(context.Background(), "acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := p.Tenant("acme"); err != nil || again != acme {
+		t.Errorf("Partitioner.Tenant should return the same store for the same tenant")
+	}
+	globex, err := p.Tenant("globex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := acme.NewGraph("?people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.ID(), "?people"; got != want {
+		t.Errorf("Graph.ID() = %q; want %q", got, want)
+	}
+	if _, err := globex.Graph("?people"); err == nil {
+		t.Errorf("tenants should not see the graphs of other tenants")
+	}
+	if _, err := globex.NewGraph("?people"); err != nil {
+		t.Errorf("tenants should be able to reuse the graph names of other tenants; %v", err)
+	}
+	if _, err := acme.NewGraph("?other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acme.NewGraph("?third"); err == nil {
+		t.Errorf("Store.NewGraph should have enforced the quota of 2 graphs")
+	} else if _, ok := err.(*QuotaError); !ok {
+		t.Errorf("Store.NewGraph returned %v; want a *QuotaError", err)
+	}
+	ids, err := acme.(storage.GraphLister).GraphNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"?other", "?people"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("GraphLister.GraphNames() = %v; want %v", ids, want)
+	}
+	all, err := shared.(storage.GraphLister).GraphNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"acme:?other", "acme:?people", "globex:?people"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("shared store holds %v; want %v", all, want)
+	}
+	if err := acme.DeleteGraph("?other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acme.NewGraph("?third"); err != nil {
+		t.Errorf("Store.NewGraph should succeed once a graph was deleted; %v", err)
+	}
+}
+
+func TestInvalidTenants(t *testing.T) {
+	p, err := New(memory.NewStore(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Store(context.Background()); err == nil {
+		t.Errorf("Partitioner.Store should fail without a tenant on the context")
+	}
+	for _, n := range []string{"", "a:b", "a/b", "?a"} {
+		if _, err := p.Tenant(n); err == nil {
+			t.Errorf("Partitioner.Tenant(%q) should have failed", n)
+		}
+	}
+	if _, err := New(memory.NewStore(), Options{MaxGraphs: -1}); err == nil {
+		t.Errorf("tenant.New should have rejected a negative quota")
+	}
+}
+
+func TestWatchAndMetrics(t *testing.T) {
+	cs := map[string]*metrics.Counters{}
+	p, err := New(memory.NewStore(), Options{
+		Metrics: func(name string) metrics.Collector {
+			cs[name] = metrics.NewCounters()
+			return cs[name]
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, err := p.Tenant("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Tenant("globex"); err != nil {
+		t.Fatal(err)
+	}
+	g, err := acme.NewGraph("?people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed []string
+	cancel, err := g.(storage.Watcher).Watch(func(c *storage.Change) {
+		changed = append(changed, c.Graph)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	tpl, err := triple.ParseTriple("/u<joe>\t\"knows\"@[]\t/u<mary>", literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddTriples([]*triple.Triple{tpl}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"?people"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("Watcher notified changes on %v; want %v", changed, want)
+	}
+	if n := cs["acme"].Snapshot().Operations["ADD_TRIPLES"].Count; n != 1 {
+		t.Errorf("acme metrics reported %d triple additions; want 1", n)
+	}
+	if n := len(cs["globex"].Snapshot().Operations); n != 0 {
+		t.Errorf("globex metrics reported %d kinds of operations; want none", n)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package literal
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Operator identifies an arithmetic operation on numeric literals.
+type Operator uint8
+
+const (
+	// Add adds two numbers.
+	Add Operator = iota
+	// Subtract subtracts the second number from the first one.
+	Subtract
+	// Multiply multiplies two numbers.
+	Multiply
+	// Divide divides the first number by the second one.
+	Divide
+)
+
+// String returns the symbol of the operator.
+func (o Operator) String() string {
+	switch o {
+	case Add:
+		return "+"
+	case Subtract:
+		return "-"
+	case Multiply:
+		return "*"
+	case Divide:
+		return "/"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// DivisionPrecision is the number of fractional digits kept when dividing
+// decimals whose quotient has no finite decimal representation.
+const DivisionPrecision = 20
+
+// numericRank orders the numeric types by how wide they are. The result of
+// an operation has the type of its widest operand.
+var numericRank = map[Type]int{
+	Int64:   0,
+	Decimal: 1,
+	Float32: 2,
+	Float64: 3,
+}
+
+// Arithmetic applies the provided operator to two numeric literals. The
+// operands are promoted to the widest of their types, following the order
+// int64, decimal, float32, and float64, which is also the type of the result.
+// Operations on int64 values fail if they overflow, and their division
+// truncates the quotient towards zero. Decimals are computed exactly, but the
+// quotients with no finite decimal representation are rounded to
+// DivisionPrecision fractional digits. Floats follow the IEEE 754 rules.
+// Divisions of int64 and decimal values by zero fail.
+func Arithmetic(op Operator, a, b *Literal) (*Literal, error) {
+	if !a.IsNumeric() || !b.IsNumeric() {
+		return nil, fmt.Errorf("literal.Arithmetic: cannot compute %s %s %s of non numeric literals", a, op, b)
+	}
+	t := a.t
+	if numericRank[b.t] > numericRank[t] {
+		t = b.t
+	}
+	var (
+		v   interface{}
+		err error
+	)
+	switch t {
+	case Int64:
+		v, err = int64Arithmetic(op, a.v.(int64), b.v.(int64))
+	case Decimal:
+		ra, _ := a.rat()
+		rb, _ := b.rat()
+		v, err = decimalArithmetic(op, ra, rb)
+	case Float32:
+		fa, _ := a.AsFloat64()
+		fb, _ := b.AsFloat64()
+		var f float64
+		f, err = floatArithmetic(op, fa, fb)
+		v = float32(f)
+	default:
+		fa, _ := a.AsFloat64()
+		fb, _ := b.AsFloat64()
+		v, err = floatArithmetic(op, fa, fb)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("literal.Arithmetic: cannot compute %s %s %s; %v", a, op, b, err)
+	}
+	return defaultBuilder.Build(t, v)
+}
+
+// int64Arithmetic applies the operator to two int64 values checking for
+// overflows.
+func int64Arithmetic(op Operator, a, b int64) (int64, error) {
+	switch op {
+	case Add:
+		if c := a + b; (c > a) == (b > 0) {
+			return c, nil
+		}
+	case Subtract:
+		if c := a - b; (c < a) == (b > 0) {
+			return c, nil
+		}
+	case Multiply:
+		if a == 0 || b == 0 {
+			return 0, nil
+		}
+		if c := a * b; c/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64) {
+			return c, nil
+		}
+	case Divide:
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		if a == math.MinInt64 && b == -1 {
+			break
+		}
+		return a / b, nil
+	default:
+		return 0, fmt.Errorf("unknown operator %d", op)
+	}
+	return 0, fmt.Errorf("int64 overflow")
+}
+
+// decimalArithmetic applies the operator to two exact values.
+func decimalArithmetic(op Operator, a, b *big.Rat) (*big.Rat, error) {
+	c := new(big.Rat)
+	switch op {
+	case Add:
+		return c.Add(a, b), nil
+	case Subtract:
+		return c.Sub(a, b), nil
+	case Multiply:
+		return c.Mul(a, b), nil
+	case Divide:
+		if b.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		c.Quo(a, b)
+		if !isFiniteDecimal(c) {
+			c.SetString(c.FloatString(DivisionPrecision))
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown operator %d", op)
+}
+
+// floatArithmetic applies the operator to two float values.
+func floatArithmetic(op Operator, a, b float64) (float64, error) {
+	switch op {
+	case Add:
+		return a + b, nil
+	case Subtract:
+		return a - b, nil
+	case Multiply:
+		return a * b, nil
+	case Divide:
+		return a / b, nil
+	}
+	return 0, fmt.Errorf("unknown operator %d", op)
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package literal
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestArithmetic(t *testing.T) {
+	b := DefaultBuilder()
+	lit := func(t Type, v interface{}) *Literal {
+		l, err := b.Build(t, v)
+		if err != nil {
+			panic(err)
+		}
+		return l
+	}
+	table := []struct {
+		op   Operator
+		a, b *Literal
+		want string
+	}{
+		{Add, lit(Int64, int64(2)), lit(Int64, int64(3)), `"5"^^type:int64`},
+		{Subtract, lit(Int64, int64(2)), lit(Int64, int64(3)), `"-1"^^type:int64`},
+		{Multiply, lit(Int64, int64(-4)), lit(Int64, int64(3)), `"-12"^^type:int64`},
+		{Divide, lit(Int64, int64(-7)), lit(Int64, int64(2)), `"-3"^^type:int64`},
+		{Add, lit(Int64, int64(1)), lit(Float64, 0.5), `"1.5"^^type:float64`},
+		{Multiply, lit(Float32, float32(1.5)), lit(Int64, int64(2)), `"3"^^type:float32`},
+		{Add, lit(Float32, float32(0.5)), lit(Float64, 0.25), `"0.75"^^type:float64`},
+		{Add, lit(Decimal, big.NewRat(1, 10)), lit(Decimal, big.NewRat(2, 10)), `"0.3"^^type:decimal`},
+		{Subtract, lit(Int64, int64(1)), lit(Decimal, big.NewRat(1, 4)), `"0.75"^^type:decimal`},
+		{Divide, lit(Decimal, big.NewRat(1, 1)), lit(Int64, int64(8)), `"0.125"^^type:decimal`},
+		{Divide, lit(Decimal, big.NewRat(2, 1)), lit(Decimal, big.NewRat(3, 1)), `"0.66666666666666666667"^^type:decimal`},
+		{Add, lit(Decimal, big.NewRat(1, 2)), lit(Float32, float32(0.25)), `"0.75"^^type:float32`},
+		{Divide, lit(Float64, 1.0), lit(Int64, int64(0)), `"+Inf"^^type:float64`},
+		{Add, lit(Int64, int64(math.MaxInt64)), lit(Int64, int64(math.MinInt64)), `"-1"^^type:int64`},
+	}
+	for _, entry := range table {
+		got, err := Arithmetic(entry.op, entry.a, entry.b)
+		if err != nil {
+			t.Errorf("literal.Arithmetic(%v, %v, %v) failed with error %v", entry.op, entry.a, entry.b, err)
+			continue
+		}
+		if got.String() != entry.want {
+			t.Errorf("literal.Arithmetic(%v, %v, %v) returned %v; want %s", entry.op, entry.a, entry.b, got, entry.want)
+		}
+	}
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	b := DefaultBuilder()
+	lit := func(t Type, v interface{}) *Literal {
+		l, err := b.Build(t, v)
+		if err != nil {
+			panic(err)
+		}
+		return l
+	}
+	table := []struct {
+		op   Operator
+		a, b *Literal
+	}{
+		{Add, lit(Int64, int64(math.MaxInt64)), lit(Int64, int64(1))},
+		{Subtract, lit(Int64, int64(math.MinInt64)), lit(Int64, int64(1))},
+		{Multiply, lit(Int64, int64(math.MaxInt64/2+1)), lit(Int64, int64(2))},
+		{Multiply, lit(Int64, int64(math.MinInt64)), lit(Int64, int64(-1))},
+		{Divide, lit(Int64, int64(math.MinInt64)), lit(Int64, int64(-1))},
+		{Divide, lit(Int64, int64(1)), lit(Int64, int64(0))},
+		{Divide, lit(Decimal, big.NewRat(1, 1)), lit(Int64, int64(0))},
+		{Add, lit(Int64, int64(1)), lit(Text, "1")},
+		{Add, lit(Duration, time.Second), lit(Int64, int64(1))},
+	}
+	for _, entry := range table {
+		if got, err := Arithmetic(entry.op, entry.a, entry.b); err == nil {
+			t.Errorf("literal.Arithmetic(%v, %v, %v) should have failed; got %v", entry.op, entry.a, entry.b, got)
+		}
+	}
+}
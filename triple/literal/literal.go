@@ -16,10 +16,15 @@
 package literal
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Type represents the type contained in a literal.
@@ -36,6 +41,17 @@ const (
 	Text
 	// Blob indicates that the type contained in the literal is a []byte.
 	Blob
+	// Duration indicates that the type contained in the literal is a
+	// time.Duration.
+	Duration
+	// Float32 indicates that the type contained in the literal is a float32.
+	Float32
+	// Decimal indicates that the type contained in the literal is an exact
+	// decimal number stored as a *big.Rat.
+	Decimal
+	// Date indicates that the type contained in the literal is a calendar date
+	// stored as a time.Time at midnight UTC.
+	Date
 )
 
 // Strings returns the pretty printing version of the type
@@ -51,7 +67,18 @@ func (t Type) String() string {
 		return "text"
 	case Blob:
 		return "blob"
+	case Duration:
+		return "duration"
+	case Float32:
+		return "float32"
+	case Decimal:
+		return "decimal"
+	case Date:
+		return "date"
 	default:
+		if d, ok := customDefinition(t); ok {
+			return d.Name
+		}
 		return "UNKNOWN"
 	}
 }
@@ -69,7 +96,97 @@ func (l *Literal) Type() Type {
 
 // String eturns a string representation of the literal.
 func (l *Literal) String() string {
-	return fmt.Sprintf("\"%v\"^^type:%v", l.Interface(), l.Type())
+	return fmt.Sprintf("\"%v\"^^type:%v", l.lexicalForm(), l.Type())
+}
+
+// lexicalForm returns the value of the literal as printed by String.
+func (l *Literal) lexicalForm() interface{} {
+	if d, ok := customDefinition(l.t); ok {
+		return d.Format(l.v)
+	}
+	switch v := l.v.(type) {
+	case *big.Rat:
+		return FormatDecimal(v)
+	case time.Time:
+		return v.Format(dateLayout)
+	}
+	return l.v
+}
+
+// dateLayout is the layout used to print and parse date literals.
+const dateLayout = "2006-01-02"
+
+// MaxDecimalDigits bounds both the number of digits and the magnitude of the
+// exponent of the decimals parsed. Parsing and printing decimals takes time
+// that grows with both, so larger ones are rejected before doing any work.
+const MaxDecimalDigits = 1024
+
+// ParseDecimal returns the decimal number written on the provided text, as an
+// optionally signed decimal number followed by an optional exponent. Numbers
+// with more than MaxDecimalDigits digits, or exponents beyond them, are
+// rejected.
+func ParseDecimal(s string) (*big.Rat, error) {
+	m, e := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		m, e = s[:i], s[i+1:]
+	}
+	if strings.IndexFunc(m, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '+' && r != '-' }) >= 0 {
+		return nil, fmt.Errorf("literal.ParseDecimal: invalid decimal %q", s)
+	}
+	if n := len(strings.TrimLeft(strings.Replace(m, ".", "", 1), "+-")); n > MaxDecimalDigits {
+		return nil, fmt.Errorf("literal.ParseDecimal: decimal %.32q... has %d digits; at most %d are supported", s, n, MaxDecimalDigits)
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		x, err := strconv.Atoi(e)
+		if err != nil {
+			return nil, fmt.Errorf("literal.ParseDecimal: invalid exponent on decimal %q", s)
+		}
+		if x > MaxDecimalDigits || x < -MaxDecimalDigits {
+			return nil, fmt.Errorf("literal.ParseDecimal: exponent %d of decimal %q is out of range [%d, %d]", x, s, -MaxDecimalDigits, MaxDecimalDigits)
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("literal.ParseDecimal: invalid decimal %q", s)
+	}
+	return r, nil
+}
+
+// decimalScale returns the number of digits after the decimal point of the
+// shortest decimal representation of the provided number, which is the
+// largest power of two or five dividing its denominator. It returns false if
+// the number has no finite decimal representation; that is, if its
+// denominator has prime factors other than two and five.
+func decimalScale(r *big.Rat) (int, bool) {
+	d := new(big.Int).Set(r.Denom())
+	twos := d.TrailingZeroBits()
+	d.Rsh(d, twos)
+	fives, five, q, m := 0, big.NewInt(5), new(big.Int), new(big.Int)
+	for d.BitLen() > 1 {
+		if q.QuoRem(d, five, m); m.Sign() != 0 {
+			return 0, false
+		}
+		d, q = q, d
+		fives++
+	}
+	if int(twos) > fives {
+		return int(twos), true
+	}
+	return fives, true
+}
+
+// FormatDecimal returns the shortest decimal representation of the provided
+// finite decimal number.
+func FormatDecimal(r *big.Rat) string {
+	n, _ := decimalScale(r)
+	return r.FloatString(n)
+}
+
+// isFiniteDecimal returns true if the provided number has a finite decimal
+// representation.
+func isFiniteDecimal(r *big.Rat) bool {
+	_, ok := decimalScale(r)
+	return ok
 }
 
 // Bool returns the value of a literal as a boolean.
@@ -112,6 +229,38 @@ func (l *Literal) Blob() ([]byte, error) {
 	return l.v.([]byte), nil
 }
 
+// Duration returns the value of a literal as a time.Duration.
+func (l *Literal) Duration() (time.Duration, error) {
+	if l.t != Duration {
+		return 0, fmt.Errorf("literal.Duration: literal is of type %v; cannot be converted to a time.Duration", l.t)
+	}
+	return l.v.(time.Duration), nil
+}
+
+// Float32 returns the value of a literal as a float32.
+func (l *Literal) Float32() (float32, error) {
+	if l.t != Float32 {
+		return 0, fmt.Errorf("literal.Float32: literal is of type %v; cannot be converted to a float32", l.t)
+	}
+	return l.v.(float32), nil
+}
+
+// Decimal returns a copy of the value of a literal as a *big.Rat.
+func (l *Literal) Decimal() (*big.Rat, error) {
+	if l.t != Decimal {
+		return nil, fmt.Errorf("literal.Decimal: literal is of type %v; cannot be converted to a *big.Rat", l.t)
+	}
+	return new(big.Rat).Set(l.v.(*big.Rat)), nil
+}
+
+// Date returns the value of a literal as a time.Time at midnight UTC.
+func (l *Literal) Date() (time.Time, error) {
+	if l.t != Date {
+		return time.Time{}, fmt.Errorf("literal.Date: literal is of type %v; cannot be converted to a date", l.t)
+	}
+	return l.v.(time.Time), nil
+}
+
 // Interface returns the value as a simple interface{}.
 func (l *Literal) Interface() interface{} {
 	return l.v
@@ -128,15 +277,25 @@ type Builder interface {
 var defaultBuilder Builder
 
 func init() {
-	defaultBuilder = &unboundBuilder{}
+	defaultBuilder = &unboundBuilder{types: defaultTypes}
 }
 
 // The deatuls bilder is unbound. This allows to create a literal arbitrarily
-// long.
-type unboundBuilder struct{}
+// long. Besides the built-in types, it builds the literals of its set of types.
+type unboundBuilder struct {
+	types *Types
+}
 
 // Build creates a new unboud literal from a type and a value.
 func (b *unboundBuilder) Build(t Type, v interface{}) (*Literal, error) {
+	if d, ok := b.types.definition(t); ok {
+		if d.Validate != nil {
+			if err := d.Validate(v); err != nil {
+				return nil, fmt.Errorf("literal.Build: invalid %s value %v; %v", t, v, err)
+			}
+		}
+		return &Literal{t: t, v: v}, nil
+	}
 	switch v.(type) {
 	case bool:
 		if t != Bool {
@@ -158,6 +317,30 @@ func (b *unboundBuilder) Build(t Type, v interface{}) (*Literal, error) {
 		if t != Blob {
 			return nil, fmt.Errorf("literal.Build: type %s does not match type of value %v", t, v)
 		}
+	case time.Duration:
+		if t != Duration {
+			return nil, fmt.Errorf("literal.Build: type %s does not match type of value %v", t, v)
+		}
+	case float32:
+		if t != Float32 {
+			return nil, fmt.Errorf("literal.Build: type %s does not match type of value %v", t, v)
+		}
+	case *big.Rat:
+		if t != Decimal {
+			return nil, fmt.Errorf("literal.Build: type %s does not match type of value %v", t, v)
+		}
+		r := v.(*big.Rat)
+		if !isFiniteDecimal(r) {
+			return nil, fmt.Errorf("literal.Build: value %v has no finite decimal representation", r)
+		}
+		// Keep a private copy so the literal cannot be mutated.
+		v = new(big.Rat).Set(r)
+	case time.Time:
+		if t != Date {
+			return nil, fmt.Errorf("literal.Build: type %s does not match type of value %v", t, v)
+		}
+		y, m, d := v.(time.Time).Date()
+		v = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
 	default:
 		return nil, fmt.Errorf("literal.Build: type %s is not supported when building literals", t)
 	}
@@ -167,21 +350,29 @@ func (b *unboundBuilder) Build(t Type, v interface{}) (*Literal, error) {
 	}, nil
 }
 
-// Parse creates a string out of a prettyfied representation.
-func (b *unboundBuilder) Parse(s string) (*Literal, error) {
+// splitLiteral returns the value and the type name of the provided prettyfied
+// literal.
+func splitLiteral(s string) (string, string, error) {
 	raw := strings.TrimSpace(s)
 	if len(raw) == 0 {
-		return nil, fmt.Errorf("literal.Parse: cannot parse and empty string into a literal; provided string %q", s)
+		return "", "", fmt.Errorf("literal.Parse: cannot parse and empty string into a literal; provided string %q", s)
 	}
 	if raw[0] != '"' {
-		return nil, fmt.Errorf("literal.Parse: text encoded literals must start with \", missing in %s", raw)
+		return "", "", fmt.Errorf("literal.Parse: text encoded literals must start with \", missing in %s", raw)
 	}
 	idx := strings.Index(raw, "\"^^type:")
 	if idx < 0 {
-		return nil, fmt.Errorf("literal.Parse: text encoded literals must have a type; missing in %s", raw)
+		return "", "", fmt.Errorf("literal.Parse: text encoded literals must have a type; missing in %s", raw)
+	}
+	return raw[1:idx], raw[idx+len("\"^^type:"):], nil
+}
+
+// Parse creates a string out of a prettyfied representation.
+func (b *unboundBuilder) Parse(s string) (*Literal, error) {
+	v, t, err := splitLiteral(s)
+	if err != nil {
+		return nil, err
 	}
-	v := raw[1:idx]
-	t := raw[idx+len("\"^^type:"):]
 	switch t {
 	case "bool":
 		pv, err := strconv.ParseBool(v)
@@ -217,8 +408,41 @@ func (b *unboundBuilder) Parse(s string) (*Literal, error) {
 			bs = append(bs, byte(b))
 		}
 		return b.Build(Blob, bs)
+	case "duration":
+		pv, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("literal.Parse: could not convert value %q to duration", v)
+		}
+		return b.Build(Duration, pv)
+	case "float32":
+		pv, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return nil, fmt.Errorf("literal.Parse: could not convert value %q to float32", v)
+		}
+		return b.Build(Float32, float32(pv))
+	case "decimal":
+		pv, err := ParseDecimal(v)
+		if err != nil {
+			return nil, fmt.Errorf("literal.Parse: could not convert value %q to decimal; %v", v, err)
+		}
+		return b.Build(Decimal, pv)
+	case "date":
+		pv, err := time.Parse(dateLayout, v)
+		if err != nil {
+			return nil, fmt.Errorf("literal.Parse: could not convert value %q to date", v)
+		}
+		return b.Build(Date, pv)
 	default:
-		return nil, nil
+		ct, ok := b.types.TypeByName(t)
+		if !ok {
+			return nil, nil
+		}
+		d, _ := b.types.definition(ct)
+		pv, err := d.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("literal.Parse: could not convert value %q to %s; %v", v, t, err)
+		}
+		return b.Build(ct, pv)
 	}
 }
 
@@ -227,14 +451,25 @@ func DefaultBuilder() Builder {
 	return defaultBuilder
 }
 
-// boundedBuilder implements a literal builder where strings and blobs are
-// guaranteed of being of bounded size
+// boundedBuilder implements a literal builder where strings, blobs, and the
+// decimal representation of decimals are guaranteed of being of bounded size
 type boundedBuilder struct {
 	max int
+	ub  *unboundBuilder
 }
 
 // Build creates a new literal of bounded size.
 func (b *boundedBuilder) Build(t Type, v interface{}) (*Literal, error) {
+	if d, ok := b.ub.types.definition(t); ok {
+		l, err := b.ub.Build(t, v)
+		if err != nil {
+			return nil, err
+		}
+		if f := d.Format(l.v); len(f) > b.max {
+			return nil, fmt.Errorf("literal.Build: cannot create literal due to size of %v (%d>%d)", f, len(f), b.max)
+		}
+		return l, nil
+	}
 	switch v.(type) {
 	case string:
 		if l := len(v.(string)); l > b.max {
@@ -244,16 +479,36 @@ func (b *boundedBuilder) Build(t Type, v interface{}) (*Literal, error) {
 		if l := len(v.([]byte)); l > b.max {
 			return nil, fmt.Errorf("literal.Build: cannot create literal due to size of %v (%d>%d)", v, l, b.max)
 		}
+	case *big.Rat:
+		if r := v.(*big.Rat); isFiniteDecimal(r) {
+			if l := len(FormatDecimal(r)); l > b.max {
+				return nil, fmt.Errorf("literal.Build: cannot create literal due to size of %v (%d>%d)", FormatDecimal(r), l, b.max)
+			}
+		}
 	}
-	return defaultBuilder.Build(t, v)
+	return b.ub.Build(t, v)
 }
 
 // Parse creates a string out of a prettyfied representation.
 func (b *boundedBuilder) Parse(s string) (*Literal, error) {
-	l, err := defaultBuilder.Parse(s)
+	v, tn, err := splitLiteral(s)
 	if err != nil {
 		return nil, err
 	}
+	// Values are checked before parsing them, so oversized ones are rejected
+	// without doing any work on them. Blobs are written using up to four
+	// characters per byte, plus the enclosing brackets.
+	max := b.max
+	if tn == "blob" {
+		max = 4*b.max + 2
+	}
+	if len(v) > max {
+		return nil, fmt.Errorf("literal.Parse: cannot create literal due to size of %v (%d>%d)", tn, len(v), max)
+	}
+	l, err := b.ub.Parse(s)
+	if err != nil || l == nil {
+		return l, err
+	}
 	t := l.Type()
 	switch t {
 	case Text:
@@ -264,15 +519,40 @@ func (b *boundedBuilder) Parse(s string) (*Literal, error) {
 		if blob, err := l.Blob(); err != nil || len(blob) > b.max {
 			return nil, fmt.Errorf("literal.Parse: cannot create literal due to size of %v (%d>%d)", t, len(blob), b.max)
 		}
+	case Decimal:
+		if d := FormatDecimal(l.v.(*big.Rat)); len(d) > b.max {
+			return nil, fmt.Errorf("literal.Parse: cannot create literal due to size of %v (%d>%d)", t, len(d), b.max)
+		}
+	default:
+		if d, ok := customDefinition(t); ok {
+			if f := d.Format(l.v); len(f) > b.max {
+				return nil, fmt.Errorf("literal.Parse: cannot create literal due to size of %v (%d>%d)", t, len(f), b.max)
+			}
+		}
 	}
 	return l, nil
 }
 
 // NewBoundedBuilder creates a builder that that guarantess that no literal will
-// be created if the size of the string or a blob is bigger than the provided
-// maximum.
+// be created if the size of the string, a blob, the decimal representation of a
+// decimal, or the formatted value of a custom literal is bigger than the
+// provided maximum.
 func NewBoundedBuilder(max int) Builder {
-	return &boundedBuilder{max: max}
+	return NewBoundedBuilderWithTypes(defaultTypes, max)
+}
+
+// NewBuilderWithTypes returns an unbound builder that, besides the built-in
+// types, builds and parses the literals of the provided set of types instead of
+// the ones added using Register.
+func NewBuilderWithTypes(ts *Types) Builder {
+	return &unboundBuilder{types: ts}
+}
+
+// NewBoundedBuilderWithTypes returns a builder bounded as the ones returned by
+// NewBoundedBuilder, that builds and parses the literals of the provided set of
+// types instead of the ones added using Register.
+func NewBoundedBuilderWithTypes(ts *Types, max int) Builder {
+	return &boundedBuilder{max: max, ub: &unboundBuilder{types: ts}}
 }
 
 // GUID returns a global unique identifier for the given literal. It is
@@ -280,3 +560,261 @@ func NewBoundedBuilder(max int) Builder {
 func (l *Literal) GUID() string {
 	return base64.StdEncoding.EncodeToString([]byte(l.String()))
 }
+
+// MarshalBinary returns the binary encoding of the literal. It contains the
+// type of the literal as a byte followed by its value. Booleans take one byte,
+// integers and durations are varints, float64 take eight bytes and float32 four,
+// decimals are stored using their decimal representation, dates are varints
+// counting the days since the Unix epoch, and text and blobs are stored as is.
+// Custom literals are stored as a marker byte followed by the length prefixed
+// name of their type and their formatted value, since the types assigned to
+// them depend on the registration order.
+func (l *Literal) MarshalBinary() ([]byte, error) {
+	if d, ok := customDefinition(l.t); ok {
+		b := make([]byte, 1+binary.MaxVarintLen64)
+		b[0] = customMarker
+		b = append(b[:1+binary.PutUvarint(b[1:], uint64(len(d.Name)))], d.Name...)
+		return append(b, d.Format(l.v)...), nil
+	}
+	b := make([]byte, 1+binary.MaxVarintLen64)
+	b[0] = byte(l.t)
+	switch v := l.v.(type) {
+	case bool:
+		if v {
+			b[1] = 1
+		}
+		return b[:2], nil
+	case int64:
+		return b[:1+binary.PutVarint(b[1:], v)], nil
+	case time.Duration:
+		return b[:1+binary.PutVarint(b[1:], int64(v))], nil
+	case float64:
+		binary.BigEndian.PutUint64(b[1:], math.Float64bits(v))
+		return b[:9], nil
+	case float32:
+		binary.BigEndian.PutUint32(b[1:], math.Float32bits(v))
+		return b[:5], nil
+	case *big.Rat:
+		return append(b[:1], FormatDecimal(v)...), nil
+	case time.Time:
+		return b[:1+binary.PutVarint(b[1:], v.Unix()/secondsPerDay)], nil
+	case string:
+		return append(b[:1], v...), nil
+	case []byte:
+		return append(b[:1], v...), nil
+	}
+	return nil, fmt.Errorf("literal.MarshalBinary: type %s is not supported", l.t)
+}
+
+// UnmarshalBinary sets the literal to the one encoded in the provided data by
+// MarshalBinary. The value is not checked against the limits of any builder.
+// Custom literals are decoded using the types added using Register; use
+// Types.UnmarshalLiteral to decode the ones of other sets.
+func (l *Literal) UnmarshalBinary(data []byte) error {
+	return l.unmarshal(data, defaultTypes)
+}
+
+// UnmarshalLiteral returns the literal encoded in the provided data by
+// MarshalBinary, decoding custom literals using the types of the set.
+func (ts *Types) UnmarshalLiteral(data []byte) (*Literal, error) {
+	l := &Literal{}
+	if err := l.unmarshal(data, ts); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// unmarshal sets the literal to the one encoded in the provided data, decoding
+// custom literals using the provided set of types.
+func (l *Literal) unmarshal(data []byte, ts *Types) error {
+	if len(data) == 0 {
+		return fmt.Errorf("literal.UnmarshalBinary: cannot decode an empty literal")
+	}
+	t, rest := Type(data[0]), data[1:]
+	var v interface{}
+	switch t {
+	case Bool:
+		if len(rest) != 1 || rest[0] > 1 {
+			return fmt.Errorf("literal.UnmarshalBinary: invalid encoded bool %q", rest)
+		}
+		v = rest[0] == 1
+	case Int64, Duration, Date:
+		i, k := binary.Varint(rest)
+		if k <= 0 || k != len(rest) {
+			return fmt.Errorf("literal.UnmarshalBinary: invalid encoded %s %q", t, rest)
+		}
+		switch t {
+		case Int64:
+			v = i
+		case Duration:
+			v = time.Duration(i)
+		default:
+			v = time.Unix(i*secondsPerDay, 0).UTC()
+		}
+	case Float64:
+		if len(rest) != 8 {
+			return fmt.Errorf("literal.UnmarshalBinary: invalid encoded float64 %q", rest)
+		}
+		v = math.Float64frombits(binary.BigEndian.Uint64(rest))
+	case Float32:
+		if len(rest) != 4 {
+			return fmt.Errorf("literal.UnmarshalBinary: invalid encoded float32 %q", rest)
+		}
+		v = math.Float32frombits(binary.BigEndian.Uint32(rest))
+	case Decimal:
+		r, err := ParseDecimal(string(rest))
+		if err != nil || !isFiniteDecimal(r) {
+			return fmt.Errorf("literal.UnmarshalBinary: invalid encoded decimal %q", rest)
+		}
+		v = r
+	case Text:
+		v = string(rest)
+	case Blob:
+		v = append([]byte{}, rest...)
+	case customMarker:
+		n, k := binary.Uvarint(rest)
+		if k <= 0 || uint64(len(rest)-k) < n {
+			return fmt.Errorf("literal.UnmarshalBinary: invalid encoded custom literal %q", rest)
+		}
+		name := string(rest[k : k+int(n)])
+		ct, ok := ts.TypeByName(name)
+		if !ok || ct < firstCustomType {
+			return fmt.Errorf("literal.UnmarshalBinary: unknown literal type %q", name)
+		}
+		d, _ := ts.definition(ct)
+		pv, err := d.Parse(string(rest[k+int(n):]))
+		if err != nil {
+			return fmt.Errorf("literal.UnmarshalBinary: invalid encoded %s value; %v", name, err)
+		}
+		t, v = ct, pv
+	default:
+		return fmt.Errorf("literal.UnmarshalBinary: unknown literal type %d", data[0])
+	}
+	l.t, l.v = t, v
+	return nil
+}
+
+// secondsPerDay is used to encode dates as days since the Unix epoch.
+const secondsPerDay = 24 * 60 * 60
+
+// Compare returns -1, 0, or 1 if the first literal is smaller, equal, or
+// bigger than the second one. Numeric literals, int64, float32, float64, and
+// decimal ones, are compared by value regardless of their type; decimals are
+// compared exactly. Other literals can only be compared to literals of the same
+// type: false sorts before true, durations and dates are compared
+// chronologically, and texts and blobs lexicographically. Custom literals are
+// compared using the Compare function of their type definition, if any.
+func Compare(a, b *Literal) (int, error) {
+	if a.IsNumeric() && b.IsNumeric() {
+		return compareNumbers(a, b), nil
+	}
+	if a.t != b.t {
+		return 0, fmt.Errorf("literal.Compare: cannot compare literals %s and %s of different types", a, b)
+	}
+	if d, ok := customDefinition(a.t); ok {
+		if d.Compare == nil {
+			return 0, fmt.Errorf("literal.Compare: literals of type %s are not ordered", a.t)
+		}
+		cmp := d.Compare(a.v, b.v)
+		return compareOrdered(cmp < 0, cmp > 0), nil
+	}
+	switch va := a.v.(type) {
+	case bool:
+		vb := b.v.(bool)
+		return compareOrdered(!va && vb, va && !vb), nil
+	case time.Duration:
+		vb := b.v.(time.Duration)
+		return compareOrdered(va < vb, va > vb), nil
+	case time.Time:
+		vb := b.v.(time.Time)
+		return compareOrdered(va.Before(vb), va.After(vb)), nil
+	case string:
+		return strings.Compare(va, b.v.(string)), nil
+	case []byte:
+		return bytes.Compare(va, b.v.([]byte)), nil
+	}
+	return 0, fmt.Errorf("literal.Compare: cannot compare literals %s and %s", a, b)
+}
+
+// IsNumeric returns true if the literal holds an int64, float32, float64, or
+// decimal value.
+func (l *Literal) IsNumeric() bool {
+	switch l.t {
+	case Int64, Float32, Float64, Decimal:
+		return true
+	}
+	return false
+}
+
+// AsFloat64 returns the value of a numeric literal widened to a float64.
+func (l *Literal) AsFloat64() (float64, error) {
+	if !l.IsNumeric() {
+		return 0, fmt.Errorf("literal.AsFloat64: literal %s is not numeric", l)
+	}
+	switch v := l.v.(type) {
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f, nil
+	}
+	return 0, fmt.Errorf("literal.AsFloat64: literal %s is not numeric", l)
+}
+
+// rat returns the exact value of a numeric literal. Infinite and not a number
+// floats have no exact value.
+func (l *Literal) rat() (*big.Rat, bool) {
+	switch v := l.v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case float32:
+		return ratFromFloat(float64(v))
+	case float64:
+		return ratFromFloat(v)
+	case *big.Rat:
+		return v, true
+	}
+	return nil, false
+}
+
+// ratFromFloat returns the exact value of a finite float.
+func ratFromFloat(f float64) (*big.Rat, bool) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, false
+	}
+	return new(big.Rat).SetFloat64(f), true
+}
+
+// compareNumbers compares two numeric literals.
+func compareNumbers(a, b *Literal) int {
+	if ia, ok := a.v.(int64); ok {
+		if ib, ok := b.v.(int64); ok {
+			return compareOrdered(ia < ib, ia > ib)
+		}
+	}
+	if a.t == Decimal || b.t == Decimal {
+		ra, oka := a.rat()
+		rb, okb := b.rat()
+		if oka && okb {
+			return ra.Cmp(rb)
+		}
+	}
+	fa, _ := a.AsFloat64()
+	fb, _ := b.AsFloat64()
+	return compareOrdered(fa < fb, fa > fb)
+}
+
+// compareOrdered returns -1 if less, 1 if more, and 0 otherwise.
+func compareOrdered(less, more bool) int {
+	switch {
+	case less:
+		return -1
+	case more:
+		return 1
+	}
+	return 0
+}
@@ -16,8 +16,12 @@ package literal
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultBuilder(t *testing.T) {
@@ -39,12 +43,23 @@ func TestDefaultBuilder(t *testing.T) {
 		{Text, "some random string", &Literal{Text, interface{}("some random string")}},
 		{Blob, []byte{}, &Literal{Blob, []byte{}}},
 		{Blob, []byte("some random bytes"), &Literal{Blob, interface{}([]byte("some random bytes"))}},
+		{Duration, time.Duration(0), &Literal{Duration, interface{}(time.Duration(0))}},
+		{Duration, 24 * time.Hour, &Literal{Duration, interface{}(24 * time.Hour)}},
+		{Float32, float32(1.5), &Literal{Float32, interface{}(float32(1.5))}},
+		{Decimal, big.NewRat(1, 4), &Literal{Decimal, interface{}(big.NewRat(1, 4))}},
+		{Date, time.Date(2015, time.July, 19, 23, 30, 0, 0, time.UTC), &Literal{Date, interface{}(time.Date(2015, time.July, 19, 0, 0, 0, 0, time.UTC))}},
 		// Invalid cases.
 		{Bool, 1, nil},
 		{Int64, 2, nil},
 		{Float64, 3, nil},
 		{Text, 4, nil},
 		{Blob, 5, nil},
+		{Duration, int64(6), nil},
+		{Int64, time.Hour, nil},
+		{Float32, float64(7), nil},
+		{Decimal, big.NewRat(1, 3), nil},
+		{Decimal, "0.5", nil},
+		{Date, "2015-07-19", nil},
 	}
 	for _, tc := range table {
 		got, err := DefaultBuilder().Build(tc.t, tc.v)
@@ -69,6 +84,7 @@ func TestBoundedBuilder(t *testing.T) {
 		// Invalid cases.
 		{Text, "01234567890", nil},
 		{Blob, []byte("01234567890"), nil},
+		{Decimal, big.NewRat(123456789012, 1), nil},
 	}
 	b := NewBoundedBuilder(max)
 	for _, tc := range table {
@@ -101,6 +117,11 @@ func TestPrettyPrinting(t *testing.T) {
 		{Text, "some random string", `"some random string"^^type:text`},
 		{Blob, []byte{}, `"[]"^^type:blob`},
 		{Blob, []byte("some random bytes"), `"[115 111 109 101 32 114 97 110 100 111 109 32 98 121 116 101 115]"^^type:blob`},
+		{Duration, 90 * time.Minute, `"1h30m0s"^^type:duration`},
+		{Float32, float32(1.5), `"1.5"^^type:float32`},
+		{Decimal, big.NewRat(-314, 100), `"-3.14"^^type:decimal`},
+		{Decimal, big.NewRat(42, 1), `"42"^^type:decimal`},
+		{Date, time.Date(2015, time.July, 19, 0, 0, 0, 0, time.UTC), `"2015-07-19"^^type:date`},
 	}
 	for _, tc := range table {
 		lit, err := DefaultBuilder().Build(tc.t, tc.v)
@@ -132,6 +153,11 @@ func TestParse(t *testing.T) {
 		{Text, "some random string", `"some random string"^^type:text`},
 		{Blob, []byte{}, `"[]"^^type:blob`},
 		{Blob, []byte("some random bytes"), `"[115 111 109 101 32 114 97 110 100 111 109 32 98 121 116 101 115]"^^type:blob`},
+		{Duration, 90 * time.Minute, `"1h30m0s"^^type:duration`},
+		{Float32, float32(1.5), `"1.5"^^type:float32`},
+		{Decimal, big.NewRat(-314, 100), `"-3.14"^^type:decimal`},
+		{Decimal, big.NewRat(42, 1), `"42"^^type:decimal`},
+		{Date, time.Date(2015, time.July, 19, 0, 0, 0, 0, time.UTC), `"2015-07-19"^^type:date`},
 	}
 	for _, tc := range table {
 		want, err := DefaultBuilder().Build(tc.t, tc.v)
@@ -147,3 +173,155 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestDurationAccessor(t *testing.T) {
+	l, err := DefaultBuilder().Build(Duration, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to build duration literal with error %v", err)
+	}
+	if got, err := l.Duration(); err != nil || got != time.Hour {
+		t.Errorf("literal.Duration returned %v, %v; want %v, nil", got, err, time.Hour)
+	}
+	if _, err := l.Int64(); err == nil {
+		t.Errorf("literal.Int64 should fail for duration literals")
+	}
+	if _, err := DefaultBuilder().Parse(`"24 hours"^^type:duration`); err == nil {
+		t.Errorf("literal.Parse should fail to parse invalid durations")
+	}
+}
+
+func TestBinaryEncoding(t *testing.T) {
+	table := []struct {
+		t Type
+		v interface{}
+	}{
+		{Bool, true},
+		{Bool, false},
+		{Int64, int64(-1234567890)},
+		{Float64, 3.14159},
+		{Text, ""},
+		{Text, "some text"},
+		{Blob, []byte{0, 1, 2}},
+		{Duration, -90 * time.Minute},
+		{Float32, float32(-2.5)},
+		{Decimal, big.NewRat(-12345, 1000)},
+		{Date, time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, entry := range table {
+		l, err := DefaultBuilder().Build(entry.t, entry.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := l.MarshalBinary()
+		if err != nil {
+			t.Fatalf("literal.MarshalBinary(%v) failed with error %v", l, err)
+		}
+		got := &Literal{}
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("literal.UnmarshalBinary(%q) failed with error %v", b, err)
+		}
+		if !reflect.DeepEqual(got, l) {
+			t.Errorf("literal.UnmarshalBinary returned the wrong literal; got %v, want %v", got, l)
+		}
+	}
+	for _, b := range [][]byte{nil, {byte(Bool)}, {byte(Bool), 2}, {byte(Int64)}, {byte(Int64), 1, 2}, {byte(Float64), 1}, {42}} {
+		if err := (&Literal{}).UnmarshalBinary(b); err == nil {
+			t.Errorf("literal.UnmarshalBinary(%q) should have failed", b)
+		}
+	}
+}
+
+func TestDecimalAndDateParsing(t *testing.T) {
+	for _, s := range []string{
+		`"1/3"^^type:decimal`,
+		`"0.5.1"^^type:decimal`,
+		`"0x1p-4"^^type:decimal`,
+		`"1e-40000"^^type:decimal`,
+		`"1e-1000000"^^type:decimal`,
+		`"1e1000000"^^type:decimal`,
+		`"1e99999999999999999999"^^type:decimal`,
+		`"2015-13-01"^^type:date`,
+		`"2015-07-19T10:00:00Z"^^type:date`,
+	} {
+		if _, err := DefaultBuilder().Parse(s); err == nil {
+			t.Errorf("literal.Parse(%s) should have failed", s)
+		}
+	}
+	if _, err := NewBoundedBuilder(4).Parse(`"3.14159"^^type:decimal`); err == nil {
+		t.Errorf("bounded literal.Parse should reject decimals longer than its bound")
+	}
+	if _, err := NewBoundedBuilder(4).Parse(`"1e-1000"^^type:decimal`); err == nil {
+		t.Errorf("bounded literal.Parse should reject decimals printed longer than its bound")
+	}
+	for _, entry := range []struct {
+		s    string
+		want string
+	}{
+		{`"0.125"^^type:decimal`, `"0.125"^^type:decimal`},
+		{`"5e-2"^^type:decimal`, `"0.05"^^type:decimal`},
+		{`"1.2e3"^^type:decimal`, `"1200"^^type:decimal`},
+		{`"1e-1024"^^type:decimal`, `"0.` + strings.Repeat("0", 1023) + `1"^^type:decimal`},
+		{`"1e1024"^^type:decimal`, `"1` + strings.Repeat("0", 1024) + `"^^type:decimal`},
+	} {
+		l, err := DefaultBuilder().Parse(entry.s)
+		if err != nil {
+			t.Errorf("literal.Parse(%s) failed with error %v", entry.s, err)
+			continue
+		}
+		if got := l.String(); got != entry.want {
+			t.Errorf("literal.Parse(%s) returned the wrong literal; got %s, want %s", entry.s, got, entry.want)
+		}
+	}
+	l, err := DefaultBuilder().Build(Decimal, big.NewRat(1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := l.Decimal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetInt64(7)
+	if got, _ := l.Decimal(); got.Cmp(big.NewRat(1, 2)) != 0 {
+		t.Errorf("literal.Decimal should return a copy; literal changed to %v", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	b := DefaultBuilder()
+	lit := func(t Type, v interface{}) *Literal {
+		l, err := b.Build(t, v)
+		if err != nil {
+			panic(err)
+		}
+		return l
+	}
+	table := []struct {
+		a, b *Literal
+		want int
+	}{
+		{lit(Int64, int64(1)), lit(Int64, int64(2)), -1},
+		{lit(Int64, int64(2)), lit(Float64, 1.5), 1},
+		{lit(Float32, float32(0.5)), lit(Decimal, big.NewRat(1, 2)), 0},
+		{lit(Decimal, big.NewRat(1, 10)), lit(Float64, 0.1), -1},
+		{lit(Decimal, big.NewRat(9007199254740993, 1)), lit(Int64, int64(9007199254740992)), 1},
+		{lit(Float64, math.Inf(-1)), lit(Decimal, big.NewRat(-1000, 1)), -1},
+		{lit(Bool, false), lit(Bool, true), -1},
+		{lit(Text, "b"), lit(Text, "a"), 1},
+		{lit(Blob, []byte{1}), lit(Blob, []byte{1}), 0},
+		{lit(Duration, time.Minute), lit(Duration, time.Hour), -1},
+		{lit(Date, time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)), lit(Date, time.Date(2015, 12, 31, 0, 0, 0, 0, time.UTC)), 1},
+	}
+	for _, entry := range table {
+		got, err := Compare(entry.a, entry.b)
+		if err != nil {
+			t.Errorf("literal.Compare(%v, %v) failed with error %v", entry.a, entry.b, err)
+			continue
+		}
+		if got != entry.want {
+			t.Errorf("literal.Compare(%v, %v) returned %d; want %d", entry.a, entry.b, got, entry.want)
+		}
+	}
+	if _, err := Compare(lit(Text, "1"), lit(Int64, int64(1))); err == nil {
+		t.Errorf("literal.Compare should fail for literals of incompatible types")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package literal
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TypeDefinition describes an application defined literal type, such as a
+// geographic point or an IP address, allowing its values to be built, parsed,
+// and printed like any other literal once registered.
+type TypeDefinition struct {
+	// Name of the type as used in the type assertion of pretty printed
+	// literals; for instance, ipaddr for "10.0.0.1"^^type:ipaddr. Names must
+	// start with a lower case letter followed by lower case letters or digits.
+	Name string
+	// Parse converts the provided lexical form into the value boxed in the
+	// literal.
+	Parse func(s string) (interface{}, error)
+	// Format returns the lexical form of the provided value. Parsing the
+	// returned string must return an equivalent value.
+	Format func(v interface{}) string
+	// Validate, if provided, checks the values used to build literals.
+	Validate func(v interface{}) error
+	// Compare, if provided, returns a negative number, zero, or a positive
+	// number if the first value is smaller, equal, or bigger than the second
+	// one. Literals of types without a Compare function cannot be ordered.
+	Compare func(a, b interface{}) int
+}
+
+const (
+	// firstCustomType is the first type assigned to registered types.
+	firstCustomType Type = 64
+	// customMarker identifies custom literals in their binary encoding.
+	customMarker = 0xff
+)
+
+// customTypes contains the definitions of the types registered on all sets.
+// Types are assigned process wide, so the literals of types registered on
+// different sets never share their type, even if their names match.
+var customTypes = struct {
+	sync.RWMutex
+	m map[Type]*TypeDefinition
+}{
+	m: map[Type]*TypeDefinition{},
+}
+
+// Types contains a set of application defined literal types. Builders created
+// using NewBuilderWithTypes build and parse the literals of its types, so
+// independent stores may use different definitions for the same type name.
+// The types registered using Register belong to the default set, used by
+// DefaultBuilder and NewBoundedBuilder. Types are safe for concurrent use.
+type Types struct {
+	mu    sync.RWMutex
+	defs  map[Type]*TypeDefinition
+	names map[string]Type
+}
+
+// NewTypes returns an empty set of types.
+func NewTypes() *Types {
+	return &Types{
+		defs:  map[Type]*TypeDefinition{},
+		names: map[string]Type{},
+	}
+}
+
+// defaultTypes contains the types added using Register.
+var defaultTypes = NewTypes()
+
+// Register adds a new literal type to the set and returns the type assigned to
+// it. Builders using the set validate the values built and parse their pretty
+// printed form using the provided definition. Types should be registered
+// before any literal of the type is used.
+func (ts *Types) Register(d TypeDefinition) (Type, error) {
+	if !validTypeName(d.Name) {
+		return 0, fmt.Errorf("literal.Register: invalid type name %q", d.Name)
+	}
+	if d.Parse == nil || d.Format == nil {
+		return 0, fmt.Errorf("literal.Register: type %q requires Parse and Format functions", d.Name)
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if _, ok := ts.typeByName(d.Name); ok {
+		return 0, fmt.Errorf("literal.Register: type %q is already registered", d.Name)
+	}
+	customTypes.Lock()
+	defer customTypes.Unlock()
+	t := firstCustomType + Type(len(customTypes.m))
+	if t >= customMarker {
+		return 0, fmt.Errorf("literal.Register: cannot register more than %d types", customMarker-firstCustomType)
+	}
+	customTypes.m[t] = &d
+	ts.defs[t], ts.names[d.Name] = &d, t
+	return t, nil
+}
+
+// TypeByName returns the type with the provided name, either a built-in or one
+// of the set.
+func (ts *Types) TypeByName(n string) (Type, bool) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.typeByName(n)
+}
+
+// typeByName returns the type with the provided name. The caller must hold the
+// lock of the set.
+func (ts *Types) typeByName(n string) (Type, bool) {
+	for t := Bool; t <= Date; t++ {
+		if t.String() == n {
+			return t, true
+		}
+	}
+	t, ok := ts.names[n]
+	return t, ok
+}
+
+// definition returns the definition of a type of the set.
+func (ts *Types) definition(t Type) (*TypeDefinition, bool) {
+	if t < firstCustomType {
+		return nil, false
+	}
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	d, ok := ts.defs[t]
+	return d, ok
+}
+
+// Register adds a new literal type to the default set of types, available to
+// the builders returned by DefaultBuilder and NewBoundedBuilder, and returns
+// the type assigned to it; see Types.Register. Types should be registered
+// during initialization, before any literal of the type is used.
+func Register(d TypeDefinition) (Type, error) {
+	return defaultTypes.Register(d)
+}
+
+// TypeByName returns the type with the provided name, either a built-in or one
+// added using Register.
+func TypeByName(n string) (Type, bool) {
+	return defaultTypes.TypeByName(n)
+}
+
+// Registered returns true if the type was added to a set of types.
+func (t Type) Registered() bool {
+	_, ok := customDefinition(t)
+	return ok
+}
+
+// customDefinition returns the definition of a registered type, regardless of
+// the set it was registered on. Builders only accept the types of their own
+// set; literals, once built, use the definition of their type.
+func customDefinition(t Type) (*TypeDefinition, bool) {
+	if t < firstCustomType {
+		return nil, false
+	}
+	customTypes.RLock()
+	defer customTypes.RUnlock()
+	d, ok := customTypes.m[t]
+	return d, ok
+}
+
+// validTypeName returns true if the provided name can be used for a type.
+func validTypeName(n string) bool {
+	if n == "" || n[0] < 'a' || n[0] > 'z' {
+		return false
+	}
+	for _, r := range n {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
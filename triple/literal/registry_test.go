@@ -0,0 +1,221 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package literal
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	ipOnce sync.Once
+	ipType Type
+)
+
+// testIPType returns the type of a registered ipaddr literal type.
+func testIPType(t *testing.T) Type {
+	ipOnce.Do(func() {
+		var err error
+		ipType, err = Register(TypeDefinition{
+			Name: "ipaddr",
+			Parse: func(s string) (interface{}, error) {
+				ip := net.ParseIP(s)
+				if ip == nil {
+					return nil, fmt.Errorf("invalid IP address %q", s)
+				}
+				return ip.To16(), nil
+			},
+			Format: func(v interface{}) string {
+				return v.(net.IP).String()
+			},
+			Validate: func(v interface{}) error {
+				if ip, ok := v.(net.IP); !ok || len(ip) != net.IPv6len {
+					return fmt.Errorf("%v is not a 16 byte net.IP", v)
+				}
+				return nil
+			},
+			Compare: func(a, b interface{}) int {
+				return bytes.Compare(a.(net.IP), b.(net.IP))
+			},
+		})
+		if err != nil {
+			t.Fatalf("literal.Register failed with error %v", err)
+		}
+	})
+	return ipType
+}
+
+func TestRegister(t *testing.T) {
+	ip := testIPType(t)
+	if got, want := ip.String(), "ipaddr"; got != want {
+		t.Errorf("Type.String returned %q; want %q", got, want)
+	}
+	if got, ok := TypeByName("ipaddr"); !ok || got != ip {
+		t.Errorf("literal.TypeByName(%q) returned %v, %v; want %v, true", "ipaddr", got, ok, ip)
+	}
+	if !ip.Registered() || Duration.Registered() {
+		t.Errorf("Type.Registered should only be true for registered types")
+	}
+	if got, ok := TypeByName("duration"); !ok || got != Duration {
+		t.Errorf("literal.TypeByName(%q) returned %v, %v; want %v, true", "duration", got, ok, Duration)
+	}
+	parse := func(s string) (interface{}, error) { return s, nil }
+	format := func(v interface{}) string { return v.(string) }
+	for _, d := range []TypeDefinition{
+		{Name: "ipaddr", Parse: parse, Format: format},
+		{Name: "text", Parse: parse, Format: format},
+		{Name: "Geo", Parse: parse, Format: format},
+		{Name: "geo-point", Parse: parse, Format: format},
+		{Name: "", Parse: parse, Format: format},
+		{Name: "geopoint", Format: format},
+		{Name: "geopoint", Parse: parse},
+	} {
+		if _, err := Register(d); err == nil {
+			t.Errorf("literal.Register(%q) should have failed", d.Name)
+		}
+	}
+}
+
+func TestCustomLiterals(t *testing.T) {
+	ip := testIPType(t)
+	l, err := DefaultBuilder().Build(ip, net.ParseIP("10.0.0.1"))
+	if err != nil {
+		t.Fatalf("literal.Build failed with error %v", err)
+	}
+	if got, want := l.String(), `"10.0.0.1"^^type:ipaddr`; got != want {
+		t.Errorf("literal.String returned %q; want %q", got, want)
+	}
+	got, err := DefaultBuilder().Parse(l.String())
+	if err != nil {
+		t.Fatalf("literal.Parse(%q) failed with error %v", l, err)
+	}
+	if !reflect.DeepEqual(got, l) {
+		t.Errorf("literal.Parse(%q) returned %v; want %v", l, got, l)
+	}
+	bs, err := l.MarshalBinary()
+	if err != nil {
+		t.Fatalf("literal.MarshalBinary(%v) failed with error %v", l, err)
+	}
+	dec := &Literal{}
+	if err := dec.UnmarshalBinary(bs); err != nil {
+		t.Fatalf("literal.UnmarshalBinary(%q) failed with error %v", bs, err)
+	}
+	if !reflect.DeepEqual(dec, l) {
+		t.Errorf("literal.UnmarshalBinary returned %v; want %v", dec, l)
+	}
+	other, err := DefaultBuilder().Parse(`"10.0.0.2"^^type:ipaddr`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp, err := Compare(l, other); err != nil || cmp != -1 {
+		t.Errorf("literal.Compare(%v, %v) returned %d, %v; want -1, nil", l, other, cmp, err)
+	}
+	text, err := DefaultBuilder().Build(Text, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Compare(l, text); err == nil {
+		t.Errorf("literal.Compare(%v, %v) should have failed", l, text)
+	}
+
+	// Invalid custom literals.
+	if _, err := DefaultBuilder().Build(ip, "10.0.0.1"); err == nil {
+		t.Errorf("literal.Build should validate custom literal values")
+	}
+	if _, err := DefaultBuilder().Parse(`"not an ip"^^type:ipaddr`); err == nil {
+		t.Errorf("literal.Parse should fail to parse invalid custom literals")
+	}
+	if _, err := NewBoundedBuilder(4).Build(ip, net.ParseIP("10.0.0.1")); err == nil {
+		t.Errorf("bounded literal.Build should reject custom literals longer than its bound")
+	}
+	if _, err := NewBoundedBuilder(4).Parse(`"10.0.0.1"^^type:ipaddr`); err == nil {
+		t.Errorf("bounded literal.Parse should reject custom literals longer than its bound")
+	}
+	for _, b := range [][]byte{{customMarker}, {customMarker, 9, 'i'}, append([]byte{customMarker, 7}, "unknown"...)} {
+		if err := (&Literal{}).UnmarshalBinary(b); err == nil {
+			t.Errorf("literal.UnmarshalBinary(%q) should have failed", b)
+		}
+	}
+}
+
+func TestBuilderWithTypes(t *testing.T) {
+	// Two sets define the same type name differently.
+	parse := func(s string) (interface{}, error) { return s, nil }
+	upper, lower := NewTypes(), NewTypes()
+	ut, err := upper.Register(TypeDefinition{Name: "code", Parse: parse, Format: func(v interface{}) string { return strings.ToUpper(v.(string)) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lt, err := lower.Register(TypeDefinition{Name: "code", Parse: parse, Format: func(v interface{}) string { return strings.ToLower(v.(string)) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ut == lt {
+		t.Errorf("types registered on different sets should never be the same; got %v for both", ut)
+	}
+	testTable := []struct {
+		b    Builder
+		want string
+	}{
+		{NewBuilderWithTypes(upper), `"AB"^^type:code`},
+		{NewBuilderWithTypes(lower), `"ab"^^type:code`},
+		{NewBoundedBuilderWithTypes(upper, 8), `"AB"^^type:code`},
+	}
+	for _, entry := range testTable {
+		l, err := entry.b.Parse(`"aB"^^type:code`)
+		if err != nil {
+			t.Fatalf("literal.Parse failed with error %v", err)
+		}
+		if got := l.String(); got != entry.want {
+			t.Errorf("literal.Parse returned %q; want %q", got, entry.want)
+		}
+	}
+	if _, err := NewBuilderWithTypes(upper).Build(lt, "ab"); err == nil {
+		t.Errorf("literal.Build should reject types of other sets")
+	}
+	if _, ok := TypeByName("code"); ok {
+		t.Errorf("literal.TypeByName should only return the types added using Register")
+	}
+	if l, err := DefaultBuilder().Parse(`"ab"^^type:code`); err != nil || l != nil {
+		t.Errorf("the default builder should ignore the types of other sets; got %v, %v", l, err)
+	}
+	if _, err := NewBoundedBuilderWithTypes(upper, 1).Parse(`"ab"^^type:code`); err == nil {
+		t.Errorf("bounded literal.Parse should reject custom literals longer than its bound")
+	}
+
+	l, err := NewBuilderWithTypes(lower).Build(lt, "Ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := l.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Literal{}).UnmarshalBinary(bs); err == nil {
+		t.Errorf("literal.UnmarshalBinary should only decode the types added using Register")
+	}
+	dec, err := lower.UnmarshalLiteral(bs)
+	if err != nil {
+		t.Fatalf("Types.UnmarshalLiteral(%q) failed with error %v", bs, err)
+	}
+	if dec.Type() != lt || dec.String() != `"ab"^^type:code` {
+		t.Errorf("Types.UnmarshalLiteral(%q) returned %v; want %v", bs, dec, l)
+	}
+}
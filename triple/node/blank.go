@@ -0,0 +1,126 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tBlank is the type of all blank nodes.
+var tBlank = Type("/_")
+
+// blankNamespace is the UUID used as namespace to derive the name based
+// UUIDs of content derived blank nodes.
+var blankNamespace = [16]byte{
+	0x6b, 0xa0, 0x1f, 0x5e, 0x3c, 0x7d, 0x4a, 0x2b,
+	0x9e, 0x41, 0xd8, 0x0c, 0x55, 0xb3, 0x6f, 0x12,
+}
+
+var (
+	// session is the random UUIDv4 that prefixes the IDs of all the blank
+	// nodes created by NewBlankNode in this process.
+	session string
+	// blankCnt counts the blank nodes created by NewBlankNode so far.
+	blankCnt uint64
+)
+
+func init() {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// Fallback to the process start time and ID if the system entropy
+		// source is not available.
+		binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(b[8:], uint64(os.Getpid()))
+	}
+	session = formatUUID(b, 4)
+}
+
+// NewBlankNode creates a new blank node. The blank node ID is made of a random
+// UUIDv4 drawn once per process, followed by a counter incremented on every
+// call. IDs never repeat within a process, and two processes only collide if
+// they draw the same 122 random bits, which is negligible in practice.
+func NewBlankNode() *Node {
+	id := ID(fmt.Sprintf("%s:%x", session, atomic.AddUint64(&blankCnt, 1)-1))
+	return &Node{
+		t:  &tBlank,
+		id: &id,
+	}
+}
+
+// NewBlankNodeFromContent creates a blank node whose ID is a name based UUIDv5
+// derived from the provided content. The same content always yields the same
+// blank node, which makes it suitable for reproducible test fixtures and
+// idempotent imports. Different contents are guaranteed to yield different
+// IDs only up to the collision resistance of SHA-1.
+func NewBlankNodeFromContent(content []byte) *Node {
+	h := sha1.New()
+	h.Write(blankNamespace[:])
+	h.Write(content)
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	id := ID(formatUUID(b, 5))
+	return &Node{
+		t:  &tBlank,
+		id: &id,
+	}
+}
+
+// BlankNodeGenerator creates blank nodes whose IDs are UUIDv4 built from the
+// bytes read from an entropy source. Its IDs are as unique as the source is
+// random; a deterministic source, such as a seeded math/rand.Rand, yields
+// the same sequence of blank nodes on every run. It is safe for concurrent
+// use.
+type BlankNodeGenerator struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+// NewBlankNodeGenerator returns a blank node generator that reads the entropy
+// used to build IDs from the provided reader.
+func NewBlankNodeGenerator(r io.Reader) *BlankNodeGenerator {
+	return &BlankNodeGenerator{r: r}
+}
+
+// New creates a new blank node using the generator entropy source.
+func (g *BlankNodeGenerator) New() (*Node, error) {
+	var b [16]byte
+	g.mu.Lock()
+	_, err := io.ReadFull(g.r, b[:])
+	g.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("node.BlankNodeGenerator.New failed to read entropy; %v", err)
+	}
+	id := ID(formatUUID(b, 4))
+	return &Node{
+		t:  &tBlank,
+		id: &id,
+	}, nil
+}
+
+// formatUUID returns the canonical text form of the UUID made of the provided
+// bytes after setting its version and RFC 4122 variant bits.
+func formatUUID(b [16]byte, version byte) string {
+	b[6] = b[6]&0x0f | version<<4
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([0-9a-f])[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestBlankNode(t *testing.T) {
+	first := NewBlankNode()
+	ss := strings.Split(first.ID().String(), ":")
+	if len(ss) != 2 || !uuidRegexp.MatchString(ss[0]) {
+		t.Fatalf("NewBlankNode returned an invalid ID in %s", first)
+	}
+	if m := uuidRegexp.FindStringSubmatch(ss[0]); m[1] != "4" {
+		t.Errorf("NewBlankNode session %s is not a UUIDv4", ss[0])
+	}
+	var cnt uint64
+	if _, err := fmt.Sscanf(ss[1], "%x", &cnt); err != nil {
+		t.Fatalf("NewBlankNode returned an invalid counter in %s", first)
+	}
+	for i := uint64(1); i < 10; i++ {
+		b := NewBlankNode()
+		if got, want := b.ID().String(), fmt.Sprintf("%s:%x", ss[0], cnt+i); got != want {
+			t.Errorf("NewBlankNode failed to return increasing IDs; got %s, want %s", got, want)
+		}
+		if got, want := b.Type().String(), "/_"; got != want {
+			t.Errorf("NewBlankNode returned type %s; want %s", got, want)
+		}
+	}
+}
+
+func TestNewBlankNodeFromContent(t *testing.T) {
+	a, b := NewBlankNodeFromContent([]byte("foo")), NewBlankNodeFromContent([]byte("foo"))
+	if a.String() != b.String() {
+		t.Errorf("NewBlankNodeFromContent returned different nodes for the same content; got %s and %s", a, b)
+	}
+	m := uuidRegexp.FindStringSubmatch(a.ID().String())
+	if m == nil || m[1] != "5" {
+		t.Errorf("NewBlankNodeFromContent returned %s; want a UUIDv5 ID", a)
+	}
+	if c := NewBlankNodeFromContent([]byte("bar")); c.String() == a.String() {
+		t.Errorf("NewBlankNodeFromContent returned the same node %s for different contents", c)
+	}
+}
+
+func TestBlankNodeGenerator(t *testing.T) {
+	ids := func(seed int64) []string {
+		g := NewBlankNodeGenerator(rand.New(rand.NewSource(seed)))
+		var res []string
+		for i := 0; i < 5; i++ {
+			n, err := g.New()
+			if err != nil {
+				t.Fatalf("BlankNodeGenerator.New failed with error %v", err)
+			}
+			if m := uuidRegexp.FindStringSubmatch(n.ID().String()); m == nil || m[1] != "4" {
+				t.Errorf("BlankNodeGenerator.New returned %s; want a UUIDv4 ID", n)
+			}
+			res = append(res, n.ID().String())
+		}
+		return res
+	}
+	a, b, c := ids(1), ids(1), ids(2)
+	if strings.Join(a, ",") != strings.Join(b, ",") {
+		t.Errorf("BlankNodeGenerator returned different IDs for the same seed; got %v and %v", a, b)
+	}
+	if strings.Join(a, ",") == strings.Join(c, ",") {
+		t.Errorf("BlankNodeGenerator returned the same IDs %v for different seeds", a)
+	}
+	if _, err := NewBlankNodeGenerator(bytes.NewReader([]byte{1, 2, 3})).New(); err == nil {
+		t.Errorf("BlankNodeGenerator.New should fail when the entropy source is exhausted")
+	}
+}
@@ -17,12 +17,11 @@ package node
 
 import (
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
-	"hash/crc64"
-	"os"
-	"os/user"
 	"strings"
-	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Type describes the type of the node.
@@ -34,9 +33,39 @@ func (t *Type) String() string {
 }
 
 // Covariant checks if given two types A and B, A covariant B if B _is a_ A.
-// In other word, A _covariant_ B if B is a prefix of A.
+// In other word, A _covariant_ B if A is a subtype of B.
 func (t *Type) Covariant(ot *Type) bool {
-	return strings.HasPrefix(t.String(), ot.String())
+	return t.IsSubtypeOf(ot)
+}
+
+// IsSubtypeOf returns true if t is ot or lives under ot in the type
+// hierarchy. The hierarchy is defined by path segments, hence /u/person is a
+// subtype of /u, but /user is not.
+func (t *Type) IsSubtypeOf(ot *Type) bool {
+	ts, os := t.String(), ot.String()
+	return ts == os || strings.HasPrefix(ts, os+"/")
+}
+
+// Parent returns the type t directly lives under. Top level types, such as
+// /u, have no parent.
+func (t *Type) Parent() (*Type, bool) {
+	s := t.String()
+	idx := strings.LastIndex(s, "/")
+	if idx <= 0 {
+		return nil, false
+	}
+	p := Type(s[:idx])
+	return &p, true
+}
+
+// Ancestors returns all the types t lives under, starting from its parent
+// and ending on its top level type.
+func (t *Type) Ancestors() []*Type {
+	var res []*Type
+	for p, ok := t.Parent(); ok; p, ok = p.Parent() {
+		res = append(res, p)
+	}
+	return res
 }
 
 // ID represents a node ID.
@@ -63,9 +92,10 @@ func (n *Node) ID() *ID {
 	return n.id
 }
 
-// String returns a pretty printing representation of Node.
+// String returns a pretty printing representation of Node. The ID is escaped
+// using EscapeID.
 func (n *Node) String() string {
-	return fmt.Sprintf("%s<%s>", n.t.String(), n.id.String())
+	return fmt.Sprintf("%s<%s>", n.t.String(), EscapeID(n.id.String()))
 }
 
 // Parse returns a node given a pretty printed representation of Node.
@@ -82,23 +112,111 @@ func Parse(s string) (*Node, error) {
 	if raw[len(raw)-1] != '>' {
 		return nil, fmt.Errorf("node.Parser: pretty printing should finish with '>' in %q", raw)
 	}
-	id, err := NewID(raw[idx+1 : len(raw)-1])
+	sID, err := UnescapeID(raw[idx+1 : len(raw)-1])
+	if err != nil {
+		return nil, fmt.Errorf("node.Parser: invalid ID in %q, %v", raw, err)
+	}
+	id, err := NewID(sID)
 	if err != nil {
 		return nil, fmt.Errorf("node.Parser: invalid ID in %q, %v", raw, err)
 	}
 	return NewNode(t, id), nil
 }
 
+// EscapeID returns the provided ID with '<' and '>' escaped using a backslash,
+// so the pretty printed node can be parsed back. Backslashes preceding them or
+// ending the ID are doubled; any other backslash is left as is, hence IDs not
+// containing '<' or '>' nor ending with a backslash are returned unchanged.
+func EscapeID(id string) string {
+	if !strings.ContainsAny(id, "<>") && !strings.HasSuffix(id, `\`) {
+		return id
+	}
+	var sb strings.Builder
+	for i := 0; i < len(id); {
+		switch c := id[i]; c {
+		case '\\':
+			j := backslashes(id, i)
+			sb.WriteString(id[i:j])
+			if j == len(id) || id[j] == '<' || id[j] == '>' {
+				sb.WriteString(id[i:j])
+			}
+			i = j
+		case '<', '>':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+			i++
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// UnescapeID reverses EscapeID. Backslashes not preceding '<', '>', or the end
+// of the string are literal text. It fails if the provided string contains
+// unescaped '<' or '>'.
+func UnescapeID(s string) (string, error) {
+	if !strings.ContainsAny(s, "<>") && !strings.HasSuffix(s, `\`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case '<', '>':
+			return "", fmt.Errorf("node.UnescapeID: unescaped %q in %q", c, s)
+		case '\\':
+			j := backslashes(s, i)
+			if j < len(s) && s[j] != '<' && s[j] != '>' {
+				sb.WriteString(s[i:j])
+				i = j
+				continue
+			}
+			n := j - i
+			sb.WriteString(s[i : i+n/2])
+			switch {
+			case j == len(s):
+				// A lone trailing backslash, as printed before IDs were
+				// escaped, is kept.
+				sb.WriteString(s[i : i+n%2])
+				i = j
+			case n%2 == 0:
+				return "", fmt.Errorf("node.UnescapeID: unescaped %q in %q", s[j], s)
+			default:
+				sb.WriteByte(s[j])
+				i = j + 1
+			}
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String(), nil
+}
+
+// backslashes returns the index following the run of backslashes starting at
+// index i of s.
+func backslashes(s string, i int) int {
+	for i < len(s) && s[i] == '\\' {
+		i++
+	}
+	return i
+}
+
 // Covariant checks if the types of two nodes is covariant.
 func (n *Node) Covariant(on *Node) bool {
 	return n.t.Covariant(on.t)
 }
 
-// NewType creates a new type from plain string.
+// NewType creates a new type from plain string. Types cannot contain spaces,
+// nor the '<', '>', and '\\' characters reserved to delimit and escape IDs.
 func NewType(t string) (*Type, error) {
 	if strings.ContainsAny(t, " \t\n\r") {
 		return nil, fmt.Errorf("node.NewType(%q) does not allow spaces", t)
 	}
+	if strings.ContainsAny(t, "<>\\") {
+		return nil, fmt.Errorf("node.NewType(%q) does not allow '<', '>', or '\\'", t)
+	}
 	if !strings.HasPrefix(t, "/") || strings.HasSuffix(t, "/") {
 		return nil, fmt.Errorf("node.NewType(%q) should start with a '/' and do not end with '/'", t)
 	}
@@ -109,14 +227,21 @@ func NewType(t string) (*Type, error) {
 	return &nt, nil
 }
 
-// NewID create a new ID from a plain string.
+// NewID create a new ID from a plain string. IDs must be valid UTF-8 and
+// cannot contain control characters other than tabs, newlines, and carriage
+// returns.
 func NewID(id string) (*ID, error) {
-	if strings.ContainsAny(id, "<>") {
-		return nil, fmt.Errorf("node.NewID(%q) does not allow '<' or '>'", id)
-	}
 	if id == "" {
 		return nil, fmt.Errorf("node.NewID(%q) cannot create empty ID", id)
 	}
+	if !utf8.ValidString(id) {
+		return nil, fmt.Errorf("node.NewID(%q) requires valid UTF-8", id)
+	}
+	for _, r := range id {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return nil, fmt.Errorf("node.NewID(%q) does not allow control character %U", id, r)
+		}
+	}
 	nID := ID(id)
 	return &nID, nil
 }
@@ -143,59 +268,32 @@ func NewNodeFromStrings(sT, sID string) (*Node, error) {
 	return NewNode(t, n), nil
 }
 
-const chanSize = 256
-
-// The channel to recover the next unique value used to create a blank node.
-var (
-	nextVal chan string
-	tBlank  Type
-)
-
-func init() {
-	// Create the hashing function.
-	hasher := crc64.New(crc64.MakeTable(crc64.ECMA))
-	h := func(s string) uint64 {
-		hasher.Reset()
-		hasher.Write([]byte(s))
-		return hasher.Sum64()
-	}
-
-	// Get the current user name.
-	osU, err := user.Current()
-	u := "UNKNOW"
-	if err == nil {
-		u = osU.Username
-	}
-
-	// Create the constant to make build a unique ID.
-	start := uint64(time.Now().UnixNano())
-	user := h(u)
-	pid := uint64(os.Getpid())
-	// Initialize the channel and blank node type.
-	nextVal, tBlank = make(chan string, chanSize), Type("/_")
-	enc := base64.StdEncoding
-	go func() {
-		cnt := uint64(0)
-		for {
-			bs := []byte(fmt.Sprintf("%x:%x:%x:%x", start, user, pid, cnt))
-			nextVal <- enc.EncodeToString(bs)
-			cnt++
-		}
-	}()
-}
-
-// NewBlankNode creates a new blank node. The blank node ID is guaranteed to
-// be uique in BadWolf.
-func NewBlankNode() *Node {
-	id := ID(<-nextVal)
-	return &Node{
-		t:  &tBlank,
-		id: &id,
-	}
-}
-
 // GUID returns a global unique identifier for the given node. It is
 // implemented as the base64 encoded stringified version of the node.
 func (n *Node) GUID() string {
 	return base64.StdEncoding.EncodeToString([]byte(n.String()))
 }
+
+// MarshalBinary returns the binary encoding of the node. It contains the
+// length of the type as an unsigned varint, followed by the type and the ID.
+func (n *Node) MarshalBinary() ([]byte, error) {
+	t, id := n.t.String(), n.id.String()
+	b := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(t)+len(id))
+	b = append(b[:binary.PutUvarint(b, uint64(len(t)))], t...)
+	return append(b, id...), nil
+}
+
+// UnmarshalBinary sets the node to the one encoded in the provided data by
+// MarshalBinary.
+func (n *Node) UnmarshalBinary(data []byte) error {
+	l, k := binary.Uvarint(data)
+	if k <= 0 || uint64(len(data)-k) < l {
+		return fmt.Errorf("node.UnmarshalBinary: invalid encoded node %q", data)
+	}
+	nn, err := NewNodeFromStrings(string(data[k:k+int(l)]), string(data[k+int(l):]))
+	if err != nil {
+		return err
+	}
+	*n = *nn
+	return nil
+}
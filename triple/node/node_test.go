@@ -16,17 +16,20 @@ package node
 
 import (
 	"encoding/base64"
-	"fmt"
-	"strings"
+	"reflect"
 	"testing"
 )
 
 func TestNewID(t *testing.T) {
-	if wID, err := NewID("<"); err == nil {
-		t.Errorf("node.NewID(\"<\") should have never validated ID %v", wID)
+	for _, id := range []string{"", "a\x00b", "bell\a", "\xff"} {
+		if wID, err := NewID(id); err == nil {
+			t.Errorf("node.NewID(%q) should have never validated ID %v", id, wID)
+		}
 	}
-	if wID, err := NewID("<"); err == nil {
-		t.Errorf("node.NewID(\"<\") should have never validated ID %v", wID)
+	for _, id := range []string{"<", "a>b", "tab\tand\nnewline", `c:\path`} {
+		if _, err := NewID(id); err != nil {
+			t.Errorf("node.NewID(%q) failed with error %v", id, err)
+		}
 	}
 	id, err := NewID("some_id")
 	if err != nil {
@@ -48,6 +51,9 @@ func TestNewType(t *testing.T) {
 		{"/foo\t", "node.NewType should have never create a Type for a string that contains '\\t'"},
 		{"/foo\n", "node.NewType should have never create a Type for a string that contains '\\n'"},
 		{"/foo\r", "node.NewType should have never create a Type for a string that contains '\\r'"},
+		{"/foo<", "node.NewType should have never create a Type for a string that contains '<'"},
+		{"/foo>", "node.NewType should have never create a Type for a string that contains '>'"},
+		{"/foo\\", "node.NewType should have never create a Type for a string that contains '\\'"},
 	}
 	for _, c := range table {
 		if _, err := NewType(c.v); err == nil {
@@ -73,6 +79,59 @@ func TestNewTypeString(t *testing.T) {
 	}
 }
 
+func TestTypeHierarchy(t *testing.T) {
+	table := []struct {
+		t, ot string
+		want  bool
+	}{
+		{"/u", "/u", true},
+		{"/u/person", "/u", true},
+		{"/u/person/admin", "/u", true},
+		{"/u", "/u/person", false},
+		{"/user", "/u", false},
+		{"/organization/team", "/org", false},
+	}
+	for _, tc := range table {
+		tt, err := NewType(tc.t)
+		if err != nil {
+			t.Fatalf("node.NewType(%q) failed with error %v", tc.t, err)
+		}
+		ot, err := NewType(tc.ot)
+		if err != nil {
+			t.Fatalf("node.NewType(%q) failed with error %v", tc.ot, err)
+		}
+		if got := tt.IsSubtypeOf(ot); got != tc.want {
+			t.Errorf("node.IsSubtypeOf(%q, %q) returned %v; want %v", tc.t, tc.ot, got, tc.want)
+		}
+		if got := tt.Covariant(ot); got != tc.want {
+			t.Errorf("node.Covariant(%q, %q) returned %v; want %v", tc.t, tc.ot, got, tc.want)
+		}
+	}
+
+	tt, err := NewType("/u/person/admin")
+	if err != nil {
+		t.Fatalf("node.NewType(\"/u/person/admin\") failed with error %v", err)
+	}
+	p, ok := tt.Parent()
+	if !ok || p.String() != "/u/person" {
+		t.Errorf("node.Parent(%q) returned %v, %v; want /u/person, true", tt, p, ok)
+	}
+	var got []string
+	for _, a := range tt.Ancestors() {
+		got = append(got, a.String())
+	}
+	if want := []string{"/u/person", "/u"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("node.Ancestors(%q) returned %v; want %v", tt, got, want)
+	}
+	top, err := NewType("/u")
+	if err != nil {
+		t.Fatalf("node.NewType(\"/u\") failed with error %v", err)
+	}
+	if p, ok := top.Parent(); ok {
+		t.Errorf("node.Parent(%q) returned %v; top level types have no parent", top, p)
+	}
+}
+
 func TestNewNodeFromString(t *testing.T) {
 	nA, err := NewNodeFromStrings("/some/type", "id_1")
 	if err != nil {
@@ -103,7 +162,16 @@ func TestParse(t *testing.T) {
 	}{
 		// Valid text nodes.
 		{"/foo<123>", true},
+		{`/foo<a\<b\>c>`, true},
+		{`/foo<a\tb\\>`, true},
+		{`/foo<c:\path>`, true},
+		{`/foo<a\xb>`, true},
 		// Invalid text nodes.
+		{"/foo<<>", false},
+		{"/foo<a<b>", false},
+		{"/foo<a>b>", false},
+		{`/foo<a\\<b>`, false},
+		{"/foo 123", false},
 	}
 	for _, tc := range table {
 		_, err := Parse(tc.s)
@@ -116,19 +184,105 @@ func TestParse(t *testing.T) {
 	}
 }
 
-func TestBlankNode(t *testing.T) {
-	for i := uint64(0); i < 10; i++ {
-		b := NewBlankNode()
-		bb, err := base64.StdEncoding.DecodeString(b.ID().String())
+func TestEscapeID(t *testing.T) {
+	table := []struct {
+		id, want string
+	}{
+		{"plain id", "plain id"},
+		{"<a>", `\<a\>`},
+		{"a\tb\nc\rd", "a\tb\nc\rd"},
+		{`c:\dir\n`, `c:\dir\n`},
+		{`c:\dir\`, `c:\dir\\`},
+		{`a\<b\\>c\\`, `a\\\<b\\\\\>c\\\\`},
+	}
+	for _, entry := range table {
+		got := EscapeID(entry.id)
+		if got != entry.want {
+			t.Errorf("node.EscapeID(%q) returned %q; want %q", entry.id, got, entry.want)
+		}
+		back, err := UnescapeID(got)
+		if err != nil || back != entry.id {
+			t.Errorf("node.UnescapeID(%q) returned %q, %v; want %q, nil", got, back, err, entry.id)
+		}
+		n, err := NewNodeFromStrings("/some/type", entry.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := n.String(), "/some/type<"+entry.want+">"; got != want {
+			t.Errorf("node.String returned %q; want %q", got, want)
+		}
+		pn, err := Parse(n.String())
+		if err != nil {
+			t.Errorf("node.Parse(%q) failed with error %v", n, err)
+			continue
+		}
+		if got := pn.ID().String(); got != entry.id {
+			t.Errorf("node.Parse(%q) returned ID %q; want %q", n, got, entry.id)
+		}
+	}
+}
+
+func TestUnescapeID(t *testing.T) {
+	table := []struct {
+		s, want string
+	}{
+		{`c:\path`, `c:\path`},
+		{`a\\b`, `a\\b`},
+		{`a\tb`, `a\tb`},
+		{`c:\`, `c:\`},
+		{`c:\\`, `c:\`},
+		{`\<\>`, "<>"},
+	}
+	for _, entry := range table {
+		if got, err := UnescapeID(entry.s); err != nil || got != entry.want {
+			t.Errorf("node.UnescapeID(%q) returned %q, %v; want %q, nil", entry.s, got, err, entry.want)
+		}
+	}
+	for _, s := range []string{"<", "a>b", `a\\<b`} {
+		if got, err := UnescapeID(s); err == nil {
+			t.Errorf("node.UnescapeID(%q) returned %q; it should reject unescaped '<' and '>'", s, got)
+		}
+	}
+}
+
+func TestBackslashIDsKeepTheirGUID(t *testing.T) {
+	// IDs with backslashes were pretty printed as is before IDs were escaped;
+	// they must keep the same text, and hence the same GUID.
+	for _, s := range []string{`/foo<c:\path>`, `/foo<a\\b>`, `/foo<\n\t>`} {
+		n, err := Parse(s)
+		if err != nil {
+			t.Fatalf("node.Parse(%q) failed with error %v", s, err)
+		}
+		if got := n.String(); got != s {
+			t.Errorf("node.Parse(%q).String() returned %q; want %q", s, got, s)
+		}
+		if got, want := n.GUID(), base64.StdEncoding.EncodeToString([]byte(s)); got != want {
+			t.Errorf("node.GUID of %q returned %q; want %q", s, got, want)
+		}
+	}
+}
+
+func TestBinaryEncoding(t *testing.T) {
+	for _, s := range []string{"/some/type<some id>", "/u<joe>", "/_<a+b=>", `/u<a\<b\tc>`} {
+		n, err := Parse(s)
 		if err != nil {
-			t.Fatalf("NewBlankNode %s could not be decoded", b)
+			t.Fatal(err)
 		}
-		ss := strings.Split(string(bb), ":")
-		if len(ss) != 4 {
-			t.Errorf("NewBlankNode returned an invalid ID in %s, decoded to %s", b, string(bb))
+		b, err := n.MarshalBinary()
+		if err != nil {
+			t.Fatalf("node.MarshalBinary(%v) failed with error %v", n, err)
 		}
-		if got, want := ss[3], fmt.Sprintf("%x", i); got != want {
-			t.Errorf("NewBlankNode failed to return increasing IDs in %s; got %s, want %s", b, got, want)
+		got := &Node{}
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("node.UnmarshalBinary(%q) failed with error %v", b, err)
+		}
+		if got.String() != n.String() {
+			t.Errorf("node.UnmarshalBinary returned the wrong node; got %v, want %v", got, n)
+		}
+	}
+	for _, b := range [][]byte{nil, {0x80}, {10, '/', 'u'}, {2, '/', 'u'}, {2, 'u', 'x', 'i', 'd'}} {
+		if err := (&Node{}).UnmarshalBinary(b); err == nil {
+			t.Errorf("node.UnmarshalBinary(%q) should have failed", b)
 		}
 	}
 }
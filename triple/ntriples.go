@@ -0,0 +1,707 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triple
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// XSDNamespace is the namespace of the XML Schema datatypes RDF literals are
+// typed with. RDFLiteral and RDFLexicalForm map them to and from literals.
+const XSDNamespace = "http://www.w3.org/2001/XMLSchema#"
+
+const (
+	// defaultIRINodeType is the node type used for IRIs when no other type is
+	// configured.
+	defaultIRINodeType = "/iri"
+	// blankNodeType is the node type used for blank nodes.
+	blankNodeType = "/_"
+)
+
+// NTriplesMapping configures how the IRIs found on N-Triples documents map to
+// BadWolf nodes and predicates. A nil mapping uses the default values.
+type NTriplesMapping struct {
+	// NodeTypes maps IRI prefixes to node types. An IRI starting with one of
+	// the prefixes becomes a node of the mapped type whose ID is the rest of
+	// the IRI. The longest matching prefix is used.
+	NodeTypes map[string]string
+	// DefaultNodeType is the type of the nodes whose IRI does not match any of
+	// the prefixes. Their ID is the whole IRI. If empty, "/iri" is used.
+	DefaultNodeType string
+	// PredicatePrefix is removed from the predicate IRIs to build the ID of the
+	// immutable predicates, and added back when writing them.
+	PredicatePrefix string
+	// BlankNodes, if set, maps the blank node labels found when parsing to new
+	// blank nodes unique to the scope. Otherwise, labels are used as the blank
+	// node IDs. Use a new scope for each parsed document.
+	BlankNodes *BlankNodeScope
+}
+
+// defaultType returns the node type for IRIs not matching any prefix.
+func (m *NTriplesMapping) defaultType() string {
+	if m == nil || m.DefaultNodeType == "" {
+		return defaultIRINodeType
+	}
+	return m.DefaultNodeType
+}
+
+// Node returns the node for the provided IRI.
+func (m *NTriplesMapping) Node(iri string) (*node.Node, error) {
+	t, id, best := m.defaultType(), iri, -1
+	if m != nil {
+		for p, pt := range m.NodeTypes {
+			if len(p) > best && strings.HasPrefix(iri, p) {
+				t, id, best = pt, iri[len(p):], len(p)
+			}
+		}
+	}
+	return node.NewNodeFromStrings(t, id)
+}
+
+// NodeIRI returns the IRI for the provided node. Blank nodes have no IRI.
+func (m *NTriplesMapping) NodeIRI(n *node.Node) (string, error) {
+	t := n.Type().String()
+	if m != nil {
+		var ps []string
+		for p, pt := range m.NodeTypes {
+			if pt == t {
+				ps = append(ps, p)
+			}
+		}
+		if len(ps) > 0 {
+			sort.Strings(ps)
+			return ps[len(ps)-1] + n.ID().String(), nil
+		}
+	}
+	if t == m.defaultType() {
+		return n.ID().String(), nil
+	}
+	return "", fmt.Errorf("no IRI mapping for node type %q of node %v", t, n)
+}
+
+// Predicate returns the immutable predicate for the provided IRI.
+func (m *NTriplesMapping) Predicate(iri string) (*predicate.Predicate, error) {
+	if m != nil {
+		iri = strings.TrimPrefix(iri, m.PredicatePrefix)
+	}
+	return predicate.NewImmutable(iri)
+}
+
+// PredicateIRI returns the IRI for the provided predicate. Temporal predicates
+// have no IRI.
+func (m *NTriplesMapping) PredicateIRI(p *predicate.Predicate) (string, error) {
+	if p.Type() != predicate.Immutable {
+		return "", fmt.Errorf("temporal predicate %v cannot be represented in N-Triples", p)
+	}
+	if m == nil {
+		return string(p.ID()), nil
+	}
+	return m.PredicatePrefix + string(p.ID()), nil
+}
+
+// ParseNTriple parses the provided N-Triples statement into a triple. IRIs and
+// blank nodes are mapped to nodes using the provided mapping, predicates
+// become immutable predicates, and literals are converted to the closest
+// literal type available. Empty lines and comments are not statements, and
+// should be skipped by the callers.
+func ParseNTriple(line string, m *NTriplesMapping, b literal.Builder) (*Triple, error) {
+	t, g, err := ParseNQuad(line, m, b)
+	if err != nil {
+		return nil, err
+	}
+	if g != "" {
+		return nil, fmt.Errorf("triple.ParseNTriple does not allow graph labels; found %q in %q", g, line)
+	}
+	return t, nil
+}
+
+// ParseNQuad parses the provided N-Quads statement into a triple in the same
+// way ParseNTriple does. It also returns the graph label of the statement, or
+// an empty string if the statement has none. Blank node labels are returned
+// with their "_:" prefix.
+func ParseNQuad(line string, m *NTriplesMapping, b literal.Builder) (*Triple, string, error) {
+	p := &ntParser{s: line}
+	t, g, err := p.statement(m, b)
+	if err != nil {
+		return nil, "", fmt.Errorf("triple.ParseNQuad failed to parse %q at position %d: %v", line, p.i, err)
+	}
+	return t, g, nil
+}
+
+// ntParser parses a single N-Triples or N-Quads statement.
+type ntParser struct {
+	s string
+	i int
+}
+
+func (p *ntParser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t' || p.s[p.i] == '\r' || p.s[p.i] == '\n') {
+		p.i++
+	}
+}
+
+func (p *ntParser) peek() byte {
+	if p.i >= len(p.s) {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+// statement parses the whole statement.
+func (p *ntParser) statement(m *NTriplesMapping, b literal.Builder) (*Triple, string, error) {
+	p.skipSpace()
+	s, err := p.node(m)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid subject: %v", err)
+	}
+	p.skipSpace()
+	piri, err := p.iri()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid predicate: %v", err)
+	}
+	pr, err := m.Predicate(piri)
+	if err != nil {
+		return nil, "", err
+	}
+	p.skipSpace()
+	var o *Object
+	if p.peek() == '"' {
+		l, err := p.literal(b)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid object: %v", err)
+		}
+		o = NewLiteralObject(l)
+	} else {
+		n, err := p.node(m)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid object: %v", err)
+		}
+		o = NewNodeObject(n)
+	}
+	p.skipSpace()
+	var g string
+	switch p.peek() {
+	case '<':
+		if g, err = p.iri(); err != nil {
+			return nil, "", fmt.Errorf("invalid graph label: %v", err)
+		}
+	case '_':
+		if g, err = p.blankLabel(); err != nil {
+			return nil, "", fmt.Errorf("invalid graph label: %v", err)
+		}
+		g = "_:" + g
+	}
+	p.skipSpace()
+	if p.peek() != '.' {
+		return nil, "", fmt.Errorf("missing final '.'")
+	}
+	p.i++
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] != '#' {
+		return nil, "", fmt.Errorf("unexpected text %q after the statement", p.s[p.i:])
+	}
+	t, err := New(s, pr, o)
+	return t, g, err
+}
+
+// node parses an IRI or a blank node.
+func (p *ntParser) node(m *NTriplesMapping) (*node.Node, error) {
+	if p.peek() == '_' {
+		l, err := p.blankLabel()
+		if err != nil {
+			return nil, err
+		}
+		if m != nil && m.BlankNodes != nil {
+			return m.BlankNodes.Label(l), nil
+		}
+		return node.NewNodeFromStrings(blankNodeType, l)
+	}
+	iri, err := p.iri()
+	if err != nil {
+		return nil, err
+	}
+	return m.Node(iri)
+}
+
+// iri parses an IRI enclosed in angle brackets.
+func (p *ntParser) iri() (string, error) {
+	if p.peek() != '<' {
+		return "", fmt.Errorf("expected '<'")
+	}
+	p.i++
+	var sb strings.Builder
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case c == '>':
+			p.i++
+			if sb.Len() == 0 {
+				return "", fmt.Errorf("empty IRI")
+			}
+			return sb.String(), nil
+		case c == '\\':
+			r, err := p.uchar()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(r)
+		case c <= ' ' || strings.IndexByte("<\"{}|^`", c) >= 0:
+			return "", fmt.Errorf("invalid character %q in IRI", c)
+		default:
+			sb.WriteByte(c)
+			p.i++
+		}
+	}
+	return "", fmt.Errorf("unterminated IRI")
+}
+
+// blankLabel parses a blank node and returns its label.
+func (p *ntParser) blankLabel() (string, error) {
+	if !strings.HasPrefix(p.s[p.i:], "_:") {
+		return "", fmt.Errorf("expected '_:'")
+	}
+	p.i += 2
+	st := p.i
+	for p.i < len(p.s) && isBlankLabelByte(p.s[p.i]) {
+		p.i++
+	}
+	// Labels cannot end with a dot, which terminates the statement instead.
+	for p.i > st && p.s[p.i-1] == '.' {
+		p.i--
+	}
+	if p.i == st {
+		return "", fmt.Errorf("empty blank node label")
+	}
+	return p.s[st:p.i], nil
+}
+
+// isBlankLabelByte returns true if the byte can be part of a blank node label.
+// Non ASCII bytes are accepted as part of UTF-8 encoded letters.
+func isBlankLabelByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-' || c == '.' || c >= utf8.RuneSelf
+}
+
+// uchar parses a \u or \U escaped code point.
+func (p *ntParser) uchar() (rune, error) {
+	if p.i+1 >= len(p.s) {
+		return 0, fmt.Errorf("unterminated escape sequence")
+	}
+	n := 0
+	switch p.s[p.i+1] {
+	case 'u':
+		n = 4
+	case 'U':
+		n = 8
+	default:
+		return 0, fmt.Errorf("invalid escape sequence \\%c", p.s[p.i+1])
+	}
+	st := p.i + 2
+	if st+n > len(p.s) {
+		return 0, fmt.Errorf("unterminated escape sequence")
+	}
+	v, err := strconv.ParseUint(p.s[st:st+n], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(v)) {
+		return 0, fmt.Errorf("invalid escape sequence %q", p.s[p.i:st+n])
+	}
+	p.i = st + n
+	return rune(v), nil
+}
+
+// literal parses a quoted literal with its optional language tag or datatype.
+func (p *ntParser) literal(b literal.Builder) (*literal.Literal, error) {
+	p.i++
+	var sb strings.Builder
+	closed := false
+	for p.i < len(p.s) && !closed {
+		c := p.s[p.i]
+		switch c {
+		case '"':
+			closed = true
+			p.i++
+		case '\\':
+			if p.i+1 >= len(p.s) {
+				return nil, fmt.Errorf("unterminated escape sequence")
+			}
+			if e := strings.IndexByte("tbnrf\"'\\", p.s[p.i+1]); e >= 0 {
+				sb.WriteByte("\t\b\n\r\f\"'\\"[e])
+				p.i += 2
+				continue
+			}
+			r, err := p.uchar()
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteRune(r)
+		case '\n', '\r':
+			return nil, fmt.Errorf("unescaped line break in literal")
+		default:
+			sb.WriteByte(c)
+			p.i++
+		}
+	}
+	if !closed {
+		return nil, fmt.Errorf("unterminated literal")
+	}
+	v := sb.String()
+	switch {
+	case p.peek() == '@':
+		p.i++
+		st := p.i
+		for p.i < len(p.s) && (isBlankLabelByte(p.s[p.i]) && p.s[p.i] != '.' && p.s[p.i] != '_') {
+			p.i++
+		}
+		if p.i == st {
+			return nil, fmt.Errorf("empty language tag")
+		}
+		return b.Build(literal.Text, v)
+	case strings.HasPrefix(p.s[p.i:], "^^"):
+		p.i += 2
+		dt, err := p.iri()
+		if err != nil {
+			return nil, fmt.Errorf("invalid datatype: %v", err)
+		}
+		return RDFLiteral(v, dt, b)
+	}
+	return b.Build(literal.Text, v)
+}
+
+// RDFLiteral returns the literal for the provided RDF lexical form and datatype
+// IRI. Literals with no datatype, or with a datatype with no literal
+// counterpart, are kept as text.
+func RDFLiteral(v, dt string, b literal.Builder) (*literal.Literal, error) {
+	if !strings.HasPrefix(dt, XSDNamespace) {
+		return b.Build(literal.Text, v)
+	}
+	switch strings.TrimPrefix(dt, XSDNamespace) {
+	case "boolean":
+		switch strings.TrimSpace(v) {
+		case "true", "1":
+			return b.Build(literal.Bool, true)
+		case "false", "0":
+			return b.Build(literal.Bool, false)
+		}
+		return nil, fmt.Errorf("invalid xsd:boolean value %q", v)
+	case "integer", "long", "int", "short", "byte", "nonNegativeInteger", "positiveInteger",
+		"negativeInteger", "nonPositiveInteger", "unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte":
+		i, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(v), "+"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %v", dt, v, err)
+		}
+		return b.Build(literal.Int64, i)
+	case "double":
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %v", dt, v, err)
+		}
+		return b.Build(literal.Float64, f)
+	case "float":
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %v", dt, v, err)
+		}
+		return b.Build(literal.Float32, float32(f))
+	case "decimal":
+		r, err := literal.ParseDecimal(strings.TrimPrefix(strings.TrimSpace(v), "+"))
+		if err != nil || strings.ContainsAny(v, "eE") {
+			return nil, fmt.Errorf("invalid xsd:decimal value %q", v)
+		}
+		return b.Build(literal.Decimal, r)
+	case "date":
+		t, err := time.Parse("2006-01-02", strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid xsd:date value %q: %v", v, err)
+		}
+		return b.Build(literal.Date, t)
+	case "base64Binary":
+		bs, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid xsd:base64Binary value %q: %v", v, err)
+		}
+		return b.Build(literal.Blob, bs)
+	case "hexBinary":
+		bs, err := hex.DecodeString(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid xsd:hexBinary value %q: %v", v, err)
+		}
+		return b.Build(literal.Blob, bs)
+	case "duration", "dayTimeDuration":
+		d, err := parseXSDDuration(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		return b.Build(literal.Duration, d)
+	}
+	return b.Build(literal.Text, v)
+}
+
+// parseXSDDuration parses a xsd:duration value. Years and months have no fixed
+// length, hence they are not supported.
+func parseXSDDuration(v string) (time.Duration, error) {
+	s, neg := v, false
+	if strings.HasPrefix(s, "-") {
+		s, neg = s[1:], true
+	}
+	if !strings.HasPrefix(s, "P") || len(s) == 1 {
+		return 0, fmt.Errorf("invalid xsd:duration value %q", v)
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' && !inTime {
+			s, inTime = s[1:], true
+			continue
+		}
+		i := strings.IndexAny(s, "YMDHS")
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid xsd:duration value %q", v)
+		}
+		f, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid xsd:duration value %q", v)
+		}
+		var unit time.Duration
+		switch {
+		case !inTime && s[i] == 'D':
+			unit = 24 * time.Hour
+		case inTime && s[i] == 'H':
+			unit = time.Hour
+		case inTime && s[i] == 'M':
+			unit = time.Minute
+		case inTime && s[i] == 'S':
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("unsupported xsd:duration value %q; only days, hours, minutes, and seconds are supported", v)
+		}
+		d += time.Duration(math.Round(f * float64(unit)))
+		s = s[i+1:]
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// formatXSDDuration returns the xsd:duration value for the provided duration.
+func formatXSDDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	return sign + "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
+
+// NTriplesWriter writes triples as N-Triples or N-Quads statements, one per
+// line.
+type NTriplesWriter struct {
+	w io.Writer
+	m *NTriplesMapping
+}
+
+// NewNTriplesWriter returns a writer that maps nodes and predicates to IRIs
+// using the provided mapping.
+func NewNTriplesWriter(w io.Writer, m *NTriplesMapping) *NTriplesWriter {
+	return &NTriplesWriter{w: w, m: m}
+}
+
+// Write writes the triple as an N-Triples statement. It fails for triples
+// that have no N-Triples counterpart, such as the ones with temporal
+// predicates, embedded triples, or nodes whose type has no IRI mapping.
+func (w *NTriplesWriter) Write(t *Triple) error {
+	return w.WriteQuad(t, "")
+}
+
+// WriteQuad writes the triple as an N-Quads statement on the provided graph.
+// Graph labels starting with "_:" are written as blank nodes. If the graph is
+// empty, it writes an N-Triples statement instead.
+func (w *NTriplesWriter) WriteQuad(t *Triple, g string) error {
+	var sb strings.Builder
+	if err := w.node(&sb, t.S()); err != nil {
+		return fmt.Errorf("triple.NTriplesWriter failed to write %v: %v", t, err)
+	}
+	sb.WriteByte(' ')
+	piri, err := w.m.PredicateIRI(t.P())
+	if err != nil {
+		return fmt.Errorf("triple.NTriplesWriter failed to write %v: %v", t, err)
+	}
+	writeIRI(&sb, piri)
+	sb.WriteByte(' ')
+	o := t.O()
+	var oerr error
+	if n, ok := o.AsNode(); ok {
+		oerr = w.node(&sb, n)
+	} else if l, ok := o.AsLiteral(); ok {
+		oerr = writeNTLiteral(&sb, l)
+	} else {
+		oerr = fmt.Errorf("object %v cannot be represented in N-Triples", o)
+	}
+	if oerr != nil {
+		return fmt.Errorf("triple.NTriplesWriter failed to write %v: %v", t, oerr)
+	}
+	switch {
+	case strings.HasPrefix(g, "_:"):
+		sb.WriteByte(' ')
+		sb.WriteString(blankLabel(g[2:]))
+	case g != "":
+		sb.WriteByte(' ')
+		writeIRI(&sb, g)
+	}
+	sb.WriteString(" .\n")
+	_, err = io.WriteString(w.w, sb.String())
+	return err
+}
+
+// node writes the IRI or blank node for the provided node.
+func (w *NTriplesWriter) node(sb *strings.Builder, n *node.Node) error {
+	if n.Type().String() == blankNodeType {
+		sb.WriteString(blankLabel(n.ID().String()))
+		return nil
+	}
+	iri, err := w.m.NodeIRI(n)
+	if err != nil {
+		return err
+	}
+	writeIRI(sb, iri)
+	return nil
+}
+
+// blankLabel returns the blank node for the provided ID. IDs that are not
+// valid labels, such as the ones of the BadWolf generated blank nodes, are hex
+// encoded.
+func blankLabel(id string) string {
+	valid := !strings.HasSuffix(id, ".")
+	for i := 0; i < len(id) && valid; i++ {
+		valid = isBlankLabelByte(id[i])
+	}
+	if !valid {
+		return "_:x" + hex.EncodeToString([]byte(id))
+	}
+	return "_:" + id
+}
+
+// writeIRI writes the provided IRI escaping the characters not allowed in it.
+func writeIRI(sb *strings.Builder, iri string) {
+	sb.WriteByte('<')
+	for _, r := range iri {
+		if r <= ' ' || strings.ContainsRune("<>\"{}|^`\\", r) {
+			fmt.Fprintf(sb, "\\u%04X", r)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('>')
+}
+
+// RDFLexicalForm returns the RDF lexical form and the datatype IRI for the
+// provided literal. The datatype of text literals is empty.
+func RDFLexicalForm(l *literal.Literal) (string, string, error) {
+	var v, dt string
+	switch l.Type() {
+	case literal.Bool:
+		b, _ := l.Bool()
+		v, dt = strconv.FormatBool(b), "boolean"
+	case literal.Int64:
+		i, _ := l.Int64()
+		v, dt = strconv.FormatInt(i, 10), "long"
+	case literal.Float64:
+		f, _ := l.Float64()
+		switch {
+		case math.IsInf(f, 1):
+			v = "INF"
+		case math.IsInf(f, -1):
+			v = "-INF"
+		default:
+			v = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		dt = "double"
+	case literal.Float32:
+		f, _ := l.Float32()
+		switch {
+		case math.IsInf(float64(f), 1):
+			v = "INF"
+		case math.IsInf(float64(f), -1):
+			v = "-INF"
+		default:
+			v = strconv.FormatFloat(float64(f), 'g', -1, 32)
+		}
+		dt = "float"
+	case literal.Decimal:
+		r, _ := l.Decimal()
+		v, dt = literal.FormatDecimal(r), "decimal"
+	case literal.Date:
+		t, _ := l.Date()
+		v, dt = t.Format("2006-01-02"), "date"
+	case literal.Text:
+		v, _ = l.Text()
+	case literal.Blob:
+		b, _ := l.Blob()
+		v, dt = base64.StdEncoding.EncodeToString(b), "base64Binary"
+	case literal.Duration:
+		d, _ := l.Duration()
+		v, dt = formatXSDDuration(d), "duration"
+	default:
+		return "", "", fmt.Errorf("literal %v of unknown type", l)
+	}
+	if dt != "" {
+		dt = XSDNamespace + dt
+	}
+	return v, dt, nil
+}
+
+// writeNTLiteral writes the provided literal using the xsd datatype that
+// matches its type.
+func writeNTLiteral(sb *strings.Builder, l *literal.Literal) error {
+	v, dt, err := RDFLexicalForm(l)
+	if err != nil {
+		return err
+	}
+	sb.WriteByte('"')
+	for _, r := range v {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	if dt != "" {
+		sb.WriteString("^^")
+		writeIRI(sb, dt)
+	}
+	return nil
+}
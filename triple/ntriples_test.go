@@ -0,0 +1,241 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triple
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+func testNTriplesMapping() *NTriplesMapping {
+	return &NTriplesMapping{
+		NodeTypes: map[string]string{
+			"http://example.org/":        "/example",
+			"http://example.org/people/": "/person",
+		},
+		PredicatePrefix: "http://example.org/rel/",
+	}
+}
+
+func TestParseNTriple(t *testing.T) {
+	table := []struct {
+		line string
+		want string
+	}{
+		{
+			line: `<http://example.org/people/joe> <http://example.org/rel/knows> <http://example.org/people/mary> .`,
+			want: "/person<joe>\t\"knows\"@[]\t/person<mary>",
+		},
+		{
+			line: `<http://example.org/city> <http://example.org/rel/name> "Zürich \"big\"\tcity"@de-CH . # comment`,
+			want: "/example<city>\t\"name\"@[]\t\"Zürich \"big\"\tcity\"^^type:text",
+		},
+		{
+			line: `_:b1 <http://other.org/p> <http://other.org/x\u0020y> .`,
+			want: "/_<b1>\t\"http://other.org/p\"@[]\t/iri<http://other.org/x y>",
+		},
+		{
+			line: "<http://a.org/s>\t<http://a.org/p>\t\"42\"^^<http://www.w3.org/2001/XMLSchema#integer>.",
+			want: "/iri<http://a.org/s>\t\"http://a.org/p\"@[]\t\"42\"^^type:int64",
+		},
+		{
+			line: `<http://a.org/s> <http://a.org/p> "1.5"^^<http://www.w3.org/2001/XMLSchema#double> .`,
+			want: "/iri<http://a.org/s>\t\"http://a.org/p\"@[]\t\"1.5\"^^type:float64",
+		},
+		{
+			line: `<http://a.org/s> <http://a.org/p> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .`,
+			want: "/iri<http://a.org/s>\t\"http://a.org/p\"@[]\t\"true\"^^type:bool",
+		},
+		{
+			line: `<http://a.org/s> <http://a.org/p> "P1DT1H30.5S"^^<http://www.w3.org/2001/XMLSchema#duration> .`,
+			want: "/iri<http://a.org/s>\t\"http://a.org/p\"@[]\t\"25h0m30.5s\"^^type:duration",
+		},
+		{
+			line: `<http://a.org/s> <http://a.org/p> "2015-01-01"^^<http://www.w3.org/2001/XMLSchema#date> .`,
+			want: "/iri<http://a.org/s>\t\"http://a.org/p\"@[]\t\"2015-01-01\"^^type:date",
+		},
+		{
+			line: `<http://a.org/s> <http://a.org/p> _:o.`,
+			want: "/iri<http://a.org/s>\t\"http://a.org/p\"@[]\t/_<o>",
+		},
+	}
+	for _, entry := range table {
+		tr, err := ParseNTriple(entry.line, testNTriplesMapping(), literal.DefaultBuilder())
+		if err != nil {
+			t.Errorf("triple.ParseNTriple(%q) failed with error %v", entry.line, err)
+			continue
+		}
+		if got := tr.String(); got != entry.want {
+			t.Errorf("triple.ParseNTriple(%q) returned the wrong triple; got %q, want %q", entry.line, got, entry.want)
+		}
+	}
+}
+
+func TestParseNTripleErrors(t *testing.T) {
+	table := []string{
+		``,
+		`# just a comment`,
+		`<http://a.org/s> <http://a.org/p> <http://a.org/o>`,
+		`<http://a.org/s> <http://a.org/p> <http://a.org/o> . extra`,
+		`<http://a.org/s> _:p <http://a.org/o> .`,
+		`"s" <http://a.org/p> <http://a.org/o> .`,
+		`<http://a.org/s> <http://a.org/p> "unterminated .`,
+		`<http://a.org/s> <http://a.org/p> "x"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+		`<http://a.org/s> <http://a.org/p> "P1Y"^^<http://www.w3.org/2001/XMLSchema#duration> .`,
+		`<http://a.org/s> <http://a.org/p> "x"@ .`,
+		`<http://a.org/s> <http://a.org/p> <http://a.org/o> <http://a.org/g> .`,
+		`<http://a.org/s x> <http://a.org/p> <http://a.org/o> .`,
+		`<http://a.org/s\u00ZZ> <http://a.org/p> <http://a.org/o> .`,
+		`<> <http://a.org/p> <http://a.org/o> .`,
+	}
+	for _, line := range table {
+		if _, err := ParseNTriple(line, nil, literal.DefaultBuilder()); err == nil {
+			t.Errorf("triple.ParseNTriple(%q) should have failed", line)
+		}
+	}
+}
+
+func TestParseNQuad(t *testing.T) {
+	table := []struct {
+		line string
+		g    string
+	}{
+		{`<http://a.org/s> <http://a.org/p> <http://a.org/o> .`, ""},
+		{`<http://a.org/s> <http://a.org/p> <http://a.org/o> <http://a.org/g> .`, "http://a.org/g"},
+		{`<http://a.org/s> <http://a.org/p> "o" _:g .`, "_:g"},
+	}
+	for _, entry := range table {
+		_, g, err := ParseNQuad(entry.line, nil, literal.DefaultBuilder())
+		if err != nil {
+			t.Errorf("triple.ParseNQuad(%q) failed with error %v", entry.line, err)
+			continue
+		}
+		if g != entry.g {
+			t.Errorf("triple.ParseNQuad(%q) returned the wrong graph; got %q, want %q", entry.line, g, entry.g)
+		}
+	}
+}
+
+func TestNTriplesWriter(t *testing.T) {
+	b := literal.DefaultBuilder()
+	mustNode := func(s string) *node.Node {
+		n, err := node.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	mustLiteral := func(typ literal.Type, v interface{}) *Object {
+		l, err := b.Build(typ, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewLiteralObject(l)
+	}
+	p, err := predicate.NewImmutable("knows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := []struct {
+		o    *Object
+		want string
+	}{
+		{NewNodeObject(mustNode("/person<mary jane>")), `<http://example.org/people/mary\u0020jane>`},
+		{NewNodeObject(mustNode("/example<city>")), `<http://example.org/city>`},
+		{NewNodeObject(mustNode("/iri<http://other.org/x>")), `<http://other.org/x>`},
+		{NewNodeObject(mustNode("/_<b1>")), `_:b1`},
+		{NewNodeObject(mustNode("/_<a+b=>")), `_:x612b623d`},
+		{mustLiteral(literal.Text, "say \"hi\"\n"), `"say \"hi\"\n"`},
+		{mustLiteral(literal.Int64, int64(-3)), `"-3"^^<http://www.w3.org/2001/XMLSchema#long>`},
+		{mustLiteral(literal.Float64, 1.5), `"1.5"^^<http://www.w3.org/2001/XMLSchema#double>`},
+		{mustLiteral(literal.Bool, true), `"true"^^<http://www.w3.org/2001/XMLSchema#boolean>`},
+		{mustLiteral(literal.Blob, []byte("hi")), `"aGk="^^<http://www.w3.org/2001/XMLSchema#base64Binary>`},
+		{mustLiteral(literal.Duration, -90*time.Second), `"-PT90S"^^<http://www.w3.org/2001/XMLSchema#duration>`},
+		{mustLiteral(literal.Float32, float32(0.25)), `"0.25"^^<http://www.w3.org/2001/XMLSchema#float>`},
+		{mustLiteral(literal.Decimal, big.NewRat(-105, 10)), `"-10.5"^^<http://www.w3.org/2001/XMLSchema#decimal>`},
+		{mustLiteral(literal.Date, time.Date(2015, time.July, 19, 0, 0, 0, 0, time.UTC)), `"2015-07-19"^^<http://www.w3.org/2001/XMLSchema#date>`},
+	}
+	s := mustNode("/person<joe>")
+	for _, entry := range table {
+		tr, err := New(s, p, entry.o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewNTriplesWriter(&buf, testNTriplesMapping()).Write(tr); err != nil {
+			t.Errorf("NTriplesWriter.Write(%v) failed with error %v", tr, err)
+			continue
+		}
+		want := "<http://example.org/people/joe> <http://example.org/rel/knows> " + entry.want + " .\n"
+		if got := buf.String(); got != want {
+			t.Errorf("NTriplesWriter.Write(%v) returned the wrong statement; got %q, want %q", tr, got, want)
+		}
+		back, err := ParseNTriple(buf.String(), testNTriplesMapping(), b)
+		if err != nil {
+			t.Errorf("triple.ParseNTriple(%q) failed with error %v", buf.String(), err)
+			continue
+		}
+		if entry.want != `_:x612b623d` && back.String() != tr.String() {
+			t.Errorf("N-Triples round trip failed; got %v, want %v", back, tr)
+		}
+	}
+}
+
+func TestNTriplesWriterQuads(t *testing.T) {
+	tr, err := ParseTriple("/iri<http://a.org/s>\t\"http://a.org/p\"@[]\t/iri<http://a.org/o>", literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := NewNTriplesWriter(&buf, nil)
+	if err := w.WriteQuad(tr, "http://a.org/g"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteQuad(tr, "_:g"); err != nil {
+		t.Fatal(err)
+	}
+	want := "<http://a.org/s> <http://a.org/p> <http://a.org/o> <http://a.org/g> .\n" +
+		"<http://a.org/s> <http://a.org/p> <http://a.org/o> _:g .\n"
+	if got := buf.String(); got != want {
+		t.Errorf("NTriplesWriter.WriteQuad returned the wrong statements; got %q, want %q", got, want)
+	}
+}
+
+func TestNTriplesWriterErrors(t *testing.T) {
+	b := literal.DefaultBuilder()
+	table := []string{
+		"/user<joe>\t\"knows\"@[]\t/iri<http://a.org/o>",
+		"/iri<http://a.org/s>\t\"knows\"@[2015-01-01T00:00:00Z]\t/iri<http://a.org/o>",
+		"/iri<http://a.org/s>\t\"knows\"@[]\t\"knows\"@[]",
+		"/iri<http://a.org/s>\t\"knows\"@[]\t<</iri<a> \"p\"@[] /iri<b>>>",
+	}
+	for _, line := range table {
+		tr, err := ParseTriple(line, b)
+		if err != nil {
+			t.Fatalf("triple.ParseTriple(%q) failed with error %v", line, err)
+		}
+		var buf bytes.Buffer
+		if err := NewNTriplesWriter(&buf, nil).Write(tr); err == nil {
+			t.Errorf("NTriplesWriter.Write(%v) should have failed; wrote %q", tr, strings.TrimSpace(buf.String()))
+		}
+	}
+}
@@ -17,6 +17,7 @@ package predicate
 
 import (
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"strings"
 	"time"
@@ -45,6 +46,165 @@ func (t Type) String() string {
 	}
 }
 
+// Granularity describes the precision of the time anchor of a temporal
+// predicate. Reduced-precision anchors stand for a whole calendar period in UTC
+// instead of a single instant.
+type Granularity uint8
+
+const (
+	// Instant anchors are full precision time anchors.
+	Instant Granularity = iota
+	// Year anchors cover a whole year (e.g. 2015).
+	Year
+	// Month anchors cover a whole month (e.g. 2015-07).
+	Month
+	// Day anchors cover a whole day (e.g. 2015-07-19).
+	Day
+	// Hour anchors cover a whole hour (e.g. 2015-07-19T13).
+	Hour
+	// Minute anchors cover a whole minute (e.g. 2015-07-19T13:12).
+	Minute
+	// Second anchors cover a whole second (e.g. 2015-07-19T13:12:04).
+	Second
+)
+
+// anchorLayouts contains the layouts used to print and parse reduced-precision
+// time anchors.
+var anchorLayouts = map[Granularity]string{
+	Year:   "2006",
+	Month:  "2006-01",
+	Day:    "2006-01-02",
+	Hour:   "2006-01-02T15",
+	Minute: "2006-01-02T15:04",
+	Second: "2006-01-02T15:04:05",
+}
+
+// String returns a pretty printed granularity.
+func (g Granularity) String() string {
+	switch g {
+	case Instant:
+		return "INSTANT"
+	case Year:
+		return "YEAR"
+	case Month:
+		return "MONTH"
+	case Day:
+		return "DAY"
+	case Hour:
+		return "HOUR"
+	case Minute:
+		return "MINUTE"
+	case Second:
+		return "SECOND"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Truncate returns the beginning of the period of the given granularity that
+// contains the provided time. Instant granularity returns the time untouched.
+func (g Granularity) Truncate(t time.Time) time.Time {
+	if g == Instant {
+		return t
+	}
+	t = t.UTC()
+	switch g {
+	case Year:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case Day:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Hour:
+		return t.Truncate(time.Hour)
+	case Minute:
+		return t.Truncate(time.Minute)
+	default:
+		return t.Truncate(time.Second)
+	}
+}
+
+// Last returns the last instant of the period of the given granularity that
+// starts at the provided time.
+func (g Granularity) Last(t time.Time) time.Time {
+	var next time.Time
+	switch g {
+	case Instant:
+		return t
+	case Year:
+		next = t.AddDate(1, 0, 0)
+	case Month:
+		next = t.AddDate(0, 1, 0)
+	case Day:
+		next = t.AddDate(0, 0, 1)
+	case Hour:
+		next = t.Add(time.Hour)
+	case Minute:
+		next = t.Add(time.Minute)
+	default:
+		next = t.Add(time.Second)
+	}
+	return next.Add(-time.Nanosecond)
+}
+
+// ParseGranularity returns the granularity with the provided case insensitive
+// name (e.g. day, or HOUR).
+func ParseGranularity(s string) (Granularity, error) {
+	for g := Instant; g <= Second; g++ {
+		if strings.EqualFold(s, g.String()) {
+			return g, nil
+		}
+	}
+	return Instant, fmt.Errorf("predicate.ParseGranularity failed to parse unknown granularity %q", s)
+}
+
+// Widen returns the provided bounds extended to the whole periods of the given
+// granularity that contain them. Nil bounds are left untouched, and Instant
+// granularity returns the bounds unchanged.
+func (g Granularity) Widen(lower, upper *time.Time) (*time.Time, *time.Time) {
+	if g == Instant {
+		return lower, upper
+	}
+	if lower != nil {
+		l := g.Truncate(*lower)
+		lower = &l
+	}
+	if upper != nil {
+		u := g.Last(g.Truncate(*upper))
+		upper = &u
+	}
+	return lower, upper
+}
+
+// ParseTimeAnchor parses a time anchor and returns its granularity. Full
+// precision anchors are expressed in RFC3339Nano. Reduced-precision anchors
+// (e.g. 2015, 2015-07, 2015-07-19, 2015-07-19T13, 2015-07-19T13:12, or
+// 2015-07-19T13:12:04) are interpreted in UTC and returned truncated to the
+// beginning of the period they cover. Anchors with fractional seconds and no
+// time zone are full precision anchors in UTC.
+func ParseTimeAnchor(s string) (time.Time, Granularity, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, Instant, nil
+	}
+	for g := Year; g <= Second; g++ {
+		l := anchorLayouts[g]
+		if len(s) != len(l) {
+			continue
+		}
+		t, err := time.Parse(l, s)
+		if err != nil {
+			return time.Time{}, Instant, fmt.Errorf("predicate.ParseTimeAnchor failed to parse %s time anchor %q with error %v", g, s, err)
+		}
+		return t, g, nil
+	}
+	if strings.Contains(s, ".") {
+		if t, err := time.Parse("2006-01-02T15:04:05.999999999", s); err == nil {
+			return t, Instant, nil
+		}
+	}
+	return time.Time{}, Instant, fmt.Errorf("predicate.ParseTimeAnchor failed to parse time anchor %q", s)
+}
+
 // ID represents a predicate ID.
 type ID string
 
@@ -55,8 +215,21 @@ func (i *ID) String() string {
 
 // Predicate represents a BadWolf predicate.
 type Predicate struct {
-	id     ID
-	anchor *time.Time
+	id          ID
+	anchor      *time.Time
+	end         *time.Time
+	granularity Granularity
+}
+
+// formatAnchor returns the string form of the time anchor of the predicate.
+func (p *Predicate) formatAnchor() string {
+	if l, ok := anchorLayouts[p.granularity]; ok {
+		return p.anchor.UTC().Format(l)
+	}
+	if p.end != nil {
+		return p.anchor.Format(time.RFC3339Nano) + "," + p.end.Format(time.RFC3339Nano)
+	}
+	return p.anchor.Format(time.RFC3339Nano)
 }
 
 // String returns the pretty printed version of the predicate.
@@ -64,10 +237,13 @@ func (p *Predicate) String() string {
 	if p.anchor == nil {
 		return fmt.Sprintf("%q@[]", p.id)
 	}
-	return fmt.Sprintf("%q@[%s]", p.id, p.anchor.Format(time.RFC3339Nano))
+	return fmt.Sprintf("%q@[%s]", p.id, p.formatAnchor())
 }
 
-// Parse converts a pretty printed predicate into a predicate.
+// Parse converts a pretty printed predicate into a predicate. Time anchors may
+// be reduced-precision anchors as accepted by ParseTimeAnchor. Two comma
+// separated anchors define the [start, end) validity interval of the
+// predicate; reduced-precision ones stand for the beginning of their period.
 func Parse(s string) (*Predicate, error) {
 	raw := strings.TrimSpace(s)
 	if raw == "" {
@@ -86,19 +262,36 @@ func Parse(s string) (*Predicate, error) {
 			id: ID(id),
 		}, nil
 	}
+	if cmps := strings.Split(ta, ","); len(cmps) == 2 {
+		var bs []time.Time
+		for _, c := range cmps {
+			c = strings.Trim(strings.TrimSpace(c), `"`)
+			t, _, err := ParseTimeAnchor(c)
+			if err != nil {
+				return nil, fmt.Errorf("predicate.Parse failed to parse time interval %s in %s with error %v", ta, raw, err)
+			}
+			bs = append(bs, t)
+		}
+		p, err := NewInterval(id, bs[0], bs[1])
+		if err != nil {
+			return nil, fmt.Errorf("predicate.Parse failed to parse time interval %s in %s with error %v", ta, raw, err)
+		}
+		return p, nil
+	}
 	if ta[0] == '"' {
 		ta = ta[1:]
 	}
 	if ta[len(ta)-1] == '"' {
 		ta = ta[:len(ta)-1]
 	}
-	pta, err := time.Parse(time.RFC3339Nano, ta)
+	pta, g, err := ParseTimeAnchor(ta)
 	if err != nil {
 		return nil, fmt.Errorf("predicate.Parse failed to parse time anchor %s in %s with error %v", ta, raw, err)
 	}
 	return &Predicate{
-		id:     ID(id),
-		anchor: &pta,
+		id:          ID(id),
+		anchor:      &pta,
+		granularity: g,
 	}, nil
 }
 
@@ -116,7 +309,8 @@ func (p *Predicate) Type() Type {
 }
 
 // TimeAnchor attempts to return the time anchor of a predicate if its type is
-// temporal.
+// temporal. The time anchor of an interval predicate is the start of its
+// validity interval.
 func (p *Predicate) TimeAnchor() (*time.Time, error) {
 	if p.anchor == nil {
 		return nil, fmt.Errorf("predicate.TimeAnchor cannot return anchor for immutable predicate %v", p)
@@ -124,6 +318,60 @@ func (p *Predicate) TimeAnchor() (*time.Time, error) {
 	return p.anchor, nil
 }
 
+// Granularity returns the granularity of the time anchor of the predicate.
+// Immutable predicates always return Instant.
+func (p *Predicate) Granularity() Granularity {
+	return p.granularity
+}
+
+// TimeSpan attempts to return the first and last instants covered by the time
+// anchor of a predicate if its type is temporal. Both are the same for full
+// precision anchors, while interval predicates cover their whole validity
+// interval.
+func (p *Predicate) TimeSpan() (time.Time, time.Time, error) {
+	if p.anchor == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("predicate.TimeSpan cannot return time span for immutable predicate %v", p)
+	}
+	if p.end != nil {
+		return *p.anchor, p.end.Add(-time.Nanosecond), nil
+	}
+	return *p.anchor, p.granularity.Last(*p.anchor), nil
+}
+
+// Interval returns the start and the exclusive end of the validity interval of
+// the predicate, and false if the predicate does not carry one.
+func (p *Predicate) Interval() (time.Time, time.Time, bool) {
+	if p.end == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return *p.anchor, *p.end, true
+}
+
+// ValidAt returns true if the predicate holds at the provided instant.
+// Immutable predicates are always valid, instant anchors are only valid at
+// their exact instant, and reduced-precision anchors and validity intervals
+// are valid at any instant they cover.
+func (p *Predicate) ValidAt(t time.Time) bool {
+	return p.WithinBounds(&t, &t)
+}
+
+// WithinBounds returns true if the time anchor of the predicate overlaps the
+// provided inclusive bounds. Nil bounds are not enforced, and immutable
+// predicates are always within bounds.
+func (p *Predicate) WithinBounds(lower, upper *time.Time) bool {
+	if p.anchor == nil {
+		return true
+	}
+	first, last, _ := p.TimeSpan()
+	if lower != nil && last.Before(*lower) {
+		return false
+	}
+	if upper != nil && first.After(*upper) {
+		return false
+	}
+	return true
+}
+
 // NewImmutable creates a new immutable predicate.
 func NewImmutable(id string) (*Predicate, error) {
 	if id == "" {
@@ -145,8 +393,212 @@ func NewTemporal(id string, t time.Time) (*Predicate, error) {
 	}, nil
 }
 
+// NewInterval creates a new temporal predicate valid during the [start, end)
+// time interval.
+func NewInterval(id string, start, end time.Time) (*Predicate, error) {
+	if id == "" {
+		return nil, fmt.Errorf("predicate.NewInterval(%q, %v, %v) cannot create an interval predicate with empty ID", id, start, end)
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("predicate.NewInterval(%q, %v, %v) requires the start of the interval to be before its end", id, start, end)
+	}
+	return &Predicate{
+		id:     ID(id),
+		anchor: &start,
+		end:    &end,
+	}, nil
+}
+
+// NewTemporalWithGranularity creates a new temporal predicate whose time anchor
+// has the provided granularity. The anchor is set to the beginning of the
+// period that contains the provided time.
+func NewTemporalWithGranularity(id string, t time.Time, g Granularity) (*Predicate, error) {
+	if g > Second {
+		return nil, fmt.Errorf("predicate.NewTemporalWithGranularity(%q, %v, %v) unknown granularity", id, t, g)
+	}
+	p, err := NewTemporal(id, g.Truncate(t))
+	if err != nil {
+		return nil, err
+	}
+	p.granularity = g
+	return p, nil
+}
+
+// Coarsen returns the predicate with its time anchor reduced to the provided
+// granularity, so predicates anchored within the same period become equal.
+// Immutable predicates, validity intervals, and anchors that are already as
+// coarse as the granularity are returned untouched.
+func (p *Predicate) Coarsen(g Granularity) *Predicate {
+	if g == Instant || p.anchor == nil || p.end != nil {
+		return p
+	}
+	if p.granularity != Instant && p.granularity <= g {
+		return p
+	}
+	np, err := NewTemporalWithGranularity(string(p.id), *p.anchor, g)
+	if err != nil {
+		return p
+	}
+	return np
+}
+
+// CanonicalString returns the pretty printed version of the predicate with its
+// time anchor expressed in UTC. Predicates anchored at the same instant share
+// the same canonical string regardless of the time zone of their anchors.
+// Reduced-precision anchors keep their reduced form, so they never collide
+// with full precision ones.
+func (p *Predicate) CanonicalString() string {
+	return p.UTC().String()
+}
+
+// UTC returns the predicate with its time anchors expressed in UTC. Immutable
+// predicates are returned untouched.
+func (p *Predicate) UTC() *Predicate {
+	if p.anchor == nil {
+		return p
+	}
+	np := *p
+	ta := p.anchor.UTC()
+	np.anchor = &ta
+	if p.end != nil {
+		te := p.end.UTC()
+		np.end = &te
+	}
+	return &np
+}
+
+// Compare returns -1, 0, or 1 if the first predicate sorts before, together
+// with, or after the second one. Predicates are sorted by ID, and predicates
+// sharing it are sorted with immutable ones first followed by the temporal ones
+// in chronological order of their time anchors. Anchors starting at the same
+// instant sort the narrower ones first, and time zones are ignored.
+func Compare(a, b *Predicate) int {
+	if cmp := strings.Compare(string(a.id), string(b.id)); cmp != 0 {
+		return cmp
+	}
+	fa, la, erra := a.TimeSpan()
+	fb, lb, errb := b.TimeSpan()
+	switch {
+	case erra != nil && errb != nil:
+		return 0
+	case erra != nil:
+		// Immutable predicates have no time anchor.
+		return -1
+	case errb != nil:
+		return 1
+	}
+	if cmp := compareTimes(fa, fb); cmp != 0 {
+		return cmp
+	}
+	return compareTimes(la, lb)
+}
+
+// compareTimes returns -1, 0, or 1 if the first time is before, equal to, or
+// after the second one.
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
 // GUID returns a global unique identifier for the given predicate. It is
-// implemented as the base64 encoded stringified version of the preducate.
+// implemented as the base64 encoded canonical string of the predicate.
+// Hence, predicates anchored at the same instant on different time zones share
+// the same GUID.
 func (p *Predicate) GUID() string {
-	return base64.StdEncoding.EncodeToString([]byte(p.String()))
+	return base64.StdEncoding.EncodeToString([]byte(p.CanonicalString()))
+}
+
+// MarshalBinary returns the binary encoding of the predicate. It contains the
+// length of the ID as an unsigned varint, followed by the ID and, for temporal
+// predicates, the binary encoding of the time anchor. Reduced-precision anchors
+// are followed by a trailing byte with their granularity, and interval
+// predicates by the encoding of the end of the interval, its length, and the
+// intervalMarker byte.
+func (p *Predicate) MarshalBinary() ([]byte, error) {
+	b := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(p.id))
+	b = append(b[:binary.PutUvarint(b, uint64(len(p.id)))], p.id...)
+	if p.anchor == nil {
+		return b, nil
+	}
+	ta, err := p.anchor.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, ta...)
+	if p.end != nil {
+		te, err := p.end.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return append(append(b, te...), byte(len(te)), intervalMarker), nil
+	}
+	if p.granularity != Instant {
+		b = append(b, byte(p.granularity))
+	}
+	return b, nil
+}
+
+// intervalMarker is the trailing byte of binary encoded interval predicates.
+const intervalMarker = 0xff
+
+// UnmarshalBinary sets the predicate to the one encoded in the provided data
+// by MarshalBinary.
+func (p *Predicate) UnmarshalBinary(data []byte) error {
+	l, k := binary.Uvarint(data)
+	if k <= 0 || uint64(len(data)-k) < l {
+		return fmt.Errorf("predicate.UnmarshalBinary: invalid encoded predicate %q", data)
+	}
+	id, rest := string(data[k:k+int(l)]), data[k+int(l):]
+	if len(rest) == 0 {
+		np, err := NewImmutable(id)
+		if err != nil {
+			return err
+		}
+		*p = *np
+		return nil
+	}
+	var ta time.Time
+	g := Instant
+	if err := ta.UnmarshalBinary(rest); err != nil {
+		// Reduced-precision anchors carry a trailing granularity byte.
+		n := len(rest) - 1
+		if n == 0 || ta.UnmarshalBinary(rest[:n]) != nil || Granularity(rest[n]) == Instant {
+			return p.unmarshalInterval(id, rest)
+		}
+		g = Granularity(rest[n])
+	}
+	np, err := NewTemporalWithGranularity(id, ta, g)
+	if err != nil {
+		return err
+	}
+	*p = *np
+	return nil
+}
+
+// unmarshalInterval sets the predicate to the interval predicate with the
+// provided ID whose encoded interval is provided.
+func (p *Predicate) unmarshalInterval(id string, data []byte) error {
+	n := len(data)
+	if n <= 2 || data[n-1] != intervalMarker || int(data[n-2]) > n-2 {
+		return fmt.Errorf("predicate.UnmarshalBinary: invalid time anchor for predicate %q", id)
+	}
+	var start, end time.Time
+	m := n - 2 - int(data[n-2])
+	if err := start.UnmarshalBinary(data[:m]); err != nil {
+		return fmt.Errorf("predicate.UnmarshalBinary: invalid interval start for predicate %q; %v", id, err)
+	}
+	if err := end.UnmarshalBinary(data[m : n-2]); err != nil {
+		return fmt.Errorf("predicate.UnmarshalBinary: invalid interval end for predicate %q; %v", id, err)
+	}
+	np, err := NewInterval(id, start, end)
+	if err != nil {
+		return err
+	}
+	*p = *np
+	return nil
 }
@@ -17,6 +17,7 @@ package predicate
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -127,3 +128,364 @@ func TestParse(t *testing.T) {
 		t.Errorf("predicate.Parse failed to immutable predicate \"foo\"@[]; got %v instead", imm)
 	}
 }
+
+func TestBinaryEncoding(t *testing.T) {
+	ta := time.Date(2015, 4, 10, 4, 21, 0, 123, time.FixedZone("PDT", -7*3600))
+	tp, err := NewTemporal("bar", ta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*Predicate{immutFoo, tp} {
+		b, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatalf("predicate.MarshalBinary(%v) failed with error %v", p, err)
+		}
+		got := &Predicate{}
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("predicate.UnmarshalBinary(%q) failed with error %v", b, err)
+		}
+		if got.String() != p.String() || got.Type() != p.Type() {
+			t.Errorf("predicate.UnmarshalBinary returned the wrong predicate; got %v, want %v", got, p)
+		}
+	}
+	for _, b := range [][]byte{nil, {0}, {10, 'f'}, {3, 'f', 'o', 'o', 1, 2}} {
+		if err := (&Predicate{}).UnmarshalBinary(b); err == nil {
+			t.Errorf("predicate.UnmarshalBinary(%q) should have failed", b)
+		}
+	}
+}
+
+func TestCanonicalString(t *testing.T) {
+	utc := time.Date(2015, 4, 10, 11, 21, 0, 0, time.UTC)
+	p1, err := NewTemporal("bar", utc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := NewTemporal("bar", utc.In(time.FixedZone("PDT", -7*3600)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1.String() == p2.String() {
+		t.Fatalf("predicates anchored on different time zones should print differently; got %v and %v", p1, p2)
+	}
+	if got, want := p2.CanonicalString(), `"bar"@[2015-04-10T11:21:00Z]`; got != want {
+		t.Errorf("predicate.CanonicalString returned the wrong string; got %q, want %q", got, want)
+	}
+	if p1.GUID() != p2.GUID() {
+		t.Errorf("predicates anchored on the same instant should share the same GUID; got %q and %q", p1.GUID(), p2.GUID())
+	}
+	if got, want := immutFoo.CanonicalString(), immutFoo.String(); got != want {
+		t.Errorf("predicate.CanonicalString returned the wrong string; got %q, want %q", got, want)
+	}
+}
+
+func TestReducedPrecisionAnchors(t *testing.T) {
+	table := []struct {
+		anchor string
+		g      Granularity
+		first  time.Time
+		last   time.Time
+	}{
+		{"2015", Year, time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2015, 12, 31, 23, 59, 59, 999999999, time.UTC)},
+		{"2015-07", Month, time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2015, 7, 31, 23, 59, 59, 999999999, time.UTC)},
+		{"2015-07-19", Day, time.Date(2015, 7, 19, 0, 0, 0, 0, time.UTC), time.Date(2015, 7, 19, 23, 59, 59, 999999999, time.UTC)},
+		{"2015-07-19T13", Hour, time.Date(2015, 7, 19, 13, 0, 0, 0, time.UTC), time.Date(2015, 7, 19, 13, 59, 59, 999999999, time.UTC)},
+		{"2015-07-19T13:12", Minute, time.Date(2015, 7, 19, 13, 12, 0, 0, time.UTC), time.Date(2015, 7, 19, 13, 12, 59, 999999999, time.UTC)},
+		{"2015-07-19T13:12:04", Second, time.Date(2015, 7, 19, 13, 12, 4, 0, time.UTC), time.Date(2015, 7, 19, 13, 12, 4, 999999999, time.UTC)},
+		{"2015-07-19T13:12:04.669618843", Instant, time.Date(2015, 7, 19, 13, 12, 4, 669618843, time.UTC), time.Date(2015, 7, 19, 13, 12, 4, 669618843, time.UTC)},
+	}
+	for _, entry := range table {
+		p, err := Parse(fmt.Sprintf("\"foo\"@[%s]", entry.anchor))
+		if err != nil {
+			t.Errorf("predicate.Parse failed to parse anchor %q with error %v", entry.anchor, err)
+			continue
+		}
+		if got, want := p.Granularity(), entry.g; got != want {
+			t.Errorf("predicate.Granularity for %q returned %v; want %v", entry.anchor, got, want)
+		}
+		first, last, err := p.TimeSpan()
+		if err != nil {
+			t.Errorf("predicate.TimeSpan for %v failed with error %v", p, err)
+		}
+		if !first.Equal(entry.first) || !last.Equal(entry.last) {
+			t.Errorf("predicate.TimeSpan for %v returned [%v, %v]; want [%v, %v]", p, first, last, entry.first, entry.last)
+		}
+		if entry.g == Instant {
+			continue
+		}
+		if got, want := p.String(), fmt.Sprintf("\"foo\"@[%s]", entry.anchor); got != want {
+			t.Errorf("predicate.String returned %q; want %q", got, want)
+		}
+		b, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatalf("predicate.MarshalBinary(%v) failed with error %v", p, err)
+		}
+		got := &Predicate{}
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("predicate.UnmarshalBinary(%q) failed with error %v", b, err)
+		}
+		if got.String() != p.String() || got.Granularity() != p.Granularity() {
+			t.Errorf("predicate.UnmarshalBinary returned the wrong predicate; got %v, want %v", got, p)
+		}
+	}
+	for _, bad := range []string{"15", "2015-7", "2015-13", "2015-07-19T25", "July 2015"} {
+		if _, _, err := ParseTimeAnchor(bad); err == nil {
+			t.Errorf("predicate.ParseTimeAnchor should have failed to parse %q", bad)
+		}
+	}
+}
+
+func TestNewTemporalWithGranularity(t *testing.T) {
+	ta := time.Date(2015, 7, 19, 23, 30, 0, 0, time.FixedZone("PDT", -7*3600))
+	p, err := NewTemporalWithGranularity("foo", ta, Day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.String(), `"foo"@[2015-07-20]`; got != want {
+		t.Errorf("predicate.NewTemporalWithGranularity returned %v; want %v", got, want)
+	}
+	full, err := NewTemporal("foo", time.Date(2015, 7, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GUID() == full.GUID() {
+		t.Errorf("reduced-precision and full precision anchors should not share GUID %q", p.GUID())
+	}
+	if _, err := NewTemporalWithGranularity("foo", ta, Second+1); err == nil {
+		t.Errorf("predicate.NewTemporalWithGranularity should have failed for an unknown granularity")
+	}
+}
+
+func TestParseGranularity(t *testing.T) {
+	for g := Instant; g <= Second; g++ {
+		for _, s := range []string{g.String(), strings.ToLower(g.String())} {
+			got, err := ParseGranularity(s)
+			if err != nil {
+				t.Errorf("predicate.ParseGranularity(%q) failed with error %v", s, err)
+				continue
+			}
+			if got != g {
+				t.Errorf("predicate.ParseGranularity(%q) returned %v; want %v", s, got, g)
+			}
+		}
+	}
+	for _, bad := range []string{"", "UNKNOWN", "days", "week"} {
+		if _, err := ParseGranularity(bad); err == nil {
+			t.Errorf("predicate.ParseGranularity should have failed to parse %q", bad)
+		}
+	}
+}
+
+func TestGranularityWiden(t *testing.T) {
+	lower := time.Date(2015, 7, 19, 13, 12, 4, 5, time.UTC)
+	upper := time.Date(2015, 7, 21, 1, 0, 0, 0, time.UTC)
+	l, u := Day.Widen(&lower, &upper)
+	if want := time.Date(2015, 7, 19, 0, 0, 0, 0, time.UTC); !l.Equal(want) {
+		t.Errorf("predicate.Day.Widen returned lower bound %v; want %v", l, want)
+	}
+	if want := time.Date(2015, 7, 22, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond); !u.Equal(want) {
+		t.Errorf("predicate.Day.Widen returned upper bound %v; want %v", u, want)
+	}
+	if l, u := Instant.Widen(&lower, &upper); l != &lower || u != &upper {
+		t.Errorf("predicate.Instant.Widen should have returned the bounds unchanged; got %v, %v", l, u)
+	}
+	if l, u := Hour.Widen(nil, nil); l != nil || u != nil {
+		t.Errorf("predicate.Hour.Widen should have kept nil bounds; got %v, %v", l, u)
+	}
+}
+
+func TestCoarsen(t *testing.T) {
+	morning, err := NewTemporal("foo", time.Date(2015, 7, 19, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	evening, err := NewTemporal("foo", time.Date(2015, 7, 19, 21, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if morning.Coarsen(Day).GUID() != evening.Coarsen(Day).GUID() {
+		t.Errorf("predicate.Coarsen(Day) should have made %v and %v equal", morning, evening)
+	}
+	if morning.Coarsen(Hour).GUID() == evening.Coarsen(Hour).GUID() {
+		t.Errorf("predicate.Coarsen(Hour) should have kept %v and %v apart", morning, evening)
+	}
+	if got, want := morning.Coarsen(Month).String(), `"foo"@[2015-07]`; got != want {
+		t.Errorf("predicate.Coarsen(Month) returned %v; want %v", got, want)
+	}
+	year, err := Parse(`"foo"@[2015]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interval, err := NewInterval("foo", time.Date(2015, 1, 1, 9, 0, 0, 0, time.UTC), time.Date(2016, 1, 1, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*Predicate{immutFoo, year, interval, morning} {
+		g := Day
+		if p == morning {
+			g = Instant
+		}
+		if got := p.Coarsen(g); got != p {
+			t.Errorf("predicate.Coarsen(%v) should have returned %v untouched; got %v", g, p, got)
+		}
+	}
+}
+
+func TestWithinBounds(t *testing.T) {
+	month, err := Parse(`"foo"@[2015-07]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instant, err := NewTemporal("foo", time.Date(2015, 7, 19, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(y int, m time.Month, d int) *time.Time {
+		t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	table := []struct {
+		p            *Predicate
+		lower, upper *time.Time
+		want         bool
+	}{
+		{immutFoo, at(2015, 1, 1), at(2015, 1, 2), true},
+		{month, nil, nil, true},
+		{month, at(2015, 7, 31), nil, true},
+		{month, at(2015, 8, 1), nil, false},
+		{month, nil, at(2015, 7, 1), true},
+		{month, nil, at(2015, 6, 30), false},
+		{month, at(2015, 7, 10), at(2015, 7, 12), true},
+		{instant, at(2015, 7, 19), at(2015, 7, 19), true},
+		{instant, at(2015, 7, 20), nil, false},
+		{instant, nil, at(2015, 7, 18), false},
+	}
+	for _, entry := range table {
+		if got := entry.p.WithinBounds(entry.lower, entry.upper); got != entry.want {
+			t.Errorf("predicate.WithinBounds(%v, %v) for %v returned %v; want %v", entry.lower, entry.upper, entry.p, got, entry.want)
+		}
+	}
+}
+
+func TestIntervals(t *testing.T) {
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2016, 6, 1, 0, 0, 0, 0, time.UTC)
+	p, err := NewInterval("works_at", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.String(), `"works_at"@[2015-01-01T00:00:00Z,2016-06-01T00:00:00Z]`; got != want {
+		t.Errorf("predicate.String returned %q; want %q", got, want)
+	}
+	if p.Type() != Temporal {
+		t.Errorf("interval predicates should be temporal; got %v", p.Type())
+	}
+	if s, e, ok := p.Interval(); !ok || !s.Equal(start) || !e.Equal(end) {
+		t.Errorf("predicate.Interval returned (%v, %v, %v); want (%v, %v, true)", s, e, ok, start, end)
+	}
+	if _, _, ok := immutFoo.Interval(); ok {
+		t.Errorf("predicate.Interval should return false for %v", immutFoo)
+	}
+	for _, in := range []string{p.String(), `"works_at"@[2015, 2016-06]`, `"works_at"@["2015","2016-06"]`} {
+		got, err := Parse(in)
+		if err != nil {
+			t.Errorf("predicate.Parse failed to parse %q with error %v", in, err)
+			continue
+		}
+		if got.GUID() != p.GUID() {
+			t.Errorf("predicate.Parse(%q) returned %v; want %v", in, got, p)
+		}
+	}
+	for _, bad := range []string{`"works_at"@[2016,2015]`, `"works_at"@[2015,2015]`, `"works_at"@[2015,foo]`, `"works_at"@[,2015]`} {
+		if got, err := Parse(bad); err == nil {
+			t.Errorf("predicate.Parse(%q) should have failed; got %v", bad, got)
+		}
+	}
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatalf("predicate.MarshalBinary(%v) failed with error %v", p, err)
+	}
+	got := &Predicate{}
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("predicate.UnmarshalBinary(%q) failed with error %v", b, err)
+	}
+	if got.String() != p.String() {
+		t.Errorf("predicate.UnmarshalBinary returned the wrong predicate; got %v, want %v", got, p)
+	}
+	if err := (&Predicate{}).UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Errorf("predicate.UnmarshalBinary should have failed for truncated interval %q", b)
+	}
+	table := []struct {
+		t    time.Time
+		want bool
+	}{
+		{start.Add(-time.Nanosecond), false},
+		{start, true},
+		{time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{end.Add(-time.Nanosecond), true},
+		{end, false},
+	}
+	for _, entry := range table {
+		if got := p.ValidAt(entry.t); got != entry.want {
+			t.Errorf("predicate.ValidAt(%v) for %v returned %v; want %v", entry.t, p, got, entry.want)
+		}
+	}
+	if _, err := NewInterval("works_at", end, start); err == nil {
+		t.Errorf("predicate.NewInterval should have failed for an interval ending before its start")
+	}
+	if _, err := NewInterval("", start, end); err == nil {
+		t.Errorf("predicate.NewInterval should have failed for an empty ID")
+	}
+}
+
+func TestUTCAndCompare(t *testing.T) {
+	pdt := time.FixedZone("PDT", -7*3600)
+	ta := time.Date(2015, 4, 10, 4, 21, 0, 0, pdt)
+	local, err := NewTemporal("bar", ta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := local.UTC()
+	if got, want := utc.String(), `"bar"@[2015-04-10T11:21:00Z]`; got != want {
+		t.Errorf("predicate.UTC returned %q; want %q", got, want)
+	}
+	if local.String() == utc.String() {
+		t.Errorf("predicate.UTC should not modify the original predicate %v", local)
+	}
+	if immutFoo.UTC() != immutFoo {
+		t.Errorf("predicate.UTC should return immutable predicates untouched")
+	}
+	iv, err := NewInterval("bar", ta, ta.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := iv.UTC().String(), `"bar"@[2015-04-10T11:21:00Z,2015-04-10T12:21:00Z]`; got != want {
+		t.Errorf("predicate.UTC returned %q; want %q", got, want)
+	}
+	mustParse := func(s string) *Predicate {
+		p, err := Parse(s)
+		if err != nil {
+			t.Fatalf("predicate.Parse(%q) failed with error %v", s, err)
+		}
+		return p
+	}
+	table := []struct {
+		a, b *Predicate
+		want int
+	}{
+		{immutFoo, immutFoo, 0},
+		{mustParse(`"bar"@[]`), immutFoo, -1},
+		{immutFoo, mustParse(`"foo"@[2015]`), -1},
+		{mustParse(`"foo"@[2015]`), immutFoo, 1},
+		{local, utc, 0},
+		{mustParse(`"foo"@[2015]`), mustParse(`"foo"@[2016]`), -1},
+		{mustParse(`"foo"@[2015-01-01T00:00:00Z]`), mustParse(`"foo"@[2015]`), -1},
+		{mustParse(`"foo"@[2015-01]`), mustParse(`"foo"@[2015]`), -1},
+		{mustParse(`"foo"@[2015,2017]`), mustParse(`"foo"@[2015]`), 1},
+	}
+	for _, entry := range table {
+		if got := Compare(entry.a, entry.b); got != entry.want {
+			t.Errorf("predicate.Compare(%v, %v) returned %d; want %d", entry.a, entry.b, got, entry.want)
+		}
+	}
+}
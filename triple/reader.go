@@ -0,0 +1,106 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triple
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/badwolf/triple/literal"
+)
+
+// ParseError describes a line of text that could not be parsed into a triple.
+type ParseError struct {
+	// Line contains the 1-based number of the line that failed to parse.
+	Line int
+
+	// Column contains the 1-based column, in runes, where the element that
+	// failed to parse starts.
+	Column int
+
+	// Text contains the line that failed to parse.
+	Text string
+
+	// Err contains the reason why the line failed to parse.
+	Err error
+}
+
+// Error returns the description of the parse error.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("triple.Reader failed to parse line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+// Reader reads triples from a text stream. Each line of the stream contains a
+// triple using the standard serialized format; blank lines are ignored.
+type Reader struct {
+	s    *bufio.Scanner
+	b    literal.Builder
+	line int
+	skip bool
+	errs []*ParseError
+}
+
+// NewReader returns a reader that parses the triples found on r using the
+// provided literal builder.
+func NewReader(r io.Reader, b literal.Builder) *Reader {
+	s := bufio.NewScanner(r)
+	s.Split(bufio.ScanLines)
+	return &Reader{s: s, b: b}
+}
+
+// SkipErrors makes Read skip the lines that fail to parse instead of returning
+// an error. The skipped lines can be retrieved using Errors.
+func (r *Reader) SkipErrors(skip bool) {
+	r.skip = skip
+}
+
+// Errors returns the errors found on the lines skipped so far.
+func (r *Reader) Errors() []*ParseError {
+	return r.errs
+}
+
+// Read returns the next triple on the stream. It returns io.EOF once the stream
+// is exhausted. Parse failures are returned as *ParseError, unless the reader
+// skips errors.
+func (r *Reader) Read() (*Triple, error) {
+	for r.s.Scan() {
+		r.line++
+		text := r.s.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		t, off, err := parseTriple(text, r.b)
+		if err == nil {
+			return t, nil
+		}
+		perr := &ParseError{
+			Line:   r.line,
+			Column: utf8.RuneCountInString(text[:off]) + 1,
+			Text:   text,
+			Err:    err,
+		}
+		if !r.skip {
+			return nil, perr
+		}
+		r.errs = append(r.errs, perr)
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
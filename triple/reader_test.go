@@ -0,0 +1,83 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triple
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/badwolf/triple/literal"
+)
+
+const readerTestInput = `/u<joe>	"knows"@[]	/u<mary>
+
+  /u<joe>	"knows"@[]	/u<peter>
+/u<joe> "knows"@[yesterday] /u<mary>
+/u<mary>	"knows"@[]	/u<joe>
+/u<mary>
+`
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader(readerTestInput), literal.DefaultBuilder())
+	for i := 0; i < 2; i++ {
+		if _, err := r.Read(); err != nil {
+			t.Fatalf("Reader.Read failed to read triple %d with error %v", i, err)
+		}
+	}
+	_, err := r.Read()
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Reader.Read should have failed with a *ParseError; got %v", err)
+	}
+	if perr.Line != 4 || perr.Column != 9 {
+		t.Errorf("Reader.Read returned the wrong error position; got line %d, column %d, want line 4, column 9", perr.Line, perr.Column)
+	}
+	if tr, err := r.Read(); err != nil || tr.S().String() != "/u<mary>" {
+		t.Errorf("Reader.Read failed to continue after an error; got %v, %v", tr, err)
+	}
+	if _, err := r.Read(); err == nil {
+		t.Errorf("Reader.Read should have failed to parse the last line")
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Reader.Read should have returned io.EOF; got %v", err)
+	}
+}
+
+func TestReaderSkipErrors(t *testing.T) {
+	r := NewReader(strings.NewReader(readerTestInput), literal.DefaultBuilder())
+	r.SkipErrors(true)
+	cnt := 0
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Reader.Read should skip errors; got %v", err)
+		}
+		cnt++
+	}
+	if got, want := cnt, 3; got != want {
+		t.Errorf("Reader.Read returned the wrong number of triples; got %d, want %d", got, want)
+	}
+	errs := r.Errors()
+	if got, want := len(errs), 2; got != want {
+		t.Fatalf("Reader.Errors returned the wrong number of errors; got %d, want %d", got, want)
+	}
+	if errs[0].Line != 4 || errs[1].Line != 6 || errs[1].Column != 9 {
+		t.Errorf("Reader.Errors returned the wrong positions; got %d:%d and %d:%d", errs[0].Line, errs[0].Column, errs[1].Line, errs[1].Column)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triple
+
+import (
+	"sync"
+
+	"github.com/google/badwolf/triple/node"
+)
+
+// BlankNodeScope maps the blank nodes found while parsing a single document to
+// new blank nodes unique to the scope. Blank node identifiers are only
+// meaningful inside the document they appear in; using one scope per parsed
+// document prevents the blank nodes of different documents from colliding.
+// It is safe for concurrent use.
+type BlankNodeScope struct {
+	mu sync.Mutex
+	m  map[string]*node.Node
+}
+
+// NewBlankNodeScope returns a new empty scope.
+func NewBlankNodeScope() *BlankNodeScope {
+	return &BlankNodeScope{m: make(map[string]*node.Node)}
+}
+
+// Label returns the blank node for the provided label. The same label always
+// returns the same blank node within a scope.
+func (s *BlankNodeScope) Label(l string) *node.Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.m[l]
+	if !ok {
+		n = node.NewBlankNode()
+		s.m[l] = n
+	}
+	return n
+}
+
+// Node returns the scoped blank node for the provided blank node. Other nodes
+// are returned unchanged.
+func (s *BlankNodeScope) Node(n *node.Node) *node.Node {
+	if n.Type().String() != blankNodeType {
+		return n
+	}
+	return s.Label(n.ID().String())
+}
+
+// Triple returns the provided triple with all its blank nodes, including the
+// ones of embedded triples, replaced by their scoped blank nodes.
+func (s *BlankNodeScope) Triple(t *Triple) *Triple {
+	o := t.o
+	switch {
+	case o.n != nil:
+		o = NewNodeObject(s.Node(o.n))
+	case o.t != nil:
+		o = NewTripleObject(s.Triple(o.t))
+	}
+	return &Triple{s: s.Node(t.s), p: t.p, o: o}
+}
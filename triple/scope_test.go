@@ -0,0 +1,76 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triple
+
+import (
+	"testing"
+
+	"github.com/google/badwolf/triple/literal"
+)
+
+func TestBlankNodeScope(t *testing.T) {
+	b := literal.DefaultBuilder()
+	tr, err := ParseTriple("/_<x>\t\"p\"@[]\t<</_<x> \"q\"@[] /_<y>>>", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, s2 := NewBlankNodeScope(), NewBlankNodeScope()
+	t1, t2 := s1.Triple(tr), s2.Triple(tr)
+	et, err := t1.O().Triple()
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := et.O().Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1.S().String() == tr.S().String() {
+		t.Errorf("BlankNodeScope.Triple should have replaced blank node %v", tr.S())
+	}
+	if t1.S().String() != et.S().String() || t1.S().String() == y.String() {
+		t.Errorf("BlankNodeScope.Triple should map equal labels to equal nodes and different labels to different ones; got %v", t1)
+	}
+	if t1.S().String() == t2.S().String() {
+		t.Errorf("different scopes should not share blank nodes; got %v twice", t1.S())
+	}
+	if got, want := s1.Label("x").String(), t1.S().String(); got != want {
+		t.Errorf("BlankNodeScope.Label returned the wrong node; got %v, want %v", got, want)
+	}
+	if t1.P() != tr.P() {
+		t.Errorf("BlankNodeScope.Triple should not change predicates; got %v, want %v", t1.P(), tr.P())
+	}
+	u, err := ParseTriple("/u<a>\t\"p\"@[]\t/u<b>", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s1.Triple(u).String(), u.String(); got != want {
+		t.Errorf("BlankNodeScope.Triple should not change other nodes; got %v, want %v", got, want)
+	}
+}
+
+func TestNTriplesBlankNodeScope(t *testing.T) {
+	m := &NTriplesMapping{BlankNodes: NewBlankNodeScope()}
+	t1, err := ParseNTriple(`_:x <http://a.org/p> _:x .`, m, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := t1.O().Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1.S().String() != o.String() || t1.S().ID().String() == "x" {
+		t.Errorf("triple.ParseNTriple should have mapped the label to a scoped blank node; got %v", t1)
+	}
+}
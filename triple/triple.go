@@ -16,7 +16,9 @@
 package triple
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"regexp"
 	"strings"
@@ -26,11 +28,13 @@ import (
 	"github.com/google/badwolf/triple/predicate"
 )
 
-// Object is the box that either contains a literal or a node.
+// Object is the box that either contains a literal, a node, a predicate, or
+// an embedded triple.
 type Object struct {
 	n *node.Node
 	p *predicate.Predicate
 	l *literal.Literal
+	t *Triple
 }
 
 // String pretty prints the object.
@@ -44,11 +48,27 @@ func (o *Object) String() string {
 	if o.p != nil {
 		return o.p.String()
 	}
+	if o.t != nil {
+		return fmt.Sprintf("<<%s %s %s>>", o.t.s, o.t.p, o.t.o)
+	}
 	return "@@@INVALID_OBJECT@@@"
 }
 
+// CanonicalString returns the pretty printed version of the object with all
+// the time anchors of its predicates expressed in UTC.
+func (o *Object) CanonicalString() string {
+	switch {
+	case o.p != nil:
+		return o.p.CanonicalString()
+	case o.t != nil:
+		return fmt.Sprintf("<<%s %s %s>>", o.t.s, o.t.p.CanonicalString(), o.t.o.CanonicalString())
+	}
+	return o.String()
+}
+
 // GUID returns a global unique identifier for the given object. It is
-// implemented as the base64 encoded stringified version of the node.
+// implemented as the base64 encoded canonical string of the object prefixed by
+// the kind of value it boxes.
 func (o *Object) GUID() string {
 	fo := "@@@INVALID_OBJECT@@@"
 	if o.n != nil {
@@ -60,7 +80,80 @@ func (o *Object) GUID() string {
 	if o.p != nil {
 		fo = "predicate"
 	}
-	return base64.StdEncoding.EncodeToString([]byte(strings.Join([]string{fo, o.String()}, ":")))
+	if o.t != nil {
+		fo = "triple"
+	}
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join([]string{fo, o.CanonicalString()}, ":")))
+}
+
+// Kind identifies the kind of value boxed by an object.
+type Kind uint8
+
+const (
+	// InvalidKind identifies objects that box no value.
+	InvalidKind Kind = iota
+	// NodeKind identifies objects that box a node.
+	NodeKind
+	// PredicateKind identifies objects that box a predicate.
+	PredicateKind
+	// LiteralKind identifies objects that box a literal.
+	LiteralKind
+	// TripleKind identifies objects that box an embedded triple.
+	TripleKind
+)
+
+// String returns the pretty printed version of the kind.
+func (k Kind) String() string {
+	switch k {
+	case NodeKind:
+		return "node"
+	case PredicateKind:
+		return "predicate"
+	case LiteralKind:
+		return "literal"
+	case TripleKind:
+		return "triple"
+	default:
+		return "invalid"
+	}
+}
+
+// Kind returns the kind of value boxed by the object.
+func (o *Object) Kind() Kind {
+	switch {
+	case o.n != nil:
+		return NodeKind
+	case o.p != nil:
+		return PredicateKind
+	case o.l != nil:
+		return LiteralKind
+	case o.t != nil:
+		return TripleKind
+	}
+	return InvalidKind
+}
+
+// AsNode returns the boxed node, and false if the object does not box a node.
+func (o *Object) AsNode() (*node.Node, bool) {
+	return o.n, o.n != nil
+}
+
+// AsPredicate returns the boxed predicate, and false if the object does not
+// box a predicate.
+func (o *Object) AsPredicate() (*predicate.Predicate, bool) {
+	return o.p, o.p != nil
+}
+
+// AsLiteral returns the boxed literal, and false if the object does not box a
+// literal.
+func (o *Object) AsLiteral() (*literal.Literal, bool) {
+	return o.l, o.l != nil
+}
+
+// AsTriple returns the boxed embedded triple, and false if the object does not
+// box a triple.
+func (o *Object) AsTriple() (*Triple, bool) {
+	return o.t, o.t != nil
 }
 
 // Node attempts to the return the boxed node.
@@ -87,8 +180,24 @@ func (o *Object) Literal() (*literal.Literal, error) {
 	return o.l, nil
 }
 
-// ParseObject attempts to parse and object.
+// Triple attempts to the return the boxed embedded triple.
+func (o *Object) Triple() (*Triple, error) {
+	if o.t == nil {
+		return nil, fmt.Errorf("triple.Object does not box a triple in %s", o)
+	}
+	return o.t, nil
+}
+
+// ParseObject attempts to parse and object. Embedded triples are expected to
+// be enclosed between << and >>.
 func ParseObject(s string, b literal.Builder) (*Object, error) {
+	if strings.HasPrefix(s, "<<") && strings.HasSuffix(s, ">>") {
+		t, err := ParseTriple(s[2:len(s)-2], b)
+		if err != nil {
+			return nil, err
+		}
+		return NewTripleObject(t), nil
+	}
 	n, err := node.Parse(s)
 	if err == nil {
 		return NewNodeObject(n), nil
@@ -126,6 +235,14 @@ func NewLiteralObject(l *literal.Literal) *Object {
 	}
 }
 
+// NewTripleObject returns a new object that boxes an embedded triple. Embedded
+// triples allow making statements about other statements.
+func NewTripleObject(t *Triple) *Object {
+	return &Object{
+		t: t,
+	}
+}
+
 // Triple describes a the <subject predicate object> used by BadWolf.
 type Triple struct {
 	s *node.Node
@@ -172,84 +289,321 @@ var (
 
 func init() {
 	pSplit = regexp.MustCompile(">\\s+\"")
-	oSplit = regexp.MustCompile("(]\\s+/)|(]\\s+\")")
+	oSplit = regexp.MustCompile("(]\\s+/)|(]\\s+\")|(]\\s+<)")
 }
 
 // ParseTriple process the provided text and tries to create a triple. It asumes
 // that the provided text contains only one triple.
 func ParseTriple(line string, b literal.Builder) (*Triple, error) {
+	t, _, err := parseTriple(line, b)
+	return t, err
+}
+
+// parseTriple works as ParseTriple, but on failure it also returns the byte
+// offset in line of the element that could not be parsed.
+func parseTriple(line string, b literal.Builder) (*Triple, int, error) {
 	raw := strings.TrimSpace(line)
-	idxp := pSplit.FindIndex([]byte(raw))
-	idxo := oSplit.FindIndex([]byte(raw))
-	if len(idxp) == 0 || len(idxo) == 0 {
-		return nil, fmt.Errorf("triple.Parse could not split s p o  out of %s", raw)
+	off := strings.Index(line, raw)
+	// Node IDs may contain escaped delimiters, so the subject is split at the
+	// first unescaped '>'.
+	end := nodeEnd(raw)
+	if end < 0 {
+		return nil, off, fmt.Errorf("triple.Parse could not split s p o  out of %s", raw)
+	}
+	idxp := pSplit.FindIndex([]byte(raw[end:]))
+	if len(idxp) == 0 || idxp[0] != 0 {
+		return nil, off + end + 1, fmt.Errorf("triple.Parse could not split s p o  out of %s", raw)
 	}
+	idxp[0], idxp[1] = idxp[0]+end, idxp[1]+end
+	idxo := oSplit.FindIndex([]byte(raw[idxp[1]:]))
+	if len(idxo) == 0 {
+		return nil, off + idxp[1] - 1, fmt.Errorf("triple.Parse could not split s p o  out of %s", raw)
+	}
+	idxo[0], idxo[1] = idxo[0]+idxp[1], idxo[1]+idxp[1]
 	ss, sp, so := raw[0:idxp[0]+1], raw[idxp[1]-1:idxo[0]+1], raw[idxo[1]-1:]
 	s, err := node.Parse(ss)
 	if err != nil {
-		return nil, fmt.Errorf("triple.Parse failed to parse subject %s with error %v", ss, err)
+		return nil, off, fmt.Errorf("triple.Parse failed to parse subject %s with error %v", ss, err)
 	}
 	p, err := predicate.Parse(sp)
 	if err != nil {
-		return nil, fmt.Errorf("triple.Parse failed to parse predicate %s with error %v", sp, err)
+		return nil, off + idxp[1] - 1, fmt.Errorf("triple.Parse failed to parse predicate %s with error %v", sp, err)
 	}
 	o, err := ParseObject(so, b)
 	if err != nil {
-		return nil, fmt.Errorf("triple.Parse failed to parse object %s with error %v", so, err)
+		return nil, off + idxo[1] - 1, fmt.Errorf("triple.Parse failed to parse object %s with error %v", so, err)
+	}
+	t, err := New(s, p, o)
+	if err != nil {
+		return nil, off, err
 	}
-	return New(s, p, o)
+	return t, 0, nil
 }
 
+// nodeEnd returns the index of the '>' closing the node the provided text
+// starts with, skipping the characters escaped in its ID, or -1 if there is
+// none.
+func nodeEnd(s string) int {
+	for i := strings.Index(s, "<") + 1; i > 0 && i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// Reserved predicate IDs used to link the blank node standing for a reified
+// triple to the subject, predicate, and object of the triple.
+const (
+	ReifiedSubject   = "_subject"
+	ReifiedPredicate = "_predicate"
+	ReifiedObject    = "_object"
+)
+
 // Reify given the current triple it returns the original triple and the newly
-// reified ones. It also returns the newly created blank node.
+// reified ones. It also returns the newly created blank node. The reified
+// triples link the blank node to the subject, predicate, and object of the
+// triple using the reserved _subject, _predicate, and _object predicates.
+// Reifying temporal triples anchors the reified triples at the time anchor, or
+// the validity interval, of the original triple.
 func (t *Triple) Reify() ([]*Triple, *node.Node, error) {
 	// Function that create the proper reification predicates.
-	rp := func(id string, p *predicate.Predicate) (*predicate.Predicate, error) {
-		if p.Type() == predicate.Temporal {
-			ta, _ := p.TimeAnchor()
-			return predicate.NewTemporal(string(p.ID()), *ta)
+	rp := func(id string) (*predicate.Predicate, error) {
+		if start, end, ok := t.p.Interval(); ok {
+			return predicate.NewInterval(id, start, end)
+		}
+		if t.p.Type() == predicate.Temporal {
+			ta, _ := t.p.TimeAnchor()
+			return predicate.NewTemporalWithGranularity(id, *ta, t.p.Granularity())
 		}
 		return predicate.NewImmutable(id)
 	}
 	b := node.NewBlankNode()
-	s, err := rp("_subject", t.p)
-	if err != nil {
-		return nil, nil, err
-	}
-	ts, _ := New(b, s, NewNodeObject(t.s))
-	p, err := rp("_predicate", t.p)
-	if err != nil {
-		return nil, nil, err
-	}
-	tp, _ := New(b, p, NewPredicateObject(t.p))
-	var to *Triple
-	if t.o.l != nil {
-		o, err := rp("_object", t.p)
+	rts := []*Triple{t}
+	for _, r := range []struct {
+		id string
+		o  *Object
+	}{
+		{ReifiedSubject, NewNodeObject(t.s)},
+		{ReifiedPredicate, NewPredicateObject(t.p)},
+		{ReifiedObject, t.o},
+	} {
+		p, err := rp(r.id)
 		if err != nil {
 			return nil, nil, err
 		}
-		to, _ = New(b, o, NewLiteralObject(t.o.l))
-	}
-	if t.o.n != nil {
-		o, err := rp("_object", t.p)
+		rt, err := New(b, p, r.o)
 		if err != nil {
 			return nil, nil, err
 		}
-		to, _ = New(b, o, NewNodeObject(t.o.n))
+		rts = append(rts, rt)
+	}
+	return rts, b, nil
+}
+
+// Reassemble returns the triples reified by the provided ones. The reified
+// triples are grouped by their subject, and each group must link its subject
+// to exactly one subject, predicate, and object. Triples not using the
+// reserved reification predicates are ignored. The reassembled triples are
+// returned in the order their subjects first appear.
+func Reassemble(ts []*Triple) ([]*Triple, error) {
+	type parts struct {
+		s *node.Node
+		p *predicate.Predicate
+		o *Object
+	}
+	var order []string
+	groups := make(map[string]*parts)
+	for _, t := range ts {
+		id := string(t.p.ID())
+		if id != ReifiedSubject && id != ReifiedPredicate && id != ReifiedObject {
+			continue
+		}
+		k := t.s.String()
+		g, ok := groups[k]
+		if !ok {
+			g = &parts{}
+			groups[k] = g
+			order = append(order, k)
+		}
+		dup := false
+		switch id {
+		case ReifiedSubject:
+			n, err := t.o.Node()
+			if err != nil {
+				return nil, fmt.Errorf("triple.Reassemble requires a node object for %v", t)
+			}
+			dup, g.s = g.s != nil, n
+		case ReifiedPredicate:
+			p, err := t.o.Predicate()
+			if err != nil {
+				return nil, fmt.Errorf("triple.Reassemble requires a predicate object for %v", t)
+			}
+			dup, g.p = g.p != nil, p
+		case ReifiedObject:
+			dup, g.o = g.o != nil, t.o
+		}
+		if dup {
+			return nil, fmt.Errorf("triple.Reassemble found more than one %s for %s", id, k)
+		}
 	}
-	if t.o.p != nil {
-		o, err := rp("_object", t.p)
+	var res []*Triple
+	for _, k := range order {
+		g := groups[k]
+		if g.s == nil || g.p == nil || g.o == nil {
+			return nil, fmt.Errorf("triple.Reassemble found an incomplete reification for %s", k)
+		}
+		t, err := New(g.s, g.p, g.o)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
-		to, _ = New(b, o, NewPredicateObject(t.o.p))
+		res = append(res, t)
 	}
+	return res, nil
+}
 
-	return []*Triple{t, ts, tp, to}, b, nil
+// CanonicalString returns the pretty printed version of the triple with all
+// the time anchors of its predicates expressed in UTC. Triples holding the same
+// values share the same canonical string.
+func (t *Triple) CanonicalString() string {
+	return fmt.Sprintf("%s\t%s\t%s", t.s, t.p.CanonicalString(), t.o.CanonicalString())
 }
 
-// GUID returns a global unique identifier for the given triple. It is
-// implemented as the base64 encoded stringified version of the triple.
+// GUID returns a global unique identifier for the given triple. It is the
+// base64 encoded SHA-256 hash of the GUIDs of the subject, the predicate, and
+// the object joined by colons. Component GUIDs are base64 encoded, hence they
+// never contain colons. Since component GUIDs are built from canonical strings,
+// the triple GUID does not depend on the time zones of the time anchors.
 func (t *Triple) GUID() string {
-	return base64.StdEncoding.EncodeToString([]byte(t.String()))
+	h := sha256.Sum256([]byte(strings.Join([]string{t.s.GUID(), t.p.GUID(), t.o.GUID()}, ":")))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// Kinds of the objects on their binary encoding.
+const (
+	nodeObject byte = iota
+	predicateObject
+	literalObject
+	tripleObject
+)
+
+// MarshalBinary returns the binary encoding of the object. It contains a byte
+// identifying the kind of the boxed value followed by its binary encoding.
+func (o *Object) MarshalBinary() ([]byte, error) {
+	var (
+		k   byte
+		b   []byte
+		err error
+	)
+	switch {
+	case o.n != nil:
+		k = nodeObject
+		b, err = o.n.MarshalBinary()
+	case o.p != nil:
+		k = predicateObject
+		b, err = o.p.MarshalBinary()
+	case o.l != nil:
+		k = literalObject
+		b, err = o.l.MarshalBinary()
+	case o.t != nil:
+		k = tripleObject
+		b, err = o.t.MarshalBinary()
+	default:
+		return nil, fmt.Errorf("triple.MarshalBinary cannot encode an empty object")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{k}, b...), nil
+}
+
+// UnmarshalBinary sets the object to the one encoded in the provided data by
+// MarshalBinary.
+func (o *Object) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("triple.UnmarshalBinary cannot decode an empty object")
+	}
+	no := &Object{}
+	switch data[0] {
+	case nodeObject:
+		no.n = &node.Node{}
+		if err := no.n.UnmarshalBinary(data[1:]); err != nil {
+			return err
+		}
+	case predicateObject:
+		no.p = &predicate.Predicate{}
+		if err := no.p.UnmarshalBinary(data[1:]); err != nil {
+			return err
+		}
+	case literalObject:
+		no.l = &literal.Literal{}
+		if err := no.l.UnmarshalBinary(data[1:]); err != nil {
+			return err
+		}
+	case tripleObject:
+		no.t = &Triple{}
+		if err := no.t.UnmarshalBinary(data[1:]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("triple.UnmarshalBinary found unknown object kind %d", data[0])
+	}
+	*o = *no
+	return nil
+}
+
+// MarshalBinary returns the binary encoding of the triple. It contains the
+// binary encodings of the subject and the predicate, each one prefixed by its
+// length as an unsigned varint, followed by the binary encoding of the object.
+func (t *Triple) MarshalBinary() ([]byte, error) {
+	var b []byte
+	for _, c := range []interface {
+		MarshalBinary() ([]byte, error)
+	}{t.s, t.p} {
+		cb, err := c.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = appendUvarint(b, uint64(len(cb)))
+		b = append(b, cb...)
+	}
+	ob, err := t.o.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(b, ob...), nil
+}
+
+// UnmarshalBinary sets the triple to the one encoded in the provided data by
+// MarshalBinary.
+func (t *Triple) UnmarshalBinary(data []byte) error {
+	var cs [2][]byte
+	for i := range cs {
+		l, k := binary.Uvarint(data)
+		if k <= 0 || uint64(len(data)-k) < l {
+			return fmt.Errorf("triple.UnmarshalBinary found an invalid encoded triple")
+		}
+		cs[i], data = data[k:k+int(l)], data[k+int(l):]
+	}
+	s, p, o := &node.Node{}, &predicate.Predicate{}, &Object{}
+	if err := s.UnmarshalBinary(cs[0]); err != nil {
+		return err
+	}
+	if err := p.UnmarshalBinary(cs[1]); err != nil {
+		return err
+	}
+	if err := o.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	t.s, t.p, t.o = s, p, o
+	return nil
+}
+
+// appendUvarint appends the unsigned varint encoding of the provided value.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
 }
@@ -15,6 +15,8 @@
 package triple
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"testing"
 
 	"github.com/google/badwolf/triple/literal"
@@ -72,6 +74,7 @@ func TestParsetriple(t *testing.T) {
 	ss := []string{
 		"/some/type<some id>\t\"foo\"@[]\t/some/type<some id>",
 		"/some/type<some id>\t\"foo\"@[]\t\"bar\"@[]",
+		"/some/type<some id>\t\"foo\"@[]\t<</some/type<other id> \"bar\"@[] /some/type<some id>>>",
 	}
 	for _, s := range ss {
 		if _, err := ParseTriple(s, literal.DefaultBuilder()); err != nil {
@@ -80,6 +83,32 @@ func TestParsetriple(t *testing.T) {
 	}
 }
 
+func TestParseTripleEscapedIDs(t *testing.T) {
+	s, err := node.NewNodeFromStrings("/some/type", "a> \"b\"\tc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := node.NewNodeFromStrings("/some/type", "] /x<y>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.NewImmutable("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := New(s, p, NewNodeObject(o))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseTriple(tr.String(), literal.DefaultBuilder())
+	if err != nil {
+		t.Fatalf("triple.ParseTriple(%q) failed with error %v", tr, err)
+	}
+	if got.S().ID().String() != s.ID().String() || got.O().String() != tr.O().String() {
+		t.Errorf("triple.ParseTriple(%q) returned %q; want %q", tr, got, tr)
+	}
+}
+
 func TestReify(t *testing.T) {
 	tr, err := ParseTriple("/some/type<some id>\t\"foo\"@[]\t\"bar\"@[]", literal.DefaultBuilder())
 	if err != nil {
@@ -93,3 +122,236 @@ func TestReify(t *testing.T) {
 		t.Errorf("triple.Reify failed to create 4 valid triples and a valid blank node; returned %v, %s instead", rts, bn)
 	}
 }
+
+func TestEmbeddedTripleObject(t *testing.T) {
+	b := literal.DefaultBuilder()
+	in := `<</some/type<some id> "foo"@[] <</some/type<other id> "bar"@[] "1"^^type:int64>>>>`
+	o, err := ParseObject(in, b)
+	if err != nil {
+		t.Fatalf("triple.ParseObject failed to parse %q with error %v", in, err)
+	}
+	if got, want := o.String(), in; got != want {
+		t.Errorf("triple.ParseObject returned the wrong object; got %s, want %s", got, want)
+	}
+	et, err := o.Triple()
+	if err != nil {
+		t.Fatalf("object.Triple failed to return the embedded triple with error %v", err)
+	}
+	if _, err := et.O().Triple(); err != nil {
+		t.Errorf("object.Triple failed to return the nested embedded triple with error %v", err)
+	}
+	if _, err := o.Node(); err == nil {
+		t.Errorf("object.Node should have failed for embedded triple %s", o)
+	}
+	if _, err := NewNodeObject(et.S()).Triple(); err == nil {
+		t.Errorf("object.Triple should have failed for node %s", et.S())
+	}
+	if o.GUID() == NewNodeObject(et.S()).GUID() {
+		t.Errorf("object.GUID should differ for different objects")
+	}
+	for _, bad := range []string{`<<>>`, `<</some/type<some id> "foo"@[]>>`} {
+		if _, err := ParseObject(bad, b); err == nil {
+			t.Errorf("triple.ParseObject should have failed to parse %q", bad)
+		}
+	}
+}
+
+func TestObjectKind(t *testing.T) {
+	b := literal.DefaultBuilder()
+	n, err := node.Parse("/some/type<some id>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.Parse(`"foo"@[]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := b.Parse(`"1"^^type:int64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	et, err := New(n, p, NewLiteralObject(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := []struct {
+		o    *Object
+		want Kind
+		s    string
+	}{
+		{NewNodeObject(n), NodeKind, "node"},
+		{NewPredicateObject(p), PredicateKind, "predicate"},
+		{NewLiteralObject(l), LiteralKind, "literal"},
+		{NewTripleObject(et), TripleKind, "triple"},
+		{&Object{}, InvalidKind, "invalid"},
+	}
+	for _, entry := range table {
+		o := entry.o
+		if got, want := o.Kind(), entry.want; got != want {
+			t.Errorf("object.Kind for %v returned %v; want %v", o, got, want)
+		}
+		if got, want := o.Kind().String(), entry.s; got != want {
+			t.Errorf("Kind.String returned %q; want %q", got, want)
+		}
+		if gn, ok := o.AsNode(); ok != (entry.want == NodeKind) || (ok && gn != n) {
+			t.Errorf("object.AsNode for %v returned (%v, %v)", o, gn, ok)
+		}
+		if gp, ok := o.AsPredicate(); ok != (entry.want == PredicateKind) || (ok && gp != p) {
+			t.Errorf("object.AsPredicate for %v returned (%v, %v)", o, gp, ok)
+		}
+		if gl, ok := o.AsLiteral(); ok != (entry.want == LiteralKind) || (ok && gl != l) {
+			t.Errorf("object.AsLiteral for %v returned (%v, %v)", o, gl, ok)
+		}
+		if gt, ok := o.AsTriple(); ok != (entry.want == TripleKind) || (ok && gt != et) {
+			t.Errorf("object.AsTriple for %v returned (%v, %v)", o, gt, ok)
+		}
+	}
+}
+
+func TestBinaryEncoding(t *testing.T) {
+	table := []string{
+		"/some/type<some id>\t\"foo\"@[]\t/some/type<some id>",
+		"/some/type<some id>\t\"foo\"@[2015-01-01T00:00:00Z]\t\"bar\"@[]",
+		"/some/type<some id>\t\"foo\"@[]\t\"1.5\"^^type:float64",
+		"/some/type<some id>\t\"foo\"@[]\t<</u<a> \"p\"@[] \"x\"^^type:text>>",
+	}
+	for _, s := range table {
+		tr, err := ParseTriple(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.ParseTriple(%q) failed with error %v", s, err)
+		}
+		b, err := tr.MarshalBinary()
+		if err != nil {
+			t.Fatalf("triple.MarshalBinary(%v) failed with error %v", tr, err)
+		}
+		got := &Triple{}
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("triple.UnmarshalBinary(%q) failed with error %v", b, err)
+		}
+		if got.String() != tr.String() {
+			t.Errorf("triple.UnmarshalBinary returned the wrong triple; got %v, want %v", got, tr)
+		}
+	}
+	tr, err := ParseTriple(table[0], literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tr.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][]byte{nil, b[:3], b[:len(b)-20], append(append([]byte{}, b[:len(b)-len("/some/type<some id>")-2]...), 9)} {
+		if err := (&Triple{}).UnmarshalBinary(bad); err == nil {
+			t.Errorf("triple.UnmarshalBinary(%q) should have failed", bad)
+		}
+	}
+	if _, err := (&Object{}).MarshalBinary(); err == nil {
+		t.Errorf("triple.MarshalBinary should have failed for an empty object")
+	}
+}
+
+func TestCanonicalStringAndGUID(t *testing.T) {
+	b := literal.DefaultBuilder()
+	mustParse := func(s string) *Triple {
+		tr, err := ParseTriple(s, b)
+		if err != nil {
+			t.Fatalf("triple.ParseTriple(%q) failed with error %v", s, err)
+		}
+		return tr
+	}
+	utc := mustParse("/u<a>\t\"p\"@[2015-01-01T08:00:00Z]\t<</u<b> \"q\"@[2015-01-01T08:00:00Z] \"r\"@[]>>")
+	pst := mustParse("/u<a>\t\"p\"@[2015-01-01T00:00:00-08:00]\t<</u<b> \"q\"@[2015-01-01T00:00:00-08:00] \"r\"@[]>>")
+	if utc.String() == pst.String() {
+		t.Fatalf("triples anchored on different time zones should print differently")
+	}
+	if got, want := pst.CanonicalString(), utc.String(); got != want {
+		t.Errorf("triple.CanonicalString returned the wrong string; got %q, want %q", got, want)
+	}
+	if utc.GUID() != pst.GUID() {
+		t.Errorf("triples holding the same values should share the same GUID; got %q and %q", utc.GUID(), pst.GUID())
+	}
+	h := sha256.Sum256([]byte(utc.S().GUID() + ":" + utc.P().GUID() + ":" + utc.O().GUID()))
+	if got, want := utc.GUID(), base64.StdEncoding.EncodeToString(h[:]); got != want {
+		t.Errorf("triple.GUID does not follow the documented algorithm; got %q, want %q", got, want)
+	}
+	other := mustParse("/u<a>\t\"p\"@[2015-01-01T08:00:00Z]\t/u<b>")
+	if utc.GUID() == other.GUID() {
+		t.Errorf("different triples should have different GUIDs; got %q for %v and %v", utc.GUID(), utc, other)
+	}
+}
+
+func TestReifyAndReassemble(t *testing.T) {
+	b := literal.DefaultBuilder()
+	table := []string{
+		"/some/type<some id>\t\"foo\"@[]\t\"bar\"@[]",
+		"/u<john>\t\"met\"@[2006-01-02T15:04:05Z]\t/u<mary>",
+		"/u<john>\t\"age\"@[]\t\"42\"^^type:int64",
+		"/u<john>\t\"said\"@[]\t<</u<mary> \"likes\"@[] /u<peter>>>",
+		"/u<john>\t\"born\"@[1975]\t/l<paris>",
+		"/u<john>\t\"works_at\"@[2015-01-01T00:00:00Z,2016-01-01T00:00:00Z]\t/c<acme>",
+	}
+	var all, want []*Triple
+	for _, s := range table {
+		tr, err := ParseTriple(s, b)
+		if err != nil {
+			t.Fatalf("triple.ParseTriple(%q) failed with error %v", s, err)
+		}
+		rts, bn, err := tr.Reify()
+		if err != nil {
+			t.Fatalf("triple.Reify(%v) failed with error %v", tr, err)
+		}
+		if len(rts) != 4 || rts[0] != tr {
+			t.Fatalf("triple.Reify(%v) should return the triple and three reified ones; got %v", tr, rts)
+		}
+		for i, id := range []string{ReifiedSubject, ReifiedPredicate, ReifiedObject} {
+			rt := rts[i+1]
+			if rt.S() != bn || string(rt.P().ID()) != id || rt.P().Type() != tr.P().Type() {
+				t.Errorf("triple.Reify(%v) returned the wrong reified triple %v", tr, rt)
+			}
+			if tr.P().Type() == predicate.Temporal {
+				f1, l1, _ := tr.P().TimeSpan()
+				f2, l2, _ := rt.P().TimeSpan()
+				if !f1.Equal(f2) || !l1.Equal(l2) {
+					t.Errorf("triple.Reify(%v) returned reified triple %v with a different time span", tr, rt)
+				}
+			}
+		}
+		all = append(all, rts...)
+		want = append(want, tr)
+	}
+	got, err := Reassemble(all)
+	if err != nil {
+		t.Fatalf("triple.Reassemble failed with error %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("triple.Reassemble returned the wrong number of triples; got %d, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].String() != want[i].String() {
+			t.Errorf("triple.Reassemble returned the wrong triple at position %d; got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReassembleErrors(t *testing.T) {
+	b := literal.DefaultBuilder()
+	table := [][]string{
+		{"/_<x>\t\"_subject\"@[]\t/u<a>", "/_<x>\t\"_predicate\"@[]\t\"p\"@[]"},
+		{"/_<x>\t\"_subject\"@[]\t\"1\"^^type:int64", "/_<x>\t\"_predicate\"@[]\t\"p\"@[]", "/_<x>\t\"_object\"@[]\t/u<b>"},
+		{"/_<x>\t\"_subject\"@[]\t/u<a>", "/_<x>\t\"_predicate\"@[]\t/u<p>", "/_<x>\t\"_object\"@[]\t/u<b>"},
+		{"/_<x>\t\"_subject\"@[]\t/u<a>", "/_<x>\t\"_subject\"@[]\t/u<c>", "/_<x>\t\"_predicate\"@[]\t\"p\"@[]", "/_<x>\t\"_object\"@[]\t/u<b>"},
+	}
+	for _, entry := range table {
+		var ts []*Triple
+		for _, s := range entry {
+			tr, err := ParseTriple(s, b)
+			if err != nil {
+				t.Fatalf("triple.ParseTriple(%q) failed with error %v", s, err)
+			}
+			ts = append(ts, tr)
+		}
+		if _, err := Reassemble(ts); err == nil {
+			t.Errorf("triple.Reassemble(%v) should have failed", ts)
+		}
+	}
+}
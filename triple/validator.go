@@ -0,0 +1,100 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triple
+
+import (
+	"fmt"
+
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// Validator checks that triples satisfy a set of constraints before they get
+// stored.
+type Validator interface {
+	// Validate returns an error describing why the triple is not valid, or nil
+	// if it satisfies all the constraints.
+	Validate(t *Triple) error
+}
+
+// ValidatorFunc allows to use plain functions as validators.
+type ValidatorFunc func(t *Triple) error
+
+// Validate calls f(t).
+func (f ValidatorFunc) Validate(t *Triple) error {
+	return f(t)
+}
+
+// RuleSet composes validators. A triple is valid if it satisfies all the rules
+// in the set. Rule sets are validators themselves, so they can be nested.
+type RuleSet []Validator
+
+// Validate returns the error of the first rule the triple does not satisfy.
+func (rs RuleSet) Validate(t *Triple) error {
+	for _, r := range rs {
+		if err := r.Validate(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateAll returns an error for the first triple the validator rejects.
+func ValidateAll(v Validator, ts []*Triple) error {
+	for _, t := range ts {
+		if err := v.Validate(t); err != nil {
+			return fmt.Errorf("triple.ValidateAll rejected %s; %v", t, err)
+		}
+	}
+	return nil
+}
+
+// AllowedPredicates returns a validator that only accepts the provided
+// predicate IDs for the subjects of type st or any of its subtypes. Triples
+// with subjects of other types are not constrained.
+func AllowedPredicates(st *node.Type, ids ...predicate.ID) Validator {
+	allowed := make(map[predicate.ID]bool, len(ids))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+	return ValidatorFunc(func(t *Triple) error {
+		if !t.S().Type().IsSubtypeOf(st) || allowed[t.P().ID()] {
+			return nil
+		}
+		return fmt.Errorf("predicate %q is not allowed for subjects of type %s", t.P().ID(), st)
+	})
+}
+
+// LiteralTypes returns a validator that requires the objects of the triples
+// using predicate ID id to be literals of one of the provided types. Triples
+// with other predicates are not constrained.
+func LiteralTypes(id predicate.ID, lts ...literal.Type) Validator {
+	return ValidatorFunc(func(t *Triple) error {
+		if t.P().ID() != id {
+			return nil
+		}
+		l, ok := t.O().AsLiteral()
+		if !ok {
+			return fmt.Errorf("predicate %q requires a literal object, got %s", id, t.O())
+		}
+		for _, lt := range lts {
+			if l.Type() == lt {
+				return nil
+			}
+		}
+		return fmt.Errorf("predicate %q does not allow literals of type %s", id, l.Type())
+	})
+}
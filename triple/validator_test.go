@@ -0,0 +1,81 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triple
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+)
+
+func TestRuleSet(t *testing.T) {
+	b := literal.DefaultBuilder()
+	person, err := node.NewType("/u/person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := RuleSet{
+		AllowedPredicates(person, "name", "age"),
+		LiteralTypes("name", literal.Text),
+		LiteralTypes("age", literal.Int64),
+	}
+	table := []struct {
+		t     string
+		valid bool
+	}{
+		{"/u/person<joe>\t\"name\"@[]\t\"Joe\"^^type:text", true},
+		{"/u/person/admin<ann>\t\"age\"@[]\t\"42\"^^type:int64", true},
+		{"/u<joe>\t\"likes\"@[]\t/u<mary>", true},
+		{"/u/person<joe>\t\"likes\"@[]\t/u<mary>", false},
+		{"/u/person/admin<ann>\t\"likes\"@[]\t/u<mary>", false},
+		{"/u/person<joe>\t\"name\"@[]\t\"42\"^^type:int64", false},
+		{"/u<joe>\t\"age\"@[]\t/u<mary>", false},
+	}
+	for _, entry := range table {
+		tr, err := ParseTriple(entry.t, b)
+		if err != nil {
+			t.Fatalf("triple.ParseTriple(%q) failed with error %v", entry.t, err)
+		}
+		if err := rs.Validate(tr); (err == nil) != entry.valid {
+			t.Errorf("RuleSet.Validate(%q) returned error %v; want valid %v", entry.t, err, entry.valid)
+		}
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	b := literal.DefaultBuilder()
+	var ts []*Triple
+	for _, s := range []string{"/u<joe>\t\"p\"@[]\t/u<mary>", "/u<joe>\t\"q\"@[]\t/u<mary>"} {
+		tr, err := ParseTriple(s, b)
+		if err != nil {
+			t.Fatalf("triple.ParseTriple(%q) failed with error %v", s, err)
+		}
+		ts = append(ts, tr)
+	}
+	v := ValidatorFunc(func(t *Triple) error {
+		if t.P().ID() == "q" {
+			return fmt.Errorf("q is not allowed")
+		}
+		return nil
+	})
+	if err := ValidateAll(v, ts[:1]); err != nil {
+		t.Errorf("triple.ValidateAll failed for valid triples with error %v", err)
+	}
+	if err := ValidateAll(v, ts); err == nil {
+		t.Errorf("triple.ValidateAll should have rejected %v", ts[1])
+	}
+}